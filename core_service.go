@@ -16,8 +16,19 @@ type CoreService struct {
 	ConfigPath                string
 	DataPath                  string
 	resourceManager           *modules.ResourceManager
+	eventHub                  *modules.EventHub
+	webhookManager            *modules.WebhookManager
+	jobManager                *modules.JobManager
+	credentialManager         *modules.CredentialManager
+	auditLogger               *modules.AuditLogger
 	transManager              *CoreTransactionManager
 	apiModule                 *modules.APIModule
+	migrationScheduler        *modules.MigrationScheduler
+	ReplicationPort           int
+	ReplicationSecret         string
+	ImageDataExporter         modules.ReplicationExporter
+	OnFenced                  func(activeAddress string, epoch uint64)
+	replicationServer         *modules.ReplicationServer
 }
 
 func (core *CoreService) GetAPIServiceAddress() string {
@@ -234,23 +245,59 @@ func (core *CoreService) InitialEndpoint() (err error) {
 	log.Printf("<core> domain %s, group address %s:%d", core.GetDomain(), core.GetGroupAddress(), core.GetGroupPort())
 	log.Printf("<core> default operate timeout %d seconds", modules.GetConfigurator().GetOperateTimeout()/time.Second)
 
-	core.resourceManager, err = modules.CreateResourceManager(core.DataPath)
+	if core.eventHub, err = modules.CreateEventHub(); err != nil {
+		return err
+	}
+	core.resourceManager, err = modules.CreateResourceManager(core.DataPath, core.eventHub)
 	if err != nil {
 		return err
 	}
-	core.transManager, err = CreateTransactionManager(core, core.resourceManager)
+	if core.webhookManager, err = modules.CreateWebhookManager(core.DataPath, core.eventHub); err != nil {
+		return err
+	}
+	if core.jobManager, err = modules.CreateJobManager(core.DataPath, core.eventHub); err != nil {
+		return err
+	}
+	if core.credentialManager, err = modules.CreateCredentialManager(core.ConfigPath, core.DataPath); err != nil {
+		return err
+	}
+	if core.auditLogger, err = modules.CreateAuditLogger(core.DataPath); err != nil {
+		return err
+	}
+	core.transManager, err = CreateTransactionManager(core, core.resourceManager, core.eventHub, core.auditLogger)
 	if err != nil {
 		return err
 	}
 
-	core.apiModule, err = modules.CreateAPIModule(core.ConfigPath, core, core.resourceManager)
+	core.apiModule, err = modules.CreateAPIModule(core.ConfigPath, core, core.resourceManager, core.eventHub, core.webhookManager,
+		core.jobManager, core.credentialManager, core.auditLogger)
 	if err != nil {
 		return err
 	}
+	if core.migrationScheduler, err = modules.CreateMigrationScheduler(core, core.resourceManager); err != nil {
+		return err
+	}
 	//register submodules
 	if err = core.RegisterSubmodule(core.apiModule.GetModuleName(), core.apiModule.GetResponseChannel()); err != nil {
 		return err
 	}
+	if err = core.RegisterSubmodule(core.migrationScheduler.GetModuleName(), core.migrationScheduler.GetResponseChannel()); err != nil {
+		return err
+	}
+	if 0 != core.ReplicationPort {
+		var state modules.HAState
+		if state, err = modules.LoadHAState(core.DataPath); err != nil {
+			return err
+		}
+		var exporters = modules.ModuleReplicationExporters(core.resourceManager, core.credentialManager, core.webhookManager,
+			core.jobManager)
+		exporters[modules.ImageDataFilename] = core.ImageDataExporter
+		core.replicationServer, err = modules.CreateReplicationServer(core.ReplicationPort, core.ReplicationSecret, state.Epoch,
+			core.credentialManager.GetSealCheck(), exporters, core.OnFenced)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -258,23 +305,69 @@ func (core *CoreService) OnEndpointStarted() (err error) {
 	if err = core.resourceManager.Start(); err != nil {
 		return err
 	}
+	if err = core.eventHub.Start(); err != nil {
+		return err
+	}
+	if err = core.webhookManager.Start(); err != nil {
+		return err
+	}
+	if err = core.jobManager.Start(); err != nil {
+		return err
+	}
+	if err = core.credentialManager.Start(); err != nil {
+		return err
+	}
+	if err = core.auditLogger.Start(); err != nil {
+		return err
+	}
 	if err = core.transManager.Start(); err != nil {
 		return err
 	}
+	if err = core.migrationScheduler.Start(); err != nil {
+		return err
+	}
 	if err = core.apiModule.Start(); err != nil {
 		return err
 	}
+	if nil != core.replicationServer {
+		if err = core.replicationServer.Start(); err != nil {
+			return err
+		}
+	}
 	log.Print("<core> started")
 	return nil
 }
 
 func (core *CoreService) OnEndpointStopped() {
+	if nil != core.replicationServer {
+		if err := core.replicationServer.Stop(); err != nil {
+			log.Printf("<core> stop replication server fail: %s", err.Error())
+		}
+	}
 	if err := core.apiModule.Stop(); err != nil {
 		log.Printf("<core> stop api module fail: %s", err.Error())
 	}
+	if err := core.migrationScheduler.Stop(); err != nil {
+		log.Printf("<core> stop migration scheduler fail: %s", err.Error())
+	}
 	if err := core.transManager.Stop(); err != nil {
 		log.Printf("<core> stop transaction manager fail: %s", err.Error())
 	}
+	if err := core.auditLogger.Stop(); err != nil {
+		log.Printf("<core> stop audit logger fail: %s", err.Error())
+	}
+	if err := core.credentialManager.Stop(); err != nil {
+		log.Printf("<core> stop credential manager fail: %s", err.Error())
+	}
+	if err := core.jobManager.Stop(); err != nil {
+		log.Printf("<core> stop job manager fail: %s", err.Error())
+	}
+	if err := core.webhookManager.Stop(); err != nil {
+		log.Printf("<core> stop webhook manager fail: %s", err.Error())
+	}
+	if err := core.eventHub.Stop(); err != nil {
+		log.Printf("<core> stop event hub fail: %s", err.Error())
+	}
 	if err := core.resourceManager.Stop(); err != nil {
 		log.Printf("<core> stop compute pool module fail: %s", err.Error())
 	}
@@ -11,18 +11,102 @@ import (
 
 type CoreTransactionManager struct {
 	*framework.TransactionEngine
+	audit modules.AuditModule
 }
 
-func CreateTransactionManager(sender framework.MessageSender, resourceModule modules.ResourceModule) (manager *CoreTransactionManager, err error) {
+//state-changing transactions recorded by audit module
+var auditedTransactions = map[framework.MessageID]string{
+	framework.CreateComputePoolRequest:            "CreateComputePool",
+	framework.ModifyComputePoolRequest:            "ModifyComputePool",
+	framework.DeleteComputePoolRequest:            "DeleteComputePool",
+	framework.CreateStoragePoolRequest:            "CreateStoragePool",
+	framework.ModifyStoragePoolRequest:            "ModifyStoragePool",
+	framework.DeleteStoragePoolRequest:            "DeleteStoragePool",
+	framework.CreateAddressPoolRequest:            "CreateAddressPool",
+	framework.ModifyAddressPoolRequest:            "ModifyAddressPool",
+	framework.DeleteAddressPoolRequest:            "DeleteAddressPool",
+	framework.AddAddressRangeRequest:              "AddAddressRange",
+	framework.RemoveAddressRangeRequest:           "RemoveAddressRange",
+	framework.AddComputePoolCellRequest:           "AddComputePoolCell",
+	framework.RemoveComputePoolCellRequest:        "RemoveComputePoolCell",
+	framework.EnableComputePoolCellRequest:        "EnableComputePoolCell",
+	framework.DisableComputePoolCellRequest:       "DisableComputePoolCell",
+	framework.ComputeCellAvailableEvent:           "ComputeCellAvailable",
+	framework.ComputeCellDisconnectedEvent:        "ComputeCellDisconnected",
+	framework.CreateGuestRequest:                  "CreateGuest",
+	framework.DeleteGuestRequest:                  "DeleteGuest",
+	framework.ModifyGuestNameRequest:              "ModifyGuestName",
+	framework.ModifyCoreRequest:                   "ModifyCore",
+	framework.ModifyMemoryRequest:                 "ModifyMemory",
+	framework.ModifyPriorityRequest:               "ModifyPriority",
+	framework.ModifyNetworkThresholdRequest:       "ModifyNetworkThreshold",
+	framework.ModifyDiskThresholdRequest:          "ModifyDiskThreshold",
+	framework.ResizeDiskRequest:                   "ResizeDisk",
+	framework.ShrinkDiskRequest:                   "ShrinkDisk",
+	framework.ResetSystemRequest:                  "ResetSystem",
+	framework.ModifyAuthRequest:                   "ModifyAuth",
+	framework.ResetSecretRequest:                  "ResetSecret",
+	framework.StartInstanceRequest:                "StartInstance",
+	framework.StopInstanceRequest:                 "StopInstance",
+	framework.ModifyAutoStartRequest:              "ModifyAutoStart",
+	framework.CreateMediaImageRequest:             "CreateMediaImage",
+	framework.ModifyMediaImageRequest:             "ModifyMediaImage",
+	framework.DeleteMediaImageRequest:             "DeleteMediaImage",
+	framework.SynchronizeMediaImageRequest:        "SynchronizeMediaImage",
+	framework.CreateDiskImageRequest:              "CreateDiskImage",
+	framework.ModifyDiskImageRequest:              "ModifyDiskImage",
+	framework.DeleteDiskImageRequest:              "DeleteDiskImage",
+	framework.SynchronizeDiskImageRequest:         "SynchronizeDiskImage",
+	framework.GuestCreatedEvent:                   "GuestCreated",
+	framework.GuestDeletedEvent:                   "GuestDeleted",
+	framework.GuestStartedEvent:                   "GuestStarted",
+	framework.GuestStoppedEvent:                   "GuestStopped",
+	framework.GuestUpdatedEvent:                   "GuestUpdated",
+	framework.SystemResetEvent:                    "SystemReset",
+	framework.StartBatchCreateGuestRequest:        "StartBatchCreateGuest",
+	framework.StartBatchDeleteGuestRequest:        "StartBatchDeleteGuest",
+	framework.StartBatchStopGuestRequest:          "StartBatchStopGuest",
+	framework.InsertMediaRequest:                  "InsertMedia",
+	framework.EjectMediaRequest:                   "EjectMedia",
+	framework.MediaAttachedEvent:                  "MediaAttached",
+	framework.MediaDetachedEvent:                  "MediaDetached",
+	framework.CreateSnapshotRequest:               "CreateSnapshot",
+	framework.DeleteSnapshotRequest:               "DeleteSnapshot",
+	framework.RestoreSnapshotRequest:              "RestoreSnapshot",
+	framework.CreateMigrationRequest:              "CreateMigration",
+	framework.InstanceMigratedEvent:               "InstanceMigrated",
+	framework.AddressChangedEvent:                 "AddressChanged",
+	framework.ModifyCellStorageRequest:            "ModifyCellStorage",
+	framework.CreateTemplateRequest:               "CreateTemplate",
+	framework.ModifyTemplateRequest:               "ModifyTemplate",
+	framework.DeleteTemplateRequest:               "DeleteTemplate",
+	framework.ChangeGuestRuleDefaultActionRequest: "ChangeGuestRuleDefaultAction",
+	framework.ChangeGuestRuleOrderRequest:         "ChangeGuestRuleOrder",
+	framework.AddGuestRuleRequest:                 "AddGuestRule",
+	framework.ModifyGuestRuleRequest:              "ModifyGuestRule",
+	framework.RemoveGuestRuleRequest:              "RemoveGuestRule",
+	framework.AddPolicyRuleRequest:                "AddPolicyRule",
+	framework.ModifyPolicyRuleRequest:             "ModifyPolicyRule",
+	framework.RemovePolicyRuleRequest:             "RemovePolicyRule",
+	framework.ChangePolicyRuleOrderRequest:        "ChangePolicyRuleOrder",
+	framework.CreatePolicyGroupRequest:            "CreatePolicyGroup",
+	framework.ModifyPolicyGroupRequest:            "ModifyPolicyGroup",
+	framework.DeletePolicyGroupRequest:            "DeletePolicyGroup",
+}
+
+func CreateTransactionManager(sender framework.MessageSender, resourceModule modules.ResourceModule,
+	eventModule modules.EventModule, auditModule modules.AuditModule) (manager *CoreTransactionManager, err error) {
 	var engine *framework.TransactionEngine
 	if engine, err = framework.CreateTransactionEngine();err != nil{
 		return nil, err
 	}
+	//responses of audited transactions reported before sent
+	sender = modules.AuditSender{MessageSender: sender, Audit: auditModule}
 	client := &http.Client{
 		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
 	}
 
-	manager = &CoreTransactionManager{engine}
+	manager = &CoreTransactionManager{engine, auditModule}
 	if err = manager.RegisterExecutor(framework.QueryComputePoolRequest,
 		&task.QueryComputePoolExecutor{sender, resourceModule}); err != nil{
 		return nil, err
@@ -154,7 +238,7 @@ func CreateTransactionManager(sender framework.MessageSender, resourceModule mod
 		return nil, err
 	}
 	if err = manager.RegisterExecutor(framework.ComputeCellAvailableEvent,
-		&task.HandleCellAvailableExecutor{sender, resourceModule}); err != nil{
+		&task.HandleCellAvailableExecutor{sender, resourceModule, eventModule}); err != nil{
 		return nil, err
 	}
 	if err = manager.RegisterExecutor(framework.GetGuestRequest,
@@ -282,28 +366,28 @@ func CreateTransactionManager(sender framework.MessageSender, resourceModule mod
 	}
 
 	if err = manager.RegisterExecutor(framework.GuestCreatedEvent,
-		&task.HandleGuestCreatedExecutor{sender, resourceModule}); err != nil{
+		&task.HandleGuestCreatedExecutor{sender, resourceModule, eventModule}); err != nil{
 		return nil, err
 	}
 	if err = manager.RegisterExecutor(framework.GuestDeletedEvent,
-		&task.HandleGuestDeletedExecutor{sender, resourceModule}); err != nil{
+		&task.HandleGuestDeletedExecutor{sender, resourceModule, eventModule}); err != nil{
 		return nil, err
 	}
 
 	if err = manager.RegisterExecutor(framework.GuestStartedEvent,
-		&task.HandleGuestStartedExecutor{sender, resourceModule}); err != nil{
+		&task.HandleGuestStartedExecutor{sender, resourceModule, eventModule}); err != nil{
 		return nil, err
 	}
 	if err = manager.RegisterExecutor(framework.GuestStoppedEvent,
-		&task.HandleGuestStoppedExecutor{sender, resourceModule}); err != nil{
+		&task.HandleGuestStoppedExecutor{sender, resourceModule, eventModule}); err != nil{
 		return nil, err
 	}
 	if err = manager.RegisterExecutor(framework.GuestUpdatedEvent,
-		&task.HandleGuestUpdatedExecutor{sender, resourceModule}); err != nil{
+		&task.HandleGuestUpdatedExecutor{sender, resourceModule, eventModule}); err != nil{
 		return nil, err
 	}
 	if err = manager.RegisterExecutor(framework.SystemResetEvent,
-		&task.HandleGuestSystemResetExecutor{resourceModule}); err != nil{
+		&task.HandleGuestSystemResetExecutor{resourceModule, eventModule}); err != nil{
 		return nil, err
 	}
 	//batch
@@ -341,11 +425,11 @@ func CreateTransactionManager(sender framework.MessageSender, resourceModule mod
 		return nil, err
 	}
 	if err = manager.RegisterExecutor(framework.MediaAttachedEvent,
-		&task.HandleMediaAttachedExecutor{sender, resourceModule}); err != nil{
+		&task.HandleMediaAttachedExecutor{sender, resourceModule, eventModule}); err != nil{
 		return nil, err
 	}
 	if err = manager.RegisterExecutor(framework.MediaDetachedEvent,
-		&task.HandleMediaDetachedExecutor{sender, resourceModule}); err != nil{
+		&task.HandleMediaDetachedExecutor{sender, resourceModule, eventModule}); err != nil{
 		return nil, err
 	}
 
@@ -383,7 +467,7 @@ func CreateTransactionManager(sender framework.MessageSender, resourceModule mod
 		return nil, err
 	}
 	if err = manager.RegisterExecutor(framework.InstanceMigratedEvent,
-		&task.HandleInstanceMigratedExecutor{sender, resourceModule}); err != nil{
+		&task.HandleInstanceMigratedExecutor{sender, resourceModule, eventModule}); err != nil{
 		return nil, err
 	}
 	if err = manager.RegisterExecutor(framework.CellStatusReportEvent,
@@ -391,11 +475,11 @@ func CreateTransactionManager(sender framework.MessageSender, resourceModule mod
 		return nil, err
 	}
 	if err = manager.RegisterExecutor(framework.AddressChangedEvent,
-		&task.HandleAddressChangedExecutor{resourceModule}); err != nil{
+		&task.HandleAddressChangedExecutor{resourceModule, eventModule}); err != nil{
 		return nil, err
 	}
 	if err = manager.RegisterExecutor(framework.ComputeCellDisconnectedEvent,
-		&task.HandleCellDisconnectedExecutor{sender, resourceModule}); err != nil{
+		&task.HandleCellDisconnectedExecutor{sender, resourceModule, eventModule}); err != nil{
 		return nil, err
 	}
 	if err = manager.RegisterExecutor(framework.ImageServerAvailableEvent,
@@ -633,3 +717,11 @@ func CreateTransactionManager(sender framework.MessageSender, resourceModule mod
 	}
 	return manager, nil
 }
+
+//RegisterExecutor : executor of state-changing transaction wrapped for audit
+func (manager *CoreTransactionManager) RegisterExecutor(initialMessage framework.MessageID, executor framework.TransactionExecutor) error{
+	if action, exists := auditedTransactions[initialMessage]; exists{
+		executor = &modules.AuditExecutor{Action: action, Executor: executor, Audit: manager.audit}
+	}
+	return manager.TransactionEngine.RegisterExecutor(initialMessage, executor)
+}
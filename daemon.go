@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"github.com/project-nano/core/imageserver"
 	"github.com/project-nano/core/modules"
+	"github.com/project-nano/core/store"
 	"github.com/project-nano/framework"
 	"github.com/project-nano/sonar"
 	"log"
@@ -23,35 +24,76 @@ import (
 )
 
 type DomainConfig struct {
-	Domain        string `json:"domain"`
-	GroupAddress  string `json:"group_address"`
-	GroupPort     int    `json:"group_port"`
-	ListenAddress string `json:"listen_address"`
-	Timeout       int    `json:"timeout,omitempty"`
+	Domain            string   `json:"domain"`
+	GroupAddress      string   `json:"group_address"`
+	GroupPort         int      `json:"group_port"`
+	ListenAddress     string   `json:"listen_address"`
+	Timeout           int      `json:"timeout,omitempty"`
+	TaskRetention     int      `json:"task_retention,omitempty"`
+	MetadataStore     string   `json:"metadata_store,omitempty"`
+	HighAvailability  bool     `json:"high_availability,omitempty"`
+	ReplicationPort   int      `json:"replication_port,omitempty"`
+	ReplicationSecret string   `json:"replication_secret,omitempty"`
+	AutoTakeover      bool     `json:"auto_takeover,omitempty"`
+	WebhookNetworks   []string `json:"webhook_allowed_networks,omitempty"`
 }
 
 type MainService struct {
-	core  *CoreService
-	image *imageserver.ImageService
+	core            *CoreService
+	image           *imageserver.ImageService
+	standby         *StandbyMonitor
+	config          DomainConfig
+	configPath      string
+	dataPath        string
+	listenInterface *net.Interface
 }
 
 const (
-	ProjectName           = "nano"
-	ExecuteName           = "core"
-	DomainConfigFileName  = "domain.cfg"
-	APIConfigFilename     = "api.cfg"
-	ImageConfigFilename   = "image.cfg"
-	ConfigPathName        = "config"
-	DataPathName          = "data"
-	DefaultPathPerm       = 0740
-	DefaultFilePerm       = 0640
-	defaultOperateTimeout = 10 //10 seconds
+	ProjectName            = "nano"
+	ExecuteName            = "core"
+	DomainConfigFileName   = "domain.cfg"
+	APIConfigFilename      = "api.cfg"
+	ImageConfigFilename    = "image.cfg"
+	ConfigPathName         = "config"
+	DataPathName           = "data"
+	DefaultPathPerm        = 0740
+	DefaultFilePerm        = 0640
+	defaultOperateTimeout  = 10   //10 seconds
+	defaultTaskRetention   = 3600 //1 hour
+	defaultReplicationPort = 5860
+	VerifyDataCommand      = "verify-data"
+	CheckUpgradeCommand    = "check-upgrade"
+	CheckUpgradeFlag       = "--check-upgrade"
+	TakeoverCommand        = "takeover"
 )
 
 func (service *MainService) Start() (output string, err error) {
+	if service.config.HighAvailability {
+		var activeAddress string
+		if activeAddress, err = probeActiveCore(service.config); err != nil {
+			log.Printf("no active core available (%s), start as active", err.Error())
+		} else {
+			//another core already active
+			if service.standby, err = CreateStandbyMonitor(service, activeAddress); err != nil {
+				return
+			}
+			if err = service.standby.Start(); err != nil {
+				return
+			}
+			output = fmt.Sprintf("\nCore Module %s\nstandby for active core '%s', replicate to '%s'\ngroup '%s:%d', domain '%s'",
+				CurrentVersion, activeAddress, service.standby.GetDataPath(),
+				service.config.GroupAddress, service.config.GroupPort, service.config.Domain)
+			return
+		}
+	}
+	return service.startActive()
+}
+
+func (service *MainService) startActive() (output string, err error) {
 	if nil == service.core {
-		err = errors.New("invalid service")
-		return
+		if err = service.createEndpoints(); err != nil {
+			return
+		}
 	}
 	if err = service.core.Start(); err != nil {
 		return
@@ -69,7 +111,49 @@ func (service *MainService) Start() (output string, err error) {
 	return
 }
 
+// stepDown : another core took over with newer epoch, stop serving and replicate from it
+func (service *MainService) stepDown(activeAddress string) {
+	if nil != service.standby {
+		//fencing of previous take over
+		if err := service.standby.Stop(); err != nil {
+			log.Printf("stop standby monitor fail: %s", err.Error())
+		}
+		service.standby = nil
+	}
+	if nil != service.core {
+		if err := service.image.Stop(); err != nil {
+			log.Printf("stop image service fail: %s", err.Error())
+		}
+		if err := service.core.Stop(); err != nil {
+			log.Printf("stop core service fail: %s", err.Error())
+		}
+		//endpoints recreated when take over again
+		service.core = nil
+		service.image = nil
+	}
+	monitor, err := CreateStandbyMonitor(service, activeAddress)
+	if err != nil {
+		log.Printf("create standby monitor fail: %s", err.Error())
+		return
+	}
+	if err = monitor.Start(); err != nil {
+		log.Printf("start standby monitor fail: %s", err.Error())
+		return
+	}
+	service.standby = monitor
+	log.Printf("stepped down, standby for active core '%s'", activeAddress)
+}
+
 func (service *MainService) Stop() (output string, err error) {
+	if nil != service.standby {
+		if err = service.standby.Stop(); err != nil {
+			return
+		}
+		if nil == service.core {
+			//never took over
+			return
+		}
+	}
 	if nil == service.core {
 		err = errors.New("invalid service")
 		return
@@ -84,6 +168,10 @@ func (service *MainService) Stop() (output string, err error) {
 }
 
 func (service *MainService) Snapshot() (output string, err error) {
+	if nil != service.standby && nil == service.core {
+		output = service.standby.GetStatus()
+		return
+	}
 	output = "hello, this is stub for snapshot"
 	return
 }
@@ -115,38 +203,219 @@ func createDaemon(workingPath string) (service framework.DaemonizedService, err
 		return
 	}
 
-	endpointCore, err := framework.CreateStubEndpoint(config.GroupAddress, config.GroupPort, config.Domain, config.ListenAddress)
-	if err != nil {
-		return
-	}
 	//set timeout
 	if config.Timeout > 0 {
 		modules.GetConfigurator().SetOperateTimeout(config.Timeout)
 	}
+	if config.TaskRetention > 0 {
+		modules.GetConfigurator().SetTaskRetention(config.TaskRetention)
+	}
+	modules.GetConfigurator().SetMetadataStore(config.MetadataStore)
+	if err = modules.GetConfigurator().SetWebhookAllowedNetworks(config.WebhookNetworks); err != nil {
+		return
+	}
+
+	var s = MainService{config: config, configPath: configPath, dataPath: dataPath, listenInterface: inf}
+	if config.HighAvailability {
+		if "" == config.ReplicationSecret {
+			err = errors.New("replication secret required for high availability")
+			return
+		}
+		if 0 == config.ReplicationPort {
+			s.config.ReplicationPort = defaultReplicationPort
+		}
+		//endpoints created when start as active or take over
+		return &s, nil
+	}
+	if err = s.createEndpoints(); err != nil {
+		return
+	}
+	return &s, nil
+}
 
-	var s = MainService{}
-	s.core = &CoreService{EndpointService: endpointCore, ConfigPath: configPath, DataPath: dataPath}
-	s.core.RegisterHandler(s.core)
-	err = s.core.GenerateName(framework.ServiceTypeCore, inf)
+func (service *MainService) createEndpoints() (err error) {
+	var config = service.config
+	endpointCore, err := framework.CreateStubEndpoint(config.GroupAddress, config.GroupPort, config.Domain, config.ListenAddress)
 	if err != nil {
 		return
 	}
+	var core = &CoreService{EndpointService: endpointCore, ConfigPath: service.configPath, DataPath: service.dataPath}
+	if config.HighAvailability {
+		core.ReplicationPort = config.ReplicationPort
+		core.ReplicationSecret = config.ReplicationSecret
+		core.OnFenced = func(activeAddress string, epoch uint64) {
+			service.stepDown(activeAddress)
+		}
+	}
+	core.RegisterHandler(core)
+	if err = core.GenerateName(framework.ServiceTypeCore, service.listenInterface); err != nil {
+		return
+	}
 	endpointImage, err := framework.CreatePeerEndpoint(config.GroupAddress, config.GroupPort, config.Domain)
 	if err != nil {
 		return
 	}
-	s.image = &imageserver.ImageService{EndpointService: endpointImage, ConfigPath: configPath, DataPath: dataPath}
-	s.image.RegisterHandler(s.image)
-	if err = s.image.GenerateName(framework.ServiceTypeImage, inf); err != nil {
+	var image = &imageserver.ImageService{EndpointService: endpointImage, ConfigPath: service.configPath, DataPath: service.dataPath,
+		MetadataStore: config.MetadataStore}
+	image.RegisterHandler(image)
+	if err = image.GenerateName(framework.ServiceTypeImage, service.listenInterface); err != nil {
 		return
 	}
-	return &s, nil
+	if config.HighAvailability {
+		core.ImageDataExporter = image.ExportImageData
+	}
+	service.core = core
+	service.image = image
+	return nil
 }
 
 func main() {
+	if 2 == len(os.Args) && VerifyDataCommand == os.Args[1] {
+		if err := verifyData(); err != nil {
+			fmt.Printf("verify data fail: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if requested, err := checkUpgradeRequested(os.Args[1:]); err != nil {
+		fmt.Printf("check upgrade fail: %s\n", err.Error())
+		os.Exit(1)
+	} else if requested {
+		if err = checkUpgrade(); err != nil {
+			fmt.Printf("check upgrade fail: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	if 2 == len(os.Args) && TakeoverCommand == os.Args[1] {
+		if err := confirmTakeover(); err != nil {
+			fmt.Printf("confirm take over fail: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
 	framework.ProcessDaemon(ExecuteName, generateConfigure, createDaemon)
 }
 
+// loadOfflineConfig : domain config and data path under working path, used by offline commands
+func loadOfflineConfig() (config DomainConfig, dataPath string, err error) {
+	var executable string
+	if executable, err = os.Executable(); err != nil {
+		return
+	}
+	var workingPath string
+	if workingPath, err = filepath.Abs(filepath.Dir(executable)); err != nil {
+		return
+	}
+	var data []byte
+	if data, err = os.ReadFile(filepath.Join(workingPath, ConfigPathName, DomainConfigFileName)); err != nil {
+		return
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		return
+	}
+	dataPath = filepath.Join(workingPath, DataPathName)
+	return config, dataPath, nil
+}
+
+// confirmTakeover : allow local standby core to take over lost active core, only after the lost core stopped
+func confirmTakeover() (err error) {
+	var config DomainConfig
+	var dataPath string
+	if config, dataPath, err = loadOfflineConfig(); err != nil {
+		return
+	}
+	if !config.HighAvailability {
+		return errors.New("high availability not enabled")
+	}
+	var confirmFile = filepath.Join(dataPath, takeoverConfirmFilename)
+	if err = os.WriteFile(confirmFile, []byte(time.Now().Format(modules.TimeFormatLayout)), DefaultFilePerm); err != nil {
+		return
+	}
+	fmt.Printf("take over confirmed, standby core takes over within %d minutes if active core still lost\n",
+		takeoverConfirmValidity/time.Minute)
+	return nil
+}
+
+// verifyData : check resource data and journal under working path, nothing modified
+func verifyData() (err error) {
+	var config DomainConfig
+	var dataPath string
+	if config, dataPath, err = loadOfflineConfig(); err != nil {
+		return
+	}
+	var report modules.DataVerifyReport
+	if report, err = modules.VerifyResourceData(dataPath, config.MetadataStore); err != nil {
+		return
+	}
+	fmt.Printf("data file '%s'\n", report.DataFile)
+	for _, line := range report.Summary {
+		fmt.Println(line)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Printf("warning: %s\n", warning)
+	}
+	for _, problem := range report.Problems {
+		fmt.Printf("problem: %s\n", problem)
+	}
+	if 0 != len(report.Problems) {
+		return fmt.Errorf("%d problem(s) found", len(report.Problems))
+	}
+	fmt.Println("resource data is consistent")
+	return nil
+}
+
+// checkUpgradeRequested : flag '--check-upgrade' (or '-check-upgrade'), or command 'check-upgrade' kept for
+// compatibility, must be used alone, so a daemon command never starts after checking
+func checkUpgradeRequested(args []string) (requested bool, err error) {
+	for _, arg := range args {
+		if CheckUpgradeFlag == arg || CheckUpgradeFlag[1:] == arg {
+			requested = true
+		}
+	}
+	if !requested {
+		return 1 == len(args) && CheckUpgradeCommand == args[0], nil
+	}
+	if 1 != len(args) {
+		return false, fmt.Errorf("%s can't be used with other arguments", CheckUpgradeFlag)
+	}
+	return true, nil
+}
+
+// checkUpgrade : list schema migrations would run on next start, nothing modified
+func checkUpgrade() (err error) {
+	var config DomainConfig
+	var dataPath string
+	if config, dataPath, err = loadOfflineConfig(); err != nil {
+		return
+	}
+	var plan store.MigrationPlan
+	if plan, err = modules.CheckResourceDataUpgrade(dataPath, config.MetadataStore); err != nil {
+		return fmt.Errorf("check '%s' fail: %s", modules.ResourceDataFilename, err.Error())
+	}
+	printMigrationPlan(modules.ResourceDataFilename, plan)
+	if plan, err = imageserver.CheckImageDataUpgrade(dataPath, config.MetadataStore); err != nil {
+		return fmt.Errorf("check '%s' fail: %s", imageserver.ImageDataFilename, err.Error())
+	}
+	printMigrationPlan(imageserver.ImageDataFilename, plan)
+	return nil
+}
+
+func printMigrationPlan(name string, plan store.MigrationPlan) {
+	if !plan.Exists {
+		fmt.Printf("%s: not created yet, version %d will be used\n", name, plan.Target)
+		return
+	}
+	if 0 == len(plan.Pending) {
+		fmt.Printf("%s: version %d, up to date\n", name, plan.Current)
+		return
+	}
+	fmt.Printf("%s: version %d, %d migration(s) to version %d on next start\n", name, plan.Current, len(plan.Pending), plan.Target)
+	for _, migration := range plan.Pending {
+		fmt.Printf("  v%d: %s\n", migration.Version, migration.Description)
+	}
+}
+
 func generateConfigure(workingPath string) (err error) {
 	var configPath = filepath.Join(workingPath, ConfigPathName)
 	if _, err = os.Stat(configPath); os.IsNotExist(err) {
@@ -175,7 +444,9 @@ func generateDomainConfig(configPath string) (err error) {
 		fmt.Println("No domain config available, following instructions to generate a new one.")
 
 		var config = DomainConfig{
-			Timeout: defaultOperateTimeout,
+			Timeout:       defaultOperateTimeout,
+			TaskRetention: defaultTaskRetention,
+			MetadataStore: store.DefaultBackend,
 		}
 		if config.Domain, err = framework.InputString("Group Domain Name", sonar.DefaultDomain); err != nil {
 			return
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCheckUpgrade_Requested(t *testing.T) {
+	var testCases = []struct {
+		Name      string
+		Args      []string
+		Requested bool
+		Valid     bool
+	}{
+		{"no argument", nil, false, true},
+		{"daemon command", []string{"start"}, false, true},
+		{"flag", []string{"--check-upgrade"}, true, true},
+		{"single dash flag", []string{"-check-upgrade"}, true, true},
+		{"command", []string{"check-upgrade"}, true, true},
+		{"flag with daemon command", []string{"start", "--check-upgrade"}, false, false},
+		{"command with other argument", []string{"check-upgrade", "start"}, false, true},
+		{"similar flag", []string{"--check-upgrades"}, false, true},
+	}
+	for _, testCase := range testCases {
+		requested, err := checkUpgradeRequested(testCase.Args)
+		if (nil == err) != testCase.Valid {
+			t.Fatalf("%s: unexpected error %v", testCase.Name, err)
+		}
+		if requested != testCase.Requested {
+			t.Fatalf("%s: requested %t, %t expected", testCase.Name, requested, testCase.Requested)
+		}
+	}
+}
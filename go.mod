@@ -5,6 +5,7 @@ go 1.19
 replace (
 	github.com/project-nano/core/imageserver => ./src/imageserver
 	github.com/project-nano/core/modules => ./src/modules
+	github.com/project-nano/core/store => ./src/store
 	github.com/project-nano/core/task => ./src/task
 	github.com/project-nano/framework => ../framework
 )
@@ -12,6 +13,7 @@ replace (
 require (
 	github.com/project-nano/core/imageserver v0.0.0-00010101000000-000000000000
 	github.com/project-nano/core/modules v0.0.0-00010101000000-000000000000
+	github.com/project-nano/core/store v0.0.0-00010101000000-000000000000
 	github.com/project-nano/core/task v0.0.0-00010101000000-000000000000
 	github.com/project-nano/framework v1.0.9
 	github.com/project-nano/sonar v0.0.0-20190628085230-df7942628d6f
@@ -30,6 +32,7 @@ require (
 	github.com/templexxx/xor v0.0.0-20191217153810-f85b25db303b // indirect
 	github.com/tjfoc/gmsm v1.4.1 // indirect
 	github.com/xtaci/kcp-go v5.4.20+incompatible // indirect
+	go.etcd.io/bbolt v1.3.7 // indirect
 	golang.org/x/crypto v0.13.0 // indirect
 	golang.org/x/net v0.15.0 // indirect
 	golang.org/x/sys v0.12.0 // indirect
@@ -2,10 +2,14 @@ module github.com/project-nano/core/imageserver
 
 go 1.19
 
-replace github.com/project-nano/framework => ../../../framework
+replace (
+	github.com/project-nano/core/store => ../store
+	github.com/project-nano/framework => ../../../framework
+)
 
 require (
 	github.com/julienschmidt/httprouter v1.3.0
+	github.com/project-nano/core/store v0.0.0-00010101000000-000000000000
 	github.com/project-nano/framework v1.0.9
 	github.com/satori/go.uuid v1.2.0
 )
@@ -22,6 +26,7 @@ require (
 	github.com/templexxx/xor v0.0.0-20191217153810-f85b25db303b // indirect
 	github.com/tjfoc/gmsm v1.4.1 // indirect
 	github.com/xtaci/kcp-go v5.4.20+incompatible // indirect
+	go.etcd.io/bbolt v1.3.7 // indirect
 	golang.org/x/crypto v0.13.0 // indirect
 	golang.org/x/net v0.15.0 // indirect
 	golang.org/x/sys v0.12.0 // indirect
@@ -0,0 +1,83 @@
+package imageserver
+
+import (
+	"fmt"
+	"github.com/project-nano/framework"
+	"sort"
+	"strings"
+)
+
+// ImageListOption : paging and sorting of image query, zero value returns all images ordered by group and name.
+// Sort is name of sort key, prefixed with '-' for descending order
+type ImageListOption struct {
+	Limit  int
+	Offset int
+	Sort   string
+}
+
+const (
+	imageListFlagTotal = iota
+	imageListFlagLimit
+	imageListFlagOffset
+	imageListFlagCount
+)
+
+// UnmarshalImageListOption : absent parameters ignored for requests from legacy callers
+func UnmarshalImageListOption(msg framework.Message) (option ImageListOption){
+	option.Limit, _ = msg.GetInt(framework.ParamKeyLimit)
+	option.Offset, _ = msg.GetInt(framework.ParamKeyStart)
+	option.Sort, _ = msg.GetString(framework.ParamKeyIndex)
+	return
+}
+
+func marshalImageListPage(result ImageResult, msg framework.Message){
+	var flags = make([]uint64, imageListFlagCount)
+	flags[imageListFlagTotal] = uint64(result.Total)
+	flags[imageListFlagLimit] = uint64(result.Limit)
+	flags[imageListFlagOffset] = uint64(result.Offset)
+	msg.SetUIntArray(framework.ParamKeyFlag, flags)
+}
+
+// arrangeImages : sort list in place then mark page in result, return range of page in list
+func arrangeImages(list interface{}, length int, image func(index int) ImageStatus, option ImageListOption,
+	result *ImageResult) (begin, end int, err error){
+	if "" != option.Sort{
+		var keys = map[string]func(i, j int) bool{
+			"name": func(i, j int) bool { return image(i).Name < image(j).Name },
+			"id": func(i, j int) bool { return image(i).ID < image(j).ID },
+			"size": func(i, j int) bool { return image(i).Size < image(j).Size },
+			"create_time": func(i, j int) bool { return image(i).CreateTime < image(j).CreateTime },
+			"modify_time": func(i, j int) bool { return image(i).ModifyTime < image(j).ModifyTime },
+		}
+		var descending = strings.HasPrefix(option.Sort, "-")
+		less, exists := keys[strings.TrimPrefix(option.Sort, "-")]
+		if !exists{
+			err = fmt.Errorf("invalid sort key '%s'", option.Sort)
+			return
+		}
+		if descending{
+			sort.SliceStable(list, func(i, j int) bool {
+				return less(j, i)
+			})
+		}else{
+			sort.SliceStable(list, less)
+		}
+	}
+	if option.Offset < 0 || option.Limit < 0{
+		err = fmt.Errorf("invalid offset %d or limit %d", option.Offset, option.Limit)
+		return
+	}
+	if option.Offset > length || (option.Offset == length && 0 != length){
+		err = fmt.Errorf("unexpected offset %d / %d", option.Offset, length)
+		return
+	}
+	begin = option.Offset
+	end = length
+	if 0 != option.Limit && begin + option.Limit < length{
+		end = begin + option.Limit
+	}
+	result.Total = length
+	result.Limit = option.Limit
+	result.Offset = option.Offset
+	return begin, end, nil
+}
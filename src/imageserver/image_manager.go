@@ -4,9 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/project-nano/core/store"
 	"github.com/project-nano/framework"
 	"github.com/satori/go.uuid"
-	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -52,6 +52,8 @@ type imageCommand struct {
 	Tags             []string
 	MediaImageConfig ImageConfig
 	DiskImageConfig  ImageConfig
+	ListOption       ImageListOption
+	Revision         uint64
 	ResultChan       chan ImageResult
 	ErrorChan        chan error
 }
@@ -80,6 +82,7 @@ const (
 	cmdGetDiskImageFile
 	cmdUpdateDiskImageProgress
 	cmdSyncDiskImages
+	cmdExportImageData
 )
 
 type ImageResult struct {
@@ -92,6 +95,11 @@ type ImageResult struct {
 	DiskList   []DiskStatus
 	MediaImage ImageStatus
 	DiskImage  DiskStatus
+	Total      int
+	Limit      int
+	Offset     int
+	Data       []byte
+	Revision   uint64
 }
 
 type ImageManager struct {
@@ -102,6 +110,8 @@ type ImageManager struct {
 	diskImageNames  map[string]bool //key = group.name
 	diskPath        string
 	dataFile        string
+	revision        uint64 //increased by every save from 1, replicated when changed
+	metadata        store.Store
 	commands        chan imageCommand
 	runner          *framework.SimpleRunner
 }
@@ -112,17 +122,17 @@ const (
 	FormatExtISO       = "iso"
 	DefaultDiskFormat  = FormatExtQCOW2
 	DefaultMediaFormat = FormatExtISO
+	ImageDataFilename  = "image.data"
 )
 
-func CreateImageManager(dataPath string) (manager *ImageManager, err error){
+func CreateImageManager(dataPath, metadataStore string) (manager *ImageManager, err error){
 	const (
 		DefaultQueueSize = 1 << 10
 		PathPerm = 0700
 		MediaPathName = "media_images"
 		DiskPathName = "disk_images"
-		DataFileName = "image.data"
 	)
-	manager = &ImageManager{}
+	manager = &ImageManager{revision: 1}
 	manager.runner = framework.CreateSimpleRunner(manager.Routine)
 	manager.mediaImages = map[string]ImageStatus{}
 	manager.mediaImageNames = map[string]bool{}
@@ -130,7 +140,7 @@ func CreateImageManager(dataPath string) (manager *ImageManager, err error){
 	manager.diskImageNames = map[string]bool{}
 
 	manager.commands = make(chan imageCommand, DefaultQueueSize)
-	manager.dataFile = filepath.Join(dataPath, DataFileName)
+	manager.dataFile = filepath.Join(dataPath, ImageDataFilename)
 	manager.mediaPath = filepath.Join(dataPath, MediaPathName)
 	manager.diskPath = filepath.Join(dataPath, DiskPathName)
 	if _, err := os.Stat(manager.mediaPath);os.IsNotExist(err){
@@ -147,6 +157,9 @@ func CreateImageManager(dataPath string) (manager *ImageManager, err error){
 			log.Printf("<image> new disk path '%s' created", manager.diskPath)
 		}
 	}
+	if manager.metadata, err = store.Open(metadataStore, manager.dataFile);err != nil{
+		return nil, err
+	}
 	if err = manager.LoadData();err != nil{
 		return nil, err
 	}
@@ -171,50 +184,73 @@ func (manager *ImageManager) Routine(c framework.RoutineController)  {
 			manager.handleCommand(cmd)
 		}
 	}
+	if err := manager.metadata.Close();err != nil{
+		log.Printf("<image> close metadata store fail: %s", err.Error())
+	}
 	c.NotifyExit()
 	log.Printf("<image> stopped")
 }
 
 type imageSavedData struct {
+	SchemaVersion int           `json:"schema_version"`
 	MediaImages []ImageStatus `json:"media_images"`
 	DiskImages  []DiskStatus  `json:"disk_images"`
 }
 
 func (manager *ImageManager) SaveData() error{
-	const (
-		FilePerm = 0640
-	)
-	var saved imageSavedData
+	var saved = manager.buildSavedData()
+	manager.revision++
+	if err := manager.metadata.Save(saved);err != nil{
+		return err
+	}
+	log.Printf("<image> %d media image(s), %d disk image(s) saved into '%s'", 
+		len(saved.MediaImages), len(saved.DiskImages), manager.dataFile)
+	return nil
+}
+
+func (manager *ImageManager) buildSavedData() (saved imageSavedData){
+	saved.SchemaVersion = ImageDataVersion()
 	for _, media := range manager.mediaImages{
 		saved.MediaImages = append(saved.MediaImages, media)
 	}
 	for _, image := range manager.diskImages{
 		saved.DiskImages = append(saved.DiskImages, image)
 	}
-	data, err := json.MarshalIndent(saved, "", " ")
-	if err != nil{
-		return err
+	sort.Slice(saved.MediaImages, func(i, j int) bool {
+		return saved.MediaImages[i].ID < saved.MediaImages[j].ID
+	})
+	sort.Slice(saved.DiskImages, func(i, j int) bool {
+		return saved.DiskImages[i].ID < saved.DiskImages[j].ID
+	})
+	return
+}
+
+func (manager *ImageManager) handleExportImageData(revision uint64, respChan chan ImageResult) (err error){
+	if revision == manager.revision{
+		respChan <- ImageResult{Revision: revision}
+		return nil
 	}
-	if err = ioutil.WriteFile(manager.dataFile, data, FilePerm);err != nil{
-		return err
+	var data []byte
+	if data, err = json.Marshal(manager.buildSavedData());err != nil{
+		respChan <- ImageResult{Error: err}
+		return
 	}
-	log.Printf("<image> %d media image(s), %d disk image(s) saved into '%s'", 
-		len(saved.MediaImages), len(saved.DiskImages), manager.dataFile)
+	respChan <- ImageResult{Data: data, Revision: manager.revision}
 	return nil
 }
 
 func (manager *ImageManager) LoadData() error{
-	if _, err := os.Stat(manager.dataFile);os.IsNotExist(err){
-		log.Println("<image> no images configured")
-		return nil
+	if _, err := store.Migrate(manager.metadata, imageDataMigrations, manager.dataFile);err != nil{
+		return fmt.Errorf("migrate image data in '%s' fail: %s", manager.dataFile, err.Error())
 	}
-	data, err := ioutil.ReadFile(manager.dataFile)
+	var saved imageSavedData
+	result, err := manager.metadata.Load(&saved)
 	if err != nil{
 		return err
 	}
-	var saved imageSavedData
-	if err = json.Unmarshal(data, &saved);err != nil{
-		return err
+	if !result.Exists{
+		log.Println("<image> no images configured")
+		return nil
 	}
 	for _, image := range saved.MediaImages{
 		image.Locked = false
@@ -231,6 +267,10 @@ func (manager *ImageManager) LoadData() error{
 	}
 	log.Printf("<image> %d media image(s), %d disk image(s) loaded from '%s'", 
 		len(saved.MediaImages), len(saved.DiskImages), manager.dataFile)
+	if result.Dirty{
+		saved.SchemaVersion = ImageDataVersion()
+		return manager.metadata.Compact(saved)
+	}
 	return nil
 }
 
@@ -238,7 +278,7 @@ func (manager *ImageManager) handleCommand(cmd imageCommand){
 	var err error
 	switch cmd.Type {
 	case cmdQueryMediaImage:
-		err = manager.handleQueryMediaImage(cmd.User, cmd.Group, cmd.ResultChan)
+		err = manager.handleQueryMediaImage(cmd.User, cmd.Group, cmd.ListOption, cmd.ResultChan)
 	case cmdCreateMediaImage:
 		err = manager.handleCreateMediaImage(cmd.MediaImageConfig, cmd.ResultChan)
 	case cmdDeleteMediaImage:
@@ -256,7 +296,7 @@ func (manager *ImageManager) handleCommand(cmd imageCommand){
 	case cmdModifyMediaImage:
 		err = manager.handleModifyMediaImage(cmd.ID, cmd.MediaImageConfig, cmd.ErrorChan)
 	case cmdQueryDiskImage:
-		err = manager.handleQueryDiskImage(cmd.User, cmd.Group, cmd.Tags, cmd.ResultChan)
+		err = manager.handleQueryDiskImage(cmd.User, cmd.Group, cmd.Tags, cmd.ListOption, cmd.ResultChan)
 	case cmdCreateDiskImage:
 		err = manager.handleCreateDiskImage(cmd.DiskImageConfig, cmd.ResultChan)
 	case cmdModifyDiskImage:
@@ -279,6 +319,8 @@ func (manager *ImageManager) handleCommand(cmd imageCommand){
 		err = manager.handleSyncMediaImages(cmd.User, cmd.Group, cmd.ErrorChan)
 	case cmdSyncDiskImages:
 		err = manager.handleSyncDiskImages(cmd.User, cmd.Group, cmd.ErrorChan)
+	case cmdExportImageData:
+		err = manager.handleExportImageData(cmd.Revision, cmd.ResultChan)
 	default:
 		log.Printf("<image> unsupported command type %d", cmd.Type)
 		break
@@ -288,8 +330,8 @@ func (manager *ImageManager) handleCommand(cmd imageCommand){
 	}
 }
 
-func (manager *ImageManager) QueryMediaImage(owner, group string, respChan chan ImageResult){
-	cmd := imageCommand{Type: cmdQueryMediaImage, User:owner, Group:group,  ResultChan:respChan}
+func (manager *ImageManager) QueryMediaImage(owner, group string, option ImageListOption, respChan chan ImageResult){
+	cmd := imageCommand{Type: cmdQueryMediaImage, User:owner, Group:group, ListOption: option, ResultChan:respChan}
 	manager.commands <- cmd
 }
 
@@ -334,8 +376,8 @@ func (manager * ImageManager) ModifyMediaImage(id string, config ImageConfig, re
 }
 
 
-func (manager *ImageManager) QueryDiskImage(owner, group string, tags []string, respChan chan ImageResult){
-	cmd := imageCommand{Type: cmdQueryDiskImage, User:owner, Group: group, Tags: tags, ResultChan:respChan}
+func (manager *ImageManager) QueryDiskImage(owner, group string, tags []string, option ImageListOption, respChan chan ImageResult){
+	cmd := imageCommand{Type: cmdQueryDiskImage, User:owner, Group: group, Tags: tags, ListOption: option, ResultChan:respChan}
 	manager.commands <- cmd
 }
 
@@ -389,7 +431,12 @@ func (manager * ImageManager) SyncDiskImages(owner, group string, respChan chan
 	manager.commands <- imageCommand{Type: cmdSyncDiskImages, User: owner, Group: group, ErrorChan: respChan}
 }
 
-func (manager *ImageManager) handleQueryMediaImage(owner, group string, respChan chan ImageResult) (err error){
+// ExportImageData : saved document for replication to standby core, data omitted when revision unchanged
+func (manager * ImageManager) ExportImageData(revision uint64, respChan chan ImageResult){
+	manager.commands <- imageCommand{Type: cmdExportImageData, Revision: revision, ResultChan: respChan}
+}
+
+func (manager *ImageManager) handleQueryMediaImage(owner, group string, option ImageListOption, respChan chan ImageResult) (err error){
 	var result []ImageStatus
 	var names []string
 	var nameToID = map[string]string{}
@@ -422,8 +469,16 @@ func (manager *ImageManager) handleQueryMediaImage(owner, group string, respChan
 		}
 		result = append(result, image)
 	}
-
-	respChan <- ImageResult{MediaList:result}
+	var page ImageResult
+	var begin, end int
+	if begin, end, err = arrangeImages(result, len(result), func(index int) ImageStatus {
+		return result[index]
+	}, option, &page); err != nil{
+		respChan <- ImageResult{Error:err}
+		return
+	}
+	page.MediaList = result[begin:end]
+	respChan <- page
 	return nil
 }
 
@@ -626,7 +681,7 @@ func (manager * ImageManager) handleModifyMediaImage(id string, config ImageConf
 	return manager.SaveData()
 }
 
-func (manager *ImageManager) handleQueryDiskImage(owner, group string, tags []string, respChan chan ImageResult) (err error){
+func (manager *ImageManager) handleQueryDiskImage(owner, group string, tags []string, option ImageListOption, respChan chan ImageResult) (err error){
 	var result []DiskStatus
 	var names []string
 	var nameToID = map[string]string{}
@@ -677,8 +732,16 @@ func (manager *ImageManager) handleQueryDiskImage(owner, group string, tags []st
 		}
 		result = append(result, image)
 	}
-
-	respChan <- ImageResult{DiskList:result}
+	var page ImageResult
+	var begin, end int
+	if begin, end, err = arrangeImages(result, len(result), func(index int) ImageStatus {
+		return result[index].ImageStatus
+	}, option, &page); err != nil{
+		respChan <- ImageResult{Error:err}
+		return
+	}
+	page.DiskList = result[begin:end]
+	respChan <- page
 	return nil
 }
 
@@ -0,0 +1,34 @@
+package imageserver
+
+import (
+	"encoding/json"
+	"github.com/project-nano/core/store"
+	"path/filepath"
+)
+
+// imageDataMigrations : forward migrations of image data, append only,
+// version of a migration must equal to its index plus one
+var imageDataMigrations = []store.Migration{
+	{
+		Version:     1,
+		Description: "mark unversioned image data as version 1",
+		Upgrade:     func(sections map[string]json.RawMessage) error{
+			return nil
+		},
+	},
+}
+
+func ImageDataVersion() int{
+	return store.LatestVersion(imageDataMigrations)
+}
+
+// CheckImageDataUpgrade : migrations would run on image data under data path, nothing modified
+func CheckImageDataUpgrade(dataPath, backend string) (plan store.MigrationPlan, err error){
+	var metadata store.Store
+	if metadata, err = store.OpenReadOnly(backend, filepath.Join(dataPath, ImageDataFilename));err != nil{
+		return
+	}
+	defer metadata.Close()
+	plan, err = store.PlanMigration(metadata, imageDataMigrations)
+	return
+}
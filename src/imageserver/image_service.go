@@ -1,6 +1,7 @@
 package imageserver
 
 import (
+	"errors"
 	"github.com/project-nano/framework"
 	"fmt"
 	"log"
@@ -8,11 +9,12 @@ import (
 
 type ImageService struct {
 	framework.EndpointService //base class
-	ConfigPath   string
-	DataPath     string
-	httpModule   *HttpModule
-	imageManager *ImageManager
-	taskManager  *TaskManager
+	ConfigPath    string
+	DataPath      string
+	MetadataStore string
+	httpModule    *HttpModule
+	imageManager  *ImageManager
+	taskManager   *TaskManager
 }
 
 func (service *ImageService) GetImageServiceAddress() string{
@@ -29,6 +31,18 @@ func (service *ImageService) GetTLSFilePath() (cert, key string){
 	return service.httpModule.GetCertFilePath(), service.httpModule.GetKeyFilePath()
 }
 
+// ExportImageData : saved image document for replication, nil data when revision unchanged
+func (service *ImageService) ExportImageData(revision uint64) (current uint64, data []byte, err error){
+	if nil == service.imageManager{
+		err = errors.New("image service not initialized")
+		return
+	}
+	var respChan = make(chan ImageResult, 1)
+	service.imageManager.ExportImageData(revision, respChan)
+	var result = <- respChan
+	return result.Revision, result.Data, result.Error
+}
+
 func (service *ImageService) OnMessageReceived(msg framework.Message){
 	if targetSession := msg.GetToSession(); targetSession != 0{
 		if err := service.taskManager.PushMessage(msg);err != nil{
@@ -91,7 +105,7 @@ func (service *ImageService) OnDependencyReady(){
 }
 
 func (service *ImageService) InitialEndpoint() (err error){
-	service.imageManager, err = CreateImageManager(service.DataPath)
+	service.imageManager, err = CreateImageManager(service.DataPath, service.MetadataStore)
 	if err != nil{
 		return
 	}
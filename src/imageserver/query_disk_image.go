@@ -19,7 +19,7 @@ func (executor *QueryDiskImageExecutor)Execute(id framework.SessionID, request f
 	filterTags, _ := request.GetStringArray(framework.ParamKeyTag)
 
 	var respChan = make(chan ImageResult, 1)
-	executor.ImageServer.QueryDiskImage(filterOwner, filterGroup, filterTags, respChan)
+	executor.ImageServer.QueryDiskImage(filterOwner, filterGroup, filterTags, UnmarshalImageListOption(request), respChan)
 
 	var result = <- respChan
 
@@ -69,6 +69,7 @@ func (executor *QueryDiskImageExecutor)Execute(id framework.SessionID, request f
 	resp.SetUIntArray(framework.ParamKeyCount, tagCount)
 	resp.SetUIntArray(framework.ParamKeyStatus, created)
 	resp.SetUIntArray(framework.ParamKeyProgress, progress)
+	marshalImageListPage(result, resp)
 	//log.Printf("[%08X] query disk image success, %d image(s) available", id, len(result.DiskList))
 	return executor.Sender.SendMessage(resp, request.GetSender())
 
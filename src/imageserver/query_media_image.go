@@ -18,7 +18,7 @@ func (executor *QueryMediaImageExecutor)Execute(id framework.SessionID, request
 	filterGroup, _ = request.GetString(framework.ParamKeyGroup)
 
 	var respChan = make(chan ImageResult, 1)
-	executor.ImageServer.QueryMediaImage(filterOwner, filterGroup, respChan)
+	executor.ImageServer.QueryMediaImage(filterOwner, filterGroup, UnmarshalImageListOption(request), respChan)
 
 	var result = <- respChan
 
@@ -60,6 +60,7 @@ func (executor *QueryMediaImageExecutor)Execute(id framework.SessionID, request
 
 	resp.SetUIntArray(framework.ParamKeySize, size)
 	resp.SetUIntArray(framework.ParamKeyCount, tagCount)
+	marshalImageListPage(result, resp)
 	//log.Printf("[%08X] query media image success, %d image(s) available", id, len(result.MediaList))
 	return executor.Sender.SendMessage(resp, request.GetSender())
 
@@ -0,0 +1,595 @@
+package modules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	APIVerbGet     = "get"
+	APIVerbCreate  = "create"
+	APIVerbModify  = "modify"
+	APIVerbDelete  = "delete"
+	APIAnyVerb     = "*"
+	APIAnyResource = "*"
+)
+
+const (
+	apiCredentialResource = "credentials"
+)
+
+const (
+	APIRoleAdmin          = "admin"
+	APIRoleMonitor        = "monitor"
+	APIRolePoolOperator   = "pool_operator"
+	APIRoleImagePublisher = "image_publisher"
+	APIRoleTenantPortal   = "tenant_portal"
+)
+
+// APIPermission : verbs allowed on resource, resource is first segment of API path, such as "guests" or "disk_images"
+type APIPermission struct {
+	Resource string   `json:"resource"`
+	Verbs    []string `json:"verbs"`
+}
+
+type APIRole struct {
+	Name        string          `json:"name"`
+	Permissions []APIPermission `json:"permissions"`
+}
+
+// apiAccess : role and scope bound to credential, empty scope means unrestricted
+type apiAccess struct {
+	Role   APIRole
+	Pools  map[string]bool
+	Users  map[string]bool
+	Groups map[string]bool
+}
+
+// apiGuestFields : payload fields naming guests, a single ID or list of IDs
+var apiGuestFields = []string{"guest", "instances"}
+
+// apiRoute : matched route attached to request context for authorization
+type apiRoute struct {
+	Path   string
+	Params httprouter.Params
+}
+
+type apiRouteKey struct{}
+
+// apiRouter : register handlers with route and lease attached to request, mutating requests recorded when audit available
+type apiRouter struct {
+	router  *httprouter.Router
+	audit   AuditModule
+	limiter *apiLimiter
+}
+
+// apiResponseWriter : capture status and failure reported by handler, reject with 429 when limited
+type apiResponseWriter struct {
+	http.ResponseWriter
+	lease   *apiLease
+	status  int
+	failed  bool
+	message string
+	capture *bytes.Buffer
+}
+
+var apiResourceAliases = map[string]string{
+	"compute_zone_status":  "compute_pools",
+	"compute_pool_status":  "compute_pools",
+	"compute_cell_status":  "compute_pool_cells",
+	"instance_status":      "instances",
+	"guest_search":         "guests",
+	"disk_image_search":    "disk_images",
+	"media_image_search":   "media_images",
+	"webhook_dead_letters": "webhooks",
+}
+
+func builtinAPIRoles() map[string]APIRole {
+	var all = []string{APIAnyVerb}
+	var readOnly = []string{APIVerbGet}
+	var grant = func(verbs []string, resources ...string) (permissions []APIPermission) {
+		for _, resource := range resources {
+			permissions = append(permissions, APIPermission{Resource: resource, Verbs: verbs})
+		}
+		return
+	}
+	var roles = []APIRole{
+		{Name: APIRoleAdmin, Permissions: grant(all, APIAnyResource)},
+		{Name: APIRoleMonitor, Permissions: grant(readOnly, "compute_pools", "compute_pool_cells", "storage_pools",
+			"address_pools", "instances", "guests", "batch", "migrations", "disk_images", "media_images", "templates",
+			"security_policy_groups", "server_groups", "quotas", "events", "jobs", "limits")},
+		{Name: APIRolePoolOperator, Permissions: append(grant(all, "compute_pools", "compute_pool_cells", "storage_pools",
+			"address_pools", "instances", "guests", "batch", "migrations", "server_groups", "jobs"),
+			grant(readOnly, "disk_images", "media_images", "templates", "quotas", "events")...)},
+		{Name: APIRoleImagePublisher, Permissions: append(grant(all, "disk_images", "media_images"),
+			grant(readOnly, "jobs", "events")...)},
+		{Name: APIRoleTenantPortal, Permissions: append(grant(all, "instances", "guests", "batch", "security_policy_groups",
+			"server_groups", "jobs"), grant(readOnly, "compute_pools", "disk_images", "media_images", "templates",
+			"quotas", "events")...)},
+	}
+	var result = map[string]APIRole{}
+	for _, role := range roles {
+		result[role.Name] = role
+	}
+	return result
+}
+
+// loadAPIRoles : custom roles in config extend built-in ones
+func loadAPIRoles(custom []APIRole) (roles map[string]APIRole, err error) {
+	var validVerbs = map[string]bool{APIVerbGet: true, APIVerbCreate: true, APIVerbModify: true, APIVerbDelete: true, APIAnyVerb: true}
+	roles = builtinAPIRoles()
+	for _, role := range custom {
+		if "" == role.Name {
+			err = fmt.Errorf("empty name of API role")
+			return
+		}
+		if _, exists := roles[role.Name]; exists {
+			err = fmt.Errorf("API role '%s' already defined", role.Name)
+			return
+		}
+		for _, permission := range role.Permissions {
+			if "" == permission.Resource {
+				err = fmt.Errorf("empty resource in API role '%s'", role.Name)
+				return
+			}
+			for _, verb := range permission.Verbs {
+				if !validVerbs[verb] {
+					err = fmt.Errorf("invalid verb '%s' in API role '%s'", verb, role.Name)
+					return
+				}
+			}
+		}
+		roles[role.Name] = role
+	}
+	return roles, nil
+}
+
+// newAPIAccess : credentials without role are administrators, compatible with legacy config
+func newAPIAccess(credential ApiCredential, roles map[string]APIRole) (access apiAccess, err error) {
+	var roleName = credential.Role
+	if "" == roleName {
+		roleName = APIRoleAdmin
+	}
+	var exists bool
+	if access.Role, exists = roles[roleName]; !exists {
+		err = fmt.Errorf("invalid role '%s' for API credential '%s'", roleName, credential.ID)
+		return
+	}
+	var toSet = func(values []string) map[string]bool {
+		if 0 == len(values) {
+			return nil
+		}
+		var set = map[string]bool{}
+		for _, value := range values {
+			set[value] = true
+		}
+		return set
+	}
+	access.Pools = toSet(credential.Pools)
+	access.Users = toSet(credential.Users)
+	access.Groups = toSet(credential.Groups)
+	return access, nil
+}
+
+func (role APIRole) Allowed(resource, verb string) bool {
+	for _, permission := range role.Permissions {
+		if APIAnyResource != permission.Resource && resource != permission.Resource {
+			continue
+		}
+		for _, allowed := range permission.Verbs {
+			if APIAnyVerb == allowed || verb == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseAPIOperation : resource and verb of request on route, search via POST only reads resource
+func parseAPIOperation(method, routePath string) (resource, verb string) {
+	var segments = strings.Split(strings.Trim(strings.TrimPrefix(routePath, apiPath("")), "/"), "/")
+	resource = segments[0]
+	switch method {
+	case http.MethodPost:
+		verb = APIVerbCreate
+	case http.MethodPut, http.MethodPatch:
+		verb = APIVerbModify
+	case http.MethodDelete:
+		verb = APIVerbDelete
+	default:
+		verb = APIVerbGet
+	}
+	if "search" == resource && 1 < len(segments) {
+		return segments[1], APIVerbGet
+	}
+	if alias, exists := apiResourceAliases[resource]; exists {
+		resource = alias
+	}
+	return
+}
+
+func (router apiRouter) handle(method, path string, handle httprouter.Handle) {
+	var route = apiRoute{Path: path}
+	var resource, verb = parseAPIOperation(method, path)
+	var audited = nil != router.audit && APIVerbGet != verb
+	router.router.Handle(method, path, func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		var current = route
+		current.Params = params
+		var lease = &apiLease{}
+		var ctx = context.WithValue(r.Context(), apiRouteKey{}, current)
+		r = r.WithContext(context.WithValue(ctx, apiLeaseKey{}, lease))
+		var recorder = &apiResponseWriter{ResponseWriter: w, lease: lease, status: http.StatusOK}
+		if audited {
+			router.auditRequest(resource, verb, recorder, r, params, handle)
+		} else {
+			handle(recorder, r, params)
+		}
+		if nil != router.limiter && lease.Acquired {
+			router.limiter.release(lease.Credential)
+		}
+	})
+}
+
+// auditRequest : record caller, payload with secrets redacted and result of mutating request
+func (router apiRouter) auditRequest(resource, verb string, recorder *apiResponseWriter, r *http.Request,
+	params httprouter.Params, handle httprouter.Handle) {
+	var start = time.Now()
+	var record = AuditRecord{
+		Time:       start.Format(TimeFormatLayout),
+		Source:     AuditSourceAPI,
+		Credential: requestCredential(r),
+		Action:     verb,
+		Resource:   resource,
+		Path:       r.Method + " " + r.URL.Path,
+	}
+	var targets []string
+	for _, param := range params {
+		targets = append(targets, param.Value)
+	}
+	record.Target = strings.Join(targets, "/")
+	if payload, err := readRequestPayload(r); err == nil && 0 != len(payload) {
+		var parameters interface{}
+		if err = json.Unmarshal(payload, &parameters); err == nil {
+			if object, isObject := parameters.(map[string]interface{}); isObject {
+				for _, key := range []string{"owner", "user"} {
+					if value, isString := object[key].(string); isString && "" == record.User {
+						record.User = value
+					}
+				}
+				record.Group, _ = object["group"].(string)
+			}
+			record.Parameters = RedactAuditParameters(parameters)
+		}
+	}
+	handle(recorder, r, params)
+	record.Duration = int64(time.Since(start) / time.Millisecond)
+	if recorder.failed || recorder.status >= http.StatusBadRequest {
+		record.Result = AuditResultFail
+		record.Error = recorder.message
+	} else {
+		record.Result = AuditResultSuccess
+	}
+	router.audit.Record(record)
+}
+
+func (writer *apiResponseWriter) WriteHeader(status int) {
+	writer.status = status
+	writer.ResponseWriter.WriteHeader(status)
+}
+
+// Write : copy of response kept when capturing
+func (writer *apiResponseWriter) Write(data []byte) (int, error) {
+	if nil != writer.capture {
+		writer.capture.Write(data)
+	}
+	return writer.ResponseWriter.Write(data)
+}
+
+// Flush : keep stream of server-sent events available
+func (writer *apiResponseWriter) Flush() {
+	if flusher, isFlusher := writer.ResponseWriter.(http.Flusher); isFlusher {
+		flusher.Flush()
+	}
+}
+
+// fail : failure reported by ResponseFail, status 429 with Retry-After when rejected by limiter
+func (writer *apiResponseWriter) fail(code int, message string) {
+	writer.failed = true
+	writer.message = message
+	if ResponseTooManyRequests == code && writer.lease.RetryAfter > 0 {
+		var seconds = int(writer.lease.RetryAfter / time.Second)
+		writer.Header().Set(HeaderNameRetryAfter, strconv.Itoa(seconds))
+		writer.WriteHeader(http.StatusTooManyRequests)
+	}
+}
+
+func (router apiRouter) GET(path string, handle httprouter.Handle) {
+	router.handle(http.MethodGet, path, handle)
+}
+
+func (router apiRouter) POST(path string, handle httprouter.Handle) {
+	router.handle(http.MethodPost, path, handle)
+}
+
+func (router apiRouter) PUT(path string, handle httprouter.Handle) {
+	router.handle(http.MethodPut, path, handle)
+}
+
+func (router apiRouter) PATCH(path string, handle httprouter.Handle) {
+	router.handle(http.MethodPatch, path, handle)
+}
+
+func (router apiRouter) DELETE(path string, handle httprouter.Handle) {
+	router.handle(http.MethodDelete, path, handle)
+}
+
+// authorizeRequest : check role of credential, then scope of pool, owner and group named by request or target guest
+func (module *APIModule) authorizeRequest(credentialID string, access apiAccess, r *http.Request) (err error) {
+	route, exists := r.Context().Value(apiRouteKey{}).(apiRoute)
+	if !exists {
+		return fmt.Errorf("no route available for '%s'", r.URL.Path)
+	}
+	var resource, verb = parseAPIOperation(r.Method, route.Path)
+	if apiCredentialResource == resource {
+		//credentials only managed by unrestricted administrator
+		if APIRoleAdmin != access.Role.Name || nil != access.Pools || nil != access.Users || nil != access.Groups {
+			return fmt.Errorf("credential '%s' can not manage credentials", credentialID)
+		}
+		return nil
+	}
+	if !access.Role.Allowed(resource, verb) {
+		return fmt.Errorf("credential '%s' with role '%s' can not %s %s", credentialID, access.Role.Name, verb, resource)
+	}
+	if !access.scoped() {
+		return nil
+	}
+	var pools, owners, groups []string
+	var collect = func(values map[string]string) {
+		for _, key := range []string{"pool", "source_pool", "target_pool"} {
+			if value := values[key]; "" != value {
+				pools = append(pools, value)
+			}
+		}
+		for _, key := range []string{"owner", "user"} {
+			if value := values[key]; "" != value {
+				owners = append(owners, value)
+			}
+		}
+		if value := values["group"]; "" != value {
+			groups = append(groups, value)
+		}
+	}
+	var values = map[string]string{}
+	for _, param := range route.Params {
+		values[param.Key] = param.Value
+	}
+	collect(values)
+	values = map[string]string{}
+	for key := range r.URL.Query() {
+		values[key] = r.URL.Query().Get(key)
+	}
+	collect(values)
+	var lists map[string][]string
+	var inspected bool
+	if values, lists, inspected, err = peekRequestFields(r); err != nil {
+		return
+	}
+	if !inspected {
+		return fmt.Errorf("payload of request can not be inspected for scoped credential '%s'", credentialID)
+	}
+	collect(values)
+
+	var guests []string
+	var guestID = route.Params.ByName("id")
+	var targetGuest = ("guests" == resource || "instances" == resource) && "" != guestID
+	if targetGuest {
+		guests = append(guests, guestID)
+	}
+	for _, key := range apiGuestFields {
+		if value := values[key]; "" != value {
+			guests = append(guests, value)
+		}
+		guests = append(guests, lists[key]...)
+	}
+	for _, guest := range guests {
+		var respChan = make(chan ResourceResult, 1)
+		module.resource.LookupInstance(guest, respChan)
+		var result = <-respChan
+		if result.Error != nil {
+			return result.Error
+		}
+		var instance = result.Instance
+		pools = append(pools, instance.Pool)
+		if (nil != access.Users || nil != access.Groups) && !access.Users[instance.User] && !access.Groups[instance.Group] {
+			return fmt.Errorf("guest '%s' out of scope of credential '%s'", guest, credentialID)
+		}
+	}
+	if nil != access.Users || nil != access.Groups {
+		if err = module.authorizeOwnedTarget(credentialID, access, resource, verb, route.Params.ByName("id")); err != nil {
+			return
+		}
+	}
+	var listGuests = ("guests" == resource || "instances" == resource) && APIVerbGet == verb && !targetGuest
+	//policy groups paged by owner or group, server groups filtered by handler
+	var listPolicyGroups = "security_policy_groups" == resource && APIVerbGet == verb && "" == route.Params.ByName("id")
+	if nil != access.Pools {
+		for _, pool := range pools {
+			if !access.Pools[pool] {
+				return fmt.Errorf("pool '%s' out of scope of credential '%s'", pool, credentialID)
+			}
+		}
+		if listGuests && 0 == len(pools) {
+			return fmt.Errorf("pool required for scoped credential '%s'", credentialID)
+		}
+	}
+	if nil != access.Users || nil != access.Groups {
+		for _, owner := range owners {
+			if !access.Users[owner] {
+				return fmt.Errorf("owner '%s' out of scope of credential '%s'", owner, credentialID)
+			}
+		}
+		for _, group := range groups {
+			if !access.Groups[group] {
+				return fmt.Errorf("group '%s' out of scope of credential '%s'", group, credentialID)
+			}
+		}
+		if (listGuests || listPolicyGroups) && 0 == len(owners) && 0 == len(groups) {
+			return fmt.Errorf("owner or group required for scoped credential '%s'", credentialID)
+		}
+		var ownedGroup = "security_policy_groups" == resource || "server_groups" == resource
+		if ownedGroup && APIVerbCreate == verb && "" == route.Params.ByName("id") && 0 == len(owners) && 0 == len(groups) {
+			return fmt.Errorf("user or group required for scoped credential '%s'", credentialID)
+		}
+		if "migrations" == resource && APIVerbCreate == verb && 0 == len(lists["instances"]) {
+			//whole cell may hold guests of others
+			return fmt.Errorf("instances required for scoped credential '%s'", credentialID)
+		}
+	}
+	return nil
+}
+
+// authorizeOwnedTarget : owner of security policy group or server group named by path must in scope,
+// global policy group readable by all
+func (module *APIModule) authorizeOwnedTarget(credentialID string, access apiAccess, resource, verb, targetID string) error {
+	if "" == targetID {
+		return nil
+	}
+	var respChan = make(chan ResourceResult, 1)
+	var user, group string
+	switch resource {
+	case "security_policy_groups":
+		module.resource.GetSecurityPolicyGroup(targetID, respChan)
+		var result = <-respChan
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.PolicyGroup.Global && APIVerbGet == verb {
+			return nil
+		}
+		user, group = result.PolicyGroup.User, result.PolicyGroup.Group
+	case "server_groups":
+		module.resource.GetServerGroup(targetID, respChan)
+		var result = <-respChan
+		if result.Error != nil {
+			return result.Error
+		}
+		user, group = result.ServerGroup.User, result.ServerGroup.Group
+	default:
+		return nil
+	}
+	if !access.ownerAllowed(user, group) {
+		return fmt.Errorf("%s '%s' out of scope of credential '%s'", resource, targetID, credentialID)
+	}
+	return nil
+}
+
+// scoped : restricted to pools, users or groups
+func (access apiAccess) scoped() bool {
+	return nil != access.Pools || nil != access.Users || nil != access.Groups
+}
+
+// poolAllowed : pool visible to credential
+func (access apiAccess) poolAllowed(pool string) bool {
+	return nil == access.Pools || access.Pools[pool]
+}
+
+// guestAllowed : guest in pool, user and group scope of credential
+func (access apiAccess) guestAllowed(instance InstanceStatus) bool {
+	if !access.poolAllowed(instance.Pool) {
+		return false
+	}
+	return access.ownerAllowed(instance.User, instance.Group)
+}
+
+// ownerAllowed : resource of user or group in scope of credential
+func (access apiAccess) ownerAllowed(user, group string) bool {
+	if nil == access.Users && nil == access.Groups {
+		return true
+	}
+	return access.Users[user] || access.Groups[group]
+}
+
+// requestAccess : access of credential verified for request, unrestricted when request not routed
+func requestAccess(r *http.Request) apiAccess {
+	if lease, exists := r.Context().Value(apiLeaseKey{}).(*apiLease); exists && lease.Verified {
+		return lease.Access
+	}
+	return apiAccess{}
+}
+
+// readRequestPayload : payload kept for handler, streams and uploads not read
+func readRequestPayload(r *http.Request) (payload []byte, err error) {
+	const (
+		maxPayloadSize = 1 << 20
+	)
+	if nil == r.Body || http.MethodGet == r.Method || http.MethodHead == r.Method {
+		return
+	}
+	if r.ContentLength < 0 || r.ContentLength > maxPayloadSize ||
+		strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		return
+	}
+	if payload, err = ioutil.ReadAll(r.Body); err != nil {
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewBuffer(payload))
+	return payload, nil
+}
+
+// peekRequestFields : top level string and string array fields of JSON payload, payload kept for handler.
+// Not inspected when payload skipped by readRequestPayload or not a JSON object, file uploads excepted
+func peekRequestFields(r *http.Request) (fields map[string]string, lists map[string][]string, inspected bool, err error) {
+	fields = map[string]string{}
+	lists = map[string][]string{}
+	if nil == r.Body || http.MethodGet == r.Method || http.MethodHead == r.Method {
+		return fields, lists, true, nil
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+		//image file carries no scope
+		return fields, lists, true, nil
+	}
+	if 0 == r.ContentLength {
+		return fields, lists, true, nil
+	}
+	var payload []byte
+	if payload, err = readRequestPayload(r); err != nil {
+		return
+	}
+	if 0 == len(payload) {
+		//stream or oversized
+		return fields, lists, false, nil
+	}
+	var object map[string]json.RawMessage
+	if err = json.Unmarshal(payload, &object); err != nil {
+		return fields, lists, false, nil
+	}
+	for key, raw := range object {
+		var value string
+		var values []string
+		if err = json.Unmarshal(raw, &value); err == nil {
+			fields[key] = value
+		} else if err = json.Unmarshal(raw, &values); err == nil {
+			lists[key] = values
+		}
+	}
+	err = nil
+	for _, key := range apiGuestFields {
+		if raw, exists := object[key]; exists && "null" != string(raw) {
+			if _, isString := fields[key]; !isString {
+				if _, isList := lists[key]; !isList {
+					//guest named in unexpected shape
+					return fields, lists, false, nil
+				}
+			}
+		}
+	}
+	return fields, lists, true, nil
+}
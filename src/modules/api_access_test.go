@@ -0,0 +1,200 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// ownedGroupResource : policy groups and server groups looked up by authorization
+type ownedGroupResource struct {
+	ResourceModule
+	policyGroups map[string]SecurityPolicyGroupStatus
+	serverGroups map[string]ServerGroupStatus
+}
+
+func (resource *ownedGroupResource) GetSecurityPolicyGroup(groupID string, respChan chan ResourceResult) {
+	if group, exists := resource.policyGroups[groupID]; exists {
+		respChan <- ResourceResult{PolicyGroup: group}
+	} else {
+		respChan <- ResourceResult{Error: fmt.Errorf("invalid security policy group '%s'", groupID)}
+	}
+}
+
+func (resource *ownedGroupResource) GetServerGroup(groupID string, respChan chan ResourceResult) {
+	if group, exists := resource.serverGroups[groupID]; exists {
+		respChan <- ResourceResult{ServerGroup: group}
+	} else {
+		respChan <- ResourceResult{Error: fmt.Errorf("invalid server group '%s'", groupID)}
+	}
+}
+
+func TestAPIAccess_OwnedGroups(t *testing.T) {
+	var resource = &ownedGroupResource{
+		policyGroups: map[string]SecurityPolicyGroupStatus{
+			"p-alice":  {ID: "p-alice", SecurityPolicyGroup: SecurityPolicyGroup{Name: "a", User: "alice", Group: "dev"}},
+			"p-bob":    {ID: "p-bob", SecurityPolicyGroup: SecurityPolicyGroup{Name: "b", User: "bob", Group: "ops"}},
+			"p-global": {ID: "p-global", SecurityPolicyGroup: SecurityPolicyGroup{Name: "g", User: "admin", Group: "admin", Global: true}},
+		},
+		serverGroups: map[string]ServerGroupStatus{
+			"s-alice": {ID: "s-alice", ServerGroupConfig: ServerGroupConfig{Name: "a", User: "alice", Group: "dev"}},
+			"s-bob":   {ID: "s-bob", ServerGroupConfig: ServerGroupConfig{Name: "b", User: "bob", Group: "ops"}},
+		},
+	}
+	var module = &APIModule{resource: resource}
+	var roles = builtinAPIRoles()
+	var scopes = map[string]apiAccess{
+		"users":  {Role: roles[APIRoleTenantPortal], Users: map[string]bool{"alice": true}},
+		"groups": {Role: roles[APIRoleTenantPortal], Groups: map[string]bool{"dev": true}},
+		"pools":  {Role: roles[APIRoleTenantPortal], Pools: map[string]bool{"default": true}},
+	}
+	var testCases = []struct {
+		Name    string
+		Scope   string
+		Method  string
+		Route   string
+		URL     string
+		Payload string
+		Allowed bool
+	}{
+		{"get own server group", "users", http.MethodGet, "/server_groups/:id", "/server_groups/s-alice", "", true},
+		{"get other server group", "users", http.MethodGet, "/server_groups/:id", "/server_groups/s-bob", "", false},
+		{"modify own server group", "users", http.MethodPut, "/server_groups/:id", "/server_groups/s-alice",
+			`{"name":"a","policy":"affinity","user":"alice"}`, true},
+		{"modify other server group", "users", http.MethodPut, "/server_groups/:id", "/server_groups/s-bob",
+			`{"name":"b","policy":"affinity","user":"alice"}`, false},
+		{"hand server group to other", "users", http.MethodPut, "/server_groups/:id", "/server_groups/s-alice",
+			`{"name":"a","policy":"affinity","user":"bob"}`, false},
+		{"delete own server group", "users", http.MethodDelete, "/server_groups/:id", "/server_groups/s-alice", "", true},
+		{"delete other server group", "users", http.MethodDelete, "/server_groups/:id", "/server_groups/s-bob", "", false},
+		{"create server group without owner", "users", http.MethodPost, "/server_groups/", "/server_groups/",
+			`{"name":"c","policy":"affinity"}`, false},
+		{"create own server group", "users", http.MethodPost, "/server_groups/", "/server_groups/",
+			`{"name":"c","policy":"affinity","user":"alice"}`, true},
+		{"delete server group of own group", "groups", http.MethodDelete, "/server_groups/:id", "/server_groups/s-alice", "", true},
+		{"delete server group of other group", "groups", http.MethodDelete, "/server_groups/:id", "/server_groups/s-bob", "", false},
+		{"pool scope not restricting owner", "pools", http.MethodDelete, "/server_groups/:id", "/server_groups/s-bob", "", true},
+		{"unknown server group", "users", http.MethodDelete, "/server_groups/:id", "/server_groups/none", "", false},
+
+		{"get own policy group", "users", http.MethodGet, "/security_policy_groups/:id", "/security_policy_groups/p-alice", "", true},
+		{"get other policy group", "users", http.MethodGet, "/security_policy_groups/:id", "/security_policy_groups/p-bob", "", false},
+		{"get global policy group", "users", http.MethodGet, "/security_policy_groups/:id", "/security_policy_groups/p-global", "", true},
+		{"modify global policy group", "users", http.MethodPut, "/security_policy_groups/:id", "/security_policy_groups/p-global",
+			`{"name":"g","user":"alice"}`, false},
+		{"modify own policy group", "users", http.MethodPut, "/security_policy_groups/:id", "/security_policy_groups/p-alice",
+			`{"name":"a","user":"alice"}`, true},
+		{"delete other policy group", "users", http.MethodDelete, "/security_policy_groups/:id", "/security_policy_groups/p-bob", "", false},
+		{"add rule to other policy group", "users", http.MethodPost, "/security_policy_groups/:id/rules/",
+			"/security_policy_groups/p-bob/rules/", `{"action":"accept","protocol":"tcp"}`, false},
+		{"read rules of global policy group", "users", http.MethodGet, "/security_policy_groups/:id/rules/",
+			"/security_policy_groups/p-global/rules/", "", true},
+		{"delete policy group of own group", "groups", http.MethodDelete, "/security_policy_groups/:id", "/security_policy_groups/p-alice", "", true},
+		{"delete policy group of other group", "groups", http.MethodDelete, "/security_policy_groups/:id", "/security_policy_groups/p-bob", "", false},
+		{"list policy groups without owner", "users", http.MethodGet, "/security_policy_groups/", "/security_policy_groups/", "", false},
+		{"list own policy groups", "users", http.MethodGet, "/security_policy_groups/", "/security_policy_groups/?owner=alice", "", true},
+		{"list other policy groups", "users", http.MethodGet, "/security_policy_groups/", "/security_policy_groups/?owner=bob", "", false},
+		{"list policy groups of own group", "groups", http.MethodGet, "/security_policy_groups/", "/security_policy_groups/?group=dev", "", true},
+		{"list policy groups of other group", "groups", http.MethodGet, "/security_policy_groups/", "/security_policy_groups/?group=ops", "", false},
+	}
+	for _, testCase := range testCases {
+		var r = httptest.NewRequest(testCase.Method, apiPath(testCase.URL), strings.NewReader(testCase.Payload))
+		var route = apiRoute{Path: apiPath(testCase.Route)}
+		if segments := strings.Split(testCase.URL, "/"); strings.Contains(testCase.Route, ":id") {
+			route.Params = httprouter.Params{{Key: "id", Value: segments[2]}}
+		}
+		r = r.WithContext(context.WithValue(r.Context(), apiRouteKey{}, route))
+		var err = module.authorizeRequest("test", scopes[testCase.Scope], r)
+		if (nil == err) != testCase.Allowed {
+			t.Fatalf("%s: unexpected authorize result %v", testCase.Name, err)
+		}
+	}
+}
+
+func TestAPIAccess_OwnerAllowed(t *testing.T) {
+	var testCases = []struct {
+		Name    string
+		Access  apiAccess
+		User    string
+		Group   string
+		Allowed bool
+	}{
+		{"unrestricted", apiAccess{}, "bob", "ops", true},
+		{"pools only", apiAccess{Pools: map[string]bool{"default": true}}, "bob", "ops", true},
+		{"user in scope", apiAccess{Users: map[string]bool{"alice": true}}, "alice", "", true},
+		{"user out of scope", apiAccess{Users: map[string]bool{"alice": true}}, "bob", "dev", false},
+		{"group in scope", apiAccess{Groups: map[string]bool{"dev": true}}, "bob", "dev", true},
+		{"group out of scope", apiAccess{Groups: map[string]bool{"dev": true}}, "bob", "ops", false},
+		{"unowned", apiAccess{Users: map[string]bool{"alice": true}}, "", "", false},
+	}
+	for _, testCase := range testCases {
+		if allowed := testCase.Access.ownerAllowed(testCase.User, testCase.Group); allowed != testCase.Allowed {
+			t.Fatalf("%s: allowed %t, %t expected", testCase.Name, allowed, testCase.Allowed)
+		}
+	}
+}
+
+func TestAPIAccess_AuditRequest(t *testing.T) {
+	var logger = auditLoggerForTest(t)
+	var router = apiRouter{router: httprouter.New(), audit: logger}
+	var handler = func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		if "fail" == params.ByName("id") {
+			ResponseFail(ResponseDefaultError, "invalid instance", w)
+			return
+		}
+		ResponseOK("", w)
+	}
+	router.GET(apiPath("/instances/:id"), handler)
+	router.PUT(apiPath("/instances/:id"), handler)
+	router.DELETE(apiPath("/instances/:id"), handler)
+	var testCases = []struct {
+		Name     string
+		Method   string
+		Target   string
+		Payload  string
+		Recorded bool
+		Result   string
+		Error    string
+		Verb     string
+	}{
+		{"query not recorded", http.MethodGet, "fail", "", false, "", "", ""},
+		{"modify", http.MethodPut, "guest", `{"owner":"alice","group":"dev","admin_secret":"s"}`, true, AuditResultSuccess, "", APIVerbModify},
+		{"failed call", http.MethodDelete, "fail", "", true, AuditResultFail, "invalid instance", APIVerbDelete},
+	}
+	for _, testCase := range testCases {
+		var r = httptest.NewRequest(testCase.Method, apiPath("/instances/"+testCase.Target), strings.NewReader(testCase.Payload))
+		r.Header.Set(HeaderNameAuthorization, "Nano-HMAC-SHA256 Credential=operator/20260101/nano, SignedHeaders=host, Signature=test")
+		router.router.ServeHTTP(httptest.NewRecorder(), r)
+		drainAuditCommands(logger)
+		records, err := readAuditFile(logger.auditFile, AuditFilter{Target: testCase.Target, Action: testCase.Verb})
+		if err != nil {
+			t.Fatalf("%s: read audit file fail: %s", testCase.Name, err.Error())
+		}
+		if !testCase.Recorded {
+			if 0 != len(records) {
+				t.Fatalf("%s: %d record(s) written", testCase.Name, len(records))
+			}
+			continue
+		}
+		if 1 != len(records) {
+			t.Fatalf("%s: %d record(s) written", testCase.Name, len(records))
+		}
+		var record = records[0]
+		if AuditSourceAPI != record.Source || "operator" != record.Credential || "instances" != record.Resource {
+			t.Fatalf("%s: unexpected record %+v", testCase.Name, record)
+		}
+		if testCase.Result != record.Result || testCase.Error != record.Error {
+			t.Fatalf("%s: result '%s' with error '%s', '%s' with '%s' expected", testCase.Name,
+				record.Result, record.Error, testCase.Result, testCase.Error)
+		}
+		if "" != testCase.Payload {
+			var parameters, _ = record.Parameters.(map[string]interface{})
+			if "alice" != record.User || "dev" != record.Group || auditRedacted != parameters["admin_secret"] {
+				t.Fatalf("%s: unexpected owner or parameters in record %+v", testCase.Name, record)
+			}
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package modules
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/julienschmidt/httprouter"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	HeaderNameIdempotencyKey     = "Idempotency-Key"
+	HeaderNameIdempotentReplayed = "Idempotent-Replayed"
+	DefaultIdempotencyPeriod     = 86400
+	DefaultIdempotencyCapacity   = 1 << 14
+	ResponseIdempotencyConflict  = 4090
+	maxIdempotencyKeyLength      = 128
+)
+
+type idempotentResponse struct {
+	Status int
+	Body   []byte
+}
+
+type idempotencyStatus int
+
+const (
+	idempotencyNew = iota
+	idempotencyPending
+	idempotencyCompleted
+	idempotencyMismatch
+)
+
+type idempotencyEntry struct {
+	Fingerprint string
+	Completed   bool
+	Response    idempotentResponse
+}
+
+type idempotencyRecord struct {
+	Key    string
+	Expire time.Time
+}
+
+type idempotencyCommandType int
+
+const (
+	idempotencyCmdBegin = iota
+	idempotencyCmdComplete
+	idempotencyCmdAbandon
+)
+
+type idempotencyResult struct {
+	Status   idempotencyStatus
+	Response idempotentResponse
+}
+
+type idempotencyCommand struct {
+	Type        idempotencyCommandType
+	Key         string
+	Fingerprint string
+	Response    idempotentResponse
+	ResultChan  chan idempotencyResult
+}
+
+// idempotencyStore : responses of requests with idempotency key, remembered for period since first request
+type idempotencyStore struct {
+	period   time.Duration
+	capacity int
+	entries  map[string]*idempotencyEntry
+	queue    []idempotencyRecord
+	commands chan idempotencyCommand
+	exitChan chan bool
+}
+
+func newIdempotencyStore(period time.Duration, capacity int) *idempotencyStore {
+	const (
+		DefaultQueueLength = 1 << 10
+	)
+	return &idempotencyStore{
+		period:   period,
+		capacity: capacity,
+		entries:  map[string]*idempotencyEntry{},
+		commands: make(chan idempotencyCommand, DefaultQueueLength),
+		exitChan: make(chan bool),
+	}
+}
+
+func (store *idempotencyStore) start() {
+	go store.routine()
+}
+
+func (store *idempotencyStore) stop() {
+	store.exitChan <- true
+	<-store.exitChan
+}
+
+// begin : reserve key for new request, or report response of previous one
+func (store *idempotencyStore) begin(key, fingerprint string) (status idempotencyStatus, response idempotentResponse) {
+	var respChan = make(chan idempotencyResult, 1)
+	store.commands <- idempotencyCommand{Type: idempotencyCmdBegin, Key: key, Fingerprint: fingerprint, ResultChan: respChan}
+	var result = <-respChan
+	return result.Status, result.Response
+}
+
+func (store *idempotencyStore) complete(key string, response idempotentResponse) {
+	store.commands <- idempotencyCommand{Type: idempotencyCmdComplete, Key: key, Response: response}
+}
+
+// abandon : release key of failed request, so that retry executed again
+func (store *idempotencyStore) abandon(key string) {
+	store.commands <- idempotencyCommand{Type: idempotencyCmdAbandon, Key: key}
+}
+
+func (store *idempotencyStore) routine() {
+	for {
+		select {
+		case <-store.exitChan:
+			store.exitChan <- true
+			return
+		case cmd := <-store.commands:
+			switch cmd.Type {
+			case idempotencyCmdBegin:
+				cmd.ResultChan <- store.handleBegin(cmd.Key, cmd.Fingerprint)
+			case idempotencyCmdComplete:
+				if entry, exists := store.entries[cmd.Key]; exists {
+					entry.Completed = true
+					entry.Response = cmd.Response
+				}
+			case idempotencyCmdAbandon:
+				store.handleAbandon(cmd.Key)
+			}
+		}
+	}
+}
+
+// handleAbandon : record of key removed with entry, so that reused key not evicted by stale record
+func (store *idempotencyStore) handleAbandon(key string) {
+	entry, exists := store.entries[key]
+	if !exists || entry.Completed {
+		return
+	}
+	delete(store.entries, key)
+	//abandoned request usually the latest
+	for index := len(store.queue) - 1; index >= 0; index-- {
+		if key == store.queue[index].Key {
+			store.queue = append(store.queue[:index], store.queue[index+1:]...)
+			return
+		}
+	}
+}
+
+func (store *idempotencyStore) handleBegin(key, fingerprint string) (result idempotencyResult) {
+	var now = time.Now()
+	for 0 != len(store.queue) && store.queue[0].Expire.Before(now) {
+		delete(store.entries, store.queue[0].Key)
+		store.queue = store.queue[1:]
+	}
+	if entry, exists := store.entries[key]; exists {
+		if fingerprint != entry.Fingerprint {
+			result.Status = idempotencyMismatch
+		} else if !entry.Completed {
+			result.Status = idempotencyPending
+		} else {
+			result.Status = idempotencyCompleted
+			result.Response = entry.Response
+		}
+		return
+	}
+	if len(store.queue) >= store.capacity {
+		log.Printf("<api> warning: idempotency cache full, key '%s' evicted", store.queue[0].Key)
+		delete(store.entries, store.queue[0].Key)
+		store.queue = store.queue[1:]
+	}
+	store.entries[key] = &idempotencyEntry{Fingerprint: fingerprint}
+	store.queue = append(store.queue, idempotencyRecord{Key: key, Expire: now.Add(store.period)})
+	result.Status = idempotencyNew
+	return
+}
+
+// idempotent : duplicate request with same Idempotency-Key from same credential receives original response,
+// only successful response remembered
+func (module *APIModule) idempotent(handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+		var key = r.Header.Get(HeaderNameIdempotencyKey)
+		recorder, isRecorder := w.(*apiResponseWriter)
+		if "" == key || !isRecorder {
+			handle(w, r, params)
+			return
+		}
+		var err error
+		if len(key) > maxIdempotencyKeyLength {
+			err = fmt.Errorf("idempotency key exceeds %d characters", maxIdempotencyKeyLength)
+			ResponseFail(ResponseDefaultError, err.Error(), w)
+			return
+		}
+		//credential verified before response shared
+		if err = module.verifyRequestSignature(r); err != nil {
+			ResponseFail(SignatureErrorCode(err), err.Error(), w)
+			return
+		}
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			err = errors.New("idempotency key not supported for multipart request")
+			ResponseFail(ResponseDefaultError, err.Error(), w)
+			return
+		}
+		//whole payload fingerprinted regardless of size, already buffered by signature verification
+		var payload []byte
+		if payload, err = ioutil.ReadAll(r.Body); err != nil {
+			ResponseFail(ResponseDefaultError, err.Error(), w)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewBuffer(payload))
+		var hash = sha256.New()
+		hash.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+		hash.Write(payload)
+		var fingerprint = hex.EncodeToString(hash.Sum(nil))
+		var entryKey = requestCredential(r) + "/" + key
+		status, response := module.idempotency.begin(entryKey, fingerprint)
+		switch status {
+		case idempotencyPending:
+			ResponseFail(ResponseIdempotencyConflict, fmt.Sprintf("request with idempotency key '%s' in progress", key), w)
+			return
+		case idempotencyMismatch:
+			ResponseFail(ResponseIdempotencyConflict, fmt.Sprintf("idempotency key '%s' used by different request", key), w)
+			return
+		case idempotencyCompleted:
+			log.Printf("<api> response of idempotency key '%s' replayed for '%s'", key, requestCredential(r))
+			w.Header().Set(HeaderNameIdempotentReplayed, "true")
+			w.WriteHeader(response.Status)
+			w.Write(response.Body)
+			return
+		}
+		recorder.capture = &bytes.Buffer{}
+		var finished = false
+		defer func() {
+			if !finished || recorder.failed || recorder.status >= http.StatusBadRequest {
+				module.idempotency.abandon(entryKey)
+			} else {
+				module.idempotency.complete(entryKey, idempotentResponse{Status: recorder.status, Body: recorder.capture.Bytes()})
+			}
+			recorder.capture = nil
+		}()
+		handle(w, r, params)
+		finished = true
+	}
+}
@@ -0,0 +1,96 @@
+package modules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdempotencyStore_Begin(t *testing.T) {
+	const (
+		opBegin = iota
+		opComplete
+		opAbandon
+	)
+	type idempotencyStep struct {
+		Operation   int
+		Key         string
+		Fingerprint string
+		Status      idempotencyStatus
+	}
+	var testCases = []struct {
+		Name     string
+		Period   time.Duration
+		Capacity int
+		Steps    []idempotencyStep
+	}{
+		{"completed response replayed", time.Hour, 4, []idempotencyStep{
+			{opBegin, "a", "f1", idempotencyNew},
+			{opBegin, "a", "f1", idempotencyPending},
+			{opComplete, "a", "", 0},
+			{opBegin, "a", "f1", idempotencyCompleted},
+		}},
+		{"payload mismatch", time.Hour, 4, []idempotencyStep{
+			{opBegin, "a", "f1", idempotencyNew},
+			{opBegin, "a", "f2", idempotencyMismatch},
+			{opComplete, "a", "", 0},
+			{opBegin, "a", "f2", idempotencyMismatch},
+		}},
+		{"abandoned key executed again", time.Hour, 4, []idempotencyStep{
+			{opBegin, "a", "f1", idempotencyNew},
+			{opAbandon, "a", "", 0},
+			{opBegin, "a", "f2", idempotencyNew},
+			{opBegin, "a", "f2", idempotencyPending},
+		}},
+		{"completed key not abandoned", time.Hour, 4, []idempotencyStep{
+			{opBegin, "a", "f1", idempotencyNew},
+			{opComplete, "a", "", 0},
+			{opAbandon, "a", "", 0},
+			{opBegin, "a", "f1", idempotencyCompleted},
+		}},
+		{"oldest evicted when full", time.Hour, 2, []idempotencyStep{
+			{opBegin, "a", "f1", idempotencyNew},
+			{opBegin, "b", "f1", idempotencyNew},
+			{opBegin, "c", "f1", idempotencyNew},
+			{opBegin, "a", "f1", idempotencyNew},
+			{opBegin, "c", "f1", idempotencyPending},
+		}},
+		{"abandoned record not evicting reused key", time.Hour, 2, []idempotencyStep{
+			{opBegin, "a", "f1", idempotencyNew},
+			{opAbandon, "a", "", 0},
+			{opBegin, "a", "f1", idempotencyNew},
+			{opBegin, "b", "f1", idempotencyNew},
+			{opBegin, "a", "f1", idempotencyPending},
+		}},
+		{"expired key forgotten", -time.Second, 4, []idempotencyStep{
+			{opBegin, "a", "f1", idempotencyNew},
+			{opComplete, "a", "", 0},
+			{opBegin, "a", "f2", idempotencyNew},
+		}},
+	}
+	for _, testCase := range testCases {
+		var store = newIdempotencyStore(testCase.Period, testCase.Capacity)
+		store.start()
+		for index, step := range testCase.Steps {
+			switch step.Operation {
+			case opComplete:
+				store.complete(step.Key, idempotentResponse{Status: 200, Body: []byte(step.Key)})
+			case opAbandon:
+				store.abandon(step.Key)
+			default:
+				status, response := store.begin(step.Key, step.Fingerprint)
+				if status != step.Status {
+					store.stop()
+					t.Fatalf("%s: step %d status %d, %d expected", testCase.Name, index, status, step.Status)
+				}
+				if idempotencyCompleted == status && step.Key != string(response.Body) {
+					store.stop()
+					t.Fatalf("%s: step %d unexpected response '%s'", testCase.Name, index, string(response.Body))
+				}
+			}
+		}
+		store.stop()
+		if len(store.queue) != len(store.entries) {
+			t.Fatalf("%s: %d record(s) queued for %d entries", testCase.Name, len(store.queue), len(store.entries))
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package modules
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	ResponseTooManyRequests = 4290
+	DefaultMaxInFlight      = 64
+	HeaderNameRetryAfter    = "Retry-After"
+)
+
+// APILimit : token bucket refilled by rate per second up to burst, zero rate or max in-flight means unlimited
+type APILimit struct {
+	Rate        float64 `json:"rate,omitempty"`
+	Burst       int     `json:"burst,omitempty"`
+	MaxInFlight int     `json:"max_in_flight,omitempty"`
+}
+
+// APILimitStatus : current state of limiter for credential
+type APILimitStatus struct {
+	Credential  string  `json:"credential"`
+	Rate        float64 `json:"rate"`
+	Burst       int     `json:"burst"`
+	MaxInFlight int     `json:"max_in_flight"`
+	Tokens      float64 `json:"tokens"`
+	InFlight    int     `json:"in_flight"`
+	Accepted    uint64  `json:"accepted"`
+	RateLimited uint64  `json:"rate_limited"`
+	Saturated   uint64  `json:"saturated"`
+}
+
+// apiLease : slot held by request after signature verified, released when handler returned.
+// Verified set with access of credential when signature already checked, such as by idempotency
+type apiLease struct {
+	Credential string
+	Acquired   bool
+	Verified   bool
+	Access     apiAccess
+	RetryAfter time.Duration
+}
+
+type apiLeaseKey struct{}
+
+type limiterState struct {
+	Limit       APILimit
+	Tokens      float64
+	LastRefill  time.Time
+	InFlight    int
+	Accepted    uint64
+	RateLimited uint64
+	Saturated   uint64
+}
+
+type limiterCommandType int
+
+const (
+	limiterCmdAcquire = iota
+	limiterCmdRelease
+	limiterCmdStatus
+)
+
+type limiterResult struct {
+	Allowed    bool
+	RetryAfter time.Duration
+	Status     []APILimitStatus
+}
+
+type limiterCommand struct {
+	Type       limiterCommandType
+	Credential string
+	ResultChan chan limiterResult
+}
+
+// apiLimiter : token bucket and in-flight count of each credential
+type apiLimiter struct {
+	defaultLimit APILimit
+	limits       map[string]APILimit
+	states       map[string]*limiterState
+	commands     chan limiterCommand
+	exitChan     chan bool
+}
+
+func newAPILimiter(defaultLimit APILimit, limits map[string]APILimit) *apiLimiter {
+	const (
+		DefaultQueueLength = 1 << 10
+	)
+	return &apiLimiter{
+		defaultLimit: defaultLimit,
+		limits:       limits,
+		states:       map[string]*limiterState{},
+		commands:     make(chan limiterCommand, DefaultQueueLength),
+		exitChan:     make(chan bool),
+	}
+}
+
+func (limiter *apiLimiter) start() {
+	go limiter.routine()
+}
+
+func (limiter *apiLimiter) stop() {
+	limiter.exitChan <- true
+	<-limiter.exitChan
+}
+
+// acquire : take one token and one in-flight slot, wait duration returned when rejected
+func (limiter *apiLimiter) acquire(credential string) (allowed bool, retryAfter time.Duration) {
+	var respChan = make(chan limiterResult, 1)
+	limiter.commands <- limiterCommand{Type: limiterCmdAcquire, Credential: credential, ResultChan: respChan}
+	var result = <-respChan
+	return result.Allowed, result.RetryAfter
+}
+
+func (limiter *apiLimiter) release(credential string) {
+	limiter.commands <- limiterCommand{Type: limiterCmdRelease, Credential: credential}
+}
+
+func (limiter *apiLimiter) status() []APILimitStatus {
+	var respChan = make(chan limiterResult, 1)
+	limiter.commands <- limiterCommand{Type: limiterCmdStatus, ResultChan: respChan}
+	var result = <-respChan
+	return result.Status
+}
+
+func (limiter *apiLimiter) routine() {
+	for {
+		select {
+		case <-limiter.exitChan:
+			limiter.exitChan <- true
+			return
+		case cmd := <-limiter.commands:
+			switch cmd.Type {
+			case limiterCmdAcquire:
+				var allowed, retryAfter = limiter.handleAcquire(cmd.Credential)
+				cmd.ResultChan <- limiterResult{Allowed: allowed, RetryAfter: retryAfter}
+			case limiterCmdRelease:
+				if state, exists := limiter.states[cmd.Credential]; exists && state.InFlight > 0 {
+					state.InFlight--
+				}
+			case limiterCmdStatus:
+				cmd.ResultChan <- limiterResult{Status: limiter.handleStatus()}
+			}
+		}
+	}
+}
+
+func (limiter *apiLimiter) handleAcquire(credential string) (allowed bool, retryAfter time.Duration) {
+	var now = time.Now()
+	var state = limiter.getState(credential, now)
+	var limit = state.Limit
+	if 0 != limit.MaxInFlight && state.InFlight >= limit.MaxInFlight {
+		state.Saturated++
+		return false, time.Second
+	}
+	if 0 != limit.Rate {
+		state.refill(now)
+		if state.Tokens < 1 {
+			state.RateLimited++
+			var wait = math.Ceil((1 - state.Tokens) / limit.Rate)
+			return false, time.Duration(wait) * time.Second
+		}
+		state.Tokens--
+	}
+	state.InFlight++
+	state.Accepted++
+	return true, 0
+}
+
+func (limiter *apiLimiter) handleStatus() []APILimitStatus {
+	var now = time.Now()
+	var idList []string
+	for id := range limiter.states {
+		idList = append(idList, id)
+	}
+	sort.Strings(idList)
+	var result = make([]APILimitStatus, 0)
+	for _, id := range idList {
+		var state = limiter.states[id]
+		if 0 != state.Limit.Rate {
+			state.refill(now)
+		}
+		result = append(result, APILimitStatus{
+			Credential:  id,
+			Rate:        state.Limit.Rate,
+			Burst:       state.Limit.Burst,
+			MaxInFlight: state.Limit.MaxInFlight,
+			Tokens:      state.Tokens,
+			InFlight:    state.InFlight,
+			Accepted:    state.Accepted,
+			RateLimited: state.RateLimited,
+			Saturated:   state.Saturated,
+		})
+	}
+	return result
+}
+
+// getState : bucket of credential starts full
+func (limiter *apiLimiter) getState(credential string, now time.Time) *limiterState {
+	if state, exists := limiter.states[credential]; exists {
+		return state
+	}
+	var limit = limiter.defaultLimit
+	if specified, exists := limiter.limits[credential]; exists {
+		limit = specified
+	}
+	var state = &limiterState{Limit: limit, Tokens: float64(limit.Burst), LastRefill: now}
+	limiter.states[credential] = state
+	return state
+}
+
+func (state *limiterState) refill(now time.Time) {
+	var elapsed = now.Sub(state.LastRefill).Seconds()
+	state.Tokens = math.Min(float64(state.Limit.Burst), state.Tokens+elapsed*state.Limit.Rate)
+	state.LastRefill = now
+}
+
+func validateAPILimit(limit APILimit) error {
+	if limit.Rate < 0 || limit.Burst < 0 || limit.MaxInFlight < 0 {
+		return fmt.Errorf("negative rate %.2f, burst %d or max in-flight %d", limit.Rate, limit.Burst, limit.MaxInFlight)
+	}
+	return nil
+}
+
+// normalizeLimit : burst not less than one request when rate limited
+func normalizeLimit(limit APILimit) APILimit {
+	if 0 != limit.Rate && limit.Burst < 1 {
+		limit.Burst = int(math.Max(1, math.Ceil(limit.Rate)))
+	}
+	return limit
+}
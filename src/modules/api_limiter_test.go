@@ -0,0 +1,107 @@
+package modules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAPILimiter_TokenBucket(t *testing.T) {
+	type acquireStep struct {
+		Elapsed    time.Duration //moved back from last refill before acquiring
+		Allowed    bool
+		RetryAfter time.Duration
+	}
+	var testCases = []struct {
+		Name  string
+		Limit APILimit
+		Steps []acquireStep
+	}{
+		{"unlimited", APILimit{}, []acquireStep{{0, true, 0}, {0, true, 0}, {0, true, 0}}},
+		{"burst consumed", APILimit{Rate: 1, Burst: 2}, []acquireStep{{0, true, 0}, {0, true, 0}, {0, false, time.Second}}},
+		{"refilled by rate", APILimit{Rate: 2, Burst: 1}, []acquireStep{{0, true, 0}, {0, false, time.Second},
+			{time.Second, true, 0}}},
+		{"slow rate waits longer", APILimit{Rate: 0.2, Burst: 1}, []acquireStep{{0, true, 0}, {0, false, time.Second * 5},
+			{time.Second * 3, false, time.Second * 2}}},
+		{"refill capped by burst", APILimit{Rate: 1, Burst: 1}, []acquireStep{{time.Minute, true, 0}, {0, false, time.Second}}},
+	}
+	for _, testCase := range testCases {
+		var credential = "test"
+		var limiter = newAPILimiter(APILimit{}, map[string]APILimit{credential: testCase.Limit})
+		for index, step := range testCase.Steps {
+			if state, exists := limiter.states[credential]; exists {
+				state.LastRefill = state.LastRefill.Add(-step.Elapsed)
+			}
+			allowed, retryAfter := limiter.handleAcquire(credential)
+			if allowed != step.Allowed || retryAfter != step.RetryAfter {
+				t.Fatalf("%s: step %d allowed %t retry after %s, %t and %s expected", testCase.Name, index,
+					allowed, retryAfter, step.Allowed, step.RetryAfter)
+			}
+		}
+	}
+}
+
+func TestAPILimiter_InFlight(t *testing.T) {
+	const (
+		limited   = "limited"
+		unlimited = "unlimited"
+	)
+	var limiter = newAPILimiter(APILimit{MaxInFlight: 2}, map[string]APILimit{unlimited: {}})
+	limiter.start()
+	defer limiter.stop()
+	var testCases = []struct {
+		Name       string
+		Credential string
+		Release    bool
+		Allowed    bool
+	}{
+		{"first slot", limited, false, true},
+		{"second slot", limited, false, true},
+		{"saturated", limited, false, false},
+		{"other credential not affected", unlimited, false, true},
+		{"slot released", limited, true, true},
+		{"saturated again", limited, false, false},
+	}
+	for _, testCase := range testCases {
+		if testCase.Release {
+			limiter.release(testCase.Credential)
+		}
+		if allowed, _ := limiter.acquire(testCase.Credential); allowed != testCase.Allowed {
+			t.Fatalf("%s: allowed %t, %t expected", testCase.Name, allowed, testCase.Allowed)
+		}
+	}
+	var status = limiter.status()
+	if 2 != len(status) || limited != status[0].Credential {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if 2 != status[0].InFlight || 3 != status[0].Accepted || 2 != status[0].Saturated {
+		t.Fatalf("unexpected status of '%s': %+v", limited, status[0])
+	}
+}
+
+func TestAPILimiter_NormalizeLimit(t *testing.T) {
+	var testCases = []struct {
+		Name     string
+		Limit    APILimit
+		Valid    bool
+		Expected APILimit
+	}{
+		{"unlimited", APILimit{}, true, APILimit{}},
+		{"burst kept", APILimit{Rate: 5, Burst: 10}, true, APILimit{Rate: 5, Burst: 10}},
+		{"burst from rate", APILimit{Rate: 2.5}, true, APILimit{Rate: 2.5, Burst: 3}},
+		{"burst at least one", APILimit{Rate: 0.5}, true, APILimit{Rate: 0.5, Burst: 1}},
+		{"negative rate", APILimit{Rate: -1}, false, APILimit{}},
+		{"negative in-flight", APILimit{MaxInFlight: -1}, false, APILimit{}},
+	}
+	for _, testCase := range testCases {
+		var err = validateAPILimit(testCase.Limit)
+		if (nil == err) != testCase.Valid {
+			t.Fatalf("%s: unexpected validate result %v", testCase.Name, err)
+		}
+		if !testCase.Valid {
+			continue
+		}
+		if normalized := normalizeLimit(testCase.Limit); normalized != testCase.Expected {
+			t.Fatalf("%s: normalized as %+v, %+v expected", testCase.Name, normalized, testCase.Expected)
+		}
+	}
+}
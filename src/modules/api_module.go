@@ -33,18 +33,47 @@ type APIModule struct {
 	currentImageURL   string
 	currentImageProxy *httputil.ReverseProxy
 	apiCredentials    map[string]string
+	apiAccess         map[string]apiAccess
+	apiRoles          map[string]APIRole
+	bootstrap         bool
+	bootstrapFile     string
+	backupKey         []byte //sign zone backups, derived from seal key
+	signatureWindow   time.Duration
+	requireNonce      bool
+	replay            *replayGuard
+	limiter           *apiLimiter
+	idempotency       *idempotencyStore
 	proxy             *RequestProxy
 	resource          ResourceModule
+	events            EventModule
+	webhooks          WebhookModule
+	jobs              JobModule
+	credentials       CredentialModule
+	audit             AuditModule
 }
 
+// ApiCredential : administrator when no role specified, empty scope of pools, users and groups means unrestricted
 type ApiCredential struct {
-	ID  string `json:"id"`
-	Key string `json:"key"`
+	ID     string    `json:"id"`
+	Key    string    `json:"key"`
+	Role   string    `json:"role,omitempty"`
+	Pools  []string  `json:"pools,omitempty"`
+	Users  []string  `json:"users,omitempty"`
+	Groups []string  `json:"groups,omitempty"`
+	Limit  *APILimit `json:"limit,omitempty"`
 }
 
+// APIConfig : signature window in seconds, requests with Nano-Date out of window rejected
 type APIConfig struct {
-	Port        int             `json:"port"`
-	Credentials []ApiCredential `json:"credentials"`
+	Port            int             `json:"port"`
+	Credentials     []ApiCredential `json:"credentials"`
+	Roles           []APIRole       `json:"roles,omitempty"`
+	SignatureWindow int             `json:"signature_window,omitempty"`
+	RequireNonce    bool            `json:"require_nonce,omitempty"`
+	NonceCapacity   int             `json:"nonce_capacity,omitempty"`
+	Limit           *APILimit       `json:"limit,omitempty"`
+	//seconds of idempotency key remembered
+	IdempotencyPeriod int `json:"idempotency_period,omitempty"`
 }
 
 const (
@@ -54,11 +83,17 @@ const (
 	HeaderNameDate          = "Nano-Date"
 	HeaderNameScope         = "Nano-Scope"
 	HeaderNameAuthorization = "Nano-Authorization"
+	HeaderNameTotalCount    = "Nano-Total-Count"
+	HeaderNameJob           = "Nano-Job" //job of operation answering empty payload
 	APIRoot                 = "/api"
 	APIVersion              = 1
+	BootstrapKeyFilename    = "bootstrap.key"
+	bootstrapKeyFilePerm    = 0600
 )
 
-func CreateAPIModule(configPath string, sender framework.MessageSender, resourceModule ResourceModule) (module *APIModule, err error) {
+func CreateAPIModule(configPath string, sender framework.MessageSender, resourceModule ResourceModule, eventModule EventModule,
+	webhookModule WebhookModule, jobModule JobModule, credentialModule CredentialModule,
+	auditModule AuditModule) (module *APIModule, err error) {
 	//load config
 	const (
 		configFilename = "api.cfg"
@@ -72,37 +107,57 @@ func CreateAPIModule(configPath string, sender framework.MessageSender, resource
 	if err = json.Unmarshal(data, &config); err != nil {
 		return
 	}
-	if 0 == len(config.Credentials) {
-		const (
-			dummyID  = "dummyID"
-			dummyKey = "ThisIsAKeyPlaceHolder_ChangeToYourContent"
-		)
-		config.Credentials = []ApiCredential{{ID: dummyID, Key: dummyKey}}
-		if data, err = json.MarshalIndent(config, "", " "); err != nil {
-			err = fmt.Errorf("marshal new config fail: %s", err.Error())
-			return
-		}
-		var file *os.File
-		if file, err = os.Create(configFile); err != nil {
-			err = fmt.Errorf("create new config fail: %s", err.Error())
-			return
-		}
-		defer file.Close()
-		if _, err = file.Write(data); err != nil {
-			err = fmt.Errorf("write new config fail: %s", err.Error())
-			return
-		}
-		log.Printf("<api> warning: dummy API credential '%s' created", dummyID)
-	}
 	var proxy *RequestProxy
 
 	if proxy, err = CreateRequestProxy(sender); err != nil {
 		return
 	}
 	var listenAddress = fmt.Sprintf(":%d", config.Port)
+	var roles map[string]APIRole
+	if roles, err = loadAPIRoles(config.Roles); err != nil {
+		return
+	}
 	module = &APIModule{}
+	var defaultLimit = APILimit{MaxInFlight: DefaultMaxInFlight}
+	if nil != config.Limit {
+		defaultLimit = *config.Limit
+	}
+	if err = validateAPILimit(defaultLimit); err != nil {
+		return
+	}
+	var credentialLimits = map[string]APILimit{}
 	module.apiCredentials = map[string]string{}
-
+	module.apiAccess = map[string]apiAccess{}
+	module.apiRoles = roles
+	//administrator created on start when no credential available
+	module.bootstrap = 0 == len(config.Credentials)
+	module.bootstrapFile = filepath.Join(configPath, BootstrapKeyFilename)
+	if module.backupKey, err = loadZoneBackupKey(filepath.Join(configPath, CredentialSealFilename)); err != nil {
+		return
+	}
+	if 0 == config.SignatureWindow {
+		config.SignatureWindow = DefaultSignatureWindow
+	} else if config.SignatureWindow < 0 {
+		err = fmt.Errorf("invalid signature window %d", config.SignatureWindow)
+		return
+	}
+	if 0 == config.NonceCapacity {
+		config.NonceCapacity = DefaultNonceCapacity
+	} else if config.NonceCapacity < 0 {
+		err = fmt.Errorf("invalid nonce capacity %d", config.NonceCapacity)
+		return
+	}
+	module.signatureWindow = time.Duration(config.SignatureWindow) * time.Second
+	module.requireNonce = config.RequireNonce
+	module.replay = newReplayGuard(config.NonceCapacity)
+	module.limiter = newAPILimiter(normalizeLimit(defaultLimit), credentialLimits)
+	if 0 == config.IdempotencyPeriod {
+		config.IdempotencyPeriod = DefaultIdempotencyPeriod
+	} else if config.IdempotencyPeriod < 0 {
+		err = fmt.Errorf("invalid idempotency period %d", config.IdempotencyPeriod)
+		return
+	}
+	module.idempotency = newIdempotencyStore(time.Duration(config.IdempotencyPeriod)*time.Second, DefaultIdempotencyCapacity)
 	for _, credential := range config.Credentials {
 		if 0 == len(credential.ID) {
 			err = errors.New("empty API ID")
@@ -113,15 +168,34 @@ func CreateAPIModule(configPath string, sender framework.MessageSender, resource
 			return
 		}
 		module.apiCredentials[credential.ID] = credential.Key
+		if nil != credential.Limit {
+			if err = validateAPILimit(*credential.Limit); err != nil {
+				err = fmt.Errorf("invalid limit of credential '%s': %s", credential.ID, err.Error())
+				return
+			}
+			credentialLimits[credential.ID] = normalizeLimit(*credential.Limit)
+		}
+		if module.apiAccess[credential.ID], err = newAPIAccess(credential, roles); err != nil {
+			return
+		}
 	}
 	module.exitChan = make(chan bool)
 	module.proxy = proxy
 	module.server.Addr = listenAddress
+	module.audit = auditModule
 	var router = httprouter.New()
 	module.RegisterAPIHandler(router)
 	log.Println("register finish")
 	module.server.Handler = router
 	module.resource = resourceModule
+	module.events = eventModule
+	module.webhooks = webhookModule
+	module.jobs = jobModule
+	module.credentials = credentialModule
+	if err = module.registerJobHandlers(); err != nil {
+		err = fmt.Errorf("register job handlers fail: %s", err.Error())
+		return
+	}
 	log.Printf("<api> config loaded from %s, listen port %d, %d API credentials available ",
 		configFile, config.Port, len(module.apiCredentials))
 	return
@@ -142,6 +216,14 @@ func (module *APIModule) Start() error {
 	if err := module.proxy.Start(); err != nil {
 		return err
 	}
+	if module.bootstrap {
+		if err := module.bootstrapCredential(); err != nil {
+			return err
+		}
+	}
+	module.replay.start()
+	module.limiter.start()
+	module.idempotency.start()
 	go module.routine()
 	return nil
 }
@@ -149,6 +231,9 @@ func (module *APIModule) Start() error {
 func (module *APIModule) Stop() error {
 	module.server.Close()
 	<-module.exitChan
+	module.replay.stop()
+	module.limiter.stop()
+	module.idempotency.stop()
 	return module.proxy.Stop()
 }
 
@@ -175,33 +260,38 @@ func (module *APIModule) verifyStreamSignature(r *http.Request) error {
 func (module *APIModule) verifySignature(r *http.Request, processPayload bool) (err error) {
 	const (
 		SignatureMethodHMAC256 = "Nano-HMAC-SHA256"
-		ShortDateFormat        = "20060102"
+		maxNonceLength         = 64
 	)
+	lease, hasLease := r.Context().Value(apiLeaseKey{}).(*apiLease)
+	if hasLease && lease.Verified {
+		return nil
+	}
 	r.Header.Set(HeaderNameHost, r.Host)
-	var apiID, apiKey, requestScope, signedHeaders, signature, signatureMethod string
+	var apiID, requestScope, signedHeaders, signature, signatureMethod string
+	var signer apiSigner
 	{
 		//Method Credential=id/scope, SignedHeaders=headers, Signature=signatures
 		//check authorization
 		var authorization = r.Header.Get(HeaderNameAuthorization)
 		var length = len(authorization)
 		if 0 == length {
-			err = errors.New("authorization required")
+			err = signatureError(ResponseSignatureMalformed, "authorization required")
 			return
 		}
 		if length <= len(SignatureMethodHMAC256) {
-			err = fmt.Errorf("insufficent authorization: %s", authorization)
+			err = signatureError(ResponseSignatureMalformed, "insufficent authorization: %s", authorization)
 			return
 		}
 		signatureMethod = authorization[:len(SignatureMethodHMAC256)]
 		if SignatureMethodHMAC256 != signatureMethod {
-			err = fmt.Errorf("invalid signature method: %s", signatureMethod)
+			err = signatureError(ResponseSignatureMalformed, "invalid signature method: %s", signatureMethod)
 			return
 		}
 		var names, values []string
 		for _, token := range strings.Split(authorization[len(SignatureMethodHMAC256)+1:], ",") {
 			var split = strings.SplitN(token, "=", 2)
 			if 2 != len(split) {
-				err = fmt.Errorf("invalid authorization token: %s", token)
+				err = signatureError(ResponseSignatureMalformed, "invalid authorization token: %s", token)
 				return
 			}
 			names = append(names, strings.Trim(split[0], " "))
@@ -214,37 +304,37 @@ func (module *APIModule) verifySignature(r *http.Request, processPayload bool) (
 			TokenSignature     = "Signature"
 		)
 		if TokenCount != len(names) || TokenCount != len(values) {
-			err = fmt.Errorf("unexpected token count %d/%d", len(names), len(values))
+			err = signatureError(ResponseSignatureMalformed, "unexpected token count %d/%d", len(names), len(values))
 			return
 		}
 		if TokenCredential != names[0] {
-			err = fmt.Errorf("invalid first token %s", names[0])
+			err = signatureError(ResponseSignatureMalformed, "invalid first token %s", names[0])
 			return
 		}
 		if TokenSignedHeaders != names[1] {
-			err = fmt.Errorf("invalid second token %s", names[1])
+			err = signatureError(ResponseSignatureMalformed, "invalid second token %s", names[1])
 			return
 		}
 		if TokenSignature != names[2] {
-			err = fmt.Errorf("invalid third token %s", names[2])
+			err = signatureError(ResponseSignatureMalformed, "invalid third token %s", names[2])
 			return
 		}
 		var idTail = strings.IndexByte(values[0], '/')
 		if -1 == idTail {
-			err = fmt.Errorf("no API ID in credential: %s", values[0])
+			err = signatureError(ResponseSignatureMalformed, "no API ID in credential: %s", values[0])
 			return
 		}
 		apiID = values[0][:idTail]
-		var exists bool
-		if apiKey, exists = module.apiCredentials[apiID]; !exists {
-			err = fmt.Errorf("invalid API ID: %s", apiID)
+		if signer, err = module.resolveSigner(apiID); err != nil {
+			err = signatureError(ResponseSignatureInvalid, err.Error())
 			return
 		}
 		requestScope = values[0][idTail+1:]
 		signedHeaders = values[1]
 		signature = values[2]
 	}
-	var canonicalRequest, requestDate, stringToSign string
+	var canonicalRequest, requestDate, nonce, stringToSign string
+	var requestTime time.Time
 	{
 		//canonicalRequest
 		var canonicalURI = url.QueryEscape(url.QueryEscape(r.URL.Path))
@@ -281,24 +371,45 @@ func (module *APIModule) verifySignature(r *http.Request, processPayload bool) (
 			}
 			if HeaderNameDate == name {
 				requestDate = r.Header.Get(name)
-				var requestTime time.Time
 				if requestTime, err = time.Parse(time.RFC3339, requestDate); err != nil {
-					err = fmt.Errorf("invalid request date: %s", requestDate)
+					err = signatureError(ResponseSignatureMalformed, "invalid request date: %s", requestDate)
+					return
 				}
-				//must on same day
-				if time.Now().Format(ShortDateFormat) != requestTime.Format(ShortDateFormat) {
-					err = fmt.Errorf("expired request with date %s", requestDate)
+				//must within validity window
+				var skew = time.Since(requestTime)
+				if skew > module.signatureWindow || skew < -module.signatureWindow {
+					err = signatureError(ResponseSignatureExpired, "expired request with date %s", requestDate)
 					return
 				}
 			}
+			if HeaderNameNonce == name {
+				nonce = r.Header.Get(name)
+			}
 			if HeaderNameScope == name {
 				var scope = r.Header.Get(name)
 				if scope != requestScope {
-					err = fmt.Errorf("request scope mismatch: %s => %s", scope, requestScope)
+					err = signatureError(ResponseSignatureMalformed, "request scope mismatch: %s => %s", scope, requestScope)
 					return
 				}
 			}
 		}
+		if "" == requestDate {
+			err = signatureError(ResponseSignatureMalformed, "request date required")
+			return
+		}
+		if "" != nonce {
+			if _, signed := headerIndexes[strings.ToLower(HeaderNameNonce)]; !signed {
+				err = signatureError(ResponseSignatureMalformed, "nonce must be signed")
+				return
+			}
+			if len(nonce) > maxNonceLength {
+				err = signatureError(ResponseSignatureMalformed, "nonce exceeds %d characters", maxNonceLength)
+				return
+			}
+		} else if module.requireNonce {
+			err = signatureError(ResponseSignatureMalformed, "nonce required")
+			return
+		}
 		var canonicalHeaders string
 		var headersBuilder strings.Builder
 		for _, token := range signedHeaderToken {
@@ -341,32 +452,154 @@ func (module *APIModule) verifySignature(r *http.Request, processPayload bool) (
 			canonicalRequest,
 		}, "\n")
 	}
-	var signKey []byte
 	{
-		var builder strings.Builder
-		builder.WriteString("nano")
-		builder.WriteString(apiKey)
-
-		var key = []byte(builder.String())
 		var data = []byte(requestScope)
-		if signKey, err = computeHMACSha256(key, data); err != nil {
-			err = fmt.Errorf("compute signature key fail: %s", err.Error())
+		var verified = false
+		for _, key := range signer.Keys {
+			var signKey, hmacSignature []byte
+			if signKey, err = computeHMACSha256(key, data); err != nil {
+				err = fmt.Errorf("compute signature key fail: %s", err.Error())
+				return
+			}
+			if hmacSignature, err = computeHMACSha256(signKey, []byte(stringToSign)); err != nil {
+				err = fmt.Errorf("compute signature fail: %s", err.Error())
+				return
+			}
+			if signature == hex.EncodeToString(hmacSignature) {
+				verified = true
+				break
+			}
+		}
+		if !verified {
+			err = signatureError(ResponseSignatureInvalid, "signature corrupted")
+			return
+		}
+	}
+	if "" != nonce {
+		//nonce expires with request date
+		if module.replay.seenBefore(apiID+"/"+nonce, requestTime.Add(module.signatureWindow)) {
+			err = signatureError(ResponseSignatureReplayed, "nonce '%s' already used", nonce)
 			return
 		}
-		var hmacSignature []byte
-		if hmacSignature, err = computeHMACSha256(signKey, []byte(stringToSign)); err != nil {
-			err = fmt.Errorf("compute signature fail: %s", err.Error())
+	}
+	if hasLease && !lease.Acquired {
+		var allowed bool
+		if allowed, lease.RetryAfter = module.limiter.acquire(apiID); !allowed {
+			err = signatureError(ResponseTooManyRequests, "too many requests from credential '%s', retry after %d second(s)",
+				apiID, int(lease.RetryAfter/time.Second))
 			return
 		}
-		var expectedSignature = hex.EncodeToString(hmacSignature)
-		if signature != expectedSignature {
-			err = errors.New("signature corrupted")
+		lease.Credential = apiID
+		lease.Acquired = true
+	}
+	if err = module.authorizeRequest(apiID, signer.Access, r); err != nil {
+		err = signatureError(ResponseAccessDenied, err.Error())
+		return
+	}
+	if hasLease {
+		lease.Verified = true
+		lease.Access = signer.Access
+	}
+	return nil
+}
+
+// apiSigner : keys of credential available for signature, previous keys remain valid during rotation
+type apiSigner struct {
+	Keys    [][]byte
+	Secrets []string
+	Access  apiAccess
+}
+
+// resolveSigner : credentials in config sign with prefixed key, managed credentials sign with digest of prefixed key
+func (module *APIModule) resolveSigner(apiID string) (signer apiSigner, err error) {
+	if key, exists := module.apiCredentials[apiID]; exists {
+		signer.Keys = [][]byte{[]byte("nano" + key)}
+		signer.Secrets = []string{key}
+		signer.Access = module.apiAccess[apiID]
+		return
+	}
+	var respChan = make(chan CredentialResult, 1)
+	module.credentials.ResolveCredential(apiID, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		err = result.Error
+		return
+	}
+	var credential = result.Credential
+	for _, key := range credential.Keys {
+		var digest []byte
+		if digest, err = hex.DecodeString(key.Digest); err != nil {
+			err = fmt.Errorf("invalid digest of credential '%s': %s", apiID, err.Error())
 			return
 		}
+		signer.Keys = append(signer.Keys, digest)
+		signer.Secrets = append(signer.Secrets, key.Digest)
+	}
+	signer.Access, err = newAPIAccess(ApiCredential{
+		ID:     apiID,
+		Role:   credential.Role,
+		Pools:  credential.Pools,
+		Users:  credential.Users,
+		Groups: credential.Groups,
+	}, module.apiRoles)
+	return
+}
+
+// bootstrapCredential : create administrator credential when neither config nor runtime credential available
+func (module *APIModule) bootstrapCredential() (err error) {
+	const (
+		bootstrapID = "admin"
+	)
+	var respChan = make(chan CredentialResult, 1)
+	module.credentials.QueryCredentials(respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		return result.Error
+	}
+	if 0 != len(result.CredentialList) {
+		return nil
 	}
+	var config = CredentialConfig{Role: APIRoleAdmin, Enabled: true}
+	module.credentials.CreateCredential(bootstrapID, config, time.Time{}, respChan)
+	result = <-respChan
+	if result.Error != nil {
+		err = fmt.Errorf("create bootstrap credential fail: %s", result.Error.Error())
+		return
+	}
+	//recreate so that permission of stale file not inherited
+	if err = os.Remove(module.bootstrapFile); err != nil && !os.IsNotExist(err) {
+		return
+	}
+	var data []byte
+	if data, err = json.MarshalIndent(ApiCredential{ID: result.ID, Key: result.Key, Role: APIRoleAdmin}, "", " "); err != nil {
+		return
+	}
+	if err = os.WriteFile(module.bootstrapFile, data, bootstrapKeyFilePerm); err != nil {
+		err = fmt.Errorf("save bootstrap credential fail: %s", err.Error())
+		return
+	}
+	log.Printf("<api> warning: no API credential available, bootstrap credential '%s' saved in '%s', rotate it and remove the file after first use",
+		result.ID, module.bootstrapFile)
 	return nil
 }
 
+// requestCredential : API ID in authorization of a verified request
+func requestCredential(r *http.Request) string {
+	const (
+		credentialPrefix = "Credential="
+	)
+	var authorization = r.Header.Get(HeaderNameAuthorization)
+	var begin = strings.Index(authorization, credentialPrefix)
+	if -1 == begin {
+		return ""
+	}
+	var credential = authorization[begin+len(credentialPrefix):]
+	if end := strings.IndexByte(credential, '/'); -1 != end {
+		return credential[:end]
+	}
+	return ""
+}
+
 func computeHMACSha256(key, data []byte) (hash []byte, err error) {
 	var h = hmac.New(sha256.New, key)
 	if _, err = h.Write(data); err != nil {
@@ -376,12 +609,19 @@ func computeHMACSha256(key, data []byte) (hash []byte, err error) {
 	return
 }
 
-func (module *APIModule) RegisterAPIHandler(router *httprouter.Router) {
+func (module *APIModule) RegisterAPIHandler(mux *httprouter.Router) {
+	var router = apiRouter{router: mux, audit: module.audit, limiter: module.limiter}
 	router.GET(apiPath("/compute_pools/"), module.handleQueryAllPools)
 	router.GET(apiPath("/compute_pools/:pool"), module.handleGetComputePool)
 	router.POST(apiPath("/compute_pools/:pool"), module.handleCreateComputePool)
 	router.PUT(apiPath("/compute_pools/:pool"), module.handleModifyComputePool)
 	router.DELETE(apiPath("/compute_pools/:pool"), module.handleDeleteComputePool)
+	router.GET(apiPath("/compute_pools/:pool/rebalance"), module.getRebalancePlan)
+	router.POST(apiPath("/compute_pools/:pool/rebalance"), module.startRebalance)
+	router.PUT(apiPath("/compute_pools/:pool/rebalance"), module.modifyRebalanceConfig)
+	router.GET(apiPath("/compute_pools/:pool/failover_capacity"), module.getFailoverCapacity)
+	router.PUT(apiPath("/compute_pools/:pool/failover_capacity"), module.modifyFailoverCapacity)
+	router.GET(apiPath("/compute_pools/:pool/failover_preview"), module.previewPoolFailover)
 
 	router.POST(apiPath("/compute_pool_cells/:pool/:cell"), module.handleAddComputeCell)
 	router.DELETE(apiPath("/compute_pool_cells/:pool/:cell"), module.handleRemoveComputeCell)
@@ -389,6 +629,12 @@ func (module *APIModule) RegisterAPIHandler(router *httprouter.Router) {
 	router.GET(apiPath("/compute_pool_cells/"), module.handleQueryUnallocatedCell)
 	router.GET(apiPath("/compute_pool_cells/:pool"), module.handleQueryCellsInPool)
 	router.GET(apiPath("/compute_pool_cells/:pool/:cell"), module.handleGetComputeCell)
+	router.GET(apiPath("/compute_pool_cells/:pool/:cell/maintenance"), module.getCellMaintenance)
+	router.POST(apiPath("/compute_pool_cells/:pool/:cell/maintenance"), module.startCellMaintenance)
+	router.DELETE(apiPath("/compute_pool_cells/:pool/:cell/maintenance"), module.cancelCellMaintenance)
+	router.GET(apiPath("/compute_pool_cells/:pool/:cell/labels"), module.getCellLabels)
+	router.PUT(apiPath("/compute_pool_cells/:pool/:cell/labels"), module.modifyCellLabels)
+	router.GET(apiPath("/compute_pool_cells/:pool/:cell/failover_preview"), module.previewCellFailover)
 
 	router.GET(apiPath("/storage_pools/"), module.handleQueryStoragePool)
 	router.GET(apiPath("/storage_pools/:pool"), module.handleGetStoragePool)
@@ -397,6 +643,32 @@ func (module *APIModule) RegisterAPIHandler(router *httprouter.Router) {
 	router.DELETE(apiPath("/storage_pools/:pool"), module.handleDeleteStoragePool)
 
 	router.GET(apiPath("/compute_zone_status/"), module.queryZoneStatistic)
+	router.GET(apiPath("/zone/backup"), module.backupZone)
+	router.POST(apiPath("/zone/restore"), module.restoreZone)
+	router.GET(apiPath("/events"), module.streamEvents)
+
+	router.GET(apiPath("/webhooks/"), module.queryWebhooks)
+	router.GET(apiPath("/webhooks/:id"), module.getWebhook)
+	router.POST(apiPath("/webhooks/"), module.createWebhook)
+	router.PUT(apiPath("/webhooks/:id"), module.modifyWebhook)
+	router.DELETE(apiPath("/webhooks/:id"), module.deleteWebhook)
+	router.GET(apiPath("/webhook_dead_letters/"), module.queryWebhookDeadLetters)
+	router.POST(apiPath("/webhook_dead_letters/:id/redeliver"), module.redeliverWebhookDeadLetter)
+	router.DELETE(apiPath("/webhook_dead_letters/:id"), module.deleteWebhookDeadLetter)
+
+	router.GET(apiPath("/jobs/"), module.queryJobs)
+	router.GET(apiPath("/jobs/:id"), module.getJob)
+	router.POST(apiPath("/jobs/:id/cancel"), module.cancelJob)
+
+	router.GET(apiPath("/credentials/"), module.queryCredentials)
+	router.GET(apiPath("/credentials/:id"), module.getCredential)
+	router.POST(apiPath("/credentials/"), module.createCredential)
+	router.PUT(apiPath("/credentials/:id"), module.modifyCredential)
+	router.POST(apiPath("/credentials/:id/rotate"), module.rotateCredential)
+	router.DELETE(apiPath("/credentials/:id"), module.deleteCredential)
+
+	router.GET(apiPath("/audit/"), module.queryAuditRecords)
+	router.GET(apiPath("/limits/"), module.queryAPILimits)
 	router.GET(apiPath("/compute_pool_status/"), module.queryComputePoolsStatus)
 	router.GET(apiPath("/compute_pool_status/:pool"), module.getComputePoolStatus)
 	router.GET(apiPath("/compute_cell_status/:pool"), module.queryComputeCellStatus)
@@ -408,7 +680,7 @@ func (module *APIModule) RegisterAPIHandler(router *httprouter.Router) {
 	router.GET(apiPath("/instance_status/:pool/:cell"), module.handleQueryInstanceStatusInCell)
 
 	router.GET(apiPath("/guests/:id"), module.handleGetGuestConfig)
-	router.POST(apiPath("/guests/"), module.handleCreateGuest)
+	router.POST(apiPath("/guests/"), module.idempotent(module.handleCreateGuest))
 	router.DELETE(apiPath("/guests/:id"), module.handleDeleteGuest)
 
 	router.PUT(apiPath("/guests/:id/name/"), module.handleModifyGuestName)
@@ -446,7 +718,7 @@ func (module *APIModule) RegisterAPIHandler(router *httprouter.Router) {
 	router.GET(apiPath("/disk_image_search/*filepath"), module.queryDiskImage)
 
 	router.GET(apiPath("/disk_images/:id"), module.getDiskImage)
-	router.POST(apiPath("/disk_images/"), module.createDiskImage)
+	router.POST(apiPath("/disk_images/"), module.idempotent(module.createDiskImage))
 	router.PUT(apiPath("/disk_images/:id"), module.modifyDiskImage)
 	router.DELETE(apiPath("/disk_images/:id"), module.deleteDiskImage)
 	router.PATCH(apiPath("/disk_images/"), module.syncDiskImages)
@@ -459,7 +731,7 @@ func (module *APIModule) RegisterAPIHandler(router *httprouter.Router) {
 
 	//snapshots
 	router.GET(apiPath("/instances/:id/snapshots/"), module.handleQueryInstanceSnapshots)
-	router.POST(apiPath("/instances/:id/snapshots/"), module.handleCreateInstanceSnapshot)
+	router.POST(apiPath("/instances/:id/snapshots/"), module.idempotent(module.handleCreateInstanceSnapshot))
 	router.PUT(apiPath("/instances/:id/snapshots/"), module.handleRestoreInstanceSnapshot)
 	router.GET(apiPath("/instances/:id/snapshots/:name"), module.handleGetInstanceSnapshot)
 	router.DELETE(apiPath("/instances/:id/snapshots/:name"), module.handleDeleteInstanceSnapshot)
@@ -467,7 +739,7 @@ func (module *APIModule) RegisterAPIHandler(router *httprouter.Router) {
 	//migrations
 	router.GET(apiPath("/migrations/"), module.handleQueryMigrations)
 	router.GET(apiPath("/migrations/:id"), module.handleGetMigration)
-	router.POST(apiPath("/migrations/"), module.handleCreateMigration)
+	router.POST(apiPath("/migrations/"), module.idempotent(module.handleCreateMigration))
 
 	//address pool
 	router.GET(apiPath("/address_pools/"), module.handleQueryAddressPool)
@@ -484,7 +756,7 @@ func (module *APIModule) RegisterAPIHandler(router *httprouter.Router) {
 
 	//batch
 	router.GET(apiPath("/batch/create_guest/:id"), module.handleGetBatchCreateGuest)
-	router.POST(apiPath("/batch/create_guest/"), module.handleStartBatchCreateGuest)
+	router.POST(apiPath("/batch/create_guest/"), module.idempotent(module.handleStartBatchCreateGuest))
 	router.GET(apiPath("/batch/delete_guest/:id"), module.handleGetBatchDeleteGuest)
 	router.POST(apiPath("/batch/delete_guest/"), module.handleStartBatchDeleteGuest)
 	router.GET(apiPath("/batch/stop_guest/:id"), module.handleGetBatchStopGuest)
@@ -516,6 +788,20 @@ func (module *APIModule) RegisterAPIHandler(router *httprouter.Router) {
 	router.DELETE(apiPath("/guests/:id/security_policy/rules/:index"), module.removeGuestSecurityRule)
 	router.PUT(apiPath("/guests/:id/security_policy/rules/:index/order"), module.moveGuestSecurityRule)
 
+	//server groups
+	router.GET(apiPath("/server_groups/"), module.queryServerGroups)
+	router.GET(apiPath("/server_groups/:id"), module.getServerGroup)
+	router.POST(apiPath("/server_groups/"), module.createServerGroup)
+	router.PUT(apiPath("/server_groups/:id"), module.modifyServerGroup)
+	router.DELETE(apiPath("/server_groups/:id"), module.deleteServerGroup)
+
+	//quotas
+	router.GET(apiPath("/quotas/"), module.queryQuotas)
+	router.GET(apiPath("/quotas/:scope/:name"), module.getQuota)
+	router.POST(apiPath("/quotas/"), module.createQuota)
+	router.PUT(apiPath("/quotas/:scope/:name"), module.modifyQuota)
+	router.DELETE(apiPath("/quotas/:scope/:name"), module.deleteQuota)
+
 	//search resource
 	router.GET(apiPath("/search/security_policy_groups/*filepath"), module.querySecurityPolicyGroups)
 	router.POST(apiPath("/search/guests/"), module.searchGuests)
@@ -523,7 +809,7 @@ func (module *APIModule) RegisterAPIHandler(router *httprouter.Router) {
 
 func (module *APIModule) queryZoneStatistic(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	msg, _ := framework.CreateJsonMessage(framework.QueryZoneStatusRequest)
@@ -630,7 +916,7 @@ func (module *APIModule) queryZoneStatistic(w http.ResponseWriter, r *http.Reque
 
 func (module *APIModule) queryComputePoolsStatus(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	msg, _ := framework.CreateJsonMessage(framework.QueryComputePoolStatusRequest)
@@ -756,12 +1042,19 @@ func (module *APIModule) queryComputePoolsStatus(w http.ResponseWriter, r *http.
 		ResponseFail(ResponseDefaultError, err.Error(), w)
 		return
 	}
-	ResponseOK(data, w)
+	var access = requestAccess(r)
+	var visible = make([]poolStatus, 0, len(data))
+	for _, status := range data {
+		if access.poolAllowed(status.Name) {
+			visible = append(visible, status)
+		}
+	}
+	ResponseOK(visible, w)
 }
 
 func (module *APIModule) getComputePoolStatus(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	pool := params.ByName("pool")
@@ -781,20 +1074,21 @@ func (module *APIModule) getComputePoolStatus(w http.ResponseWriter, r *http.Req
 		return
 	}
 	type respData struct {
-		Name            string   `json:"name"`
-		Enabled         bool     `json:"enabled"`
-		Cells           []uint64 `json:"cells"`
-		Instances       []uint64 `json:"instances"`
-		CpuUsage        float64  `json:"cpu_usage"`
-		MaxCpu          uint     `json:"max_cpu"`
-		AvailableMemory uint64   `json:"available_memory"`
-		MaxMemory       uint64   `json:"max_memory"`
-		AvailableDisk   uint64   `json:"available_disk"`
-		MaxDisk         uint64   `json:"max_disk"`
-		ReadSpeed       uint64   `json:"read_speed"`
-		WriteSpeed      uint64   `json:"write_speed"`
-		ReceiveSpeed    uint64   `json:"receive_speed"`
-		SendSpeed       uint64   `json:"send_speed"`
+		Name             string                  `json:"name"`
+		Enabled          bool                    `json:"enabled"`
+		Cells            []uint64                `json:"cells"`
+		Instances        []uint64                `json:"instances"`
+		CpuUsage         float64                 `json:"cpu_usage"`
+		MaxCpu           uint                    `json:"max_cpu"`
+		AvailableMemory  uint64                  `json:"available_memory"`
+		MaxMemory        uint64                  `json:"max_memory"`
+		AvailableDisk    uint64                  `json:"available_disk"`
+		MaxDisk          uint64                  `json:"max_disk"`
+		ReadSpeed        uint64                  `json:"read_speed"`
+		WriteSpeed       uint64                  `json:"write_speed"`
+		ReceiveSpeed     uint64                  `json:"receive_speed"`
+		SendSpeed        uint64                  `json:"send_speed"`
+		FailoverCapacity *FailoverCapacityStatus `json:"failover_capacity,omitempty"`
 	}
 
 	parser := func(msg framework.Message) (data respData, err error) {
@@ -860,12 +1154,19 @@ func (module *APIModule) getComputePoolStatus(w http.ResponseWriter, r *http.Req
 		ResponseFail(ResponseDefaultError, err.Error(), w)
 		return
 	}
+	var capacityChan = make(chan ResourceResult, 1)
+	module.resource.GetFailoverCapacity(pool, capacityChan)
+	if result := <-capacityChan; result.Error != nil {
+		log.Printf("<api> check failover capacity of pool '%s' fail: %s", pool, result.Error.Error())
+	} else if result.FailoverCapacity.Enabled {
+		data.FailoverCapacity = &result.FailoverCapacity
+	}
 	ResponseOK(data, w)
 }
 
 func (module *APIModule) queryComputeCellStatus(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	pool := params.ByName("pool")
@@ -1000,7 +1301,7 @@ func (module *APIModule) queryComputeCellStatus(w http.ResponseWriter, r *http.R
 
 func (module *APIModule) getComputeCellStatus(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	pool := params.ByName("pool")
@@ -1111,7 +1412,7 @@ func (module *APIModule) getComputeCellStatus(w http.ResponseWriter, r *http.Req
 
 func (module *APIModule) handleQueryInstanceStatusInPool(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var poolName = params.ByName("pool")
@@ -1122,7 +1423,15 @@ func (module *APIModule) handleQueryInstanceStatusInPool(w http.ResponseWriter,
 		return
 	}
 
+	option, err := ParseListOption(r.URL.Query())
+	if err != nil {
+		log.Printf("<api> query instance in pool fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+
 	msg, _ := framework.CreateJsonMessage(framework.QueryInstanceStatusRequest)
+	option.MarshalToMessage(msg)
 	msg.SetString(framework.ParamKeyPool, poolName)
 
 	respChan := make(chan ProxyResult)
@@ -1143,12 +1452,12 @@ func (module *APIModule) handleQueryInstanceStatusInPool(w http.ResponseWriter,
 		ResponseFail(ResponseDefaultError, err.Error(), w)
 		return
 	}
-	ResponseOK(result, w)
+	ResponseList(result, resp, option, w)
 }
 
 func (module *APIModule) handleQueryInstanceStatusInCell(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var poolName = params.ByName("pool")
@@ -1167,7 +1476,15 @@ func (module *APIModule) handleQueryInstanceStatusInCell(w http.ResponseWriter,
 		return
 	}
 
+	option, err := ParseListOption(r.URL.Query())
+	if err != nil {
+		log.Printf("<api> query instance in cell fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+
 	msg, _ := framework.CreateJsonMessage(framework.QueryInstanceStatusRequest)
+	option.MarshalToMessage(msg)
 	msg.SetString(framework.ParamKeyPool, poolName)
 	msg.SetString(framework.ParamKeyCell, cellName)
 
@@ -1189,12 +1506,12 @@ func (module *APIModule) handleQueryInstanceStatusInCell(w http.ResponseWriter,
 		ResponseFail(ResponseDefaultError, err.Error(), w)
 		return
 	}
-	ResponseOK(result, w)
+	ResponseList(result, resp, option, w)
 }
 
 func (module *APIModule) handleCreateComputePool(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	pool := params.ByName("pool")
@@ -1205,9 +1522,14 @@ func (module *APIModule) handleCreateComputePool(w http.ResponseWriter, r *http.
 		return
 	}
 	type UserRequest struct {
-		Storage  string `json:"storage,omitempty"`
-		Network  string `json:"network,omitempty"`
-		Failover bool   `json:"failover,omitempty"`
+		Storage            string   `json:"storage,omitempty"`
+		Network            string   `json:"network,omitempty"`
+		Failover           bool     `json:"failover,omitempty"`
+		Strategy           string   `json:"strategy,omitempty"`
+		CPUOvercommit      *float64 `json:"cpu_overcommit,omitempty"`
+		MemoryOvercommit   *float64 `json:"memory_overcommit,omitempty"`
+		DiskOvercommit     *float64 `json:"disk_overcommit,omitempty"`
+		CPUHealthThreshold *float64 `json:"cpu_health_threshold,omitempty"`
 	}
 	var requestData UserRequest
 	if 0 != len(rawData) {
@@ -1223,6 +1545,18 @@ func (module *APIModule) handleCreateComputePool(w http.ResponseWriter, r *http.
 	msg.SetString(framework.ParamKeyStorage, requestData.Storage)
 	msg.SetString(framework.ParamKeyNetwork, requestData.Network)
 	msg.SetBoolean(framework.ParamKeyOption, requestData.Failover)
+	msg.SetString(framework.ParamKeyPolicy, requestData.Strategy)
+	var overcommit = PoolOvercommitUpdate{
+		CPURatio:           requestData.CPUOvercommit,
+		MemoryRatio:        requestData.MemoryOvercommit,
+		DiskRatio:          requestData.DiskOvercommit,
+		CPUHealthThreshold: requestData.CPUHealthThreshold,
+	}
+	if err := overcommit.Apply(defaultPoolOvercommit()).Verify(); err != nil {
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	msg.SetUIntArray(framework.ParamKeyLimit, overcommit.ToOptions())
 	respChan := make(chan ProxyResult)
 	if err := module.proxy.SendRequest(msg, respChan); err != nil {
 		log.Printf("<api> request create compute pool fail: %s", err.Error())
@@ -1240,7 +1574,7 @@ func (module *APIModule) handleCreateComputePool(w http.ResponseWriter, r *http.
 
 func (module *APIModule) handleDeleteComputePool(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	pool := params.ByName("pool")
@@ -1263,15 +1597,20 @@ func (module *APIModule) handleDeleteComputePool(w http.ResponseWriter, r *http.
 
 func (module *APIModule) handleModifyComputePool(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	pool := params.ByName("pool")
 	type UserRequest struct {
-		Enable   bool   `json:"enable,omitempty"`
-		Storage  string `json:"storage,omitempty"`
-		Network  string `json:"network,omitempty"`
-		Failover bool   `json:"failover,omitempty"`
+		Enable             bool     `json:"enable,omitempty"`
+		Storage            string   `json:"storage,omitempty"`
+		Network            string   `json:"network,omitempty"`
+		Failover           bool     `json:"failover,omitempty"`
+		Strategy           string   `json:"strategy,omitempty"`
+		CPUOvercommit      *float64 `json:"cpu_overcommit,omitempty"`
+		MemoryOvercommit   *float64 `json:"memory_overcommit,omitempty"`
+		DiskOvercommit     *float64 `json:"disk_overcommit,omitempty"`
+		CPUHealthThreshold *float64 `json:"cpu_health_threshold,omitempty"`
 	}
 	var requestData UserRequest
 	var decoder = json.NewDecoder(r.Body)
@@ -1285,6 +1624,18 @@ func (module *APIModule) handleModifyComputePool(w http.ResponseWriter, r *http.
 	msg.SetString(framework.ParamKeyStorage, requestData.Storage)
 	msg.SetString(framework.ParamKeyNetwork, requestData.Network)
 	msg.SetBoolean(framework.ParamKeyOption, requestData.Failover)
+	msg.SetString(framework.ParamKeyPolicy, requestData.Strategy)
+	var overcommit = PoolOvercommitUpdate{
+		CPURatio:           requestData.CPUOvercommit,
+		MemoryRatio:        requestData.MemoryOvercommit,
+		DiskRatio:          requestData.DiskOvercommit,
+		CPUHealthThreshold: requestData.CPUHealthThreshold,
+	}
+	if err := overcommit.Apply(defaultPoolOvercommit()).Verify(); err != nil {
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	msg.SetUIntArray(framework.ParamKeyLimit, overcommit.ToOptions())
 	respChan := make(chan ProxyResult)
 	if err := module.proxy.SendRequest(msg, respChan); err != nil {
 		log.Printf("<api> request modify compute pool fail: %s", err.Error())
@@ -1302,7 +1653,7 @@ func (module *APIModule) handleModifyComputePool(w http.ResponseWriter, r *http.
 
 func (module *APIModule) handleQueryStoragePool(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	msg, _ := framework.CreateJsonMessage(framework.QueryStoragePoolRequest)
@@ -1369,7 +1720,7 @@ func (module *APIModule) handleQueryStoragePool(w http.ResponseWriter, r *http.R
 
 func (module *APIModule) handleGetStoragePool(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	poolName := params.ByName("pool")
@@ -1423,7 +1774,7 @@ func (module *APIModule) handleGetStoragePool(w http.ResponseWriter, r *http.Req
 
 func (module *APIModule) handleCreateStoragePool(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	pool := params.ByName("pool")
@@ -1461,7 +1812,7 @@ func (module *APIModule) handleCreateStoragePool(w http.ResponseWriter, r *http.
 
 func (module *APIModule) handleModifyStoragePool(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	pool := params.ByName("pool")
@@ -1499,7 +1850,7 @@ func (module *APIModule) handleModifyStoragePool(w http.ResponseWriter, r *http.
 
 func (module *APIModule) handleDeleteStoragePool(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	pool := params.ByName("pool")
@@ -1522,7 +1873,7 @@ func (module *APIModule) handleDeleteStoragePool(w http.ResponseWriter, r *http.
 
 func (module *APIModule) handleAddComputeCell(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	pool := params.ByName("pool")
@@ -1547,7 +1898,7 @@ func (module *APIModule) handleAddComputeCell(w http.ResponseWriter, r *http.Req
 
 func (module *APIModule) handleRemoveComputeCell(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	pool := params.ByName("pool")
@@ -1572,7 +1923,7 @@ func (module *APIModule) handleRemoveComputeCell(w http.ResponseWriter, r *http.
 
 func (module *APIModule) handleModifyComputeCell(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	pool := params.ByName("pool")
@@ -1616,7 +1967,7 @@ func (module *APIModule) handleModifyComputeCell(w http.ResponseWriter, r *http.
 
 func (module *APIModule) handleGetComputeCell(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	pool := params.ByName("pool")
@@ -1706,7 +2057,7 @@ func (module *APIModule) handleGetComputeCell(w http.ResponseWriter, r *http.Req
 
 func (module *APIModule) handleQueryUnallocatedCell(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	msg, _ := framework.CreateJsonMessage(framework.QueryUnallocatedComputePoolCellRequest)
@@ -1733,7 +2084,7 @@ func (module *APIModule) handleQueryUnallocatedCell(w http.ResponseWriter, r *ht
 
 func (module *APIModule) handleQueryCellsInPool(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	pool := params.ByName("pool")
@@ -1762,7 +2113,7 @@ func (module *APIModule) handleQueryCellsInPool(w http.ResponseWriter, r *http.R
 
 func (module *APIModule) handleQueryAllPools(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	msg, _ := framework.CreateJsonMessage(framework.QueryComputePoolRequest)
@@ -1843,12 +2194,19 @@ func (module *APIModule) handleQueryAllPools(w http.ResponseWriter, r *http.Requ
 		ResponseFail(ResponseDefaultError, err.Error(), w)
 		return
 	}
-	ResponseOK(pools, w)
+	var access = requestAccess(r)
+	var visible = make([]Pool, 0, len(pools))
+	for _, pool := range pools {
+		if access.poolAllowed(pool.Name) {
+			visible = append(visible, pool)
+		}
+	}
+	ResponseOK(visible, w)
 }
 
 func (module *APIModule) handleGetComputePool(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	poolName := params.ByName("pool")
@@ -1873,12 +2231,17 @@ func (module *APIModule) handleGetComputePool(w http.ResponseWriter, r *http.Req
 		return
 	}
 	type Pool struct {
-		Name     string `json:"name"`
-		Enabled  bool   `json:"enabled"`
-		Cells    uint   `json:"cells"`
-		Network  string `json:"network"`
-		Storage  string `json:"storage"`
-		Failover bool   `json:"failover"`
+		Name               string  `json:"name"`
+		Enabled            bool    `json:"enabled"`
+		Cells              uint    `json:"cells"`
+		Network            string  `json:"network"`
+		Storage            string  `json:"storage"`
+		Failover           bool    `json:"failover"`
+		Strategy           string  `json:"strategy"`
+		CPUOvercommit      float64 `json:"cpu_overcommit"`
+		MemoryOvercommit   float64 `json:"memory_overcommit"`
+		DiskOvercommit     float64 `json:"disk_overcommit"`
+		CPUHealthThreshold float64 `json:"cpu_health_threshold"`
 	}
 
 	parser := func(msg framework.Message) (pool Pool, err error) {
@@ -1900,6 +2263,21 @@ func (module *APIModule) handleGetComputePool(w http.ResponseWriter, r *http.Req
 		if pool.Failover, err = msg.GetBoolean(framework.ParamKeyOption); err != nil {
 			return
 		}
+		if pool.Strategy, err = msg.GetString(framework.ParamKeyPolicy); err != nil {
+			return
+		}
+		var options []uint64
+		if options, err = msg.GetUIntArray(framework.ParamKeyLimit); err != nil {
+			return
+		}
+		var overcommit PoolOvercommit
+		if err = overcommit.FromOptions(options); err != nil {
+			return
+		}
+		pool.CPUOvercommit = overcommit.CPURatio
+		pool.MemoryOvercommit = overcommit.MemoryRatio
+		pool.DiskOvercommit = overcommit.DiskRatio
+		pool.CPUHealthThreshold = overcommit.CPUHealthThreshold
 		return
 	}
 	//success
@@ -1914,7 +2292,7 @@ func (module *APIModule) handleGetComputePool(w http.ResponseWriter, r *http.Req
 
 func (module *APIModule) handleQueryGuestConfig(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	type userRequest struct {
@@ -2051,7 +2429,7 @@ func (module *APIModule) redirectToImageServer(w http.ResponseWriter, r *http.Re
 	var err = module.verifyStreamSignature(r)
 	if err != nil {
 		log.Printf("<api> verify stream fail: %s", err.Error())
-		ResponseFail(ResponseDefaultError, "unauthorized stream", w)
+		ResponseFail(SignatureErrorCode(err), "unauthorized stream", w)
 		return
 	}
 	var respChan = make(chan ResourceResult, 1)
@@ -2094,7 +2472,7 @@ func (module *APIModule) redirectToImageServer(w http.ResponseWriter, r *http.Re
 
 func (module *APIModule) handleGetGuestConfig(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	id := params.ByName("id")
@@ -2129,7 +2507,7 @@ func (module *APIModule) handleGetGuestConfig(w http.ResponseWriter, r *http.Req
 func (module *APIModule) handleCreateGuest(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err error
 	if err = module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	type ciConfig struct {
@@ -2140,23 +2518,25 @@ func (module *APIModule) handleCreateGuest(w http.ResponseWriter, r *http.Reques
 	}
 
 	type userRequest struct {
-		Name                string           `json:"name"`
-		Owner               string           `json:"owner"`
-		Group               string           `json:"group"`
-		Pool                string           `json:"pool"`
-		Cores               uint             `json:"cores"`
-		Memory              uint             `json:"memory"`
-		Disks               []uint64         `json:"disks"`
-		Template            string           `json:"template"`
-		AutoStart           bool             `json:"auto_start,omitempty"`
-		NetworkAddress      string           `json:"network_address,omitempty"`
-		EthernetAddress     string           `json:"ethernet_address,omitempty"`
-		FromImage           string           `json:"from_image,omitempty"`
-		Port                []uint64         `json:"port,omitempty"`
-		Modules             []string         `json:"modules,omitempty"`
-		CloudInit           *ciConfig        `json:"cloud_init,omitempty"`
-		QoS                 *restInstanceQoS `json:"qos,omitempty"`
-		SecurityPolicyGroup string           `json:"security_policy_group,omitempty"`
+		Name                string             `json:"name"`
+		Owner               string             `json:"owner"`
+		Group               string             `json:"group"`
+		Pool                string             `json:"pool"`
+		Cores               uint               `json:"cores"`
+		Memory              uint               `json:"memory"`
+		Disks               []uint64           `json:"disks"`
+		Template            string             `json:"template"`
+		AutoStart           bool               `json:"auto_start,omitempty"`
+		NetworkAddress      string             `json:"network_address,omitempty"`
+		EthernetAddress     string             `json:"ethernet_address,omitempty"`
+		FromImage           string             `json:"from_image,omitempty"`
+		Port                []uint64           `json:"port,omitempty"`
+		Modules             []string           `json:"modules,omitempty"`
+		CloudInit           *ciConfig          `json:"cloud_init,omitempty"`
+		QoS                 *restInstanceQoS   `json:"qos,omitempty"`
+		SecurityPolicyGroup string             `json:"security_policy_group,omitempty"`
+		ServerGroup         string             `json:"server_group,omitempty"`
+		Selector            *PlacementSelector `json:"selector,omitempty"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -2217,6 +2597,15 @@ func (module *APIModule) handleCreateGuest(w http.ResponseWriter, r *http.Reques
 	msg.SetBoolean(framework.ParamKeyOption, request.AutoStart)
 	msg.SetString(framework.ParamKeyTemplate, request.Template)
 	msg.SetString(framework.ParamKeyPolicy, request.SecurityPolicyGroup)
+	msg.SetString(framework.ParamKeyAssign, request.ServerGroup)
+	if nil != request.Selector {
+		if err := request.Selector.Verify(); err != nil {
+			log.Printf("<api> invalid selector: %s", err.Error())
+			ResponseFail(ResponseDefaultError, err.Error(), w)
+			return
+		}
+		msg.SetStringArray(framework.ParamKeyTag, request.Selector.ToTags())
+	}
 	//optional disk image
 	if "" != request.FromImage {
 		msg.SetString(framework.ParamKeyImage, request.FromImage)
@@ -2267,6 +2656,8 @@ func (module *APIModule) handleCreateGuest(w http.ResponseWriter, r *http.Reques
 	}
 	msg.SetUIntArray(framework.ParamKeyFlag, flags)
 
+	var job = module.beginJob(r, JobTypeCreateGuest, "")
+	defer job.close(w)
 	respChan := make(chan ProxyResult)
 	if err := module.proxy.SendRequest(msg, respChan); err != nil {
 		log.Printf("<api> send create request fail: %s", err.Error())
@@ -2292,10 +2683,11 @@ func (module *APIModule) handleCreateGuest(w http.ResponseWriter, r *http.Reques
 		return
 	}
 	type userResponse struct {
-		ID string `json:"id"`
+		ID  string `json:"id"`
+		Job string `json:"job,omitempty"`
 	}
 
-	var result = userResponse{ID: id}
+	var result = userResponse{ID: id, Job: job.dispatch(id, created)}
 	if !created {
 		w.WriteHeader(http.StatusAccepted)
 	} else {
@@ -2306,7 +2698,7 @@ func (module *APIModule) handleCreateGuest(w http.ResponseWriter, r *http.Reques
 
 func (module *APIModule) handleDeleteGuest(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	id := params.ByName("id")
@@ -2346,7 +2738,7 @@ func (module *APIModule) handleDeleteGuest(w http.ResponseWriter, r *http.Reques
 
 func (module *APIModule) handleGetInstanceStatus(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	id := params.ByName("id")
@@ -2375,7 +2767,7 @@ func (module *APIModule) handleGetInstanceStatus(w http.ResponseWriter, r *http.
 
 func (module *APIModule) handleStartInstance(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	id := params.ByName("id")
@@ -2419,7 +2811,7 @@ func (module *APIModule) handleStartInstance(w http.ResponseWriter, r *http.Requ
 
 func (module *APIModule) handleStopInstance(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	id := params.ByName("id")
@@ -2464,10 +2856,22 @@ func (module *APIModule) handleStopInstance(w http.ResponseWriter, r *http.Reque
 }
 
 func (module *APIModule) searchMediaImage(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	if err := module.verifyRequestSignature(r); err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
 	var filterOwner = r.URL.Query().Get("owner")
 	var filterGroup = r.URL.Query().Get("group")
 
+	option, err := ParseListOption(r.URL.Query())
+	if err != nil {
+		log.Printf("<api> query media image fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+
 	msg, _ := framework.CreateJsonMessage(framework.QueryMediaImageRequest)
+	option.MarshalToMessage(msg)
 	msg.SetString(framework.ParamKeyUser, filterOwner)
 	msg.SetString(framework.ParamKeyGroup, filterGroup)
 
@@ -2556,12 +2960,12 @@ func (module *APIModule) searchMediaImage(w http.ResponseWriter, r *http.Request
 		ResponseFail(ResponseDefaultError, err.Error(), w)
 		return
 	}
-	ResponseOK(payload, w)
+	ResponseList(payload, resp, option, w)
 }
 
 func (module *APIModule) queryAllMediaImage(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	msg, _ := framework.CreateJsonMessage(framework.QueryMediaImageRequest)
@@ -2654,7 +3058,7 @@ func (module *APIModule) queryAllMediaImage(w http.ResponseWriter, r *http.Reque
 
 func (module *APIModule) getMediaImage(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -2707,7 +3111,7 @@ func (module *APIModule) getMediaImage(w http.ResponseWriter, r *http.Request, p
 
 func (module *APIModule) createMediaImage(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	type userRequest struct {
@@ -2761,7 +3165,7 @@ func (module *APIModule) createMediaImage(w http.ResponseWriter, r *http.Request
 
 func (module *APIModule) modifyMediaImage(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var imageID = params.ByName("id")
@@ -2806,7 +3210,7 @@ func (module *APIModule) modifyMediaImage(w http.ResponseWriter, r *http.Request
 
 func (module *APIModule) deleteMediaImage(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -2830,7 +3234,7 @@ func (module *APIModule) deleteMediaImage(w http.ResponseWriter, r *http.Request
 func (module *APIModule) syncMediaImages(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err error
 	if err = module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	type RequestPayload struct {
@@ -2874,14 +3278,22 @@ func (module *APIModule) syncMediaImages(w http.ResponseWriter, r *http.Request,
 
 func (module *APIModule) queryDiskImage(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var filterOwner = r.URL.Query().Get("owner")
 	var filterGroup = r.URL.Query().Get("group")
 	var filterTags = r.URL.Query()["tags"]
 
+	option, err := ParseListOption(r.URL.Query())
+	if err != nil {
+		log.Printf("<api> query disk image fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+
 	msg, _ := framework.CreateJsonMessage(framework.QueryDiskImageRequest)
+	option.MarshalToMessage(msg)
 
 	msg.SetString(framework.ParamKeyUser, filterOwner)
 
@@ -2976,12 +3388,12 @@ func (module *APIModule) queryDiskImage(w http.ResponseWriter, r *http.Request,
 		ResponseFail(ResponseDefaultError, err.Error(), w)
 		return
 	}
-	ResponseOK(payload, w)
+	ResponseList(payload, resp, option, w)
 }
 
 func (module *APIModule) getDiskImage(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -3046,7 +3458,7 @@ func (module *APIModule) getDiskImage(w http.ResponseWriter, r *http.Request, pa
 
 func (module *APIModule) createDiskImage(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	type userRequest struct {
@@ -3073,6 +3485,11 @@ func (module *APIModule) createDiskImage(w http.ResponseWriter, r *http.Request,
 	msg.SetString(framework.ParamKeyDescription, request.Description)
 	msg.SetStringArray(framework.ParamKeyTag, request.Tags)
 
+	var job *apiJob
+	if "" != request.Guest {
+		job = module.beginJob(r, JobTypeCreateDiskImage, "")
+		defer job.close(w)
+	}
 	var respChan = make(chan ProxyResult)
 	if err := module.proxy.SendRequest(msg, respChan); err != nil {
 		log.Printf("<api> send create disk image request fail: %s", err.Error())
@@ -3094,10 +3511,12 @@ func (module *APIModule) createDiskImage(w http.ResponseWriter, r *http.Request,
 	}
 
 	type userResponse struct {
-		ID string `json:"id"`
+		ID  string `json:"id"`
+		Job string `json:"job,omitempty"`
 	}
 	var data = userResponse{ID: imageID}
 	if "" != request.Guest {
+		data.Job = job.dispatch(imageID, false)
 		w.WriteHeader(http.StatusAccepted)
 	}
 	ResponseOK(data, w)
@@ -3105,7 +3524,7 @@ func (module *APIModule) createDiskImage(w http.ResponseWriter, r *http.Request,
 
 func (module *APIModule) modifyDiskImage(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var imageID = params.ByName("id")
@@ -3150,7 +3569,7 @@ func (module *APIModule) modifyDiskImage(w http.ResponseWriter, r *http.Request,
 
 func (module *APIModule) deleteDiskImage(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -3174,7 +3593,7 @@ func (module *APIModule) deleteDiskImage(w http.ResponseWriter, r *http.Request,
 func (module *APIModule) syncDiskImages(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err error
 	if err = module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	type RequestPayload struct {
@@ -3218,7 +3637,7 @@ func (module *APIModule) syncDiskImages(w http.ResponseWriter, r *http.Request,
 
 func (module *APIModule) handleModifyGuestName(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -3254,7 +3673,7 @@ func (module *APIModule) handleModifyGuestName(w http.ResponseWriter, r *http.Re
 
 func (module *APIModule) handleModifyGuestCores(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -3292,7 +3711,7 @@ func (module *APIModule) handleModifyGuestCores(w http.ResponseWriter, r *http.R
 
 func (module *APIModule) handleModifyGuestMemory(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -3330,7 +3749,7 @@ func (module *APIModule) handleModifyGuestMemory(w http.ResponseWriter, r *http.
 
 func (module *APIModule) handleModifyAutoStart(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -3366,7 +3785,7 @@ func (module *APIModule) handleModifyAutoStart(w http.ResponseWriter, r *http.Re
 
 func (module *APIModule) handleModifyGuestPriority(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -3415,7 +3834,7 @@ func (module *APIModule) handleModifyGuestPriority(w http.ResponseWriter, r *htt
 
 func (module *APIModule) handleModifyDiskThreshold(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -3454,7 +3873,7 @@ func (module *APIModule) handleModifyDiskThreshold(w http.ResponseWriter, r *htt
 
 func (module *APIModule) handleModifyNetworkThreshold(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -3491,7 +3910,7 @@ func (module *APIModule) handleModifyNetworkThreshold(w http.ResponseWriter, r *
 
 func (module *APIModule) handleResetGuestSystem(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var guestID = params.ByName("id")
@@ -3510,6 +3929,8 @@ func (module *APIModule) handleResetGuestSystem(w http.ResponseWriter, r *http.R
 	msg.SetString(framework.ParamKeyGuest, guestID)
 	msg.SetString(framework.ParamKeyImage, request.FromImage)
 
+	var job = module.beginJob(r, JobTypeResetSystem, guestID)
+	defer job.close(w)
 	var respChan = make(chan ProxyResult)
 	if err := module.proxy.SendRequest(msg, respChan); err != nil {
 		log.Printf("<api> send reset system request fail: %s", err.Error())
@@ -3522,12 +3943,15 @@ func (module *APIModule) handleResetGuestSystem(w http.ResponseWriter, r *http.R
 		ResponseFail(ResponseDefaultError, errMsg, w)
 		return
 	}
+	if jobID := job.dispatch(guestID, false); "" != jobID {
+		w.Header().Set(HeaderNameJob, jobID)
+	}
 	ResponseOK("", w)
 }
 
 func (module *APIModule) handleModifyGuestPassword(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -3572,7 +3996,7 @@ func (module *APIModule) handleModifyGuestPassword(w http.ResponseWriter, r *htt
 
 func (module *APIModule) handleGetGuestPassword(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -3613,7 +4037,7 @@ func (module *APIModule) handleGetGuestPassword(w http.ResponseWriter, r *http.R
 
 func (module *APIModule) handleResizeDisk(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -3642,6 +4066,8 @@ func (module *APIModule) handleResizeDisk(w http.ResponseWriter, r *http.Request
 	msg.SetUInt(framework.ParamKeySize, request.Size)
 	msg.SetBoolean(framework.ParamKeyImmediate, request.Immediate)
 
+	var job = module.beginJob(r, JobTypeResizeDisk, id)
+	defer job.close(w)
 	var respChan = make(chan ProxyResult)
 	if err := module.proxy.SendRequest(msg, respChan); err != nil {
 		log.Printf("<api> send resize disk request fail: %s", err.Error())
@@ -3654,12 +4080,15 @@ func (module *APIModule) handleResizeDisk(w http.ResponseWriter, r *http.Request
 		ResponseFail(ResponseDefaultError, errMsg, w)
 		return
 	}
+	if jobID := job.dispatch(id, true); "" != jobID {
+		w.Header().Set(HeaderNameJob, jobID)
+	}
 	ResponseOK("", w)
 }
 
 func (module *APIModule) handleShrinkDisk(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -3703,7 +4132,7 @@ func (module *APIModule) handleShrinkDisk(w http.ResponseWriter, r *http.Request
 
 func (module *APIModule) handleInsertMedia(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -3743,7 +4172,7 @@ func (module *APIModule) handleInsertMedia(w http.ResponseWriter, r *http.Reques
 
 func (module *APIModule) handleEjectMedia(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -3768,7 +4197,7 @@ func (module *APIModule) handleEjectMedia(w http.ResponseWriter, r *http.Request
 
 func (module *APIModule) handleQueryInstanceSnapshots(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var instanceID = params.ByName("id")
@@ -3834,7 +4263,7 @@ func (module *APIModule) handleQueryInstanceSnapshots(w http.ResponseWriter, r *
 
 func (module *APIModule) handleCreateInstanceSnapshot(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var instanceID = params.ByName("id")
@@ -3874,7 +4303,7 @@ func (module *APIModule) handleCreateInstanceSnapshot(w http.ResponseWriter, r *
 
 func (module *APIModule) handleDeleteInstanceSnapshot(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var instanceID = params.ByName("id")
@@ -3901,7 +4330,7 @@ func (module *APIModule) handleDeleteInstanceSnapshot(w http.ResponseWriter, r *
 
 func (module *APIModule) handleRestoreInstanceSnapshot(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var instanceID = params.ByName("id")
@@ -3938,7 +4367,7 @@ func (module *APIModule) handleRestoreInstanceSnapshot(w http.ResponseWriter, r
 
 func (module *APIModule) handleGetInstanceSnapshot(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var instanceID = params.ByName("id")
@@ -3983,10 +4412,17 @@ func (module *APIModule) handleGetInstanceSnapshot(w http.ResponseWriter, r *htt
 
 func (module *APIModule) handleQueryMigrations(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	option, err := ParseListOption(r.URL.Query())
+	if err != nil {
+		log.Printf("<api> query migration fail: %s", err.Error())
 		ResponseFail(ResponseDefaultError, err.Error(), w)
 		return
 	}
 	msg, _ := framework.CreateJsonMessage(framework.QueryMigrationRequest)
+	option.MarshalToMessage(msg)
 	var respChan = make(chan ProxyResult, 1)
 	if err := module.proxy.SendRequest(msg, respChan); err != nil {
 		log.Printf("<api> send query migration request fail: %s", err.Error())
@@ -4007,7 +4443,6 @@ func (module *APIModule) handleQueryMigrations(w http.ResponseWriter, r *http.Re
 	}
 	var id, errMessage []string
 	var finished, progress []uint64
-	var err error
 
 	var respPayload = make([]Migration, 0)
 	if id, err = resp.GetStringArray(framework.ParamKeyMigration); err != nil {
@@ -4060,12 +4495,12 @@ func (module *APIModule) handleQueryMigrations(w http.ResponseWriter, r *http.Re
 		m.Error = errMessage[i]
 		respPayload = append(respPayload, m)
 	}
-	ResponseOK(respPayload, w)
+	ResponseList(respPayload, resp, option, w)
 }
 
 func (module *APIModule) handleGetMigration(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var migrationID = params.ByName("id")
@@ -4097,15 +4532,16 @@ func (module *APIModule) handleGetMigration(w http.ResponseWriter, r *http.Reque
 
 func (module *APIModule) handleCreateMigration(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	type UserRequest struct {
-		SourcePool string   `json:"source_pool"`
-		SourceCell string   `json:"source_cell"`
-		TargetPool string   `json:"target_pool,omitempty"`
-		TargetCell string   `json:"target_cell,omitempty"`
-		Instances  []string `json:"instances,omitempty"`
+		SourcePool string             `json:"source_pool"`
+		SourceCell string             `json:"source_cell"`
+		TargetPool string             `json:"target_pool,omitempty"`
+		TargetCell string             `json:"target_cell,omitempty"`
+		Instances  []string           `json:"instances,omitempty"`
+		Selector   *PlacementSelector `json:"selector,omitempty"`
 	}
 	var err error
 	var decoder = json.NewDecoder(r.Body)
@@ -4126,7 +4562,17 @@ func (module *APIModule) handleCreateMigration(w http.ResponseWriter, r *http.Re
 	msg.SetStringArray(framework.ParamKeyPool, []string{requestData.SourcePool})
 	msg.SetStringArray(framework.ParamKeyCell, []string{requestData.SourceCell, requestData.TargetCell})
 	msg.SetStringArray(framework.ParamKeyInstance, requestData.Instances)
+	if nil != requestData.Selector {
+		if err = requestData.Selector.Verify(); err != nil {
+			log.Printf("<api> verify migration request fail: %s", err.Error())
+			ResponseFail(ResponseDefaultError, err.Error(), w)
+			return
+		}
+		msg.SetStringArray(framework.ParamKeyTag, requestData.Selector.ToTags())
+	}
 
+	var job = module.beginJob(r, JobTypeMigration, "")
+	defer job.close(w)
 	var respChan = make(chan ProxyResult, 1)
 	if err := module.proxy.SendRequest(msg, respChan); err != nil {
 		log.Printf("<api> send create migration request fail: %s", err.Error())
@@ -4140,16 +4586,17 @@ func (module *APIModule) handleCreateMigration(w http.ResponseWriter, r *http.Re
 		return
 	}
 	type UserResponse struct {
-		ID string `json:"id"`
+		ID  string `json:"id"`
+		Job string `json:"job,omitempty"`
 	}
 	migrationID, _ := resp.GetString(framework.ParamKeyMigration)
-	var respPayload = UserResponse{migrationID}
+	var respPayload = UserResponse{migrationID, job.dispatch(migrationID, false)}
 	ResponseOK(respPayload, w)
 }
 
 func (module *APIModule) handleQueryAddressPool(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	msg, _ := framework.CreateJsonMessage(framework.QueryAddressPoolRequest)
@@ -4244,7 +4691,7 @@ func (module *APIModule) handleQueryAddressPool(w http.ResponseWriter, r *http.R
 
 func (module *APIModule) handleGetAddressPool(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var poolName = params.ByName("pool")
@@ -4336,7 +4783,7 @@ func (module *APIModule) handleGetAddressPool(w http.ResponseWriter, r *http.Req
 
 func (module *APIModule) handleCreateAddressPool(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var poolName = params.ByName("pool")
@@ -4372,7 +4819,7 @@ func (module *APIModule) handleCreateAddressPool(w http.ResponseWriter, r *http.
 
 func (module *APIModule) handleModifyAddressPool(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var poolName = params.ByName("pool")
@@ -4408,7 +4855,7 @@ func (module *APIModule) handleModifyAddressPool(w http.ResponseWriter, r *http.
 
 func (module *APIModule) handleDeleteAddressPool(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var poolName = params.ByName("pool")
@@ -4431,12 +4878,19 @@ func (module *APIModule) handleDeleteAddressPool(w http.ResponseWriter, r *http.
 
 func (module *APIModule) handleQueryAddressRange(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var poolName = params.ByName("pool")
 	var rangeType = params.ByName("type")
+	option, err := ParseListOption(r.URL.Query())
+	if err != nil {
+		log.Printf("<api> query address range fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
 	msg, _ := framework.CreateJsonMessage(framework.QueryAddressRangeRequest)
+	option.MarshalToMessage(msg)
 	msg.SetString(framework.ParamKeyAddress, poolName)
 	msg.SetString(framework.ParamKeyType, rangeType)
 	var respChan = make(chan ProxyResult, 1)
@@ -4489,12 +4943,12 @@ func (module *APIModule) handleQueryAddressRange(w http.ResponseWriter, r *http.
 		ResponseFail(ResponseDefaultError, err.Error(), w)
 		return
 	}
-	ResponseOK(payload, w)
+	ResponseList(payload, resp, option, w)
 }
 
 func (module *APIModule) handleGetAddressRange(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var poolName = params.ByName("pool")
@@ -4565,7 +5019,7 @@ func (module *APIModule) handleGetAddressRange(w http.ResponseWriter, r *http.Re
 
 func (module *APIModule) handleAddAddressRange(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var poolName = params.ByName("pool")
@@ -4608,7 +5062,7 @@ func (module *APIModule) handleAddAddressRange(w http.ResponseWriter, r *http.Re
 
 func (module *APIModule) handleRemoveAddressRange(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var poolName = params.ByName("pool")
@@ -4637,7 +5091,7 @@ func (module *APIModule) handleRemoveAddressRange(w http.ResponseWriter, r *http
 
 func (module *APIModule) handleGetBatchCreateGuest(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var batchID = params.ByName("id")
@@ -4740,7 +5194,7 @@ func (module *APIModule) handleGetBatchCreateGuest(w http.ResponseWriter, r *htt
 func (module *APIModule) handleStartBatchCreateGuest(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err error
 	if err = module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	type ciConfig struct {
@@ -4751,24 +5205,26 @@ func (module *APIModule) handleStartBatchCreateGuest(w http.ResponseWriter, r *h
 	}
 
 	type userRequest struct {
-		NameRule        string           `json:"name_rule"`
-		NamePrefix      string           `json:"name_prefix"`
-		Count           uint             `json:"count"`
-		Owner           string           `json:"owner"`
-		Group           string           `json:"group"`
-		Pool            string           `json:"pool"`
-		Cores           uint             `json:"cores"`
-		Memory          uint             `json:"memory"`
-		Disks           []uint64         `json:"disks"`
-		Template        string           `json:"template"`
-		AutoStart       bool             `json:"auto_start,omitempty"`
-		NetworkAddress  string           `json:"network_address,omitempty"`
-		EthernetAddress string           `json:"ethernet_address,omitempty"`
-		FromImage       string           `json:"from_image,omitempty"`
-		Port            []uint64         `json:"port,omitempty"`
-		Modules         []string         `json:"modules,omitempty"`
-		CloudInit       *ciConfig        `json:"cloud_init,omitempty"`
-		QoS             *restInstanceQoS `json:"qos,omitempty"`
+		NameRule        string             `json:"name_rule"`
+		NamePrefix      string             `json:"name_prefix"`
+		Count           uint               `json:"count"`
+		Owner           string             `json:"owner"`
+		Group           string             `json:"group"`
+		Pool            string             `json:"pool"`
+		Cores           uint               `json:"cores"`
+		Memory          uint               `json:"memory"`
+		Disks           []uint64           `json:"disks"`
+		Template        string             `json:"template"`
+		AutoStart       bool               `json:"auto_start,omitempty"`
+		NetworkAddress  string             `json:"network_address,omitempty"`
+		EthernetAddress string             `json:"ethernet_address,omitempty"`
+		FromImage       string             `json:"from_image,omitempty"`
+		Port            []uint64           `json:"port,omitempty"`
+		Modules         []string           `json:"modules,omitempty"`
+		CloudInit       *ciConfig          `json:"cloud_init,omitempty"`
+		QoS             *restInstanceQoS   `json:"qos,omitempty"`
+		ServerGroup     string             `json:"server_group,omitempty"`
+		Selector        *PlacementSelector `json:"selector,omitempty"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -4840,6 +5296,15 @@ func (module *APIModule) handleStartBatchCreateGuest(w http.ResponseWriter, r *h
 	msg.SetString(framework.ParamKeyUser, request.Owner)
 	msg.SetString(framework.ParamKeyGroup, request.Group)
 	msg.SetString(framework.ParamKeyPool, request.Pool)
+	msg.SetString(framework.ParamKeyAssign, request.ServerGroup)
+	if nil != request.Selector {
+		if err := request.Selector.Verify(); err != nil {
+			log.Printf("<api> invalid selector: %s", err.Error())
+			ResponseFail(ResponseDefaultError, err.Error(), w)
+			return
+		}
+		msg.SetStringArray(framework.ParamKeyTag, request.Selector.ToTags())
+	}
 
 	msg.SetUInt(framework.ParamKeyCount, request.Count)
 	msg.SetUInt(framework.ParamKeyCore, request.Cores)
@@ -4898,6 +5363,8 @@ func (module *APIModule) handleStartBatchCreateGuest(w http.ResponseWriter, r *h
 
 	msg.SetUIntArray(framework.ParamKeyFlag, flags)
 
+	var job = module.beginJob(r, JobTypeBatchCreateGuest, "")
+	defer job.close(w)
 	respChan := make(chan ProxyResult)
 	if err := module.proxy.SendRequest(msg, respChan); err != nil {
 		log.Printf("<api> send batch create request fail: %s", err.Error())
@@ -4917,17 +5384,18 @@ func (module *APIModule) handleStartBatchCreateGuest(w http.ResponseWriter, r *h
 		return
 	}
 	type userResponse struct {
-		ID string `json:"id"`
+		ID  string `json:"id"`
+		Job string `json:"job,omitempty"`
 	}
 
-	var result = userResponse{ID: batchID}
+	var result = userResponse{ID: batchID, Job: job.dispatch(batchID, false)}
 	w.WriteHeader(http.StatusAccepted)
 	ResponseOK(result, w)
 }
 
 func (module *APIModule) handleGetBatchDeleteGuest(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var batchID = params.ByName("id")
@@ -5021,7 +5489,7 @@ func (module *APIModule) handleGetBatchDeleteGuest(w http.ResponseWriter, r *htt
 
 func (module *APIModule) handleStartBatchDeleteGuest(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	type UserRequest struct {
@@ -5039,6 +5507,8 @@ func (module *APIModule) handleStartBatchDeleteGuest(w http.ResponseWriter, r *h
 	msg, _ := framework.CreateJsonMessage(framework.StartBatchDeleteGuestRequest)
 	msg.SetStringArray(framework.ParamKeyGuest, requestData.Guest)
 
+	var job = module.beginJob(r, JobTypeBatchDeleteGuest, "")
+	defer job.close(w)
 	var respChan = make(chan ProxyResult, 1)
 	if err := module.proxy.SendRequest(msg, respChan); err != nil {
 		log.Printf("<api> send start batch delete request fail: %s", err.Error())
@@ -5058,17 +5528,18 @@ func (module *APIModule) handleStartBatchDeleteGuest(w http.ResponseWriter, r *h
 		return
 	}
 	type userResponse struct {
-		ID string `json:"id"`
+		ID  string `json:"id"`
+		Job string `json:"job,omitempty"`
 	}
 
-	var result = userResponse{ID: batchID}
+	var result = userResponse{ID: batchID, Job: job.dispatch(batchID, false)}
 	w.WriteHeader(http.StatusAccepted)
 	ResponseOK(result, w)
 }
 
 func (module *APIModule) handleGetBatchStopGuest(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var batchID = params.ByName("id")
@@ -5162,7 +5633,7 @@ func (module *APIModule) handleGetBatchStopGuest(w http.ResponseWriter, r *http.
 
 func (module *APIModule) handleStartBatchStopGuest(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	if err := module.verifyRequestSignature(r); err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	type UserRequest struct {
@@ -5195,6 +5666,8 @@ func (module *APIModule) handleStartBatchStopGuest(w http.ResponseWriter, r *htt
 	msg.SetStringArray(framework.ParamKeyGuest, requestData.Guest)
 	msg.SetUIntArray(framework.ParamKeyOption, options)
 
+	var job = module.beginJob(r, JobTypeBatchStopGuest, "")
+	defer job.close(w)
 	var respChan = make(chan ProxyResult, 1)
 	if err := module.proxy.SendRequest(msg, respChan); err != nil {
 		log.Printf("<api> send start batch stop request fail: %s", err.Error())
@@ -5214,10 +5687,11 @@ func (module *APIModule) handleStartBatchStopGuest(w http.ResponseWriter, r *htt
 		return
 	}
 	type userResponse struct {
-		ID string `json:"id"`
+		ID  string `json:"id"`
+		Job string `json:"job,omitempty"`
 	}
 
-	var result = userResponse{ID: batchID}
+	var result = userResponse{ID: batchID, Job: job.dispatch(batchID, false)}
 	w.WriteHeader(http.StatusAccepted)
 	ResponseOK(result, w)
 }
@@ -5225,7 +5699,7 @@ func (module *APIModule) handleStartBatchStopGuest(w http.ResponseWriter, r *htt
 func (module *APIModule) querySystemTemplates(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 
@@ -5299,7 +5773,7 @@ func (module *APIModule) querySystemTemplates(w http.ResponseWriter, r *http.Req
 func (module *APIModule) getSystemTemplate(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -5384,7 +5858,7 @@ func (module *APIModule) getSystemTemplate(w http.ResponseWriter, r *http.Reques
 func (module *APIModule) createSystemTemplate(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var decoder = json.NewDecoder(r.Body)
@@ -5473,7 +5947,7 @@ func (module *APIModule) createSystemTemplate(w http.ResponseWriter, r *http.Req
 func (module *APIModule) modifySystemTemplate(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var templateID = params.ByName("id")
@@ -5553,7 +6027,7 @@ func (module *APIModule) modifySystemTemplate(w http.ResponseWriter, r *http.Req
 func (module *APIModule) deleteSystemTemplate(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var id = params.ByName("id")
@@ -5577,7 +6051,7 @@ func (module *APIModule) deleteSystemTemplate(w http.ResponseWriter, r *http.Req
 func (module *APIModule) resetMonitorSecret(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var guestID = params.ByName("id")
@@ -5603,7 +6077,7 @@ func (module *APIModule) resetMonitorSecret(w http.ResponseWriter, r *http.Reque
 func (module *APIModule) queryCellStorages(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var cellName = params.ByName("cell")
@@ -5672,7 +6146,7 @@ func (module *APIModule) queryCellStorages(w http.ResponseWriter, r *http.Reques
 func (module *APIModule) changeCellStorage(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var cellName = params.ByName("cell")
@@ -5715,7 +6189,7 @@ func (module *APIModule) changeCellStorage(w http.ResponseWriter, r *http.Reques
 func (module *APIModule) querySecurityPolicyGroups(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var enabledOnly = r.URL.Query().Get("enabled_only")
@@ -5723,7 +6197,15 @@ func (module *APIModule) querySecurityPolicyGroups(w http.ResponseWriter, r *htt
 	var owner = r.URL.Query().Get("owner")
 	var group = r.URL.Query().Get("group")
 
+	option, err := ParseListOption(r.URL.Query())
+	if err != nil {
+		log.Printf("<api> query security policy groups fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+
 	msg, _ := framework.CreateJsonMessage(framework.QueryPolicyGroupRequest)
+	option.MarshalToMessage(msg)
 
 	msg.SetString(framework.ParamKeyUser, owner)
 	msg.SetString(framework.ParamKeyGroup, group)
@@ -5757,14 +6239,14 @@ func (module *APIModule) querySecurityPolicyGroups(w http.ResponseWriter, r *htt
 		ResponseFail(ResponseDefaultError, err.Error(), w)
 		return
 	}
-	ResponseOK(payload, w)
+	ResponseList(payload, resp, option, w)
 }
 
 func (module *APIModule) searchGuests(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	err := module.verifyRequestSignature(r)
 	defer func() {
 		if nil != err {
-			if reportErr := ResponseFail(ResponseDefaultError, err.Error(), w); reportErr != nil {
+			if reportErr := ResponseFail(SignatureErrorCode(err), err.Error(), w); reportErr != nil {
 				log.Printf("<api> warning: fail to report error: %s", reportErr.Error())
 			}
 		}
@@ -5860,7 +6342,7 @@ func (module *APIModule) searchGuests(w http.ResponseWriter, r *http.Request, pa
 func (module *APIModule) getSecurityPolicyGroup(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var policyID = params.ByName("id")
@@ -5890,7 +6372,7 @@ func (module *APIModule) getSecurityPolicyGroup(w http.ResponseWriter, r *http.R
 func (module *APIModule) createSecurityPolicyGroup(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var decoder = json.NewDecoder(r.Body)
@@ -5931,7 +6413,7 @@ func (module *APIModule) createSecurityPolicyGroup(w http.ResponseWriter, r *htt
 func (module *APIModule) modifySecurityPolicyGroup(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var policyID = params.ByName("id")
@@ -5964,7 +6446,7 @@ func (module *APIModule) modifySecurityPolicyGroup(w http.ResponseWriter, r *htt
 func (module *APIModule) deleteSecurityPolicyGroup(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var policyID = params.ByName("id")
@@ -5989,7 +6471,7 @@ func (module *APIModule) deleteSecurityPolicyGroup(w http.ResponseWriter, r *htt
 func (module *APIModule) querySecurityPolicyRules(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var policyID = params.ByName("id")
@@ -6021,7 +6503,7 @@ func (module *APIModule) querySecurityPolicyRules(w http.ResponseWriter, r *http
 func (module *APIModule) addSecurityPolicyRule(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var policyID = params.ByName("id")
@@ -6075,7 +6557,7 @@ func (module *APIModule) addSecurityPolicyRule(w http.ResponseWriter, r *http.Re
 func (module *APIModule) modifySecurityPolicyRule(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var policyID = params.ByName("id")
@@ -6138,7 +6620,7 @@ func (module *APIModule) modifySecurityPolicyRule(w http.ResponseWriter, r *http
 func (module *APIModule) removeSecurityPolicyRule(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var policyID = params.ByName("id")
@@ -6175,7 +6657,7 @@ func (module *APIModule) moveSecurityPolicyRule(w http.ResponseWriter, r *http.R
 	)
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var policyID = params.ByName("id")
@@ -6223,7 +6705,7 @@ func (module *APIModule) moveSecurityPolicyRule(w http.ResponseWriter, r *http.R
 func (module *APIModule) getGuestSecurityPolicy(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var instanceID = params.ByName("id")
@@ -6255,7 +6737,7 @@ func (module *APIModule) getGuestSecurityPolicy(w http.ResponseWriter, r *http.R
 func (module *APIModule) changeGuestSecurityAction(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var instanceID = params.ByName("id")
@@ -6291,7 +6773,7 @@ func (module *APIModule) changeGuestSecurityAction(w http.ResponseWriter, r *htt
 func (module *APIModule) addGuestSecurityRule(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var instanceID = params.ByName("id")
@@ -6349,7 +6831,7 @@ func (module *APIModule) addGuestSecurityRule(w http.ResponseWriter, r *http.Req
 func (module *APIModule) modifyGuestSecurityRule(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var instanceID = params.ByName("id")
@@ -6415,7 +6897,7 @@ func (module *APIModule) modifyGuestSecurityRule(w http.ResponseWriter, r *http.
 func (module *APIModule) removeGuestSecurityRule(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var instanceID = params.ByName("id")
@@ -6453,7 +6935,7 @@ func (module *APIModule) moveGuestSecurityRule(w http.ResponseWriter, r *http.Re
 	)
 	var err = module.verifyRequestSignature(r)
 	if err != nil {
-		ResponseFail(ResponseDefaultError, err.Error(), w)
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
 		return
 	}
 	var instanceID = params.ByName("id")
@@ -6498,38 +6980,1513 @@ func (module *APIModule) moveGuestSecurityRule(w http.ResponseWriter, r *http.Re
 	ResponseOK("", w)
 }
 
-func IsResponseSuccess(respChan chan ProxyResult) (resp framework.Message, errMessage string, success bool) {
-	result, ok := <-respChan
-	if !ok {
-		return resp, "channel closed", false
+func (module *APIModule) queryServerGroups(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
 	}
+	var condition = ServerGroupQueryCondition{
+		User:  r.URL.Query().Get("owner"),
+		Group: r.URL.Query().Get("group"),
+	}
+	var respChan = make(chan ResourceResult, 1)
+	module.resource.QueryServerGroups(condition, respChan)
+	var result = <-respChan
 	if result.Error != nil {
-		return resp, result.Error.Error(), false
+		err = result.Error
+		log.Printf("<api> query server groups fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
 	}
-	if !result.Response.IsSuccess() {
-		return resp, result.Response.GetError(), false
+	var access = requestAccess(r)
+	var payload = make([]ServerGroupStatus, 0)
+	for _, group := range result.ServerGroupList {
+		if access.ownerAllowed(group.User, group.Group) {
+			payload = append(payload, group)
+		}
 	}
-	return result.Response, "", true
+	ResponseOK(payload, w)
 }
 
-type Response struct {
-	ErrorCode int         `json:"error_code"`
-	Message   string      `json:"message"`
-	Data      interface{} `json:"data"`
+func (module *APIModule) getServerGroup(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var groupID = params.ByName("id")
+	var respChan = make(chan ResourceResult, 1)
+	module.resource.GetServerGroup(groupID, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		err = result.Error
+		log.Printf("<api> get server group fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK(result.ServerGroup, w)
 }
 
-const (
-	ResponseDefaultError = 500
-)
+func (module *APIModule) createServerGroup(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var decoder = json.NewDecoder(r.Body)
+	var request ServerGroupConfig
+	if err = decoder.Decode(&request); err != nil {
+		log.Printf("<api> parse create server group request fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var respChan = make(chan ResourceResult, 1)
+	module.resource.CreateServerGroup(request, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		err = result.Error
+		log.Printf("<api> create server group fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	type Response struct {
+		ID string `json:"id"`
+	}
+	ResponseOK(Response{ID: result.ServerGroup.ID}, w)
+}
 
-func ResponseFail(code int, message string, writer io.Writer) error {
-	encoder := json.NewEncoder(writer)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(Response{code, message, struct{}{}})
+func (module *APIModule) modifyServerGroup(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var groupID = params.ByName("id")
+	var decoder = json.NewDecoder(r.Body)
+	var request ServerGroupConfig
+	if err = decoder.Decode(&request); err != nil {
+		log.Printf("<api> parse modify server group request fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var respChan = make(chan error, 1)
+	module.resource.ModifyServerGroup(groupID, request, respChan)
+	if err = <-respChan; err != nil {
+		log.Printf("<api> modify server group fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK("", w)
 }
 
-func ResponseOK(data interface{}, writer io.Writer) error {
-	encoder := json.NewEncoder(writer)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(Response{0, "", data})
+func (module *APIModule) deleteServerGroup(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var groupID = params.ByName("id")
+	var respChan = make(chan error, 1)
+	module.resource.DeleteServerGroup(groupID, respChan)
+	if err = <-respChan; err != nil {
+		log.Printf("<api> delete server group fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK("", w)
+}
+
+func (module *APIModule) queryQuotas(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var respChan = make(chan ResourceResult, 1)
+	module.resource.QueryQuotas(respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		err = result.Error
+		log.Printf("<api> query quotas fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var payload = make([]QuotaStatus, 0)
+	payload = append(payload, result.QuotaList...)
+	ResponseOK(payload, w)
+}
+
+func (module *APIModule) getQuota(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var scope = params.ByName("scope")
+	var name = params.ByName("name")
+	var respChan = make(chan ResourceResult, 1)
+	module.resource.GetQuota(scope, name, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		err = result.Error
+		log.Printf("<api> get quota fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK(result.Quota, w)
+}
+
+func (module *APIModule) createQuota(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	type userRequest struct {
+		Scope string        `json:"scope"`
+		Name  string        `json:"name"`
+		Limit ResourceQuota `json:"limit"`
+	}
+	var request userRequest
+	var decoder = json.NewDecoder(r.Body)
+	if err = decoder.Decode(&request); err != nil {
+		log.Printf("<api> parse create quota request fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var respChan = make(chan error, 1)
+	module.resource.CreateQuota(request.Scope, request.Name, request.Limit, respChan)
+	if err = <-respChan; err != nil {
+		log.Printf("<api> create quota fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK("", w)
+}
+
+func (module *APIModule) modifyQuota(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var scope = params.ByName("scope")
+	var name = params.ByName("name")
+	var limit ResourceQuota
+	var decoder = json.NewDecoder(r.Body)
+	if err = decoder.Decode(&limit); err != nil {
+		log.Printf("<api> parse modify quota request fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var respChan = make(chan error, 1)
+	module.resource.ModifyQuota(scope, name, limit, respChan)
+	if err = <-respChan; err != nil {
+		log.Printf("<api> modify quota fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK("", w)
+}
+
+func (module *APIModule) deleteQuota(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var scope = params.ByName("scope")
+	var name = params.ByName("name")
+	var respChan = make(chan error, 1)
+	module.resource.DeleteQuota(scope, name, respChan)
+	if err = <-respChan; err != nil {
+		log.Printf("<api> delete quota fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK("", w)
+}
+
+func (module *APIModule) getCellMaintenance(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var pool = params.ByName("pool")
+	var cell = params.ByName("cell")
+	var respChan = make(chan ResourceResult, 1)
+	module.resource.GetCellMaintenance(pool, cell, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		err = result.Error
+		log.Printf("<api> get maintenance of cell '%s' fail: %s", cell, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK(result.Maintenance, w)
+}
+
+func (module *APIModule) startCellMaintenance(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var pool = params.ByName("pool")
+	var cell = params.ByName("cell")
+	type userRequest struct {
+		StopRunning bool `json:"stop_running,omitempty"`
+	}
+	var request userRequest
+	var decoder = json.NewDecoder(r.Body)
+	if err = decoder.Decode(&request); err != nil && io.EOF != err {
+		log.Printf("<api> parse start maintenance request fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var respChan = make(chan error, 1)
+	module.resource.StartCellMaintenance(pool, cell, request.StopRunning, respChan)
+	if err = <-respChan; err != nil {
+		log.Printf("<api> start maintenance of cell '%s' fail: %s", cell, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK("", w)
+}
+
+func (module *APIModule) cancelCellMaintenance(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var pool = params.ByName("pool")
+	var cell = params.ByName("cell")
+	var statusChan = make(chan ResourceResult, 1)
+	module.resource.GetCellMaintenance(pool, cell, statusChan)
+	var result = <-statusChan
+	if result.Error != nil {
+		err = result.Error
+		log.Printf("<api> cancel maintenance of cell '%s' fail: %s", cell, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var respChan = make(chan error, 1)
+	module.resource.CancelCellMaintenance(pool, cell, respChan)
+	if err = <-respChan; err != nil {
+		log.Printf("<api> cancel maintenance of cell '%s' fail: %s", cell, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	//start guests stopped by drain but not moved yet
+	for _, instanceID := range result.Maintenance.Restart {
+		if err = sendInstanceCommand(module.proxy, instanceID, false); err != nil {
+			log.Printf("<api> warning: start instance '%s' after maintenance canceled fail: %s", instanceID, err.Error())
+		} else {
+			log.Printf("<api> instance '%s' started again after maintenance canceled", instanceID)
+		}
+	}
+	ResponseOK("", w)
+}
+
+func (module *APIModule) getCellLabels(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var pool = params.ByName("pool")
+	var cell = params.ByName("cell")
+	var respChan = make(chan ResourceResult, 1)
+	module.resource.GetCellLabels(pool, cell, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		err = result.Error
+		log.Printf("<api> get labels of cell '%s' fail: %s", cell, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK(result.Labels, w)
+}
+
+func (module *APIModule) modifyCellLabels(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var pool = params.ByName("pool")
+	var cell = params.ByName("cell")
+	var labels = map[string]string{}
+	var decoder = json.NewDecoder(r.Body)
+	if err = decoder.Decode(&labels); err != nil {
+		log.Printf("<api> parse modify labels request fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var respChan = make(chan error, 1)
+	module.resource.ModifyCellLabels(pool, cell, labels, respChan)
+	if err = <-respChan; err != nil {
+		log.Printf("<api> modify labels of cell '%s' fail: %s", cell, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK("", w)
+}
+
+func (module *APIModule) previewPoolFailover(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	module.previewFailover(params.ByName("pool"), "", w, r)
+}
+
+func (module *APIModule) previewCellFailover(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	module.previewFailover(params.ByName("pool"), params.ByName("cell"), w, r)
+}
+
+func (module *APIModule) previewFailover(pool, cell string, w http.ResponseWriter, r *http.Request) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var respChan = make(chan ResourceResult, 1)
+	module.resource.PreviewFailover(pool, cell, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		err = result.Error
+		log.Printf("<api> preview failover of pool '%s' fail: %s", pool, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var payload = make([]FailoverPreview, 0)
+	payload = append(payload, result.FailoverPreviewList...)
+	ResponseOK(payload, w)
+}
+
+func (module *APIModule) getRebalancePlan(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var pool = params.ByName("pool")
+	type ResponsePayload struct {
+		Plan RebalancePlan    `json:"plan"`
+		Task *RebalanceStatus `json:"task,omitempty"`
+	}
+	var respChan = make(chan ResourceResult, 1)
+	module.resource.GetRebalancePlan(pool, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		err = result.Error
+		log.Printf("<api> get rebalance plan of pool '%s' fail: %s", pool, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var payload = ResponsePayload{Plan: result.RebalancePlan}
+	if "" != result.Rebalance.Pool {
+		payload.Task = &result.Rebalance
+	}
+	ResponseOK(payload, w)
+}
+
+func (module *APIModule) startRebalance(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var pool = params.ByName("pool")
+	type ResponsePayload struct {
+		Plan RebalancePlan   `json:"plan"`
+		Task RebalanceStatus `json:"task"`
+	}
+	var respChan = make(chan ResourceResult, 1)
+	module.resource.StartRebalance(pool, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		err = result.Error
+		log.Printf("<api> start rebalance of pool '%s' fail: %s", pool, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK(ResponsePayload{Plan: result.RebalancePlan, Task: result.Rebalance}, w)
+}
+
+func (module *APIModule) modifyRebalanceConfig(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var pool = params.ByName("pool")
+	var config PoolRebalanceConfig
+	var decoder = json.NewDecoder(r.Body)
+	if err = decoder.Decode(&config); err != nil {
+		log.Printf("<api> parse modify rebalance request fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var respChan = make(chan error, 1)
+	module.resource.ModifyRebalanceConfig(pool, config, respChan)
+	if err = <-respChan; err != nil {
+		log.Printf("<api> modify rebalance of pool '%s' fail: %s", pool, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK("", w)
+}
+
+func (module *APIModule) getFailoverCapacity(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var pool = params.ByName("pool")
+	var respChan = make(chan ResourceResult, 1)
+	module.resource.GetFailoverCapacity(pool, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		err = result.Error
+		log.Printf("<api> get failover capacity of pool '%s' fail: %s", pool, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK(result.FailoverCapacity, w)
+}
+
+func (module *APIModule) modifyFailoverCapacity(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var pool = params.ByName("pool")
+	var config PoolFailoverCapacity
+	var decoder = json.NewDecoder(r.Body)
+	if err = decoder.Decode(&config); err != nil {
+		log.Printf("<api> parse modify failover capacity request fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var respChan = make(chan error, 1)
+	module.resource.ModifyFailoverCapacity(pool, config, respChan)
+	if err = <-respChan; err != nil {
+		log.Printf("<api> modify failover capacity of pool '%s' fail: %s", pool, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK("", w)
+}
+
+func (module *APIModule) backupZone(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var signer = requestCredential(r)
+	var respChan = make(chan ResourceResult, 1)
+	module.resource.ExportZoneBackup(respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<api> export zone backup fail: %s", result.Error.Error())
+		ResponseFail(ResponseDefaultError, result.Error.Error(), w)
+		return
+	}
+	var backup = result.ZoneBackup
+	if err = backup.Sign(signer, module.backupKey); err != nil {
+		log.Printf("<api> sign zone backup fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	log.Printf("<api> zone '%s' backup created by '%s'", backup.Data.Zone, signer)
+	ResponseOK(backup, w)
+}
+
+// restoreZone : restore configuration from signed backup, only report differences when dry_run=true
+func (module *APIModule) restoreZone(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var dryRun = false
+	if value := r.URL.Query().Get("dry_run"); "" != value {
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			err = fmt.Errorf("invalid dry_run '%s'", value)
+			ResponseFail(ResponseDefaultError, err.Error(), w)
+			return
+		}
+	}
+	var backup ZoneBackup
+	var decoder = json.NewDecoder(r.Body)
+	if err = decoder.Decode(&backup); err != nil {
+		log.Printf("<api> parse zone backup fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	if zoneBackupCredentialVersion == backup.Version {
+		var credential apiSigner
+		if credential, err = module.resolveSigner(backup.Signer); err != nil {
+			err = fmt.Errorf("invalid backup signer '%s': %s", backup.Signer, err.Error())
+			ResponseFail(ResponseDefaultError, err.Error(), w)
+			return
+		}
+		for _, secret := range credential.Secrets {
+			if err = backup.Verify([]byte(secret)); err == nil {
+				break
+			}
+		}
+	} else {
+		err = backup.Verify(module.backupKey)
+	}
+	if err != nil {
+		log.Printf("<api> verify zone backup fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var respChan = make(chan ResourceResult, 1)
+	module.resource.RestoreZoneBackup(backup.Data, dryRun, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<api> restore zone backup fail: %s", result.Error.Error())
+		ResponseFail(ResponseDefaultError, result.Error.Error(), w)
+		return
+	}
+	var report = result.ZoneRestore
+	if !dryRun {
+		log.Printf("<api> zone '%s' restored from backup created at %s by '%s'", backup.Data.Zone, backup.CreateTime, backup.Signer)
+		//connected cells join restored pools
+		for cellName, address := range report.resynchronize {
+			event, _ := framework.CreateJsonMessage(framework.ComputeCellAvailableEvent)
+			event.SetString(framework.ParamKeyCell, cellName)
+			event.SetString(framework.ParamKeyAddress, address)
+			if err = module.proxy.sender.SendToSelf(event); err != nil {
+				log.Printf("<api> notify cell '%s' after restore fail: %s", cellName, err.Error())
+			}
+		}
+	}
+	ResponseOK(report, w)
+}
+
+// streamEvents : push cluster events as server-sent events, filtered by type/pool/cell/guest,
+// resume after the event specified by header 'Last-Event-ID' or parameter 'cursor'
+func (module *APIModule) streamEvents(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	const (
+		headerLastEventID = "Last-Event-ID"
+		eventLost         = "events_lost"
+		keepAliveInterval = time.Second * 15
+	)
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		ResponseFail(ResponseDefaultError, "streaming not supported", w)
+		return
+	}
+	var query = r.URL.Query()
+	var filter = EventFilter{Pool: query.Get("pool"), Cell: query.Get("cell"), Guest: query.Get("guest")}
+	if filter.Types, err = ParseEventTypes(query.Get("type")); err != nil {
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var cursor = r.Header.Get(headerLastEventID)
+	if "" == cursor {
+		cursor = query.Get("cursor")
+	}
+	var respChan = make(chan EventResult, 1)
+	module.events.SubscribeEvents(filter, cursor, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<api> subscribe events fail: %s", result.Error.Error())
+		ResponseFail(ResponseDefaultError, result.Error.Error(), w)
+		return
+	}
+	var subscription = result.Subscription
+	defer module.events.UnsubscribeEvents(subscription.ID)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	var access = requestAccess(r)
+	var visibleGuests = map[string]bool{}
+	var writeEvent = func(event ClusterEvent) error {
+		if access.scoped() && !module.eventVisible(access, event, visibleGuests) {
+			return nil
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+	if result.Lost {
+		//client should reload status before consuming following events
+		fmt.Fprintf(w, "event: %s\ndata: {\"cursor\": \"%s\"}\n\n", eventLost, cursor)
+	}
+	for _, event := range result.Backlog {
+		if err = writeEvent(event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+	log.Printf("<api> event subscriber %d from %s connected", subscription.ID, r.RemoteAddr)
+	var keepAlive = time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			log.Printf("<api> event subscriber %d disconnected", subscription.ID)
+			return
+		case event, ok := <-subscription.Events:
+			if !ok {
+				log.Printf("<api> event subscriber %d closed by hub", subscription.ID)
+				return
+			}
+			if err = writeEvent(event); err != nil {
+				log.Printf("<api> push event to subscriber %d fail: %s", subscription.ID, err.Error())
+				return
+			}
+		case <-keepAlive.C:
+			if _, err = fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// eventVisible : events of guest in scope, or of pool in scope when credential not restricted to users,
+// result of guest lookup cached in visible since deleted guest no longer available
+func (module *APIModule) eventVisible(access apiAccess, event ClusterEvent, visible map[string]bool) bool {
+	if "" == event.Guest {
+		return nil == access.Users && nil == access.Groups && "" != event.Pool && access.poolAllowed(event.Pool)
+	}
+	if allowed, exists := visible[event.Guest]; exists {
+		return allowed
+	}
+	var respChan = make(chan ResourceResult, 1)
+	module.resource.LookupInstance(event.Guest, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		return false
+	}
+	var allowed = access.guestAllowed(result.Instance)
+	visible[event.Guest] = allowed
+	return allowed
+}
+
+func (module *APIModule) queryWebhooks(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var respChan = make(chan WebhookResult, 1)
+	module.webhooks.QueryWebhooks(respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<api> query webhooks fail: %s", result.Error.Error())
+		ResponseFail(ResponseDefaultError, result.Error.Error(), w)
+		return
+	}
+	ResponseOK(result.WebhookList, w)
+}
+
+func (module *APIModule) getWebhook(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var id = params.ByName("id")
+	var respChan = make(chan WebhookResult, 1)
+	module.webhooks.GetWebhook(id, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<api> get webhook '%s' fail: %s", id, result.Error.Error())
+		ResponseFail(ResponseDefaultError, result.Error.Error(), w)
+		return
+	}
+	ResponseOK(result.Webhook, w)
+}
+
+func (module *APIModule) createWebhook(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var config WebhookConfig
+	var decoder = json.NewDecoder(r.Body)
+	if err = decoder.Decode(&config); err != nil {
+		log.Printf("<api> parse create webhook request fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var respChan = make(chan WebhookResult, 1)
+	module.webhooks.CreateWebhook(config, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<api> create webhook fail: %s", result.Error.Error())
+		ResponseFail(ResponseDefaultError, result.Error.Error(), w)
+		return
+	}
+	type userResponse struct {
+		ID string `json:"id"`
+	}
+	ResponseOK(userResponse{ID: result.ID}, w)
+}
+
+func (module *APIModule) modifyWebhook(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var id = params.ByName("id")
+	var config WebhookConfig
+	var decoder = json.NewDecoder(r.Body)
+	if err = decoder.Decode(&config); err != nil {
+		log.Printf("<api> parse modify webhook request fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var respChan = make(chan error, 1)
+	module.webhooks.ModifyWebhook(id, config, respChan)
+	if err = <-respChan; err != nil {
+		log.Printf("<api> modify webhook '%s' fail: %s", id, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK("", w)
+}
+
+func (module *APIModule) deleteWebhook(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var id = params.ByName("id")
+	var respChan = make(chan error, 1)
+	module.webhooks.DeleteWebhook(id, respChan)
+	if err = <-respChan; err != nil {
+		log.Printf("<api> delete webhook '%s' fail: %s", id, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK("", w)
+}
+
+// queryWebhookDeadLetters : events failed to deliver, filter by parameter 'webhook'
+func (module *APIModule) queryWebhookDeadLetters(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var respChan = make(chan WebhookResult, 1)
+	module.webhooks.QueryDeadLetters(r.URL.Query().Get("webhook"), respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<api> query webhook dead letters fail: %s", result.Error.Error())
+		ResponseFail(ResponseDefaultError, result.Error.Error(), w)
+		return
+	}
+	ResponseOK(result.DeadLetters, w)
+}
+
+func (module *APIModule) redeliverWebhookDeadLetter(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var id = params.ByName("id")
+	var respChan = make(chan error, 1)
+	module.webhooks.RedeliverDeadLetter(id, respChan)
+	if err = <-respChan; err != nil {
+		log.Printf("<api> redeliver dead letter '%s' fail: %s", id, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK("", w)
+}
+
+func (module *APIModule) deleteWebhookDeadLetter(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var id = params.ByName("id")
+	var respChan = make(chan error, 1)
+	module.webhooks.DeleteDeadLetter(id, respChan)
+	if err = <-respChan; err != nil {
+		log.Printf("<api> delete dead letter '%s' fail: %s", id, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK("", w)
+}
+
+func IsResponseSuccess(respChan chan ProxyResult) (resp framework.Message, errMessage string, success bool) {
+	result, ok := <-respChan
+	if !ok {
+		return resp, "channel closed", false
+	}
+	if result.Error != nil {
+		return resp, result.Error.Error(), false
+	}
+	if !result.Response.IsSuccess() {
+		return resp, result.Response.GetError(), false
+	}
+	return result.Response, "", true
+}
+
+type Response struct {
+	ErrorCode int         `json:"error_code"`
+	Message   string      `json:"message"`
+	Data      interface{} `json:"data"`
+}
+
+const (
+	ResponseDefaultError = 500
+)
+
+func ResponseFail(code int, message string, writer io.Writer) error {
+	if recorder, isRecorder := writer.(*apiResponseWriter); isRecorder {
+		recorder.fail(code, message)
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(Response{code, message, struct{}{}})
+}
+
+func ResponseOK(data interface{}, writer io.Writer) error {
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(Response{0, "", data})
+}
+
+// ResponseList : total of whole list reported in header when available, only selected fields of each item returned
+func ResponseList(list interface{}, resp framework.Message, option ListOption, w http.ResponseWriter) error {
+	if flags, err := resp.GetUIntArray(framework.ParamKeyFlag); err == nil && ListFlagCount == len(flags) {
+		w.Header().Set(HeaderNameTotalCount, strconv.FormatUint(flags[ListFlagTotal], 10))
+	}
+	if 0 == len(option.Fields) {
+		return ResponseOK(list, w)
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return ResponseFail(ResponseDefaultError, err.Error(), w)
+	}
+	var items []map[string]json.RawMessage
+	if err = json.Unmarshal(data, &items); err != nil {
+		return ResponseFail(ResponseDefaultError, err.Error(), w)
+	}
+	var selected = make([]map[string]json.RawMessage, 0, len(items))
+	for _, item := range items {
+		var fields = map[string]json.RawMessage{}
+		for _, name := range option.Fields {
+			if value, exists := item[name]; exists {
+				fields[name] = value
+			}
+		}
+		selected = append(selected, fields)
+	}
+	return ResponseOK(selected, w)
+}
+
+func (module *APIModule) registerJobHandlers() (err error) {
+	var handlers = map[string]JobHandler{
+		JobTypeCreateGuest: {EventDriven: true, Track: module.trackGuestJob, Cancel: module.cancelCreateGuestJob},
+		JobTypeResetSystem: {EventDriven: true, Track: module.trackResetSystemJob},
+		JobTypeResizeDisk:  {},
+		JobTypeMigration:   {Track: module.trackMigrationJob},
+		JobTypeBatchCreateGuest: {Track: func(batchID string) (JobStatus, error) {
+			return module.trackBatchJob(batchID, module.resource.GetBatchCreateGuestStatus)
+		}},
+		JobTypeBatchDeleteGuest: {Track: func(batchID string) (JobStatus, error) {
+			return module.trackBatchJob(batchID, module.resource.GetBatchDeleteGuestStatus)
+		}},
+		JobTypeBatchStopGuest: {Track: func(batchID string) (JobStatus, error) {
+			return module.trackBatchJob(batchID, module.resource.GetBatchStopGuestStatus)
+		}},
+		JobTypeCreateDiskImage: {Track: module.trackDiskImageJob, Cancel: module.cancelDiskImageJob},
+	}
+	for jobType, handler := range handlers {
+		if err = module.jobs.RegisterJobHandler(jobType, handler); err != nil {
+			return
+		}
+	}
+	return nil
+}
+
+// apiJob : job created before operation dispatched, so events of operation finished early not missed
+type apiJob struct {
+	module     *APIModule
+	ID         string
+	dispatched bool
+}
+
+// beginJob : target may be empty when allocated by operation, operation still served when job not created
+func (module *APIModule) beginJob(r *http.Request, jobType, target string) *apiJob {
+	var job = &apiJob{module: module}
+	var respChan = make(chan JobResult, 1)
+	module.jobs.CreateJob(Job{Type: jobType, Target: target, Owner: requestCredential(r)}, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<api> create %s job for '%s' fail: %s", jobType, target, result.Error.Error())
+		return job
+	}
+	job.ID = result.Job.ID
+	return job
+}
+
+// dispatch : bind target of accepted operation, empty id returned when job unavailable
+func (job *apiJob) dispatch(target string, completed bool) string {
+	job.dispatched = true
+	if "" == job.ID {
+		return ""
+	}
+	var respChan = make(chan error, 1)
+	job.module.jobs.DispatchJob(job.ID, target, completed, nil, respChan)
+	if err := <-respChan; err != nil {
+		log.Printf("<api> dispatch job '%s' to '%s' fail: %s", job.ID, target, err.Error())
+		return ""
+	}
+	return job.ID
+}
+
+// close : job failed with error responded when operation not dispatched
+func (job *apiJob) close(w http.ResponseWriter) {
+	if nil == job || job.dispatched || "" == job.ID {
+		return
+	}
+	var reason = "operation not dispatched"
+	if recorder, isRecorder := w.(*apiResponseWriter); isRecorder && "" != recorder.message {
+		reason = recorder.message
+	}
+	var respChan = make(chan error, 1)
+	job.module.jobs.DispatchJob(job.ID, "", false, errors.New(reason), respChan)
+	if err := <-respChan; err != nil {
+		log.Printf("<api> fail job '%s' fail: %s", job.ID, err.Error())
+	}
+}
+
+func (module *APIModule) trackGuestJob(instanceID string) (status JobStatus, err error) {
+	var respChan = make(chan ResourceResult, 1)
+	module.resource.GetInstanceStatus(instanceID, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		return JobStatus{State: JobStateFailed, Error: result.Error.Error()}, nil
+	}
+	if result.Instance.Created {
+		return JobStatus{State: JobStateSucceeded}, nil
+	}
+	return JobStatus{State: JobStateRunning, Progress: result.Instance.Progress}, nil
+}
+
+// trackResetSystemJob : reset not recorded since core restarted reported as error
+func (module *APIModule) trackResetSystemJob(instanceID string) (status JobStatus, err error) {
+	var respChan = make(chan ResourceResult, 1)
+	module.resource.GetResetSystemStatus(instanceID, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		return JobStatus{}, result.Error
+	}
+	var reset = result.SystemReset
+	if !reset.Finished {
+		return JobStatus{State: JobStateRunning, Progress: reset.Progress}, nil
+	}
+	if "" != reset.Error {
+		return JobStatus{State: JobStateFailed, Progress: reset.Progress, Error: reset.Error}, nil
+	}
+	return JobStatus{State: JobStateSucceeded}, nil
+}
+
+func (module *APIModule) cancelCreateGuestJob(instanceID string) (err error) {
+	msg, _ := framework.CreateJsonMessage(framework.DeleteGuestRequest)
+	msg.SetString(framework.ParamKeyInstance, instanceID)
+	msg.SetUInt(framework.ParamKeyOption, 1)
+	var respChan = make(chan ProxyResult, 1)
+	if err = module.proxy.SendRequest(msg, respChan); err != nil {
+		return
+	}
+	if _, errMsg, success := IsResponseSuccess(respChan); !success {
+		return errors.New(errMsg)
+	}
+	return nil
+}
+
+func (module *APIModule) trackMigrationJob(migrationID string) (status JobStatus, err error) {
+	var respChan = make(chan ResourceResult, 1)
+	module.resource.GetMigration(migrationID, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		return JobStatus{State: JobStateFailed, Error: result.Error.Error()}, nil
+	}
+	var migration = result.Migration
+	if !migration.Finished {
+		return JobStatus{State: JobStateRunning, Progress: migration.Progress}, nil
+	}
+	if migration.Error != nil {
+		return JobStatus{State: JobStateFailed, Progress: migration.Progress, Error: migration.Error.Error()}, nil
+	}
+	return JobStatus{State: JobStateSucceeded}, nil
+}
+
+// trackBatchJob : progress is percentage of guests processed
+func (module *APIModule) trackBatchJob(batchID string, query func(string, chan ResourceResult)) (status JobStatus, err error) {
+	var respChan = make(chan ResourceResult, 1)
+	query(batchID, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		return JobStatus{State: JobStateFailed, Error: result.Error.Error()}, nil
+	}
+	var taskStatus []BatchTaskStatus
+	for _, guest := range result.BatchCreate {
+		taskStatus = append(taskStatus, guest.Status)
+	}
+	for _, guest := range result.BatchDelete {
+		taskStatus = append(taskStatus, guest.Status)
+	}
+	for _, guest := range result.BatchStop {
+		taskStatus = append(taskStatus, guest.Status)
+	}
+	var processed, failed = 0, 0
+	for _, current := range taskStatus {
+		switch current {
+		case BatchTaskStatusSuccess:
+			processed++
+		case BatchTaskStatusFail:
+			processed++
+			failed++
+		}
+	}
+	if 0 == len(taskStatus) || processed < len(taskStatus) {
+		var progress uint
+		if 0 != len(taskStatus) {
+			progress = uint(processed * jobProgressComplete / len(taskStatus))
+		}
+		return JobStatus{State: JobStateRunning, Progress: progress}, nil
+	}
+	if 0 != failed {
+		return JobStatus{State: JobStateFailed, Progress: jobProgressComplete, Error: fmt.Sprintf("%d guest(s) failed", failed)}, nil
+	}
+	return JobStatus{State: JobStateSucceeded}, nil
+}
+
+func (module *APIModule) trackDiskImageJob(imageID string) (status JobStatus, err error) {
+	msg, _ := framework.CreateJsonMessage(framework.GetDiskImageRequest)
+	msg.SetString(framework.ParamKeyImage, imageID)
+	var respChan = make(chan ProxyResult, 1)
+	if err = module.proxy.SendRequest(msg, respChan); err != nil {
+		return
+	}
+	resp, errMsg, success := IsResponseSuccess(respChan)
+	if !success {
+		return JobStatus{State: JobStateFailed, Error: errMsg}, nil
+	}
+	if created, _ := resp.GetBoolean(framework.ParamKeyEnable); created {
+		return JobStatus{State: JobStateSucceeded}, nil
+	}
+	progress, _ := resp.GetUInt(framework.ParamKeyProgress)
+	return JobStatus{State: JobStateRunning, Progress: progress}, nil
+}
+
+func (module *APIModule) cancelDiskImageJob(imageID string) (err error) {
+	msg, _ := framework.CreateJsonMessage(framework.DeleteDiskImageRequest)
+	msg.SetString(framework.ParamKeyImage, imageID)
+	var respChan = make(chan ProxyResult, 1)
+	if err = module.proxy.SendRequest(msg, respChan); err != nil {
+		return
+	}
+	if _, errMsg, success := IsResponseSuccess(respChan); !success {
+		return errors.New(errMsg)
+	}
+	return nil
+}
+
+func (module *APIModule) queryJobs(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var query = r.URL.Query()
+	var filter = JobFilter{
+		Type:   query.Get("type"),
+		State:  query.Get("state"),
+		Owner:  query.Get("owner"),
+		Target: query.Get("target"),
+	}
+	if requestAccess(r).scoped() {
+		//only jobs created by scoped credential itself
+		filter.Owner = requestCredential(r)
+	}
+	var respChan = make(chan JobResult, 1)
+	module.jobs.QueryJobs(filter, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<api> query jobs fail: %s", result.Error.Error())
+		ResponseFail(ResponseDefaultError, result.Error.Error(), w)
+		return
+	}
+	ResponseOK(result.JobList, w)
+}
+
+func (module *APIModule) getJob(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var id = params.ByName("id")
+	var job Job
+	if job, err = module.getScopedJob(id, r); err != nil {
+		log.Printf("<api> get job '%s' fail: %s", id, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK(job, w)
+}
+
+func (module *APIModule) cancelJob(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var id = params.ByName("id")
+	if _, err = module.getScopedJob(id, r); err != nil {
+		log.Printf("<api> cancel job '%s' fail: %s", id, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var respChan = make(chan error, 1)
+	module.jobs.CancelJob(id, respChan)
+	if err = <-respChan; err != nil {
+		log.Printf("<api> cancel job '%s' fail: %s", id, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	ResponseOK("", w)
+}
+
+// getScopedJob : scoped credential only sees jobs created by itself
+func (module *APIModule) getScopedJob(id string, r *http.Request) (job Job, err error) {
+	var respChan = make(chan JobResult, 1)
+	module.jobs.GetJob(id, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		err = result.Error
+		return
+	}
+	if requestAccess(r).scoped() && result.Job.Owner != requestCredential(r) {
+		err = fmt.Errorf("invalid job '%s'", id)
+		return
+	}
+	return result.Job, nil
+}
+
+func (module *APIModule) queryCredentials(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var respChan = make(chan CredentialResult, 1)
+	module.credentials.QueryCredentials(respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<api> query credentials fail: %s", result.Error.Error())
+		ResponseFail(ResponseDefaultError, result.Error.Error(), w)
+		return
+	}
+	ResponseOK(result.CredentialList, w)
+}
+
+func (module *APIModule) getCredential(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var id = params.ByName("id")
+	var respChan = make(chan CredentialResult, 1)
+	module.credentials.GetCredential(id, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<api> get credential '%s' fail: %s", id, result.Error.Error())
+		ResponseFail(ResponseDefaultError, result.Error.Error(), w)
+		return
+	}
+	ResponseOK(result.Credential, w)
+}
+
+// createCredential : key only returned once, enabled by default, empty expire_time means never expire
+func (module *APIModule) createCredential(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	type userRequest struct {
+		ID         string   `json:"id,omitempty"`
+		Role       string   `json:"role"`
+		Pools      []string `json:"pools,omitempty"`
+		Users      []string `json:"users,omitempty"`
+		Groups     []string `json:"groups,omitempty"`
+		Enabled    *bool    `json:"enabled,omitempty"`
+		ExpireTime string   `json:"expire_time,omitempty"`
+	}
+	var request userRequest
+	var decoder = json.NewDecoder(r.Body)
+	if err = decoder.Decode(&request); err != nil {
+		log.Printf("<api> parse create credential request fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	if _, exists := module.apiCredentials[request.ID]; exists {
+		err = fmt.Errorf("credential '%s' already defined in config", request.ID)
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	if _, exists := module.apiRoles[request.Role]; !exists {
+		err = fmt.Errorf("invalid role '%s'", request.Role)
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var expire time.Time
+	if expire, err = parseCredentialExpire(request.ExpireTime); err != nil {
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var config = CredentialConfig{
+		Role:    request.Role,
+		Pools:   request.Pools,
+		Users:   request.Users,
+		Groups:  request.Groups,
+		Enabled: nil == request.Enabled || *request.Enabled,
+	}
+	var respChan = make(chan CredentialResult, 1)
+	module.credentials.CreateCredential(request.ID, config, expire, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<api> create credential fail: %s", result.Error.Error())
+		ResponseFail(ResponseDefaultError, result.Error.Error(), w)
+		return
+	}
+	log.Printf("<api> credential '%s' created by '%s'", result.ID, requestCredential(r))
+	type userResponse struct {
+		ID      string `json:"id"`
+		Key     string `json:"key"`
+		Version int    `json:"version"`
+	}
+	ResponseOK(userResponse{ID: result.ID, Key: result.Key, Version: result.Version}, w)
+}
+
+// modifyCredential : omitted fields unchanged, disabled credential rejected until enabled again
+func (module *APIModule) modifyCredential(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var id = params.ByName("id")
+	type userRequest struct {
+		Role    *string   `json:"role,omitempty"`
+		Pools   *[]string `json:"pools,omitempty"`
+		Users   *[]string `json:"users,omitempty"`
+		Groups  *[]string `json:"groups,omitempty"`
+		Enabled *bool     `json:"enabled,omitempty"`
+	}
+	var request userRequest
+	var decoder = json.NewDecoder(r.Body)
+	if err = decoder.Decode(&request); err != nil {
+		log.Printf("<api> parse modify credential request fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var respChan = make(chan CredentialResult, 1)
+	module.credentials.GetCredential(id, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		ResponseFail(ResponseDefaultError, result.Error.Error(), w)
+		return
+	}
+	var config = result.Credential.CredentialConfig
+	if nil != request.Role {
+		if _, exists := module.apiRoles[*request.Role]; !exists {
+			err = fmt.Errorf("invalid role '%s'", *request.Role)
+			ResponseFail(ResponseDefaultError, err.Error(), w)
+			return
+		}
+		config.Role = *request.Role
+	}
+	if nil != request.Pools {
+		config.Pools = *request.Pools
+	}
+	if nil != request.Users {
+		config.Users = *request.Users
+	}
+	if nil != request.Groups {
+		config.Groups = *request.Groups
+	}
+	if nil != request.Enabled {
+		config.Enabled = *request.Enabled
+	}
+	var errChan = make(chan error, 1)
+	module.credentials.ModifyCredential(id, config, errChan)
+	if err = <-errChan; err != nil {
+		log.Printf("<api> modify credential '%s' fail: %s", id, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	log.Printf("<api> credential '%s' modified by '%s'", id, requestCredential(r))
+	ResponseOK("", w)
+}
+
+// rotateCredential : new key returned once, previous keys valid for overlap seconds, default one hour
+func (module *APIModule) rotateCredential(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var id = params.ByName("id")
+	type userRequest struct {
+		ExpireTime string `json:"expire_time,omitempty"`
+		Overlap    *int   `json:"overlap,omitempty"`
+	}
+	var request userRequest
+	var decoder = json.NewDecoder(r.Body)
+	if err = decoder.Decode(&request); err != nil && io.EOF != err {
+		log.Printf("<api> parse rotate credential request fail: %s", err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var expire time.Time
+	if expire, err = parseCredentialExpire(request.ExpireTime); err != nil {
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var overlap = CredentialDefaultOverlap
+	if nil != request.Overlap {
+		overlap = time.Duration(*request.Overlap) * time.Second
+	}
+	var respChan = make(chan CredentialResult, 1)
+	module.credentials.RotateCredential(id, expire, overlap, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<api> rotate credential '%s' fail: %s", id, result.Error.Error())
+		ResponseFail(ResponseDefaultError, result.Error.Error(), w)
+		return
+	}
+	log.Printf("<api> credential '%s' rotated by '%s'", id, requestCredential(r))
+	type userResponse struct {
+		ID      string `json:"id"`
+		Key     string `json:"key"`
+		Version int    `json:"version"`
+	}
+	ResponseOK(userResponse{ID: result.ID, Key: result.Key, Version: result.Version}, w)
+}
+
+func (module *APIModule) deleteCredential(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var id = params.ByName("id")
+	var respChan = make(chan error, 1)
+	module.credentials.DeleteCredential(id, respChan)
+	if err = <-respChan; err != nil {
+		log.Printf("<api> delete credential '%s' fail: %s", id, err.Error())
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	log.Printf("<api> credential '%s' deleted by '%s'", id, requestCredential(r))
+	ResponseOK("", w)
+}
+
+func parseCredentialExpire(value string) (expire time.Time, err error) {
+	if "" == value {
+		return
+	}
+	if expire, err = time.ParseInLocation(TimeFormatLayout, value, time.Local); err != nil {
+		err = fmt.Errorf("invalid expire time '%s'", value)
+	}
+	return
+}
+
+// queryAuditRecords : newest first, filter by source, credential, user, group, action, resource, target, result, since and until
+func (module *APIModule) queryAuditRecords(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	var option ListOption
+	if option, err = ParseListOption(r.URL.Query()); err != nil {
+		ResponseFail(ResponseDefaultError, err.Error(), w)
+		return
+	}
+	var query = r.URL.Query()
+	var filter = AuditFilter{
+		Source:     query.Get("source"),
+		Credential: query.Get("credential"),
+		User:       query.Get("user"),
+		Group:      query.Get("group"),
+		Action:     query.Get("action"),
+		Resource:   query.Get("resource"),
+		Target:     query.Get("target"),
+		Result:     query.Get("result"),
+		Since:      query.Get("since"),
+		Until:      query.Get("until"),
+	}
+	for _, value := range []string{filter.Since, filter.Until} {
+		if "" == value {
+			continue
+		}
+		if _, err = time.ParseInLocation(TimeFormatLayout, value, time.Local); err != nil {
+			err = fmt.Errorf("invalid time '%s'", value)
+			ResponseFail(ResponseDefaultError, err.Error(), w)
+			return
+		}
+	}
+	var respChan = make(chan AuditResult, 1)
+	module.audit.QueryRecords(filter, option, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<api> query audit records fail: %s", result.Error.Error())
+		ResponseFail(ResponseDefaultError, result.Error.Error(), w)
+		return
+	}
+	w.Header().Set(HeaderNameTotalCount, strconv.Itoa(result.Total))
+	ResponseOK(result.Records, w)
+}
+
+// queryAPILimits : limiter state of credentials which have sent requests since start
+func (module *APIModule) queryAPILimits(w http.ResponseWriter, r *http.Request, params httprouter.Params) {
+	var err = module.verifyRequestSignature(r)
+	if err != nil {
+		ResponseFail(SignatureErrorCode(err), err.Error(), w)
+		return
+	}
+	ResponseOK(module.limiter.status(), w)
 }
@@ -0,0 +1,121 @@
+package modules
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// bootstrapModuleForTest : API module backed by started credential manager, stopped when test finished
+func bootstrapModuleForTest(t *testing.T) (module *APIModule, credentials *CredentialManager) {
+	var configPath, dataPath = t.TempDir(), t.TempDir()
+	credentials, err := CreateCredentialManager(configPath, dataPath)
+	if err != nil {
+		t.Fatalf("create credential manager fail: %s", err.Error())
+	}
+	if err = credentials.Start(); err != nil {
+		t.Fatalf("start credential manager fail: %s", err.Error())
+	}
+	t.Cleanup(func() {
+		credentials.Stop()
+	})
+	module = &APIModule{
+		credentials:   credentials,
+		bootstrap:     true,
+		bootstrapFile: filepath.Join(configPath, BootstrapKeyFilename),
+	}
+	return module, credentials
+}
+
+// verifiedAPIRequest : request of credential already verified by limiter
+func verifiedAPIRequest(method, url, credential string, body []byte) *http.Request {
+	var r = httptest.NewRequest(method, apiPath(url), bytes.NewReader(body))
+	r.Header.Set(HeaderNameAuthorization, "Nano-HMAC-SHA256 Credential="+credential+"/20260101/nano, SignedHeaders=host, Signature=test")
+	return r.WithContext(context.WithValue(r.Context(), apiLeaseKey{}, &apiLease{Credential: credential, Verified: true}))
+}
+
+func TestAPIModule_BootstrapCredential(t *testing.T) {
+	var testCases = []struct {
+		Name       string
+		Existing   bool
+		StaleFile  bool
+		Bootstrap  bool
+		Credential int
+	}{
+		{"no credential", false, false, true, 1},
+		{"stale bootstrap file", false, true, true, 1},
+		{"credential exists", true, false, false, 1},
+	}
+	for _, testCase := range testCases {
+		var module, credentials = bootstrapModuleForTest(t)
+		var respChan = make(chan CredentialResult, 1)
+		if testCase.Existing {
+			credentials.CreateCredential("operator", CredentialConfig{Role: APIRoleAdmin, Enabled: true}, time.Time{}, respChan)
+			if result := <-respChan; result.Error != nil {
+				t.Fatalf("%s: create credential fail: %s", testCase.Name, result.Error.Error())
+			}
+		}
+		if testCase.StaleFile {
+			if err := ioutil.WriteFile(module.bootstrapFile, []byte("stale"), 0644); err != nil {
+				t.Fatalf("%s: write stale file fail: %s", testCase.Name, err.Error())
+			}
+		}
+		if err := module.bootstrapCredential(); err != nil {
+			t.Fatalf("%s: bootstrap fail: %s", testCase.Name, err.Error())
+		}
+		credentials.QueryCredentials(respChan)
+		var result = <-respChan
+		if result.Error != nil || testCase.Credential != len(result.CredentialList) {
+			t.Fatalf("%s: %d credential(s) available, %d expected, error %v",
+				testCase.Name, len(result.CredentialList), testCase.Credential, result.Error)
+		}
+		info, err := os.Stat(module.bootstrapFile)
+		if !testCase.Bootstrap {
+			if !os.IsNotExist(err) {
+				t.Fatalf("%s: bootstrap file created with existing credential", testCase.Name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: bootstrap file not created: %v", testCase.Name, err)
+		}
+		if mode := info.Mode().Perm(); bootstrapKeyFilePerm != mode {
+			t.Fatalf("%s: bootstrap file mode %s, %s expected", testCase.Name, mode, os.FileMode(bootstrapKeyFilePerm))
+		}
+		data, err := ioutil.ReadFile(module.bootstrapFile)
+		if err != nil {
+			t.Fatalf("%s: read bootstrap file fail: %s", testCase.Name, err.Error())
+		}
+		var saved ApiCredential
+		if err = json.Unmarshal(data, &saved); err != nil {
+			t.Fatalf("%s: invalid bootstrap file: %s", testCase.Name, err.Error())
+		}
+		if APIRoleAdmin != saved.Role || "" == saved.Key {
+			t.Fatalf("%s: unexpected bootstrap credential %+v", testCase.Name, saved)
+		}
+		//saved key signs as created credential
+		credentials.ResolveCredential(saved.ID, respChan)
+		if result = <-respChan; result.Error != nil {
+			t.Fatalf("%s: resolve bootstrap credential fail: %s", testCase.Name, result.Error.Error())
+		}
+		if APIRoleAdmin != result.Credential.Role || 0 == len(result.Credential.Keys) ||
+			DigestCredentialKey(saved.Key) != result.Credential.Keys[0].Digest {
+			t.Fatalf("%s: key in bootstrap file not match credential '%s'", testCase.Name, saved.ID)
+		}
+		//credential available since, so not created again
+		if err = module.bootstrapCredential(); err != nil {
+			t.Fatalf("%s: bootstrap again fail: %s", testCase.Name, err.Error())
+		}
+		credentials.QueryCredentials(respChan)
+		if result = <-respChan; 1 != len(result.CredentialList) {
+			t.Fatalf("%s: %d credential(s) after bootstrap again", testCase.Name, len(result.CredentialList))
+		}
+	}
+}
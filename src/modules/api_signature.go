@@ -0,0 +1,118 @@
+package modules
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	HeaderNameNonce            = "Nano-Nonce"
+	DefaultSignatureWindow     = 300
+	DefaultNonceCapacity       = 1 << 16
+	ResponseSignatureMalformed = 4001
+	ResponseSignatureInvalid   = 4002
+	ResponseSignatureExpired   = 4003
+	ResponseSignatureReplayed  = 4004
+	ResponseAccessDenied       = 4030
+)
+
+// SignatureError : failure of request signature or authorization, code reported in response
+type SignatureError struct {
+	Code    int
+	Message string
+}
+
+func (err *SignatureError) Error() string {
+	return err.Message
+}
+
+func signatureError(code int, format string, args ...interface{}) error {
+	return &SignatureError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// SignatureErrorCode : code of signature error, default error for others
+func SignatureErrorCode(err error) int {
+	if signatureErr, isSignatureError := err.(*SignatureError); isSignatureError {
+		return signatureErr.Code
+	}
+	return ResponseDefaultError
+}
+
+type nonceRecord struct {
+	Key    string
+	Expire time.Time
+}
+
+type nonceCheck struct {
+	nonceRecord
+	RespChan chan bool
+}
+
+// replayGuard : nonces seen in validity window, oldest evicted when capacity reached
+type replayGuard struct {
+	capacity int
+	seen     map[string]bool
+	queue    []nonceRecord
+	checks   chan nonceCheck
+	exitChan chan bool
+}
+
+func newReplayGuard(capacity int) *replayGuard {
+	const (
+		DefaultQueueLength = 1 << 10
+	)
+	return &replayGuard{
+		capacity: capacity,
+		seen:     map[string]bool{},
+		checks:   make(chan nonceCheck, DefaultQueueLength),
+		exitChan: make(chan bool),
+	}
+}
+
+func (guard *replayGuard) start() {
+	go guard.routine()
+}
+
+func (guard *replayGuard) stop() {
+	guard.exitChan <- true
+	<-guard.exitChan
+}
+
+// seenBefore : check and remember nonce until expire
+func (guard *replayGuard) seenBefore(key string, expire time.Time) bool {
+	var respChan = make(chan bool, 1)
+	guard.checks <- nonceCheck{nonceRecord{key, expire}, respChan}
+	return <-respChan
+}
+
+func (guard *replayGuard) routine() {
+	for {
+		select {
+		case <-guard.exitChan:
+			guard.exitChan <- true
+			return
+		case check := <-guard.checks:
+			check.RespChan <- guard.handleCheck(check.nonceRecord)
+		}
+	}
+}
+
+func (guard *replayGuard) handleCheck(record nonceRecord) (seen bool) {
+	var now = time.Now()
+	for 0 != len(guard.queue) && guard.queue[0].Expire.Before(now) {
+		delete(guard.seen, guard.queue[0].Key)
+		guard.queue = guard.queue[1:]
+	}
+	if guard.seen[record.Key] {
+		return true
+	}
+	if len(guard.queue) >= guard.capacity {
+		log.Printf("<api> warning: nonce cache full, unexpired nonce '%s' evicted", guard.queue[0].Key)
+		delete(guard.seen, guard.queue[0].Key)
+		guard.queue = guard.queue[1:]
+	}
+	guard.seen[record.Key] = true
+	guard.queue = append(guard.queue, record)
+	return false
+}
@@ -0,0 +1,44 @@
+package modules
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplayGuard_SeenBefore(t *testing.T) {
+	type nonceStep struct {
+		Key    string
+		Expire time.Duration
+		Seen   bool
+	}
+	const (
+		valid   = time.Minute
+		expired = -time.Second
+	)
+	var testCases = []struct {
+		Name     string
+		Capacity int
+		Steps    []nonceStep
+	}{
+		{"replay rejected", 4, []nonceStep{{"a", valid, false}, {"b", valid, false}, {"a", valid, true}}},
+		{"expired nonce forgotten", 4, []nonceStep{{"a", expired, false}, {"a", valid, false}, {"a", valid, true}}},
+		{"oldest evicted when full", 2, []nonceStep{{"a", valid, false}, {"b", valid, false}, {"c", valid, false},
+			{"a", valid, false}, {"c", valid, true}}},
+		{"expired released before eviction", 2, []nonceStep{{"a", expired, false}, {"b", valid, false}, {"c", valid, false},
+			{"b", valid, true}}},
+	}
+	for _, testCase := range testCases {
+		var guard = newReplayGuard(testCase.Capacity)
+		guard.start()
+		for index, step := range testCase.Steps {
+			if seen := guard.seenBefore(step.Key, time.Now().Add(step.Expire)); seen != step.Seen {
+				guard.stop()
+				t.Fatalf("%s: step %d nonce '%s' seen %t, %t expected", testCase.Name, index, step.Key, seen, step.Seen)
+			}
+		}
+		guard.stop()
+		if len(guard.queue) > testCase.Capacity || len(guard.queue) != len(guard.seen) {
+			t.Fatalf("%s: %d queued and %d seen exceed capacity %d", testCase.Name, len(guard.queue), len(guard.seen), testCase.Capacity)
+		}
+	}
+}
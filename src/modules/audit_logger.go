@@ -0,0 +1,402 @@
+package modules
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/project-nano/framework"
+	uuid "github.com/satori/go.uuid"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	AuditFilename          = "audit.log"
+	AuditSourceAPI         = "api"
+	AuditSourceTransaction = "transaction"
+	AuditResultSuccess     = "success"
+	AuditResultFail        = "fail"
+	auditRedacted          = "******"
+	auditMaxFileSize       = 16 << 20
+	auditMaxBackups        = 9
+	auditFilePerm          = 0600
+)
+
+// AuditRecord : one mutating API call or state-changing transaction, duration in milliseconds
+type AuditRecord struct {
+	ID         string      `json:"id"`
+	Time       string      `json:"time"`
+	Source     string      `json:"source"`
+	Credential string      `json:"credential,omitempty"`
+	Sender     string      `json:"sender,omitempty"`
+	User       string      `json:"user,omitempty"`
+	Group      string      `json:"group,omitempty"`
+	Action     string      `json:"action"`
+	Resource   string      `json:"resource,omitempty"`
+	Target     string      `json:"target,omitempty"`
+	Path       string      `json:"path,omitempty"`
+	Parameters interface{} `json:"parameters,omitempty"`
+	Result     string      `json:"result"`
+	Error      string      `json:"error,omitempty"`
+	Duration   int64       `json:"duration"`
+}
+
+// AuditFilter : empty field matches all, Since and Until in TimeFormatLayout
+type AuditFilter struct {
+	Source     string
+	Credential string
+	User       string
+	Group      string
+	Action     string
+	Resource   string
+	Target     string
+	Result     string
+	Since      string
+	Until      string
+}
+
+type AuditResult struct {
+	Error   error
+	Records []AuditRecord
+	Total   int
+	Limit   int
+	Offset  int
+}
+
+type AuditModule interface {
+	Record(record AuditRecord)
+	QueryRecords(filter AuditFilter, option ListOption, respChan chan AuditResult)
+	BeginTransaction(requester string, session framework.SessionID)
+	ObserveResponse(target string, msg framework.Message)
+	FinishTransaction(requester string, session framework.SessionID, record AuditRecord)
+}
+
+type auditCommandType int
+
+const (
+	auditCmdRecord = iota
+	auditCmdQuery
+	auditCmdBegin
+	auditCmdObserve
+	auditCmdFinish
+)
+
+type auditCommand struct {
+	Type       auditCommandType
+	Record     AuditRecord
+	Filter     AuditFilter
+	Option     ListOption
+	Key        string
+	Message    framework.Message
+	ResultChan chan AuditResult
+}
+
+// AuditLogger : append records to audit file as JSON lines, rotate file when size exceeded
+type AuditLogger struct {
+	auditFile string
+	file      *os.File
+	size      int64
+	responses map[string]framework.Message
+	commands  chan auditCommand
+	runner    *framework.SimpleRunner
+}
+
+func CreateAuditLogger(dataPath string) (logger *AuditLogger, err error) {
+	const (
+		DefaultQueueLength = 1 << 10
+	)
+	logger = &AuditLogger{}
+	logger.auditFile = filepath.Join(dataPath, AuditFilename)
+	logger.responses = map[string]framework.Message{}
+	logger.commands = make(chan auditCommand, DefaultQueueLength)
+	if err = logger.openFile(); err != nil {
+		return
+	}
+	logger.runner = framework.CreateSimpleRunner(logger.routine)
+	return logger, nil
+}
+
+func (logger *AuditLogger) Start() error {
+	return logger.runner.Start()
+}
+
+func (logger *AuditLogger) Stop() error {
+	return logger.runner.Stop()
+}
+
+func (logger *AuditLogger) Record(record AuditRecord) {
+	logger.commands <- auditCommand{Type: auditCmdRecord, Record: record}
+}
+
+// QueryRecords : newest record first unless sorted by option
+func (logger *AuditLogger) QueryRecords(filter AuditFilter, option ListOption, respChan chan AuditResult) {
+	logger.commands <- auditCommand{Type: auditCmdQuery, Filter: filter, Option: option, ResultChan: respChan}
+}
+
+// BeginTransaction : start capturing response sent back to requester of transaction
+func (logger *AuditLogger) BeginTransaction(requester string, session framework.SessionID) {
+	logger.commands <- auditCommand{Type: auditCmdBegin, Key: auditTransactionKey(requester, session)}
+}
+
+// ObserveResponse : response kept only when transaction of target session is being audited
+func (logger *AuditLogger) ObserveResponse(target string, msg framework.Message) {
+	logger.commands <- auditCommand{Type: auditCmdObserve, Key: auditTransactionKey(target, msg.GetToSession()), Message: msg}
+}
+
+// FinishTransaction : result of record taken from captured response when available
+func (logger *AuditLogger) FinishTransaction(requester string, session framework.SessionID, record AuditRecord) {
+	logger.commands <- auditCommand{Type: auditCmdFinish, Key: auditTransactionKey(requester, session), Record: record}
+}
+
+func (logger *AuditLogger) routine(c framework.RoutineController) {
+	log.Printf("<audit> started, records write to '%s'", logger.auditFile)
+	for !c.IsStopping() {
+		select {
+		case <-c.GetNotifyChannel():
+			c.SetStopping()
+		case cmd := <-logger.commands:
+			logger.handleCommand(cmd)
+		}
+	}
+	//flush records in queue
+	for 0 != len(logger.commands) {
+		logger.handleCommand(<-logger.commands)
+	}
+	if err := logger.file.Close(); err != nil {
+		log.Printf("<audit> close audit file fail: %s", err.Error())
+	}
+	c.NotifyExit()
+	log.Println("<audit> stopped")
+}
+
+func (logger *AuditLogger) handleCommand(cmd auditCommand) {
+	var err error
+	switch cmd.Type {
+	case auditCmdRecord:
+		err = logger.handleRecord(cmd.Record)
+	case auditCmdQuery:
+		err = logger.handleQueryRecords(cmd.Filter, cmd.Option, cmd.ResultChan)
+	case auditCmdBegin:
+		logger.responses[cmd.Key] = nil
+	case auditCmdObserve:
+		if _, exists := logger.responses[cmd.Key]; exists {
+			logger.responses[cmd.Key] = cmd.Message
+		}
+	case auditCmdFinish:
+		err = logger.handleFinishTransaction(cmd.Key, cmd.Record)
+	default:
+		log.Printf("<audit> unsupported command type %d", cmd.Type)
+		return
+	}
+	if err != nil {
+		log.Printf("<audit> handle command %d fail: %s", cmd.Type, err.Error())
+	}
+}
+
+func (logger *AuditLogger) handleRecord(record AuditRecord) (err error) {
+	record.ID = uuid.NewV4().String()
+	if "" == record.Time {
+		record.Time = time.Now().Format(TimeFormatLayout)
+	}
+	var data []byte
+	if data, err = json.Marshal(record); err != nil {
+		return
+	}
+	data = append(data, '\n')
+	if logger.size+int64(len(data)) > auditMaxFileSize {
+		if err = logger.rotate(); err != nil {
+			return
+		}
+	}
+	var written int
+	written, err = logger.file.Write(data)
+	logger.size += int64(written)
+	return
+}
+
+func (logger *AuditLogger) handleFinishTransaction(key string, record AuditRecord) (err error) {
+	if resp := logger.responses[key]; nil != resp {
+		if resp.IsSuccess() {
+			record.Result = AuditResultSuccess
+			record.Error = ""
+		} else {
+			record.Result = AuditResultFail
+			record.Error = resp.GetError()
+		}
+	}
+	delete(logger.responses, key)
+	return logger.handleRecord(record)
+}
+
+func (logger *AuditLogger) handleQueryRecords(filter AuditFilter, option ListOption, respChan chan AuditResult) (err error) {
+	var records = make([]AuditRecord, 0)
+	for _, fileName := range logger.auditFiles() {
+		var matched []AuditRecord
+		if matched, err = readAuditFile(fileName, filter); err != nil {
+			respChan <- AuditResult{Error: err}
+			return
+		}
+		//newest first
+		for index := len(matched) - 1; index >= 0; index-- {
+			records = append(records, matched[index])
+		}
+	}
+	var keys = ListSortKeys{
+		"time":     func(i, j int) bool { return records[i].Time < records[j].Time },
+		"duration": func(i, j int) bool { return records[i].Duration < records[j].Duration },
+		"action":   func(i, j int) bool { return records[i].Action < records[j].Action },
+	}
+	if err = SortList(records, option, "", keys); err != nil {
+		respChan <- AuditResult{Error: err}
+		return
+	}
+	var begin, end int
+	if begin, end, err = option.Page(len(records)); err != nil {
+		respChan <- AuditResult{Error: err}
+		return
+	}
+	respChan <- AuditResult{Records: records[begin:end], Total: len(records), Limit: option.Limit, Offset: option.Offset}
+	return nil
+}
+
+// auditFiles : current file first, then backups from newest to oldest
+func (logger *AuditLogger) auditFiles() (files []string) {
+	files = append(files, logger.auditFile)
+	for index := 1; index <= auditMaxBackups; index++ {
+		var backup = fmt.Sprintf("%s.%d", logger.auditFile, index)
+		if _, err := os.Stat(backup); err != nil {
+			break
+		}
+		files = append(files, backup)
+	}
+	return
+}
+
+func (logger *AuditLogger) openFile() (err error) {
+	if logger.file, err = os.OpenFile(logger.auditFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, auditFilePerm); err != nil {
+		err = fmt.Errorf("open audit file fail: %s", err.Error())
+		return
+	}
+	var info os.FileInfo
+	if info, err = logger.file.Stat(); err != nil {
+		return
+	}
+	logger.size = info.Size()
+	return nil
+}
+
+// rotate : shift backups, oldest one removed when exceeds limit
+func (logger *AuditLogger) rotate() (err error) {
+	if err = logger.file.Close(); err != nil {
+		return
+	}
+	var backup = func(index int) string {
+		return fmt.Sprintf("%s.%d", logger.auditFile, index)
+	}
+	if _, err = os.Stat(backup(auditMaxBackups)); err == nil {
+		if err = os.Remove(backup(auditMaxBackups)); err != nil {
+			return
+		}
+	}
+	for index := auditMaxBackups - 1; index > 0; index-- {
+		if _, err = os.Stat(backup(index)); err != nil {
+			continue
+		}
+		if err = os.Rename(backup(index), backup(index+1)); err != nil {
+			return
+		}
+	}
+	if err = os.Rename(logger.auditFile, backup(1)); err != nil {
+		return
+	}
+	log.Printf("<audit> audit file rotated to '%s'", backup(1))
+	return logger.openFile()
+}
+
+func readAuditFile(fileName string, filter AuditFilter) (records []AuditRecord, err error) {
+	var file *os.File
+	if file, err = os.Open(fileName); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return
+	}
+	defer file.Close()
+	var scanner = bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1<<16), auditMaxFileSize)
+	for scanner.Scan() {
+		var record AuditRecord
+		if err = json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			log.Printf("<audit> ignore invalid record in '%s': %s", fileName, err.Error())
+			continue
+		}
+		if filter.match(record) {
+			records = append(records, record)
+		}
+	}
+	return records, scanner.Err()
+}
+
+func (filter AuditFilter) match(record AuditRecord) bool {
+	var pairs = [][2]string{
+		{filter.Source, record.Source},
+		{filter.Credential, record.Credential},
+		{filter.User, record.User},
+		{filter.Group, record.Group},
+		{filter.Action, record.Action},
+		{filter.Resource, record.Resource},
+		{filter.Target, record.Target},
+		{filter.Result, record.Result},
+	}
+	for _, pair := range pairs {
+		if "" != pair[0] && pair[0] != pair[1] {
+			return false
+		}
+	}
+	if "" != filter.Since && record.Time < filter.Since {
+		return false
+	}
+	if "" != filter.Until && record.Time > filter.Until {
+		return false
+	}
+	return true
+}
+
+func auditTransactionKey(requester string, session framework.SessionID) string {
+	return fmt.Sprintf("%s/%d", requester, session)
+}
+
+// RedactAuditParameters : value of secret fields replaced in decoded JSON payload
+func RedactAuditParameters(value interface{}) interface{} {
+	switch current := value.(type) {
+	case map[string]interface{}:
+		for key, item := range current {
+			if isAuditSecret(key) {
+				current[key] = auditRedacted
+			} else {
+				current[key] = RedactAuditParameters(item)
+			}
+		}
+		return current
+	case []interface{}:
+		for index, item := range current {
+			current[index] = RedactAuditParameters(item)
+		}
+		return current
+	default:
+		return value
+	}
+}
+
+func isAuditSecret(name string) bool {
+	var lowerName = strings.ToLower(name)
+	for _, keyword := range []string{"secret", "password", "key", "token"} {
+		if strings.Contains(lowerName, keyword) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package modules
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// auditLoggerForTest : logger not started, commands handled by drainAuditCommands
+func auditLoggerForTest(t *testing.T) *AuditLogger {
+	logger, err := CreateAuditLogger(t.TempDir())
+	if err != nil {
+		t.Fatalf("create audit logger fail: %s", err.Error())
+	}
+	t.Cleanup(func() {
+		logger.file.Close()
+	})
+	return logger
+}
+
+func drainAuditCommands(logger *AuditLogger) {
+	for 0 != len(logger.commands) {
+		logger.handleCommand(<-logger.commands)
+	}
+}
+
+func TestAuditLogger_Redact(t *testing.T) {
+	var testCases = []struct {
+		Name     string
+		Payload  string
+		Expected string
+	}{
+		{"no secret", `{"name":"guest","cores":2}`, `{"cores":2,"name":"guest"}`},
+		{"secret fields", `{"name":"hook","secret":"s","admin_password":"p","Token":"t","api_key":"k"}`,
+			`{"Token":"******","admin_password":"******","api_key":"******","name":"hook","secret":"******"}`},
+		{"nested object", `{"ci":{"user":"root","root_password":"p"}}`, `{"ci":{"root_password":"******","user":"root"}}`},
+		{"object in array", `{"hooks":[{"url":"u","secret":"s"}]}`, `{"hooks":[{"secret":"******","url":"u"}]}`},
+		{"whole value of secret field", `{"keys":["a","b"]}`, `{"keys":"******"}`},
+		{"array payload", `[{"password":"p"},"plain"]`, `[{"password":"******"},"plain"]`},
+		{"scalar payload", `"password"`, `"password"`},
+	}
+	for _, testCase := range testCases {
+		var payload interface{}
+		if err := json.Unmarshal([]byte(testCase.Payload), &payload); err != nil {
+			t.Fatalf("%s: invalid payload: %s", testCase.Name, err.Error())
+		}
+		data, err := json.Marshal(RedactAuditParameters(payload))
+		if err != nil {
+			t.Fatalf("%s: marshal fail: %s", testCase.Name, err.Error())
+		}
+		if testCase.Expected != string(data) {
+			t.Fatalf("%s: redacted as %s, %s expected", testCase.Name, data, testCase.Expected)
+		}
+	}
+}
+
+func TestAuditLogger_Rotate(t *testing.T) {
+	const (
+		recordCount = auditMaxBackups + 3
+	)
+	var logger = auditLoggerForTest(t)
+	for index := 0; index < recordCount; index++ {
+		//file regarded as full, so every record after the first rotates
+		if 0 != index {
+			logger.size = auditMaxFileSize
+		}
+		if err := logger.handleRecord(AuditRecord{Source: AuditSourceAPI, Action: "create", Target: strconv.Itoa(index)}); err != nil {
+			t.Fatalf("record %d fail: %s", index, err.Error())
+		}
+	}
+	var files = logger.auditFiles()
+	if auditMaxBackups+1 != len(files) {
+		t.Fatalf("%d audit file(s) kept, %d expected", len(files), auditMaxBackups+1)
+	}
+	if _, err := os.Stat(fmt.Sprintf("%s.%d", logger.auditFile, auditMaxBackups+1)); !os.IsNotExist(err) {
+		t.Fatalf("backup exceeds limit %d", auditMaxBackups)
+	}
+	for _, fileName := range files {
+		info, err := os.Stat(fileName)
+		if err != nil {
+			t.Fatalf("stat '%s' fail: %s", fileName, err.Error())
+		}
+		if mode := info.Mode().Perm(); auditFilePerm != mode {
+			t.Fatalf("'%s' created with mode %s", fileName, mode)
+		}
+	}
+	//newest first across rotated files, oldest records removed with last backup
+	var respChan = make(chan AuditResult, 1)
+	if err := logger.handleQueryRecords(AuditFilter{}, ListOption{}, respChan); err != nil {
+		t.Fatalf("query records fail: %s", err.Error())
+	}
+	var result = <-respChan
+	if auditMaxBackups+1 != result.Total {
+		t.Fatalf("%d record(s) available, %d expected", result.Total, auditMaxBackups+1)
+	}
+	for index, record := range result.Records {
+		if expected := strconv.Itoa(recordCount - 1 - index); expected != record.Target {
+			t.Fatalf("record %d targets '%s', '%s' expected", index, record.Target, expected)
+		}
+	}
+}
+
+func TestAuditLogger_Query(t *testing.T) {
+	var logger = auditLoggerForTest(t)
+	var records = []AuditRecord{
+		{Time: "2026-01-01 10:00:00", Source: AuditSourceAPI, Credential: "alice", Action: "create", Resource: "instances",
+			Target: "t1", Result: AuditResultSuccess, Duration: 30},
+		{Time: "2026-01-02 10:00:00", Source: AuditSourceAPI, Credential: "bob", Action: "delete", Resource: "instances",
+			Target: "t2", Result: AuditResultFail, Error: "invalid instance", Duration: 10},
+		{Time: "2026-01-03 10:00:00", Source: AuditSourceTransaction, Sender: "api", Action: "modify_guest",
+			Target: "t3", Result: AuditResultSuccess, Duration: 20},
+	}
+	for _, record := range records {
+		if err := logger.handleRecord(record); err != nil {
+			t.Fatalf("record fail: %s", err.Error())
+		}
+	}
+	if err := logger.Start(); err != nil {
+		t.Fatalf("start audit logger fail: %s", err.Error())
+	}
+	defer logger.Stop()
+	var module = &APIModule{audit: logger}
+	var testCases = []struct {
+		Name    string
+		Query   url.Values
+		Targets []string
+		Total   int
+		Valid   bool
+	}{
+		{"all", url.Values{}, []string{"t3", "t2", "t1"}, 3, true},
+		{"by source", url.Values{"source": {AuditSourceAPI}}, []string{"t2", "t1"}, 2, true},
+		{"failed calls", url.Values{"result": {AuditResultFail}}, []string{"t2"}, 1, true},
+		{"since", url.Values{"since": {"2026-01-02 00:00:00"}}, []string{"t3", "t2"}, 2, true},
+		{"credential until", url.Values{"credential": {"alice"}, "until": {"2026-01-01 23:59:59"}}, []string{"t1"}, 1, true},
+		{"sort by duration", url.Values{"sort": {"duration"}}, []string{"t2", "t3", "t1"}, 3, true},
+		{"page", url.Values{"limit": {"1"}, "offset": {"1"}}, []string{"t2"}, 3, true},
+		{"nothing matched", url.Values{"user": {"nobody"}}, []string{}, 0, true},
+		{"invalid time", url.Values{"since": {"yesterday"}}, nil, 0, false},
+		{"invalid sort key", url.Values{"sort": {"target"}}, nil, 0, false},
+	}
+	for _, testCase := range testCases {
+		var r = verifiedAPIRequest(http.MethodGet, "/audit/?"+testCase.Query.Encode(), "admin", nil)
+		var w = httptest.NewRecorder()
+		module.queryAuditRecords(w, r, nil)
+		var response struct {
+			ErrorCode int             `json:"error_code"`
+			Message   string          `json:"message"`
+			Data      json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: parse response fail: %s", testCase.Name, err.Error())
+		}
+		if !testCase.Valid {
+			if 0 == response.ErrorCode {
+				t.Fatalf("%s: invalid query accepted", testCase.Name)
+			}
+			continue
+		}
+		if 0 != response.ErrorCode {
+			t.Fatalf("%s: query fail: %s", testCase.Name, response.Message)
+		}
+		var found []AuditRecord
+		if err := json.Unmarshal(response.Data, &found); err != nil {
+			t.Fatalf("%s: parse records fail: %s", testCase.Name, err.Error())
+		}
+		var targets = make([]string, 0)
+		for _, record := range found {
+			targets = append(targets, record.Target)
+		}
+		if strings.Join(targets, ",") != strings.Join(testCase.Targets, ",") {
+			t.Fatalf("%s: records %v, %v expected", testCase.Name, targets, testCase.Targets)
+		}
+		if total := w.Header().Get(HeaderNameTotalCount); strconv.Itoa(testCase.Total) != total {
+			t.Fatalf("%s: total %s, %d expected", testCase.Name, total, testCase.Total)
+		}
+	}
+}
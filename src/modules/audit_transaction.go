@@ -0,0 +1,109 @@
+package modules
+
+import (
+	"fmt"
+	"github.com/project-nano/framework"
+	"time"
+)
+
+const (
+	messageTypeMask = 1<<framework.ResourceOffset - 1
+)
+
+// AuditSender : report responses to audit module before sending, so result of audited transaction available
+type AuditSender struct {
+	framework.MessageSender
+	Audit AuditModule
+}
+
+func (sender AuditSender) SendMessage(msg framework.Message, target string) error {
+	if framework.MessageResponse == msg.GetID()&messageTypeMask {
+		sender.Audit.ObserveResponse(target, msg)
+	}
+	return sender.MessageSender.SendMessage(msg, target)
+}
+
+// AuditExecutor : record state-changing transaction with requester, parameters and result
+type AuditExecutor struct {
+	Action   string
+	Executor framework.TransactionExecutor
+	Audit    AuditModule
+}
+
+func (executor *AuditExecutor) Execute(id framework.SessionID, request framework.Message,
+	incoming chan framework.Message, terminate chan bool) (err error) {
+	var start = time.Now()
+	var requester = request.GetSender()
+	var session = request.GetFromSession()
+	var isRequest = framework.MessageRequest == request.GetID()&messageTypeMask
+	if isRequest {
+		executor.Audit.BeginTransaction(requester, session)
+	}
+	err = executor.Executor.Execute(id, request, incoming, terminate)
+	var record = AuditRecord{
+		Time:       start.Format(TimeFormatLayout),
+		Source:     AuditSourceTransaction,
+		Sender:     requester,
+		Action:     executor.Action,
+		Target:     auditMessageTarget(request),
+		Parameters: auditMessageParameters(request),
+		Result:     AuditResultSuccess,
+		Duration:   int64(time.Since(start) / time.Millisecond),
+	}
+	record.User, _ = request.GetString(framework.ParamKeyUser)
+	record.Group, _ = request.GetString(framework.ParamKeyGroup)
+	if err != nil {
+		record.Result = AuditResultFail
+		record.Error = err.Error()
+	}
+	executor.Audit.FinishTransaction(requester, session, record)
+	return err
+}
+
+// auditMessageTarget : most specific resource named in message
+func auditMessageTarget(msg framework.Message) string {
+	for _, key := range []framework.ParamKey{framework.ParamKeyInstance, framework.ParamKeyGuest, framework.ParamKeyImage,
+		framework.ParamKeyID, framework.ParamKeyCell, framework.ParamKeyPool, framework.ParamKeyName} {
+		if value, err := msg.GetString(key); err == nil && "" != value {
+			return value
+		}
+	}
+	return ""
+}
+
+// auditMessageParameters : parameters named by type and key, secret redacted
+func auditMessageParameters(msg framework.Message) map[string]interface{} {
+	var parameters = map[string]interface{}{}
+	var name = func(valueType string, key framework.ParamKey) string {
+		return fmt.Sprintf("%s.%d", valueType, key)
+	}
+	for key, value := range msg.GetAllString() {
+		if framework.ParamKeySecret == key {
+			parameters[name("string", key)] = auditRedacted
+		} else {
+			parameters[name("string", key)] = value
+		}
+	}
+	for key, value := range msg.GetAllBoolean() {
+		parameters[name("bool", key)] = value
+	}
+	for key, value := range msg.GetAllInt() {
+		parameters[name("int", key)] = value
+	}
+	for key, value := range msg.GetAllUInt() {
+		parameters[name("uint", key)] = value
+	}
+	for key, value := range msg.GetAllFloat() {
+		parameters[name("float", key)] = value
+	}
+	for key, value := range msg.GetAllUIntArray() {
+		parameters[name("uint_array", key)] = value
+	}
+	for key, value := range msg.GetAllStringArray() {
+		parameters[name("string_array", key)] = value
+	}
+	if 0 == len(parameters) {
+		return nil
+	}
+	return parameters
+}
@@ -0,0 +1,127 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"github.com/project-nano/framework"
+	"testing"
+)
+
+// discardSender : messages dropped after reported to audit
+type discardSender struct {
+	framework.MessageSender
+}
+
+func (sender discardSender) SendMessage(msg framework.Message, target string) error {
+	return nil
+}
+
+// respondExecutor : send response of request through sender, then finish with error
+type respondExecutor struct {
+	sender  framework.MessageSender
+	respond bool
+	success bool
+	message string
+	err     error
+}
+
+func (executor *respondExecutor) Execute(id framework.SessionID, request framework.Message,
+	incoming chan framework.Message, terminate chan bool) error {
+	if executor.respond {
+		resp, _ := framework.CreateJsonMessage(framework.CreateGuestResponse)
+		resp.SetToSession(request.GetFromSession())
+		resp.SetSuccess(executor.success)
+		resp.SetError(executor.message)
+		if err := executor.sender.SendMessage(resp, request.GetSender()); err != nil {
+			return err
+		}
+	}
+	return executor.err
+}
+
+func TestAuditExecutor_Record(t *testing.T) {
+	var testCases = []struct {
+		Name    string
+		Respond bool
+		Success bool
+		Message string
+		Error   error
+		Result  string
+		Detail  string
+	}{
+		{"success", true, true, "", nil, AuditResultSuccess, ""},
+		{"failure responded", true, false, "not enough memory", nil, AuditResultFail, "not enough memory"},
+		{"executor fail", false, false, "", errors.New("timeout"), AuditResultFail, "timeout"},
+		{"response overrides error", true, false, "invalid pool", errors.New("abort"), AuditResultFail, "invalid pool"},
+	}
+	for index, testCase := range testCases {
+		var logger = auditLoggerForTest(t)
+		var sender = AuditSender{MessageSender: discardSender{}, Audit: logger}
+		var executor = &AuditExecutor{
+			Action: "create_guest",
+			Audit:  logger,
+			Executor: &respondExecutor{sender: sender, respond: testCase.Respond, success: testCase.Success,
+				message: testCase.Message, err: testCase.Error},
+		}
+		request, _ := framework.CreateJsonMessage(framework.CreateGuestRequest)
+		request.SetSender("api")
+		request.SetFromSession(framework.SessionID(index + 1))
+		request.SetString(framework.ParamKeyName, "guest")
+		request.SetString(framework.ParamKeyUser, "alice")
+		request.SetString(framework.ParamKeySecret, "plain secret")
+		if err := executor.Execute(1, request, nil, nil); err != testCase.Error {
+			t.Fatalf("%s: unexpected execute result %v", testCase.Name, err)
+		}
+		drainAuditCommands(logger)
+		records, err := readAuditFile(logger.auditFile, AuditFilter{})
+		if err != nil {
+			t.Fatalf("%s: read audit file fail: %s", testCase.Name, err.Error())
+		}
+		if 1 != len(records) {
+			t.Fatalf("%s: %d record(s) written", testCase.Name, len(records))
+		}
+		var record = records[0]
+		if AuditSourceTransaction != record.Source || "api" != record.Sender || "create_guest" != record.Action ||
+			"guest" != record.Target || "alice" != record.User {
+			t.Fatalf("%s: unexpected record %+v", testCase.Name, record)
+		}
+		if testCase.Result != record.Result || testCase.Detail != record.Error {
+			t.Fatalf("%s: result '%s' with error '%s', '%s' with '%s' expected", testCase.Name,
+				record.Result, record.Error, testCase.Result, testCase.Detail)
+		}
+		parameters, _ := record.Parameters.(map[string]interface{})
+		if secret := parameters[fmt.Sprintf("string.%d", framework.ParamKeySecret)]; auditRedacted != secret {
+			t.Fatalf("%s: secret recorded as %v", testCase.Name, secret)
+		}
+		if 0 != len(logger.responses) {
+			t.Fatalf("%s: %d response(s) still captured", testCase.Name, len(logger.responses))
+		}
+	}
+}
+
+func TestAuditSender_ObserveResponse(t *testing.T) {
+	var logger = auditLoggerForTest(t)
+	var sender = AuditSender{MessageSender: discardSender{}, Audit: logger}
+	logger.BeginTransaction("api", 1)
+	//response of other session and request to requester ignored
+	other, _ := framework.CreateJsonMessage(framework.CreateGuestResponse)
+	other.SetToSession(2)
+	sender.SendMessage(other, "api")
+	request, _ := framework.CreateJsonMessage(framework.CreateGuestRequest)
+	request.SetToSession(1)
+	sender.SendMessage(request, "api")
+	drainAuditCommands(logger)
+	if resp, exists := logger.responses[auditTransactionKey("api", 1)]; !exists || nil != resp {
+		t.Fatalf("unexpected response captured: %v", resp)
+	}
+	if 1 != len(logger.responses) {
+		t.Fatalf("%d transaction(s) captured", len(logger.responses))
+	}
+	resp, _ := framework.CreateJsonMessage(framework.CreateGuestResponse)
+	resp.SetToSession(1)
+	sender.SendMessage(resp, "api")
+	drainAuditCommands(logger)
+	if nil == logger.responses[auditTransactionKey("api", 1)] {
+		t.Fatalf("response of audited transaction not captured")
+	}
+}
@@ -0,0 +1,677 @@
+package modules
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/project-nano/core/store"
+	"github.com/project-nano/framework"
+	uuid "github.com/satori/go.uuid"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	CredentialDataFilename   = "credential.data"
+	CredentialSealFilename   = "credential.key"
+	CredentialDefaultOverlap = time.Hour
+	credentialKeyLength      = 48
+	credentialKeyPrefix      = "nano"
+	credentialSealKeyLength  = 32
+	credentialSealFilePerm   = 0600
+	credentialSealCheck      = "nano-credential-seal-check"
+)
+
+// ErrSealKeyMismatch : seal key of standby unable to open credentials replicated from active core
+var ErrSealKeyMismatch = errors.New("seal key mismatch")
+
+// CredentialConfig : role and scope of managed credential, same meaning as ApiCredential in config
+type CredentialConfig struct {
+	Role    string   `json:"role"`
+	Pools   []string `json:"pools,omitempty"`
+	Users   []string `json:"users,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+	Enabled bool     `json:"enabled"`
+}
+
+// CredentialKey : digest of prefixed key signs requests, so only saved sealed by the seal key in config path.
+// Digest only kept in memory, loaded from data before sealing introduced, never returned by query
+type CredentialKey struct {
+	Version     int    `json:"version"`
+	Digest      string `json:"digest,omitempty"`
+	Sealed      string `json:"sealed,omitempty"`
+	CreatedTime string `json:"created_time"`
+	ExpireTime  string `json:"expire_time,omitempty"`
+}
+
+type ManagedCredential struct {
+	ID string `json:"id"`
+	CredentialConfig
+	Keys         []CredentialKey `json:"keys"`
+	CreatedTime  string          `json:"created_time"`
+	ModifiedTime string          `json:"modified_time"`
+}
+
+// CredentialResult : plain key only available in result of create or rotate
+type CredentialResult struct {
+	Error          error
+	ID             string
+	Key            string
+	Version        int
+	Credential     ManagedCredential
+	CredentialList []ManagedCredential
+	Data           []byte
+	Revision       uint64
+}
+
+type CredentialModule interface {
+	QueryCredentials(respChan chan CredentialResult)
+	GetCredential(id string, respChan chan CredentialResult)
+	CreateCredential(id string, config CredentialConfig, expire time.Time, respChan chan CredentialResult)
+	ModifyCredential(id string, config CredentialConfig, respChan chan error)
+	RotateCredential(id string, expire time.Time, overlap time.Duration, respChan chan CredentialResult)
+	DeleteCredential(id string, respChan chan error)
+	ResolveCredential(id string, respChan chan CredentialResult)
+	ExportCredentialData(revision uint64, respChan chan CredentialResult)
+}
+
+type credentialCommandType int
+
+const (
+	credentialCmdQuery = iota
+	credentialCmdGet
+	credentialCmdCreate
+	credentialCmdModify
+	credentialCmdRotate
+	credentialCmdDelete
+	credentialCmdResolve
+	credentialCmdExport
+)
+
+type credentialCommand struct {
+	Type       credentialCommandType
+	ID         string
+	Config     CredentialConfig
+	Expire     time.Time
+	Overlap    time.Duration
+	Revision   uint64
+	ResultChan chan CredentialResult
+	ErrorChan  chan error
+}
+
+type credentialSavedData struct {
+	Credentials []ManagedCredential `json:"credentials,omitempty"`
+}
+
+// CredentialManager : API credentials managed at runtime, each credential holds several keys during rotation
+type CredentialManager struct {
+	credentials map[string]ManagedCredential
+	commands    chan credentialCommand
+	dataFile    string
+	sealFile    string
+	sealer      cipher.AEAD
+	sealCheck   string
+	revision    uint64 //increased by every save from 1, replicated when changed
+	metadata    store.Store
+	runner      *framework.SimpleRunner
+}
+
+// CreateCredentialManager : seal key in config path generated when absent,
+// standby core must hold the same seal key to verify replicated credentials
+func CreateCredentialManager(configPath, dataPath string) (manager *CredentialManager, err error) {
+	const (
+		DefaultQueueLength = 1 << 10
+	)
+	manager = &CredentialManager{revision: 1}
+	manager.credentials = map[string]ManagedCredential{}
+	manager.commands = make(chan credentialCommand, DefaultQueueLength)
+	manager.dataFile = filepath.Join(dataPath, CredentialDataFilename)
+	manager.sealFile = filepath.Join(configPath, CredentialSealFilename)
+	if manager.sealer, err = loadCredentialSealer(manager.sealFile); err != nil {
+		return
+	}
+	if manager.sealCheck, err = sealCredentialDigest(manager.sealer, credentialSealCheck); err != nil {
+		return
+	}
+	if manager.metadata, err = store.Open(GetConfigurator().GetMetadataStore(), manager.dataFile); err != nil {
+		return
+	}
+	if err = manager.loadData(); err != nil {
+		return
+	}
+	manager.runner = framework.CreateSimpleRunner(manager.routine)
+	return manager, nil
+}
+
+// GetSealCheck : constant sealed by seal key, standby verifies its own seal key by opening it
+func (manager *CredentialManager) GetSealCheck() string {
+	return manager.sealCheck
+}
+
+func (manager *CredentialManager) Start() error {
+	return manager.runner.Start()
+}
+
+func (manager *CredentialManager) Stop() error {
+	return manager.runner.Stop()
+}
+
+func (manager *CredentialManager) QueryCredentials(respChan chan CredentialResult) {
+	manager.commands <- credentialCommand{Type: credentialCmdQuery, ResultChan: respChan}
+}
+
+func (manager *CredentialManager) GetCredential(id string, respChan chan CredentialResult) {
+	manager.commands <- credentialCommand{Type: credentialCmdGet, ID: id, ResultChan: respChan}
+}
+
+// CreateCredential : new ID allocated when id is empty, zero expire means never expire
+func (manager *CredentialManager) CreateCredential(id string, config CredentialConfig, expire time.Time, respChan chan CredentialResult) {
+	manager.commands <- credentialCommand{Type: credentialCmdCreate, ID: id, Config: config, Expire: expire, ResultChan: respChan}
+}
+
+// ModifyCredential : change role, scope or enable status, keys unchanged
+func (manager *CredentialManager) ModifyCredential(id string, config CredentialConfig, respChan chan error) {
+	manager.commands <- credentialCommand{Type: credentialCmdModify, ID: id, Config: config, ErrorChan: respChan}
+}
+
+// RotateCredential : issue new key, current keys remain valid for overlap
+func (manager *CredentialManager) RotateCredential(id string, expire time.Time, overlap time.Duration, respChan chan CredentialResult) {
+	manager.commands <- credentialCommand{Type: credentialCmdRotate, ID: id, Expire: expire, Overlap: overlap, ResultChan: respChan}
+}
+
+func (manager *CredentialManager) DeleteCredential(id string, respChan chan error) {
+	manager.commands <- credentialCommand{Type: credentialCmdDelete, ID: id, ErrorChan: respChan}
+}
+
+// ResolveCredential : enabled credential with digests of unexpired keys, newest key first
+func (manager *CredentialManager) ResolveCredential(id string, respChan chan CredentialResult) {
+	manager.commands <- credentialCommand{Type: credentialCmdResolve, ID: id, ResultChan: respChan}
+}
+
+// ExportCredentialData : saved document for replication to standby core, data omitted when revision unchanged
+func (manager *CredentialManager) ExportCredentialData(revision uint64, respChan chan CredentialResult) {
+	manager.commands <- credentialCommand{Type: credentialCmdExport, Revision: revision, ResultChan: respChan}
+}
+
+func (manager *CredentialManager) routine(c framework.RoutineController) {
+	log.Printf("<credential> started, %d credential(s) available", len(manager.credentials))
+	for !c.IsStopping() {
+		select {
+		case <-c.GetNotifyChannel():
+			c.SetStopping()
+		case cmd := <-manager.commands:
+			manager.handleCommand(cmd)
+		}
+	}
+	if err := manager.metadata.Close(); err != nil {
+		log.Printf("<credential> close metadata store fail: %s", err.Error())
+	}
+	c.NotifyExit()
+	log.Println("<credential> stopped")
+}
+
+func (manager *CredentialManager) handleCommand(cmd credentialCommand) {
+	var err error
+	switch cmd.Type {
+	case credentialCmdQuery:
+		err = manager.handleQueryCredentials(cmd.ResultChan)
+	case credentialCmdGet:
+		err = manager.handleGetCredential(cmd.ID, cmd.ResultChan)
+	case credentialCmdCreate:
+		err = manager.handleCreateCredential(cmd.ID, cmd.Config, cmd.Expire, cmd.ResultChan)
+	case credentialCmdModify:
+		err = manager.handleModifyCredential(cmd.ID, cmd.Config, cmd.ErrorChan)
+	case credentialCmdRotate:
+		err = manager.handleRotateCredential(cmd.ID, cmd.Expire, cmd.Overlap, cmd.ResultChan)
+	case credentialCmdDelete:
+		err = manager.handleDeleteCredential(cmd.ID, cmd.ErrorChan)
+	case credentialCmdResolve:
+		err = manager.handleResolveCredential(cmd.ID, cmd.ResultChan)
+	case credentialCmdExport:
+		err = manager.handleExportCredentialData(cmd.Revision, cmd.ResultChan)
+	default:
+		log.Printf("<credential> unsupported command type %d", cmd.Type)
+		return
+	}
+	if err != nil {
+		log.Printf("<credential> handle command %d fail: %s", cmd.Type, err.Error())
+	}
+}
+
+func (manager *CredentialManager) handleQueryCredentials(respChan chan CredentialResult) (err error) {
+	var idList []string
+	for id := range manager.credentials {
+		idList = append(idList, id)
+	}
+	sort.Strings(idList)
+	var credentials = make([]ManagedCredential, 0)
+	for _, id := range idList {
+		credentials = append(credentials, manager.credentials[id].withoutDigest())
+	}
+	respChan <- CredentialResult{CredentialList: credentials}
+	return nil
+}
+
+func (manager *CredentialManager) handleGetCredential(id string, respChan chan CredentialResult) (err error) {
+	credential, exists := manager.credentials[id]
+	if !exists {
+		err = fmt.Errorf("invalid credential '%s'", id)
+		respChan <- CredentialResult{Error: err}
+		return
+	}
+	respChan <- CredentialResult{Credential: credential.withoutDigest()}
+	return nil
+}
+
+func (manager *CredentialManager) handleCreateCredential(id string, config CredentialConfig, expire time.Time,
+	respChan chan CredentialResult) (err error) {
+	if "" == id {
+		id = uuid.NewV4().String()
+	}
+	if err = validateCredentialID(id); err != nil {
+		respChan <- CredentialResult{Error: err}
+		return
+	}
+	if _, exists := manager.credentials[id]; exists {
+		err = fmt.Errorf("credential '%s' already exists", id)
+		respChan <- CredentialResult{Error: err}
+		return
+	}
+	if "" == config.Role {
+		err = errors.New("role required")
+		respChan <- CredentialResult{Error: err}
+		return
+	}
+	var now = time.Now()
+	if !expire.IsZero() && !expire.After(now) {
+		err = fmt.Errorf("expire time %s already passed", expire.Format(TimeFormatLayout))
+		respChan <- CredentialResult{Error: err}
+		return
+	}
+	var key string
+	var credentialKey CredentialKey
+	if key, credentialKey, err = generateCredentialKey(manager.sealer, 1, now, expire); err != nil {
+		respChan <- CredentialResult{Error: err}
+		return
+	}
+	var credential = ManagedCredential{
+		ID:               id,
+		CredentialConfig: config,
+		Keys:             []CredentialKey{credentialKey},
+		CreatedTime:      now.Format(TimeFormatLayout),
+		ModifiedTime:     now.Format(TimeFormatLayout),
+	}
+	manager.credentials[id] = credential
+	log.Printf("<credential> new credential '%s' created with role '%s'", id, config.Role)
+	respChan <- CredentialResult{ID: id, Key: key, Version: credentialKey.Version}
+	return manager.saveData()
+}
+
+func (manager *CredentialManager) handleModifyCredential(id string, config CredentialConfig, respChan chan error) (err error) {
+	credential, exists := manager.credentials[id]
+	if !exists {
+		err = fmt.Errorf("invalid credential '%s'", id)
+		respChan <- err
+		return
+	}
+	if "" == config.Role {
+		err = errors.New("role required")
+		respChan <- err
+		return
+	}
+	credential.CredentialConfig = config
+	credential.ModifiedTime = time.Now().Format(TimeFormatLayout)
+	manager.credentials[id] = credential
+	log.Printf("<credential> credential '%s' modified, role '%s', enabled %t", id, config.Role, config.Enabled)
+	respChan <- nil
+	return manager.saveData()
+}
+
+func (manager *CredentialManager) handleRotateCredential(id string, expire time.Time, overlap time.Duration,
+	respChan chan CredentialResult) (err error) {
+	credential, exists := manager.credentials[id]
+	if !exists {
+		err = fmt.Errorf("invalid credential '%s'", id)
+		respChan <- CredentialResult{Error: err}
+		return
+	}
+	if overlap < 0 {
+		err = fmt.Errorf("invalid overlap %s", overlap)
+		respChan <- CredentialResult{Error: err}
+		return
+	}
+	var now = time.Now()
+	if !expire.IsZero() && !expire.After(now) {
+		err = fmt.Errorf("expire time %s already passed", expire.Format(TimeFormatLayout))
+		respChan <- CredentialResult{Error: err}
+		return
+	}
+	//drop expired keys, valid keys expire after overlap
+	var overlapEnd = now.Add(overlap)
+	var keys []CredentialKey
+	var latestVersion = 0
+	for _, current := range credential.Keys {
+		if current.Version > latestVersion {
+			latestVersion = current.Version
+		}
+		if current.expired(now) {
+			continue
+		}
+		if 0 == overlap {
+			continue
+		}
+		if current.expireAfter(overlapEnd) {
+			current.ExpireTime = overlapEnd.Format(TimeFormatLayout)
+		}
+		keys = append(keys, current)
+	}
+	var key string
+	var credentialKey CredentialKey
+	if key, credentialKey, err = generateCredentialKey(manager.sealer, latestVersion+1, now, expire); err != nil {
+		respChan <- CredentialResult{Error: err}
+		return
+	}
+	credential.Keys = append(keys, credentialKey)
+	credential.ModifiedTime = now.Format(TimeFormatLayout)
+	manager.credentials[id] = credential
+	log.Printf("<credential> key of credential '%s' rotated to version %d, %d previous key(s) valid until %s",
+		id, credentialKey.Version, len(keys), overlapEnd.Format(TimeFormatLayout))
+	respChan <- CredentialResult{ID: id, Key: key, Version: credentialKey.Version}
+	return manager.saveData()
+}
+
+func (manager *CredentialManager) handleDeleteCredential(id string, respChan chan error) (err error) {
+	if _, exists := manager.credentials[id]; !exists {
+		err = fmt.Errorf("invalid credential '%s'", id)
+		respChan <- err
+		return
+	}
+	delete(manager.credentials, id)
+	log.Printf("<credential> credential '%s' deleted", id)
+	respChan <- nil
+	return manager.saveData()
+}
+
+func (manager *CredentialManager) handleResolveCredential(id string, respChan chan CredentialResult) (err error) {
+	credential, exists := manager.credentials[id]
+	if !exists {
+		err = fmt.Errorf("invalid API ID: %s", id)
+		respChan <- CredentialResult{Error: err}
+		return
+	}
+	if !credential.Enabled {
+		err = fmt.Errorf("credential '%s' disabled", id)
+		respChan <- CredentialResult{Error: err}
+		return
+	}
+	var now = time.Now()
+	var keys []CredentialKey
+	for _, key := range credential.Keys {
+		if "" == key.Digest {
+			//unsealed fail
+			continue
+		}
+		if !key.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	if 0 == len(keys) {
+		err = fmt.Errorf("all keys of credential '%s' expired", id)
+		respChan <- CredentialResult{Error: err}
+		return
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Version > keys[j].Version
+	})
+	credential.Keys = keys
+	respChan <- CredentialResult{Credential: credential}
+	return nil
+}
+
+func validateCredentialID(id string) error {
+	if strings.ContainsAny(id, "/,= ") {
+		return fmt.Errorf("invalid character in credential ID '%s'", id)
+	}
+	return nil
+}
+
+// generateCredentialKey : random key in hex, save sealed digest only
+func generateCredentialKey(sealer cipher.AEAD, version int, now, expire time.Time) (key string, credentialKey CredentialKey, err error) {
+	var buffer = make([]byte, credentialKeyLength)
+	if _, err = rand.Read(buffer); err != nil {
+		err = fmt.Errorf("generate key fail: %s", err.Error())
+		return
+	}
+	key = hex.EncodeToString(buffer)
+	credentialKey = CredentialKey{
+		Version:     version,
+		Digest:      DigestCredentialKey(key),
+		CreatedTime: now.Format(TimeFormatLayout),
+	}
+	if credentialKey.Sealed, err = sealCredentialDigest(sealer, credentialKey.Digest); err != nil {
+		return
+	}
+	if !expire.IsZero() {
+		credentialKey.ExpireTime = expire.Format(TimeFormatLayout)
+	}
+	return key, credentialKey, nil
+}
+
+// DigestCredentialKey : SHA256 of prefixed key in hex.
+// Prefixed key longer than HMAC block is hashed before signing, so digest signs request the same as key
+func DigestCredentialKey(key string) string {
+	var digest = sha256.Sum256([]byte(credentialKeyPrefix + key))
+	return hex.EncodeToString(digest[:])
+}
+
+// loadCredentialSealer : generate seal key with owner only permission when absent
+func loadCredentialSealer(sealFile string) (sealer cipher.AEAD, err error) {
+	if _, err = os.Stat(sealFile); !os.IsNotExist(err) {
+		return readCredentialSealer(sealFile)
+	}
+	var key = make([]byte, credentialSealKeyLength)
+	if _, err = rand.Read(key); err != nil {
+		err = fmt.Errorf("generate seal key fail: %s", err.Error())
+		return
+	}
+	if err = os.WriteFile(sealFile, []byte(hex.EncodeToString(key)), credentialSealFilePerm); err != nil {
+		err = fmt.Errorf("save seal key fail: %s", err.Error())
+		return
+	}
+	log.Printf("<credential> new seal key generated in '%s'", sealFile)
+	var block cipher.Block
+	if block, err = aes.NewCipher(key); err != nil {
+		return
+	}
+	return cipher.NewGCM(block)
+}
+
+func readCredentialSealer(sealFile string) (sealer cipher.AEAD, err error) {
+	var key []byte
+	if key, err = readCredentialSealKey(sealFile); err != nil {
+		return
+	}
+	var block cipher.Block
+	if block, err = aes.NewCipher(key); err != nil {
+		return
+	}
+	return cipher.NewGCM(block)
+}
+
+func readCredentialSealKey(sealFile string) (key []byte, err error) {
+	var data []byte
+	if data, err = os.ReadFile(sealFile); err != nil {
+		return
+	}
+	if key, err = hex.DecodeString(strings.TrimSpace(string(data))); err != nil {
+		err = fmt.Errorf("invalid seal key in '%s': %s", sealFile, err.Error())
+		return
+	}
+	if credentialSealKeyLength != len(key) {
+		err = fmt.Errorf("invalid seal key length %d in '%s'", len(key), sealFile)
+		return
+	}
+	if info, statError := os.Stat(sealFile); nil == statError && 0 != info.Mode().Perm()&0077 {
+		log.Printf("<credential> warning: seal key '%s' accessible by others, mode %s", sealFile, info.Mode().Perm())
+	}
+	return key, nil
+}
+
+// VerifyCredentialSeal : seal key in file must open check sealed by active core, never generated here
+func VerifyCredentialSeal(sealFile, check string) (err error) {
+	var sealer cipher.AEAD
+	if sealer, err = readCredentialSealer(sealFile); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: '%s' not found, copy it from active core", ErrSealKeyMismatch, sealFile)
+		}
+		return fmt.Errorf("%w: %s", ErrSealKeyMismatch, err.Error())
+	}
+	var plain string
+	if plain, err = openCredentialDigest(sealer, check); err != nil || credentialSealCheck != plain {
+		return fmt.Errorf("%w: '%s' differs from active core, copy it from active core", ErrSealKeyMismatch, sealFile)
+	}
+	return nil
+}
+
+func sealCredentialDigest(sealer cipher.AEAD, digest string) (sealed string, err error) {
+	var nonce = make([]byte, sealer.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		err = fmt.Errorf("generate nonce fail: %s", err.Error())
+		return
+	}
+	return hex.EncodeToString(sealer.Seal(nonce, nonce, []byte(digest), nil)), nil
+}
+
+func openCredentialDigest(sealer cipher.AEAD, sealed string) (digest string, err error) {
+	var data []byte
+	if data, err = hex.DecodeString(sealed); err != nil {
+		return
+	}
+	if len(data) < sealer.NonceSize() {
+		err = errors.New("sealed digest too short")
+		return
+	}
+	var plain []byte
+	if plain, err = sealer.Open(nil, data[:sealer.NonceSize()], data[sealer.NonceSize():], nil); err != nil {
+		err = errors.New("seal key mismatch")
+		return
+	}
+	return string(plain), nil
+}
+
+func (key CredentialKey) expired(now time.Time) bool {
+	if "" == key.ExpireTime {
+		return false
+	}
+	expire, err := time.ParseInLocation(TimeFormatLayout, key.ExpireTime, time.Local)
+	if err != nil {
+		return true
+	}
+	return !expire.After(now)
+}
+
+func (key CredentialKey) expireAfter(moment time.Time) bool {
+	if "" == key.ExpireTime {
+		return true
+	}
+	expire, err := time.ParseInLocation(TimeFormatLayout, key.ExpireTime, time.Local)
+	if err != nil {
+		return false
+	}
+	return expire.After(moment)
+}
+
+func (credential ManagedCredential) withoutDigest() ManagedCredential {
+	var keys = make([]CredentialKey, 0, len(credential.Keys))
+	for _, key := range credential.Keys {
+		key.Digest = ""
+		key.Sealed = ""
+		keys = append(keys, key)
+	}
+	credential.Keys = keys
+	return credential
+}
+
+func (manager *CredentialManager) handleExportCredentialData(revision uint64, respChan chan CredentialResult) (err error) {
+	if revision == manager.revision {
+		respChan <- CredentialResult{Revision: revision}
+		return nil
+	}
+	var data []byte
+	if data, err = json.Marshal(manager.buildSavedData()); err != nil {
+		respChan <- CredentialResult{Error: err}
+		return
+	}
+	respChan <- CredentialResult{Data: data, Revision: manager.revision}
+	return nil
+}
+
+// buildSavedData : digest removed, only sealed digest saved or replicated
+func (manager *CredentialManager) buildSavedData() (saved credentialSavedData) {
+	for _, credential := range manager.credentials {
+		var keys = make([]CredentialKey, 0, len(credential.Keys))
+		for _, key := range credential.Keys {
+			key.Digest = ""
+			keys = append(keys, key)
+		}
+		credential.Keys = keys
+		saved.Credentials = append(saved.Credentials, credential)
+	}
+	sort.Slice(saved.Credentials, func(i, j int) bool {
+		return saved.Credentials[i].ID < saved.Credentials[j].ID
+	})
+	return
+}
+
+func (manager *CredentialManager) saveData() error {
+	manager.revision++
+	return manager.metadata.Save(manager.buildSavedData())
+}
+
+func (manager *CredentialManager) loadData() (err error) {
+	var saved credentialSavedData
+	var result store.LoadResult
+	if result, err = manager.metadata.Load(&saved); err != nil {
+		return
+	}
+	if !result.Exists {
+		return nil
+	}
+	var sealed = 0
+	for _, credential := range saved.Credentials {
+		for index, key := range credential.Keys {
+			if "" == key.Sealed {
+				//plain digest saved by previous version
+				if key.Sealed, err = sealCredentialDigest(manager.sealer, key.Digest); err != nil {
+					return
+				}
+				sealed++
+			} else if key.Digest, err = openCredentialDigest(manager.sealer, key.Sealed); err != nil {
+				log.Printf("<credential> warning: key %d of credential '%s' unavailable, open with '%s' fail: %s",
+					key.Version, credential.ID, manager.sealFile, err.Error())
+				key.Digest = ""
+				err = nil
+			}
+			credential.Keys[index] = key
+		}
+		manager.credentials[credential.ID] = credential
+	}
+	log.Printf("<credential> %d credential(s) loaded from '%s'", len(saved.Credentials), manager.dataFile)
+	if 0 != sealed {
+		log.Printf("<credential> %d plain digest(s) sealed with '%s'", sealed, manager.sealFile)
+		return manager.metadata.Compact(manager.buildSavedData())
+	}
+	if result.Dirty {
+		return manager.metadata.Compact(manager.buildSavedData())
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package modules
+
+import (
+	"encoding/json"
+	"github.com/project-nano/core/store"
+	"path/filepath"
+	"time"
+)
+
+// resourceDataMigrations : forward migrations of resource data, append only,
+// version of a migration must equal to its index plus one
+var resourceDataMigrations = []store.Migration{
+	{
+		Version:     1,
+		Description: "fill admin, display, remote control and timestamps missing in system templates",
+		Upgrade:     upgradeTemplateDefaults,
+	},
+}
+
+func ResourceDataVersion() int {
+	return store.LatestVersion(resourceDataMigrations)
+}
+
+// CheckResourceDataUpgrade : migrations would run on resource data under data path, nothing modified
+func CheckResourceDataUpgrade(dataPath, backend string) (plan store.MigrationPlan, err error) {
+	var metadata store.Store
+	if metadata, err = store.OpenReadOnly(backend, filepath.Join(dataPath, ResourceDataFilename)); err != nil {
+		return
+	}
+	defer metadata.Close()
+	plan, err = store.PlanMigration(metadata, resourceDataMigrations)
+	return
+}
+
+// upgradeTemplateDefaults : templates stored by earlier releases may lack options required by current one
+func upgradeTemplateDefaults(sections map[string]json.RawMessage) (err error) {
+	const (
+		sectionName  = "system_templates"
+		linuxAdmin   = "root"
+		windowsAdmin = "Administrator"
+	)
+	section, exists := sections[sectionName]
+	if !exists {
+		return nil
+	}
+	var templates []SystemTemplate
+	if err = json.Unmarshal(section, &templates); err != nil {
+		return
+	}
+	var now = time.Now().Format(TimeFormatLayout)
+	for index, template := range templates {
+		if "" == template.Admin {
+			if SystemNameWindows == template.OperatingSystem {
+				template.Admin = windowsAdmin
+			} else {
+				template.Admin = linuxAdmin
+			}
+		}
+		if "" == template.Display {
+			template.Display = DisplayDriverVGA
+		}
+		if "" == template.Control {
+			template.Control = RemoteControlVNC
+		}
+		if "" == template.CreatedTime {
+			template.CreatedTime = now
+		}
+		if "" == template.ModifiedTime {
+			template.ModifiedTime = template.CreatedTime
+		}
+		templates[index] = template
+	}
+	if sections[sectionName], err = json.Marshal(templates); err != nil {
+		return
+	}
+	return nil
+}
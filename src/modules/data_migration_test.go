@@ -0,0 +1,101 @@
+package modules
+
+import (
+	"encoding/json"
+	"github.com/project-nano/core/store"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDataMigration_TemplateDefaults(t *testing.T) {
+	const (
+		storedTime = "2020-01-02 03:04:05"
+	)
+	var testCases = []struct {
+		Name     string
+		Stored   string
+		Expected SystemTemplateConfig
+		Created  string
+	}{
+		{"linux defaults", `{"id":"t1","name":"linux","operating_system":"linux"}`,
+			SystemTemplateConfig{Name: "linux", OperatingSystem: SystemNameLinux, Admin: "root", Display: DisplayDriverVGA, Control: RemoteControlVNC}, ""},
+		{"windows admin", `{"id":"t2","name":"windows","operating_system":"windows"}`,
+			SystemTemplateConfig{Name: "windows", OperatingSystem: SystemNameWindows, Admin: "Administrator", Display: DisplayDriverVGA, Control: RemoteControlVNC}, ""},
+		{"specified kept", `{"id":"t3","name":"custom","operating_system":"linux","admin":"nano","display":"qxl","control":"spice","created_time":"` + storedTime + `"}`,
+			SystemTemplateConfig{Name: "custom", OperatingSystem: SystemNameLinux, Admin: "nano", Display: DisplayDriverQXL, Control: RemoteControlSPICE}, storedTime},
+	}
+	for _, testCase := range testCases {
+		var sections = map[string]json.RawMessage{"system_templates": json.RawMessage("[" + testCase.Stored + "]")}
+		if err := upgradeTemplateDefaults(sections); err != nil {
+			t.Fatalf("%s: upgrade fail: %s", testCase.Name, err.Error())
+		}
+		var templates []SystemTemplate
+		if err := json.Unmarshal(sections["system_templates"], &templates); err != nil {
+			t.Fatalf("%s: parse templates fail: %s", testCase.Name, err.Error())
+		}
+		if 1 != len(templates) {
+			t.Fatalf("%s: %d template(s) upgraded", testCase.Name, len(templates))
+		}
+		var template = templates[0]
+		if template.SystemTemplateConfig != testCase.Expected {
+			t.Fatalf("%s: template %+v, %+v expected", testCase.Name, template.SystemTemplateConfig, testCase.Expected)
+		}
+		if "" == template.CreatedTime || template.ModifiedTime != template.CreatedTime {
+			t.Fatalf("%s: invalid timestamps '%s' / '%s'", testCase.Name, template.CreatedTime, template.ModifiedTime)
+		}
+		if "" != testCase.Created && template.CreatedTime != testCase.Created {
+			t.Fatalf("%s: created time '%s' modified", testCase.Name, template.CreatedTime)
+		}
+	}
+	var empty = map[string]json.RawMessage{}
+	if err := upgradeTemplateDefaults(empty); err != nil || 0 != len(empty) {
+		t.Fatalf("data without templates modified: %v, %v", empty, err)
+	}
+}
+
+func TestDataMigration_CheckUpgrade(t *testing.T) {
+	var testCases = []struct {
+		Name    string
+		Backend string
+		Stored  string
+		Exists  bool
+		Pending int
+	}{
+		{"nothing stored", store.BackendJSON, "", false, 0},
+		{"nothing stored in kv", store.BackendKV, "", false, 0},
+		{"unversioned", store.BackendJSON, `{"system_templates":[]}`, true, 1},
+		{"unversioned imported by kv", store.BackendKV, `{"system_templates":[]}`, true, 1},
+		{"up to date", store.BackendJSON, `{"schema_version":1}`, true, 0},
+	}
+	for _, testCase := range testCases {
+		var dataPath = t.TempDir()
+		var dataFile = filepath.Join(dataPath, ResourceDataFilename)
+		if "" != testCase.Stored {
+			if err := ioutil.WriteFile(dataFile, []byte(testCase.Stored), store.DefaultPerm); err != nil {
+				t.Fatalf("%s: write data fail: %s", testCase.Name, err.Error())
+			}
+		}
+		plan, err := CheckResourceDataUpgrade(dataPath, testCase.Backend)
+		if err != nil {
+			t.Fatalf("%s: check upgrade fail: %s", testCase.Name, err.Error())
+		}
+		if plan.Exists != testCase.Exists || len(plan.Pending) != testCase.Pending || ResourceDataVersion() != plan.Target {
+			t.Fatalf("%s: unexpected plan %+v", testCase.Name, plan)
+		}
+		entries, err := ioutil.ReadDir(dataPath)
+		if err != nil {
+			t.Fatalf("%s: read data path fail: %s", testCase.Name, err.Error())
+		}
+		var created []string
+		for _, entry := range entries {
+			if ResourceDataFilename != entry.Name() {
+				created = append(created, entry.Name())
+			}
+		}
+		if 0 != len(created) {
+			t.Fatalf("%s: '%s' created by check", testCase.Name, strings.Join(created, ","))
+		}
+	}
+}
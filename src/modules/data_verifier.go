@@ -0,0 +1,208 @@
+package modules
+
+import (
+	"fmt"
+	"github.com/project-nano/core/store"
+	"net"
+	"path/filepath"
+)
+
+const (
+	ResourceDataFilename = "resource.data"
+)
+
+// DataVerifyReport : result of offline consistency check on resource data
+type DataVerifyReport struct {
+	DataFile  string
+	Replayed  int
+	Discarded int
+	Summary   []string
+	Warnings  []string
+	Problems  []string
+}
+
+func (report *DataVerifyReport) problem(format string, args ...interface{}) {
+	report.Problems = append(report.Problems, fmt.Sprintf(format, args...))
+}
+
+func (report *DataVerifyReport) warning(format string, args ...interface{}) {
+	report.Warnings = append(report.Warnings, fmt.Sprintf(format, args...))
+}
+
+// VerifyResourceData : recover resource data from store under data path without compacting it,
+// then check references and address allocations
+func VerifyResourceData(dataPath, backend string) (report DataVerifyReport, err error) {
+	report.DataFile = filepath.Join(dataPath, ResourceDataFilename)
+	var metadata store.Store
+	if metadata, err = store.OpenReadOnly(backend, report.DataFile); err != nil {
+		return
+	}
+	defer metadata.Close()
+	var config ResourceData
+	var result store.LoadResult
+	if result, err = metadata.Load(&config); err != nil {
+		err = fmt.Errorf("load resource data fail: %s", err.Error())
+		return
+	}
+	if !result.Exists {
+		err = fmt.Errorf("no resource data available in '%s'", dataPath)
+		return
+	}
+	report.Replayed = result.Replayed
+	report.Discarded = result.Discarded
+	if 0 != result.Discarded {
+		report.warning("%d broken journal entry(s), will be discarded on next start", result.Discarded)
+	}
+	if latest := ResourceDataVersion(); config.SchemaVersion < latest {
+		report.warning("schema version %d, will be upgraded to %d on next start", config.SchemaVersion, latest)
+	} else if config.SchemaVersion > latest {
+		report.problem("schema version %d newer than supported version %d", config.SchemaVersion, latest)
+	}
+	if "" == config.Zone {
+		report.problem("zone name is empty")
+	}
+	var storagePools = map[string]bool{}
+	for _, storage := range config.StoragePools {
+		if storagePools[storage.Name] {
+			report.problem("duplicate storage pool '%s'", storage.Name)
+		}
+		storagePools[storage.Name] = true
+	}
+	var addressPools = map[string]bool{}
+	var allocatedAddresses = 0
+	for _, pool := range config.AddressPools {
+		if addressPools[pool.Name] {
+			report.problem("duplicate address pool '%s'", pool.Name)
+		}
+		addressPools[pool.Name] = true
+		allocatedAddresses += verifyAddressPool(pool, &report)
+	}
+	var cellPool = map[string]string{}
+	var poolNames = map[string]bool{}
+	for _, pool := range config.Pools {
+		if poolNames[pool.Name] {
+			report.problem("duplicate compute pool '%s'", pool.Name)
+		}
+		poolNames[pool.Name] = true
+		if "" != pool.Storage && !storagePools[pool.Storage] {
+			report.problem("compute pool '%s' refers to invalid storage pool '%s'", pool.Name, pool.Storage)
+		}
+		if "" != pool.Network && !addressPools[pool.Network] {
+			report.problem("compute pool '%s' refers to invalid address pool '%s'", pool.Name, pool.Network)
+		}
+		for cellName, cell := range pool.Cells {
+			if previous, exists := cellPool[cellName]; exists {
+				report.problem("cell '%s' belongs to both pool '%s' and '%s'", cellName, previous, pool.Name)
+			}
+			cellPool[cellName] = pool.Name
+			if err = VerifyCellLabels(cell.Labels); err != nil {
+				report.problem("cell '%s': %s", cellName, err.Error())
+				err = nil
+			}
+		}
+	}
+	var templateID = map[string]bool{}
+	for _, template := range config.SystemTemplates {
+		if templateID[template.ID] {
+			report.problem("duplicate system template '%s'", template.ID)
+		}
+		templateID[template.ID] = true
+	}
+	var policyID, policyName = map[string]bool{}, map[string]bool{}
+	for _, policy := range config.SecurityPolicyGroup {
+		if policyID[policy.ID] {
+			report.problem("duplicate security policy group '%s'", policy.ID)
+		}
+		if policyName[policy.Name] {
+			report.problem("duplicate security policy group name '%s'", policy.Name)
+		}
+		policyID[policy.ID] = true
+		policyName[policy.Name] = true
+	}
+	var groupID, groupName = map[string]bool{}, map[string]bool{}
+	var memberGroup = map[string]string{}
+	for _, group := range config.ServerGroups {
+		if groupID[group.ID] {
+			report.problem("duplicate server group '%s'", group.ID)
+		}
+		if groupName[group.Name] {
+			report.problem("duplicate server group name '%s'", group.Name)
+		}
+		groupID[group.ID] = true
+		groupName[group.Name] = true
+		for _, instanceID := range group.Members {
+			if previous, exists := memberGroup[instanceID]; exists {
+				report.problem("instance '%s' is member of both server group '%s' and '%s'", instanceID, previous, group.ID)
+			}
+			memberGroup[instanceID] = group.ID
+		}
+	}
+	for _, quota := range config.Quotas {
+		if QuotaScopeUser != quota.Scope && QuotaScopeGroup != quota.Scope {
+			report.problem("invalid scope '%s' of quota '%s'", quota.Scope, quota.Name)
+		}
+	}
+	for instanceID, selector := range config.InstanceSelectors {
+		if err = selector.Verify(); err != nil {
+			report.problem("selector of instance '%s': %s", instanceID, err.Error())
+			err = nil
+		}
+	}
+	for _, migration := range config.Migrations {
+		for _, cellName := range []string{migration.SourceCell, migration.TargetCell} {
+			if _, exists := cellPool[cellName]; !exists && !migration.Finished && "" == migration.Error {
+				report.warning("unfinished migration '%s' refers to cell '%s' not in any pool", migration.ID, cellName)
+			}
+		}
+	}
+	report.Summary = append(report.Summary,
+		fmt.Sprintf("zone '%s', %d compute pool(s), %d cell(s)", config.Zone, len(config.Pools), len(cellPool)),
+		fmt.Sprintf("%d storage pool(s), %d address pool(s), %d address(es) allocated", len(config.StoragePools), len(config.AddressPools), allocatedAddresses),
+		fmt.Sprintf("%d template(s), %d security policy group(s), %d server group(s), %d quota(s)",
+			len(config.SystemTemplates), len(config.SecurityPolicyGroup), len(config.ServerGroups), len(config.Quotas)),
+		fmt.Sprintf("%d migration(s), %d/%d/%d batch create/delete/stop task(s)",
+			len(config.Migrations), len(config.BatchCreateTasks), len(config.BatchDeleteTasks), len(config.BatchStopTasks)),
+		fmt.Sprintf("%d journal entry(s) replayed", result.Replayed))
+	return report, nil
+}
+
+func verifyAddressPool(pool addressPoolDefine, report *DataVerifyReport) (allocated int) {
+	var addressOwner = map[string]string{}
+	for _, addressRange := range pool.Ranges {
+		var start, end = net.ParseIP(addressRange.Start), net.ParseIP(addressRange.End)
+		if nil == start.To4() || nil == end.To4() {
+			report.problem("invalid range '%s' - '%s' in address pool '%s'", addressRange.Start, addressRange.End, pool.Name)
+			continue
+		}
+		var first, last = IPv4ToNumber(start), IPv4ToNumber(end)
+		if first > last {
+			report.problem("range '%s' - '%s' in address pool '%s' is reversed", addressRange.Start, addressRange.End, pool.Name)
+			continue
+		}
+		if _, err := IPv4ToMask(addressRange.Netmask); err != nil {
+			report.problem("invalid netmask '%s' of range '%s' in address pool '%s'", addressRange.Netmask, addressRange.Start, pool.Name)
+		}
+		if addressRange.Capacity != last-first+1 {
+			report.warning("capacity %d of range '%s' in address pool '%s' not match %d address(es)",
+				addressRange.Capacity, addressRange.Start, pool.Name, last-first+1)
+		}
+		for _, address := range addressRange.Allocated {
+			allocated++
+			var ip = net.ParseIP(address.Address)
+			if nil == ip.To4() {
+				report.problem("invalid address '%s' allocated in address pool '%s'", address.Address, pool.Name)
+				continue
+			}
+			if value := IPv4ToNumber(ip); value < first || value > last {
+				report.problem("address '%s' allocated out of range '%s' - '%s' in address pool '%s'",
+					address.Address, addressRange.Start, addressRange.End, pool.Name)
+			}
+			if previous, exists := addressOwner[address.Address]; exists {
+				report.problem("address '%s' in address pool '%s' allocated to both '%s' and '%s'",
+					address.Address, pool.Name, previous, address.Instance)
+			}
+			addressOwner[address.Address] = address.Instance
+		}
+	}
+	return
+}
@@ -0,0 +1,308 @@
+package modules
+
+import (
+	"fmt"
+	"github.com/project-nano/framework"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	EventGuestCreated             = "guest_created"
+	EventGuestDeleted             = "guest_deleted"
+	EventGuestStarted             = "guest_started"
+	EventGuestStopped             = "guest_stopped"
+	EventGuestUpdated             = "guest_updated"
+	EventGuestSystemReset         = "guest_system_reset"
+	EventAddressChanged           = "address_changed"
+	EventMediaAttached            = "media_attached"
+	EventMediaDetached            = "media_detached"
+	EventInstanceMigrated         = "instance_migrated"
+	EventCellAvailable            = "cell_available"
+	EventCellDisconnected         = "cell_disconnected"
+	EventCellFailover             = "cell_failover"
+	EventMigrationFinished        = "migration_finished"
+	EventBatchFinished            = "batch_finished"
+	EventFailoverCapacityLost     = "failover_capacity_lost"
+	EventFailoverCapacityRestored = "failover_capacity_restored"
+	batchOperationCreate          = "create"
+	batchOperationDelete          = "delete"
+	batchOperationStop            = "stop"
+	eventHistoryLimit             = 1 << 10
+	eventSubscriberCapacity       = 1 << 8
+)
+
+var clusterEventTypes = map[string]bool{
+	EventGuestCreated:             true,
+	EventGuestDeleted:             true,
+	EventGuestStarted:             true,
+	EventGuestStopped:             true,
+	EventGuestUpdated:             true,
+	EventGuestSystemReset:         true,
+	EventAddressChanged:           true,
+	EventMediaAttached:            true,
+	EventMediaDetached:            true,
+	EventInstanceMigrated:         true,
+	EventCellAvailable:            true,
+	EventCellDisconnected:         true,
+	EventCellFailover:             true,
+	EventMigrationFinished:        true,
+	EventBatchFinished:            true,
+	EventFailoverCapacityLost:     true,
+	EventFailoverCapacityRestored: true,
+}
+
+// ClusterEvent : event reported by cells, ID is the resume cursor of event stream
+type ClusterEvent struct {
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	Time       string            `json:"time"`
+	Pool       string            `json:"pool,omitempty"`
+	Cell       string            `json:"cell,omitempty"`
+	Guest      string            `json:"guest,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// EventFilter : empty field matches all
+type EventFilter struct {
+	Types map[string]bool
+	Pool  string
+	Cell  string
+	Guest string
+}
+
+// EventSubscription : Events closed when hub stopped or subscriber too slow to consume
+type EventSubscription struct {
+	ID     uint64
+	Events chan ClusterEvent
+}
+
+type EventResult struct {
+	Error        error
+	Subscription EventSubscription
+	Backlog      []ClusterEvent
+	Lost         bool
+}
+
+type EventModule interface {
+	PublishEvent(event ClusterEvent)
+	SubscribeEvents(filter EventFilter, cursor string, respChan chan EventResult)
+	UnsubscribeEvents(subscription uint64)
+}
+
+type eventCommandType int
+
+const (
+	eventCmdPublish = iota
+	eventCmdSubscribe
+	eventCmdUnsubscribe
+)
+
+type eventCommand struct {
+	Type         eventCommandType
+	Event        ClusterEvent
+	Filter       EventFilter
+	Cursor       string
+	Subscription uint64
+	ResultChan   chan EventResult
+}
+
+type eventSubscriber struct {
+	filter EventFilter
+	events chan ClusterEvent
+}
+
+// EventHub : keep recent events in memory and fan out to stream subscribers.
+// sequence restarts with core, so cursor carries the epoch of hub
+type EventHub struct {
+	epoch          string
+	sequence       uint64
+	history        []ClusterEvent
+	subscribers    map[uint64]eventSubscriber
+	nextSubscriber uint64
+	commands       chan eventCommand
+	runner         *framework.SimpleRunner
+}
+
+func CreateEventHub() (hub *EventHub, err error) {
+	const (
+		DefaultQueueLength = 1 << 10
+	)
+	hub = &EventHub{}
+	hub.epoch = strconv.FormatInt(time.Now().UnixNano(), 36)
+	hub.subscribers = map[uint64]eventSubscriber{}
+	hub.commands = make(chan eventCommand, DefaultQueueLength)
+	hub.runner = framework.CreateSimpleRunner(hub.routine)
+	return hub, nil
+}
+
+func ParseEventTypes(value string) (types map[string]bool, err error) {
+	types = map[string]bool{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if "" == name {
+			continue
+		}
+		if !clusterEventTypes[name] {
+			err = fmt.Errorf("invalid event type '%s'", name)
+			return
+		}
+		types[name] = true
+	}
+	return types, nil
+}
+
+func (filter EventFilter) Match(event ClusterEvent) bool {
+	if 0 != len(filter.Types) && !filter.Types[event.Type] {
+		return false
+	}
+	if "" != filter.Pool && filter.Pool != event.Pool {
+		return false
+	}
+	if "" != filter.Cell && filter.Cell != event.Cell {
+		return false
+	}
+	if "" != filter.Guest && filter.Guest != event.Guest {
+		return false
+	}
+	return true
+}
+
+func (hub *EventHub) Start() error {
+	return hub.runner.Start()
+}
+
+func (hub *EventHub) Stop() error {
+	return hub.runner.Stop()
+}
+
+func (hub *EventHub) PublishEvent(event ClusterEvent) {
+	hub.commands <- eventCommand{Type: eventCmdPublish, Event: event}
+}
+
+// SubscribeEvents : events after cursor returned as backlog, Lost set when some of them already dropped from history
+func (hub *EventHub) SubscribeEvents(filter EventFilter, cursor string, respChan chan EventResult) {
+	hub.commands <- eventCommand{Type: eventCmdSubscribe, Filter: filter, Cursor: cursor, ResultChan: respChan}
+}
+
+func (hub *EventHub) UnsubscribeEvents(subscription uint64) {
+	hub.commands <- eventCommand{Type: eventCmdUnsubscribe, Subscription: subscription}
+}
+
+func (hub *EventHub) routine(c framework.RoutineController) {
+	log.Println("<event> started")
+	for !c.IsStopping() {
+		select {
+		case <-c.GetNotifyChannel():
+			c.SetStopping()
+		case cmd := <-hub.commands:
+			hub.handleCommand(cmd)
+		}
+	}
+	for id, subscriber := range hub.subscribers {
+		close(subscriber.events)
+		delete(hub.subscribers, id)
+	}
+	c.NotifyExit()
+	log.Println("<event> stopped")
+}
+
+func (hub *EventHub) handleCommand(cmd eventCommand) {
+	var err error
+	switch cmd.Type {
+	case eventCmdPublish:
+		hub.handlePublishEvent(cmd.Event)
+	case eventCmdSubscribe:
+		err = hub.handleSubscribeEvents(cmd.Filter, cmd.Cursor, cmd.ResultChan)
+	case eventCmdUnsubscribe:
+		hub.handleUnsubscribeEvents(cmd.Subscription)
+	default:
+		log.Printf("<event> unsupported command type %d", cmd.Type)
+	}
+	if err != nil {
+		log.Printf("<event> handle command %d fail: %s", cmd.Type, err.Error())
+	}
+}
+
+func (hub *EventHub) handlePublishEvent(event ClusterEvent) {
+	hub.sequence++
+	event.ID = fmt.Sprintf("%s-%d", hub.epoch, hub.sequence)
+	event.Time = time.Now().Format(TimeFormatLayout)
+	hub.history = append(hub.history, event)
+	if len(hub.history) > eventHistoryLimit {
+		hub.history = hub.history[len(hub.history)-eventHistoryLimit:]
+	}
+	for id, subscriber := range hub.subscribers {
+		if !subscriber.filter.Match(event) {
+			continue
+		}
+		select {
+		case subscriber.events <- event:
+		default:
+			//subscriber resumes from its last event after reconnect
+			log.Printf("<event> subscriber %d too slow, disconnected", id)
+			close(subscriber.events)
+			delete(hub.subscribers, id)
+		}
+	}
+}
+
+func (hub *EventHub) handleSubscribeEvents(filter EventFilter, cursor string, respChan chan EventResult) (err error) {
+	var result EventResult
+	if "" != cursor {
+		var offset int
+		if offset, result.Lost, err = hub.locateCursor(cursor); err != nil {
+			respChan <- EventResult{Error: err}
+			return
+		}
+		for _, event := range hub.history[offset:] {
+			if filter.Match(event) {
+				result.Backlog = append(result.Backlog, event)
+			}
+		}
+	}
+	hub.nextSubscriber++
+	var subscriber = eventSubscriber{filter: filter, events: make(chan ClusterEvent, eventSubscriberCapacity)}
+	hub.subscribers[hub.nextSubscriber] = subscriber
+	result.Subscription = EventSubscription{ID: hub.nextSubscriber, Events: subscriber.events}
+	respChan <- result
+	return nil
+}
+
+func (hub *EventHub) handleUnsubscribeEvents(subscription uint64) {
+	subscriber, exists := hub.subscribers[subscription]
+	if !exists {
+		return
+	}
+	close(subscriber.events)
+	delete(hub.subscribers, subscription)
+}
+
+// locateCursor : offset in history of the first event after cursor
+func (hub *EventHub) locateCursor(cursor string) (offset int, lost bool, err error) {
+	var separator = strings.LastIndexByte(cursor, '-')
+	if -1 == separator {
+		err = fmt.Errorf("invalid cursor '%s'", cursor)
+		return
+	}
+	var sequence uint64
+	if sequence, err = strconv.ParseUint(cursor[separator+1:], 10, 64); err != nil {
+		err = fmt.Errorf("invalid cursor '%s'", cursor)
+		return
+	}
+	if cursor[:separator] != hub.epoch {
+		//issued before core restarted
+		return 0, true, nil
+	}
+	if sequence > hub.sequence {
+		err = fmt.Errorf("invalid cursor '%s'", cursor)
+		return
+	}
+	var first = hub.sequence - uint64(len(hub.history)) + 1
+	if sequence+1 < first {
+		return 0, true, nil
+	}
+	return int(sequence + 1 - first), false, nil
+}
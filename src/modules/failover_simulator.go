@@ -0,0 +1,388 @@
+package modules
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultFailoverTolerance      = 1
+	failoverCapacityCheckInterval = time.Second * 30
+	maxFailoverScenarios          = 64
+)
+
+// simulateFailover : build the plan as handleBuildFailoverPlan does when cells lost,
+// on a copy of cells, so no status of manager changed
+func (manager *ResourceManager) simulateFailover(poolName string, lost []string) (preview FailoverPreview, err error) {
+	pool, exists := manager.pools[poolName]
+	if !exists {
+		err = fmt.Errorf("invalid compute pool '%s'", poolName)
+		return
+	}
+	var cells = map[string]ManagedComputeCell{}
+	for cellName, _ := range pool.Cells {
+		cell, exists := manager.cells[cellName]
+		if !exists {
+			err = fmt.Errorf("invalid cell '%s' in pool '%s'", cellName, poolName)
+			return
+		}
+		var instances = map[string]bool{}
+		for instanceID, flag := range cell.Instances {
+			instances[instanceID] = flag
+		}
+		cell.Instances = instances
+		cells[cellName] = cell
+	}
+	var lostInstances = map[string]bool{}
+	var idList []string
+	for _, cellName := range lost {
+		cell, exists := cells[cellName]
+		if !exists {
+			err = fmt.Errorf("cell '%s' not in pool '%s'", cellName, poolName)
+			return
+		}
+		cell.Alive = false
+		cell.Enabled = false
+		cells[cellName] = cell
+		for instanceID, _ := range cell.Instances {
+			lostInstances[instanceID] = true
+			idList = append(idList, instanceID)
+		}
+	}
+	sort.Stable(sort.StringSlice(idList))
+	preview.Cell = strings.Join(lost, ",")
+	preview.Plan = map[string][]string{}
+	var relocated = map[string]string{}
+	for _, instanceID := range idList {
+		ins, exists := manager.instances[instanceID]
+		if !exists {
+			err = fmt.Errorf("invalid instance '%s'", instanceID)
+			return
+		}
+		var targetName string
+		var constraint placementConstraint
+		if constraint, err = manager.instanceConstraint(instanceID, lostInstances, relocated); err != nil {
+			return
+		}
+		if !constraint.isEmpty() {
+			targetName, _ = manager.selectCellIn(cells, poolName, ins.InstanceResource, false, constraint)
+		}
+		if "" == targetName {
+			if targetName, err = manager.selectCellIn(cells, poolName, ins.InstanceResource, false, placementConstraint{}); err != nil {
+				preview.Unplaceable = append(preview.Unplaceable, instanceID)
+				err = nil
+				continue
+			}
+		}
+		relocated[instanceID] = targetName
+		var target = cells[targetName]
+		target.Instances[instanceID] = false
+		cells[targetName] = target
+		preview.Plan[targetName] = append(preview.Plan[targetName], instanceID)
+	}
+	var names []string
+	for cellName, cell := range cells {
+		if cell.Alive && cell.Enabled {
+			names = append(names, cellName)
+		}
+	}
+	sort.Stable(sort.StringSlice(names))
+	for _, cellName := range names {
+		var candidate placementCandidate
+		if candidate, err = manager.buildPlacementCandidate(cells[cellName], pool.PoolOvercommit); err != nil {
+			return
+		}
+		var usage = FailoverCellUsage{
+			Name:            cellName,
+			Instances:       candidate.Instances,
+			AllocatedCores:  candidate.AllocatedCores,
+			CoreLimit:       candidate.CoreLimit,
+			AllocatedMemory: candidate.AllocatedMemory,
+			MemoryLimit:     candidate.MemoryLimit,
+		}
+		if 0 != usage.CoreLimit {
+			usage.CoreRatio = float64(usage.AllocatedCores) * 100 / float64(usage.CoreLimit)
+		}
+		if 0 != usage.MemoryLimit {
+			usage.MemoryRatio = float64(usage.AllocatedMemory) * 100 / float64(usage.MemoryLimit)
+		}
+		preview.Cells = append(preview.Cells, usage)
+	}
+	return preview, nil
+}
+
+// handlePreviewFailover : preview for one cell, or every alive cell in pool when cell name omitted
+func (manager *ResourceManager) handlePreviewFailover(poolName, cellName string, respChan chan ResourceResult) (err error) {
+	pool, exists := manager.pools[poolName]
+	if !exists {
+		err = fmt.Errorf("invalid compute pool '%s'", poolName)
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	var targets []string
+	if "" != cellName {
+		if !pool.Cells[cellName] {
+			err = fmt.Errorf("cell '%s' not in pool '%s'", cellName, poolName)
+			respChan <- ResourceResult{Error: err}
+			return err
+		}
+		targets = []string{cellName}
+	} else {
+		for name, _ := range pool.Cells {
+			if cell, exists := manager.cells[name]; exists && cell.Alive {
+				targets = append(targets, name)
+			}
+		}
+		sort.Stable(sort.StringSlice(targets))
+	}
+	var result ResourceResult
+	for _, target := range targets {
+		var preview FailoverPreview
+		if preview, err = manager.simulateFailover(poolName, []string{target}); err != nil {
+			respChan <- ResourceResult{Error: err}
+			return err
+		}
+		result.FailoverPreviewList = append(result.FailoverPreviewList, preview)
+	}
+	respChan <- result
+	return nil
+}
+
+func headroomToString(headroom int64) string {
+	if headroom < 0 {
+		return "-" + bytesToString(uint64(-headroom))
+	}
+	return bytesToString(uint64(headroom))
+}
+
+func defaultFailoverCapacity() PoolFailoverCapacity {
+	return PoolFailoverCapacity{Tolerance: DefaultFailoverTolerance}
+}
+
+// failoverScenarios : every combination of tolerance cells, or the most allocated cells when combinations too many
+func failoverScenarios(cells []string, allocated map[string]uint64, tolerance int) (scenarios [][]string) {
+	var combinations = 1
+	for i := 0; i < tolerance; i++ {
+		combinations = combinations * (len(cells) - i) / (i + 1)
+		if combinations > maxFailoverScenarios {
+			var sorted = make([]string, len(cells))
+			copy(sorted, cells)
+			sort.SliceStable(sorted, func(i, j int) bool {
+				return allocated[sorted[i]] > allocated[sorted[j]]
+			})
+			return [][]string{sorted[:tolerance]}
+		}
+	}
+	var current []string
+	var walk func(start int)
+	walk = func(start int) {
+		if len(current) == tolerance {
+			var scenario = make([]string, tolerance)
+			copy(scenario, current)
+			scenarios = append(scenarios, scenario)
+			return
+		}
+		for index := start; index < len(cells); index++ {
+			current = append(current, cells[index])
+			walk(index + 1)
+			current = current[:len(current)-1]
+		}
+	}
+	walk(0)
+	return scenarios
+}
+
+// evaluateFailoverCapacity : simulate losing tolerance cells in every scenario, keep the worst one
+func (manager *ResourceManager) evaluateFailoverCapacity(poolName string) (status FailoverCapacityStatus, err error) {
+	pool, exists := manager.pools[poolName]
+	if !exists {
+		err = fmt.Errorf("invalid compute pool '%s'", poolName)
+		return
+	}
+	status.Config = pool.FailoverCapacity
+	status.Enabled = pool.Failover
+	status.CheckTime = time.Now().Format(TimeFormatLayout)
+	var cells []string
+	var allocated = map[string]uint64{}
+	for cellName, _ := range pool.Cells {
+		cell, exists := manager.cells[cellName]
+		if !exists || !cell.Alive {
+			continue
+		}
+		cells = append(cells, cellName)
+		for instanceID, _ := range cell.Instances {
+			if ins, exists := manager.instances[instanceID]; exists {
+				allocated[cellName] += uint64(ins.Memory)
+			}
+		}
+	}
+	sort.Stable(sort.StringSlice(cells))
+	var tolerance = int(pool.FailoverCapacity.Tolerance)
+	if 0 == tolerance {
+		tolerance = DefaultFailoverTolerance
+	}
+	if tolerance >= len(cells) {
+		//no cell survives
+		status.WorstCase = cells
+		for _, cellName := range cells {
+			status.Unplaceable += len(manager.cells[cellName].Instances)
+		}
+		status.Guaranteed = 0 == status.Unplaceable
+		return status, nil
+	}
+	var first = true
+	for _, lost := range failoverScenarios(cells, allocated, tolerance) {
+		var preview FailoverPreview
+		if preview, err = manager.simulateFailover(poolName, lost); err != nil {
+			return
+		}
+		var cores, memory int64
+		var overloaded = false
+		for _, usage := range preview.Cells {
+			cores += int64(usage.CoreLimit) - int64(usage.AllocatedCores)
+			memory += int64(usage.MemoryLimit) - int64(usage.AllocatedMemory)
+			if usage.AllocatedCores > usage.CoreLimit || usage.AllocatedMemory > usage.MemoryLimit {
+				overloaded = true
+			}
+		}
+		var unplaceable = len(preview.Unplaceable)
+		var worse = first || unplaceable > status.Unplaceable ||
+			(unplaceable == status.Unplaceable && memory < status.HeadroomMemory)
+		if worse {
+			status.WorstCase = lost
+			status.Unplaceable = unplaceable
+			status.HeadroomCores = cores
+			status.HeadroomMemory = memory
+			status.Guaranteed = 0 == unplaceable && !overloaded
+		} else if overloaded {
+			status.Guaranteed = false
+		}
+		first = false
+	}
+	return status, nil
+}
+
+// checkFailoverCapacity : evaluate pools with failover enabled, warn and publish event when guarantee lost or restored
+func (manager *ResourceManager) checkFailoverCapacity() {
+	for poolName, pool := range manager.pools {
+		if !pool.Failover {
+			delete(manager.failoverCapacity, poolName)
+			continue
+		}
+		status, err := manager.evaluateFailoverCapacity(poolName)
+		if err != nil {
+			log.Printf("<resource_manager> warning: check failover capacity of pool '%s' fail: %s", poolName, err.Error())
+			continue
+		}
+		previous, exists := manager.failoverCapacity[poolName]
+		if !status.Guaranteed && (!exists || previous.Guaranteed) {
+			log.Printf("<resource_manager> warning: failover capacity of pool '%s' lost, losing cell(s) %s leaves %d instance(s) unplaceable, headroom %d cores / %s memory",
+				poolName, strings.Join(status.WorstCase, ","), status.Unplaceable, status.HeadroomCores, headroomToString(status.HeadroomMemory))
+			manager.publishFailoverCapacity(EventFailoverCapacityLost, poolName, status)
+		} else if status.Guaranteed && exists && !previous.Guaranteed {
+			log.Printf("<resource_manager> failover capacity of pool '%s' restored", poolName)
+			manager.publishFailoverCapacity(EventFailoverCapacityRestored, poolName, status)
+		}
+		manager.failoverCapacity[poolName] = status
+	}
+}
+
+func (manager *ResourceManager) publishFailoverCapacity(eventType, poolName string, status FailoverCapacityStatus) {
+	if nil == manager.events {
+		return
+	}
+	manager.events.PublishEvent(ClusterEvent{Type: eventType, Pool: poolName, Attributes: map[string]string{
+		"worst_case":      strings.Join(status.WorstCase, ","),
+		"unplaceable":     strconv.Itoa(status.Unplaceable),
+		"headroom_cores":  strconv.FormatInt(status.HeadroomCores, 10),
+		"headroom_memory": strconv.FormatInt(status.HeadroomMemory, 10),
+	}})
+}
+
+// verifyFailoverCapacity : check guarantee with new instance placed in target cell,
+// simulated on copies of cells and instances, so no status of manager changed
+func (manager *ResourceManager) verifyFailoverCapacity(poolName, cellName string, instance InstanceStatus) (err error) {
+	pool, exists := manager.pools[poolName]
+	if !exists {
+		return fmt.Errorf("invalid compute pool '%s'", poolName)
+	}
+	if !pool.Failover || !pool.FailoverCapacity.Enforce {
+		return nil
+	}
+	cell, exists := manager.cells[cellName]
+	if !exists {
+		return fmt.Errorf("invalid cell '%s'", cellName)
+	}
+	previous, exists := manager.failoverCapacity[poolName]
+	if exists && !previous.Guaranteed {
+		//already broken, don't block allocation
+		return nil
+	}
+	var instances = make(map[string]InstanceStatus, len(manager.instances)+1)
+	for instanceID, status := range manager.instances {
+		instances[instanceID] = status
+	}
+	instances[instance.ID] = instance
+	var members = map[string]bool{instance.ID: true}
+	for instanceID, flag := range cell.Instances {
+		members[instanceID] = flag
+	}
+	cell.Instances = members
+	var cells = make(map[string]ManagedComputeCell, len(manager.cells))
+	for name, current := range manager.cells {
+		cells[name] = current
+	}
+	cells[cellName] = cell
+	var simulated = *manager
+	simulated.instances = instances
+	simulated.cells = cells
+	status, err := simulated.evaluateFailoverCapacity(poolName)
+	if err != nil {
+		return err
+	}
+	if !status.Guaranteed {
+		return fmt.Errorf("allocation breaks failover capacity of pool '%s', losing cell(s) %s leaves %d instance(s) unplaceable",
+			poolName, strings.Join(status.WorstCase, ","), status.Unplaceable)
+	}
+	return nil
+}
+
+func (manager *ResourceManager) handleGetFailoverCapacity(poolName string, respChan chan ResourceResult) (err error) {
+	var status FailoverCapacityStatus
+	if status, err = manager.evaluateFailoverCapacity(poolName); err != nil {
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	respChan <- ResourceResult{FailoverCapacity: status}
+	return nil
+}
+
+func (manager *ResourceManager) handleModifyFailoverCapacity(poolName string, config PoolFailoverCapacity, respChan chan error) (err error) {
+	pool, exists := manager.pools[poolName]
+	if !exists {
+		err = fmt.Errorf("invalid compute pool '%s'", poolName)
+		respChan <- err
+		return err
+	}
+	if 0 == config.Tolerance {
+		err = fmt.Errorf("invalid failover tolerance %d", config.Tolerance)
+		respChan <- err
+		return err
+	}
+	if pool.FailoverCapacity == config {
+		err = fmt.Errorf("no need to change failover capacity of pool '%s'", poolName)
+		respChan <- err
+		return err
+	}
+	pool.FailoverCapacity = config
+	manager.pools[poolName] = pool
+	delete(manager.failoverCapacity, poolName)
+	log.Printf("<resource_manager> failover capacity of pool '%s' changed, tolerance %d cell(s), enforce %t",
+		poolName, config.Tolerance, config.Enforce)
+	respChan <- nil
+	return manager.saveConfig()
+}
@@ -0,0 +1,125 @@
+package modules
+
+import (
+	"testing"
+)
+
+type recordedEvents struct {
+	EventModule
+	published []ClusterEvent
+}
+
+func (events *recordedEvents) PublishEvent(event ClusterEvent) {
+	events.published = append(events.published, event)
+}
+
+// failoverManagerForTest : pool 'p' of two cells with 4 cores and 4 GiB, guest 'i1' of 2 cores and 2 GiB in 'c1'
+func failoverManagerForTest(events EventModule) *ResourceManager {
+	const (
+		gib = 1 << 30
+	)
+	var manager = &ResourceManager{events: events}
+	var pool ManagedComputePool
+	pool.Name = "p"
+	pool.Failover = true
+	pool.PoolOvercommit = PoolOvercommit{CPURatio: 1, MemoryRatio: 1, DiskRatio: 1}
+	pool.FailoverCapacity = PoolFailoverCapacity{Tolerance: 1, Enforce: true}
+	pool.Cells = map[string]bool{"c1": true, "c2": true}
+	manager.pools = map[string]ManagedComputePool{"p": pool}
+	manager.cells = map[string]ManagedComputeCell{}
+	for _, name := range []string{"c1", "c2"} {
+		var cell = computeCellForTest(name, nil)
+		cell.Pool = "p"
+		cell.Alive = true
+		cell.Enabled = true
+		cell.Cores = 4
+		cell.Memory = 4 * gib
+		cell.Instances = map[string]bool{}
+		manager.cells[name] = cell
+	}
+	manager.cells["c1"].Instances["i1"] = true
+	manager.instances = map[string]InstanceStatus{"i1": instanceForTest("i1", "c1", 2, 2*gib)}
+	manager.failoverCapacity = map[string]FailoverCapacityStatus{}
+	return manager
+}
+
+func instanceForTest(id, cell string, cores uint, memory uint) (instance InstanceStatus) {
+	instance.ID = id
+	instance.Name = id
+	instance.Pool = "p"
+	instance.Cell = cell
+	instance.Cores = cores
+	instance.Memory = memory
+	return
+}
+
+func TestFailoverCapacity_Verify(t *testing.T) {
+	const (
+		gib = 1 << 30
+	)
+	var testCases = []struct {
+		Name     string
+		Cores    uint
+		Memory   uint
+		Accepted bool
+	}{
+		{"survivor fits both", 2, 2 * gib, true},
+		{"survivor overloaded", 3, 3 * gib, false},
+	}
+	for _, testCase := range testCases {
+		var manager = failoverManagerForTest(nil)
+		var instance = instanceForTest("new", "c2", testCase.Cores, testCase.Memory)
+		var err = manager.verifyFailoverCapacity("p", "c2", instance)
+		if (nil == err) != testCase.Accepted {
+			t.Fatalf("%s: unexpected verify result %v", testCase.Name, err)
+		}
+		if _, exists := manager.instances["new"]; exists || 1 != len(manager.instances) {
+			t.Fatalf("%s: instances of manager changed: %v", testCase.Name, manager.instances)
+		}
+		if 0 != len(manager.cells["c2"].Instances) || 1 != len(manager.cells["c1"].Instances) {
+			t.Fatalf("%s: instances of cells changed", testCase.Name)
+		}
+	}
+}
+
+func TestFailoverCapacity_Events(t *testing.T) {
+	const (
+		gib = 1 << 30
+	)
+	var events = &recordedEvents{}
+	var manager = failoverManagerForTest(events)
+	var steps = []struct {
+		Name     string
+		Overload bool
+		Expected string
+	}{
+		{"guaranteed", false, ""},
+		{"capacity lost", true, EventFailoverCapacityLost},
+		{"still lost", true, ""},
+		{"capacity restored", false, EventFailoverCapacityRestored},
+	}
+	for _, step := range steps {
+		if step.Overload {
+			manager.instances["i2"] = instanceForTest("i2", "c2", 3, 3*gib)
+			manager.cells["c2"].Instances["i2"] = true
+		} else {
+			delete(manager.instances, "i2")
+			delete(manager.cells["c2"].Instances, "i2")
+		}
+		events.published = nil
+		manager.checkFailoverCapacity()
+		if "" == step.Expected {
+			if 0 != len(events.published) {
+				t.Fatalf("%s: unexpected events %+v", step.Name, events.published)
+			}
+			continue
+		}
+		if 1 != len(events.published) {
+			t.Fatalf("%s: %d event(s) published", step.Name, len(events.published))
+		}
+		var event = events.published[0]
+		if step.Expected != event.Type || "p" != event.Pool || "" == event.Attributes["unplaceable"] {
+			t.Fatalf("%s: unexpected event %+v", step.Name, event)
+		}
+	}
+}
@@ -36,6 +36,8 @@ type InstanceStatus struct {
 	Host            string //hosting cell ip
 	User            string
 	Group           string
+	ServerGroup     string
+	Selector        PlacementSelector
 	AutoStart       bool
 	System          string
 	Created         bool
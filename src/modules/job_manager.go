@@ -0,0 +1,722 @@
+package modules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/project-nano/core/store"
+	"github.com/project-nano/framework"
+	uuid "github.com/satori/go.uuid"
+	"log"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	JobTypeCreateGuest      = "create_guest"
+	JobTypeResetSystem      = "reset_system"
+	JobTypeResizeDisk       = "resize_disk"
+	JobTypeMigration        = "migration"
+	JobTypeBatchCreateGuest = "batch_create_guest"
+	JobTypeBatchDeleteGuest = "batch_delete_guest"
+	JobTypeBatchStopGuest   = "batch_stop_guest"
+	JobTypeCreateDiskImage  = "create_disk_image"
+)
+
+const (
+	JobStateRunning   = "running"
+	JobStateSucceeded = "succeeded"
+	JobStateFailed    = "failed"
+	JobStateCancelled = "cancelled"
+)
+
+const (
+	JobDataFilename     = "job.data"
+	jobTrackInterval    = time.Second * 2
+	jobCompactInterval  = time.Minute
+	jobLimit            = 1 << 12
+	jobProgressComplete = 100
+)
+
+// Job : long-running operation, Target is id of guest, migration, batch or image operated
+type Job struct {
+	ID           string `json:"id"`
+	Type         string `json:"type"`
+	Target       string `json:"target"`
+	Owner        string `json:"owner,omitempty"`
+	State        string `json:"state"`
+	Progress     uint   `json:"progress"`
+	Error        string `json:"error,omitempty"`
+	Cancelable   bool   `json:"cancelable"`
+	Recovered    bool   `json:"-"` //unfinished when core restarted
+	CreatedTime  string `json:"created_time"`
+	ModifiedTime string `json:"modified_time"`
+	FinishedTime string `json:"finished_time,omitempty"`
+}
+
+// JobStatus : current status of job target reported by tracker
+type JobStatus struct {
+	State    string
+	Progress uint
+	Error    string
+}
+
+// JobHandler : Track called periodically outside routine of job manager, event driven jobs
+// only tracked once after dispatched and after core restarted, Cancel is optional
+type JobHandler struct {
+	EventDriven bool
+	Track       func(target string) (JobStatus, error)
+	Cancel      func(target string) error
+}
+
+// JobFilter : empty field matches all
+type JobFilter struct {
+	Type   string
+	State  string
+	Owner  string
+	Target string
+}
+
+type JobResult struct {
+	Error    error
+	Job      Job
+	JobList  []Job
+	Data     []byte
+	Revision uint64
+}
+
+type JobModule interface {
+	RegisterJobHandler(jobType string, handler JobHandler) error
+	CreateJob(job Job, respChan chan JobResult)
+	DispatchJob(id, target string, completed bool, dispatchError error, respChan chan error)
+	QueryJobs(filter JobFilter, respChan chan JobResult)
+	GetJob(id string, respChan chan JobResult)
+	CancelJob(id string, respChan chan error)
+	ExportJobData(revision uint64, respChan chan JobResult)
+}
+
+type jobCommandType int
+
+const (
+	jobCmdCreate = iota
+	jobCmdDispatch
+	jobCmdQuery
+	jobCmdGet
+	jobCmdCancel
+	jobCmdExport
+)
+
+type jobCommand struct {
+	Type       jobCommandType
+	ID         string
+	Target     string
+	Completed  bool
+	Error      error
+	Job        Job
+	Filter     JobFilter
+	Revision   uint64
+	ResultChan chan JobResult
+	ErrorChan  chan error
+}
+
+type jobUpdate struct {
+	ID     string
+	Status JobStatus
+	Error  error
+}
+
+type jobSavedData struct {
+	Jobs []Job `json:"jobs,omitempty"`
+}
+
+// JobManager : unified view of long-running operations, updated by cluster events and trackers
+type JobManager struct {
+	jobs         map[string]Job
+	cancelling   map[string]bool
+	catchup      map[string]bool
+	handlers     map[string]JobHandler
+	tracking     bool
+	revision     uint64 //increased by every save from 1, replicated when changed
+	events       EventModule
+	subscription *EventSubscription
+	cursor       string
+	updates      chan []jobUpdate
+	cancelled    chan jobUpdate
+	commands     chan jobCommand
+	dataFile     string
+	metadata     store.Store
+	runner       *framework.SimpleRunner
+}
+
+func CreateJobManager(dataPath string, events EventModule) (manager *JobManager, err error) {
+	const (
+		DefaultQueueLength = 1 << 10
+	)
+	manager = &JobManager{events: events, revision: 1}
+	manager.jobs = map[string]Job{}
+	manager.cancelling = map[string]bool{}
+	manager.catchup = map[string]bool{}
+	manager.handlers = map[string]JobHandler{}
+	manager.updates = make(chan []jobUpdate, 1)
+	manager.cancelled = make(chan jobUpdate, DefaultQueueLength)
+	manager.commands = make(chan jobCommand, DefaultQueueLength)
+	manager.dataFile = filepath.Join(dataPath, JobDataFilename)
+	if manager.metadata, err = store.Open(GetConfigurator().GetMetadataStore(), manager.dataFile); err != nil {
+		return
+	}
+	if err = manager.loadData(); err != nil {
+		return
+	}
+	manager.runner = framework.CreateSimpleRunner(manager.routine)
+	return manager, nil
+}
+
+func (manager *JobManager) Start() error {
+	return manager.runner.Start()
+}
+
+func (manager *JobManager) Stop() error {
+	return manager.runner.Stop()
+}
+
+// RegisterJobHandler : must be called before started
+func (manager *JobManager) RegisterJobHandler(jobType string, handler JobHandler) error {
+	if _, exists := manager.handlers[jobType]; exists {
+		return fmt.Errorf("handler of job type '%s' already registered", jobType)
+	}
+	manager.handlers[jobType] = handler
+	return nil
+}
+
+// CreateJob : job with State specified recorded as finished, running job created before dispatching operation
+// and target may be empty when allocated by operation
+func (manager *JobManager) CreateJob(job Job, respChan chan JobResult) {
+	manager.commands <- jobCommand{Type: jobCmdCreate, Job: job, ResultChan: respChan}
+}
+
+// DispatchJob : bind target allocated by operation, job failed when dispatch failed, succeeded when operation already completed
+func (manager *JobManager) DispatchJob(id, target string, completed bool, dispatchError error, respChan chan error) {
+	manager.commands <- jobCommand{Type: jobCmdDispatch, ID: id, Target: target, Completed: completed, Error: dispatchError, ErrorChan: respChan}
+}
+
+func (manager *JobManager) QueryJobs(filter JobFilter, respChan chan JobResult) {
+	manager.commands <- jobCommand{Type: jobCmdQuery, Filter: filter, ResultChan: respChan}
+}
+
+func (manager *JobManager) GetJob(id string, respChan chan JobResult) {
+	manager.commands <- jobCommand{Type: jobCmdGet, ID: id, ResultChan: respChan}
+}
+
+func (manager *JobManager) CancelJob(id string, respChan chan error) {
+	manager.commands <- jobCommand{Type: jobCmdCancel, ID: id, ErrorChan: respChan}
+}
+
+// ExportJobData : saved document for replication to standby core, data omitted when revision unchanged
+func (manager *JobManager) ExportJobData(revision uint64, respChan chan JobResult) {
+	manager.commands <- jobCommand{Type: jobCmdExport, Revision: revision, ResultChan: respChan}
+}
+
+func (manager *JobManager) routine(c framework.RoutineController) {
+	log.Printf("<job> started, %d job(s) available", len(manager.jobs))
+	var trackTicker = time.NewTicker(jobTrackInterval)
+	var compactTicker = time.NewTicker(jobCompactInterval)
+	manager.subscribe()
+	for !c.IsStopping() {
+		var incoming chan ClusterEvent
+		if nil != manager.subscription {
+			incoming = manager.subscription.Events
+		}
+		select {
+		case <-c.GetNotifyChannel():
+			c.SetStopping()
+		case event, ok := <-incoming:
+			if !ok {
+				log.Println("<job> event subscription closed by hub")
+				manager.subscription = nil
+				break
+			}
+			manager.cursor = event.ID
+			manager.onClusterEvent(event)
+		case updates := <-manager.updates:
+			manager.tracking = false
+			for _, update := range updates {
+				manager.onJobUpdate(update)
+			}
+		case update := <-manager.cancelled:
+			delete(manager.cancelling, update.ID)
+			manager.onJobUpdate(update)
+		case <-trackTicker.C:
+			if nil == manager.subscription {
+				manager.subscribe()
+			}
+			manager.releaseExpiredJobs()
+			manager.trackJobs()
+		case <-compactTicker.C:
+			if 0 != manager.metadata.Pending() {
+				if err := manager.metadata.Compact(manager.buildSavedData()); err != nil {
+					log.Printf("<job> compact data fail: %s", err.Error())
+				}
+			}
+		case cmd := <-manager.commands:
+			manager.handleCommand(cmd)
+		}
+	}
+	trackTicker.Stop()
+	compactTicker.Stop()
+	if nil != manager.subscription {
+		manager.events.UnsubscribeEvents(manager.subscription.ID)
+	}
+	if err := manager.metadata.Compact(manager.buildSavedData()); err != nil {
+		log.Printf("<job> save data fail: %s", err.Error())
+	}
+	if err := manager.metadata.Close(); err != nil {
+		log.Printf("<job> close metadata store fail: %s", err.Error())
+	}
+	c.NotifyExit()
+	log.Println("<job> stopped")
+}
+
+func (manager *JobManager) subscribe() {
+	var types = map[string]bool{
+		EventGuestCreated:     true,
+		EventGuestDeleted:     true,
+		EventGuestUpdated:     true,
+		EventGuestSystemReset: true,
+		EventBatchFinished:    true,
+	}
+	var respChan = make(chan EventResult, 1)
+	manager.events.SubscribeEvents(EventFilter{Types: types}, manager.cursor, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<job> subscribe events fail: %s", result.Error.Error())
+		return
+	}
+	if result.Lost {
+		log.Printf("<job> warning: events after '%s' lost", manager.cursor)
+	}
+	manager.subscription = &result.Subscription
+	for _, event := range result.Backlog {
+		manager.cursor = event.ID
+		manager.onClusterEvent(event)
+	}
+}
+
+func (manager *JobManager) handleCommand(cmd jobCommand) {
+	var err error
+	switch cmd.Type {
+	case jobCmdCreate:
+		err = manager.handleCreateJob(cmd.Job, cmd.ResultChan)
+	case jobCmdDispatch:
+		err = manager.handleDispatchJob(cmd.ID, cmd.Target, cmd.Completed, cmd.Error, cmd.ErrorChan)
+	case jobCmdQuery:
+		err = manager.handleQueryJobs(cmd.Filter, cmd.ResultChan)
+	case jobCmdGet:
+		err = manager.handleGetJob(cmd.ID, cmd.ResultChan)
+	case jobCmdCancel:
+		err = manager.handleCancelJob(cmd.ID, cmd.ErrorChan)
+	case jobCmdExport:
+		err = manager.handleExportJobData(cmd.Revision, cmd.ResultChan)
+	default:
+		log.Printf("<job> unsupported command type %d", cmd.Type)
+		return
+	}
+	if err != nil {
+		log.Printf("<job> handle command %d fail: %s", cmd.Type, err.Error())
+	}
+}
+
+func (manager *JobManager) handleCreateJob(job Job, respChan chan JobResult) (err error) {
+	handler, exists := manager.handlers[job.Type]
+	if !exists {
+		err = fmt.Errorf("invalid job type '%s'", job.Type)
+		respChan <- JobResult{Error: err}
+		return
+	}
+	if "" == job.Target && "" != job.State {
+		err = errors.New("job target required")
+		respChan <- JobResult{Error: err}
+		return
+	}
+	var now = time.Now().Format(TimeFormatLayout)
+	job.ID = uuid.NewV4().String()
+	job.CreatedTime = now
+	job.ModifiedTime = now
+	job.Recovered = false
+	if "" == job.State {
+		job.State = JobStateRunning
+		job.Cancelable = nil != handler.Cancel
+	} else {
+		job.Cancelable = false
+		job.FinishedTime = now
+		if JobStateSucceeded == job.State {
+			job.Progress = jobProgressComplete
+		}
+	}
+	manager.jobs[job.ID] = job
+	log.Printf("<job> new %s job '%s' created for '%s'", job.Type, job.ID, job.Target)
+	respChan <- JobResult{Job: job}
+	return manager.saveData()
+}
+
+func (manager *JobManager) handleDispatchJob(id, target string, completed bool, dispatchError error, respChan chan error) (err error) {
+	job, exists := manager.jobs[id]
+	if !exists {
+		err = fmt.Errorf("invalid job '%s'", id)
+		respChan <- err
+		return
+	}
+	if JobStateRunning != job.State {
+		err = fmt.Errorf("job '%s' already %s", id, job.State)
+		respChan <- err
+		return
+	}
+	if "" != target {
+		job.Target = target
+	}
+	if "" == job.Target && nil == dispatchError {
+		err = fmt.Errorf("target of job '%s' required", id)
+		respChan <- err
+		return
+	}
+	manager.jobs[id] = job
+	respChan <- nil
+	if dispatchError != nil {
+		manager.onJobUpdate(jobUpdate{ID: id, Status: JobStatus{State: JobStateFailed, Error: dispatchError.Error()}})
+		return nil
+	}
+	if completed {
+		manager.onJobUpdate(jobUpdate{ID: id, Status: JobStatus{State: JobStateSucceeded}})
+		return nil
+	}
+	if manager.handlers[job.Type].EventDriven {
+		//events may arrive before target bound
+		manager.catchup[id] = true
+	}
+	log.Printf("<job> %s job '%s' dispatched to '%s'", job.Type, id, job.Target)
+	return manager.saveData()
+}
+
+func (manager *JobManager) handleQueryJobs(filter JobFilter, respChan chan JobResult) (err error) {
+	var jobs = make([]Job, 0)
+	for _, job := range manager.jobs {
+		if "" != filter.Type && filter.Type != job.Type {
+			continue
+		}
+		if "" != filter.State && filter.State != job.State {
+			continue
+		}
+		if "" != filter.Owner && filter.Owner != job.Owner {
+			continue
+		}
+		if "" != filter.Target && filter.Target != job.Target {
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	//latest first
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].CreatedTime != jobs[j].CreatedTime {
+			return jobs[i].CreatedTime > jobs[j].CreatedTime
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+	respChan <- JobResult{JobList: jobs}
+	return nil
+}
+
+func (manager *JobManager) handleGetJob(id string, respChan chan JobResult) (err error) {
+	job, exists := manager.jobs[id]
+	if !exists {
+		err = fmt.Errorf("invalid job '%s'", id)
+		respChan <- JobResult{Error: err}
+		return
+	}
+	respChan <- JobResult{Job: job}
+	return nil
+}
+
+func (manager *JobManager) handleCancelJob(id string, respChan chan error) (err error) {
+	job, exists := manager.jobs[id]
+	if !exists {
+		err = fmt.Errorf("invalid job '%s'", id)
+		respChan <- err
+		return
+	}
+	if JobStateRunning != job.State {
+		err = fmt.Errorf("job '%s' already %s", id, job.State)
+		respChan <- err
+		return
+	}
+	if "" == job.Target {
+		err = fmt.Errorf("job '%s' not dispatched yet", id)
+		respChan <- err
+		return
+	}
+	var handler = manager.handlers[job.Type]
+	if nil == handler.Cancel {
+		err = fmt.Errorf("%s job can not be cancelled", job.Type)
+		respChan <- err
+		return
+	}
+	if manager.cancelling[id] {
+		err = fmt.Errorf("job '%s' is cancelling", id)
+		respChan <- err
+		return
+	}
+	manager.cancelling[id] = true
+	log.Printf("<job> try cancel %s job '%s'", job.Type, id)
+	go func(target string) {
+		if err := handler.Cancel(target); err != nil {
+			log.Printf("<job> cancel job '%s' fail: %s", id, err.Error())
+			manager.cancelled <- jobUpdate{ID: id, Error: err}
+			respChan <- err
+			return
+		}
+		manager.cancelled <- jobUpdate{ID: id, Status: JobStatus{State: JobStateCancelled}}
+		respChan <- nil
+	}(job.Target)
+	return nil
+}
+
+// trackJobs : fetch status of running jobs in background, one round at a time
+func (manager *JobManager) trackJobs() {
+	if manager.tracking {
+		return
+	}
+	type trackTarget struct {
+		ID     string
+		Target string
+		Track  func(string) (JobStatus, error)
+	}
+	var targets []trackTarget
+	for id, job := range manager.jobs {
+		if JobStateRunning != job.State || "" == job.Target || manager.cancelling[id] {
+			continue
+		}
+		var handler = manager.handlers[job.Type]
+		if nil == handler.Track || (handler.EventDriven && !job.Recovered && !manager.catchup[id]) {
+			continue
+		}
+		targets = append(targets, trackTarget{ID: id, Target: job.Target, Track: handler.Track})
+	}
+	if 0 == len(targets) {
+		return
+	}
+	manager.tracking = true
+	go func() {
+		var updates []jobUpdate
+		for _, target := range targets {
+			status, err := target.Track(target.Target)
+			updates = append(updates, jobUpdate{ID: target.ID, Status: status, Error: err})
+		}
+		manager.updates <- updates
+	}()
+}
+
+func (manager *JobManager) onClusterEvent(event ClusterEvent) {
+	var target = event.Guest
+	if EventBatchFinished == event.Type {
+		target = event.Attributes["batch"]
+	}
+	for id, job := range manager.jobs {
+		if JobStateRunning != job.State || "" == job.Target || target != job.Target {
+			continue
+		}
+		var status = JobStatus{State: JobStateRunning, Progress: job.Progress}
+		switch event.Type {
+		case EventBatchFinished:
+			if JobTypeBatchCreateGuest != job.Type && JobTypeBatchDeleteGuest != job.Type && JobTypeBatchStopGuest != job.Type {
+				continue
+			}
+			if failed := event.Attributes["failed"]; "0" != failed {
+				status.State = JobStateFailed
+				status.Error = fmt.Sprintf("%s guest(s) failed", failed)
+			} else {
+				status.State = JobStateSucceeded
+			}
+		case EventGuestUpdated:
+			if JobTypeCreateGuest != job.Type && JobTypeResetSystem != job.Type {
+				continue
+			}
+			if message, exists := event.Attributes["error"]; exists {
+				status.State = JobStateFailed
+				status.Error = message
+			} else if value, exists := event.Attributes["progress"]; exists {
+				if progress, err := strconv.ParseUint(value, 10, 32); err == nil {
+					status.Progress = uint(progress)
+				}
+			}
+		case EventGuestCreated:
+			if JobTypeCreateGuest != job.Type {
+				continue
+			}
+			status.State = JobStateSucceeded
+		case EventGuestSystemReset:
+			if JobTypeResetSystem != job.Type {
+				continue
+			}
+			if message, exists := event.Attributes["error"]; exists {
+				status.State = JobStateFailed
+				status.Error = message
+			} else {
+				status.State = JobStateSucceeded
+			}
+		case EventGuestDeleted:
+			if JobTypeCreateGuest != job.Type && JobTypeResetSystem != job.Type {
+				continue
+			}
+			if manager.cancelling[id] {
+				//finished by cancel routine
+				continue
+			}
+			status.State = JobStateFailed
+			status.Error = "guest deleted"
+		default:
+			continue
+		}
+		manager.onJobUpdate(jobUpdate{ID: id, Status: status})
+	}
+}
+
+func (manager *JobManager) onJobUpdate(update jobUpdate) {
+	job, exists := manager.jobs[update.ID]
+	if !exists || JobStateRunning != job.State {
+		delete(manager.catchup, update.ID)
+		return
+	}
+	if update.Error != nil {
+		if !job.Recovered {
+			//keep tracking
+			return
+		}
+		update.Status = JobStatus{State: JobStateFailed, Error: fmt.Sprintf("status lost after restart of core: %s", update.Error.Error())}
+	}
+	delete(manager.catchup, update.ID)
+	var status = update.Status
+	if JobStateRunning == status.State {
+		if status.Progress != job.Progress {
+			job.Progress = status.Progress
+			job.ModifiedTime = time.Now().Format(TimeFormatLayout)
+			manager.jobs[update.ID] = job
+		}
+		return
+	}
+	var now = time.Now().Format(TimeFormatLayout)
+	job.State = status.State
+	job.Error = status.Error
+	job.Cancelable = false
+	job.Recovered = false
+	job.ModifiedTime = now
+	job.FinishedTime = now
+	if JobStateSucceeded == status.State {
+		job.Progress = jobProgressComplete
+	} else if status.Progress > job.Progress {
+		job.Progress = status.Progress
+	}
+	manager.jobs[update.ID] = job
+	log.Printf("<job> %s job '%s' %s", job.Type, job.ID, job.State)
+	if err := manager.saveData(); err != nil {
+		log.Printf("<job> save data fail: %s", err.Error())
+	}
+}
+
+func (manager *JobManager) releaseExpiredJobs() {
+	var expireTime = time.Now().Add(-GetConfigurator().GetTaskRetention()).Format(TimeFormatLayout)
+	var finished []Job
+	var released = 0
+	for id, job := range manager.jobs {
+		if JobStateRunning == job.State {
+			continue
+		}
+		if job.FinishedTime < expireTime {
+			delete(manager.jobs, id)
+			released++
+			continue
+		}
+		finished = append(finished, job)
+	}
+	if len(manager.jobs) > jobLimit {
+		//drop earliest finished jobs
+		sort.Slice(finished, func(i, j int) bool {
+			return finished[i].FinishedTime < finished[j].FinishedTime
+		})
+		for _, job := range finished {
+			if len(manager.jobs) <= jobLimit {
+				break
+			}
+			delete(manager.jobs, job.ID)
+			released++
+		}
+	}
+	if 0 == released {
+		return
+	}
+	log.Printf("<job> %d finished job(s) released", released)
+	if err := manager.saveData(); err != nil {
+		log.Printf("<job> save data fail: %s", err.Error())
+	}
+}
+
+func (manager *JobManager) handleExportJobData(revision uint64, respChan chan JobResult) (err error) {
+	if revision == manager.revision {
+		respChan <- JobResult{Revision: revision}
+		return nil
+	}
+	var data []byte
+	if data, err = json.Marshal(manager.buildSavedData()); err != nil {
+		respChan <- JobResult{Error: err}
+		return
+	}
+	respChan <- JobResult{Data: data, Revision: manager.revision}
+	return nil
+}
+
+func (manager *JobManager) buildSavedData() (saved jobSavedData) {
+	for _, job := range manager.jobs {
+		saved.Jobs = append(saved.Jobs, job)
+	}
+	sort.Slice(saved.Jobs, func(i, j int) bool {
+		return saved.Jobs[i].ID < saved.Jobs[j].ID
+	})
+	return
+}
+
+func (manager *JobManager) saveData() error {
+	manager.revision++
+	return manager.metadata.Save(manager.buildSavedData())
+}
+
+func (manager *JobManager) loadData() (err error) {
+	var saved jobSavedData
+	var result store.LoadResult
+	if result, err = manager.metadata.Load(&saved); err != nil {
+		return
+	}
+	if !result.Exists {
+		return nil
+	}
+	var recovered, interrupted = 0, 0
+	var now = time.Now().Format(TimeFormatLayout)
+	for _, job := range saved.Jobs {
+		if JobStateRunning == job.State && "" == job.Target {
+			//operation never dispatched
+			job.State = JobStateFailed
+			job.Error = "dispatch interrupted by restart of core"
+			job.Cancelable = false
+			job.ModifiedTime = now
+			job.FinishedTime = now
+			interrupted++
+		} else if JobStateRunning == job.State {
+			job.Recovered = true
+			recovered++
+		}
+		manager.jobs[job.ID] = job
+	}
+	log.Printf("<job> %d job(s) loaded from '%s', %d unfinished", len(saved.Jobs), manager.dataFile, recovered)
+	if result.Dirty || 0 != interrupted {
+		return manager.metadata.Compact(manager.buildSavedData())
+	}
+	return nil
+}
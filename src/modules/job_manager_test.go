@@ -0,0 +1,142 @@
+package modules
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestJobManager_Dispatch(t *testing.T) {
+	const (
+		stepEvent = iota
+		stepDispatch
+		stepTrack
+		stepCancel
+	)
+	type jobStep struct {
+		Operation int
+		Target    string
+		Completed bool
+		Error     error
+		Valid     bool
+	}
+	var testCases = []struct {
+		Name    string
+		Target  string
+		Tracked JobStatus
+		Steps   []jobStep
+		State   string
+		Message string
+	}{
+		{"finished before dispatched", "", JobStatus{State: JobStateSucceeded}, []jobStep{
+			{stepEvent, "g1", false, nil, true},
+			{stepDispatch, "g1", false, nil, true},
+			{stepTrack, "", false, nil, true},
+		}, JobStateSucceeded, ""},
+		{"event after dispatched", "", JobStatus{State: JobStateRunning}, []jobStep{
+			{stepDispatch, "g1", false, nil, true},
+			{stepTrack, "", false, nil, true},
+			{stepEvent, "g1", false, nil, true},
+		}, JobStateSucceeded, ""},
+		{"dispatch failed", "", JobStatus{}, []jobStep{
+			{stepDispatch, "", false, errors.New("rejected"), true},
+		}, JobStateFailed, "rejected"},
+		{"completed when dispatched", "g1", JobStatus{}, []jobStep{
+			{stepDispatch, "", true, nil, true},
+		}, JobStateSucceeded, ""},
+		{"target required", "", JobStatus{}, []jobStep{
+			{stepDispatch, "", false, nil, false},
+		}, JobStateRunning, ""},
+		{"not cancelled before dispatched", "", JobStatus{}, []jobStep{
+			{stepCancel, "", false, nil, false},
+			{stepDispatch, "g1", false, nil, true},
+			{stepCancel, "", false, nil, true},
+		}, JobStateCancelled, ""},
+	}
+	for _, testCase := range testCases {
+		manager, err := CreateJobManager(t.TempDir(), nil)
+		if err != nil {
+			t.Fatalf("%s: create job manager fail: %s", testCase.Name, err.Error())
+		}
+		var tracked = testCase.Tracked
+		if err = manager.RegisterJobHandler(JobTypeCreateGuest, JobHandler{
+			EventDriven: true,
+			Track: func(string) (JobStatus, error) {
+				return tracked, nil
+			},
+			Cancel: func(string) error {
+				return nil
+			},
+		}); err != nil {
+			t.Fatalf("%s: register handler fail: %s", testCase.Name, err.Error())
+		}
+		var resultChan = make(chan JobResult, 1)
+		manager.handleCreateJob(Job{Type: JobTypeCreateGuest, Target: testCase.Target}, resultChan)
+		var result = <-resultChan
+		if result.Error != nil {
+			t.Fatalf("%s: create job fail: %s", testCase.Name, result.Error.Error())
+		}
+		var jobID = result.Job.ID
+		for index, step := range testCase.Steps {
+			var errChan = make(chan error, 1)
+			switch step.Operation {
+			case stepEvent:
+				manager.onClusterEvent(ClusterEvent{Type: EventGuestCreated, Guest: step.Target})
+			case stepDispatch:
+				manager.handleDispatchJob(jobID, step.Target, step.Completed, step.Error, errChan)
+				err = <-errChan
+			case stepTrack:
+				manager.trackJobs()
+				if !manager.tracking {
+					t.Fatalf("%s: step %d job not tracked", testCase.Name, index)
+				}
+				for _, update := range <-manager.updates {
+					manager.onJobUpdate(update)
+				}
+				manager.tracking = false
+			case stepCancel:
+				if err = manager.handleCancelJob(jobID, errChan); nil == err {
+					manager.onJobUpdate(<-manager.cancelled)
+					err = <-errChan
+				}
+			}
+			if (nil == err) != step.Valid {
+				t.Fatalf("%s: step %d unexpected result %v", testCase.Name, index, err)
+			}
+			err = nil
+		}
+		var job = manager.jobs[jobID]
+		manager.metadata.Close()
+		if job.State != testCase.State || job.Error != testCase.Message {
+			t.Fatalf("%s: job %s '%s', %s '%s' expected", testCase.Name, job.State, job.Error, testCase.State, testCase.Message)
+		}
+		if manager.catchup[jobID] {
+			t.Fatalf("%s: job still waiting for catch-up", testCase.Name)
+		}
+	}
+}
+
+func TestJobManager_LoadUndispatched(t *testing.T) {
+	var dataPath = t.TempDir()
+	manager, err := CreateJobManager(dataPath, nil)
+	if err != nil {
+		t.Fatalf("create job manager fail: %s", err.Error())
+	}
+	manager.jobs = map[string]Job{
+		"undispatched": {ID: "undispatched", Type: JobTypeCreateGuest, State: JobStateRunning},
+		"running":      {ID: "running", Type: JobTypeCreateGuest, Target: "g1", State: JobStateRunning},
+	}
+	if err = manager.saveData(); err != nil {
+		t.Fatalf("save jobs fail: %s", err.Error())
+	}
+	manager.metadata.Close()
+	if manager, err = CreateJobManager(dataPath, nil); err != nil {
+		t.Fatalf("reload job manager fail: %s", err.Error())
+	}
+	defer manager.metadata.Close()
+	if job := manager.jobs["undispatched"]; JobStateFailed != job.State || "" == job.FinishedTime {
+		t.Fatalf("undispatched job loaded as %+v", job)
+	}
+	if job := manager.jobs["running"]; JobStateRunning != job.State || !job.Recovered {
+		t.Fatalf("running job loaded as %+v", job)
+	}
+}
@@ -0,0 +1,145 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"github.com/project-nano/framework"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ListOption : paging and sorting of list query, zero value returns whole list in default order.
+// Sort is name of sort key, prefixed with '-' for descending order, Fields only applied when rendering response
+type ListOption struct {
+	Limit  int
+	Offset int
+	Sort   string
+	Fields []string
+}
+
+// ListSortKeys : less function of each sortable key, index into list being sorted
+type ListSortKeys map[string]func(i, j int) bool
+
+const (
+	ListFlagTotal = iota
+	ListFlagLimit
+	ListFlagOffset
+	ListFlagCount
+)
+
+const (
+	listSortDescending = "-"
+	listMaxLimit       = 1 << 12
+)
+
+func ParseListOption(query url.Values) (option ListOption, err error) {
+	if value := query.Get("limit"); "" != value {
+		if option.Limit, err = strconv.Atoi(value); err != nil || option.Limit < 0 || option.Limit > listMaxLimit {
+			err = fmt.Errorf("invalid limit '%s'", value)
+			return
+		}
+	}
+	if value := query.Get("offset"); "" != value {
+		if option.Offset, err = strconv.Atoi(value); err != nil || option.Offset < 0 {
+			err = fmt.Errorf("invalid offset '%s'", value)
+			return
+		}
+	}
+	option.Sort = strings.TrimSpace(query.Get("sort"))
+	if value := query.Get("fields"); "" != value {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); "" != field {
+				option.Fields = append(option.Fields, field)
+			}
+		}
+	}
+	return option, nil
+}
+
+func (option ListOption) MarshalToMessage(msg framework.Message) {
+	msg.SetInt(framework.ParamKeyLimit, option.Limit)
+	msg.SetInt(framework.ParamKeyStart, option.Offset)
+	msg.SetString(framework.ParamKeyIndex, option.Sort)
+}
+
+// UnmarshalListOptionFromMessage : absent parameters ignored for requests from legacy callers
+func UnmarshalListOptionFromMessage(msg framework.Message) (option ListOption) {
+	option.Limit, _ = msg.GetInt(framework.ParamKeyLimit)
+	option.Offset, _ = msg.GetInt(framework.ParamKeyStart)
+	option.Sort, _ = msg.GetString(framework.ParamKeyIndex)
+	return
+}
+
+// MarshalListPageToMessage : page of result in flags, same layout as search guests
+func MarshalListPageToMessage(result ResourceResult, msg framework.Message) {
+	var flags = make([]uint64, ListFlagCount)
+	flags[ListFlagTotal] = uint64(result.Total)
+	flags[ListFlagLimit] = uint64(result.Limit)
+	flags[ListFlagOffset] = uint64(result.Offset)
+	msg.SetUIntArray(framework.ParamKeyFlag, flags)
+}
+
+// SortList : stable sort list in place, defaultKey used when no key specified, empty defaultKey keeps current order
+func SortList(list interface{}, option ListOption, defaultKey string, keys ListSortKeys) error {
+	var key = option.Sort
+	if "" == key {
+		if "" == defaultKey {
+			return nil
+		}
+		key = defaultKey
+	}
+	var descending = strings.HasPrefix(key, listSortDescending)
+	key = strings.TrimPrefix(key, listSortDescending)
+	less, exists := keys[key]
+	if !exists {
+		var names []string
+		for name := range keys {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("invalid sort key '%s', available: %s", key, strings.Join(names, ","))
+	}
+	if descending {
+		sort.SliceStable(list, func(i, j int) bool {
+			return less(j, i)
+		})
+	} else {
+		sort.SliceStable(list, less)
+	}
+	return nil
+}
+
+// Page : range of list returned, zero limit means all items after offset
+func (option ListOption) Page(total int) (begin, end int, err error) {
+	if option.Offset < 0 || option.Limit < 0 {
+		err = errors.New("negative offset or limit")
+		return
+	}
+	if option.Offset > total || (option.Offset == total && 0 != total) {
+		err = fmt.Errorf("unexpected offset %d / %d", option.Offset, total)
+		return
+	}
+	begin = option.Offset
+	end = total
+	if 0 != option.Limit && begin+option.Limit < total {
+		end = begin + option.Limit
+	}
+	return begin, end, nil
+}
+
+// arrangeList : sort list of length by option then mark page in result, return range of page in list
+func arrangeList(list interface{}, length int, option ListOption, defaultKey string, keys ListSortKeys,
+	result *ResourceResult) (begin, end int, err error) {
+	if err = SortList(list, option, defaultKey, keys); err != nil {
+		return
+	}
+	if begin, end, err = option.Page(length); err != nil {
+		return
+	}
+	result.Total = length
+	result.Limit = option.Limit
+	result.Offset = option.Offset
+	return begin, end, nil
+}
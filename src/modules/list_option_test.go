@@ -0,0 +1,164 @@
+package modules
+
+import (
+	"encoding/json"
+	"github.com/project-nano/framework"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type listItemForTest struct {
+	Name  string `json:"name"`
+	Cores int    `json:"cores"`
+	Pool  string `json:"pool"`
+}
+
+func TestListOption_Parse(t *testing.T) {
+	var testCases = []struct {
+		Name     string
+		Query    string
+		Valid    bool
+		Expected ListOption
+	}{
+		{"empty", "", true, ListOption{}},
+		{"all options", "limit=10&offset=20&sort=-name&fields=name,+cores,,", true,
+			ListOption{Limit: 10, Offset: 20, Sort: "-name", Fields: []string{"name", "cores"}}},
+		{"invalid limit", "limit=ten", false, ListOption{}},
+		{"negative limit", "limit=-1", false, ListOption{}},
+		{"limit too large", "limit=100000", false, ListOption{}},
+		{"negative offset", "offset=-5", false, ListOption{}},
+	}
+	for _, testCase := range testCases {
+		query, err := url.ParseQuery(testCase.Query)
+		if err != nil {
+			t.Fatalf("%s: parse query fail: %s", testCase.Name, err.Error())
+		}
+		option, err := ParseListOption(query)
+		if (nil == err) != testCase.Valid {
+			t.Fatalf("%s: unexpected parse result %v", testCase.Name, err)
+		}
+		if !testCase.Valid {
+			continue
+		}
+		if option.Limit != testCase.Expected.Limit || option.Offset != testCase.Expected.Offset ||
+			option.Sort != testCase.Expected.Sort || strings.Join(option.Fields, ",") != strings.Join(testCase.Expected.Fields, ",") {
+			t.Fatalf("%s: option %+v, %+v expected", testCase.Name, option, testCase.Expected)
+		}
+	}
+}
+
+func TestListOption_Sort(t *testing.T) {
+	var testCases = []struct {
+		Name       string
+		Sort       string
+		DefaultKey string
+		Valid      bool
+		Expected   string
+	}{
+		{"keep order", "", "", true, "c,a,b,d"},
+		{"default key", "", "name", true, "a,b,c,d"},
+		{"ascending", "cores", "name", true, "a,c,b,d"},
+		{"descending stable", "-cores", "name", true, "b,d,c,a"},
+		{"descending name", "-name", "", true, "d,c,b,a"},
+		{"invalid key", "memory", "name", false, ""},
+	}
+	for _, testCase := range testCases {
+		var list = []listItemForTest{{"c", 2, "p1"}, {"a", 1, "p1"}, {"b", 4, "p2"}, {"d", 4, "p2"}}
+		var keys = ListSortKeys{
+			"name":  func(i, j int) bool { return list[i].Name < list[j].Name },
+			"cores": func(i, j int) bool { return list[i].Cores < list[j].Cores },
+		}
+		var err = SortList(list, ListOption{Sort: testCase.Sort}, testCase.DefaultKey, keys)
+		if (nil == err) != testCase.Valid {
+			t.Fatalf("%s: unexpected sort result %v", testCase.Name, err)
+		}
+		if !testCase.Valid {
+			continue
+		}
+		var names []string
+		for _, item := range list {
+			names = append(names, item.Name)
+		}
+		if sorted := strings.Join(names, ","); sorted != testCase.Expected {
+			t.Fatalf("%s: sorted as %s, %s expected", testCase.Name, sorted, testCase.Expected)
+		}
+	}
+}
+
+func TestListOption_Page(t *testing.T) {
+	var testCases = []struct {
+		Name   string
+		Option ListOption
+		Total  int
+		Valid  bool
+		Begin  int
+		End    int
+	}{
+		{"whole list", ListOption{}, 5, true, 0, 5},
+		{"empty list", ListOption{}, 0, true, 0, 0},
+		{"first page", ListOption{Limit: 2}, 5, true, 0, 2},
+		{"middle page", ListOption{Limit: 2, Offset: 2}, 5, true, 2, 4},
+		{"last page", ListOption{Limit: 2, Offset: 4}, 5, true, 4, 5},
+		{"offset only", ListOption{Offset: 3}, 5, true, 3, 5},
+		{"offset at end", ListOption{Offset: 5}, 5, false, 0, 0},
+		{"offset beyond empty list", ListOption{Offset: 1}, 0, false, 0, 0},
+		{"negative limit", ListOption{Limit: -1}, 5, false, 0, 0},
+	}
+	for _, testCase := range testCases {
+		begin, end, err := testCase.Option.Page(testCase.Total)
+		if (nil == err) != testCase.Valid {
+			t.Fatalf("%s: unexpected page result %v", testCase.Name, err)
+		}
+		if testCase.Valid && (begin != testCase.Begin || end != testCase.End) {
+			t.Fatalf("%s: page [%d, %d), [%d, %d) expected", testCase.Name, begin, end, testCase.Begin, testCase.End)
+		}
+	}
+}
+
+func TestListOption_ResponseFields(t *testing.T) {
+	var list = []listItemForTest{{"a", 1, "p1"}, {"b", 2, "p2"}}
+	var testCases = []struct {
+		Name     string
+		Fields   []string
+		Expected []string
+	}{
+		{"all fields", nil, []string{"cores", "name", "pool"}},
+		{"selected fields", []string{"name", "pool"}, []string{"name", "pool"}},
+		{"unknown field ignored", []string{"name", "memory"}, []string{"name"}},
+	}
+	for _, testCase := range testCases {
+		resp, err := framework.CreateJsonMessage(framework.QueryComputePoolResponse)
+		if err != nil {
+			t.Fatalf("%s: create message fail: %s", testCase.Name, err.Error())
+		}
+		MarshalListPageToMessage(ResourceResult{Total: 10, Limit: 2}, resp)
+		var recorder = httptest.NewRecorder()
+		if err = ResponseList(list, resp, ListOption{Fields: testCase.Fields}, recorder); err != nil {
+			t.Fatalf("%s: response fail: %s", testCase.Name, err.Error())
+		}
+		if total := recorder.Header().Get(HeaderNameTotalCount); "10" != total {
+			t.Fatalf("%s: unexpected total count '%s'", testCase.Name, total)
+		}
+		var response struct {
+			Data []map[string]json.RawMessage `json:"data"`
+		}
+		if err = json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: parse response fail: %s", testCase.Name, err.Error())
+		}
+		if len(response.Data) != len(list) {
+			t.Fatalf("%s: %d item(s) returned, %d expected", testCase.Name, len(response.Data), len(list))
+		}
+		for _, item := range response.Data {
+			if len(item) != len(testCase.Expected) {
+				t.Fatalf("%s: unexpected fields %v", testCase.Name, item)
+			}
+			for _, name := range testCase.Expected {
+				if _, exists := item[name]; !exists {
+					t.Fatalf("%s: field '%s' missing in %v", testCase.Name, name, item)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"github.com/project-nano/framework"
+	"log"
+	"time"
+)
+
+// MigrationScheduler : drain cells in maintenance and rebalance pools by creating migrations batch by batch,
+// progress of both kept by resource manager so that they continue after core restarted
+type MigrationScheduler struct {
+	resource ResourceModule
+	proxy    *RequestProxy
+	runner   *framework.SimpleRunner
+}
+
+func CreateMigrationScheduler(sender framework.MessageSender, resource ResourceModule) (scheduler *MigrationScheduler, err error) {
+	scheduler = &MigrationScheduler{resource: resource}
+	if scheduler.proxy, err = CreateModuleRequestProxy(MigrationSchedulerModuleName, sender); err != nil {
+		return
+	}
+	scheduler.runner = framework.CreateSimpleRunner(scheduler.routine)
+	return scheduler, nil
+}
+
+func (scheduler *MigrationScheduler) GetModuleName() string {
+	return scheduler.proxy.Module
+}
+
+func (scheduler *MigrationScheduler) GetResponseChannel() chan framework.Message {
+	return scheduler.proxy.ResponseChan
+}
+
+func (scheduler *MigrationScheduler) Start() error {
+	if err := scheduler.proxy.Start(); err != nil {
+		return err
+	}
+	return scheduler.runner.Start()
+}
+
+func (scheduler *MigrationScheduler) Stop() error {
+	if err := scheduler.runner.Stop(); err != nil {
+		return err
+	}
+	return scheduler.proxy.Stop()
+}
+
+func (scheduler *MigrationScheduler) routine(c framework.RoutineController) {
+	const (
+		checkInterval = time.Second * 5
+	)
+	log.Println("<scheduler> started")
+	var ticker = time.NewTicker(checkInterval)
+	for !c.IsStopping() {
+		select {
+		case <-c.GetNotifyChannel():
+			c.SetStopping()
+		case <-ticker.C:
+			scheduler.drainCells()
+			scheduler.rebalancePools()
+		}
+	}
+	ticker.Stop()
+	c.NotifyExit()
+	log.Println("<scheduler> stopped")
+}
+
+func (scheduler *MigrationScheduler) drainCells() {
+	var respChan = make(chan ResourceResult, 1)
+	scheduler.resource.QueryCellMaintenance(respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<scheduler> query cell maintenance fail: %s", result.Error.Error())
+		return
+	}
+	for _, status := range result.MaintenanceList {
+		if status.Finished || "" != status.Migration {
+			continue
+		}
+		scheduler.resource.NextMaintenanceBatch(status.Cell, respChan)
+		result = <-respChan
+		if result.Error != nil {
+			log.Printf("<scheduler> get next batch of cell '%s' fail: %s", status.Cell, result.Error.Error())
+			continue
+		}
+		var next = result.MaintenanceBatch
+		var errChan = make(chan error, 1)
+		for _, instanceID := range next.Start {
+			if err := sendInstanceCommand(scheduler.proxy, instanceID, false); err != nil {
+				scheduler.resource.SetMaintenanceMigration(status.Cell, "", fmt.Errorf("start instance '%s' fail: %s", instanceID, err.Error()), errChan)
+				<-errChan
+			} else {
+				log.Printf("<scheduler> instance '%s' started again after drained", instanceID)
+			}
+		}
+		for _, instanceID := range next.Stop {
+			if err := sendInstanceCommand(scheduler.proxy, instanceID, true); err != nil {
+				scheduler.resource.SetMaintenanceMigration(status.Cell, "", fmt.Errorf("stop instance '%s' fail: %s", instanceID, err.Error()), errChan)
+				<-errChan
+			} else {
+				log.Printf("<scheduler> instance '%s' stopped for drain", instanceID)
+			}
+		}
+		var batch = next.Migration
+		if 0 == len(batch.Instances) {
+			continue
+		}
+		migrationID, migrateError := scheduler.startMigration(batch)
+		scheduler.resource.SetMaintenanceMigration(status.Cell, migrationID, migrateError, errChan)
+		if err := <-errChan; err != nil {
+			log.Printf("<scheduler> update maintenance of cell '%s' fail: %s", status.Cell, err.Error())
+		}
+	}
+}
+
+func (scheduler *MigrationScheduler) rebalancePools() {
+	var respChan = make(chan ResourceResult, 1)
+	scheduler.resource.QueryRebalance(respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<scheduler> query rebalance fail: %s", result.Error.Error())
+		return
+	}
+	for _, status := range result.RebalanceList {
+		if status.Finished || "" != status.Migration {
+			continue
+		}
+		scheduler.resource.NextRebalanceBatch(status.Pool, respChan)
+		result = <-respChan
+		if result.Error != nil {
+			log.Printf("<scheduler> get next batch of pool '%s' fail: %s", status.Pool, result.Error.Error())
+			continue
+		}
+		var batch = result.Migration.MigrationParameter
+		if 0 == len(batch.Instances) {
+			continue
+		}
+		migrationID, migrateError := scheduler.startMigration(batch)
+		var errChan = make(chan error, 1)
+		scheduler.resource.SetRebalanceMigration(status.Pool, migrationID, migrateError, errChan)
+		if err := <-errChan; err != nil {
+			log.Printf("<scheduler> update rebalance of pool '%s' fail: %s", status.Pool, err.Error())
+		}
+	}
+}
+
+// startMigration : create migration through the same request as REST API
+func (scheduler *MigrationScheduler) startMigration(batch MigrationParameter) (migrationID string, err error) {
+	msg, _ := framework.CreateJsonMessage(framework.CreateMigrationRequest)
+	msg.SetStringArray(framework.ParamKeyPool, []string{batch.SourcePool})
+	msg.SetStringArray(framework.ParamKeyCell, []string{batch.SourceCell, batch.TargetCell})
+	msg.SetStringArray(framework.ParamKeyInstance, batch.Instances)
+	var respChan = make(chan ProxyResult, 1)
+	if err = scheduler.proxy.SendRequest(msg, respChan); err != nil {
+		log.Printf("<scheduler> send create migration request fail: %s", err.Error())
+		return
+	}
+	resp, errMsg, success := IsResponseSuccess(respChan)
+	if !success {
+		err = errors.New(errMsg)
+		log.Printf("<scheduler> migrate %d instance(s) from cell '%s' to '%s' fail: %s",
+			len(batch.Instances), batch.SourceCell, batch.TargetCell, errMsg)
+		return
+	}
+	migrationID, _ = resp.GetString(framework.ParamKeyMigration)
+	return migrationID, nil
+}
+
+// sendInstanceCommand : gracefully stop, or start instance stopped by drain
+func sendInstanceCommand(proxy *RequestProxy, instanceID string, stop bool) (err error) {
+	var msg framework.Message
+	if stop {
+		msg, _ = framework.CreateJsonMessage(framework.StopInstanceRequest)
+		//no reboot, no force
+		msg.SetUIntArray(framework.ParamKeyOption, []uint64{0, 0})
+	} else {
+		msg, _ = framework.CreateJsonMessage(framework.StartInstanceRequest)
+		msg.SetUInt(framework.ParamKeyOption, InstanceMediaOptionNone)
+	}
+	msg.SetString(framework.ParamKeyInstance, instanceID)
+	var respChan = make(chan ProxyResult, 1)
+	if err = proxy.SendRequest(msg, respChan); err != nil {
+		return
+	}
+	if _, errMsg, success := IsResponseSuccess(respChan); !success {
+		return errors.New(errMsg)
+	}
+	return nil
+}
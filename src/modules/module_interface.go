@@ -3,6 +3,7 @@ package modules
 import (
 	"fmt"
 	"github.com/project-nano/framework"
+	"net"
 	"time"
 )
 
@@ -24,12 +25,23 @@ type GuestQueryCondition struct {
 	Created        bool
 }
 
+// PoolOvercommit : overcommit ratios when allocating instances on cells of pool,
+// and the CPU usage(percentage) over which a cell is considered overloaded
+type PoolOvercommit struct {
+	CPURatio           float64
+	MemoryRatio        float64
+	DiskRatio          float64
+	CPUHealthThreshold float64
+}
+
 type ComputePoolInfo struct {
-	Name      string
-	Enabled   bool
-	Network   string
-	Storage   string
-	Failover  bool
+	Name     string
+	Enabled  bool
+	Network  string
+	Storage  string
+	Failover bool
+	Strategy string
+	PoolOvercommit
 	CellCount uint64
 }
 
@@ -143,11 +155,36 @@ type ResourceResult struct {
 	PolicyGroup         SecurityPolicyGroupStatus
 	PolicyGroupList     []SecurityPolicyGroupStatus
 	PolicyRuleList      []SecurityPolicyRule
+	ServerGroup         ServerGroupStatus
+	ServerGroupList     []ServerGroupStatus
+	Quota               QuotaStatus
+	QuotaList           []QuotaStatus
+	Maintenance         CellMaintenanceStatus
+	MaintenanceList     []CellMaintenanceStatus
+	MaintenanceBatch    MaintenanceBatch
+	RebalancePlan       RebalancePlan
+	Rebalance           RebalanceStatus
+	RebalanceList       []RebalanceStatus
+	Labels              map[string]string
+	FailoverPreviewList []FailoverPreview
+	FailoverCapacity    FailoverCapacityStatus
+	SystemReset         SystemResetStatus
+	Data                []byte
+	Revision            uint64
+	ZoneBackup          ZoneBackup
+	ZoneRestore         ZoneRestoreReport
 	Total               int
 	Offset              int
 	Limit               int
 }
 
+// SystemResetStatus : latest reset of guest system since core started
+type SystemResetStatus struct {
+	Finished bool
+	Progress uint
+	Error    string
+}
+
 type SearchGuestsCondition struct {
 	Limit   int
 	Offset  int
@@ -183,13 +220,17 @@ type MigrationParameter struct {
 	TargetPool string
 	TargetCell string
 	Instances  []string
+	Selector   PlacementSelector
 }
 
 type MigrationStatus struct {
 	MigrationParameter
-	Finished bool
-	Progress uint
-	Error    error
+	Finished   bool
+	Progress   uint
+	Error      error
+	Recovered  bool //interrupted by restart, waiting for reconcile
+	CreateTime time.Time
+	FinishTime time.Time
 }
 
 type CellStatusReport struct {
@@ -208,10 +249,13 @@ const (
 )
 
 type BatchCreateRequest struct {
-	Rule   BatchCreateNameRule
-	Prefix string
-	Pool   string
-	Count  int
+	Rule     BatchCreateNameRule
+	Prefix   string
+	Pool     string
+	Count    int
+	User     string
+	Group    string
+	Resource InstanceResource //resource of each guest
 }
 
 type BatchTaskStatus int
@@ -337,13 +381,188 @@ type SecurityPolicyGroupQueryCondition struct {
 	GlobalOnly  bool
 }
 
+const (
+	ServerGroupPolicyAffinity     = "affinity"
+	ServerGroupPolicyAntiAffinity = "anti-affinity"
+)
+
+type ServerGroupConfig struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Policy      string `json:"policy"`
+	User        string `json:"user"`
+	Group       string `json:"group"`
+}
+
+type ServerGroupStatus struct {
+	ID string `json:"id"`
+	ServerGroupConfig
+	Members []string `json:"members,omitempty"`
+}
+
+type ServerGroupQueryCondition struct {
+	User  string
+	Group string
+}
+
+const (
+	QuotaScopeUser  = "user"
+	QuotaScopeGroup = "group"
+)
+
+// ResourceQuota : memory and disk in bytes, zero means unlimited when used as limit
+type ResourceQuota struct {
+	Cores             uint   `json:"cores"`
+	Memory            uint64 `json:"memory"`
+	Disk              uint64 `json:"disk"`
+	Guests            uint   `json:"guests"`
+	Snapshots         uint   `json:"snapshots"`
+	ExternalAddresses uint   `json:"external_addresses"`
+}
+
+type QuotaStatus struct {
+	Scope string        `json:"scope"`
+	Name  string        `json:"name"`
+	Limit ResourceQuota `json:"limit"`
+	Used  ResourceQuota `json:"used"`
+}
+
+// PlacementSelector : cell labels required or preferred by guest,
+// cells without all required labels are never selected, cells with more preferred labels are selected first
+type PlacementSelector struct {
+	Required  map[string]string `json:"required,omitempty"`
+	Preferred map[string]string `json:"preferred,omitempty"`
+}
+
+// CellMaintenanceStatus : drain progress of a cell under maintenance. Migration only moves stopped guests,
+// so running guests are waiting until stopped, unless stop_running specified: then they are stopped by the drain
+// and started again after moved, listed in restart until started
+type CellMaintenanceStatus struct {
+	Pool        string   `json:"pool"`
+	Cell        string   `json:"cell"`
+	StopRunning bool     `json:"stop_running"`
+	Finished    bool     `json:"finished"`
+	Total       int      `json:"total"`
+	Migrated    int      `json:"migrated"`
+	Remaining   int      `json:"remaining"`
+	Waiting     int      `json:"waiting"`
+	Progress    uint     `json:"progress"`
+	Migration   string   `json:"migration,omitempty"`
+	Restart     []string `json:"restart,omitempty"`
+	Blocked     string   `json:"blocked,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	StartTime   string   `json:"start_time"`
+}
+
+// MaintenanceBatch : next actions of drain, guests to stop before migrate and guests moved to start again
+type MaintenanceBatch struct {
+	Migration MigrationParameter
+	Stop      []string
+	Start     []string
+}
+
+// FailoverCellUsage : allocation of a surviving cell after failover, ratio in percentage of overcommit limit
+type FailoverCellUsage struct {
+	Name            string  `json:"name"`
+	Instances       int     `json:"instances"`
+	AllocatedCores  uint64  `json:"allocated_cores"`
+	CoreLimit       uint64  `json:"core_limit"`
+	CoreRatio       float64 `json:"core_ratio"`
+	AllocatedMemory uint64  `json:"allocated_memory"`
+	MemoryLimit     uint64  `json:"memory_limit"`
+	MemoryRatio     float64 `json:"memory_ratio"`
+}
+
+// FailoverPreview : plan built when the cell lost, without changing any status
+type FailoverPreview struct {
+	Cell        string              `json:"cell"`
+	Plan        map[string][]string `json:"plan"` //target cell => instances
+	Unplaceable []string            `json:"unplaceable,omitempty"`
+	Cells       []FailoverCellUsage `json:"cells"`
+}
+
+// PoolFailoverCapacity : guests of any Tolerance cells must be absorbable by the rest cells of pool,
+// allocation breaking the guarantee rejected when Enforce enabled
+type PoolFailoverCapacity struct {
+	Tolerance uint `json:"tolerance"`
+	Enforce   bool `json:"enforce"`
+}
+
+// FailoverCapacityStatus : result of the worst failover scenario, headroom is the spare capacity
+// left in surviving cells, negative when overcommit limits exceeded
+type FailoverCapacityStatus struct {
+	Config         PoolFailoverCapacity `json:"config"`
+	Enabled        bool                 `json:"enabled"`
+	Guaranteed     bool                 `json:"guaranteed"`
+	WorstCase      []string             `json:"worst_case,omitempty"`
+	Unplaceable    int                  `json:"unplaceable"`
+	HeadroomCores  int64                `json:"headroom_cores"`
+	HeadroomMemory int64                `json:"headroom_memory"`
+	CheckTime      string               `json:"check_time,omitempty"`
+}
+
+// PoolRebalanceConfig : rebalance when the difference between the most and the least loaded cell
+// exceeds threshold(percentage), check every interval minutes, zero interval for manual rebalance only
+type PoolRebalanceConfig struct {
+	Threshold float64 `json:"threshold"`
+	Interval  uint    `json:"interval"`
+}
+
+type RebalanceMove struct {
+	Instance   string `json:"instance"`
+	Name       string `json:"name"`
+	SourceCell string `json:"source_cell"`
+	TargetCell string `json:"target_cell"`
+}
+
+// RebalanceCellLoad : load is the highest of allocation ratio of cores and memory against overcommit limit,
+// and cpu and memory usage measured by cell
+type RebalanceCellLoad struct {
+	Name         string  `json:"name"`
+	Load         float64 `json:"load"`
+	ExpectedLoad float64 `json:"expected_load"`
+	CpuUsage     float64 `json:"cpu_usage"`
+	MemoryUsage  float64 `json:"memory_usage"`
+}
+
+type RebalancePlan struct {
+	Pool         string              `json:"pool"`
+	Config       PoolRebalanceConfig `json:"config"`
+	Skew         float64             `json:"skew"`
+	ExpectedSkew float64             `json:"expected_skew"`
+	Cells        []RebalanceCellLoad `json:"cells,omitempty"`
+	Moves        []RebalanceMove     `json:"moves,omitempty"`
+	Unmovable    int                 `json:"unmovable"`
+	Blocked      []RebalanceBlocked  `json:"blocked,omitempty"`
+}
+
+// RebalanceBlocked : instance on overloaded cell that can not move
+type RebalanceBlocked struct {
+	Instance string `json:"instance"`
+	Name     string `json:"name"`
+	Cell     string `json:"cell"`
+	Reason   string `json:"reason"`
+}
+
+type RebalanceStatus struct {
+	Pool      string `json:"pool"`
+	Scheduled bool   `json:"scheduled"`
+	Finished  bool   `json:"finished"`
+	Total     int    `json:"total"`
+	Migrated  int    `json:"migrated"`
+	Failed    int    `json:"failed"`
+	Migration string `json:"migration,omitempty"`
+	Error     string `json:"error,omitempty"`
+	StartTime string `json:"start_time"`
+}
+
 type ResourceModule interface {
 	//zone
 	QueryZoneStatus(resp chan ResourceResult)
 
 	//pools
-	CreatePool(name, storage, address string, failover bool, resultChan chan error)
-	ModifyPool(name, storage, address string, failover bool, resultChan chan error)
+	CreatePool(name, storage, address string, failover bool, strategy string, overcommit PoolOvercommitUpdate, resultChan chan error)
+	ModifyPool(name, storage, address string, failover bool, strategy string, overcommit PoolOvercommitUpdate, resultChan chan error)
 	DeletePool(name string, resultChan chan error)
 	GetAllComputePool(resp chan ResourceResult)
 	GetComputePool(pool string, resp chan ResourceResult)
@@ -364,7 +583,7 @@ type ResourceModule interface {
 	ModifyAddressPool(config AddressPoolConfig, respChan chan ResourceResult)
 	DeleteAddressPool(name string, respChan chan error)
 
-	QueryAddressRange(poolName, rangeType string, respChan chan ResourceResult)
+	QueryAddressRange(poolName, rangeType string, option ListOption, respChan chan ResourceResult)
 	GetAddressRange(poolName, rangeType, startAddress string, respChan chan ResourceResult)
 	AddAddressRange(poolName, rangeType string, config AddressRangeConfig, respChan chan error)
 	RemoveAddressRange(poolName, rangeType, startAddress string, respChan chan error)
@@ -384,6 +603,26 @@ type ResourceModule interface {
 	DisableCell(poolName, cellName string, purge bool, respChan chan error)
 	FinishPurgeCell(cellName string, respChan chan error)
 
+	//cell maintenance
+	QueryCellMaintenance(respChan chan ResourceResult)
+	GetCellMaintenance(poolName, cellName string, respChan chan ResourceResult)
+	StartCellMaintenance(poolName, cellName string, stopRunning bool, respChan chan error)
+	CancelCellMaintenance(poolName, cellName string, respChan chan error)
+	NextMaintenanceBatch(cellName string, respChan chan ResourceResult)
+	SetMaintenanceMigration(cellName, migrationID string, err error, respChan chan error)
+
+	//cell labels
+	GetCellLabels(poolName, cellName string, respChan chan ResourceResult)
+	ModifyCellLabels(poolName, cellName string, labels map[string]string, respChan chan error)
+
+	//pool rebalance
+	GetRebalancePlan(poolName string, respChan chan ResourceResult)
+	ModifyRebalanceConfig(poolName string, config PoolRebalanceConfig, respChan chan error)
+	StartRebalance(poolName string, respChan chan ResourceResult)
+	QueryRebalance(respChan chan ResourceResult)
+	NextRebalanceBatch(poolName string, respChan chan ResourceResult)
+	SetRebalanceMigration(poolName, migrationID string, err error, respChan chan error)
+
 	//instances
 	QueryGuestsByCondition(condition GuestQueryCondition, respChan chan ResourceResult)
 	BatchUpdateInstanceStatus(pool, cell string, instances []InstanceStatus, respChan chan error)
@@ -396,8 +635,9 @@ type ResourceModule interface {
 	DeallocateInstance(id string, err error, respChan chan error)
 
 	GetInstanceStatus(id string, respChan chan ResourceResult)
-	QueryInstanceStatusInPool(poolName string, respChan chan ResourceResult)
-	QueryInstanceStatusInCell(poolName, cellName string, respChan chan ResourceResult)
+	LookupInstance(id string, respChan chan ResourceResult)
+	QueryInstanceStatusInPool(poolName string, option ListOption, respChan chan ResourceResult)
+	QueryInstanceStatusInCell(poolName, cellName string, option ListOption, respChan chan ResourceResult)
 	UpdateInstanceAddress(id, ip string, respChan chan error)
 	RenameInstance(id, name string, respChan chan error)
 	GetInstanceByName(poolName, instanceName string, respChan chan ResourceResult)
@@ -413,7 +653,7 @@ type ResourceModule interface {
 	GetImageServer(respChan chan ResourceResult)
 
 	//migration
-	QueryMigration(respChan chan ResourceResult)
+	QueryMigration(option ListOption, respChan chan ResourceResult)
 	GetMigration(id string, respChan chan ResourceResult)
 	CreateMigration(params MigrationParameter, respChan chan ResourceResult)
 	FinishMigration(migration string, instances []string, ports []uint64, respChan chan error)
@@ -422,12 +662,16 @@ type ResourceModule interface {
 
 	//failover
 	BuildFailoverPlan(cellName string, respChan chan ResourceResult)
+	PreviewFailover(poolName, cellName string, respChan chan ResourceResult)
+	GetFailoverCapacity(poolName string, respChan chan ResourceResult)
+	ModifyFailoverCapacity(poolName string, config PoolFailoverCapacity, respChan chan error)
 	MigrateInstance(oldCell, newCell string, instances []string, ports []uint64, respChan chan error)
 	PurgeInstance(cellName string, respChan chan error)
 
 	//reset system
 	BeginResetSystem(instanceID string, respChan chan error)
 	FinishResetSystem(instanceID string, err error, respChan chan error)
+	GetResetSystemStatus(instanceID string, respChan chan ResourceResult)
 
 	//batch
 	StartBatchCreateGuest(request BatchCreateRequest, respChan chan ResourceResult)
@@ -452,7 +696,8 @@ type ResourceModule interface {
 	DeleteSystemTemplate(id string, respChan chan error)
 
 	//Security Policy Group
-	QuerySecurityPolicyGroups(condition SecurityPolicyGroupQueryCondition, respChan chan ResourceResult)
+	QuerySecurityPolicyGroups(condition SecurityPolicyGroupQueryCondition, option ListOption,
+		respChan chan ResourceResult)
 	GetSecurityPolicyGroup(groupID string, respChan chan ResourceResult)
 	CreateSecurityPolicyGroup(config SecurityPolicyGroup, respChan chan ResourceResult)
 	ModifySecurityPolicyGroup(groupID string, config SecurityPolicyGroup, respChan chan error)
@@ -463,6 +708,29 @@ type ResourceModule interface {
 	ModifySecurityPolicyRule(groupID string, index int, rule SecurityPolicyRule, respChan chan error)
 	RemoveSecurityPolicyRule(groupID string, index int, respChan chan error)
 	MoveSecurityPolicyRule(groupID string, index int, up bool, respChan chan error)
+
+	//Server Group
+	QueryServerGroups(condition ServerGroupQueryCondition, respChan chan ResourceResult)
+	GetServerGroup(groupID string, respChan chan ResourceResult)
+	CreateServerGroup(config ServerGroupConfig, respChan chan ResourceResult)
+	ModifyServerGroup(groupID string, config ServerGroupConfig, respChan chan error)
+	DeleteServerGroup(groupID string, respChan chan error)
+
+	//Quota
+	QueryQuotas(respChan chan ResourceResult)
+	GetQuota(scope, name string, respChan chan ResourceResult)
+	CreateQuota(scope, name string, limit ResourceQuota, respChan chan error)
+	ModifyQuota(scope, name string, limit ResourceQuota, respChan chan error)
+	DeleteQuota(scope, name string, respChan chan error)
+	CheckQuota(user, group string, required ResourceQuota, respChan chan error)
+	UpdateSnapshotCount(instanceID string, count uint, respChan chan error)
+
+	//high availability
+	ExportResourceData(revision uint64, respChan chan ResourceResult)
+
+	//zone backup
+	ExportZoneBackup(respChan chan ResourceResult)
+	RestoreZoneBackup(data ZoneBackupData, dryRun bool, respChan chan ResourceResult)
 }
 
 func (report *CellStatusReport) FromMessage(msg framework.Message) (err error) {
@@ -585,7 +853,10 @@ func CellsFromMessage(message framework.Message) (cells []ComputeCellInfo, err e
 }
 
 type Configurator struct {
-	operateTimeout time.Duration
+	operateTimeout  time.Duration
+	taskRetention   time.Duration
+	metadataStore   string
+	webhookNetworks []*net.IPNet
 }
 
 func (c *Configurator) SetOperateTimeout(timeoutInSeconds int) {
@@ -597,12 +868,52 @@ func (c *Configurator) GetOperateTimeout() time.Duration {
 	return c.operateTimeout
 }
 
+func (c *Configurator) SetTaskRetention(retentionInSeconds int) {
+	c.taskRetention = time.Duration(retentionInSeconds) * time.Second
+}
+
+// GetTaskRetention : how long finished migrations and batch tasks kept
+func (c *Configurator) GetTaskRetention() time.Duration {
+	return c.taskRetention
+}
+
+func (c *Configurator) SetMetadataStore(backend string) {
+	c.metadataStore = backend
+}
+
+// GetMetadataStore : backend of persistent metadata, json or kv
+func (c *Configurator) GetMetadataStore() string {
+	return c.metadataStore
+}
+
+// SetWebhookAllowedNetworks : networks in CIDR, such as "10.0.0.0/8"
+func (c *Configurator) SetWebhookAllowedNetworks(networks []string) (err error) {
+	var parsed []*net.IPNet
+	for _, network := range networks {
+		var ipNet *net.IPNet
+		if _, ipNet, err = net.ParseCIDR(network); err != nil {
+			err = fmt.Errorf("invalid webhook allowed network '%s': %s", network, err.Error())
+			return
+		}
+		parsed = append(parsed, ipNet)
+	}
+	c.webhookNetworks = parsed
+	return nil
+}
+
+// GetWebhookAllowedNetworks : private or local networks webhook may post to
+func (c *Configurator) GetWebhookAllowedNetworks() []*net.IPNet {
+	return c.webhookNetworks
+}
+
 const (
-	defaultOperateTimeout = 10 //10 seconds
+	defaultOperateTimeout = 10   //10 seconds
+	defaultTaskRetention  = 3600 //1 hour
 )
 
 var globalConfigurator = Configurator{
 	operateTimeout: defaultOperateTimeout * time.Second,
+	taskRetention:  defaultTaskRetention * time.Second,
 }
 
 func GetConfigurator() *Configurator {
@@ -0,0 +1,388 @@
+package modules
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	PlacementStrategyWeighted = "weighted"
+	PlacementStrategySpread   = "spread"
+	PlacementStrategyBinpack  = "binpack"
+	DefaultPlacementStrategy  = PlacementStrategyWeighted
+)
+
+const (
+	evaluateCoreFactor   = 2
+	evaluateMemoryFactor = 1.5
+	evaluateDiskFactor   = 0.5
+	evaluateTotalFactor  = evaluateCoreFactor + evaluateMemoryFactor + evaluateDiskFactor
+)
+
+// placementCandidate : snapshot of a cell when selecting target for new instance
+type placementCandidate struct {
+	Name            string
+	Usage           ResourceUsage //real time usage reported by cell
+	Instances       int
+	AllocatedCores  uint64
+	AllocatedMemory uint64
+	AllocatedDisk   uint64
+	CoreLimit       uint64
+	MemoryLimit     uint64
+	DiskLimit       uint64
+}
+
+type placementRequirement struct {
+	Cores  uint64
+	Memory uint64
+	Disk   uint64
+}
+
+// placementStrategy : score candidate cells, the cell with the highest score will be selected,
+// fit is false when candidate can not hold the requirement
+type placementStrategy interface {
+	Evaluate(candidate placementCandidate, required placementRequirement) (score float64, fit bool)
+}
+
+// weightedStrategy : the classic evaluation, average of real time capacity and configured capacity
+type weightedStrategy struct {
+}
+
+// spreadStrategy : prefer the cell with the lowest allocation, keep load even across pool
+type spreadStrategy struct {
+}
+
+// binpackStrategy : prefer the most allocated cell which still fits, free whole cells
+type binpackStrategy struct {
+}
+
+var placementStrategies = map[string]placementStrategy{
+	PlacementStrategyWeighted: &weightedStrategy{},
+	PlacementStrategySpread:   &spreadStrategy{},
+	PlacementStrategyBinpack:  &binpackStrategy{},
+}
+
+func getPlacementStrategy(name string) (strategy placementStrategy, err error) {
+	if "" == name {
+		name = DefaultPlacementStrategy
+	}
+	var exists bool
+	if strategy, exists = placementStrategies[name]; !exists {
+		err = fmt.Errorf("invalid placement strategy '%s'", name)
+		return
+	}
+	return strategy, nil
+}
+
+func (strategy *weightedStrategy) Evaluate(candidate placementCandidate, required placementRequirement) (score float64, fit bool) {
+	const (
+		fullCPUUsage = 100
+	)
+	var availableCores float64
+	if candidate.Usage.CpuUsage >= fullCPUUsage {
+		availableCores = 0
+	} else {
+		availableCores = float64(candidate.Usage.Cores) * (fullCPUUsage - candidate.Usage.CpuUsage) / 100
+	}
+	var realLoad = evaluateCoreFactor*capacityRatio(availableCores, required.Cores) +
+		evaluateMemoryFactor*capacityRatio(float64(candidate.Usage.MemoryAvailable), required.Memory) +
+		evaluateDiskFactor*capacityRatio(float64(candidate.Usage.DiskAvailable), required.Disk)
+
+	var configureLoad = evaluateCoreFactor*capacityRatio(float64(candidate.CoreLimit)-float64(candidate.AllocatedCores), required.Cores) +
+		evaluateMemoryFactor*capacityRatio(float64(candidate.MemoryLimit)-float64(candidate.AllocatedMemory), required.Memory) +
+		evaluateDiskFactor*capacityRatio(float64(candidate.DiskLimit)-float64(candidate.AllocatedDisk), required.Disk)
+	score = (realLoad + configureLoad) / 2
+	return score, score > 0
+}
+
+func (strategy *spreadStrategy) Evaluate(candidate placementCandidate, required placementRequirement) (score float64, fit bool) {
+	var load float64
+	if load, fit = allocationLoad(candidate, required); !fit {
+		return
+	}
+	return 1 - load, true
+}
+
+func (strategy *binpackStrategy) Evaluate(candidate placementCandidate, required placementRequirement) (score float64, fit bool) {
+	var load float64
+	if load, fit = allocationLoad(candidate, required); !fit {
+		return
+	}
+	return load, true
+}
+
+// allocationLoad : weighted allocation ratio of candidate after the requirement placed, range (0, 1]
+func allocationLoad(candidate placementCandidate, required placementRequirement) (load float64, fit bool) {
+	var coreLoad, memoryLoad, diskLoad float64
+	if coreLoad, fit = allocationRatio(candidate.AllocatedCores+required.Cores, candidate.CoreLimit); !fit {
+		return
+	}
+	if memoryLoad, fit = allocationRatio(candidate.AllocatedMemory+required.Memory, candidate.MemoryLimit); !fit {
+		return
+	}
+	if diskLoad, fit = allocationRatio(candidate.AllocatedDisk+required.Disk, candidate.DiskLimit); !fit {
+		return
+	}
+	load = (evaluateCoreFactor*coreLoad + evaluateMemoryFactor*memoryLoad + evaluateDiskFactor*diskLoad) / evaluateTotalFactor
+	return load, true
+}
+
+func allocationRatio(allocated, limit uint64) (ratio float64, fit bool) {
+	if 0 == limit || allocated > limit {
+		return 0, false
+	}
+	return float64(allocated) / float64(limit), true
+}
+
+func capacityRatio(available float64, required uint64) float64 {
+	if 0 == required {
+		return available
+	}
+	return available / float64(required)
+}
+
+// placementConstraint : cells restricted by the policy of server group and the label selector,
+// empty constraint allows any cell
+type placementConstraint struct {
+	Group    string
+	Policy   string
+	Required map[string]bool //affinity: cells hosting members
+	Excluded map[string]bool //anti-affinity: cells hosting members
+	Labels   PlacementSelector
+}
+
+func (constraint placementConstraint) allows(cellName string) bool {
+	if 0 != len(constraint.Required) && !constraint.Required[cellName] {
+		return false
+	}
+	return !constraint.Excluded[cellName]
+}
+
+// accepts : check both server group policy and required labels
+func (constraint placementConstraint) accepts(cell ManagedComputeCell) bool {
+	return constraint.allows(cell.Name) && constraint.Labels.Matches(cell.Labels)
+}
+
+func (constraint placementConstraint) isEmpty() bool {
+	return "" == constraint.Group && constraint.Labels.IsEmpty()
+}
+
+const (
+	selectorRequiredPrefix  = "required:"
+	selectorPreferredPrefix = "preferred:"
+)
+
+var labelPattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._/-]*[a-zA-Z0-9])?$`)
+
+func VerifyCellLabels(labels map[string]string) error {
+	for key, value := range labels {
+		if !labelPattern.MatchString(key) {
+			return fmt.Errorf("invalid label key '%s'", key)
+		}
+		if "" != value && !labelPattern.MatchString(value) {
+			return fmt.Errorf("invalid value '%s' of label '%s'", value, key)
+		}
+	}
+	return nil
+}
+
+func (selector PlacementSelector) IsEmpty() bool {
+	return 0 == len(selector.Required) && 0 == len(selector.Preferred)
+}
+
+func (selector PlacementSelector) Verify() error {
+	if err := VerifyCellLabels(selector.Required); err != nil {
+		return fmt.Errorf("required selector: %s", err.Error())
+	}
+	if err := VerifyCellLabels(selector.Preferred); err != nil {
+		return fmt.Errorf("preferred selector: %s", err.Error())
+	}
+	return nil
+}
+
+// Matches : labels contain all required key/value pairs
+func (selector PlacementSelector) Matches(labels map[string]string) bool {
+	for key, value := range selector.Required {
+		if current, exists := labels[key]; !exists || current != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Preference : count of preferred key/value pairs in labels
+func (selector PlacementSelector) Preference(labels map[string]string) int {
+	var matched = 0
+	for key, value := range selector.Preferred {
+		if current, exists := labels[key]; exists && current == value {
+			matched++
+		}
+	}
+	return matched
+}
+
+// ToTags : encode as 'required:key=value' and 'preferred:key=value' for messages
+func (selector PlacementSelector) ToTags() (tags []string) {
+	for key, value := range selector.Required {
+		tags = append(tags, fmt.Sprintf("%s%s=%s", selectorRequiredPrefix, key, value))
+	}
+	for key, value := range selector.Preferred {
+		tags = append(tags, fmt.Sprintf("%s%s=%s", selectorPreferredPrefix, key, value))
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+func ParsePlacementSelector(tags []string) (selector PlacementSelector, err error) {
+	for _, tag := range tags {
+		var target *map[string]string
+		var pair string
+		if strings.HasPrefix(tag, selectorRequiredPrefix) {
+			target = &selector.Required
+			pair = strings.TrimPrefix(tag, selectorRequiredPrefix)
+		} else if strings.HasPrefix(tag, selectorPreferredPrefix) {
+			target = &selector.Preferred
+			pair = strings.TrimPrefix(tag, selectorPreferredPrefix)
+		} else {
+			err = fmt.Errorf("invalid selector '%s'", tag)
+			return
+		}
+		var index = strings.Index(pair, "=")
+		if -1 == index {
+			err = fmt.Errorf("invalid selector '%s'", tag)
+			return
+		}
+		if nil == *target {
+			*target = map[string]string{}
+		}
+		(*target)[pair[:index]] = pair[index+1:]
+	}
+	err = selector.Verify()
+	return
+}
+
+const (
+	DefaultOvercommitRatio    = 3
+	DefaultCPUHealthThreshold = 80
+	overcommitOptionScale     = 100
+	overcommitOptionCount     = 4
+)
+
+func defaultPoolOvercommit() PoolOvercommit {
+	return PoolOvercommit{
+		CPURatio:           DefaultOvercommitRatio,
+		MemoryRatio:        DefaultOvercommitRatio,
+		DiskRatio:          DefaultOvercommitRatio,
+		CPUHealthThreshold: DefaultCPUHealthThreshold,
+	}
+}
+
+// PoolOvercommitUpdate : overcommit values specified in request, nil for unchanged
+type PoolOvercommitUpdate struct {
+	CPURatio           *float64
+	MemoryRatio        *float64
+	DiskRatio          *float64
+	CPUHealthThreshold *float64
+}
+
+// Apply : specified values override base, so an explicit zero is kept
+func (update PoolOvercommitUpdate) Apply(base PoolOvercommit) PoolOvercommit {
+	if nil != update.CPURatio {
+		base.CPURatio = *update.CPURatio
+	}
+	if nil != update.MemoryRatio {
+		base.MemoryRatio = *update.MemoryRatio
+	}
+	if nil != update.DiskRatio {
+		base.DiskRatio = *update.DiskRatio
+	}
+	if nil != update.CPUHealthThreshold {
+		base.CPUHealthThreshold = *update.CPUHealthThreshold
+	}
+	return base
+}
+
+// ToOptions : cpu ratio, memory ratio, disk ratio, cpu health threshold scaled by 100, then flags of specified values
+func (update PoolOvercommitUpdate) ToOptions() []uint64 {
+	var options = make([]uint64, overcommitOptionCount+1)
+	for index, value := range update.values() {
+		if nil != value {
+			options[index] = uint64(*value * overcommitOptionScale)
+			options[overcommitOptionCount] |= 1 << uint(index)
+		}
+	}
+	return options
+}
+
+func (update *PoolOvercommitUpdate) FromOptions(options []uint64) error {
+	if overcommitOptionCount+1 != len(options) {
+		return fmt.Errorf("unexpected overcommit options count %d", len(options))
+	}
+	var specified = options[overcommitOptionCount]
+	var values = make([]*float64, overcommitOptionCount)
+	for index := range values {
+		if 0 != specified&(1<<uint(index)) {
+			var value = float64(options[index]) / overcommitOptionScale
+			values[index] = &value
+		}
+	}
+	update.CPURatio, update.MemoryRatio, update.DiskRatio, update.CPUHealthThreshold = values[0], values[1], values[2], values[3]
+	return nil
+}
+
+func (update PoolOvercommitUpdate) values() []*float64 {
+	return []*float64{update.CPURatio, update.MemoryRatio, update.DiskRatio, update.CPUHealthThreshold}
+}
+
+func (overcommit PoolOvercommit) Verify() error {
+	if overcommit.CPURatio <= 0 {
+		return fmt.Errorf("invalid cpu overcommit ratio %.2f", overcommit.CPURatio)
+	}
+	if overcommit.MemoryRatio <= 0 {
+		return fmt.Errorf("invalid memory overcommit ratio %.2f", overcommit.MemoryRatio)
+	}
+	if overcommit.DiskRatio <= 0 {
+		return fmt.Errorf("invalid disk overcommit ratio %.2f", overcommit.DiskRatio)
+	}
+	if overcommit.CPUHealthThreshold < 0 || overcommit.CPUHealthThreshold > 100 {
+		return fmt.Errorf("invalid cpu health threshold %.2f%%", overcommit.CPUHealthThreshold)
+	}
+	return nil
+}
+
+// ToOptions : cpu ratio, memory ratio, disk ratio, cpu health threshold, scaled by 100
+func (overcommit PoolOvercommit) ToOptions() []uint64 {
+	return []uint64{
+		uint64(overcommit.CPURatio * overcommitOptionScale),
+		uint64(overcommit.MemoryRatio * overcommitOptionScale),
+		uint64(overcommit.DiskRatio * overcommitOptionScale),
+		uint64(overcommit.CPUHealthThreshold * overcommitOptionScale),
+	}
+}
+
+func (overcommit *PoolOvercommit) FromOptions(options []uint64) error {
+	if overcommitOptionCount != len(options) {
+		return fmt.Errorf("unexpected overcommit options count %d", len(options))
+	}
+	overcommit.CPURatio = float64(options[0]) / overcommitOptionScale
+	overcommit.MemoryRatio = float64(options[1]) / overcommitOptionScale
+	overcommit.DiskRatio = float64(options[2]) / overcommitOptionScale
+	overcommit.CPUHealthThreshold = float64(options[3]) / overcommitOptionScale
+	return nil
+}
+
+// exceededResource : the first resource exceeds its overcommit limit after requirement placed, empty when all fit
+func exceededResource(candidate placementCandidate, required placementRequirement) string {
+	if candidate.AllocatedCores+required.Cores > candidate.CoreLimit {
+		return "cpu"
+	}
+	if candidate.AllocatedMemory+required.Memory > candidate.MemoryLimit {
+		return "memory"
+	}
+	if candidate.AllocatedDisk+required.Disk > candidate.DiskLimit {
+		return "disk"
+	}
+	return ""
+}
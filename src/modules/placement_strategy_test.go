@@ -0,0 +1,275 @@
+package modules
+
+import (
+	"testing"
+)
+
+func selectPlacementForTest(t *testing.T, strategyName string, candidates []placementCandidate, required placementRequirement) (selected string) {
+	strategy, err := getPlacementStrategy(strategyName)
+	if err != nil {
+		t.Fatalf("get strategy '%s' fail: %s", strategyName, err.Error())
+	}
+	var highest float64
+	for _, candidate := range candidates {
+		score, fit := strategy.Evaluate(candidate, required)
+		if !fit {
+			continue
+		}
+		if "" == selected || score > highest {
+			selected = candidate.Name
+			highest = score
+		}
+	}
+	return
+}
+
+func TestPlacementStrategy_Select(t *testing.T) {
+	const (
+		gib = 1 << 30
+	)
+	var idle = placementCandidate{Name: "idle", AllocatedCores: 2, AllocatedMemory: 4 * gib, AllocatedDisk: 50 * gib,
+		CoreLimit: 32, MemoryLimit: 64 * gib, DiskLimit: 1000 * gib,
+		Usage: ResourceUsage{Cores: 16, CpuUsage: 10, MemoryAvailable: 28 * gib, DiskAvailable: 900 * gib}}
+	var busy = placementCandidate{Name: "busy", AllocatedCores: 24, AllocatedMemory: 48 * gib, AllocatedDisk: 600 * gib,
+		CoreLimit: 32, MemoryLimit: 64 * gib, DiskLimit: 1000 * gib,
+		Usage: ResourceUsage{Cores: 16, CpuUsage: 70, MemoryAvailable: 8 * gib, DiskAvailable: 400 * gib}}
+	var full = placementCandidate{Name: "full", AllocatedCores: 31, AllocatedMemory: 62 * gib, AllocatedDisk: 900 * gib,
+		CoreLimit: 32, MemoryLimit: 64 * gib, DiskLimit: 1000 * gib,
+		Usage: ResourceUsage{Cores: 16, CpuUsage: 95, MemoryAvailable: 1 * gib, DiskAvailable: 100 * gib}}
+	var small = placementRequirement{Cores: 1, Memory: 1 * gib, Disk: 10 * gib}
+	var large = placementRequirement{Cores: 4, Memory: 8 * gib, Disk: 100 * gib}
+	var testCases = []struct {
+		Name       string
+		Strategy   string
+		Candidates []placementCandidate
+		Required   placementRequirement
+		Expected   string
+	}{
+		{"default weighted prefers idle", "", []placementCandidate{busy, idle, full}, small, "idle"},
+		{"spread prefers lowest allocation", PlacementStrategySpread, []placementCandidate{busy, idle, full}, small, "idle"},
+		{"binpack prefers most allocated", PlacementStrategyBinpack, []placementCandidate{idle, busy, full}, small, "full"},
+		{"binpack skips cell not fit", PlacementStrategyBinpack, []placementCandidate{idle, busy, full}, large, "busy"},
+		{"spread skips cell not fit", PlacementStrategySpread, []placementCandidate{full}, large, ""},
+		{"zero limit never fits", PlacementStrategyBinpack, []placementCandidate{{Name: "empty"}}, small, ""},
+	}
+	for _, testCase := range testCases {
+		if selected := selectPlacementForTest(t, testCase.Strategy, testCase.Candidates, testCase.Required); selected != testCase.Expected {
+			t.Fatalf("%s: '%s' selected, '%s' expected", testCase.Name, selected, testCase.Expected)
+		}
+	}
+}
+
+func TestPlacementStrategy_Invalid(t *testing.T) {
+	if _, err := getPlacementStrategy("random"); err == nil {
+		t.Fatalf("invalid strategy accepted")
+	}
+}
+
+func TestPlacementStrategy_ExceededResource(t *testing.T) {
+	var candidate = placementCandidate{AllocatedCores: 6, AllocatedMemory: 6, AllocatedDisk: 6, CoreLimit: 8, MemoryLimit: 8, DiskLimit: 8}
+	var testCases = []struct {
+		Name     string
+		Required placementRequirement
+		Expected string
+	}{
+		{"all fit", placementRequirement{2, 2, 2}, ""},
+		{"cpu exceeded", placementRequirement{3, 3, 3}, "cpu"},
+		{"memory exceeded", placementRequirement{1, 3, 3}, "memory"},
+		{"disk exceeded", placementRequirement{1, 1, 3}, "disk"},
+	}
+	for _, testCase := range testCases {
+		if exceeded := exceededResource(candidate, testCase.Required); exceeded != testCase.Expected {
+			t.Fatalf("%s: '%s' exceeded, '%s' expected", testCase.Name, exceeded, testCase.Expected)
+		}
+	}
+}
+
+func overcommitValue(value float64) *float64 {
+	return &value
+}
+
+func TestPoolOvercommitUpdate_Apply(t *testing.T) {
+	var base = defaultPoolOvercommit()
+	var testCases = []struct {
+		Name     string
+		Update   PoolOvercommitUpdate
+		Expected PoolOvercommit
+	}{
+		{"empty takes base", PoolOvercommitUpdate{}, base},
+		{"specified kept", PoolOvercommitUpdate{CPURatio: overcommitValue(1.5), MemoryRatio: overcommitValue(1),
+			DiskRatio: overcommitValue(2), CPUHealthThreshold: overcommitValue(90)},
+			PoolOvercommit{CPURatio: 1.5, MemoryRatio: 1, DiskRatio: 2, CPUHealthThreshold: 90}},
+		{"partial applied", PoolOvercommitUpdate{MemoryRatio: overcommitValue(1.2)},
+			PoolOvercommit{CPURatio: DefaultOvercommitRatio, MemoryRatio: 1.2, DiskRatio: DefaultOvercommitRatio, CPUHealthThreshold: DefaultCPUHealthThreshold}},
+		{"explicit zero", PoolOvercommitUpdate{CPUHealthThreshold: overcommitValue(0)},
+			PoolOvercommit{CPURatio: DefaultOvercommitRatio, MemoryRatio: DefaultOvercommitRatio, DiskRatio: DefaultOvercommitRatio}},
+	}
+	for _, testCase := range testCases {
+		if applied := testCase.Update.Apply(base); applied != testCase.Expected {
+			t.Fatalf("%s: applied %+v, %+v expected", testCase.Name, applied, testCase.Expected)
+		}
+	}
+}
+
+func TestPoolOvercommit_Verify(t *testing.T) {
+	var base = defaultPoolOvercommit()
+	var testCases = []struct {
+		Name   string
+		Update PoolOvercommitUpdate
+		Valid  bool
+	}{
+		{"default", PoolOvercommitUpdate{}, true},
+		{"undercommit", PoolOvercommitUpdate{CPURatio: overcommitValue(0.5)}, true},
+		{"zero threshold", PoolOvercommitUpdate{CPUHealthThreshold: overcommitValue(0)}, true},
+		{"zero cpu ratio", PoolOvercommitUpdate{CPURatio: overcommitValue(0)}, false},
+		{"negative cpu ratio", PoolOvercommitUpdate{CPURatio: overcommitValue(-1)}, false},
+		{"negative memory ratio", PoolOvercommitUpdate{MemoryRatio: overcommitValue(-0.5)}, false},
+		{"zero disk ratio", PoolOvercommitUpdate{DiskRatio: overcommitValue(0)}, false},
+		{"threshold over 100", PoolOvercommitUpdate{CPUHealthThreshold: overcommitValue(101)}, false},
+		{"negative threshold", PoolOvercommitUpdate{CPUHealthThreshold: overcommitValue(-1)}, false},
+	}
+	for _, testCase := range testCases {
+		if err := testCase.Update.Apply(base).Verify(); (nil == err) != testCase.Valid {
+			t.Fatalf("%s: unexpected verify result %v", testCase.Name, err)
+		}
+	}
+}
+
+func TestPoolOvercommit_Options(t *testing.T) {
+	var origin = PoolOvercommit{CPURatio: 2.5, MemoryRatio: 1.25, DiskRatio: 4, CPUHealthThreshold: 85}
+	var restored PoolOvercommit
+	if err := restored.FromOptions(origin.ToOptions()); err != nil {
+		t.Fatalf("restore options fail: %s", err.Error())
+	}
+	if restored != origin {
+		t.Fatalf("restored %+v, %+v expected", restored, origin)
+	}
+	if err := restored.FromOptions([]uint64{100}); err == nil {
+		t.Fatalf("incomplete options accepted")
+	}
+	//unspecified and explicit zero distinguished
+	var update = PoolOvercommitUpdate{MemoryRatio: overcommitValue(1.25), CPUHealthThreshold: overcommitValue(0)}
+	var restoredUpdate PoolOvercommitUpdate
+	if err := restoredUpdate.FromOptions(update.ToOptions()); err != nil {
+		t.Fatalf("restore update options fail: %s", err.Error())
+	}
+	if applied, expected := restoredUpdate.Apply(origin), update.Apply(origin); applied != expected {
+		t.Fatalf("restored update applied as %+v, %+v expected", applied, expected)
+	}
+	if err := restoredUpdate.FromOptions(origin.ToOptions()); err == nil {
+		t.Fatalf("update options without flags accepted")
+	}
+}
+
+func TestPlacementSelector_Parse(t *testing.T) {
+	var testCases = []struct {
+		Name      string
+		Tags      []string
+		Valid     bool
+		Required  int
+		Preferred int
+	}{
+		{"empty", nil, true, 0, 0},
+		{"required and preferred", []string{"required:zone=a", "required:ssd=", "preferred:rack=r1"}, true, 2, 1},
+		{"missing prefix", []string{"zone=a"}, false, 0, 0},
+		{"missing value separator", []string{"required:zone"}, false, 0, 0},
+		{"invalid key", []string{"required:-zone=a"}, false, 0, 0},
+		{"invalid value", []string{"preferred:zone=a b"}, false, 0, 0},
+	}
+	for _, testCase := range testCases {
+		selector, err := ParsePlacementSelector(testCase.Tags)
+		if (nil == err) != testCase.Valid {
+			t.Fatalf("%s: unexpected parse result %v", testCase.Name, err)
+		}
+		if !testCase.Valid {
+			continue
+		}
+		if len(selector.Required) != testCase.Required || len(selector.Preferred) != testCase.Preferred {
+			t.Fatalf("%s: unexpected selector %+v", testCase.Name, selector)
+		}
+		restored, err := ParsePlacementSelector(selector.ToTags())
+		if err != nil {
+			t.Fatalf("%s: parse tags fail: %s", testCase.Name, err.Error())
+		}
+		if len(restored.ToTags()) != len(testCase.Tags) {
+			t.Fatalf("%s: tags %v restored as %v", testCase.Name, testCase.Tags, restored.ToTags())
+		}
+	}
+}
+
+func TestPlacementSelector_Matches(t *testing.T) {
+	var selector = PlacementSelector{
+		Required:  map[string]string{"zone": "a", "ssd": ""},
+		Preferred: map[string]string{"rack": "r1", "gpu": "t4"},
+	}
+	var testCases = []struct {
+		Name       string
+		Labels     map[string]string
+		Matched    bool
+		Preference int
+	}{
+		{"no labels", nil, false, 0},
+		{"all required", map[string]string{"zone": "a", "ssd": ""}, true, 0},
+		{"different value", map[string]string{"zone": "b", "ssd": ""}, false, 0},
+		{"preferred without required", map[string]string{"rack": "r1", "gpu": "t4"}, false, 2},
+		{"required and preferred", map[string]string{"zone": "a", "ssd": "", "rack": "r1", "gpu": "a100"}, true, 1},
+	}
+	for _, testCase := range testCases {
+		if matched := selector.Matches(testCase.Labels); matched != testCase.Matched {
+			t.Fatalf("%s: matched %t, %t expected", testCase.Name, matched, testCase.Matched)
+		}
+		if preference := selector.Preference(testCase.Labels); preference != testCase.Preference {
+			t.Fatalf("%s: preference %d, %d expected", testCase.Name, preference, testCase.Preference)
+		}
+	}
+}
+
+func computeCellForTest(name string, labels map[string]string) (cell ManagedComputeCell) {
+	cell.Name = name
+	cell.Labels = labels
+	return
+}
+
+func TestPlacementConstraint_Accepts(t *testing.T) {
+	var labels = map[string]string{"zone": "a"}
+	var testCases = []struct {
+		Name       string
+		Constraint placementConstraint
+		Cell       ManagedComputeCell
+		Accepted   bool
+	}{
+		{"empty constraint", placementConstraint{}, computeCellForTest("c1", nil), true},
+		{"affinity member cell", placementConstraint{Required: map[string]bool{"c1": true}}, computeCellForTest("c1", nil), true},
+		{"affinity other cell", placementConstraint{Required: map[string]bool{"c1": true}}, computeCellForTest("c2", nil), false},
+		{"anti-affinity member cell", placementConstraint{Excluded: map[string]bool{"c1": true}}, computeCellForTest("c1", nil), false},
+		{"label matched", placementConstraint{Labels: PlacementSelector{Required: labels}}, computeCellForTest("c1", labels), true},
+		{"label missing", placementConstraint{Labels: PlacementSelector{Required: labels}}, computeCellForTest("c1", nil), false},
+	}
+	for _, testCase := range testCases {
+		if accepted := testCase.Constraint.accepts(testCase.Cell); accepted != testCase.Accepted {
+			t.Fatalf("%s: accepted %t, %t expected", testCase.Name, accepted, testCase.Accepted)
+		}
+	}
+}
+
+func TestServerGroup_Accepted(t *testing.T) {
+	var testCases = []struct {
+		Name     string
+		Config   ServerGroupConfig
+		User     string
+		Group    string
+		Accepted bool
+	}{
+		{"shared group", ServerGroupConfig{}, "alice", "dev", true},
+		{"owner matched", ServerGroupConfig{User: "alice"}, "alice", "", true},
+		{"other owner", ServerGroupConfig{User: "alice"}, "bob", "dev", false},
+		{"group matched", ServerGroupConfig{User: "alice", Group: "dev"}, "bob", "dev", true},
+		{"other group", ServerGroupConfig{Group: "dev"}, "bob", "ops", false},
+		{"empty group not matched", ServerGroupConfig{User: "alice"}, "bob", "", false},
+	}
+	for _, testCase := range testCases {
+		if accepted := serverGroupAccepted(testCase.Config, testCase.User, testCase.Group); accepted != testCase.Accepted {
+			t.Fatalf("%s: accepted %t, %t expected", testCase.Name, accepted, testCase.Accepted)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package modules
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	DefaultRebalanceThreshold = 20
+	rebalanceMaxMoves         = 20
+	rebalanceBatchSize        = 5
+	rebalanceCheckInterval    = time.Minute
+)
+
+// poolRebalanceTask : migrations of plan executed batch by batch, only one batch in progress
+type poolRebalanceTask struct {
+	Status  RebalanceStatus
+	Batches []MigrationParameter
+	Next    int
+}
+
+// rebalanceCell : allocation of a cell when computing plan, usage measured by cell not changed by moving stopped instance
+type rebalanceCell struct {
+	Name      string
+	Candidate placementCandidate
+	Usage     float64
+	Instances []string
+}
+
+func defaultRebalanceConfig() PoolRebalanceConfig {
+	return PoolRebalanceConfig{Threshold: DefaultRebalanceThreshold}
+}
+
+func (config PoolRebalanceConfig) Verify() error {
+	if config.Threshold <= 0 || config.Threshold > 100 {
+		return fmt.Errorf("invalid rebalance threshold %.2f", config.Threshold)
+	}
+	return nil
+}
+
+func (cell rebalanceCell) load() float64 {
+	var ratio = func(allocated, limit uint64) float64 {
+		if 0 == limit {
+			if 0 == allocated {
+				return 0
+			}
+			return 100
+		}
+		return float64(allocated) * 100 / float64(limit)
+	}
+	var allocated = math.Max(ratio(cell.Candidate.AllocatedCores, cell.Candidate.CoreLimit),
+		ratio(cell.Candidate.AllocatedMemory, cell.Candidate.MemoryLimit))
+	return math.Max(allocated, cell.Usage)
+}
+
+func poolSkew(cells []rebalanceCell) float64 {
+	if len(cells) < 2 {
+		return 0
+	}
+	var highest, lowest = cells[0].load(), cells[0].load()
+	for _, cell := range cells[1:] {
+		var load = cell.load()
+		highest = math.Max(highest, load)
+		lowest = math.Min(lowest, load)
+	}
+	return highest - lowest
+}
+
+// buildRebalancePlan : move stopped instances from the most loaded cell to the least loaded one,
+// until skew under threshold or no move improves the balance.
+// Migration only moves stopped instances, running ones on the loaded cell are reported as blocked
+func (manager *ResourceManager) buildRebalancePlan(poolName string) (plan RebalancePlan, err error) {
+	pool, exists := manager.pools[poolName]
+	if !exists {
+		err = fmt.Errorf("invalid compute pool '%s'", poolName)
+		return
+	}
+	plan.Pool = poolName
+	plan.Config = pool.Rebalance
+	var cellNames []string
+	for cellName, _ := range pool.Cells {
+		cellNames = append(cellNames, cellName)
+	}
+	sort.Stable(sort.StringSlice(cellNames))
+	var cells []rebalanceCell
+	var usages = map[string]RebalanceCellLoad{}
+	for _, cellName := range cellNames {
+		cell, exists := manager.cells[cellName]
+		if !exists {
+			err = fmt.Errorf("invalid cell '%s' in pool '%s'", cellName, poolName)
+			return
+		}
+		if !cell.Alive || !cell.Enabled {
+			continue
+		}
+		var current = rebalanceCell{Name: cellName}
+		if current.Candidate, err = manager.buildPlacementCandidate(cell, pool.PoolOvercommit); err != nil {
+			return
+		}
+		for instanceID, _ := range cell.Instances {
+			current.Instances = append(current.Instances, instanceID)
+		}
+		sort.Stable(sort.StringSlice(current.Instances))
+		var usage = RebalanceCellLoad{Name: cellName, CpuUsage: cell.CpuUsage}
+		if 0 != cell.Memory && cell.Memory >= cell.MemoryAvailable {
+			usage.MemoryUsage = float64(cell.Memory-cell.MemoryAvailable) * 100 / float64(cell.Memory)
+		}
+		current.Usage = math.Max(usage.CpuUsage, usage.MemoryUsage)
+		usage.Load = current.load()
+		usages[cellName] = usage
+		cells = append(cells, current)
+	}
+	plan.Skew = poolSkew(cells)
+	var relocated = map[string]string{}
+	var unmovable = map[string]RebalanceBlocked{}
+	for len(plan.Moves) < rebalanceMaxMoves && len(cells) >= 2 {
+		sort.SliceStable(cells, func(i, j int) bool {
+			return cells[i].load() > cells[j].load()
+		})
+		var source, target = &cells[0], &cells[len(cells)-1]
+		var sourceLoad = source.load()
+		if sourceLoad-target.load() <= pool.Rebalance.Threshold {
+			break
+		}
+		var selected = -1
+		var selectedLoad = sourceLoad
+		for index, instanceID := range source.Instances {
+			if _, moved := relocated[instanceID]; moved {
+				continue
+			}
+			ins, exists := manager.instances[instanceID]
+			if !exists {
+				continue
+			}
+			if ins.Running || ins.Migrating {
+				var blocked = RebalanceBlocked{Instance: instanceID, Name: ins.Name, Cell: source.Name}
+				if ins.Migrating {
+					blocked.Reason = "already in migrating"
+				} else {
+					blocked.Reason = "running, live migration not supported"
+				}
+				unmovable[instanceID] = blocked
+				continue
+			}
+			var constraint placementConstraint
+			if constraint, err = manager.instanceConstraint(instanceID, map[string]bool{instanceID: true}, relocated); err != nil {
+				return
+			}
+			if !constraint.accepts(manager.cells[target.Name]) {
+				continue
+			}
+			var cores, memory = uint64(ins.Cores), uint64(ins.Memory)
+			if target.Candidate.AllocatedCores+cores > target.Candidate.CoreLimit ||
+				target.Candidate.AllocatedMemory+memory > target.Candidate.MemoryLimit {
+				continue
+			}
+			var sourceAfter, targetAfter = *source, *target
+			sourceAfter.Candidate.AllocatedCores -= cores
+			sourceAfter.Candidate.AllocatedMemory -= memory
+			targetAfter.Candidate.AllocatedCores += cores
+			targetAfter.Candidate.AllocatedMemory += memory
+			var highest = math.Max(sourceAfter.load(), targetAfter.load())
+			if highest < selectedLoad {
+				selected = index
+				selectedLoad = highest
+			}
+		}
+		if -1 == selected {
+			break
+		}
+		var instanceID = source.Instances[selected]
+		var ins = manager.instances[instanceID]
+		source.Candidate.AllocatedCores -= uint64(ins.Cores)
+		source.Candidate.AllocatedMemory -= uint64(ins.Memory)
+		target.Candidate.AllocatedCores += uint64(ins.Cores)
+		target.Candidate.AllocatedMemory += uint64(ins.Memory)
+		relocated[instanceID] = target.Name
+		plan.Moves = append(plan.Moves, RebalanceMove{Instance: instanceID, Name: ins.Name,
+			SourceCell: source.Name, TargetCell: target.Name})
+	}
+	plan.ExpectedSkew = poolSkew(cells)
+	plan.Unmovable = len(unmovable)
+	for _, blocked := range unmovable {
+		plan.Blocked = append(plan.Blocked, blocked)
+	}
+	sort.Slice(plan.Blocked, func(i, j int) bool {
+		return plan.Blocked[i].Instance < plan.Blocked[j].Instance
+	})
+	for _, cell := range cells {
+		var usage = usages[cell.Name]
+		usage.ExpectedLoad = cell.load()
+		usages[cell.Name] = usage
+	}
+	for _, cellName := range cellNames {
+		if usage, exists := usages[cellName]; exists {
+			plan.Cells = append(plan.Cells, usage)
+		}
+	}
+	return plan, nil
+}
+
+// startRebalanceTask : group moves into migrations between the same cells
+func (manager *ResourceManager) startRebalanceTask(plan RebalancePlan, scheduled bool) {
+	var task = poolRebalanceTask{}
+	task.Status = RebalanceStatus{
+		Pool:      plan.Pool,
+		Scheduled: scheduled,
+		Total:     len(plan.Moves),
+		StartTime: time.Now().Format(TimeFormatLayout),
+	}
+	var pending = map[string]int{} //source:target => batch index
+	for _, move := range plan.Moves {
+		var key = fmt.Sprintf("%s:%s", move.SourceCell, move.TargetCell)
+		index, exists := pending[key]
+		if !exists || len(task.Batches[index].Instances) >= rebalanceBatchSize {
+			task.Batches = append(task.Batches, MigrationParameter{SourcePool: plan.Pool, SourceCell: move.SourceCell,
+				TargetPool: plan.Pool, TargetCell: move.TargetCell})
+			index = len(task.Batches) - 1
+			pending[key] = index
+		}
+		task.Batches[index].Instances = append(task.Batches[index].Instances, move.Instance)
+	}
+	manager.rebalances[plan.Pool] = task
+	log.Printf("<resource_manager> rebalance pool '%s' started, %d instance(s) in %d migration(s), skew %.2f%% => %.2f%%",
+		plan.Pool, len(plan.Moves), len(task.Batches), plan.Skew, plan.ExpectedSkew)
+}
+
+// refreshRebalance : check result of batch in progress
+func (manager *ResourceManager) refreshRebalance(task poolRebalanceTask) poolRebalanceTask {
+	if task.Status.Finished || "" == task.Status.Migration {
+		return task
+	}
+	if migration, exists := manager.migrations[task.Status.Migration]; exists && !migration.Finished && nil == migration.Error {
+		//in progress
+		return task
+	} else if exists && nil != migration.Error {
+		task.Status.Error = migration.Error.Error()
+	}
+	var batch = task.Batches[task.Next]
+	for _, instanceID := range batch.Instances {
+		if ins, exists := manager.instances[instanceID]; exists && ins.Cell == batch.TargetCell {
+			task.Status.Migrated++
+		} else {
+			task.Status.Failed++
+		}
+	}
+	task.Status.Migration = ""
+	task.Next++
+	if task.Next >= len(task.Batches) {
+		task.Status.Finished = true
+		log.Printf("<resource_manager> rebalance pool '%s' finished, %d migrated, %d failed",
+			task.Status.Pool, task.Status.Migrated, task.Status.Failed)
+	}
+	manager.rebalances[task.Status.Pool] = task
+	return task
+}
+
+// scheduleRebalance : start rebalance on pools with interval configured
+func (manager *ResourceManager) scheduleRebalance() {
+	var now = time.Now()
+	for poolName, pool := range manager.pools {
+		if 0 == pool.Rebalance.Interval || !pool.Enabled {
+			continue
+		}
+		if now.Before(pool.NextRebalance) {
+			continue
+		}
+		pool.NextRebalance = now.Add(time.Duration(pool.Rebalance.Interval) * time.Minute)
+		manager.pools[poolName] = pool
+		if task, exists := manager.rebalances[poolName]; exists && !task.Status.Finished {
+			continue
+		}
+		plan, err := manager.buildRebalancePlan(poolName)
+		if err != nil {
+			log.Printf("<resource_manager> warning: build rebalance plan of pool '%s' fail: %s", poolName, err.Error())
+			continue
+		}
+		if 0 == len(plan.Moves) {
+			continue
+		}
+		manager.startRebalanceTask(plan, true)
+	}
+}
+
+func (manager *ResourceManager) handleGetRebalancePlan(poolName string, respChan chan ResourceResult) (err error) {
+	var result ResourceResult
+	if result.RebalancePlan, err = manager.buildRebalancePlan(poolName); err != nil {
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	if task, exists := manager.rebalances[poolName]; exists {
+		result.Rebalance = manager.refreshRebalance(task).Status
+	}
+	respChan <- result
+	return nil
+}
+
+func (manager *ResourceManager) handleModifyRebalanceConfig(poolName string, config PoolRebalanceConfig, respChan chan error) (err error) {
+	pool, exists := manager.pools[poolName]
+	if !exists {
+		err = fmt.Errorf("invalid compute pool '%s'", poolName)
+		respChan <- err
+		return err
+	}
+	if err = config.Verify(); err != nil {
+		respChan <- err
+		return err
+	}
+	if pool.Rebalance == config {
+		err = fmt.Errorf("no need to change rebalance config of pool '%s'", poolName)
+		respChan <- err
+		return err
+	}
+	pool.Rebalance = config
+	pool.NextRebalance = time.Time{}
+	manager.pools[poolName] = pool
+	log.Printf("<resource_manager> rebalance of pool '%s' changed, threshold %.2f%%, interval %d minute(s)",
+		poolName, config.Threshold, config.Interval)
+	respChan <- nil
+	return manager.saveConfig()
+}
+
+func (manager *ResourceManager) handleStartRebalance(poolName string, respChan chan ResourceResult) (err error) {
+	if task, exists := manager.rebalances[poolName]; exists && !manager.refreshRebalance(task).Status.Finished {
+		err = fmt.Errorf("pool '%s' already in rebalancing", poolName)
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	plan, err := manager.buildRebalancePlan(poolName)
+	if err != nil {
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	if 0 == len(plan.Moves) {
+		if 0 != len(plan.Blocked) {
+			err = fmt.Errorf("no stopped instance can balance pool '%s', skew %.2f%%, %d instance(s) blocked",
+				poolName, plan.Skew, len(plan.Blocked))
+		} else {
+			err = fmt.Errorf("no instance need to move in pool '%s', skew %.2f%%", poolName, plan.Skew)
+		}
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	manager.startRebalanceTask(plan, false)
+	respChan <- ResourceResult{RebalancePlan: plan, Rebalance: manager.rebalances[poolName].Status}
+	return nil
+}
+
+func (manager *ResourceManager) handleQueryRebalance(respChan chan ResourceResult) (err error) {
+	var names []string
+	for poolName, _ := range manager.rebalances {
+		names = append(names, poolName)
+	}
+	sort.Stable(sort.StringSlice(names))
+	var result ResourceResult
+	for _, poolName := range names {
+		var task = manager.refreshRebalance(manager.rebalances[poolName])
+		result.RebalanceList = append(result.RebalanceList, task.Status)
+	}
+	respChan <- result
+	return nil
+}
+
+// handleNextRebalanceBatch : empty instances returned when rebalance finished or previous batch still migrating
+func (manager *ResourceManager) handleNextRebalanceBatch(poolName string, respChan chan ResourceResult) (err error) {
+	task, exists := manager.rebalances[poolName]
+	if !exists {
+		err = fmt.Errorf("pool '%s' not in rebalancing", poolName)
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	task = manager.refreshRebalance(task)
+	if task.Status.Finished || "" != task.Status.Migration {
+		respChan <- ResourceResult{}
+		return nil
+	}
+	respChan <- ResourceResult{Migration: MigrationStatus{MigrationParameter: task.Batches[task.Next]}}
+	return nil
+}
+
+func (manager *ResourceManager) handleSetRebalanceMigration(poolName, migrationID string, reason error, respChan chan error) (err error) {
+	task, exists := manager.rebalances[poolName]
+	if !exists || task.Status.Finished {
+		err = fmt.Errorf("pool '%s' not in rebalancing", poolName)
+		respChan <- err
+		return err
+	}
+	if reason != nil {
+		//skip failed batch
+		task.Status.Error = reason.Error()
+		task.Status.Failed += len(task.Batches[task.Next].Instances)
+		task.Next++
+		if task.Next >= len(task.Batches) {
+			task.Status.Finished = true
+		}
+		log.Printf("<resource_manager> warning: rebalance pool '%s' fail: %s", poolName, task.Status.Error)
+	} else {
+		task.Status.Migration = migrationID
+		log.Printf("<resource_manager> migration '%s' started to rebalance pool '%s'", migrationID, poolName)
+	}
+	manager.rebalances[poolName] = task
+	respChan <- nil
+	return nil
+}
@@ -0,0 +1,595 @@
+package modules
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/project-nano/core/store"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+const (
+	ReplicationPath            = "/replication/data"
+	ReplicationFencePath       = "/replication/fence"
+	HAStateFilename            = "ha.data"
+	ImageDataFilename          = "image.data" //saved by image service under the same data path
+	replicationSignatureMethod = "Nano-Replica-HMAC-SHA256"
+	replicationMaxSkew         = time.Minute * 5
+	replicationRequestTimeout  = time.Second * 5
+	replicationChallengeLength = 16
+	replicationSessionLength   = 16
+	replicationRequestLimit    = 1 << 12
+	headerNameReplicaChallenge = "Nano-Replica-Challenge"
+)
+
+// replicatedDataFiles : persistent documents copied from active core to standby
+var replicatedDataFiles = []string{ResourceDataFilename, CredentialDataFilename, WebhookDataFilename,
+	JobDataFilename, ImageDataFilename, HAStateFilename}
+
+// HAState : fencing epoch of high availability, increased by every take over.
+// An active core steps down when it finds another core holding a newer epoch.
+type HAState struct {
+	Epoch uint64 `json:"epoch"`
+}
+
+// ReplicationFence : sent by the core took over to previous active core
+type ReplicationFence struct {
+	Epoch   uint64 `json:"epoch"`
+	Address string `json:"address"`
+}
+
+// ReplicationFenceResult : epoch of fenced core, stepped down when fence epoch newer
+type ReplicationFenceResult struct {
+	Epoch       uint64 `json:"epoch"`
+	SteppedDown bool   `json:"stepped_down"`
+}
+
+// ReplicationExporter : saved document of a module and its current revision, data is nil when
+// revision unchanged. Revision zero never replicated, so always exported
+type ReplicationExporter func(revision uint64) (current uint64, data []byte, err error)
+
+// replicationRequest : revisions of documents held by standby, only valid in the same session of active core
+type replicationRequest struct {
+	Session   string            `json:"session,omitempty"`
+	Revisions map[string]uint64 `json:"revisions,omitempty"`
+}
+
+// replicationBundle : documents changed since revisions of request keyed by data filename,
+// all documents included when session of request differs
+type replicationBundle struct {
+	Session   string                     `json:"session"`
+	Epoch     uint64                     `json:"epoch"`
+	SealCheck string                     `json:"seal_check"`
+	Revisions map[string]uint64          `json:"revisions"`
+	Documents map[string]json.RawMessage `json:"documents,omitempty"`
+}
+
+// ReplicationServer : serve persistent data of active core, standby core pulls changed documents periodically.
+// Responses are sealed with key derived from replication secret, bound to challenge of request.
+type ReplicationServer struct {
+	server    http.Server
+	secret    string
+	epoch     uint64
+	session   string
+	sealCheck string
+	exporters map[string]ReplicationExporter
+	onFenced  func(activeAddress string, epoch uint64)
+	fenced    chan bool
+	exitChan  chan bool
+}
+
+// ResourceReplica : local copy of persistent data kept by standby core,
+// loaded by modules when standby takes over
+type ResourceReplica struct {
+	dataPath     string
+	sealFile     string
+	secret       string
+	stores       map[string]store.Store
+	client       http.Client
+	session      string
+	revisions    map[string]uint64
+	epoch        uint64
+	synchronized time.Time
+}
+
+// CreateReplicationServer : exporters required for every replicated document except HA state,
+// seal check verified by standby before it holds a usable replica
+func CreateReplicationServer(port int, secret string, epoch uint64, sealCheck string, exporters map[string]ReplicationExporter,
+	onFenced func(activeAddress string, epoch uint64)) (server *ReplicationServer, err error) {
+	if "" == secret {
+		err = errors.New("replication secret required")
+		return
+	}
+	for _, filename := range replicatedDataFiles {
+		if _, exists := exporters[filename]; !exists && HAStateFilename != filename {
+			err = fmt.Errorf("no exporter for '%s'", filename)
+			return
+		}
+	}
+	var random = make([]byte, replicationSessionLength)
+	if _, err = rand.Read(random); err != nil {
+		return
+	}
+	server = &ReplicationServer{secret: secret, epoch: epoch, session: hex.EncodeToString(random), sealCheck: sealCheck,
+		exporters: exporters, onFenced: onFenced, fenced: make(chan bool, 1), exitChan: make(chan bool)}
+	server.server.Addr = fmt.Sprintf(":%d", port)
+	var mux = http.NewServeMux()
+	mux.HandleFunc(ReplicationPath, server.handleReplicationData)
+	mux.HandleFunc(ReplicationFencePath, server.handleFence)
+	server.server.Handler = mux
+	return server, nil
+}
+
+// ModuleReplicationExporters : exporters of documents saved by core modules, image data exported by image service
+func ModuleReplicationExporters(resource ResourceModule, credential CredentialModule, webhook WebhookModule,
+	job JobModule) map[string]ReplicationExporter {
+	return map[string]ReplicationExporter{
+		ResourceDataFilename: func(revision uint64) (uint64, []byte, error) {
+			var respChan = make(chan ResourceResult, 1)
+			resource.ExportResourceData(revision, respChan)
+			var result = <-respChan
+			return result.Revision, result.Data, result.Error
+		},
+		CredentialDataFilename: func(revision uint64) (uint64, []byte, error) {
+			var respChan = make(chan CredentialResult, 1)
+			credential.ExportCredentialData(revision, respChan)
+			var result = <-respChan
+			return result.Revision, result.Data, result.Error
+		},
+		WebhookDataFilename: func(revision uint64) (uint64, []byte, error) {
+			var respChan = make(chan WebhookResult, 1)
+			webhook.ExportWebhookData(revision, respChan)
+			var result = <-respChan
+			return result.Revision, result.Data, result.Error
+		},
+		JobDataFilename: func(revision uint64) (uint64, []byte, error) {
+			var respChan = make(chan JobResult, 1)
+			job.ExportJobData(revision, respChan)
+			var result = <-respChan
+			return result.Revision, result.Data, result.Error
+		},
+	}
+}
+
+func (server *ReplicationServer) GetServiceAddress() string {
+	return server.server.Addr
+}
+
+func (server *ReplicationServer) Start() error {
+	go server.routine()
+	return nil
+}
+
+func (server *ReplicationServer) Stop() error {
+	server.server.Close()
+	<-server.exitChan
+	return nil
+}
+
+func (server *ReplicationServer) routine() {
+	log.Printf("<replication> serve data of epoch %d at '%s'", server.epoch, server.server.Addr)
+	if err := server.server.ListenAndServe(); err != nil {
+		log.Printf("<replication> server stopped: %s", err.Error())
+	}
+	server.exitChan <- true
+}
+
+// handleReplicationData : only documents whose revision differs from the one held by standby exported
+func (server *ReplicationServer) handleReplicationData(w http.ResponseWriter, r *http.Request) {
+	if http.MethodPost != r.Method {
+		http.Error(w, fmt.Sprintf("invalid method '%s'", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, replicationRequestLimit))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = verifyReplicationRequest(r, server.secret, body); err != nil {
+		log.Printf("<replication> reject request from %s: %s", r.RemoteAddr, err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var request replicationRequest
+	if err = json.Unmarshal(body, &request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var known = request.Revisions
+	if request.Session != server.session {
+		//revisions of another session meaningless
+		known = nil
+	}
+	var bundle = replicationBundle{Session: server.session, Epoch: server.epoch, SealCheck: server.sealCheck,
+		Revisions: map[string]uint64{}, Documents: map[string]json.RawMessage{}}
+	for _, filename := range replicatedDataFiles {
+		revision, exists := known[filename]
+		if HAStateFilename == filename {
+			bundle.Revisions[filename] = server.epoch
+			if !exists || revision != server.epoch {
+				if bundle.Documents[filename], err = json.Marshal(HAState{Epoch: server.epoch}); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			}
+			continue
+		}
+		var data []byte
+		if bundle.Revisions[filename], data, err = server.exporters[filename](revision); err != nil {
+			err = fmt.Errorf("export '%s' fail: %s", filename, err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if nil != data {
+			bundle.Documents[filename] = data
+		}
+	}
+	var data []byte
+	if data, err = json.Marshal(bundle); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	server.writeSealed(w, r, data)
+}
+
+// handleFence : step down when another core took over with newer epoch
+func (server *ReplicationServer) handleFence(w http.ResponseWriter, r *http.Request) {
+	if http.MethodPost != r.Method {
+		http.Error(w, fmt.Sprintf("invalid method '%s'", r.Method), http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<10))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err = verifyReplicationRequest(r, server.secret, body); err != nil {
+		log.Printf("<replication> reject fence from %s: %s", r.RemoteAddr, err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	var fence ReplicationFence
+	if err = json.Unmarshal(body, &fence); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var result = ReplicationFenceResult{Epoch: server.epoch}
+	if fence.Epoch > server.epoch {
+		result.SteppedDown = true
+		select {
+		case server.fenced <- true:
+			log.Printf("<replication> core '%s' took over with epoch %d, newer than local epoch %d, step down",
+				fence.Address, fence.Epoch, server.epoch)
+			go server.onFenced(fence.Address, fence.Epoch)
+		default:
+			//already stepping down
+		}
+	} else {
+		log.Printf("<replication> warning: ignore fence of core '%s' with epoch %d, local epoch %d",
+			fence.Address, fence.Epoch, server.epoch)
+	}
+	var data []byte
+	if data, err = json.Marshal(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	server.writeSealed(w, r, data)
+}
+
+func (server *ReplicationServer) writeSealed(w http.ResponseWriter, r *http.Request, data []byte) {
+	sealed, err := sealReplicationPayload(server.secret, r.Header.Get(headerNameReplicaChallenge), data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(sealed)
+}
+
+func signReplicationRequest(secret, method, path, date, challenge string, body []byte) string {
+	var digest = sha256.Sum256(body)
+	var mac = hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%s\n%s\n%s\n%s\n%s\n%s", replicationSignatureMethod, method, path, date, challenge,
+		hex.EncodeToString(digest[:]))))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func verifyReplicationRequest(r *http.Request, secret string, body []byte) (err error) {
+	var date = r.Header.Get(HeaderNameDate)
+	if "" == date {
+		return errors.New("request date required")
+	}
+	var requestTime time.Time
+	if requestTime, err = http.ParseTime(date); err != nil {
+		return fmt.Errorf("invalid request date '%s'", date)
+	}
+	if skew := time.Since(requestTime); skew > replicationMaxSkew || skew < -replicationMaxSkew {
+		return fmt.Errorf("request date '%s' out of range", date)
+	}
+	var challenge = r.Header.Get(headerNameReplicaChallenge)
+	if len(challenge) != replicationChallengeLength*2 {
+		return errors.New("invalid challenge")
+	}
+	var authorization = r.Header.Get(HeaderNameAuthorization)
+	var expected = fmt.Sprintf("%s %s", replicationSignatureMethod,
+		signReplicationRequest(secret, r.Method, r.URL.Path, date, challenge, body))
+	if !hmac.Equal([]byte(expected), []byte(authorization)) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func replicationCipher(secret string) (aead cipher.AEAD, err error) {
+	var key = sha256.Sum256([]byte(replicationSignatureMethod + secret))
+	var block cipher.Block
+	if block, err = aes.NewCipher(key[:]); err != nil {
+		return
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealReplicationPayload : encrypt and authenticate response, challenge of request as additional data
+// so that a recorded response can not be replayed to another request
+func sealReplicationPayload(secret, challenge string, payload []byte) (sealed []byte, err error) {
+	var aead cipher.AEAD
+	if aead, err = replicationCipher(secret); err != nil {
+		return
+	}
+	var nonce = make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+	return aead.Seal(nonce, nonce, payload, []byte(challenge)), nil
+}
+
+func openReplicationPayload(secret, challenge string, sealed []byte) (payload []byte, err error) {
+	var aead cipher.AEAD
+	if aead, err = replicationCipher(secret); err != nil {
+		return
+	}
+	if len(sealed) < aead.NonceSize() {
+		err = errors.New("sealed payload too short")
+		return
+	}
+	if payload, err = aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(challenge)); err != nil {
+		err = errors.New("payload authentication fail")
+		return
+	}
+	return payload, nil
+}
+
+func (manager *ResourceManager) handleExportResourceData(revision uint64, respChan chan ResourceResult) (err error) {
+	if revision == manager.revision {
+		respChan <- ResourceResult{Revision: revision}
+		return nil
+	}
+	var config ResourceData
+	if config, err = manager.buildResourceData(); err != nil {
+		respChan <- ResourceResult{Error: err}
+		return
+	}
+	var data []byte
+	if data, err = json.Marshal(config); err != nil {
+		respChan <- ResourceResult{Error: err}
+		return
+	}
+	respChan <- ResourceResult{Data: data, Revision: manager.revision}
+	return nil
+}
+
+// LoadHAState : zero epoch when never taken over
+func LoadHAState(dataPath string) (state HAState, err error) {
+	var metadata store.Store
+	if metadata, err = store.Open(GetConfigurator().GetMetadataStore(), filepath.Join(dataPath, HAStateFilename)); err != nil {
+		return
+	}
+	defer metadata.Close()
+	_, err = metadata.LoadSection("epoch", &state.Epoch)
+	return
+}
+
+func SaveHAState(dataPath string, state HAState) (err error) {
+	var metadata store.Store
+	if metadata, err = store.Open(GetConfigurator().GetMetadataStore(), filepath.Join(dataPath, HAStateFilename)); err != nil {
+		return
+	}
+	defer metadata.Close()
+	return metadata.Compact(state)
+}
+
+func newReplicationRequest(secret, method, url, path string, body []byte) (request *http.Request, challenge string, err error) {
+	if request, err = http.NewRequest(method, url, bytes.NewReader(body)); err != nil {
+		return
+	}
+	var random = make([]byte, replicationChallengeLength)
+	if _, err = rand.Read(random); err != nil {
+		return
+	}
+	challenge = hex.EncodeToString(random)
+	var date = time.Now().UTC().Format(http.TimeFormat)
+	request.Header.Set(HeaderNameDate, date)
+	request.Header.Set(headerNameReplicaChallenge, challenge)
+	request.Header.Set(HeaderNameAuthorization, fmt.Sprintf("%s %s", replicationSignatureMethod,
+		signReplicationRequest(secret, method, path, date, challenge, body)))
+	return
+}
+
+// SendReplicationFence : notify previous active core of new epoch, peer steps down when epoch newer than its own
+func SendReplicationFence(host string, port int, secret string, fence ReplicationFence) (result ReplicationFenceResult, err error) {
+	var body []byte
+	if body, err = json.Marshal(fence); err != nil {
+		return
+	}
+	var request *http.Request
+	var challenge string
+	if request, challenge, err = newReplicationRequest(secret, http.MethodPost,
+		fmt.Sprintf("http://%s:%d%s", host, port, ReplicationFencePath), ReplicationFencePath, body); err != nil {
+		return
+	}
+	request.Header.Set("Content-Type", "application/json")
+	var client = http.Client{Timeout: replicationRequestTimeout}
+	var response *http.Response
+	if response, err = client.Do(request); err != nil {
+		return
+	}
+	defer response.Body.Close()
+	var data []byte
+	if data, err = io.ReadAll(response.Body); err != nil {
+		return
+	}
+	if http.StatusOK != response.StatusCode {
+		err = fmt.Errorf("unexpected status %d: %s", response.StatusCode, string(data))
+		return
+	}
+	if data, err = openReplicationPayload(secret, challenge, data); err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &result)
+	return
+}
+
+// OpenResourceReplica : seal key in config path must match the one of active core, checked on every synchronization
+func OpenResourceReplica(configPath, dataPath, secret string) (replica *ResourceReplica, err error) {
+	if "" == secret {
+		err = errors.New("replication secret required")
+		return
+	}
+	replica = &ResourceReplica{
+		dataPath:  dataPath,
+		sealFile:  filepath.Join(configPath, CredentialSealFilename),
+		secret:    secret,
+		stores:    map[string]store.Store{},
+		revisions: map[string]uint64{},
+		client:    http.Client{Timeout: replicationRequestTimeout},
+	}
+	var state HAState
+	if state, err = LoadHAState(dataPath); err != nil {
+		return nil, err
+	}
+	replica.epoch = state.Epoch
+	for _, filename := range replicatedDataFiles {
+		var metadata store.Store
+		if metadata, err = store.Open(GetConfigurator().GetMetadataStore(), filepath.Join(dataPath, filename)); err != nil {
+			replica.Close()
+			return nil, err
+		}
+		replica.stores[filename] = metadata
+	}
+	return replica, nil
+}
+
+// Synchronize : pull documents changed since last synchronization from active core. Nothing written and
+// replica not ready when seal key mismatch, since replicated credentials unusable after take over
+func (replica *ResourceReplica) Synchronize(host string, port int) (updated bool, err error) {
+	var body []byte
+	if body, err = json.Marshal(replicationRequest{Session: replica.session, Revisions: replica.revisions}); err != nil {
+		return
+	}
+	var request *http.Request
+	var challenge string
+	if request, challenge, err = newReplicationRequest(replica.secret, http.MethodPost,
+		fmt.Sprintf("http://%s:%d%s", host, port, ReplicationPath), ReplicationPath, body); err != nil {
+		return
+	}
+	request.Header.Set("Content-Type", "application/json")
+	var response *http.Response
+	if response, err = replica.client.Do(request); err != nil {
+		return
+	}
+	defer response.Body.Close()
+	var data []byte
+	if data, err = io.ReadAll(response.Body); err != nil {
+		return
+	}
+	if http.StatusOK != response.StatusCode {
+		err = fmt.Errorf("unexpected status %d: %s", response.StatusCode, string(data))
+		return
+	}
+	if data, err = openReplicationPayload(replica.secret, challenge, data); err != nil {
+		return
+	}
+	var bundle replicationBundle
+	if err = json.Unmarshal(data, &bundle); err != nil {
+		err = fmt.Errorf("invalid replication data: %s", err.Error())
+		return
+	}
+	if bundle.Epoch < replica.epoch {
+		err = fmt.Errorf("epoch %d of active core older than replicated epoch %d", bundle.Epoch, replica.epoch)
+		return
+	}
+	if err = VerifyCredentialSeal(replica.sealFile, bundle.SealCheck); err != nil {
+		replica.synchronized = time.Time{}
+		return
+	}
+	for filename := range bundle.Documents {
+		if _, exists := replica.stores[filename]; !exists {
+			err = fmt.Errorf("unexpected document '%s'", filename)
+			return
+		}
+	}
+	if bundle.Session != replica.session {
+		for _, filename := range replicatedDataFiles {
+			if _, exists := bundle.Documents[filename]; !exists {
+				err = fmt.Errorf("document '%s' not replicated in new session", filename)
+				return
+			}
+		}
+	}
+	if document, exists := bundle.Documents[ResourceDataFilename]; exists {
+		var config ResourceData
+		if err = json.Unmarshal(document, &config); err != nil {
+			err = fmt.Errorf("invalid resource data: %s", err.Error())
+			return
+		}
+	}
+	for _, filename := range replicatedDataFiles {
+		document, exists := bundle.Documents[filename]
+		if !exists {
+			continue
+		}
+		if err = replica.stores[filename].Compact(document); err != nil {
+			err = fmt.Errorf("save '%s' fail: %s", filename, err.Error())
+			return
+		}
+	}
+	replica.session = bundle.Session
+	replica.revisions = bundle.Revisions
+	replica.epoch = bundle.Epoch
+	replica.synchronized = time.Now()
+	return 0 != len(bundle.Documents), nil
+}
+
+// LastSynchronized : zero when nothing pulled from active core yet
+func (replica *ResourceReplica) LastSynchronized() time.Time {
+	return replica.synchronized
+}
+
+// GetEpoch : epoch of active core last replicated
+func (replica *ResourceReplica) GetEpoch() uint64 {
+	return replica.epoch
+}
+
+func (replica *ResourceReplica) GetDataPath() string {
+	return replica.dataPath
+}
+
+func (replica *ResourceReplica) Close() (err error) {
+	for filename, metadata := range replica.stores {
+		if closeError := metadata.Close(); closeError != nil {
+			err = closeError
+		}
+		delete(replica.stores, filename)
+	}
+	return
+}
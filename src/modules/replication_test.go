@@ -0,0 +1,245 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"github.com/project-nano/core/store"
+	"io/ioutil"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+const replicationSecretForTest = "replication-secret"
+
+// replicatedModule : document exported when revision of standby differs, requested revisions recorded
+type replicatedModule struct {
+	revision  uint64
+	zone      string
+	requested []uint64
+}
+
+func (module *replicatedModule) export(revision uint64) (uint64, []byte, error) {
+	module.requested = append(module.requested, revision)
+	if revision == module.revision {
+		return revision, nil, nil
+	}
+	return module.revision, []byte(fmt.Sprintf(`{"zone":"%s"}`, module.zone)), nil
+}
+
+// replicationServerForTest : server of epoch with all documents at revision 1, sealed by key in config path
+func replicationServerForTest(t *testing.T, configPath string, epoch uint64) (server *ReplicationServer,
+	modules map[string]*replicatedModule, host string, port int, close func()) {
+	sealer, err := loadCredentialSealer(filepath.Join(configPath, CredentialSealFilename))
+	if err != nil {
+		t.Fatalf("load seal key fail: %s", err.Error())
+	}
+	check, err := sealCredentialDigest(sealer, credentialSealCheck)
+	if err != nil {
+		t.Fatalf("seal check fail: %s", err.Error())
+	}
+	modules = map[string]*replicatedModule{}
+	var exporters = map[string]ReplicationExporter{}
+	for _, filename := range replicatedDataFiles {
+		if HAStateFilename == filename {
+			continue
+		}
+		var module = &replicatedModule{revision: 1, zone: filename}
+		modules[filename] = module
+		exporters[filename] = module.export
+	}
+	if server, err = CreateReplicationServer(0, replicationSecretForTest, epoch, check, exporters,
+		func(string, uint64) {}); err != nil {
+		t.Fatalf("create replication server fail: %s", err.Error())
+	}
+	var listener = httptest.NewServer(server.server.Handler)
+	address, err := url.Parse(listener.URL)
+	if err != nil {
+		t.Fatalf("parse server address fail: %s", err.Error())
+	}
+	host, portString, err := net.SplitHostPort(address.Host)
+	if err != nil {
+		t.Fatalf("split server address fail: %s", err.Error())
+	}
+	if port, err = strconv.Atoi(portString); err != nil {
+		t.Fatalf("invalid server port: %s", err.Error())
+	}
+	return server, modules, host, port, listener.Close
+}
+
+func loadReplicatedZone(t *testing.T, dataPath, filename string) string {
+	metadata, err := store.Open(store.BackendJSON, filepath.Join(dataPath, filename))
+	if err != nil {
+		t.Fatalf("open '%s' fail: %s", filename, err.Error())
+	}
+	defer metadata.Close()
+	var document struct {
+		Zone string `json:"zone"`
+	}
+	if _, err = metadata.Load(&document); err != nil {
+		t.Fatalf("load '%s' fail: %s", filename, err.Error())
+	}
+	return document.Zone
+}
+
+func TestReplication_Rounds(t *testing.T) {
+	var configPath, dataPath = t.TempDir(), t.TempDir()
+	_, modules, host, port, closeServer := replicationServerForTest(t, configPath, 1)
+	replica, err := OpenResourceReplica(configPath, dataPath, replicationSecretForTest)
+	if err != nil {
+		t.Fatalf("open replica fail: %s", err.Error())
+	}
+	defer replica.Close()
+	var rounds = []struct {
+		Name      string
+		Restart   bool
+		Modify    string
+		Updated   bool
+		Requested uint64
+	}{
+		{"initial round", false, "", true, 0},
+		{"nothing changed", false, "", false, 1},
+		{"one document changed", false, JobDataFilename, true, 1},
+		{"active core restarted", true, "", true, 0},
+	}
+	for _, round := range rounds {
+		if round.Restart {
+			closeServer()
+			_, modules, host, port, closeServer = replicationServerForTest(t, configPath, 1)
+		}
+		if "" != round.Modify {
+			modules[round.Modify].revision++
+			modules[round.Modify].zone = "modified"
+		}
+		updated, err := replica.Synchronize(host, port)
+		if err != nil {
+			t.Fatalf("%s: synchronize fail: %s", round.Name, err.Error())
+		}
+		if updated != round.Updated {
+			t.Fatalf("%s: updated %t, %t expected", round.Name, updated, round.Updated)
+		}
+		if replica.LastSynchronized().IsZero() {
+			t.Fatalf("%s: replica not synchronized", round.Name)
+		}
+		for filename, module := range modules {
+			var requested = module.requested[len(module.requested)-1]
+			if requested != round.Requested {
+				t.Fatalf("%s: revision %d of '%s' requested, %d expected", round.Name, requested, filename, round.Requested)
+			}
+			if zone := loadReplicatedZone(t, dataPath, filename); zone != module.zone {
+				t.Fatalf("%s: '%s' replicated as '%s'", round.Name, filename, zone)
+			}
+		}
+	}
+	closeServer()
+	if state, err := LoadHAState(dataPath); err != nil || 1 != state.Epoch {
+		t.Fatalf("unexpected HA state %+v, error %v", state, err)
+	}
+}
+
+func TestReplication_SealKey(t *testing.T) {
+	var activeConfig = t.TempDir()
+	_, _, host, port, closeServer := replicationServerForTest(t, activeConfig, 1)
+	defer closeServer()
+	var testCases = []struct {
+		Name    string
+		Prepare func(configPath string)
+		Valid   bool
+	}{
+		{"same seal key", func(configPath string) {
+			data, _ := ioutil.ReadFile(filepath.Join(activeConfig, CredentialSealFilename))
+			ioutil.WriteFile(filepath.Join(configPath, CredentialSealFilename), data, credentialSealFilePerm)
+		}, true},
+		{"seal key missing", func(string) {}, false},
+		{"seal key differs", func(configPath string) {
+			loadCredentialSealer(filepath.Join(configPath, CredentialSealFilename))
+		}, false},
+	}
+	for _, testCase := range testCases {
+		var configPath, dataPath = t.TempDir(), t.TempDir()
+		testCase.Prepare(configPath)
+		replica, err := OpenResourceReplica(configPath, dataPath, replicationSecretForTest)
+		if err != nil {
+			t.Fatalf("%s: open replica fail: %s", testCase.Name, err.Error())
+		}
+		_, err = replica.Synchronize(host, port)
+		replica.Close()
+		if testCase.Valid {
+			if err != nil {
+				t.Fatalf("%s: synchronize fail: %s", testCase.Name, err.Error())
+			}
+			continue
+		}
+		if !errors.Is(err, ErrSealKeyMismatch) {
+			t.Fatalf("%s: seal key mismatch expected, got %v", testCase.Name, err)
+		}
+		if !replica.LastSynchronized().IsZero() {
+			t.Fatalf("%s: replica ready with mismatched seal key", testCase.Name)
+		}
+		if "" != loadReplicatedZone(t, dataPath, ResourceDataFilename) {
+			t.Fatalf("%s: data replicated with mismatched seal key", testCase.Name)
+		}
+	}
+}
+
+func TestReplication_EpochFencing(t *testing.T) {
+	var testCases = []struct {
+		Name        string
+		ServerEpoch uint64
+		FenceEpoch  uint64
+		SteppedDown bool
+	}{
+		{"newer epoch", 2, 3, true},
+		{"same epoch", 2, 2, false},
+		{"older epoch", 2, 1, false},
+	}
+	for _, testCase := range testCases {
+		server, _, host, port, closeServer := replicationServerForTest(t, t.TempDir(), testCase.ServerEpoch)
+		var fenced = make(chan uint64, 1)
+		server.onFenced = func(address string, epoch uint64) {
+			fenced <- epoch
+		}
+		result, err := SendReplicationFence(host, port, replicationSecretForTest,
+			ReplicationFence{Epoch: testCase.FenceEpoch, Address: "standby"})
+		if err != nil {
+			t.Fatalf("%s: send fence fail: %s", testCase.Name, err.Error())
+		}
+		if result.SteppedDown != testCase.SteppedDown || result.Epoch != testCase.ServerEpoch {
+			t.Fatalf("%s: unexpected fence result %+v", testCase.Name, result)
+		}
+		if testCase.SteppedDown {
+			if epoch := <-fenced; epoch != testCase.FenceEpoch {
+				t.Fatalf("%s: fenced with epoch %d", testCase.Name, epoch)
+			}
+		} else if 0 != len(fenced) {
+			t.Fatalf("%s: stepped down by fence of epoch %d", testCase.Name, testCase.FenceEpoch)
+		}
+		if _, err = SendReplicationFence(host, port, "invalid secret", ReplicationFence{Epoch: 9}); err == nil {
+			t.Fatalf("%s: fence with invalid secret accepted", testCase.Name)
+		}
+		closeServer()
+	}
+}
+
+func TestReplication_OlderEpochRejected(t *testing.T) {
+	var configPath, dataPath = t.TempDir(), t.TempDir()
+	_, _, host, port, closeServer := replicationServerForTest(t, configPath, 1)
+	defer closeServer()
+	if err := SaveHAState(dataPath, HAState{Epoch: 2}); err != nil {
+		t.Fatalf("save HA state fail: %s", err.Error())
+	}
+	replica, err := OpenResourceReplica(configPath, dataPath, replicationSecretForTest)
+	if err != nil {
+		t.Fatalf("open replica fail: %s", err.Error())
+	}
+	defer replica.Close()
+	if _, err = replica.Synchronize(host, port); err == nil {
+		t.Fatalf("data of older epoch replicated")
+	}
+	if 2 != replica.GetEpoch() || "" != loadReplicatedZone(t, dataPath, ResourceDataFilename) {
+		t.Fatalf("replica modified by core of older epoch")
+	}
+}
@@ -28,14 +28,20 @@ type RequestProxy struct {
 }
 
 const (
-	APIModuleName = "API"
+	APIModuleName                = "API"
+	MigrationSchedulerModuleName = "MigrationScheduler"
 )
 
 func CreateRequestProxy(sender framework.MessageSender) (*RequestProxy, error) {
+	return CreateModuleRequestProxy(APIModuleName, sender)
+}
+
+// CreateModuleRequestProxy : responses routed back by module name, which must be registered as submodule
+func CreateModuleRequestProxy(module string, sender framework.MessageSender) (*RequestProxy, error) {
 	const (
 		queueLength = 1 << 10
 	)
-	proxy := RequestProxy{APIModuleName, make(chan framework.Message, queueLength),
+	proxy := RequestProxy{module, make(chan framework.Message, queueLength),
 		make(chan ProxyRequest, queueLength), sender, make(chan bool), make(chan bool)}
 	return &proxy, nil
 }
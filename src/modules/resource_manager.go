@@ -3,20 +3,19 @@ package modules
 import (
 	"bytes"
 	"encoding/binary"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/project-nano/core/store"
 	"github.com/project-nano/framework"
 	uuid "github.com/satori/go.uuid"
-	"io/ioutil"
 	"log"
 	"math"
 	"math/rand"
 	"net"
-	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -24,18 +23,28 @@ import (
 //config file define
 
 type cellDefine struct {
-	Enabled        bool     `json:"enabled,omitempty"`
-	PurgeAppending bool     `json:"purge_appending,omitempty"`
-	Instances      []string `json:"-"`
+	Enabled        bool              `json:"enabled,omitempty"`
+	PurgeAppending bool              `json:"purge_appending,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Instances      []string          `json:"-"`
 }
 
 type poolDefine struct {
-	Name     string                `json:"name"`
-	Enabled  bool                  `json:"enabled,omitempty"`
-	Network  string                `json:"network,omitempty"`
-	Storage  string                `json:"storage,omitempty"`
-	Failover bool                  `json:"failover,omitempty"`
-	Cells    map[string]cellDefine `json:"cells,omitempty"`
+	Name               string                `json:"name"`
+	Enabled            bool                  `json:"enabled,omitempty"`
+	Network            string                `json:"network,omitempty"`
+	Storage            string                `json:"storage,omitempty"`
+	Failover           bool                  `json:"failover,omitempty"`
+	Strategy           string                `json:"strategy,omitempty"`
+	CPUOvercommit      *float64              `json:"cpu_overcommit,omitempty"`
+	MemoryOvercommit   *float64              `json:"memory_overcommit,omitempty"`
+	DiskOvercommit     *float64              `json:"disk_overcommit,omitempty"`
+	CPUHealthThreshold *float64              `json:"cpu_health_threshold,omitempty"`
+	RebalanceThreshold float64               `json:"rebalance_threshold,omitempty"`
+	RebalanceInterval  uint                  `json:"rebalance_interval,omitempty"`
+	FailoverTolerance  uint                  `json:"failover_tolerance,omitempty"`
+	FailoverEnforce    bool                  `json:"failover_enforce,omitempty"`
+	Cells              map[string]cellDefine `json:"cells,omitempty"`
 }
 
 type storageDefine struct {
@@ -50,13 +59,29 @@ type addressPoolDefine struct {
 	Ranges []AddressRangeStatus `json:"ranges,omitempty"`
 }
 
+type quotaDefine struct {
+	Scope string        `json:"scope"`
+	Name  string        `json:"name"`
+	Limit ResourceQuota `json:"limit"`
+}
+
 type ResourceData struct {
+	SchemaVersion       int                          `json:"schema_version"`
 	Zone                string                       `json:"zone"`
 	Pools               []poolDefine                 `json:"pools"`
 	StoragePools        []storageDefine              `json:"storage_pools,omitempty"`
 	AddressPools        []addressPoolDefine          `json:"address_pools,omitempty"`
 	SystemTemplates     []SystemTemplate             `json:"system_templates,omitempty"`
 	SecurityPolicyGroup []managedSecurityPolicyGroup `json:"security_policy_group,omitempty"`
+	ServerGroups        []ServerGroupStatus          `json:"server_groups,omitempty"`
+	Quotas              []quotaDefine                `json:"quotas,omitempty"`
+	InstanceSelectors   map[string]PlacementSelector `json:"instance_selectors,omitempty"`
+	SnapshotCounts      map[string]uint              `json:"snapshot_counts,omitempty"`
+	Migrations          []migrationDefine            `json:"migrations,omitempty"`
+	BatchCreateTasks    []batchTaskDefine            `json:"batch_create_tasks,omitempty"`
+	BatchDeleteTasks    []batchTaskDefine            `json:"batch_delete_tasks,omitempty"`
+	BatchStopTasks      []batchTaskDefine            `json:"batch_stop_tasks,omitempty"`
+	Maintenances        []CellMaintenanceStatus      `json:"maintenances,omitempty"`
 }
 
 // memory status define
@@ -75,6 +100,9 @@ type ManagedComputePool struct {
 	CellStatistic
 	InstanceStatistic
 	ResourceUsage
+	Rebalance        PoolRebalanceConfig
+	NextRebalance    time.Time
+	FailoverCapacity PoolFailoverCapacity
 }
 
 type ManagedComputeCell struct {
@@ -83,6 +111,8 @@ type ManagedComputeCell struct {
 	LatestUpdate time.Time
 	Instances    map[string]bool
 	Pending      map[string]bool
+	Labels       map[string]string
+	Synchronized bool //instances reported since core started
 	InstanceStatistic
 	ResourceUsage
 }
@@ -114,6 +144,7 @@ type BatchCreateGuestTask struct {
 	StartTime    time.Time
 	LatestUpdate time.Time
 	Finished     bool
+	Recovered    bool //unfinished when core restarted
 	Guests       []CreateGuestStatus
 	GuestName    map[string]int //name => index
 }
@@ -122,6 +153,7 @@ type BatchDeleteGuestTask struct {
 	StartTime    time.Time
 	LatestUpdate time.Time
 	Finished     bool
+	Recovered    bool //unfinished when core restarted
 	Guests       []DeleteGuestStatus
 	GuestID      map[string]int //id => index
 }
@@ -135,10 +167,16 @@ type BatchStopGuestTask struct {
 	StartTime    time.Time
 	LatestUpdate time.Time
 	Finished     bool
+	Recovered    bool //unfinished when core restarted
 	Guests       []StopGuestStatus
 	GuestID      map[string]int //id => index
 }
 
+type quotaScope struct {
+	Scope string
+	Name  string
+}
+
 type ResourceManager struct {
 	reportChan          chan CellStatusReport
 	commands            chan resourceCommand
@@ -148,6 +186,7 @@ type ResourceManager struct {
 	instances           map[string]InstanceStatus
 	imageServers        map[string]imageServer //key = server name
 	pendingError        map[string]error       //pending create error
+	systemResets        map[string]SystemResetStatus
 	storagePools        map[string]StoragePoolInfo
 	addressPools        map[string]ManagedAddressPool
 	migrations          map[string]MigrationStatus
@@ -159,10 +198,24 @@ type ResourceManager struct {
 	policyGroups        map[string]managedSecurityPolicyGroup
 	policyGroupNames    map[string]bool
 	sortedPolicyGroupID []string
+	serverGroups        map[string]ServerGroupStatus
+	serverGroupNames    map[string]bool
+	sortedServerGroupID []string
+	instanceGroups      map[string]string //instance id => server group id
+	instanceSelectors   map[string]PlacementSelector
+	quotas              map[quotaScope]ResourceQuota
+	snapshotCounts      map[string]uint                   //instance id => snapshots, updated when snapshots queried or changed
+	maintenances        map[string]CellMaintenanceStatus  //cell name => drain status
+	maintenanceStops    map[string]time.Time              //instance id => latest stop requested by drain
+	rebalances          map[string]poolRebalanceTask      //pool name => rebalance task
+	failoverCapacity    map[string]FailoverCapacityStatus //pool name => latest check
 	generator           *rand.Rand
 	zone                ManagedZone
 	startTime           time.Time
+	revision            uint64 //increased by every save from 1, replicated when changed
 	dataFile            string
+	metadata            store.Store
+	events              EventModule
 	runner              *framework.SimpleRunner
 }
 
@@ -196,6 +249,12 @@ type resourceCommand struct {
 	MigrationID      string
 	Error            error
 	Failover         bool
+	Strategy         string
+	Overcommit       PoolOvercommitUpdate
+	Rebalance        PoolRebalanceConfig
+	FailoverCapacity PoolFailoverCapacity
+	Labels           map[string]string
+	ZoneBackup       ZoneBackupData
 	IDList           []string
 	PortList         []uint64
 	DiskImages       []DiskImageStatus
@@ -215,9 +274,17 @@ type resourceCommand struct {
 	PolicyGroup      SecurityPolicyGroup
 	PolicyGroupQuery SecurityPolicyGroupQueryCondition
 	PolicyRule       SecurityPolicyRule
+	ServerGroup      ServerGroupConfig
+	ServerGroupQuery ServerGroupQueryCondition
+	User             string
+	Scope            string
+	Quota            ResourceQuota
+	Count            uint
 	Index            int
+	Revision         uint64
 	Flag             bool
 	SearchCondition  SearchGuestsCondition
+	ListOption       ListOption
 	ErrorChan        chan error
 	ResultChan       chan ResourceResult
 }
@@ -235,12 +302,6 @@ type ResourceStatistic struct {
 
 type commandType int
 
-const (
-	evaluateCoreFactor   = 2
-	evaluateMemoryFactor = 1.5
-	evaluateDiskFactor   = 0.5
-)
-
 const (
 	cmdQueryAllComputePoolInfo = iota
 	cmdGetComputePoolInfo
@@ -306,6 +367,7 @@ const (
 	cmdPurgeInstance
 	cmdBeginResetSystem
 	cmdFinishResetSystem
+	cmdGetResetSystem
 	cmdStartBatchCreateGuest
 	cmdSetBatchCreateGuestStart
 	cmdSetBatchCreateGuestFail
@@ -335,6 +397,39 @@ const (
 	cmdMoveSecurityPolicyRule
 	cmdSearchGuests
 	cmdUpdateAutoStart
+	cmdQueryServerGroups
+	cmdGetServerGroup
+	cmdCreateServerGroup
+	cmdModifyServerGroup
+	cmdDeleteServerGroup
+	cmdQueryQuotas
+	cmdGetQuota
+	cmdCreateQuota
+	cmdModifyQuota
+	cmdDeleteQuota
+	cmdCheckQuota
+	cmdUpdateSnapshotCount
+	cmdQueryCellMaintenance
+	cmdGetCellMaintenance
+	cmdStartCellMaintenance
+	cmdCancelCellMaintenance
+	cmdNextMaintenanceBatch
+	cmdSetMaintenanceMigration
+	cmdGetRebalancePlan
+	cmdModifyRebalanceConfig
+	cmdStartRebalance
+	cmdQueryRebalance
+	cmdNextRebalanceBatch
+	cmdSetRebalanceMigration
+	cmdGetCellLabels
+	cmdModifyCellLabels
+	cmdPreviewFailover
+	cmdGetFailoverCapacity
+	cmdModifyFailoverCapacity
+	cmdExportResourceData
+	cmdExportZoneBackup
+	cmdRestoreZoneBackup
+	cmdLookupInstance
 	cmdInvalid
 )
 
@@ -403,6 +498,7 @@ var commandNames = []string{
 	"PurgeInstance",
 	"BeginResetSystem",
 	"FinishResetSystem",
+	"GetResetSystem",
 	"StartBatchCreateGuest",
 	"SetBatchCreateGuestStart",
 	"SetBatchCreateGuestFail",
@@ -432,6 +528,39 @@ var commandNames = []string{
 	"MoveSecurityPolicyRule",
 	"SearchGuests",
 	"UpdateAutoStart",
+	"QueryServerGroups",
+	"GetServerGroup",
+	"CreateServerGroup",
+	"ModifyServerGroup",
+	"DeleteServerGroup",
+	"QueryQuotas",
+	"GetQuota",
+	"CreateQuota",
+	"ModifyQuota",
+	"DeleteQuota",
+	"CheckQuota",
+	"UpdateSnapshotCount",
+	"QueryCellMaintenance",
+	"GetCellMaintenance",
+	"StartCellMaintenance",
+	"CancelCellMaintenance",
+	"NextMaintenanceBatch",
+	"SetMaintenanceMigration",
+	"GetRebalancePlan",
+	"ModifyRebalanceConfig",
+	"StartRebalance",
+	"QueryRebalance",
+	"NextRebalanceBatch",
+	"SetRebalanceMigration",
+	"GetCellLabels",
+	"ModifyCellLabels",
+	"PreviewFailover",
+	"GetFailoverCapacity",
+	"ModifyFailoverCapacity",
+	"ExportResourceData",
+	"ExportZoneBackup",
+	"RestoreZoneBackup",
+	"LookupInstance",
 }
 
 func (c commandType) toString() string {
@@ -448,32 +577,39 @@ const (
 	RangeTypeInternal = "internal"
 )
 
-func CreateResourceManager(dataPath string) (manager *ResourceManager, err error) {
+const (
+	metadataCompactInterval = time.Minute * 10
+)
+
+func CreateResourceManager(dataPath string, events EventModule) (manager *ResourceManager, err error) {
 	if cmdInvalid != len(commandNames) {
 		err = fmt.Errorf("insufficient command names %d/%d", len(commandNames), cmdInvalid)
 		return
 	}
 
 	const (
-		DefaultQueueLength  = 1 << 10
-		DefaultDataFilename = "resource.data"
+		DefaultQueueLength = 1 << 10
 	)
-	manager = &ResourceManager{}
+	manager = &ResourceManager{events: events, revision: 1}
 	manager.runner = framework.CreateSimpleRunner(manager.mainRoutine)
 	manager.reportChan = make(chan CellStatusReport, DefaultQueueLength)
 	manager.commands = make(chan resourceCommand, DefaultQueueLength)
-	manager.dataFile = filepath.Join(dataPath, DefaultDataFilename)
-	manager.pools = map[string]ManagedComputePool{}
-	manager.cells = map[string]ManagedComputeCell{}
+	manager.dataFile = filepath.Join(dataPath, ResourceDataFilename)
+	if manager.metadata, err = store.Open(GetConfigurator().GetMetadataStore(), manager.dataFile); err != nil {
+		return
+	}
+	manager.initialZoneConfig()
 	manager.instances = map[string]InstanceStatus{}
-	manager.unallocatedCells = map[string]bool{}
 	manager.imageServers = map[string]imageServer{}
 	manager.pendingError = map[string]error{}
-	manager.storagePools = map[string]StoragePoolInfo{}
-	manager.addressPools = map[string]ManagedAddressPool{}
-	manager.templates = map[string]SystemTemplate{}
-	manager.policyGroups = map[string]managedSecurityPolicyGroup{}
-	manager.policyGroupNames = map[string]bool{}
+	manager.systemResets = map[string]SystemResetStatus{}
+	manager.serverGroups = map[string]ServerGroupStatus{}
+	manager.serverGroupNames = map[string]bool{}
+	manager.instanceGroups = map[string]string{}
+	manager.quotas = map[quotaScope]ResourceQuota{}
+	manager.snapshotCounts = map[string]uint{}
+	manager.maintenanceStops = map[string]time.Time{}
+	manager.instanceSelectors = map[string]PlacementSelector{}
 	manager.migrations = map[string]MigrationStatus{}
 	manager.batchCreateTasks = map[string]BatchCreateGuestTask{}
 	manager.batchDeleteTasks = map[string]BatchDeleteGuestTask{}
@@ -498,13 +634,15 @@ func (manager *ResourceManager) UpdateCellStatus(report CellStatusReport) {
 	manager.reportChan <- report
 }
 
-func (manager *ResourceManager) CreatePool(name, storage, address string, failover bool, resultChan chan error) {
-	req := resourceCommand{Type: cmdCreateComputePool, Pool: name, Storage: storage, Address: address, Failover: failover, ErrorChan: resultChan}
+func (manager *ResourceManager) CreatePool(name, storage, address string, failover bool, strategy string, overcommit PoolOvercommitUpdate, resultChan chan error) {
+	req := resourceCommand{Type: cmdCreateComputePool, Pool: name, Storage: storage, Address: address, Failover: failover, Strategy: strategy,
+		Overcommit: overcommit, ErrorChan: resultChan}
 	manager.commands <- req
 }
 
-func (manager *ResourceManager) ModifyPool(name, storage, address string, failover bool, resultChan chan error) {
-	manager.commands <- resourceCommand{Type: cmdModifyComputePool, Pool: name, Storage: storage, Address: address, Failover: failover, ErrorChan: resultChan}
+func (manager *ResourceManager) ModifyPool(name, storage, address string, failover bool, strategy string, overcommit PoolOvercommitUpdate, resultChan chan error) {
+	manager.commands <- resourceCommand{Type: cmdModifyComputePool, Pool: name, Storage: storage, Address: address, Failover: failover, Strategy: strategy,
+		Overcommit: overcommit, ErrorChan: resultChan}
 }
 
 func (manager *ResourceManager) DeletePool(name string, resultChan chan error) {
@@ -555,6 +693,25 @@ func (manager *ResourceManager) FinishPurgeCell(cellName string, respChan chan e
 	manager.commands <- resourceCommand{Type: cmdFinishPurgeCell, Cell: cellName, ErrorChan: respChan}
 }
 
+func (manager *ResourceManager) QueryCellMaintenance(respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdQueryCellMaintenance, ResultChan: respChan}
+}
+func (manager *ResourceManager) GetCellMaintenance(poolName, cellName string, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdGetCellMaintenance, Pool: poolName, Cell: cellName, ResultChan: respChan}
+}
+func (manager *ResourceManager) StartCellMaintenance(poolName, cellName string, stopRunning bool, respChan chan error) {
+	manager.commands <- resourceCommand{Type: cmdStartCellMaintenance, Pool: poolName, Cell: cellName, Flag: stopRunning, ErrorChan: respChan}
+}
+func (manager *ResourceManager) CancelCellMaintenance(poolName, cellName string, respChan chan error) {
+	manager.commands <- resourceCommand{Type: cmdCancelCellMaintenance, Pool: poolName, Cell: cellName, ErrorChan: respChan}
+}
+func (manager *ResourceManager) NextMaintenanceBatch(cellName string, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdNextMaintenanceBatch, Cell: cellName, ResultChan: respChan}
+}
+func (manager *ResourceManager) SetMaintenanceMigration(cellName, migrationID string, err error, respChan chan error) {
+	manager.commands <- resourceCommand{Type: cmdSetMaintenanceMigration, Cell: cellName, MigrationID: migrationID, Error: err, ErrorChan: respChan}
+}
+
 func (manager *ResourceManager) GetUnallocatedCells(resp chan ResourceResult) {
 	req := resourceCommand{Type: cmdQueryUnallocatedComputeCell, ResultChan: resp}
 	manager.commands <- req
@@ -644,12 +801,12 @@ func (manager *ResourceManager) GetInstanceStatus(id string, respChan chan Resou
 	manager.commands <- cmd
 }
 
-func (manager *ResourceManager) QueryInstanceStatusInPool(poolName string, respChan chan ResourceResult) {
-	cmd := resourceCommand{Type: cmdQueryInstanceStatusInPool, Pool: poolName, ResultChan: respChan}
+func (manager *ResourceManager) QueryInstanceStatusInPool(poolName string, option ListOption, respChan chan ResourceResult) {
+	cmd := resourceCommand{Type: cmdQueryInstanceStatusInPool, Pool: poolName, ListOption: option, ResultChan: respChan}
 	manager.commands <- cmd
 }
-func (manager *ResourceManager) QueryInstanceStatusInCell(poolName, cellName string, respChan chan ResourceResult) {
-	cmd := resourceCommand{Type: cmdQueryInstanceStatusInCell, Pool: poolName, Cell: cellName, ResultChan: respChan}
+func (manager *ResourceManager) QueryInstanceStatusInCell(poolName, cellName string, option ListOption, respChan chan ResourceResult) {
+	cmd := resourceCommand{Type: cmdQueryInstanceStatusInCell, Pool: poolName, Cell: cellName, ListOption: option, ResultChan: respChan}
 	manager.commands <- cmd
 }
 
@@ -701,8 +858,8 @@ func (manager *ResourceManager) GetImageServer(respChan chan ResourceResult) {
 }
 
 // migration
-func (manager *ResourceManager) QueryMigration(respChan chan ResourceResult) {
-	manager.commands <- resourceCommand{Type: cmdQueryMigration, ResultChan: respChan}
+func (manager *ResourceManager) QueryMigration(option ListOption, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdQueryMigration, ListOption: option, ResultChan: respChan}
 }
 
 func (manager *ResourceManager) GetMigration(id string, respChan chan ResourceResult) {
@@ -727,6 +884,33 @@ func (manager *ResourceManager) UpdateMigration(migration string, progress uint,
 func (manager *ResourceManager) BuildFailoverPlan(cellName string, respChan chan ResourceResult) {
 	manager.commands <- resourceCommand{Type: cmdBuildFailoverPlan, Cell: cellName, ResultChan: respChan}
 }
+func (manager *ResourceManager) PreviewFailover(poolName, cellName string, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdPreviewFailover, Pool: poolName, Cell: cellName, ResultChan: respChan}
+}
+func (manager *ResourceManager) GetFailoverCapacity(poolName string, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdGetFailoverCapacity, Pool: poolName, ResultChan: respChan}
+}
+func (manager *ResourceManager) ModifyFailoverCapacity(poolName string, config PoolFailoverCapacity, respChan chan error) {
+	manager.commands <- resourceCommand{Type: cmdModifyFailoverCapacity, Pool: poolName, FailoverCapacity: config, ErrorChan: respChan}
+}
+
+// ExportResourceData : saved document for replication to standby core, data omitted when revision unchanged
+func (manager *ResourceManager) ExportResourceData(revision uint64, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdExportResourceData, Revision: revision, ResultChan: respChan}
+}
+
+func (manager *ResourceManager) ExportZoneBackup(respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdExportZoneBackup, ResultChan: respChan}
+}
+
+func (manager *ResourceManager) RestoreZoneBackup(data ZoneBackupData, dryRun bool, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdRestoreZoneBackup, ZoneBackup: data, Flag: dryRun, ResultChan: respChan}
+}
+
+// LookupInstance : unlike GetInstanceStatus, pending error of instance kept
+func (manager *ResourceManager) LookupInstance(id string, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdLookupInstance, InstanceID: id, ResultChan: respChan}
+}
 
 func (manager *ResourceManager) MigrateInstance(oldCell, newCell string, instances []string, ports []uint64, respChan chan error) {
 	manager.commands <- resourceCommand{Type: cmdMigrationInstance, Cell: oldCell, Target: newCell, IDList: instances, PortList: ports, ErrorChan: respChan}
@@ -752,8 +936,8 @@ func (manager *ResourceManager) DeleteAddressPool(name string, respChan chan err
 	manager.commands <- resourceCommand{Type: cmdDeleteAddressPool, Address: name, ErrorChan: respChan}
 }
 
-func (manager *ResourceManager) QueryAddressRange(poolName, rangeType string, respChan chan ResourceResult) {
-	manager.commands <- resourceCommand{Type: cmdQueryAddressRange, Address: poolName, Range: rangeType, ResultChan: respChan}
+func (manager *ResourceManager) QueryAddressRange(poolName, rangeType string, option ListOption, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdQueryAddressRange, Address: poolName, Range: rangeType, ListOption: option, ResultChan: respChan}
 }
 func (manager *ResourceManager) GetAddressRange(poolName, rangeType, startAddress string, respChan chan ResourceResult) {
 	manager.commands <- resourceCommand{Type: cmdGetAddressRange, Address: poolName, Range: rangeType, Start: startAddress, ResultChan: respChan}
@@ -773,6 +957,10 @@ func (manager *ResourceManager) FinishResetSystem(instanceID string, err error,
 	manager.commands <- resourceCommand{Type: cmdFinishResetSystem, InstanceID: instanceID, Error: err, ErrorChan: respChan}
 }
 
+func (manager *ResourceManager) GetResetSystemStatus(instanceID string, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdGetResetSystem, InstanceID: instanceID, ResultChan: respChan}
+}
+
 // batch
 func (manager *ResourceManager) StartBatchCreateGuest(request BatchCreateRequest, respChan chan ResourceResult) {
 	manager.commands <- resourceCommand{Type: cmdStartBatchCreateGuest, BatchCreating: request, ResultChan: respChan}
@@ -842,8 +1030,8 @@ func (manager *ResourceManager) DeleteSystemTemplate(id string, respChan chan er
 }
 
 // Security Policy Group
-func (manager *ResourceManager) QuerySecurityPolicyGroups(condition SecurityPolicyGroupQueryCondition, respChan chan ResourceResult) {
-	manager.commands <- resourceCommand{Type: cmdQuerySecurityPolicyGroups, PolicyGroupQuery: condition, ResultChan: respChan}
+func (manager *ResourceManager) QuerySecurityPolicyGroups(condition SecurityPolicyGroupQueryCondition, option ListOption, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdQuerySecurityPolicyGroups, PolicyGroupQuery: condition, ListOption: option, ResultChan: respChan}
 }
 func (manager *ResourceManager) GetSecurityPolicyGroup(groupID string, respChan chan ResourceResult) {
 	manager.commands <- resourceCommand{Type: cmdGetSecurityPolicyGroup, Group: groupID, ResultChan: respChan}
@@ -877,6 +1065,72 @@ func (manager *ResourceManager) MoveSecurityPolicyRule(groupID string, index int
 	manager.commands <- resourceCommand{Type: cmdMoveSecurityPolicyRule, Group: groupID, Index: index, Flag: up, ErrorChan: respChan}
 }
 
+// Server Group
+func (manager *ResourceManager) QueryServerGroups(condition ServerGroupQueryCondition, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdQueryServerGroups, ServerGroupQuery: condition, ResultChan: respChan}
+}
+func (manager *ResourceManager) GetServerGroup(groupID string, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdGetServerGroup, Group: groupID, ResultChan: respChan}
+}
+func (manager *ResourceManager) CreateServerGroup(config ServerGroupConfig, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdCreateServerGroup, ServerGroup: config, ResultChan: respChan}
+}
+func (manager *ResourceManager) ModifyServerGroup(groupID string, config ServerGroupConfig, respChan chan error) {
+	manager.commands <- resourceCommand{Type: cmdModifyServerGroup, Group: groupID, ServerGroup: config, ErrorChan: respChan}
+}
+func (manager *ResourceManager) DeleteServerGroup(groupID string, respChan chan error) {
+	manager.commands <- resourceCommand{Type: cmdDeleteServerGroup, Group: groupID, ErrorChan: respChan}
+}
+
+// Quota
+func (manager *ResourceManager) QueryQuotas(respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdQueryQuotas, ResultChan: respChan}
+}
+func (manager *ResourceManager) GetQuota(scope, name string, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdGetQuota, Scope: scope, Name: name, ResultChan: respChan}
+}
+func (manager *ResourceManager) CreateQuota(scope, name string, limit ResourceQuota, respChan chan error) {
+	manager.commands <- resourceCommand{Type: cmdCreateQuota, Scope: scope, Name: name, Quota: limit, ErrorChan: respChan}
+}
+func (manager *ResourceManager) ModifyQuota(scope, name string, limit ResourceQuota, respChan chan error) {
+	manager.commands <- resourceCommand{Type: cmdModifyQuota, Scope: scope, Name: name, Quota: limit, ErrorChan: respChan}
+}
+func (manager *ResourceManager) DeleteQuota(scope, name string, respChan chan error) {
+	manager.commands <- resourceCommand{Type: cmdDeleteQuota, Scope: scope, Name: name, ErrorChan: respChan}
+}
+func (manager *ResourceManager) CheckQuota(user, group string, required ResourceQuota, respChan chan error) {
+	manager.commands <- resourceCommand{Type: cmdCheckQuota, User: user, Group: group, Quota: required, ErrorChan: respChan}
+}
+func (manager *ResourceManager) UpdateSnapshotCount(instanceID string, count uint, respChan chan error) {
+	manager.commands <- resourceCommand{Type: cmdUpdateSnapshotCount, InstanceID: instanceID, Count: count, ErrorChan: respChan}
+}
+
+func (manager *ResourceManager) GetCellLabels(poolName, cellName string, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdGetCellLabels, Pool: poolName, Cell: cellName, ResultChan: respChan}
+}
+func (manager *ResourceManager) ModifyCellLabels(poolName, cellName string, labels map[string]string, respChan chan error) {
+	manager.commands <- resourceCommand{Type: cmdModifyCellLabels, Pool: poolName, Cell: cellName, Labels: labels, ErrorChan: respChan}
+}
+
+func (manager *ResourceManager) GetRebalancePlan(poolName string, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdGetRebalancePlan, Pool: poolName, ResultChan: respChan}
+}
+func (manager *ResourceManager) ModifyRebalanceConfig(poolName string, config PoolRebalanceConfig, respChan chan error) {
+	manager.commands <- resourceCommand{Type: cmdModifyRebalanceConfig, Pool: poolName, Rebalance: config, ErrorChan: respChan}
+}
+func (manager *ResourceManager) StartRebalance(poolName string, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdStartRebalance, Pool: poolName, ResultChan: respChan}
+}
+func (manager *ResourceManager) QueryRebalance(respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdQueryRebalance, ResultChan: respChan}
+}
+func (manager *ResourceManager) NextRebalanceBatch(poolName string, respChan chan ResourceResult) {
+	manager.commands <- resourceCommand{Type: cmdNextRebalanceBatch, Pool: poolName, ResultChan: respChan}
+}
+func (manager *ResourceManager) SetRebalanceMigration(poolName, migrationID string, err error, respChan chan error) {
+	manager.commands <- resourceCommand{Type: cmdSetRebalanceMigration, Pool: poolName, MigrationID: migrationID, Error: err, ErrorChan: respChan}
+}
+
 func (manager *ResourceManager) mainRoutine(c framework.RoutineController) {
 	const (
 		summaryInterval     = time.Second * 5
@@ -884,6 +1138,9 @@ func (manager *ResourceManager) mainRoutine(c framework.RoutineController) {
 	)
 	var summaryTicker = time.NewTicker(summaryInterval)
 	var batchUpdateTicker = time.NewTicker(batchUpdateInterval)
+	var rebalanceTicker = time.NewTicker(rebalanceCheckInterval)
+	var failoverCapacityTicker = time.NewTicker(failoverCapacityCheckInterval)
+	var compactTicker = time.NewTicker(metadataCompactInterval)
 	for !c.IsStopping() {
 		select {
 		case <-c.GetNotifyChannel():
@@ -894,18 +1151,109 @@ func (manager *ResourceManager) mainRoutine(c framework.RoutineController) {
 			manager.onUpdateSystemStatus()
 		case <-batchUpdateTicker.C:
 			manager.updateBatchStatus()
+		case <-rebalanceTicker.C:
+			manager.scheduleRebalance()
+		case <-failoverCapacityTicker.C:
+			manager.checkFailoverCapacity()
+		case <-compactTicker.C:
+			if 0 != manager.metadata.Pending() {
+				if err := manager.compactConfig(); err != nil {
+					log.Printf("<resource_manager> compact config fail: %s", err.Error())
+				}
+			}
 		case cmd := <-manager.commands:
 			manager.handleCommand(cmd)
 		}
 	}
+	if err := manager.metadata.Close(); err != nil {
+		log.Printf("<resource_manager> close metadata store fail: %s", err.Error())
+	}
 	c.NotifyExit()
 }
 
+// unfinishedBatchTasks : task id => operation
+func (manager *ResourceManager) unfinishedBatchTasks() (tasks map[string]string) {
+	tasks = map[string]string{}
+	for taskID, task := range manager.batchCreateTasks {
+		if !task.Finished {
+			tasks[taskID] = batchOperationCreate
+		}
+	}
+	for taskID, task := range manager.batchDeleteTasks {
+		if !task.Finished {
+			tasks[taskID] = batchOperationDelete
+		}
+	}
+	for taskID, task := range manager.batchStopTasks {
+		if !task.Finished {
+			tasks[taskID] = batchOperationStop
+		}
+	}
+	return
+}
+
+func (manager *ResourceManager) publishBatchFinished(unfinished map[string]string) {
+	if nil == manager.events {
+		return
+	}
+	for taskID, operation := range unfinished {
+		var finished bool
+		var statusList []BatchTaskStatus
+		switch operation {
+		case batchOperationCreate:
+			var task = manager.batchCreateTasks[taskID]
+			finished = task.Finished
+			for _, guest := range task.Guests {
+				statusList = append(statusList, guest.Status)
+			}
+		case batchOperationDelete:
+			var task = manager.batchDeleteTasks[taskID]
+			finished = task.Finished
+			for _, guest := range task.Guests {
+				statusList = append(statusList, guest.Status)
+			}
+		default:
+			var task = manager.batchStopTasks[taskID]
+			finished = task.Finished
+			for _, guest := range task.Guests {
+				statusList = append(statusList, guest.Status)
+			}
+		}
+		if !finished {
+			continue
+		}
+		var succeeded, failed = 0, 0
+		for _, status := range statusList {
+			if BatchTaskStatusSuccess == status {
+				succeeded++
+			} else {
+				failed++
+			}
+		}
+		manager.events.PublishEvent(ClusterEvent{Type: EventBatchFinished, Attributes: map[string]string{
+			"batch":     taskID,
+			"operation": operation,
+			"succeeded": strconv.Itoa(succeeded),
+			"failed":    strconv.Itoa(failed),
+		}})
+	}
+}
+
 func (manager *ResourceManager) updateBatchStatus() {
-	const (
-		TaskExpire = time.Second * 30
-	)
-	var expireTime = time.Now().Add(-TaskExpire)
+	var expireTime = time.Now().Add(-GetConfigurator().GetTaskRetention())
+	var stallTime = time.Now().Add(-batchTaskStallTimeout)
+	var recoverTime = time.Now().Add(-batchTaskRecoverTimeout)
+	var synchronized = manager.instancesSynchronized()
+	var changed = manager.releaseExpiredMigrations()
+	var unfinished = manager.unfinishedBatchTasks()
+	defer func() {
+		manager.publishBatchFinished(unfinished)
+		if changed {
+			if err := manager.saveConfig(); err != nil {
+				log.Printf("<resource_manager> save batch status fail: %s", err.Error())
+			}
+		}
+	}()
 	if 0 != len(manager.batchCreateTasks) {
 		//create batch
 		var expired []string
@@ -917,10 +1265,36 @@ func (manager *ResourceManager) updateBatchStatus() {
 				}
 			} else {
 				//unfinished
-				if task.LatestUpdate.Before(expireTime) {
+				if task.Recovered {
+					if !synchronized {
+						if task.LatestUpdate.Before(recoverTime) {
+							for guestIndex, guest := range task.Guests {
+								if guest.Status == BatchTaskStatusProcess {
+									guest.Status = BatchTaskStatusFail
+									guest.Error = "status lost after restart of core"
+									task.Guests[guestIndex] = guest
+								}
+							}
+							task.Finished = true
+							task.LatestUpdate = time.Now()
+							manager.batchCreateTasks[taskID] = task
+							changed = true
+							log.Printf("<resource_manager> mark recovered batch create task '%s' finished due to cells not synchronized", taskID)
+						}
+						//wait for all cells report instances
+						continue
+					}
+					task.Recovered = false
+					task.LatestUpdate = time.Now()
+					manager.batchCreateTasks[taskID] = task
+					changed = true
+					log.Printf("<resource_manager> recovered batch create task '%s' resumed", taskID)
+				}
+				if task.LatestUpdate.Before(stallTime) {
 					//expired
 					task.Finished = true
 					manager.batchCreateTasks[taskID] = task
+					changed = true
 					log.Printf("<resource_manager> mark batch create task '%s' finished due to expire", taskID)
 					continue
 				}
@@ -933,6 +1307,7 @@ func (manager *ResourceManager) updateBatchStatus() {
 							//create fail
 							guest.Status = BatchTaskStatusFail
 							guest.Error = createError.Error()
+							changed = true
 							log.Printf("<resource_manager> batch create guest '%s' fail: %s", guest.Name, createError.Error())
 						} else if 0 == len(guest.ID) {
 							//not id allocated
@@ -947,6 +1322,7 @@ func (manager *ResourceManager) updateBatchStatus() {
 							}
 							if ins.Created {
 								guest.Status = BatchTaskStatusSuccess
+								changed = true
 								log.Printf("<resource_manager> update guest '%s' as created in batch '%s'", guest.Name, taskID)
 							} else {
 								guest.Progress = ins.Progress
@@ -965,6 +1341,7 @@ func (manager *ResourceManager) updateBatchStatus() {
 					//all guest processed
 					task.Finished = true
 					manager.batchCreateTasks[taskID] = task
+					changed = true
 					log.Printf("<resource_manager> batch create task '%s' finished", taskID)
 				}
 			}
@@ -974,6 +1351,7 @@ func (manager *ResourceManager) updateBatchStatus() {
 				delete(manager.batchCreateTasks, taskID)
 				log.Printf("<resource_manager> release expired batch create task '%s'", taskID)
 			}
+			changed = true
 		}
 	}
 	if 0 != len(manager.batchDeleteTasks) {
@@ -987,10 +1365,36 @@ func (manager *ResourceManager) updateBatchStatus() {
 				}
 			} else {
 				//unfinished
-				if task.LatestUpdate.Before(expireTime) {
+				if task.Recovered {
+					if !synchronized {
+						if task.LatestUpdate.Before(recoverTime) {
+							for guestIndex, guest := range task.Guests {
+								if guest.Status == BatchTaskStatusProcess {
+									guest.Status = BatchTaskStatusFail
+									guest.Error = "status lost after restart of core"
+									task.Guests[guestIndex] = guest
+								}
+							}
+							task.Finished = true
+							task.LatestUpdate = time.Now()
+							manager.batchDeleteTasks[taskID] = task
+							changed = true
+							log.Printf("<resource_manager> mark recovered batch delete task '%s' finished due to cells not synchronized", taskID)
+						}
+						//wait for all cells report instances
+						continue
+					}
+					task.Recovered = false
+					task.LatestUpdate = time.Now()
+					manager.batchDeleteTasks[taskID] = task
+					changed = true
+					log.Printf("<resource_manager> recovered batch delete task '%s' resumed", taskID)
+				}
+				if task.LatestUpdate.Before(stallTime) {
 					//expired
 					task.Finished = true
 					manager.batchDeleteTasks[taskID] = task
+					changed = true
 					log.Printf("<resource_manager> mark batch delete task '%s' finished due to expire", taskID)
 					continue
 				}
@@ -1002,6 +1406,7 @@ func (manager *ResourceManager) updateBatchStatus() {
 							//already deleted
 							guest.Status = BatchTaskStatusSuccess
 							task.Guests[guestIndex] = guest
+							changed = true
 							log.Printf("<resource_manager> update guest '%s' as deleted in batch '%s'", guest.Name, taskID)
 						} else {
 							unfinishedGuestCount++
@@ -1012,6 +1417,7 @@ func (manager *ResourceManager) updateBatchStatus() {
 					//all guest processed
 					task.Finished = true
 					manager.batchDeleteTasks[taskID] = task
+					changed = true
 					log.Printf("<resource_manager> batch delete task '%s' finished", taskID)
 				}
 			}
@@ -1021,6 +1427,7 @@ func (manager *ResourceManager) updateBatchStatus() {
 				delete(manager.batchDeleteTasks, taskID)
 				log.Printf("<resource_manager> release expired batch delete task '%s'", taskID)
 			}
+			changed = true
 		}
 	}
 
@@ -1035,10 +1442,36 @@ func (manager *ResourceManager) updateBatchStatus() {
 				}
 			} else {
 				//unfinished
-				if task.LatestUpdate.Before(expireTime) {
+				if task.Recovered {
+					if !synchronized {
+						if task.LatestUpdate.Before(recoverTime) {
+							for guestIndex, guest := range task.Guests {
+								if guest.Status == BatchTaskStatusProcess {
+									guest.Status = BatchTaskStatusFail
+									guest.Error = "status lost after restart of core"
+									task.Guests[guestIndex] = guest
+								}
+							}
+							task.Finished = true
+							task.LatestUpdate = time.Now()
+							manager.batchStopTasks[taskID] = task
+							changed = true
+							log.Printf("<resource_manager> mark recovered batch stop task '%s' finished due to cells not synchronized", taskID)
+						}
+						//wait for all cells report instances
+						continue
+					}
+					task.Recovered = false
+					task.LatestUpdate = time.Now()
+					manager.batchStopTasks[taskID] = task
+					changed = true
+					log.Printf("<resource_manager> recovered batch stop task '%s' resumed", taskID)
+				}
+				if task.LatestUpdate.Before(stallTime) {
 					//expired
 					task.Finished = true
 					manager.batchStopTasks[taskID] = task
+					changed = true
 					log.Printf("<resource_manager> mark batch stop task '%s' finished due to expire", taskID)
 					continue
 				}
@@ -1046,10 +1479,11 @@ func (manager *ResourceManager) updateBatchStatus() {
 				var unfinishedGuestCount = 0
 				for guestIndex, guest := range task.Guests {
 					if guest.Status == BatchTaskStatusProcess {
-						if _, exists := manager.instances[guest.ID]; !exists {
-							//already stopd
+						if ins, exists := manager.instances[guest.ID]; !exists || !ins.Running {
+							//already stopped
 							guest.Status = BatchTaskStatusSuccess
 							task.Guests[guestIndex] = guest
+							changed = true
 							log.Printf("<resource_manager> update guest '%s' as stopped in batch '%s'", guest.Name, taskID)
 						} else {
 							unfinishedGuestCount++
@@ -1060,6 +1494,7 @@ func (manager *ResourceManager) updateBatchStatus() {
 					//all guest processed
 					task.Finished = true
 					manager.batchStopTasks[taskID] = task
+					changed = true
 					log.Printf("<resource_manager> batch stop task '%s' finished", taskID)
 				}
 			}
@@ -1069,6 +1504,7 @@ func (manager *ResourceManager) updateBatchStatus() {
 				delete(manager.batchStopTasks, taskID)
 				log.Printf("<resource_manager> release expired batch stop task '%s'", taskID)
 			}
+			changed = true
 		}
 	}
 }
@@ -1081,9 +1517,9 @@ func (manager *ResourceManager) handleCommand(cmd resourceCommand) {
 	case cmdGetComputePoolInfo:
 		err = manager.handleGetComputePool(cmd.Pool, cmd.ResultChan)
 	case cmdCreateComputePool:
-		err = manager.handleCreatePool(cmd.Pool, cmd.Storage, cmd.Address, cmd.Failover, cmd.ErrorChan)
+		err = manager.handleCreatePool(cmd.Pool, cmd.Storage, cmd.Address, cmd.Failover, cmd.Strategy, cmd.Overcommit, cmd.ErrorChan)
 	case cmdModifyComputePool:
-		err = manager.handleModifyPool(cmd.Pool, cmd.Storage, cmd.Address, cmd.Failover, cmd.ErrorChan)
+		err = manager.handleModifyPool(cmd.Pool, cmd.Storage, cmd.Address, cmd.Failover, cmd.Strategy, cmd.Overcommit, cmd.ErrorChan)
 	case cmdDeleteComputePool:
 		err = manager.handleDeletePool(cmd.Pool, cmd.ErrorChan)
 	case cmdQueryStoragePool:
@@ -1109,6 +1545,34 @@ func (manager *ResourceManager) handleCommand(cmd resourceCommand) {
 		err = manager.handleDisableCell(cmd.Pool, cmd.Cell, cmd.Failover, cmd.ErrorChan)
 	case cmdFinishPurgeCell:
 		err = manager.handleFinishPurgeCell(cmd.Cell, cmd.ErrorChan)
+	case cmdQueryCellMaintenance:
+		err = manager.handleQueryCellMaintenance(cmd.ResultChan)
+	case cmdGetCellMaintenance:
+		err = manager.handleGetCellMaintenance(cmd.Pool, cmd.Cell, cmd.ResultChan)
+	case cmdStartCellMaintenance:
+		err = manager.handleStartCellMaintenance(cmd.Pool, cmd.Cell, cmd.Flag, cmd.ErrorChan)
+	case cmdCancelCellMaintenance:
+		err = manager.handleCancelCellMaintenance(cmd.Pool, cmd.Cell, cmd.ErrorChan)
+	case cmdNextMaintenanceBatch:
+		err = manager.handleNextMaintenanceBatch(cmd.Cell, cmd.ResultChan)
+	case cmdSetMaintenanceMigration:
+		err = manager.handleSetMaintenanceMigration(cmd.Cell, cmd.MigrationID, cmd.Error, cmd.ErrorChan)
+	case cmdGetCellLabels:
+		err = manager.handleGetCellLabels(cmd.Pool, cmd.Cell, cmd.ResultChan)
+	case cmdModifyCellLabels:
+		err = manager.handleModifyCellLabels(cmd.Pool, cmd.Cell, cmd.Labels, cmd.ErrorChan)
+	case cmdGetRebalancePlan:
+		err = manager.handleGetRebalancePlan(cmd.Pool, cmd.ResultChan)
+	case cmdModifyRebalanceConfig:
+		err = manager.handleModifyRebalanceConfig(cmd.Pool, cmd.Rebalance, cmd.ErrorChan)
+	case cmdStartRebalance:
+		err = manager.handleStartRebalance(cmd.Pool, cmd.ResultChan)
+	case cmdQueryRebalance:
+		err = manager.handleQueryRebalance(cmd.ResultChan)
+	case cmdNextRebalanceBatch:
+		err = manager.handleNextRebalanceBatch(cmd.Pool, cmd.ResultChan)
+	case cmdSetRebalanceMigration:
+		err = manager.handleSetRebalanceMigration(cmd.Pool, cmd.MigrationID, cmd.Error, cmd.ErrorChan)
 	case cmdQueryUnallocatedComputeCell:
 		err = manager.handleGetUnallocatedCells(cmd.ResultChan)
 	case cmdQueryZoneStatus:
@@ -1142,9 +1606,9 @@ func (manager *ResourceManager) handleCommand(cmd resourceCommand) {
 	case cmdGetInstanceStatus:
 		err = manager.handleGetInstanceStatus(cmd.InstanceID, cmd.ResultChan)
 	case cmdQueryInstanceStatusInPool:
-		err = manager.handleQueryInstanceStatusInPool(cmd.Pool, cmd.ResultChan)
+		err = manager.handleQueryInstanceStatusInPool(cmd.Pool, cmd.ListOption, cmd.ResultChan)
 	case cmdQueryInstanceStatusInCell:
-		err = manager.handleQueryInstanceStatusInCell(cmd.Pool, cmd.Cell, cmd.ResultChan)
+		err = manager.handleQueryInstanceStatusInCell(cmd.Pool, cmd.Cell, cmd.ListOption, cmd.ResultChan)
 	case cmdUpdateInstanceAddress:
 		err = manager.handleUpdateInstanceAddress(cmd.InstanceID, cmd.Address, cmd.ErrorChan)
 	case cmdRenameInstance:
@@ -1170,7 +1634,7 @@ func (manager *ResourceManager) handleCommand(cmd resourceCommand) {
 	case cmdSetCellDead:
 		err = manager.handleSetCellStopped(cmd.Cell, cmd.ErrorChan)
 	case cmdQueryMigration:
-		err = manager.handleQueryMigration(cmd.ResultChan)
+		err = manager.handleQueryMigration(cmd.ListOption, cmd.ResultChan)
 	case cmdGetMigration:
 		err = manager.handleGetMigration(cmd.MigrationID, cmd.ResultChan)
 	case cmdCreateMigration:
@@ -1181,6 +1645,20 @@ func (manager *ResourceManager) handleCommand(cmd resourceCommand) {
 		err = manager.handleCancelMigration(cmd.MigrationID, cmd.Error, cmd.ErrorChan)
 	case cmdBuildFailoverPlan:
 		err = manager.handleBuildFailoverPlan(cmd.Cell, cmd.ResultChan)
+	case cmdPreviewFailover:
+		err = manager.handlePreviewFailover(cmd.Pool, cmd.Cell, cmd.ResultChan)
+	case cmdGetFailoverCapacity:
+		err = manager.handleGetFailoverCapacity(cmd.Pool, cmd.ResultChan)
+	case cmdModifyFailoverCapacity:
+		err = manager.handleModifyFailoverCapacity(cmd.Pool, cmd.FailoverCapacity, cmd.ErrorChan)
+	case cmdExportResourceData:
+		err = manager.handleExportResourceData(cmd.Revision, cmd.ResultChan)
+	case cmdExportZoneBackup:
+		err = manager.handleExportZoneBackup(cmd.ResultChan)
+	case cmdRestoreZoneBackup:
+		err = manager.handleRestoreZoneBackup(cmd.ZoneBackup, cmd.Flag, cmd.ResultChan)
+	case cmdLookupInstance:
+		err = manager.handleLookupInstance(cmd.InstanceID, cmd.ResultChan)
 	case cmdMigrationInstance:
 		err = manager.handleMigrateInstance(cmd.Cell, cmd.Target, cmd.IDList, cmd.PortList, cmd.ErrorChan)
 	case cmdPurgeInstance:
@@ -1196,7 +1674,7 @@ func (manager *ResourceManager) handleCommand(cmd resourceCommand) {
 	case cmdDeleteAddressPool:
 		err = manager.handleDeleteAddressPool(cmd.Address, cmd.ErrorChan)
 	case cmdQueryAddressRange:
-		err = manager.handleQueryAddressRange(cmd.Address, cmd.Range, cmd.ResultChan)
+		err = manager.handleQueryAddressRange(cmd.Address, cmd.Range, cmd.ListOption, cmd.ResultChan)
 	case cmdGetAddressRange:
 		err = manager.handleGetAddressRange(cmd.Address, cmd.Range, cmd.Start, cmd.ResultChan)
 	case cmdAddAddressRange:
@@ -1207,6 +1685,8 @@ func (manager *ResourceManager) handleCommand(cmd resourceCommand) {
 		err = manager.handleBeginResetSystem(cmd.InstanceID, cmd.ErrorChan)
 	case cmdFinishResetSystem:
 		err = manager.handleFinishResetSystem(cmd.InstanceID, cmd.Error, cmd.ErrorChan)
+	case cmdGetResetSystem:
+		err = manager.handleGetResetSystemStatus(cmd.InstanceID, cmd.ResultChan)
 	case cmdStartBatchCreateGuest:
 		err = manager.handleStartBatchCreateGuest(cmd.BatchCreating, cmd.ResultChan)
 	case cmdSetBatchCreateGuestStart:
@@ -1242,7 +1722,7 @@ func (manager *ResourceManager) handleCommand(cmd resourceCommand) {
 	case cmdDeleteSystemTemplate:
 		err = manager.handleDeleteSystemTemplate(cmd.TemplateID, cmd.ErrorChan)
 	case cmdQuerySecurityPolicyGroups:
-		err = manager.handleQuerySecurityPolicyGroups(cmd.PolicyGroupQuery, cmd.ResultChan)
+		err = manager.handleQuerySecurityPolicyGroups(cmd.PolicyGroupQuery, cmd.ListOption, cmd.ResultChan)
 	case cmdGetSecurityPolicyGroup:
 		err = manager.handleGetSecurityPolicyGroup(cmd.Group, cmd.ResultChan)
 	case cmdCreateSecurityPolicyGroup:
@@ -1261,6 +1741,30 @@ func (manager *ResourceManager) handleCommand(cmd resourceCommand) {
 		err = manager.handleRemoveSecurityPolicyRule(cmd.Group, cmd.Index, cmd.ErrorChan)
 	case cmdMoveSecurityPolicyRule:
 		err = manager.handleMoveSecurityPolicyRule(cmd.Group, cmd.Index, cmd.Flag, cmd.ErrorChan)
+	case cmdQueryServerGroups:
+		err = manager.handleQueryServerGroups(cmd.ServerGroupQuery, cmd.ResultChan)
+	case cmdGetServerGroup:
+		err = manager.handleGetServerGroup(cmd.Group, cmd.ResultChan)
+	case cmdCreateServerGroup:
+		err = manager.handleCreateServerGroup(cmd.ServerGroup, cmd.ResultChan)
+	case cmdModifyServerGroup:
+		err = manager.handleModifyServerGroup(cmd.Group, cmd.ServerGroup, cmd.ErrorChan)
+	case cmdDeleteServerGroup:
+		err = manager.handleDeleteServerGroup(cmd.Group, cmd.ErrorChan)
+	case cmdQueryQuotas:
+		err = manager.handleQueryQuotas(cmd.ResultChan)
+	case cmdGetQuota:
+		err = manager.handleGetQuota(cmd.Scope, cmd.Name, cmd.ResultChan)
+	case cmdCreateQuota:
+		err = manager.handleCreateQuota(cmd.Scope, cmd.Name, cmd.Quota, cmd.ErrorChan)
+	case cmdModifyQuota:
+		err = manager.handleModifyQuota(cmd.Scope, cmd.Name, cmd.Quota, cmd.ErrorChan)
+	case cmdDeleteQuota:
+		err = manager.handleDeleteQuota(cmd.Scope, cmd.Name, cmd.ErrorChan)
+	case cmdCheckQuota:
+		err = manager.handleCheckQuota(cmd.User, cmd.Group, cmd.Quota, cmd.ErrorChan)
+	case cmdUpdateSnapshotCount:
+		err = manager.handleUpdateSnapshotCount(cmd.InstanceID, cmd.Count, cmd.ErrorChan)
 	default:
 		log.Printf("<resource_manager> unsupported command type %d", cmd.Type)
 		break
@@ -1361,7 +1865,8 @@ func (manager *ResourceManager) handleQueryAllPools(resp chan ResourceResult) er
 	sort.Stable(sort.StringSlice(names))
 	for _, poolName := range names {
 		pool, _ := manager.pools[poolName]
-		var info = ComputePoolInfo{poolName, pool.Enabled, pool.Network, pool.Storage, pool.Failover, uint64(len(pool.Cells))}
+		var info = ComputePoolInfo{Name: poolName, Enabled: pool.Enabled, Network: pool.Network, Storage: pool.Storage,
+			Failover: pool.Failover, Strategy: pool.Strategy, PoolOvercommit: pool.PoolOvercommit, CellCount: uint64(len(pool.Cells))}
 		result = append(result, info)
 	}
 	resp <- ResourceResult{ComputePoolInfoList: result}
@@ -1379,12 +1884,24 @@ func (manager *ResourceManager) handleGetComputePool(poolName string, resp chan
 	return nil
 }
 
-func (manager *ResourceManager) handleCreatePool(name, storage, addressPool string, failover bool, resp chan error) (err error) {
+func (manager *ResourceManager) handleCreatePool(name, storage, addressPool string, failover bool, strategy string, update PoolOvercommitUpdate, resp chan error) (err error) {
 	if _, exists := manager.pools[name]; exists {
 		err = fmt.Errorf("'%s' alrady exists", name)
 		resp <- err
 		return err
 	}
+	if "" == strategy {
+		strategy = DefaultPlacementStrategy
+	}
+	if _, err = getPlacementStrategy(strategy); err != nil {
+		resp <- err
+		return err
+	}
+	var overcommit = update.Apply(defaultPoolOvercommit())
+	if err = overcommit.Verify(); err != nil {
+		resp <- err
+		return err
+	}
 	var newPool = ManagedComputePool{}
 	newPool.Enabled = true
 	newPool.Name = name
@@ -1416,23 +1933,46 @@ func (manager *ResourceManager) handleCreatePool(name, storage, addressPool stri
 		log.Printf("<resource_manager> address pool '%s' bound to '%s'", addressPool, name)
 	}
 	newPool.Failover = failover
+	newPool.Strategy = strategy
+	newPool.PoolOvercommit = overcommit
+	newPool.Rebalance = defaultRebalanceConfig()
+	newPool.FailoverCapacity = defaultFailoverCapacity()
+	log.Printf("<resource_manager> compute pool '%s' using placement strategy '%s', overcommit cpu %.2f/ memory %.2f/ disk %.2f, cpu health threshold %.2f%%",
+		name, strategy, overcommit.CPURatio, overcommit.MemoryRatio, overcommit.DiskRatio, overcommit.CPUHealthThreshold)
 	manager.pools[name] = newPool
 	resp <- nil
 	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleModifyPool(poolName, storage, addressPool string, failover bool, resp chan error) (err error) {
+func (manager *ResourceManager) handleModifyPool(poolName, storage, addressPool string, failover bool, strategy string, update PoolOvercommitUpdate, resp chan error) (err error) {
 	pool, exists := manager.pools[poolName]
 	if !exists {
 		err = fmt.Errorf("invalid pool'%s'", poolName)
 		resp <- err
 		return err
 	}
-	if (pool.Storage == storage) && (pool.Failover == failover) && (pool.Network == addressPool) {
+	if "" == strategy {
+		strategy = DefaultPlacementStrategy
+	}
+	var overcommit = update.Apply(pool.PoolOvercommit)
+	if err = overcommit.Verify(); err != nil {
+		resp <- err
+		return err
+	}
+	if (pool.Storage == storage) && (pool.Failover == failover) && (pool.Network == addressPool) && (pool.Strategy == strategy) &&
+		(pool.PoolOvercommit == overcommit) {
 		err = errors.New("no need to change")
 		resp <- err
 		return err
 	}
+	if _, err = getPlacementStrategy(strategy); err != nil {
+		resp <- err
+		return err
+	}
+	if err = overcommit.Verify(); err != nil {
+		resp <- err
+		return err
+	}
 	var sharedStorage = "" != storage
 	if pool.Failover != failover {
 		//change failover
@@ -1517,6 +2057,15 @@ func (manager *ResourceManager) handleModifyPool(poolName, storage, addressPool
 		pool.Network = addressPool
 
 	}
+	if pool.Strategy != strategy {
+		log.Printf("<resource_manager> placement strategy of pool '%s' changed from '%s' to '%s'", poolName, pool.Strategy, strategy)
+		pool.Strategy = strategy
+	}
+	if pool.PoolOvercommit != overcommit {
+		log.Printf("<resource_manager> overcommit of pool '%s' changed to cpu %.2f/ memory %.2f/ disk %.2f, cpu health threshold %.2f%%",
+			poolName, overcommit.CPURatio, overcommit.MemoryRatio, overcommit.DiskRatio, overcommit.CPUHealthThreshold)
+		pool.PoolOvercommit = overcommit
+	}
 
 	manager.pools[poolName] = pool
 	resp <- nil
@@ -1536,6 +2085,8 @@ func (manager *ResourceManager) handleDeletePool(name string, resp chan error) e
 		return err
 	}
 	delete(manager.pools, name)
+	delete(manager.rebalances, name)
+	delete(manager.failoverCapacity, name)
 	log.Printf("<resource_manager> compute pool '%s' deleted", name)
 	resp <- nil
 	return manager.saveConfig()
@@ -1755,6 +2306,7 @@ func (manager *ResourceManager) handleRemoveCell(poolName, cellName string, resp
 		return err
 	}
 	delete(pool.Cells, cellName)
+	delete(manager.maintenances, cellName)
 	cell.Pool = ""
 	manager.unallocatedCells[cellName] = true
 	pool.CellCount = uint64(len(pool.Cells))
@@ -1796,6 +2348,10 @@ func (manager *ResourceManager) handleEnableCell(poolName, cellName string, resp
 	} else {
 		log.Printf("<resource_manager> cell '%s' in pool '%s' enabled", cellName, poolName)
 	}
+	if _, exists = manager.maintenances[cellName]; exists {
+		delete(manager.maintenances, cellName)
+		log.Printf("<resource_manager> maintenance of cell '%s' closed", cellName)
+	}
 	cell.PurgeAppending = false
 	cell.Enabled = true
 	manager.cells[cellName] = cell
@@ -1857,523 +2413,1033 @@ func (manager *ResourceManager) handleFinishPurgeCell(cellName string, respChan
 	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleGetUnallocatedCells(resp chan ResourceResult) error {
-	var cells []ComputeCellInfo
-	for name, _ := range manager.unallocatedCells {
-		if cell, exists := manager.cells[name]; !exists {
-			err := fmt.Errorf("invalid cell '%s'", name)
-			resp <- ResourceResult{Error: err}
-			return err
-		} else {
-			var info = ComputeCellInfo{cell.Name, cell.Address, cell.Enabled, cell.Alive, cell.PurgeAppending}
-			cells = append(cells, info)
-		}
-	}
-	resp <- ResourceResult{ComputeCellInfoList: cells}
-	return nil
-}
-
-func (manager *ResourceManager) handleQueryZoneStatus(resp chan ResourceResult) error {
-	var s = ZoneStatus{Name: manager.zone.Name,
-		PoolStatistic: manager.zone.PoolStatistic, CellStatistic: manager.zone.CellStatistic,
-		InstanceStatistic: manager.zone.InstanceStatistic, ResourceUsage: manager.zone.ResourceUsage,
-		StartTime: manager.startTime}
-
-	resp <- ResourceResult{Zone: s}
-	return nil
-}
-
-func (manager *ResourceManager) handleQueryComputePoolStatus(resp chan ResourceResult) error {
-	var pools []ComputePoolStatus
+func (manager *ResourceManager) handleQueryCellMaintenance(respChan chan ResourceResult) (err error) {
 	var names []string
-	for name, _ := range manager.pools {
-		names = append(names, name)
+	for cellName, _ := range manager.maintenances {
+		names = append(names, cellName)
 	}
 	sort.Stable(sort.StringSlice(names))
-	for _, poolName := range names {
-		pool, _ := manager.pools[poolName]
-		var s = ComputePoolStatus{
-			Name: pool.Name, Enabled: pool.Enabled,
-			CellStatistic: pool.CellStatistic, InstanceStatistic: pool.InstanceStatistic, ResourceUsage: pool.ResourceUsage}
-		pools = append(pools, s)
+	var result ResourceResult
+	for _, cellName := range names {
+		var status = manager.refreshMaintenance(manager.maintenances[cellName])
+		result.MaintenanceList = append(result.MaintenanceList, status)
 	}
-	resp <- ResourceResult{ComputePoolList: pools}
+	respChan <- result
 	return nil
 }
 
-func (manager *ResourceManager) handleGetComputePoolStatus(name string, resp chan ResourceResult) error {
-	pool, exists := manager.pools[name]
-	if !exists {
-		err := fmt.Errorf("invalid pool '%s'", name)
-		resp <- ResourceResult{Error: err}
+func (manager *ResourceManager) handleGetCellMaintenance(poolName, cellName string, respChan chan ResourceResult) (err error) {
+	status, exists := manager.maintenances[cellName]
+	if !exists || status.Pool != poolName {
+		err = fmt.Errorf("cell '%s' of pool '%s' not in maintenance", cellName, poolName)
+		respChan <- ResourceResult{Error: err}
 		return err
 	}
-	var s = ComputePoolStatus{
-		Name: pool.Name, Enabled: pool.Enabled,
-		CellStatistic: pool.CellStatistic, InstanceStatistic: pool.InstanceStatistic, ResourceUsage: pool.ResourceUsage}
-
-	resp <- ResourceResult{ComputePool: s}
+	respChan <- ResourceResult{Maintenance: manager.refreshMaintenance(status)}
 	return nil
 }
 
-func (manager *ResourceManager) handleQueryComputeCellStatus(poolName string, resp chan ResourceResult) error {
-	pool, exists := manager.pools[poolName]
+func (manager *ResourceManager) handleStartCellMaintenance(poolName, cellName string, stopRunning bool, respChan chan error) (err error) {
+	if _, exists := manager.pools[poolName]; !exists {
+		err = fmt.Errorf("invalid compute pool '%s'", poolName)
+		respChan <- err
+		return err
+	}
+	cell, exists := manager.cells[cellName]
 	if !exists {
-		err := fmt.Errorf("invalid pool '%s'", poolName)
-		resp <- ResourceResult{Error: err}
+		err = fmt.Errorf("invalid compute cell '%s'", cellName)
+		respChan <- err
 		return err
 	}
-	var result []ComputeCellStatus
-	var names []string
-	for name, _ := range pool.Cells {
-		names = append(names, name)
+	if cell.Pool != poolName {
+		err = fmt.Errorf("cell '%s' not in pool '%s'", cellName, cell.Pool)
+		respChan <- err
+		return err
 	}
-	sort.Stable(sort.StringSlice(names))
-
-	for _, cellName := range names {
-		cell, exists := manager.cells[cellName]
-		if !exists {
-			err := fmt.Errorf("invalid cell '%s' in pool %s", cellName, poolName)
-			resp <- ResourceResult{Error: err}
+	if !cell.Alive {
+		err = fmt.Errorf("cell '%s' already lost", cellName)
+		respChan <- err
+		return err
+	}
+	if cell.PurgeAppending {
+		err = fmt.Errorf("cell '%s' has purge appending", cellName)
+		respChan <- err
+		return err
+	}
+	if current, exists := manager.maintenances[cellName]; exists && !current.Finished {
+		err = fmt.Errorf("cell '%s' already in maintenance", cellName)
+		respChan <- err
+		return err
+	}
+	if !stopRunning {
+		//migration between cells only moves stopped instances, cell doesn't support live migration
+		if !cell.Synchronized {
+			err = fmt.Errorf("instances of cell '%s' not reported yet, try later", cellName)
+			respChan <- err
+			return err
+		}
+		var running []string
+		for instanceID, _ := range cell.Instances {
+			if ins, exists := manager.instances[instanceID]; exists && ins.Running {
+				running = append(running, ins.Name)
+			}
+		}
+		if 0 != len(running) {
+			sort.Strings(running)
+			err = fmt.Errorf("live migration not supported, %d running instance(s) in cell '%s' can't drain: %s. "+
+				"stop them first, or enable stop_running to stop them before migrating and start them again on target cell",
+				len(running), cellName, strings.Join(running, ", "))
+			respChan <- err
 			return err
 		}
-		var s = ComputeCellStatus{ComputeCellInfo: cell.ComputeCellInfo,
-			InstanceStatistic: cell.InstanceStatistic, ResourceUsage: cell.ResourceUsage}
-		result = append(result, s)
 	}
-	resp <- ResourceResult{ComputeCellList: result}
-	return nil
+	var status = CellMaintenanceStatus{
+		Pool:        poolName,
+		Cell:        cellName,
+		StopRunning: stopRunning,
+		Total:       len(cell.Instances) + len(cell.Pending),
+		StartTime:   time.Now().Format(TimeFormatLayout),
+	}
+	status = manager.refreshMaintenance(status)
+	if stopRunning {
+		log.Printf("<resource_manager> cell '%s' in pool '%s' enter maintenance, %d instance(s) to drain, %d running will be stopped and started again",
+			cellName, poolName, status.Total, status.Waiting)
+	} else {
+		log.Printf("<resource_manager> cell '%s' in pool '%s' enter maintenance, %d instance(s) to drain",
+			cellName, poolName, status.Total)
+	}
+	respChan <- nil
+	cell.Enabled = false
+	manager.cells[cellName] = cell
+	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleGetComputeCellStatus(poolName, cellName string, resp chan ResourceResult) error {
+// handleCancelCellMaintenance : stop draining and enable the cell again, a migration in progress continues until finished
+func (manager *ResourceManager) handleCancelCellMaintenance(poolName, cellName string, respChan chan error) (err error) {
+	status, exists := manager.maintenances[cellName]
+	if !exists || status.Pool != poolName {
+		err = fmt.Errorf("cell '%s' of pool '%s' not in maintenance", cellName, poolName)
+		respChan <- err
+		return err
+	}
 	cell, exists := manager.cells[cellName]
 	if !exists {
-		err := fmt.Errorf("invalid cell '%s'", cellName)
-		resp <- ResourceResult{Error: err}
+		err = fmt.Errorf("invalid compute cell '%s'", cellName)
+		respChan <- err
 		return err
 	}
-	if cell.Pool != poolName {
-		err := fmt.Errorf("cell '%s' not in pool '%s'", cellName, poolName)
-		resp <- ResourceResult{Error: err}
-		return err
+	delete(manager.maintenances, cellName)
+	for _, instanceID := range status.Restart {
+		delete(manager.maintenanceStops, instanceID)
 	}
-	var s = ComputeCellStatus{ComputeCellInfo: cell.ComputeCellInfo, InstanceStatistic: cell.InstanceStatistic, ResourceUsage: cell.ResourceUsage}
-	resp <- ResourceResult{ComputeCell: s}
-	return nil
-}
-
-func (manager *ResourceManager) handleUpdateCellInfo(cellName, cellAddress string, respChan chan error) (err error) {
-	cell, exists := manager.cells[cellName]
-	if !exists {
-		var cellStatus = ManagedComputeCell{}
-		cellStatus.LatestUpdate = time.Now()
-		cellStatus.Name = cellName
-		cellStatus.Address = cellAddress
-		cellStatus.Enabled = true
-		cellStatus.Alive = true
-		cellStatus.Instances = map[string]bool{}
-		cellStatus.Pending = map[string]bool{}
-		manager.unallocatedCells[cellName] = true
-		manager.cells[cellName] = cellStatus
-		log.Printf("<resource_manager> new unallocated cell '%s' (address %s) available", cellName, cellAddress)
+	if "" != status.Migration {
+		log.Printf("<resource_manager> maintenance of cell '%s' canceled, migration '%s' still in progress",
+			cellName, status.Migration)
 	} else {
-		cell.Alive = true
-		cell.LatestUpdate = time.Now()
-		if cell.Address != cellAddress {
-			log.Printf("<resource_manager> cell '%s' address changed to %s", cellName, cellAddress)
-			cell.Address = cellAddress
-		}
-		manager.cells[cellName] = cell
+		log.Printf("<resource_manager> maintenance of cell '%s' canceled", cellName)
 	}
 	respChan <- nil
-	return nil
-
+	cell.Enabled = true
+	manager.cells[cellName] = cell
+	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleGetCellStatus(cellName string, respChan chan ResourceResult) error {
-	cell, exists := manager.cells[cellName]
+// handleNextMaintenanceBatch : select stopped instances in the cell that can migrate to the same target,
+// empty instances returned when drain finished or previous batch still migrating.
+// When stop running specified, running instances returned to stop and moved ones to start again
+func (manager *ResourceManager) handleNextMaintenanceBatch(cellName string, respChan chan ResourceResult) (err error) {
+	const (
+		maintenanceBatchSize   = 5
+		maintenanceStopTimeout = time.Minute * 3
+	)
+	status, exists := manager.maintenances[cellName]
 	if !exists {
-		err := fmt.Errorf("invalid cell '%s'", cellName)
+		err = fmt.Errorf("cell '%s' not in maintenance", cellName)
 		respChan <- ResourceResult{Error: err}
 		return err
 	}
-	var status = ComputeCellStatus{cell.ComputeCellInfo, cell.InstanceStatistic, cell.ResourceUsage}
-	respChan <- ResourceResult{Pool: cell.Pool, ComputeCell: status}
-	return nil
-}
-
-func (manager *ResourceManager) handleSetCellStopped(cellName string, respChan chan error) (err error) {
-	cell, exists := manager.cells[cellName]
-	if !exists {
-		err = fmt.Errorf("invalid cell '%s'", cellName)
-		respChan <- err
-		return err
-	}
-	cell.Alive = false
-	log.Printf("<resource_manager> remote cell '%s' stopped", cellName)
-	if "" != cell.Pool {
-		//update resource statistic
-		cell.LostInstances = 0
-		for instanceID, _ := range cell.Instances {
-			if ins, exists := manager.instances[instanceID]; exists {
-				if ins.Running {
-					cell.RunningInstances--
-				} else {
-					cell.StoppedInstances--
-				}
-				cell.LostInstances++
-				ins.Lost = true
-				manager.instances[instanceID] = ins
-			} else {
-				err = fmt.Errorf("invalid instance '%s' in cell '%s'", instanceID, cellName)
-				respChan <- err
-				return err
+	status = manager.refreshMaintenance(status)
+	var next MaintenanceBatch
+	var changed = false
+	if 0 != len(status.Restart) && manager.instancesSynchronized() {
+		var restart []string
+		for _, instanceID := range status.Restart {
+			ins, exists := manager.instances[instanceID]
+			if !exists {
+				//deleted
+				continue
+			}
+			if ins.Cell == cellName || ins.Migrating {
+				restart = append(restart, instanceID)
+				continue
+			}
+			if !ins.Running {
+				next.Start = append(next.Start, instanceID)
 			}
 		}
+		if len(restart) != len(status.Restart) {
+			status.Restart = restart
+			manager.maintenances[cellName] = status
+			changed = true
+		}
+	}
+	for instanceID, _ := range manager.maintenanceStops {
+		if ins, exists := manager.instances[instanceID]; !exists || !ins.Running {
+			delete(manager.maintenanceStops, instanceID)
+		}
+	}
+	cell, exists := manager.cells[cellName]
+	if status.Finished || "" != status.Migration || !exists || !cell.Alive {
+		respChan <- ResourceResult{MaintenanceBatch: next}
+		if changed {
+			return manager.saveConfig()
+		}
+		return nil
 	}
-	manager.cells[cellName] = cell
-	respChan <- nil
-	return nil
-}
-func (manager *ResourceManager) handleQueryGuestsByCondition(condition GuestQueryCondition, respChan chan ResourceResult) (err error) {
-
 	var idList []string
-	{
-		//build filter list
-		if condition.InCell {
-			if cell, exists := manager.cells[condition.Cell]; !exists {
-				err := fmt.Errorf("invalid cell '%s'", condition.Cell)
-				respChan <- ResourceResult{Error: err}
-				return err
-			} else {
-				for id, _ := range cell.Instances {
-					idList = append(idList, id)
-				}
-			}
-		} else if pool, exists := manager.pools[condition.Pool]; !exists {
-			err := fmt.Errorf("invalid pool '%s'", condition.Pool)
-			respChan <- ResourceResult{Error: err}
-			return err
-		} else {
-			for cellName, _ := range pool.Cells {
-				if cell, exists := manager.cells[cellName]; !exists {
-					err := fmt.Errorf("invalid cell '%s' in pool '%s'", condition.Cell, condition.Pool)
-					respChan <- ResourceResult{Error: err}
-					return err
-				} else {
-					for id, _ := range cell.Instances {
-						idList = append(idList, id)
-					}
-				}
+	var draining = map[string]bool{}
+	for instanceID, _ := range cell.Instances {
+		idList = append(idList, instanceID)
+		draining[instanceID] = true
+	}
+	sort.Stable(sort.StringSlice(idList))
 
-			}
+	var batch = MigrationParameter{SourcePool: status.Pool, SourceCell: cellName, TargetPool: status.Pool}
+	var selected = map[string]bool{}
+	var required InstanceResource
+	var lastError error
+	for _, instanceID := range idList {
+		if len(batch.Instances) >= maintenanceBatchSize {
+			break
 		}
-	}
-	var names []string
-	var nameToID = map[string]string{}
-	{
-		//filter by property
-		for _, id := range idList {
-			if instance, exists := manager.instances[id]; !exists {
-				err := fmt.Errorf("invalid instance '%s'", id)
-				respChan <- ResourceResult{Error: err}
-				return err
-			} else {
-				if !(condition.WithOwner && instance.User == condition.Owner) && !(condition.WithGroup && instance.Group == condition.Group) {
-					continue
+		if selected[instanceID] {
+			continue
+		}
+		ins, exists := manager.instances[instanceID]
+		if !exists || ins.Running || ins.Migrating {
+			continue
+		}
+		var members = []string{instanceID}
+		var constraint placementConstraint
+		if constraint, err = manager.instanceConstraint(instanceID, draining, nil); err != nil {
+			lastError = err
+			continue
+		}
+		if groupID, exists := manager.instanceGroups[instanceID]; exists {
+			if ServerGroupPolicyAffinity == constraint.Policy {
+				//all members in this cell must move together
+				members = []string{}
+				var ready = true
+				for _, memberID := range manager.serverGroups[groupID].Members {
+					if !draining[memberID] {
+						continue
+					}
+					if member, exists := manager.instances[memberID]; !exists || member.Running || member.Migrating {
+						ready = false
+						break
+					}
+					members = append(members, memberID)
 				}
-				if condition.WithStatus && (instance.Running != (condition.Status == InstanceStatusRunning)) {
+				if !ready {
 					continue
 				}
-				if condition.WithCreateFlag && (instance.Created != condition.Created) {
-					continue
+			} else {
+				//only one anti-affinity member in a batch
+				var conflict = false
+				for memberID, _ := range selected {
+					if manager.instanceGroups[memberID] == groupID {
+						conflict = true
+						break
+					}
 				}
-
-				if _, exists := nameToID[instance.Name]; exists {
-					err = fmt.Errorf("encounter duplicate instance name '%s'", instance.Name)
-					respChan <- ResourceResult{Error: err}
-					return
+				if conflict {
+					continue
 				}
-
-				names = append(names, instance.Name)
-				nameToID[instance.Name] = id
 			}
 		}
+		var combined = InstanceResource{Cores: required.Cores, Memory: required.Memory}
+		combined.Disks = append(combined.Disks, required.Disks...)
+		for _, memberID := range members {
+			var member = manager.instances[memberID]
+			combined.Cores += member.Cores
+			combined.Memory += member.Memory
+			combined.Disks = append(combined.Disks, member.Disks...)
+		}
+		var target string
+		if "" == batch.TargetCell {
+			target, err = manager.selectCell(status.Pool, combined, true, constraint)
+		} else if !constraint.accepts(manager.cells[batch.TargetCell]) {
+			continue
+		} else {
+			target, err = manager.selectCell(status.Pool, combined, true,
+				placementConstraint{Required: map[string]bool{batch.TargetCell: true}})
+		}
+		if err != nil {
+			lastError = fmt.Errorf("no target for instance '%s': %s", ins.Name, err.Error())
+			continue
+		}
+		var unmatched = false
+		for _, memberID := range members {
+			if !manager.instanceSelectors[memberID].Matches(manager.cells[target].Labels) {
+				unmatched = true
+				break
+			}
+		}
+		if unmatched {
+			continue
+		}
+		batch.TargetCell = target
+		required = combined
+		for _, memberID := range members {
+			selected[memberID] = true
+			batch.Instances = append(batch.Instances, memberID)
+		}
 	}
-	sort.Stable(sort.StringSlice(names))
-	var result []InstanceStatus
-	for _, name := range names {
-		id, exists := nameToID[name]
-		if !exists {
-			err = fmt.Errorf("no instance mapped with name '%s'", name)
-			respChan <- ResourceResult{Error: err}
-			return err
+	err = nil
+	if 0 == len(batch.Instances) && nil != lastError {
+		status.Error = lastError.Error()
+	}
+	next.Migration = batch
+	if status.StopRunning {
+		var now = time.Now()
+		var stopping = 0
+		var candidates []string
+		for _, instanceID := range idList {
+			ins, exists := manager.instances[instanceID]
+			if !exists || !ins.Running || ins.Migrating {
+				continue
+			}
+			if requested, exists := manager.maintenanceStops[instanceID]; exists && now.Before(requested.Add(maintenanceStopTimeout)) {
+				stopping++
+				continue
+			}
+			candidates = append(candidates, instanceID)
 		}
-		ins, exists := manager.instances[id]
-		if !exists {
-			err = fmt.Errorf("invalid instance '%s' with name '%s'", id, name)
-			respChan <- ResourceResult{Error: err}
-			return err
+		for _, instanceID := range candidates {
+			if stopping >= maintenanceBatchSize {
+				break
+			}
+			next.Stop = append(next.Stop, instanceID)
+			manager.maintenanceStops[instanceID] = now
+			stopping++
+			var listed = false
+			for _, restartID := range status.Restart {
+				if restartID == instanceID {
+					listed = true
+					break
+				}
+			}
+			if !listed {
+				status.Restart = append(status.Restart, instanceID)
+				changed = true
+			}
 		}
-		result = append(result, ins)
 	}
-	respChan <- ResourceResult{InstanceList: result}
+	manager.maintenances[cellName] = status
+	respChan <- ResourceResult{MaintenanceBatch: next}
+	if changed {
+		return manager.saveConfig()
+	}
 	return nil
 }
 
-func (manager *ResourceManager) handleBatchUpdateInstanceStatus(poolName, cellName string, instances []InstanceStatus, respChan chan error) error {
-	pool, exists := manager.pools[poolName]
+func (manager *ResourceManager) handleSetMaintenanceMigration(cellName, migrationID string, reason error, respChan chan error) (err error) {
+	status, exists := manager.maintenances[cellName]
 	if !exists {
-		err := fmt.Errorf("invalid pool '%s'", poolName)
+		err = fmt.Errorf("cell '%s' not in maintenance", cellName)
 		respChan <- err
 		return err
 	}
+	if reason != nil {
+		status.Error = reason.Error()
+		log.Printf("<resource_manager> warning: drain cell '%s' fail: %s", cellName, status.Error)
+	} else {
+		status.Migration = migrationID
+		status.Error = ""
+		log.Printf("<resource_manager> migration '%s' started to drain cell '%s'", migrationID, cellName)
+	}
+	manager.maintenances[cellName] = status
+	respChan <- nil
+	return manager.saveConfig()
+}
+
+func (manager *ResourceManager) handleGetCellLabels(poolName, cellName string, respChan chan ResourceResult) (err error) {
 	cell, exists := manager.cells[cellName]
 	if !exists {
-		err := fmt.Errorf("invalid cell '%s'", cellName)
-		respChan <- err
+		err = fmt.Errorf("invalid compute cell '%s'", cellName)
+		respChan <- ResourceResult{Error: err}
 		return err
 	}
 	if cell.Pool != poolName {
-		err := fmt.Errorf("cell '%s' not belong to '%s'", cellName, poolName)
-		respChan <- err
+		err = fmt.Errorf("cell '%s' not in pool '%s'", cellName, poolName)
+		respChan <- ResourceResult{Error: err}
 		return err
 	}
-	if 0 != len(cell.Instances) {
-		for id, _ := range cell.Instances {
-			log.Printf("<resource_manager> clear expired instance status, id '%s'", id)
-			if ins, exists := manager.instances[id]; exists {
-				delete(pool.InstanceNames, ins.Name)
-			}
-			delete(manager.instances, id)
-		}
-	}
-	cell.Instances = map[string]bool{}
-	cell.Pending = map[string]bool{}
-	cell.InstanceStatistic.Reset()
-	for _, config := range instances {
-		config.InternalNetwork.MonitorAddress = cell.Address
-		config.Host = cell.Address
-		manager.instances[config.ID] = config
-		cell.Instances[config.ID] = true
-		//todo: migrating
-		if config.Running {
-			cell.RunningInstances++
-		} else {
-			cell.StoppedInstances++
-		}
-		pool.InstanceNames[config.Name] = config.ID
+	var labels = map[string]string{}
+	for key, value := range cell.Labels {
+		labels[key] = value
 	}
-
-	manager.cells[cellName] = cell
-	manager.pools[poolName] = pool
-	log.Printf("<resource_manager> %d instance updated in cell '%s' ", len(cell.Instances), cellName)
-	respChan <- nil
+	respChan <- ResourceResult{Labels: labels}
 	return nil
 }
 
-func (manager *ResourceManager) handleAllocateInstance(poolName string, config InstanceStatus, respChan chan ResourceResult) (err error) {
-	pool, exists := manager.pools[poolName]
+// handleModifyCellLabels : replace all labels of cell
+func (manager *ResourceManager) handleModifyCellLabels(poolName, cellName string, labels map[string]string, respChan chan error) (err error) {
+	cell, exists := manager.cells[cellName]
 	if !exists {
-		err = fmt.Errorf("invalid compute pool '%s'", poolName)
-		respChan <- ResourceResult{Error: err}
+		err = fmt.Errorf("invalid compute cell '%s'", cellName)
+		respChan <- err
 		return err
 	}
-	if _, exists = pool.InstanceNames[config.Name]; exists {
-		err = fmt.Errorf("instance '%s' already exists in pool '%s'", config.Name, poolName)
-		respChan <- ResourceResult{Error: err}
+	if cell.Pool != poolName {
+		err = fmt.Errorf("cell '%s' not in pool '%s'", cellName, poolName)
+		respChan <- err
 		return err
 	}
-	var newID = uuid.NewV4()
-	config.ID = newID.String()
-	cellName, err := manager.selectCell(poolName, config.InstanceResource, true)
-	if err != nil {
-		log.Printf("<resource_manager> select cell fail: %s", err.Error())
-		respChan <- ResourceResult{Error: err}
+	if err = VerifyCellLabels(labels); err != nil {
+		respChan <- err
 		return err
 	}
-	config.Cell = cellName
-	config.Pool = poolName
-	cell, exists := manager.cells[cellName]
-	if !exists {
-		err = fmt.Errorf("invalid cell '%s'", cellName)
-		respChan <- ResourceResult{Error: err}
-		return err
-	}
-	if "" != pool.Network {
-		//select address
-		internal, external, err := manager.allocateNetworkAddress(pool, config.ID)
-		if err != nil {
-			respChan <- ResourceResult{Error: err}
-			return err
-		}
-		if "" != external {
-			config.ExternalNetwork.AssignedAddress = external
-			log.Printf("<resource_manager> address '%s/%s' assigned for instance '%s'", internal, external, config.Name)
-		} else {
-			log.Printf("<resource_manager> internal address '%s' assigned for instance '%s'", internal, config.Name)
-		}
-		config.InternalNetwork.AssignedAddress = internal
+	cell.Labels = map[string]string{}
+	for key, value := range labels {
+		cell.Labels[key] = value
 	}
-	config.InternalNetwork.MonitorAddress = cell.Address
-	config.Host = cell.Address
-	cell.Pending[config.ID] = true
-	pool.InstanceNames[config.Name] = config.ID
-
-	manager.instances[config.ID] = config
 	manager.cells[cellName] = cell
-	manager.pools[poolName] = pool
-	respChan <- ResourceResult{Instance: config}
-	log.Printf("<resource_manager> allocate cell '%s' for instance '%s'(%s)", cellName, config.Name, config.ID)
-	return nil
+	log.Printf("<resource_manager> %d label(s) of cell '%s' updated", len(labels), cellName)
+	respChan <- nil
+	return manager.saveConfig()
 }
 
-// running/create/progress/media only
-func (manager *ResourceManager) handleUpdateInstanceStatus(status InstanceStatus, respChan chan error) error {
-	ins, exists := manager.instances[status.ID]
+// refreshMaintenance : update drain progress from instances left in cell and save back
+func (manager *ResourceManager) refreshMaintenance(status CellMaintenanceStatus) CellMaintenanceStatus {
+	cell, exists := manager.cells[status.Cell]
+	//instances unknown until cell reported after restart
+	var synchronized = !exists || cell.Synchronized
 	if !exists {
-		err := fmt.Errorf("invalid instance '%s'", status.ID)
-		respChan <- err
-		return err
-	}
-	if ins.Running != status.Running {
-		cell, exists := manager.cells[ins.Cell]
-		if !exists {
-			err := fmt.Errorf("invalid cell '%s' for instance '%s'", ins.Cell, status.ID)
-			respChan <- err
-			return err
-		}
-		if ins.Running {
-			//running => stopped
-			cell.StoppedInstances++
-			cell.RunningInstances--
-		} else {
-			//stopped => running
-			cell.StoppedInstances--
-			cell.RunningInstances++
+		status.Remaining = 0
+		status.Waiting = 0
+	} else {
+		status.Remaining = len(cell.Instances) + len(cell.Pending)
+		status.Waiting = 0
+		for instanceID, _ := range cell.Instances {
+			if ins, exists := manager.instances[instanceID]; exists && ins.Running {
+				status.Waiting++
+			}
 		}
-		ins.Running = status.Running
-		manager.cells[ins.Cell] = cell
 	}
-	if !ins.Created {
-		ins.Progress = status.Progress
-		ins.Created = status.Created
+	if 0 != status.Waiting && !status.StopRunning {
+		status.Blocked = fmt.Sprintf("%d instance(s) started after maintenance began, live migration not supported, drain waits until they stopped", status.Waiting)
+	} else {
+		status.Blocked = ""
 	}
-	if ins.Cores != status.Cores {
-		ins.Cores = status.Cores
+	if status.Total > status.Remaining {
+		status.Migrated = status.Total - status.Remaining
+	} else {
+		status.Migrated = 0
 	}
-	if ins.Memory != status.Memory {
-		ins.Memory = status.Memory
+	if "" != status.Migration {
+		migration, exists := manager.migrations[status.Migration]
+		if !exists || migration.Finished {
+			status.Migration = ""
+		} else if nil != migration.Error {
+			status.Error = migration.Error.Error()
+			status.Migration = ""
+		}
 	}
-	if len(ins.Disks) == len(status.Disks) {
-		for index := 0; index < len(ins.Disks); index++ {
-			if ins.Disks[index] != status.Disks[index] {
-				ins.Disks[index] = status.Disks[index]
-			}
+	if synchronized && 0 == status.Remaining && "" == status.Migration && 0 == len(status.Restart) {
+		if !status.Finished {
+			log.Printf("<resource_manager> cell '%s' drained, %d instance(s) migrated", status.Cell, status.Migrated)
 		}
+		status.Finished = true
 	}
-	if ins.MediaAttached != status.MediaAttached {
-		ins.MediaAttached = status.MediaAttached
-		ins.MediaSource = status.MediaSource
+	if status.Finished || 0 == status.Total {
+		status.Progress = 100
+	} else {
+		status.Progress = uint(status.Migrated * 100 / status.Total)
 	}
+	manager.maintenances[status.Cell] = status
+	return status
+}
 
-	manager.instances[status.ID] = ins
-	respChan <- nil
+func (manager *ResourceManager) handleGetUnallocatedCells(resp chan ResourceResult) error {
+	var cells []ComputeCellInfo
+	for name, _ := range manager.unallocatedCells {
+		if cell, exists := manager.cells[name]; !exists {
+			err := fmt.Errorf("invalid cell '%s'", name)
+			resp <- ResourceResult{Error: err}
+			return err
+		} else {
+			var info = ComputeCellInfo{cell.Name, cell.Address, cell.Enabled, cell.Alive, cell.PurgeAppending}
+			cells = append(cells, info)
+		}
+	}
+	resp <- ResourceResult{ComputeCellInfoList: cells}
 	return nil
 }
 
-func (manager *ResourceManager) handleConfirmInstance(id string, monitorPort uint, monitorSecret, ethernetAddress string, respChan chan error) error {
-	status, exists := manager.instances[id]
-	if !exists {
-		err := fmt.Errorf("invalid instance '%s'", id)
-		respChan <- err
-		return err
+func (manager *ResourceManager) handleQueryZoneStatus(resp chan ResourceResult) error {
+	var s = ZoneStatus{Name: manager.zone.Name,
+		PoolStatistic: manager.zone.PoolStatistic, CellStatistic: manager.zone.CellStatistic,
+		InstanceStatistic: manager.zone.InstanceStatistic, ResourceUsage: manager.zone.ResourceUsage,
+		StartTime: manager.startTime}
+
+	resp <- ResourceResult{Zone: s}
+	return nil
+}
+
+func (manager *ResourceManager) handleQueryComputePoolStatus(resp chan ResourceResult) error {
+	var pools []ComputePoolStatus
+	var names []string
+	for name, _ := range manager.pools {
+		names = append(names, name)
 	}
-	cell, exists := manager.cells[status.Cell]
-	if !exists {
-		err := fmt.Errorf("invalid cell '%s'", status.Cell)
-		respChan <- err
-		return err
+	sort.Stable(sort.StringSlice(names))
+	for _, poolName := range names {
+		pool, _ := manager.pools[poolName]
+		var s = ComputePoolStatus{
+			Name: pool.Name, Enabled: pool.Enabled,
+			CellStatistic: pool.CellStatistic, InstanceStatistic: pool.InstanceStatistic, ResourceUsage: pool.ResourceUsage}
+		pools = append(pools, s)
 	}
-	if _, exists := cell.Pending[id]; !exists {
-		err := fmt.Errorf("instance '%s' already confirmed", id)
-		respChan <- err
+	resp <- ResourceResult{ComputePoolList: pools}
+	return nil
+}
+
+func (manager *ResourceManager) handleGetComputePoolStatus(name string, resp chan ResourceResult) error {
+	pool, exists := manager.pools[name]
+	if !exists {
+		err := fmt.Errorf("invalid pool '%s'", name)
+		resp <- ResourceResult{Error: err}
 		return err
 	}
-	delete(cell.Pending, id)
-	cell.Instances[id] = true
-	cell.StoppedInstances++
-
-	status.InternalNetwork.MonitorPort = monitorPort
-	status.MonitorSecret = monitorSecret
-	status.HardwareAddress = ethernetAddress
-	status.Created = true
-	status.Progress = 0
-	status.CreateTime = time.Now().Format(TimeFormatLayout)
+	var s = ComputePoolStatus{
+		Name: pool.Name, Enabled: pool.Enabled,
+		CellStatistic: pool.CellStatistic, InstanceStatistic: pool.InstanceStatistic, ResourceUsage: pool.ResourceUsage}
 
-	//update
-	manager.instances[id] = status
-	manager.cells[status.Cell] = cell
-	log.Printf("<resource_manager> instance '%s' confirmed, monitor port %d", id, status.InternalNetwork.MonitorPort)
-	respChan <- nil
+	resp <- ResourceResult{ComputePool: s}
 	return nil
 }
 
-func (manager *ResourceManager) handleDeallocateInstance(id string, err error, respChan chan error) error {
-	ins, exists := manager.instances[id]
+func (manager *ResourceManager) handleQueryComputeCellStatus(poolName string, resp chan ResourceResult) error {
+	pool, exists := manager.pools[poolName]
 	if !exists {
-		err := fmt.Errorf("invalid instance '%s'", id)
-		respChan <- err
+		err := fmt.Errorf("invalid pool '%s'", poolName)
+		resp <- ResourceResult{Error: err}
 		return err
 	}
-	if ins.Cell == "" {
-		err := fmt.Errorf("instance '%s' not allocated", id)
-		respChan <- err
-		return err
+	var result []ComputeCellStatus
+	var names []string
+	for name, _ := range pool.Cells {
+		names = append(names, name)
 	}
-	var cellName = ins.Cell
+	sort.Stable(sort.StringSlice(names))
+
+	for _, cellName := range names {
+		cell, exists := manager.cells[cellName]
+		if !exists {
+			err := fmt.Errorf("invalid cell '%s' in pool %s", cellName, poolName)
+			resp <- ResourceResult{Error: err}
+			return err
+		}
+		var s = ComputeCellStatus{ComputeCellInfo: cell.ComputeCellInfo,
+			InstanceStatistic: cell.InstanceStatistic, ResourceUsage: cell.ResourceUsage}
+		result = append(result, s)
+	}
+	resp <- ResourceResult{ComputeCellList: result}
+	return nil
+}
+
+func (manager *ResourceManager) handleGetComputeCellStatus(poolName, cellName string, resp chan ResourceResult) error {
 	cell, exists := manager.cells[cellName]
 	if !exists {
 		err := fmt.Errorf("invalid cell '%s'", cellName)
-		respChan <- err
+		resp <- ResourceResult{Error: err}
 		return err
 	}
-	if _, exists = cell.Pending[id]; exists {
-		delete(cell.Pending, id)
-		log.Printf("<resource_manager> pending instance '%s' deallocated in cell '%s'", id, cellName)
-	} else {
-		delete(cell.Instances, id)
-		log.Printf("<resource_manager> instance '%s' deallocated in cell '%s'", id, cellName)
-	}
-	if ins.Running {
-		cell.RunningInstances--
-	} else {
-		cell.StoppedInstances--
+	if cell.Pool != poolName {
+		err := fmt.Errorf("cell '%s' not in pool '%s'", cellName, poolName)
+		resp <- ResourceResult{Error: err}
+		return err
 	}
+	var s = ComputeCellStatus{ComputeCellInfo: cell.ComputeCellInfo, InstanceStatistic: cell.InstanceStatistic, ResourceUsage: cell.ResourceUsage}
+	resp <- ResourceResult{ComputeCell: s}
+	return nil
+}
 
-	if pool, exists := manager.pools[ins.Pool]; exists {
-		if "" != pool.Network {
-			manager.deallocateNetworkAddress(pool, ins.InternalNetwork.AssignedAddress, ins.ExternalNetwork.AssignedAddress)
+func (manager *ResourceManager) handleUpdateCellInfo(cellName, cellAddress string, respChan chan error) (err error) {
+	cell, exists := manager.cells[cellName]
+	if !exists {
+		var cellStatus = ManagedComputeCell{}
+		cellStatus.LatestUpdate = time.Now()
+		cellStatus.Name = cellName
+		cellStatus.Address = cellAddress
+		cellStatus.Enabled = true
+		cellStatus.Alive = true
+		cellStatus.Instances = map[string]bool{}
+		cellStatus.Pending = map[string]bool{}
+		manager.unallocatedCells[cellName] = true
+		manager.cells[cellName] = cellStatus
+		log.Printf("<resource_manager> new unallocated cell '%s' (address %s) available", cellName, cellAddress)
+	} else {
+		cell.Alive = true
+		cell.LatestUpdate = time.Now()
+		if cell.Address != cellAddress {
+			log.Printf("<resource_manager> cell '%s' address changed to %s", cellName, cellAddress)
+			cell.Address = cellAddress
 		}
-		delete(pool.InstanceNames, ins.Name)
-		manager.pools[ins.Pool] = pool
-	}
-
-	//update instance statistic
-	manager.cells[cellName] = cell
-	delete(manager.instances, id)
-	if err != nil {
-		manager.pendingError[id] = err
+		manager.cells[cellName] = cell
 	}
 	respChan <- nil
 	return nil
+
 }
 
-func (manager *ResourceManager) handleGetInstanceStatus(id string, respChan chan ResourceResult) (err error) {
-	var exists bool
-	var status InstanceStatus
-	if err, exists = manager.pendingError[id]; exists {
-		//fetch pending error
+func (manager *ResourceManager) handleGetCellStatus(cellName string, respChan chan ResourceResult) error {
+	cell, exists := manager.cells[cellName]
+	if !exists {
+		err := fmt.Errorf("invalid cell '%s'", cellName)
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	var status = ComputeCellStatus{cell.ComputeCellInfo, cell.InstanceStatistic, cell.ResourceUsage}
+	respChan <- ResourceResult{Pool: cell.Pool, ComputeCell: status}
+	return nil
+}
+
+func (manager *ResourceManager) handleSetCellStopped(cellName string, respChan chan error) (err error) {
+	cell, exists := manager.cells[cellName]
+	if !exists {
+		err = fmt.Errorf("invalid cell '%s'", cellName)
+		respChan <- err
+		return err
+	}
+	cell.Alive = false
+	log.Printf("<resource_manager> remote cell '%s' stopped", cellName)
+	if "" != cell.Pool {
+		//update resource statistic
+		cell.LostInstances = 0
+		for instanceID, _ := range cell.Instances {
+			if ins, exists := manager.instances[instanceID]; exists {
+				if ins.Running {
+					cell.RunningInstances--
+				} else {
+					cell.StoppedInstances--
+				}
+				cell.LostInstances++
+				ins.Lost = true
+				manager.instances[instanceID] = ins
+			} else {
+				err = fmt.Errorf("invalid instance '%s' in cell '%s'", instanceID, cellName)
+				respChan <- err
+				return err
+			}
+		}
+	}
+	manager.cells[cellName] = cell
+	respChan <- nil
+	return nil
+}
+func (manager *ResourceManager) handleQueryGuestsByCondition(condition GuestQueryCondition, respChan chan ResourceResult) (err error) {
+
+	var idList []string
+	{
+		//build filter list
+		if condition.InCell {
+			if cell, exists := manager.cells[condition.Cell]; !exists {
+				err := fmt.Errorf("invalid cell '%s'", condition.Cell)
+				respChan <- ResourceResult{Error: err}
+				return err
+			} else {
+				for id, _ := range cell.Instances {
+					idList = append(idList, id)
+				}
+			}
+		} else if pool, exists := manager.pools[condition.Pool]; !exists {
+			err := fmt.Errorf("invalid pool '%s'", condition.Pool)
+			respChan <- ResourceResult{Error: err}
+			return err
+		} else {
+			for cellName, _ := range pool.Cells {
+				if cell, exists := manager.cells[cellName]; !exists {
+					err := fmt.Errorf("invalid cell '%s' in pool '%s'", condition.Cell, condition.Pool)
+					respChan <- ResourceResult{Error: err}
+					return err
+				} else {
+					for id, _ := range cell.Instances {
+						idList = append(idList, id)
+					}
+				}
+
+			}
+		}
+	}
+	var names []string
+	var nameToID = map[string]string{}
+	{
+		//filter by property
+		for _, id := range idList {
+			if instance, exists := manager.instances[id]; !exists {
+				err := fmt.Errorf("invalid instance '%s'", id)
+				respChan <- ResourceResult{Error: err}
+				return err
+			} else {
+				if !(condition.WithOwner && instance.User == condition.Owner) && !(condition.WithGroup && instance.Group == condition.Group) {
+					continue
+				}
+				if condition.WithStatus && (instance.Running != (condition.Status == InstanceStatusRunning)) {
+					continue
+				}
+				if condition.WithCreateFlag && (instance.Created != condition.Created) {
+					continue
+				}
+
+				if _, exists := nameToID[instance.Name]; exists {
+					err = fmt.Errorf("encounter duplicate instance name '%s'", instance.Name)
+					respChan <- ResourceResult{Error: err}
+					return
+				}
+
+				names = append(names, instance.Name)
+				nameToID[instance.Name] = id
+			}
+		}
+	}
+	sort.Stable(sort.StringSlice(names))
+	var result []InstanceStatus
+	for _, name := range names {
+		id, exists := nameToID[name]
+		if !exists {
+			err = fmt.Errorf("no instance mapped with name '%s'", name)
+			respChan <- ResourceResult{Error: err}
+			return err
+		}
+		ins, exists := manager.instances[id]
+		if !exists {
+			err = fmt.Errorf("invalid instance '%s' with name '%s'", id, name)
+			respChan <- ResourceResult{Error: err}
+			return err
+		}
+		result = append(result, ins)
+	}
+	respChan <- ResourceResult{InstanceList: result}
+	return nil
+}
+
+func (manager *ResourceManager) handleBatchUpdateInstanceStatus(poolName, cellName string, instances []InstanceStatus, respChan chan error) error {
+	pool, exists := manager.pools[poolName]
+	if !exists {
+		err := fmt.Errorf("invalid pool '%s'", poolName)
+		respChan <- err
+		return err
+	}
+	cell, exists := manager.cells[cellName]
+	if !exists {
+		err := fmt.Errorf("invalid cell '%s'", cellName)
+		respChan <- err
+		return err
+	}
+	if cell.Pool != poolName {
+		err := fmt.Errorf("cell '%s' not belong to '%s'", cellName, poolName)
+		respChan <- err
+		return err
+	}
+	if 0 != len(cell.Instances) {
+		for id, _ := range cell.Instances {
+			log.Printf("<resource_manager> clear expired instance status, id '%s'", id)
+			if ins, exists := manager.instances[id]; exists {
+				delete(pool.InstanceNames, ins.Name)
+			}
+			delete(manager.instances, id)
+		}
+	}
+	cell.Instances = map[string]bool{}
+	cell.Pending = map[string]bool{}
+	cell.InstanceStatistic.Reset()
+	for _, config := range instances {
+		config.InternalNetwork.MonitorAddress = cell.Address
+		config.Host = cell.Address
+		config.ServerGroup = manager.instanceGroups[config.ID]
+		config.Selector = manager.instanceSelectors[config.ID]
+		manager.instances[config.ID] = config
+		cell.Instances[config.ID] = true
+		//todo: migrating
+		if config.Running {
+			cell.RunningInstances++
+		} else {
+			cell.StoppedInstances++
+		}
+		pool.InstanceNames[config.Name] = config.ID
+	}
+
+	cell.Synchronized = true
+	manager.cells[cellName] = cell
+	manager.pools[poolName] = pool
+	log.Printf("<resource_manager> %d instance updated in cell '%s' ", len(cell.Instances), cellName)
+	respChan <- nil
+	if manager.reconcileMigrations(cellName) {
+		return manager.saveConfig()
+	}
+	return nil
+}
+
+func (manager *ResourceManager) handleAllocateInstance(poolName string, config InstanceStatus, respChan chan ResourceResult) (err error) {
+	pool, exists := manager.pools[poolName]
+	if !exists {
+		err = fmt.Errorf("invalid compute pool '%s'", poolName)
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	if _, exists = pool.InstanceNames[config.Name]; exists {
+		err = fmt.Errorf("instance '%s' already exists in pool '%s'", config.Name, poolName)
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	{
+		var required = resourceToQuota(config.InstanceResource, 1)
+		if manager.externalAddressRequired(poolName) {
+			required.ExternalAddresses = 1
+		}
+		if err = manager.verifyQuota(config.User, config.Group, required); err != nil {
+			respChan <- ResourceResult{Error: err}
+			return err
+		}
+	}
+	var constraint placementConstraint
+	if "" != config.ServerGroup {
+		if group, exists := manager.serverGroups[config.ServerGroup]; exists && !serverGroupAccepted(group.ServerGroupConfig, config.User, config.Group) {
+			err = fmt.Errorf("server group '%s' not available for instance of user '%s', group '%s'", group.Name, config.User, config.Group)
+			respChan <- ResourceResult{Error: err}
+			return err
+		}
+		if constraint, err = manager.buildPlacementConstraint(config.ServerGroup, nil, nil); err != nil {
+			respChan <- ResourceResult{Error: err}
+			return err
+		}
+	}
+	if err = config.Selector.Verify(); err != nil {
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	constraint.Labels = config.Selector
+	var newID = uuid.NewV4()
+	config.ID = newID.String()
+	cellName, err := manager.selectCell(poolName, config.InstanceResource, true, constraint)
+	if err != nil {
+		log.Printf("<resource_manager> select cell fail: %s", err.Error())
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	config.Cell = cellName
+	config.Pool = poolName
+	if err = manager.verifyFailoverCapacity(poolName, cellName, config); err != nil {
+		log.Printf("<resource_manager> verify failover capacity fail: %s", err.Error())
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	cell, exists := manager.cells[cellName]
+	if !exists {
+		err = fmt.Errorf("invalid cell '%s'", cellName)
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	if "" != pool.Network {
+		//select address
+		internal, external, err := manager.allocateNetworkAddress(pool, config.ID)
+		if err != nil {
+			respChan <- ResourceResult{Error: err}
+			return err
+		}
+		if "" != external {
+			config.ExternalNetwork.AssignedAddress = external
+			log.Printf("<resource_manager> address '%s/%s' assigned for instance '%s'", internal, external, config.Name)
+		} else {
+			log.Printf("<resource_manager> internal address '%s' assigned for instance '%s'", internal, config.Name)
+		}
+		config.InternalNetwork.AssignedAddress = internal
+	}
+	config.InternalNetwork.MonitorAddress = cell.Address
+	config.Host = cell.Address
+	cell.Pending[config.ID] = true
+	pool.InstanceNames[config.Name] = config.ID
+
+	manager.instances[config.ID] = config
+	manager.cells[cellName] = cell
+	manager.pools[poolName] = pool
+	respChan <- ResourceResult{Instance: config}
+	log.Printf("<resource_manager> allocate cell '%s' for instance '%s'(%s)", cellName, config.Name, config.ID)
+	if !config.Selector.IsEmpty() {
+		manager.instanceSelectors[config.ID] = config.Selector
+	}
+	if "" != config.ServerGroup {
+		if err = manager.joinServerGroup(config.ServerGroup, config.ID); err != nil {
+			return err
+		}
+	}
+	if "" != config.ServerGroup || !config.Selector.IsEmpty() {
+		return manager.saveConfig()
+	}
+	return nil
+}
+
+// running/create/progress/media only
+func (manager *ResourceManager) handleUpdateInstanceStatus(status InstanceStatus, respChan chan error) error {
+	ins, exists := manager.instances[status.ID]
+	if !exists {
+		err := fmt.Errorf("invalid instance '%s'", status.ID)
+		respChan <- err
+		return err
+	}
+	if ins.Running != status.Running {
+		cell, exists := manager.cells[ins.Cell]
+		if !exists {
+			err := fmt.Errorf("invalid cell '%s' for instance '%s'", ins.Cell, status.ID)
+			respChan <- err
+			return err
+		}
+		if ins.Running {
+			//running => stopped
+			cell.StoppedInstances++
+			cell.RunningInstances--
+		} else {
+			//stopped => running
+			cell.StoppedInstances--
+			cell.RunningInstances++
+		}
+		ins.Running = status.Running
+		manager.cells[ins.Cell] = cell
+	}
+	if !ins.Created {
+		ins.Progress = status.Progress
+		ins.Created = status.Created
+	}
+	if ins.Cores != status.Cores {
+		ins.Cores = status.Cores
+	}
+	if ins.Memory != status.Memory {
+		ins.Memory = status.Memory
+	}
+	if len(ins.Disks) == len(status.Disks) {
+		for index := 0; index < len(ins.Disks); index++ {
+			if ins.Disks[index] != status.Disks[index] {
+				ins.Disks[index] = status.Disks[index]
+			}
+		}
+	}
+	if ins.MediaAttached != status.MediaAttached {
+		ins.MediaAttached = status.MediaAttached
+		ins.MediaSource = status.MediaSource
+	}
+
+	manager.instances[status.ID] = ins
+	respChan <- nil
+	return nil
+}
+
+func (manager *ResourceManager) handleConfirmInstance(id string, monitorPort uint, monitorSecret, ethernetAddress string, respChan chan error) error {
+	status, exists := manager.instances[id]
+	if !exists {
+		err := fmt.Errorf("invalid instance '%s'", id)
+		respChan <- err
+		return err
+	}
+	cell, exists := manager.cells[status.Cell]
+	if !exists {
+		err := fmt.Errorf("invalid cell '%s'", status.Cell)
+		respChan <- err
+		return err
+	}
+	if _, exists := cell.Pending[id]; !exists {
+		err := fmt.Errorf("instance '%s' already confirmed", id)
+		respChan <- err
+		return err
+	}
+	delete(cell.Pending, id)
+	cell.Instances[id] = true
+	cell.StoppedInstances++
+
+	status.InternalNetwork.MonitorPort = monitorPort
+	status.MonitorSecret = monitorSecret
+	status.HardwareAddress = ethernetAddress
+	status.Created = true
+	status.Progress = 0
+	status.CreateTime = time.Now().Format(TimeFormatLayout)
+
+	//update
+	manager.instances[id] = status
+	manager.cells[status.Cell] = cell
+	log.Printf("<resource_manager> instance '%s' confirmed, monitor port %d", id, status.InternalNetwork.MonitorPort)
+	respChan <- nil
+	return nil
+}
+
+func (manager *ResourceManager) handleDeallocateInstance(id string, err error, respChan chan error) error {
+	ins, exists := manager.instances[id]
+	if !exists {
+		err := fmt.Errorf("invalid instance '%s'", id)
+		respChan <- err
+		return err
+	}
+	if ins.Cell == "" {
+		err := fmt.Errorf("instance '%s' not allocated", id)
+		respChan <- err
+		return err
+	}
+	var cellName = ins.Cell
+	cell, exists := manager.cells[cellName]
+	if !exists {
+		err := fmt.Errorf("invalid cell '%s'", cellName)
+		respChan <- err
+		return err
+	}
+	if _, exists = cell.Pending[id]; exists {
+		delete(cell.Pending, id)
+		log.Printf("<resource_manager> pending instance '%s' deallocated in cell '%s'", id, cellName)
+	} else {
+		delete(cell.Instances, id)
+		log.Printf("<resource_manager> instance '%s' deallocated in cell '%s'", id, cellName)
+	}
+	if ins.Running {
+		cell.RunningInstances--
+	} else {
+		cell.StoppedInstances--
+	}
+
+	if pool, exists := manager.pools[ins.Pool]; exists {
+		if "" != pool.Network {
+			manager.deallocateNetworkAddress(pool, ins.InternalNetwork.AssignedAddress, ins.ExternalNetwork.AssignedAddress)
+		}
+		delete(pool.InstanceNames, ins.Name)
+		manager.pools[ins.Pool] = pool
+	}
+
+	//update instance statistic
+	manager.cells[cellName] = cell
+	delete(manager.instances, id)
+	delete(manager.systemResets, id)
+	if err != nil {
+		manager.pendingError[id] = err
+	}
+	respChan <- nil
+	var changed = manager.leaveServerGroup(id)
+	if _, exists := manager.snapshotCounts[id]; exists {
+		delete(manager.snapshotCounts, id)
+		changed = true
+	}
+	if _, exists := manager.instanceSelectors[id]; exists {
+		delete(manager.instanceSelectors, id)
+		changed = true
+	}
+	if changed {
+		return manager.saveConfig()
+	}
+	return nil
+}
+
+func (manager *ResourceManager) handleGetInstanceStatus(id string, respChan chan ResourceResult) (err error) {
+	var exists bool
+	var status InstanceStatus
+	if err, exists = manager.pendingError[id]; exists {
+		//fetch pending error
 		delete(manager.pendingError, id)
 		respChan <- ResourceResult{Error: err}
 		log.Printf("<resource_manager> pending error of instance '%s' fetched", id)
@@ -2388,7 +3454,18 @@ func (manager *ResourceManager) handleGetInstanceStatus(id string, respChan chan
 	}
 }
 
-func (manager *ResourceManager) handleQueryInstanceStatusInPool(poolName string, respChan chan ResourceResult) (err error) {
+func (manager *ResourceManager) handleLookupInstance(id string, respChan chan ResourceResult) (err error) {
+	status, exists := manager.instances[id]
+	if !exists {
+		err = fmt.Errorf("invalid instance '%s'", id)
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	respChan <- ResourceResult{Instance: status}
+	return nil
+}
+
+func (manager *ResourceManager) handleQueryInstanceStatusInPool(poolName string, option ListOption, respChan chan ResourceResult) (err error) {
 
 	pool, exists := manager.pools[poolName]
 	if !exists {
@@ -2411,16 +3488,16 @@ func (manager *ResourceManager) handleQueryInstanceStatusInPool(poolName string,
 			}
 		}
 	}
-	result, err := manager.getSortedInstances(idList)
+	result, err := manager.getPagedInstances(idList, option)
 	if err != nil {
 		respChan <- ResourceResult{Error: err}
 		return err
 	}
-	respChan <- ResourceResult{InstanceList: result}
+	respChan <- result
 	return nil
 }
 
-func (manager *ResourceManager) handleQueryInstanceStatusInCell(poolName, cellName string, respChan chan ResourceResult) (err error) {
+func (manager *ResourceManager) handleQueryInstanceStatusInCell(poolName, cellName string, option ListOption, respChan chan ResourceResult) (err error) {
 	pool, exists := manager.pools[poolName]
 	if !exists {
 		err = fmt.Errorf("invalid pool '%s'", poolName)
@@ -2446,13 +3523,13 @@ func (manager *ResourceManager) handleQueryInstanceStatusInCell(poolName, cellNa
 		idList = append(idList, instanceID)
 	}
 
-	result, err := manager.getSortedInstances(idList)
+	result, err := manager.getPagedInstances(idList, option)
 	if err != nil {
 		respChan <- ResourceResult{Error: err}
 		return err
 	}
 
-	respChan <- ResourceResult{InstanceList: result}
+	respChan <- result
 	return nil
 }
 
@@ -2632,6 +3709,30 @@ func (manager *ResourceManager) handleUpdateGuestAutoStart(guestID string, enabl
 	return
 }
 
+// getPagedInstances : instances sorted by name unless specified in option
+func (manager *ResourceManager) getPagedInstances(idList []string, option ListOption) (result ResourceResult, err error) {
+	var instances []InstanceStatus
+	if instances, err = manager.getSortedInstances(idList); err != nil {
+		return
+	}
+	var keys = ListSortKeys{
+		"name":        func(i, j int) bool { return instances[i].Name < instances[j].Name },
+		"id":          func(i, j int) bool { return instances[i].ID < instances[j].ID },
+		"owner":       func(i, j int) bool { return instances[i].User < instances[j].User },
+		"group":       func(i, j int) bool { return instances[i].Group < instances[j].Group },
+		"cell":        func(i, j int) bool { return instances[i].Cell < instances[j].Cell },
+		"cores":       func(i, j int) bool { return instances[i].Cores < instances[j].Cores },
+		"memory":      func(i, j int) bool { return instances[i].Memory < instances[j].Memory },
+		"create_time": func(i, j int) bool { return instances[i].CreateTime < instances[j].CreateTime },
+	}
+	var begin, end int
+	if begin, end, err = arrangeList(instances, len(instances), option, "name", keys, &result); err != nil {
+		return
+	}
+	result.InstanceList = instances[begin:end]
+	return result, nil
+}
+
 func (manager *ResourceManager) getSortedInstances(idList []string) (result []InstanceStatus, err error) {
 	var names []string
 	var nameToID = map[string]string{}
@@ -2814,22 +3915,29 @@ func (manager *ResourceManager) handleGetImageServer(respChan chan ResourceResul
 	return nil
 }
 
-func (manager *ResourceManager) handleQueryMigration(respChan chan ResourceResult) (err error) {
-	var result []MigrationStatus
-	var releaseList []string
+func (manager *ResourceManager) handleQueryMigration(option ListOption, respChan chan ResourceResult) (err error) {
+	var migrations []MigrationStatus
 	for _, m := range manager.migrations {
-		result = append(result, m)
-		if (m.Finished) || (m.Error != nil) {
-			releaseList = append(releaseList, m.ID)
-		}
+		migrations = append(migrations, m)
+	}
+	//order by id first, so that migrations created in same time keep stable
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].ID < migrations[j].ID
+	})
+	var keys = ListSortKeys{
+		"id":          func(i, j int) bool { return migrations[i].ID < migrations[j].ID },
+		"create_time": func(i, j int) bool { return migrations[i].CreateTime.Before(migrations[j].CreateTime) },
+		"progress":    func(i, j int) bool { return migrations[i].Progress < migrations[j].Progress },
+		"finished":    func(i, j int) bool { return !migrations[i].Finished && migrations[j].Finished },
 	}
-	respChan <- ResourceResult{MigrationList: result}
-	if 0 != len(releaseList) {
-		for _, id := range releaseList {
-			delete(manager.migrations, id)
-		}
-		log.Printf("<resource_manager> %d migration(s) released", len(releaseList))
+	var result ResourceResult
+	var begin, end int
+	if begin, end, err = arrangeList(migrations, len(migrations), option, "create_time", keys, &result); err != nil {
+		respChan <- ResourceResult{Error: err}
+		return
 	}
+	result.MigrationList = migrations[begin:end]
+	respChan <- result
 	return nil
 }
 
@@ -2841,9 +3949,6 @@ func (manager *ResourceManager) handleGetMigration(id string, respChan chan Reso
 		return err
 	}
 	respChan <- ResourceResult{Migration: migration}
-	if (migration.Error != nil) || migration.Finished {
-		delete(manager.migrations, id)
-	}
 	return nil
 }
 
@@ -2887,6 +3992,34 @@ func (manager *ResourceManager) handleCreateMigration(params MigrationParameter,
 		respChan <- ResourceResult{Error: err}
 		return err
 	}
+	if err = params.Selector.Verify(); err != nil {
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	if "" == params.TargetCell {
+		//select target cell fulfill resources and labels of instances
+		var instances = params.Instances
+		if 0 == len(instances) {
+			for instanceID, _ := range manager.cells[params.SourceCell].Instances {
+				instances = append(instances, instanceID)
+			}
+		}
+		var required InstanceResource
+		for _, instanceID := range instances {
+			if ins, exists := manager.instances[instanceID]; exists {
+				required.Cores += ins.Cores
+				required.Memory += ins.Memory
+				required.Disks = append(required.Disks, ins.Disks...)
+			}
+		}
+		var constraint = placementConstraint{Excluded: map[string]bool{params.SourceCell: true}, Labels: params.Selector}
+		if params.TargetCell, err = manager.selectCell(params.SourcePool, required, true, constraint); err != nil {
+			err = fmt.Errorf("select target cell fail: %s", err.Error())
+			respChan <- ResourceResult{Error: err}
+			return err
+		}
+		log.Printf("<resource_manager> cell '%s' selected as migration target of %d instance(s)", params.TargetCell, len(instances))
+	}
 	if _, exists = pool.Cells[params.TargetCell]; !exists {
 		err = fmt.Errorf("target cell '%s' not in pool '%s'", params.TargetCell, params.TargetPool)
 		respChan <- ResourceResult{Error: err}
@@ -2908,6 +4041,11 @@ func (manager *ResourceManager) handleCreateMigration(params MigrationParameter,
 		respChan <- ResourceResult{Error: err}
 		return err
 	}
+	if !params.Selector.Matches(targetCell.Labels) {
+		err = fmt.Errorf("target cell '%s' doesn't have required labels", params.TargetCell)
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
 	sourceCell, exists := manager.cells[params.SourceCell]
 	if !exists {
 		err = fmt.Errorf("invalid source cell '%s'", params.SourceCell)
@@ -2926,8 +4064,10 @@ func (manager *ResourceManager) handleCreateMigration(params MigrationParameter,
 	M.SourceCell = params.SourceCell
 	M.TargetPool = params.TargetPool
 	M.TargetCell = params.TargetCell
+	M.Selector = params.Selector
 	M.Finished = false
 	M.Progress = 0
+	M.CreateTime = time.Now()
 	if _, exists = manager.migrations[M.ID]; exists {
 		err = fmt.Errorf("migration '%s' already exists", M.ID)
 		respChan <- ResourceResult{Error: err}
@@ -2970,6 +4110,45 @@ func (manager *ResourceManager) handleCreateMigration(params MigrationParameter,
 			respChan <- ResourceResult{Error: err}
 			return err
 		}
+		if !manager.instanceSelectors[instanceID].Matches(targetCell.Labels) {
+			err = fmt.Errorf("target cell '%s' doesn't have labels required by instance '%s'('%s')", targetCell.Name, instanceID, ins.Name)
+			respChan <- ResourceResult{Error: err}
+			return err
+		}
+	}
+	//verify server group policy
+	{
+		var migrating = map[string]bool{}
+		for _, instanceID := range M.Instances {
+			migrating[instanceID] = true
+		}
+		var antiAffinityMembers = map[string]string{} //group id => instance id
+		for _, instanceID := range M.Instances {
+			groupID, exists := manager.instanceGroups[instanceID]
+			if !exists {
+				continue
+			}
+			var constraint placementConstraint
+			if constraint, err = manager.buildPlacementConstraint(groupID, migrating, nil); err != nil {
+				respChan <- ResourceResult{Error: err}
+				return err
+			}
+			if !constraint.allows(targetCell.Name) {
+				err = fmt.Errorf("migrate instance '%s' to cell '%s' violates %s policy of server group '%s'",
+					instanceID, targetCell.Name, constraint.Policy, constraint.Group)
+				respChan <- ResourceResult{Error: err}
+				return err
+			}
+			if ServerGroupPolicyAntiAffinity == constraint.Policy {
+				if previous, exists := antiAffinityMembers[groupID]; exists {
+					err = fmt.Errorf("instance '%s' and '%s' can not migrate to the same cell due to %s policy of server group '%s'",
+						previous, instanceID, constraint.Policy, constraint.Group)
+					respChan <- ResourceResult{Error: err}
+					return err
+				}
+				antiAffinityMembers[groupID] = instanceID
+			}
+		}
 	}
 	//batch update
 	for _, instanceID := range M.Instances {
@@ -2982,7 +4161,7 @@ func (manager *ResourceManager) handleCreateMigration(params MigrationParameter,
 		len(M.Instances), M.SourcePool, M.SourceCell, M.TargetPool, M.TargetCell, M.ID)
 	manager.migrations[M.ID] = M
 	respChan <- ResourceResult{Migration: M}
-	return nil
+	return manager.saveConfig()
 }
 
 func (manager *ResourceManager) handleFinishMigration(migrationID string, instances []string, monitorPorts []uint64, respChan chan error) (err error) {
@@ -2998,10 +4177,12 @@ func (manager *ResourceManager) handleFinishMigration(migrationID string, instan
 		return err
 	}
 	migration.Finished = true
+	migration.Progress = 100
+	migration.FinishTime = time.Now()
 	manager.migrations[migrationID] = migration
 	log.Printf("<resource_manager> migration '%s' finished", migrationID)
 	respChan <- nil
-	return nil
+	return manager.saveConfig()
 }
 
 func (manager *ResourceManager) handleCancelMigration(migrationID string, reason error, respChan chan error) (err error) {
@@ -3030,9 +4211,10 @@ func (manager *ResourceManager) handleCancelMigration(migrationID string, reason
 		migration.Finished = true
 		log.Printf("<resource_manager> migration '%s' canceled without reason", migrationID)
 	}
+	migration.FinishTime = time.Now()
 	manager.migrations[migrationID] = migration
 	respChan <- nil
-	return nil
+	return manager.saveConfig()
 }
 
 func (manager *ResourceManager) handleBuildFailoverPlan(cellName string, respChan chan ResourceResult) (err error) {
@@ -3080,6 +4262,7 @@ func (manager *ResourceManager) handleBuildFailoverPlan(cellName string, respCha
 		manager.cells[cellName] = cell
 		//build plan and migrate
 		var plan = map[string][]string{}
+		var relocated = map[string]string{} //instance id => target cell
 		for instanceID, _ := range cell.Instances {
 			ins, exists := manager.instances[instanceID]
 			if !exists {
@@ -3087,11 +4270,26 @@ func (manager *ResourceManager) handleBuildFailoverPlan(cellName string, respCha
 				respChan <- ResourceResult{Error: err}
 				return err
 			}
-			targetName, err := manager.selectCell(pool.Name, ins.InstanceResource, false)
+			var targetName string
+			constraint, err := manager.instanceConstraint(instanceID, cell.Instances, relocated)
 			if err != nil {
 				respChan <- ResourceResult{Error: err}
 				return err
 			}
+			if !constraint.isEmpty() {
+				if targetName, err = manager.selectCell(pool.Name, ins.InstanceResource, false, constraint); err != nil {
+					//recover instance first, server group policy and labels are best effort when failover
+					log.Printf("<resource_manager> warning: ignore placement constraint when failover instance '%s': %s",
+						instanceID, err.Error())
+				}
+			}
+			if "" == targetName {
+				if targetName, err = manager.selectCell(pool.Name, ins.InstanceResource, false, placementConstraint{}); err != nil {
+					respChan <- ResourceResult{Error: err}
+					return err
+				}
+			}
+			relocated[instanceID] = targetName
 			targetCell, exists := manager.cells[targetName]
 			if !exists {
 				err = fmt.Errorf("invalid target cell '%s'", targetName)
@@ -3324,7 +4522,7 @@ func (manager *ResourceManager) handleDeleteAddressPool(poolName string, respCha
 	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleQueryAddressRange(poolName, rangeType string, respChan chan ResourceResult) (err error) {
+func (manager *ResourceManager) handleQueryAddressRange(poolName, rangeType string, option ListOption, respChan chan ResourceResult) (err error) {
 	pool, exists := manager.addressPools[poolName]
 	if !exists {
 		err = fmt.Errorf("address pool '%s' not exists", poolName)
@@ -3337,8 +4535,26 @@ func (manager *ResourceManager) handleQueryAddressRange(poolName, rangeType stri
 		return err
 	}
 	var result ResourceResult
-	result.AddressRangeList = make([]AddressRangeStatus, 0)
+	var ranges = make([]ManagedIPV4AddressRange, 0)
 	for _, addressRange := range pool.ranges {
+		ranges = append(ranges, addressRange)
+	}
+	var keys = ListSortKeys{
+		"start": func(i, j int) bool {
+			return bytes.Compare(ranges[i].startAddress.To16(), ranges[j].startAddress.To16()) < 0
+		},
+		"end": func(i, j int) bool {
+			return bytes.Compare(ranges[i].endAddress.To16(), ranges[j].endAddress.To16()) < 0
+		},
+		"capacity": func(i, j int) bool { return ranges[i].capacity < ranges[j].capacity },
+	}
+	var begin, end int
+	if begin, end, err = arrangeList(ranges, len(ranges), option, "start", keys, &result); err != nil {
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	result.AddressRangeList = make([]AddressRangeStatus, 0)
+	for _, addressRange := range ranges[begin:end] {
 		var status AddressRangeStatus
 		status.Start = addressRange.startAddress.String()
 		status.End = addressRange.endAddress.String()
@@ -3513,6 +4729,7 @@ func (manager *ResourceManager) handleBeginResetSystem(instanceID string, respCh
 	ins.Created = false
 	ins.Progress = 0
 	manager.instances[instanceID] = ins
+	manager.systemResets[instanceID] = SystemResetStatus{}
 	log.Printf("<resource_manager> begin reset system of guest '%s'", ins.Name)
 	respChan <- nil
 	return nil
@@ -3533,8 +4750,10 @@ func (manager *ResourceManager) handleFinishResetSystem(instanceID string, reset
 	if resetError != nil {
 		err = resetError
 		manager.pendingError[instanceID] = resetError
+		manager.systemResets[instanceID] = SystemResetStatus{Finished: true, Progress: ins.Progress, Error: resetError.Error()}
 		log.Printf("<resource_manager> reset system fail: %s", err.Error())
 	} else {
+		manager.systemResets[instanceID] = SystemResetStatus{Finished: true}
 		ins.Created = true
 		ins.Progress = 0
 		manager.instances[instanceID] = ins
@@ -3544,6 +4763,23 @@ func (manager *ResourceManager) handleFinishResetSystem(instanceID string, reset
 	return nil
 }
 
+// handleGetResetSystemStatus : progress of unfinished reset reported by guest status
+func (manager *ResourceManager) handleGetResetSystemStatus(instanceID string, respChan chan ResourceResult) (err error) {
+	status, exists := manager.systemResets[instanceID]
+	if !exists {
+		err = fmt.Errorf("no reset of guest '%s' recorded", instanceID)
+		respChan <- ResourceResult{Error: err}
+		return
+	}
+	if !status.Finished {
+		if ins, exists := manager.instances[instanceID]; exists {
+			status.Progress = ins.Progress
+		}
+	}
+	respChan <- ResourceResult{SystemReset: status}
+	return nil
+}
+
 // batch
 func (manager *ResourceManager) handleStartBatchCreateGuest(request BatchCreateRequest, respChan chan ResourceResult) (err error) {
 	if len(request.Prefix) == 0 {
@@ -3567,6 +4803,14 @@ func (manager *ResourceManager) handleStartBatchCreateGuest(request BatchCreateR
 		respChan <- ResourceResult{Error: err}
 		return err
 	}
+	var required = resourceToQuota(request.Resource, uint(request.Count))
+	if manager.externalAddressRequired(request.Pool) {
+		required.ExternalAddresses = uint(request.Count)
+	}
+	if err = manager.verifyQuota(request.User, request.Group, required); err != nil {
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
 	var newID = uuid.NewV4()
 	var task BatchCreateGuestTask
 	task.Finished = false
@@ -3588,88 +4832,187 @@ func (manager *ResourceManager) handleStartBatchCreateGuest(request BatchCreateR
 		return err
 	}
 	var taskID = newID.String()
-	manager.batchCreateTasks[taskID] = task
-	respChan <- ResourceResult{Batch: taskID, BatchCreate: task.Guests}
-	log.Printf("<resource_manager> new create guest batch allocated, task id '%s'", taskID)
-	return nil
+	manager.batchCreateTasks[taskID] = task
+	respChan <- ResourceResult{Batch: taskID, BatchCreate: task.Guests}
+	log.Printf("<resource_manager> new create guest batch allocated, task id '%s'", taskID)
+	return manager.saveConfig()
+}
+
+func (manager *ResourceManager) handleSetBatchCreateGuestStart(batchID, guestName, guestID string, respChan chan error) (err error) {
+	task, exists := manager.batchCreateTasks[batchID]
+	if !exists {
+		err = fmt.Errorf("invalid batch task '%s", batchID)
+		respChan <- err
+		return err
+	}
+	guestIndex, exists := task.GuestName[guestName]
+	if !exists {
+		err = fmt.Errorf("no guest named '%s' in batch '%s'", guestName, batchID)
+		respChan <- err
+		return err
+	}
+	if guestIndex > len(task.Guests) {
+		err = fmt.Errorf("invalid index %d with name '%s'", guestIndex, guestName)
+		respChan <- err
+		return err
+	}
+	var guestStatus = task.Guests[guestIndex]
+	guestStatus.ID = guestID
+	task.Guests[guestIndex] = guestStatus
+	task.LatestUpdate = time.Now()
+	manager.batchCreateTasks[batchID] = task
+	log.Printf("<resource_manager> guest '%s' created with id '%s' in batch '%s'", guestName, guestID, batchID)
+	respChan <- nil
+	return manager.saveConfig()
+}
+
+func (manager *ResourceManager) handleSetBatchCreateGuestFail(batchID, guestName string, createError error, respChan chan error) (err error) {
+	task, exists := manager.batchCreateTasks[batchID]
+	if !exists {
+		err = fmt.Errorf("invalid batch task '%s", batchID)
+		respChan <- err
+		return err
+	}
+	guestIndex, exists := task.GuestName[guestName]
+	if !exists {
+		err = fmt.Errorf("no guest named '%s' in batch '%s'", guestName, batchID)
+		respChan <- err
+		return err
+	}
+	if guestIndex > len(task.Guests) {
+		err = fmt.Errorf("invalid index %d with name '%s'", guestIndex, guestName)
+		respChan <- err
+		return err
+	}
+	var guestStatus = task.Guests[guestIndex]
+	guestStatus.Error = createError.Error()
+	guestStatus.Status = BatchTaskStatusFail
+	task.Guests[guestIndex] = guestStatus
+	task.LatestUpdate = time.Now()
+	manager.batchCreateTasks[batchID] = task
+	log.Printf("<resource_manager> batch create guest '%s' fail: %s", guestName, createError.Error())
+	respChan <- nil
+	return manager.saveConfig()
+}
+
+func (manager *ResourceManager) handleGetBatchCreateGuestStatus(batchID string, respChan chan ResourceResult) (err error) {
+	task, exists := manager.batchCreateTasks[batchID]
+	if !exists {
+		err = fmt.Errorf("invalid batch task '%s", batchID)
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	respChan <- ResourceResult{BatchCreate: task.Guests}
+	return nil
+}
+
+func (manager *ResourceManager) handleStartBatchDeleteGuest(id []string, respChan chan ResourceResult) (err error) {
+	if 0 == len(id) {
+		err = errors.New("guest id required")
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	var newID = uuid.NewV4()
+	var task BatchDeleteGuestTask
+	task.Finished = false
+	task.StartTime = time.Now()
+	task.LatestUpdate = time.Now()
+	task.GuestID = map[string]int{}
+
+	for taskIndex, guestID := range id {
+		guest, exists := manager.instances[guestID]
+		if !exists {
+			err = fmt.Errorf("invalid guest '%s'", guestID)
+			respChan <- ResourceResult{Error: err}
+			return err
+		}
+		var status = DeleteGuestStatus{guest.Name, guestID, BatchTaskStatusProcess, ""}
+		task.GuestID[guestID] = taskIndex
+		task.Guests = append(task.Guests, status)
+	}
+	var taskID = newID.String()
+	manager.batchDeleteTasks[taskID] = task
+	respChan <- ResourceResult{Batch: taskID, BatchDelete: task.Guests}
+	log.Printf("<resource_manager> new delete guest batch allocated, task id '%s'", taskID)
+	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleSetBatchCreateGuestStart(batchID, guestName, guestID string, respChan chan error) (err error) {
-	task, exists := manager.batchCreateTasks[batchID]
+func (manager *ResourceManager) handleSetBatchDeleteGuestSuccess(batchID, guestID string, respChan chan error) (err error) {
+	task, exists := manager.batchDeleteTasks[batchID]
 	if !exists {
 		err = fmt.Errorf("invalid batch task '%s", batchID)
 		respChan <- err
 		return err
 	}
-	guestIndex, exists := task.GuestName[guestName]
+	guestIndex, exists := task.GuestID[guestID]
 	if !exists {
-		err = fmt.Errorf("no guest named '%s' in batch '%s'", guestName, batchID)
+		err = fmt.Errorf("no guest with id '%s' in batch '%s'", guestID, batchID)
 		respChan <- err
 		return err
 	}
 	if guestIndex > len(task.Guests) {
-		err = fmt.Errorf("invalid index %d with name '%s'", guestIndex, guestName)
+		err = fmt.Errorf("invalid index %d with id '%s'", guestIndex, guestID)
 		respChan <- err
 		return err
 	}
 	var guestStatus = task.Guests[guestIndex]
-	guestStatus.ID = guestID
+	guestStatus.Status = BatchTaskStatusSuccess
 	task.Guests[guestIndex] = guestStatus
 	task.LatestUpdate = time.Now()
-	manager.batchCreateTasks[batchID] = task
-	log.Printf("<resource_manager> guest '%s' created with id '%s' in batch '%s'", guestName, guestID, batchID)
+	manager.batchDeleteTasks[batchID] = task
+	log.Printf("<resource_manager> batch delete guest '%s' success", guestID)
 	respChan <- nil
-	return nil
+	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleSetBatchCreateGuestFail(batchID, guestName string, createError error, respChan chan error) (err error) {
-	task, exists := manager.batchCreateTasks[batchID]
+func (manager *ResourceManager) handleSetBatchDeleteGuestFail(batchID, guestID string, deleteError error, respChan chan error) (err error) {
+	task, exists := manager.batchDeleteTasks[batchID]
 	if !exists {
 		err = fmt.Errorf("invalid batch task '%s", batchID)
 		respChan <- err
 		return err
 	}
-	guestIndex, exists := task.GuestName[guestName]
+	guestIndex, exists := task.GuestID[guestID]
 	if !exists {
-		err = fmt.Errorf("no guest named '%s' in batch '%s'", guestName, batchID)
+		err = fmt.Errorf("no guest with id '%s' in batch '%s'", guestID, batchID)
 		respChan <- err
 		return err
 	}
 	if guestIndex > len(task.Guests) {
-		err = fmt.Errorf("invalid index %d with name '%s'", guestIndex, guestName)
+		err = fmt.Errorf("invalid index %d with id '%s'", guestIndex, guestID)
 		respChan <- err
 		return err
 	}
 	var guestStatus = task.Guests[guestIndex]
-	guestStatus.Error = createError.Error()
+	guestStatus.Error = deleteError.Error()
 	guestStatus.Status = BatchTaskStatusFail
 	task.Guests[guestIndex] = guestStatus
 	task.LatestUpdate = time.Now()
-	manager.batchCreateTasks[batchID] = task
-	log.Printf("<resource_manager> batch create guest '%s' fail: %s", guestName, createError.Error())
+	manager.batchDeleteTasks[batchID] = task
+	log.Printf("<resource_manager> batch delete guest '%s' fail: %s", guestID, deleteError.Error())
 	respChan <- nil
-	return nil
+	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleGetBatchCreateGuestStatus(batchID string, respChan chan ResourceResult) (err error) {
-	task, exists := manager.batchCreateTasks[batchID]
+func (manager *ResourceManager) handleGetBatchDeleteGuestStatus(batchID string, respChan chan ResourceResult) (err error) {
+	task, exists := manager.batchDeleteTasks[batchID]
 	if !exists {
 		err = fmt.Errorf("invalid batch task '%s", batchID)
 		respChan <- ResourceResult{Error: err}
 		return err
 	}
-	respChan <- ResourceResult{BatchCreate: task.Guests}
+	respChan <- ResourceResult{BatchDelete: task.Guests}
 	return nil
 }
 
-func (manager *ResourceManager) handleStartBatchDeleteGuest(id []string, respChan chan ResourceResult) (err error) {
+func (manager *ResourceManager) handleStartBatchStopGuest(id []string, respChan chan ResourceResult) (err error) {
 	if 0 == len(id) {
 		err = errors.New("guest id required")
 		respChan <- ResourceResult{Error: err}
 		return err
 	}
 	var newID = uuid.NewV4()
-	var task BatchDeleteGuestTask
+	var task BatchStopGuestTask
 	task.Finished = false
 	task.StartTime = time.Now()
 	task.LatestUpdate = time.Now()
@@ -3682,19 +5025,19 @@ func (manager *ResourceManager) handleStartBatchDeleteGuest(id []string, respCha
 			respChan <- ResourceResult{Error: err}
 			return err
 		}
-		var status = DeleteGuestStatus{guest.Name, guestID, BatchTaskStatusProcess, ""}
+		var status = StopGuestStatus{guest.Name, guestID, BatchTaskStatusProcess, ""}
 		task.GuestID[guestID] = taskIndex
 		task.Guests = append(task.Guests, status)
 	}
 	var taskID = newID.String()
-	manager.batchDeleteTasks[taskID] = task
-	respChan <- ResourceResult{Batch: taskID, BatchDelete: task.Guests}
-	log.Printf("<resource_manager> new delete guest batch allocated, task id '%s'", taskID)
-	return nil
+	manager.batchStopTasks[taskID] = task
+	respChan <- ResourceResult{Batch: taskID, BatchStop: task.Guests}
+	log.Printf("<resource_manager> new stop guest batch allocated, task id '%s'", taskID)
+	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleSetBatchDeleteGuestSuccess(batchID, guestID string, respChan chan error) (err error) {
-	task, exists := manager.batchDeleteTasks[batchID]
+func (manager *ResourceManager) handleSetBatchStopGuestSuccess(batchID, guestID string, respChan chan error) (err error) {
+	task, exists := manager.batchStopTasks[batchID]
 	if !exists {
 		err = fmt.Errorf("invalid batch task '%s", batchID)
 		respChan <- err
@@ -3715,281 +5058,464 @@ func (manager *ResourceManager) handleSetBatchDeleteGuestSuccess(batchID, guestI
 	guestStatus.Status = BatchTaskStatusSuccess
 	task.Guests[guestIndex] = guestStatus
 	task.LatestUpdate = time.Now()
-	manager.batchDeleteTasks[batchID] = task
-	log.Printf("<resource_manager> batch delete guest '%s' success", guestID)
+	manager.batchStopTasks[batchID] = task
+	log.Printf("<resource_manager> batch stop guest '%s' success", guestID)
 	respChan <- nil
-	return nil
+	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleSetBatchDeleteGuestFail(batchID, guestID string, deleteError error, respChan chan error) (err error) {
-	task, exists := manager.batchDeleteTasks[batchID]
+func (manager *ResourceManager) handleSetBatchStopGuestFail(batchID, guestID string, deleteError error, respChan chan error) (err error) {
+	task, exists := manager.batchStopTasks[batchID]
 	if !exists {
 		err = fmt.Errorf("invalid batch task '%s", batchID)
 		respChan <- err
 		return err
 	}
-	guestIndex, exists := task.GuestID[guestID]
-	if !exists {
-		err = fmt.Errorf("no guest with id '%s' in batch '%s'", guestID, batchID)
-		respChan <- err
-		return err
+	guestIndex, exists := task.GuestID[guestID]
+	if !exists {
+		err = fmt.Errorf("no guest with id '%s' in batch '%s'", guestID, batchID)
+		respChan <- err
+		return err
+	}
+	if guestIndex > len(task.Guests) {
+		err = fmt.Errorf("invalid index %d with id '%s'", guestIndex, guestID)
+		respChan <- err
+		return err
+	}
+	var guestStatus = task.Guests[guestIndex]
+	guestStatus.Error = deleteError.Error()
+	guestStatus.Status = BatchTaskStatusFail
+	task.Guests[guestIndex] = guestStatus
+	task.LatestUpdate = time.Now()
+	manager.batchStopTasks[batchID] = task
+	log.Printf("<resource_manager> batch stop guest '%s' fail: %s", guestID, deleteError.Error())
+	respChan <- nil
+	return manager.saveConfig()
+}
+
+func (manager *ResourceManager) handleGetBatchStopGuestStatus(batchID string, respChan chan ResourceResult) (err error) {
+	task, exists := manager.batchStopTasks[batchID]
+	if !exists {
+		err = fmt.Errorf("invalid batch task '%s", batchID)
+		respChan <- ResourceResult{Error: err}
+		return err
+	}
+	respChan <- ResourceResult{BatchStop: task.Guests}
+	return nil
+}
+
+func (manager *ResourceManager) handleUpdateInstanceMonitorSecret(instanceID, secret string, respChan chan error) (err error) {
+	defer func() {
+		respChan <- err
+	}()
+	var instance InstanceStatus
+	var exists bool
+	if instance, exists = manager.instances[instanceID]; !exists {
+		err = fmt.Errorf("invalid instance '%s'", instanceID)
+		return
+	}
+	instance.MonitorSecret = secret
+	manager.instances[instanceID] = instance
+	log.Printf("<resource_manager> monitor secret of instance '%s' updated", instance.Name)
+	return
+}
+
+func (manager *ResourceManager) handleQuerySystemTemplates(respChan chan ResourceResult) (err error) {
+	defer func() {
+		if err != nil {
+			respChan <- ResourceResult{Error: err}
+		}
+	}()
+	var result = make([]SystemTemplate, 0)
+	for _, id := range manager.allTemplateID {
+		template, exists := manager.templates[id]
+		if !exists {
+			err = fmt.Errorf("invalid template '%s'", id)
+			return
+		}
+		result = append(result, template)
+	}
+	respChan <- ResourceResult{TemplateList: result}
+	return nil
+}
+
+func (manager *ResourceManager) handleGetSystemTemplate(id string, respChan chan ResourceResult) (err error) {
+	defer func() {
+		if err != nil {
+			respChan <- ResourceResult{Error: err}
+		}
+	}()
+	template, exists := manager.templates[id]
+	if !exists {
+		err = fmt.Errorf("invalid template '%s'", id)
+		return
+	}
+	respChan <- ResourceResult{Template: template}
+	return nil
+}
+
+func (manager *ResourceManager) handleCreateSystemTemplate(config SystemTemplateConfig, respChan chan ResourceResult) (err error) {
+	defer func() {
+		if err != nil {
+			respChan <- ResourceResult{Error: err}
+		}
+	}()
+	for _, t := range manager.templates {
+		if t.Name == config.Name {
+			err = fmt.Errorf("system template '%s' already exists", t.Name)
+			return
+		}
+	}
+	if _, err = config.ToOptions(); err != nil {
+		err = fmt.Errorf("invalid template config: %s", err.Error())
+		return
+	}
+	var template = CreateSystemTemplate(config)
+	manager.templates[template.ID] = template
+	manager.allTemplateID = append(manager.allTemplateID, template.ID)
+	if err = manager.saveConfig(); err != nil {
+		err = fmt.Errorf("add new template fail: %s", err.Error())
+		return
+	}
+	respChan <- ResourceResult{Template: template}
+	log.Printf("<resource_manager> new template '%s'(%s) created", template.Name, template.ID)
+	return nil
+}
+
+func (manager *ResourceManager) handleModifySystemTemplate(id string, config SystemTemplateConfig, respChan chan error) (err error) {
+	defer func() {
+		respChan <- err
+	}()
+	if _, err = config.ToOptions(); err != nil {
+		err = fmt.Errorf("invalid template config: %s", err.Error())
+		return
+	}
+	template, exists := manager.templates[id]
+	if !exists {
+		err = fmt.Errorf("invalid template '%s'", id)
+		return
+	}
+	template.SystemTemplateConfig = config
+	template.ModifiedTime = time.Now().Format(TimeFormatLayout)
+	manager.templates[id] = template
+	log.Printf("<resource_manager> template '%s' modified", template.Name)
+	err = manager.saveConfig()
+	return
+}
+
+func (manager *ResourceManager) handleDeleteSystemTemplate(id string, respChan chan error) (err error) {
+	defer func() {
+		respChan <- err
+	}()
+	template, exists := manager.templates[id]
+	if !exists {
+		err = fmt.Errorf("invalid template '%s'", id)
+		return
+	}
+	delete(manager.templates, id)
+	var newArray []string
+	for _, templateID := range manager.allTemplateID {
+		if id != templateID {
+			newArray = append(newArray, templateID)
+		}
+	}
+	manager.allTemplateID = newArray
+	log.Printf("<resource_manager> template '%s' deleted", template.Name)
+	err = manager.saveConfig()
+	return
+}
+
+// Security Policy Group
+func (manager *ResourceManager) handleQuerySecurityPolicyGroups(condition SecurityPolicyGroupQueryCondition, option ListOption, respChan chan ResourceResult) (err error) {
+	var group managedSecurityPolicyGroup
+	var exists bool
+	var groupID string
+	var result ResourceResult
+	for _, groupID = range manager.sortedPolicyGroupID {
+		if group, exists = manager.policyGroups[groupID]; !exists {
+			err = fmt.Errorf("invalid security policy group '%s' in index", groupID)
+			respChan <- ResourceResult{Error: err}
+			return
+		}
+		if "" != condition.User && condition.User != group.User {
+			continue
+		}
+		if "" != condition.Group && condition.Group != group.Group {
+			continue
+		}
+		if condition.EnabledOnly && !group.Enabled {
+			continue
+		}
+		if condition.GlobalOnly && !group.Global {
+			continue
+		}
+		result.PolicyGroupList = append(result.PolicyGroupList, group.SecurityPolicyGroupStatus)
+	}
+	var groups = result.PolicyGroupList
+	//keep order of index by default
+	var keys = ListSortKeys{
+		"id":    func(i, j int) bool { return groups[i].ID < groups[j].ID },
+		"name":  func(i, j int) bool { return groups[i].Name < groups[j].Name },
+		"user":  func(i, j int) bool { return groups[i].User < groups[j].User },
+		"group": func(i, j int) bool { return groups[i].Group < groups[j].Group },
 	}
-	if guestIndex > len(task.Guests) {
-		err = fmt.Errorf("invalid index %d with id '%s'", guestIndex, guestID)
-		respChan <- err
-		return err
+	var begin, end int
+	if begin, end, err = arrangeList(groups, len(groups), option, "", keys, &result); err != nil {
+		respChan <- ResourceResult{Error: err}
+		return
 	}
-	var guestStatus = task.Guests[guestIndex]
-	guestStatus.Error = deleteError.Error()
-	guestStatus.Status = BatchTaskStatusFail
-	task.Guests[guestIndex] = guestStatus
-	task.LatestUpdate = time.Now()
-	manager.batchDeleteTasks[batchID] = task
-	log.Printf("<resource_manager> batch delete guest '%s' fail: %s", guestID, deleteError.Error())
-	respChan <- nil
+	result.PolicyGroupList = groups[begin:end]
+	log.Printf("<resource_manager> %d security policy group(s) queried", len(result.PolicyGroupList))
+	respChan <- result
 	return nil
 }
 
-func (manager *ResourceManager) handleGetBatchDeleteGuestStatus(batchID string, respChan chan ResourceResult) (err error) {
-	task, exists := manager.batchDeleteTasks[batchID]
-	if !exists {
-		err = fmt.Errorf("invalid batch task '%s", batchID)
+func (manager *ResourceManager) handleGetSecurityPolicyGroup(groupID string, respChan chan ResourceResult) (err error) {
+	var group managedSecurityPolicyGroup
+	var exists bool
+	if group, exists = manager.policyGroups[groupID]; !exists {
+		err = fmt.Errorf("invalid security policy group '%s'", groupID)
 		respChan <- ResourceResult{Error: err}
-		return err
+		return
 	}
-	respChan <- ResourceResult{BatchDelete: task.Guests}
+	var status = SecurityPolicyGroupStatus{
+		ID:                  group.ID,
+		SecurityPolicyGroup: group.SecurityPolicyGroup,
+	}
+	respChan <- ResourceResult{PolicyGroup: status}
 	return nil
 }
 
-func (manager *ResourceManager) handleStartBatchStopGuest(id []string, respChan chan ResourceResult) (err error) {
-	if 0 == len(id) {
-		err = errors.New("guest id required")
+func (manager *ResourceManager) handleCreateSecurityPolicyGroup(config SecurityPolicyGroup, respChan chan ResourceResult) (err error) {
+	var exists bool
+	if _, exists = manager.policyGroupNames[config.Name]; exists {
+		err = fmt.Errorf("security policy group '%s' already exists in system", config.Name)
 		respChan <- ResourceResult{Error: err}
-		return err
+		return
 	}
 	var newID = uuid.NewV4()
-	var task BatchStopGuestTask
-	task.Finished = false
-	task.StartTime = time.Now()
-	task.LatestUpdate = time.Now()
-	task.GuestID = map[string]int{}
-
-	for taskIndex, guestID := range id {
-		guest, exists := manager.instances[guestID]
-		if !exists {
-			err = fmt.Errorf("invalid guest '%s'", guestID)
-			respChan <- ResourceResult{Error: err}
-			return err
-		}
-		var status = StopGuestStatus{guest.Name, guestID, BatchTaskStatusProcess, ""}
-		task.GuestID[guestID] = taskIndex
-		task.Guests = append(task.Guests, status)
+	var groupID = newID.String()
+	if _, exists = manager.policyGroups[groupID]; exists {
+		err = fmt.Errorf("security policy group '%s' already exists", groupID)
+		respChan <- ResourceResult{Error: err}
+		return
 	}
-	var taskID = newID.String()
-	manager.batchStopTasks[taskID] = task
-	respChan <- ResourceResult{Batch: taskID, BatchStop: task.Guests}
-	log.Printf("<resource_manager> new stop guest batch allocated, task id '%s'", taskID)
-	return nil
+	var group managedSecurityPolicyGroup
+	group.ID = groupID
+	group.SecurityPolicyGroup = config
+	manager.policyGroups[groupID] = group
+	manager.policyGroupNames[group.Name] = true
+	manager.sortedPolicyGroupID = append(manager.sortedPolicyGroupID, groupID)
+	respChan <- ResourceResult{
+		PolicyGroup: SecurityPolicyGroupStatus{
+			ID:                  groupID,
+			SecurityPolicyGroup: config,
+		},
+	}
+	log.Printf("<resource_manager> new security policy group '%s'(%s) created", config.Name, groupID)
+	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleSetBatchStopGuestSuccess(batchID, guestID string, respChan chan error) (err error) {
-	task, exists := manager.batchStopTasks[batchID]
-	if !exists {
-		err = fmt.Errorf("invalid batch task '%s", batchID)
-		respChan <- err
-		return err
-	}
-	guestIndex, exists := task.GuestID[guestID]
-	if !exists {
-		err = fmt.Errorf("no guest with id '%s' in batch '%s'", guestID, batchID)
-		respChan <- err
-		return err
-	}
-	if guestIndex > len(task.Guests) {
-		err = fmt.Errorf("invalid index %d with id '%s'", guestIndex, guestID)
+func (manager *ResourceManager) handleModifySecurityPolicyGroup(groupID string, config SecurityPolicyGroup, respChan chan error) (err error) {
+	var group managedSecurityPolicyGroup
+	var exists bool
+	if group, exists = manager.policyGroups[groupID]; !exists {
+		err = fmt.Errorf("invalid security policy group '%s'", groupID)
 		respChan <- err
-		return err
+		return
 	}
-	var guestStatus = task.Guests[guestIndex]
-	guestStatus.Status = BatchTaskStatusSuccess
-	task.Guests[guestIndex] = guestStatus
-	task.LatestUpdate = time.Now()
-	manager.batchStopTasks[batchID] = task
-	log.Printf("<resource_manager> batch stop guest '%s' success", guestID)
-	respChan <- nil
-	return nil
+	group.SecurityPolicyGroup = config
+	manager.policyGroups[groupID] = group
+	respChan <- err
+	log.Printf("<resource_manager> security policy group '%s'(%s) modified", config.Name, groupID)
+	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleSetBatchStopGuestFail(batchID, guestID string, deleteError error, respChan chan error) (err error) {
-	task, exists := manager.batchStopTasks[batchID]
-	if !exists {
-		err = fmt.Errorf("invalid batch task '%s", batchID)
+func (manager *ResourceManager) handleDeleteSecurityPolicyGroup(groupID string, respChan chan error) (err error) {
+	var group managedSecurityPolicyGroup
+	var exists bool
+	if group, exists = manager.policyGroups[groupID]; !exists {
+		err = fmt.Errorf("invalid security policy group '%s'", groupID)
 		respChan <- err
-		return err
+		return
 	}
-	guestIndex, exists := task.GuestID[guestID]
-	if !exists {
-		err = fmt.Errorf("no guest with id '%s' in batch '%s'", guestID, batchID)
-		respChan <- err
-		return err
+	var index = -1
+	for offset, id := range manager.sortedPolicyGroupID {
+		if id == groupID {
+			index = offset
+			break
+		}
 	}
-	if guestIndex > len(task.Guests) {
-		err = fmt.Errorf("invalid index %d with id '%s'", guestIndex, guestID)
+	if -1 == index {
+		err = fmt.Errorf("can not found security policy group '%s' in index", groupID)
 		respChan <- err
-		return err
+		return
 	}
-	var guestStatus = task.Guests[guestIndex]
-	guestStatus.Error = deleteError.Error()
-	guestStatus.Status = BatchTaskStatusFail
-	task.Guests[guestIndex] = guestStatus
-	task.LatestUpdate = time.Now()
-	manager.batchStopTasks[batchID] = task
-	log.Printf("<resource_manager> batch stop guest '%s' fail: %s", guestID, deleteError.Error())
+	if index == len(manager.sortedPolicyGroupID)-1 {
+		//truncate tail
+		manager.sortedPolicyGroupID = manager.sortedPolicyGroupID[:index]
+	} else {
+		manager.sortedPolicyGroupID = append(manager.sortedPolicyGroupID[:index], manager.sortedPolicyGroupID[index+1:]...)
+	}
+	delete(manager.policyGroupNames, group.Name)
+	delete(manager.policyGroups, groupID)
 	respChan <- nil
-	return nil
+	log.Printf("<resource_manager> security policy group '%s'(%s) deleted", group.Name, groupID)
+	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleGetBatchStopGuestStatus(batchID string, respChan chan ResourceResult) (err error) {
-	task, exists := manager.batchStopTasks[batchID]
-	if !exists {
-		err = fmt.Errorf("invalid batch task '%s", batchID)
+func (manager *ResourceManager) handleGetSecurityPolicyRules(groupID string, respChan chan ResourceResult) (err error) {
+	var group managedSecurityPolicyGroup
+	var exists bool
+	if group, exists = manager.policyGroups[groupID]; !exists {
+		err = fmt.Errorf("invalid security policy group '%s'", groupID)
 		respChan <- ResourceResult{Error: err}
-		return err
+		return
 	}
-	respChan <- ResourceResult{BatchStop: task.Guests}
+	respChan <- ResourceResult{PolicyRuleList: group.Rules}
 	return nil
 }
 
-func (manager *ResourceManager) handleUpdateInstanceMonitorSecret(instanceID, secret string, respChan chan error) (err error) {
-	defer func() {
-		respChan <- err
-	}()
-	var instance InstanceStatus
+func (manager *ResourceManager) handleAddSecurityPolicyRule(groupID string, rule SecurityPolicyRule, respChan chan error) (err error) {
+	var group managedSecurityPolicyGroup
 	var exists bool
-	if instance, exists = manager.instances[instanceID]; !exists {
-		err = fmt.Errorf("invalid instance '%s'", instanceID)
+	if group, exists = manager.policyGroups[groupID]; !exists {
+		err = fmt.Errorf("invalid security policy group '%s'", groupID)
+		respChan <- err
 		return
 	}
-	instance.MonitorSecret = secret
-	manager.instances[instanceID] = instance
-	log.Printf("<resource_manager> monitor secret of instance '%s' updated", instance.Name)
-	return
-}
-
-func (manager *ResourceManager) handleQuerySystemTemplates(respChan chan ResourceResult) (err error) {
-	defer func() {
-		if err != nil {
-			respChan <- ResourceResult{Error: err}
-		}
-	}()
-	var result = make([]SystemTemplate, 0)
-	for _, id := range manager.allTemplateID {
-		template, exists := manager.templates[id]
-		if !exists {
-			err = fmt.Errorf("invalid template '%s'", id)
+	for _, current := range group.Rules {
+		if rule.TargetPort == current.TargetPort && rule.Protocol == current.Protocol && rule.SourceAddress == current.SourceAddress {
+			err = fmt.Errorf("rule %s:%s:%d already defined in policy group '%s'",
+				rule.Protocol, rule.SourceAddress, rule.TargetPort, group.Name)
+			respChan <- err
 			return
 		}
-		result = append(result, template)
 	}
-	respChan <- ResourceResult{TemplateList: result}
-	return nil
+	group.Rules = append(group.Rules, rule)
+	manager.policyGroups[groupID] = group
+	respChan <- nil
+	log.Printf("<resource_manager> new rule added to securiy policy group '%s'(%s)",
+		group.Name, groupID)
+	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleGetSystemTemplate(id string, respChan chan ResourceResult) (err error) {
-	defer func() {
-		if err != nil {
-			respChan <- ResourceResult{Error: err}
+func (manager *ResourceManager) handleModifySecurityPolicyRule(groupID string, index int, rule SecurityPolicyRule, respChan chan error) (err error) {
+	var group managedSecurityPolicyGroup
+	var exists bool
+	if group, exists = manager.policyGroups[groupID]; !exists {
+		err = fmt.Errorf("invalid security policy group '%s'", groupID)
+		respChan <- err
+		return
+	}
+	if index >= len(group.Rules) {
+		err = fmt.Errorf("invalid index %d on security policy group '%s'", index, groupID)
+		respChan <- err
+		return
+	}
+	for currentIndex, current := range group.Rules {
+		if rule.TargetPort == current.TargetPort && rule.Protocol == current.Protocol && rule.SourceAddress == current.SourceAddress {
+			if index == currentIndex {
+				if rule.Accept == current.Accept {
+					err = errors.New("no need to change")
+					respChan <- err
+					return
+				}
+			} else {
+				err = fmt.Errorf("rule %s:%s:%d already defined in policy group '%s'",
+					rule.Protocol, rule.SourceAddress, rule.TargetPort, group.Name)
+				respChan <- err
+				return
+			}
+
 		}
-	}()
-	template, exists := manager.templates[id]
-	if !exists {
-		err = fmt.Errorf("invalid template '%s'", id)
-		return
 	}
-	respChan <- ResourceResult{Template: template}
-	return nil
+	group.Rules[index] = rule
+	manager.policyGroups[groupID] = group
+	respChan <- nil
+	log.Printf("<resource_manager> %dth rule of securiy policy group '%s'(%s) modified",
+		index, group.Name, groupID)
+	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleCreateSystemTemplate(config SystemTemplateConfig, respChan chan ResourceResult) (err error) {
-	defer func() {
-		if err != nil {
-			respChan <- ResourceResult{Error: err}
-		}
-	}()
-	for _, t := range manager.templates {
-		if t.Name == config.Name {
-			err = fmt.Errorf("system template '%s' already exists", t.Name)
-			return
-		}
-	}
-	if _, err = config.ToOptions(); err != nil {
-		err = fmt.Errorf("invalid template config: %s", err.Error())
+func (manager *ResourceManager) handleRemoveSecurityPolicyRule(groupID string, index int, respChan chan error) (err error) {
+	var group managedSecurityPolicyGroup
+	var exists bool
+	if group, exists = manager.policyGroups[groupID]; !exists {
+		err = fmt.Errorf("invalid security policy group '%s'", groupID)
+		respChan <- err
 		return
 	}
-	var template = CreateSystemTemplate(config)
-	manager.templates[template.ID] = template
-	manager.allTemplateID = append(manager.allTemplateID, template.ID)
-	if err = manager.saveConfig(); err != nil {
-		err = fmt.Errorf("add new template fail: %s", err.Error())
+	if index >= len(group.Rules) {
+		err = fmt.Errorf("invalid index %d on security policy group '%s'", index, groupID)
+		respChan <- err
 		return
 	}
-	respChan <- ResourceResult{Template: template}
-	log.Printf("<resource_manager> new template '%s'(%s) created", template.Name, template.ID)
-	return nil
+	if index == len(group.Rules)-1 {
+		//last
+		group.Rules = group.Rules[:index]
+	} else {
+		group.Rules = append(group.Rules[:index], group.Rules[index+1:]...)
+	}
+	manager.policyGroups[groupID] = group
+	respChan <- nil
+	log.Printf("<resource_manager> %dth rule of securiy policy group '%s'(%s) removed",
+		index, group.Name, groupID)
+	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleModifySystemTemplate(id string, config SystemTemplateConfig, respChan chan error) (err error) {
-	defer func() {
+func (manager *ResourceManager) handleMoveSecurityPolicyRule(groupID string, index int, moveUp bool, respChan chan error) (err error) {
+	var group managedSecurityPolicyGroup
+	var exists bool
+	if group, exists = manager.policyGroups[groupID]; !exists {
+		err = fmt.Errorf("invalid security policy group '%s'", groupID)
 		respChan <- err
-	}()
-	if _, err = config.ToOptions(); err != nil {
-		err = fmt.Errorf("invalid template config: %s", err.Error())
-		return
-	}
-	template, exists := manager.templates[id]
-	if !exists {
-		err = fmt.Errorf("invalid template '%s'", id)
 		return
 	}
-	template.SystemTemplateConfig = config
-	template.ModifiedTime = time.Now().Format(TimeFormatLayout)
-	manager.templates[id] = template
-	log.Printf("<resource_manager> template '%s' modified", template.Name)
-	err = manager.saveConfig()
-	return
-}
-
-func (manager *ResourceManager) handleDeleteSystemTemplate(id string, respChan chan error) (err error) {
-	defer func() {
+	var ruleCount = len(group.Rules)
+	if index >= ruleCount {
+		err = fmt.Errorf("invalid index %d on security policy group '%s'", index, groupID)
 		respChan <- err
-	}()
-	template, exists := manager.templates[id]
-	if !exists {
-		err = fmt.Errorf("invalid template '%s'", id)
 		return
 	}
-	delete(manager.templates, id)
-	var newArray []string
-	for _, templateID := range manager.allTemplateID {
-		if id != templateID {
-			newArray = append(newArray, templateID)
+	var swapIndex int
+	if moveUp {
+		if 0 == index {
+			err = errors.New("already on top")
+			respChan <- err
+			return
+		}
+		swapIndex = index - 1
+	} else {
+		if ruleCount-1 == index {
+			err = errors.New("already on bottom")
+			respChan <- err
+			return
 		}
+		swapIndex = index + 1
 	}
-	manager.allTemplateID = newArray
-	log.Printf("<resource_manager> template '%s' deleted", template.Name)
-	err = manager.saveConfig()
-	return
+	var current = group.Rules[index]
+	group.Rules[index] = group.Rules[swapIndex]
+	group.Rules[swapIndex] = current
+	respChan <- nil
+	if moveUp {
+		log.Printf("<resource_manager> %dth rule of securiy policy group '%s'(%s) moved up",
+			index, group.Name, groupID)
+	} else {
+		log.Printf("<resource_manager> %dth rule of securiy policy group '%s'(%s) moved down",
+			index, group.Name, groupID)
+	}
+	return manager.saveConfig()
 }
 
-// Security Policy Group
-func (manager *ResourceManager) handleQuerySecurityPolicyGroups(condition SecurityPolicyGroupQueryCondition, respChan chan ResourceResult) (err error) {
-	var group managedSecurityPolicyGroup
+// Server Group
+func (manager *ResourceManager) handleQueryServerGroups(condition ServerGroupQueryCondition, respChan chan ResourceResult) (err error) {
+	var group ServerGroupStatus
 	var exists bool
-	var groupID string
 	var result ResourceResult
-	for _, groupID = range manager.sortedPolicyGroupID {
-		if group, exists = manager.policyGroups[groupID]; !exists {
-			err = fmt.Errorf("invalid security policy group '%s' in index", groupID)
+	for _, groupID := range manager.sortedServerGroupID {
+		if group, exists = manager.serverGroups[groupID]; !exists {
+			err = fmt.Errorf("invalid server group '%s' in index", groupID)
 			respChan <- ResourceResult{Error: err}
 			return
 		}
@@ -3999,255 +5525,424 @@ func (manager *ResourceManager) handleQuerySecurityPolicyGroups(condition Securi
 		if "" != condition.Group && condition.Group != group.Group {
 			continue
 		}
-		if condition.EnabledOnly && !group.Enabled {
-			continue
-		}
-		if condition.GlobalOnly && !group.Global {
-			continue
-		}
-		result.PolicyGroupList = append(result.PolicyGroupList, group.SecurityPolicyGroupStatus)
+		result.ServerGroupList = append(result.ServerGroupList, group)
 	}
-	log.Printf("<resource_manager> %d security policy group(s) queried", len(result.PolicyGroupList))
+	log.Printf("<resource_manager> %d server group(s) queried", len(result.ServerGroupList))
 	respChan <- result
 	return nil
 }
 
-func (manager *ResourceManager) handleGetSecurityPolicyGroup(groupID string, respChan chan ResourceResult) (err error) {
-	var group managedSecurityPolicyGroup
-	var exists bool
-	if group, exists = manager.policyGroups[groupID]; !exists {
-		err = fmt.Errorf("invalid security policy group '%s'", groupID)
+func (manager *ResourceManager) handleGetServerGroup(groupID string, respChan chan ResourceResult) (err error) {
+	group, exists := manager.serverGroups[groupID]
+	if !exists {
+		err = fmt.Errorf("invalid server group '%s'", groupID)
 		respChan <- ResourceResult{Error: err}
 		return
 	}
-	var status = SecurityPolicyGroupStatus{
-		ID:                  group.ID,
-		SecurityPolicyGroup: group.SecurityPolicyGroup,
-	}
-	respChan <- ResourceResult{PolicyGroup: status}
+	respChan <- ResourceResult{ServerGroup: group}
 	return nil
 }
 
-func (manager *ResourceManager) handleCreateSecurityPolicyGroup(config SecurityPolicyGroup, respChan chan ResourceResult) (err error) {
+func (manager *ResourceManager) handleCreateServerGroup(config ServerGroupConfig, respChan chan ResourceResult) (err error) {
+	if err = verifyServerGroupConfig(config); err != nil {
+		respChan <- ResourceResult{Error: err}
+		return
+	}
 	var exists bool
-	if _, exists = manager.policyGroupNames[config.Name]; exists {
-		err = fmt.Errorf("security policy group '%s' already exists in system", config.Name)
+	if _, exists = manager.serverGroupNames[config.Name]; exists {
+		err = fmt.Errorf("server group '%s' already exists in system", config.Name)
 		respChan <- ResourceResult{Error: err}
 		return
 	}
 	var newID = uuid.NewV4()
 	var groupID = newID.String()
-	if _, exists = manager.policyGroups[groupID]; exists {
-		err = fmt.Errorf("security policy group '%s' already exists", groupID)
+	if _, exists = manager.serverGroups[groupID]; exists {
+		err = fmt.Errorf("server group '%s' already exists", groupID)
 		respChan <- ResourceResult{Error: err}
 		return
 	}
-	var group managedSecurityPolicyGroup
-	group.ID = groupID
-	group.SecurityPolicyGroup = config
-	manager.policyGroups[groupID] = group
-	manager.policyGroupNames[group.Name] = true
-	manager.sortedPolicyGroupID = append(manager.sortedPolicyGroupID, groupID)
-	respChan <- ResourceResult{
-		PolicyGroup: SecurityPolicyGroupStatus{
-			ID:                  groupID,
-			SecurityPolicyGroup: config,
-		},
-	}
-	log.Printf("<resource_manager> new security policy group '%s'(%s) created", config.Name, groupID)
+	var group = ServerGroupStatus{ID: groupID, ServerGroupConfig: config}
+	manager.serverGroups[groupID] = group
+	manager.serverGroupNames[config.Name] = true
+	manager.sortedServerGroupID = append(manager.sortedServerGroupID, groupID)
+	respChan <- ResourceResult{ServerGroup: group}
+	log.Printf("<resource_manager> new %s server group '%s'(%s) created", config.Policy, config.Name, groupID)
 	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleModifySecurityPolicyGroup(groupID string, config SecurityPolicyGroup, respChan chan error) (err error) {
-	var group managedSecurityPolicyGroup
-	var exists bool
-	if group, exists = manager.policyGroups[groupID]; !exists {
-		err = fmt.Errorf("invalid security policy group '%s'", groupID)
+func (manager *ResourceManager) handleModifyServerGroup(groupID string, config ServerGroupConfig, respChan chan error) (err error) {
+	group, exists := manager.serverGroups[groupID]
+	if !exists {
+		err = fmt.Errorf("invalid server group '%s'", groupID)
 		respChan <- err
 		return
 	}
-	group.SecurityPolicyGroup = config
-	manager.policyGroups[groupID] = group
-	respChan <- err
-	log.Printf("<resource_manager> security policy group '%s'(%s) modified", config.Name, groupID)
+	if err = verifyServerGroupConfig(config); err != nil {
+		respChan <- err
+		return
+	}
+	if config.Name != group.Name {
+		if _, exists = manager.serverGroupNames[config.Name]; exists {
+			err = fmt.Errorf("server group '%s' already exists in system", config.Name)
+			respChan <- err
+			return
+		}
+	}
+	if config.Policy != group.Policy && 0 != len(group.Members) {
+		err = fmt.Errorf("can not change policy of server group '%s', %d member(s) remain", group.Name, len(group.Members))
+		respChan <- err
+		return
+	}
+	if (config.User != group.User || config.Group != group.Group) && 0 != len(group.Members) {
+		err = fmt.Errorf("can not change owner of server group '%s', %d member(s) remain", group.Name, len(group.Members))
+		respChan <- err
+		return
+	}
+	delete(manager.serverGroupNames, group.Name)
+	manager.serverGroupNames[config.Name] = true
+	group.ServerGroupConfig = config
+	manager.serverGroups[groupID] = group
+	respChan <- nil
+	log.Printf("<resource_manager> server group '%s'(%s) modified", config.Name, groupID)
 	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleDeleteSecurityPolicyGroup(groupID string, respChan chan error) (err error) {
-	var group managedSecurityPolicyGroup
-	var exists bool
-	if group, exists = manager.policyGroups[groupID]; !exists {
-		err = fmt.Errorf("invalid security policy group '%s'", groupID)
+func (manager *ResourceManager) handleDeleteServerGroup(groupID string, respChan chan error) (err error) {
+	group, exists := manager.serverGroups[groupID]
+	if !exists {
+		err = fmt.Errorf("invalid server group '%s'", groupID)
+		respChan <- err
+		return
+	}
+	if 0 != len(group.Members) {
+		err = fmt.Errorf("server group '%s' still has %d member(s)", group.Name, len(group.Members))
 		respChan <- err
 		return
 	}
 	var index = -1
-	for offset, id := range manager.sortedPolicyGroupID {
+	for offset, id := range manager.sortedServerGroupID {
 		if id == groupID {
 			index = offset
 			break
 		}
 	}
 	if -1 == index {
-		err = fmt.Errorf("can not found security policy group '%s' in index", groupID)
+		err = fmt.Errorf("can not found server group '%s' in index", groupID)
 		respChan <- err
 		return
 	}
-	if index == len(manager.sortedPolicyGroupID)-1 {
-		//truncate tail
-		manager.sortedPolicyGroupID = manager.sortedPolicyGroupID[:index]
+	manager.sortedServerGroupID = append(manager.sortedServerGroupID[:index], manager.sortedServerGroupID[index+1:]...)
+	delete(manager.serverGroupNames, group.Name)
+	delete(manager.serverGroups, groupID)
+	respChan <- nil
+	log.Printf("<resource_manager> server group '%s'(%s) deleted", group.Name, groupID)
+	return manager.saveConfig()
+}
+
+func verifyServerGroupConfig(config ServerGroupConfig) error {
+	if "" == config.Name {
+		return errors.New("name of server group required")
+	}
+	switch config.Policy {
+	case ServerGroupPolicyAffinity, ServerGroupPolicyAntiAffinity:
+		return nil
+	default:
+		return fmt.Errorf("invalid server group policy '%s'", config.Policy)
+	}
+}
+
+// serverGroupAccepted : group without owner shared by all instances, otherwise instance must belong to its user or group
+func serverGroupAccepted(config ServerGroupConfig, user, group string) bool {
+	if "" == config.User && "" == config.Group {
+		return true
+	}
+	return ("" != config.User && user == config.User) || ("" != config.Group && group == config.Group)
+}
+
+func (manager *ResourceManager) joinServerGroup(groupID, instanceID string) (err error) {
+	group, exists := manager.serverGroups[groupID]
+	if !exists {
+		err = fmt.Errorf("invalid server group '%s'", groupID)
+		return
+	}
+	group.Members = append(group.Members, instanceID)
+	manager.serverGroups[groupID] = group
+	manager.instanceGroups[instanceID] = groupID
+	log.Printf("<resource_manager> instance '%s' joined server group '%s'", instanceID, group.Name)
+	return nil
+}
+
+// leaveServerGroup : return true when instance removed from its server group
+func (manager *ResourceManager) leaveServerGroup(instanceID string) bool {
+	groupID, exists := manager.instanceGroups[instanceID]
+	if !exists {
+		return false
+	}
+	delete(manager.instanceGroups, instanceID)
+	group, exists := manager.serverGroups[groupID]
+	if !exists {
+		return false
+	}
+	var members []string
+	for _, memberID := range group.Members {
+		if memberID != instanceID {
+			members = append(members, memberID)
+		}
+	}
+	group.Members = members
+	manager.serverGroups[groupID] = group
+	log.Printf("<resource_manager> instance '%s' left server group '%s'", instanceID, group.Name)
+	return true
+}
+
+// buildPlacementConstraint : collect cells hosting members of server group,
+// members in ignored are skipped unless they have been relocated
+func (manager *ResourceManager) buildPlacementConstraint(groupID string, ignored map[string]bool, relocated map[string]string) (constraint placementConstraint, err error) {
+	group, exists := manager.serverGroups[groupID]
+	if !exists {
+		err = fmt.Errorf("invalid server group '%s'", groupID)
+		return
+	}
+	var occupied = map[string]bool{}
+	for _, memberID := range group.Members {
+		if targetCell, moved := relocated[memberID]; moved {
+			occupied[targetCell] = true
+		} else if ignored[memberID] {
+			continue
+		} else if ins, exists := manager.instances[memberID]; exists {
+			occupied[ins.Cell] = true
+		}
+	}
+	constraint.Group = group.Name
+	constraint.Policy = group.Policy
+	if ServerGroupPolicyAffinity == group.Policy {
+		constraint.Required = occupied
 	} else {
-		manager.sortedPolicyGroupID = append(manager.sortedPolicyGroupID[:index], manager.sortedPolicyGroupID[index+1:]...)
+		constraint.Excluded = occupied
+	}
+	return constraint, nil
+}
+
+// instanceConstraint : server group policy and label selector of an existing instance
+func (manager *ResourceManager) instanceConstraint(instanceID string, ignored map[string]bool, relocated map[string]string) (constraint placementConstraint, err error) {
+	if groupID, exists := manager.instanceGroups[instanceID]; exists {
+		if constraint, err = manager.buildPlacementConstraint(groupID, ignored, relocated); err != nil {
+			return
+		}
+	}
+	constraint.Labels = manager.instanceSelectors[instanceID]
+	return constraint, nil
+}
+
+// Quota
+func (manager *ResourceManager) handleQueryQuotas(respChan chan ResourceResult) (err error) {
+	var scopes []quotaScope
+	for scope, _ := range manager.quotas {
+		scopes = append(scopes, scope)
+	}
+	sort.Slice(scopes, func(i, j int) bool {
+		if scopes[i].Scope != scopes[j].Scope {
+			return scopes[i].Scope > scopes[j].Scope
+		}
+		return scopes[i].Name < scopes[j].Name
+	})
+	var result ResourceResult
+	for _, scope := range scopes {
+		result.QuotaList = append(result.QuotaList, QuotaStatus{
+			Scope: scope.Scope,
+			Name:  scope.Name,
+			Limit: manager.quotas[scope],
+			Used:  manager.quotaUsage(scope),
+		})
 	}
-	delete(manager.policyGroupNames, group.Name)
-	delete(manager.policyGroups, groupID)
-	respChan <- nil
-	log.Printf("<resource_manager> security policy group '%s'(%s) deleted", group.Name, groupID)
-	return manager.saveConfig()
+	respChan <- result
+	return nil
 }
 
-func (manager *ResourceManager) handleGetSecurityPolicyRules(groupID string, respChan chan ResourceResult) (err error) {
-	var group managedSecurityPolicyGroup
-	var exists bool
-	if group, exists = manager.policyGroups[groupID]; !exists {
-		err = fmt.Errorf("invalid security policy group '%s'", groupID)
+// handleGetQuota : usage always available, limit is empty when no quota specified
+func (manager *ResourceManager) handleGetQuota(scope, name string, respChan chan ResourceResult) (err error) {
+	var key = quotaScope{Scope: scope, Name: name}
+	if err = key.verify(); err != nil {
 		respChan <- ResourceResult{Error: err}
 		return
 	}
-	respChan <- ResourceResult{PolicyRuleList: group.Rules}
+	respChan <- ResourceResult{Quota: QuotaStatus{
+		Scope: scope,
+		Name:  name,
+		Limit: manager.quotas[key],
+		Used:  manager.quotaUsage(key),
+	}}
 	return nil
 }
 
-func (manager *ResourceManager) handleAddSecurityPolicyRule(groupID string, rule SecurityPolicyRule, respChan chan error) (err error) {
-	var group managedSecurityPolicyGroup
-	var exists bool
-	if group, exists = manager.policyGroups[groupID]; !exists {
-		err = fmt.Errorf("invalid security policy group '%s'", groupID)
+func (manager *ResourceManager) handleCreateQuota(scope, name string, limit ResourceQuota, respChan chan error) (err error) {
+	var key = quotaScope{Scope: scope, Name: name}
+	if err = key.verify(); err != nil {
 		respChan <- err
 		return
 	}
-	for _, current := range group.Rules {
-		if rule.TargetPort == current.TargetPort && rule.Protocol == current.Protocol && rule.SourceAddress == current.SourceAddress {
-			err = fmt.Errorf("rule %s:%s:%d already defined in policy group '%s'",
-				rule.Protocol, rule.SourceAddress, rule.TargetPort, group.Name)
-			respChan <- err
-			return
-		}
+	if _, exists := manager.quotas[key]; exists {
+		err = fmt.Errorf("quota of %s '%s' already exists", scope, name)
+		respChan <- err
+		return
 	}
-	group.Rules = append(group.Rules, rule)
-	manager.policyGroups[groupID] = group
+	manager.quotas[key] = limit
 	respChan <- nil
-	log.Printf("<resource_manager> new rule added to securiy policy group '%s'(%s)",
-		group.Name, groupID)
+	log.Printf("<resource_manager> quota of %s '%s' created", scope, name)
 	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleModifySecurityPolicyRule(groupID string, index int, rule SecurityPolicyRule, respChan chan error) (err error) {
-	var group managedSecurityPolicyGroup
-	var exists bool
-	if group, exists = manager.policyGroups[groupID]; !exists {
-		err = fmt.Errorf("invalid security policy group '%s'", groupID)
+func (manager *ResourceManager) handleModifyQuota(scope, name string, limit ResourceQuota, respChan chan error) (err error) {
+	var key = quotaScope{Scope: scope, Name: name}
+	current, exists := manager.quotas[key]
+	if !exists {
+		err = fmt.Errorf("no quota for %s '%s'", scope, name)
 		respChan <- err
 		return
 	}
-	if index >= len(group.Rules) {
-		err = fmt.Errorf("invalid index %d on security policy group '%s'", index, groupID)
+	if current == limit {
+		err = errors.New("no need to change")
 		respChan <- err
 		return
 	}
-	for currentIndex, current := range group.Rules {
-		if rule.TargetPort == current.TargetPort && rule.Protocol == current.Protocol && rule.SourceAddress == current.SourceAddress {
-			if index == currentIndex {
-				if rule.Accept == current.Accept {
-					err = errors.New("no need to change")
-					respChan <- err
-					return
-				}
-			} else {
-				err = fmt.Errorf("rule %s:%s:%d already defined in policy group '%s'",
-					rule.Protocol, rule.SourceAddress, rule.TargetPort, group.Name)
-				respChan <- err
-				return
-			}
-
-		}
-	}
-	group.Rules[index] = rule
-	manager.policyGroups[groupID] = group
+	manager.quotas[key] = limit
 	respChan <- nil
-	log.Printf("<resource_manager> %dth rule of securiy policy group '%s'(%s) modified",
-		index, group.Name, groupID)
+	log.Printf("<resource_manager> quota of %s '%s' modified", scope, name)
 	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleRemoveSecurityPolicyRule(groupID string, index int, respChan chan error) (err error) {
-	var group managedSecurityPolicyGroup
-	var exists bool
-	if group, exists = manager.policyGroups[groupID]; !exists {
-		err = fmt.Errorf("invalid security policy group '%s'", groupID)
+func (manager *ResourceManager) handleDeleteQuota(scope, name string, respChan chan error) (err error) {
+	var key = quotaScope{Scope: scope, Name: name}
+	if _, exists := manager.quotas[key]; !exists {
+		err = fmt.Errorf("no quota for %s '%s'", scope, name)
 		respChan <- err
 		return
 	}
-	if index >= len(group.Rules) {
-		err = fmt.Errorf("invalid index %d on security policy group '%s'", index, groupID)
+	delete(manager.quotas, key)
+	respChan <- nil
+	log.Printf("<resource_manager> quota of %s '%s' deleted", scope, name)
+	return manager.saveConfig()
+}
+
+func (manager *ResourceManager) handleCheckQuota(user, group string, required ResourceQuota, respChan chan error) (err error) {
+	err = manager.verifyQuota(user, group, required)
+	respChan <- err
+	return
+}
+
+func (manager *ResourceManager) handleUpdateSnapshotCount(instanceID string, count uint, respChan chan error) (err error) {
+	if _, exists := manager.instances[instanceID]; !exists {
+		err = fmt.Errorf("invalid instance '%s'", instanceID)
 		respChan <- err
 		return
 	}
-	if index == len(group.Rules)-1 {
-		//last
-		group.Rules = group.Rules[:index]
+	respChan <- nil
+	if count == manager.snapshotCounts[instanceID] {
+		return nil
+	}
+	if 0 == count {
+		delete(manager.snapshotCounts, instanceID)
 	} else {
-		group.Rules = append(group.Rules[:index], group.Rules[index+1:]...)
+		manager.snapshotCounts[instanceID] = count
 	}
-	manager.policyGroups[groupID] = group
-	respChan <- nil
-	log.Printf("<resource_manager> %dth rule of securiy policy group '%s'(%s) removed",
-		index, group.Name, groupID)
+	//persisted, so usage of instances not queried since restart still counted
 	return manager.saveConfig()
 }
 
-func (manager *ResourceManager) handleMoveSecurityPolicyRule(groupID string, index int, moveUp bool, respChan chan error) (err error) {
-	var group managedSecurityPolicyGroup
-	var exists bool
-	if group, exists = manager.policyGroups[groupID]; !exists {
-		err = fmt.Errorf("invalid security policy group '%s'", groupID)
-		respChan <- err
-		return
+func (scope quotaScope) verify() error {
+	if QuotaScopeUser != scope.Scope && QuotaScopeGroup != scope.Scope {
+		return fmt.Errorf("invalid quota scope '%s'", scope.Scope)
 	}
-	var ruleCount = len(group.Rules)
-	if index >= ruleCount {
-		err = fmt.Errorf("invalid index %d on security policy group '%s'", index, groupID)
-		respChan <- err
-		return
+	if "" == scope.Name {
+		return fmt.Errorf("name of %s required", scope.Scope)
 	}
-	var swapIndex int
-	if moveUp {
-		if 0 == index {
-			err = errors.New("already on top")
-			respChan <- err
-			return
+	return nil
+}
+
+func (manager *ResourceManager) quotaUsage(scope quotaScope) (used ResourceQuota) {
+	for _, ins := range manager.instances {
+		if QuotaScopeUser == scope.Scope && ins.User != scope.Name {
+			continue
 		}
-		swapIndex = index - 1
-	} else {
-		if ruleCount-1 == index {
-			err = errors.New("already on bottom")
-			respChan <- err
+		if QuotaScopeGroup == scope.Scope && ins.Group != scope.Name {
+			continue
+		}
+		used.Cores += ins.Cores
+		used.Memory += uint64(ins.Memory)
+		for _, diskSize := range ins.Disks {
+			used.Disk += diskSize
+		}
+		used.Guests++
+		used.Snapshots += manager.snapshotCounts[ins.ID]
+		if "" != ins.ExternalNetwork.AssignedAddress || manager.externalAddressRequired(ins.Pool) {
+			used.ExternalAddresses++
+		}
+	}
+	return
+}
+
+// externalAddressRequired : instances in pool with external allocation mode hold an external address,
+// counted before assigned by cell so pending instances can't bypass quota
+func (manager *ResourceManager) externalAddressRequired(poolName string) bool {
+	pool, exists := manager.pools[poolName]
+	if !exists || "" == pool.Network {
+		return false
+	}
+	addresses, exists := manager.addressPools[pool.Network]
+	if !exists {
+		return false
+	}
+	return AddressAllocationExternal == addresses.mode || AddressAllocationBoth == addresses.mode
+}
+
+// verifyQuota : check both quotas of user and group, only increased items verified
+func (manager *ResourceManager) verifyQuota(user, group string, required ResourceQuota) (err error) {
+	for _, scope := range []quotaScope{{QuotaScopeUser, user}, {QuotaScopeGroup, group}} {
+		limit, exists := manager.quotas[scope]
+		if !exists {
+			continue
+		}
+		var used = manager.quotaUsage(scope)
+		type quotaItem struct {
+			Name     string
+			Limit    uint64
+			Used     uint64
+			Required uint64
+			Bytes    bool
+		}
+		var items = []quotaItem{
+			{"cores", uint64(limit.Cores), uint64(used.Cores), uint64(required.Cores), false},
+			{"memory", limit.Memory, used.Memory, required.Memory, true},
+			{"disk", limit.Disk, used.Disk, required.Disk, true},
+			{"guests", uint64(limit.Guests), uint64(used.Guests), uint64(required.Guests), false},
+			{"snapshots", uint64(limit.Snapshots), uint64(used.Snapshots), uint64(required.Snapshots), false},
+			{"external addresses", uint64(limit.ExternalAddresses), uint64(used.ExternalAddresses), uint64(required.ExternalAddresses), false},
+		}
+		for _, item := range items {
+			if 0 == item.Required || 0 == item.Limit || item.Used+item.Required <= item.Limit {
+				continue
+			}
+			if item.Bytes {
+				err = fmt.Errorf("%s quota of %s '%s' exceeded: %s used, %s required, limit %s", item.Name, scope.Scope, scope.Name,
+					bytesToString(item.Used), bytesToString(item.Required), bytesToString(item.Limit))
+			} else {
+				err = fmt.Errorf("%s quota of %s '%s' exceeded: %d used, %d required, limit %d", item.Name, scope.Scope, scope.Name,
+					item.Used, item.Required, item.Limit)
+			}
 			return
 		}
-		swapIndex = index + 1
 	}
-	var current = group.Rules[index]
-	group.Rules[index] = group.Rules[swapIndex]
-	group.Rules[swapIndex] = current
-	respChan <- nil
-	if moveUp {
-		log.Printf("<resource_manager> %dth rule of securiy policy group '%s'(%s) moved up",
-			index, group.Name, groupID)
-	} else {
-		log.Printf("<resource_manager> %dth rule of securiy policy group '%s'(%s) moved down",
-			index, group.Name, groupID)
+	return nil
+}
+
+func resourceToQuota(resource InstanceResource, count uint) (required ResourceQuota) {
+	required.Cores = resource.Cores * count
+	required.Memory = uint64(resource.Memory) * uint64(count)
+	for _, diskSize := range resource.Disks {
+		required.Disk += diskSize * uint64(count)
 	}
-	return manager.saveConfig()
+	required.Guests = count
+	return
 }
 
 func (manager *ResourceManager) transferInstances(sourceName, targetName string, instances []string, monitorPorts []uint64) (err error) {
@@ -4382,23 +6077,38 @@ func (manager *ResourceManager) deallocateNetworkAddress(pool ManagedComputePool
 	return err
 }
 
-func (manager *ResourceManager) selectCell(poolName string, required InstanceResource, mustFulfill bool) (selected string, err error) {
-	const (
-		HealthCpuUsage float64 = 80
-	)
+func (manager *ResourceManager) selectCell(poolName string, required InstanceResource, mustFulfill bool, constraint placementConstraint) (selected string, err error) {
+	return manager.selectCellIn(manager.cells, poolName, required, mustFulfill, constraint)
+}
+
+// selectCellIn : select from specified cell status, used to simulate placement without changing cells of manager
+func (manager *ResourceManager) selectCellIn(cells map[string]ManagedComputeCell, poolName string, required InstanceResource, mustFulfill bool, constraint placementConstraint) (selected string, err error) {
 	pool, exists := manager.pools[poolName]
 	if !exists {
 		return "", fmt.Errorf("invalid pool '%s'", poolName)
 	}
-	var selectedCapacity float32 = 0.0
+	strategy, err := getPlacementStrategy(pool.Strategy)
+	if err != nil {
+		return
+	}
 	var requiredMemory = uint64(required.Memory)
 	var requiredDisk uint64 = 0
 	for _, volSize := range required.Disks {
 		requiredDisk += volSize
 	}
-	var coreMeetsRequirement, memoryMeetsRequirement, diskMeetsRequirement = false, false, false
+	var requirement = placementRequirement{Cores: uint64(required.Cores), Memory: requiredMemory, Disk: requiredDisk}
+	var cellNames []string
 	for cellName, _ := range pool.Cells {
-		cell, exists := manager.cells[cellName]
+		cellNames = append(cellNames, cellName)
+	}
+	sort.Stable(sort.StringSlice(cellNames))
+	var selectedScore float64
+	var selectedPreference = 0
+	var availableCells = 0
+	var overcommitExceeded []string
+	var coreMeetsRequirement, memoryMeetsRequirement, diskMeetsRequirement = false, false, false
+	for _, cellName := range cellNames {
+		cell, exists := cells[cellName]
 		if !exists {
 			err = fmt.Errorf("invalid cell '%s' in pool '%s'", cellName, poolName)
 			return
@@ -4411,10 +6121,19 @@ func (manager *ResourceManager) selectCell(poolName string, required InstanceRes
 			log.Printf("<resource_manager> debug: ignore disabled cell '%s' when select resource node", cellName)
 			continue
 		}
-
+		if !constraint.allows(cellName) {
+			log.Printf("<resource_manager> debug: ignore cell '%s' due to %s policy of server group '%s'",
+				cellName, constraint.Policy, constraint.Group)
+			continue
+		}
+		if !constraint.Labels.Matches(cell.Labels) {
+			log.Printf("<resource_manager> debug: ignore cell '%s' due to required labels unmatched", cellName)
+			continue
+		}
+		availableCells++
 		if mustFulfill {
 			//check minimal resource
-			if cell.CpuUsage > HealthCpuUsage {
+			if cell.CpuUsage > pool.CPUHealthThreshold {
 				log.Printf("<resource_manager> debug: ignore cell '%s' due to cpu overload (%.2f%%)", cellName, cell.CpuUsage)
 				continue
 			} else if !coreMeetsRequirement {
@@ -4435,19 +6154,37 @@ func (manager *ResourceManager) selectCell(poolName string, required InstanceRes
 				memoryMeetsRequirement = true
 			}
 		}
-		var realLoad = manager.evaluateRealTimeCapacity(cell, required.Cores, required.Memory, requiredDisk)
-
-		configureLoad, err := manager.evaluateConfigureCapacity(cell, required.Cores, required.Memory, requiredDisk)
+		candidate, err := manager.buildPlacementCandidate(cell, pool.PoolOvercommit)
 		if err != nil {
 			return "", err
 		}
-		var capacity = (realLoad + configureLoad) / 2
-		//log.Printf("<resource_manager> debug: '%s' => %.2f", cellName, capacity)
-		if capacity > selectedCapacity {
+		if mustFulfill {
+			if resource := exceededResource(candidate, requirement); "" != resource {
+				log.Printf("<resource_manager> debug: ignore cell '%s' due to %s overcommit ratio exceeded", cellName, resource)
+				overcommitExceeded = append(overcommitExceeded, fmt.Sprintf("%s: %s", cellName, resource))
+				continue
+			}
+		}
+		score, fit := strategy.Evaluate(candidate, requirement)
+		if !fit {
+			log.Printf("<resource_manager> debug: ignore cell '%s' due to insufficient capacity", cellName)
+			continue
+		}
+		//log.Printf("<resource_manager> debug: '%s' => %.2f", cellName, score)
+		//preferred labels take precedence over score
+		var preference = constraint.Labels.Preference(cell.Labels)
+		if "" == selected || preference > selectedPreference || (preference == selectedPreference && score > selectedScore) {
 			selected = cellName
-			selectedCapacity = capacity
+			selectedScore = score
+			selectedPreference = preference
 		}
 	}
+	if 0 == availableCells && "" != constraint.Group {
+		return "", fmt.Errorf("no available cell satisfies %s policy of server group '%s'", constraint.Policy, constraint.Group)
+	}
+	if 0 == availableCells && 0 != len(constraint.Labels.Required) {
+		return "", fmt.Errorf("no available cell has required labels %s", strings.Join(PlacementSelector{Required: constraint.Labels.Required}.ToTags(), ", "))
+	}
 	if mustFulfill {
 		if !diskMeetsRequirement {
 			return "", fmt.Errorf("no cell has enough disk: %s", bytesToString(requiredDisk))
@@ -4456,41 +6193,20 @@ func (manager *ResourceManager) selectCell(poolName string, required InstanceRes
 			return "", fmt.Errorf("no cell has enough memory: %s", bytesToString(requiredMemory))
 		}
 		if !coreMeetsRequirement {
-			return "", fmt.Errorf("all cell cores are busy (load over %.2f%%)", HealthCpuUsage)
+			return "", fmt.Errorf("all cell cores are busy (load over %.2f%%)", pool.CPUHealthThreshold)
+		}
+		if "" == selected && 0 != len(overcommitExceeded) {
+			return "", fmt.Errorf("no cell available, overcommit ratio exceeded in pool '%s' (%s)",
+				poolName, strings.Join(overcommitExceeded, ", "))
 		}
 	}
-	if 0.0 == selectedCapacity {
+	if "" == selected {
 		return "", errors.New("no cell fulfill the resource requirement")
 	}
 	return selected, nil
 }
 
-func (manager *ResourceManager) evaluateRealTimeCapacity(cell ManagedComputeCell, requireCore, requireMemory uint, requireDisk uint64) (capacity float32) {
-	const (
-		fullCPUUsage = 100
-	)
-
-	var availableCores float32
-	if cell.CpuUsage >= fullCPUUsage {
-		availableCores = 0
-	} else {
-		availableCores = float32(cell.Cores) * float32(fullCPUUsage-cell.CpuUsage) / 100
-	}
-
-	var coreCapacity = availableCores / float32(requireCore)
-	var memoryCapacity = float32(cell.MemoryAvailable) / float32(requireMemory)
-	var diskCapacity = float32(cell.DiskAvailable / requireDisk)
-	capacity = evaluateCoreFactor*coreCapacity + evaluateMemoryFactor*memoryCapacity + evaluateDiskFactor*diskCapacity
-	//log.Printf("<resource_manager> debug: real capacity %.2f, core %.2f / %d => %.2f, mem %d / %d => %.2f, disk %d / %d => %.2f",
-	//	capacity, availableCores, requireCore, coreCapacity, cell.MemoryAvailable >> 20, requireMemory >> 20, memoryCapacity,
-	//	cell.DiskAvailable  >> 30, requireDisk >> 30, diskCapacity)
-	return capacity
-}
-
-func (manager *ResourceManager) evaluateConfigureCapacity(cell ManagedComputeCell, requireCore, requireMemory uint, requireDisk uint64) (capacity float32, err error) {
-	const (
-		configureScale = 3
-	)
+func (manager *ResourceManager) buildPlacementCandidate(cell ManagedComputeCell, overcommit PoolOvercommit) (candidate placementCandidate, err error) {
 	var idList []string
 	for instanceID, _ := range cell.Instances {
 		idList = append(idList, instanceID)
@@ -4498,47 +6214,80 @@ func (manager *ResourceManager) evaluateConfigureCapacity(cell ManagedComputeCel
 	for instanceID, _ := range cell.Pending {
 		idList = append(idList, instanceID)
 	}
-	var availableCores = cell.Cores * configureScale
-	var availableMemory = cell.Memory * configureScale
-	var availableDisk = cell.Disk * configureScale
+	candidate.Name = cell.Name
+	candidate.Usage = cell.ResourceUsage
+	candidate.Instances = len(idList)
+	candidate.CoreLimit = uint64(float64(cell.Cores) * overcommit.CPURatio)
+	candidate.MemoryLimit = uint64(float64(cell.Memory) * overcommit.MemoryRatio)
+	candidate.DiskLimit = uint64(float64(cell.Disk) * overcommit.DiskRatio)
 	for _, instanceID := range idList {
 		ins, exists := manager.instances[instanceID]
 		if !exists {
 			err = fmt.Errorf("invalid instance '%s' in cell '%s'", instanceID, cell.Name)
 			return
 		}
-		availableCores -= ins.Cores
-		availableMemory -= uint64(ins.Memory)
+		candidate.AllocatedCores += uint64(ins.Cores)
+		candidate.AllocatedMemory += uint64(ins.Memory)
 		for _, diskSize := range ins.Disks {
-			availableDisk -= diskSize
+			candidate.AllocatedDisk += diskSize
 		}
 	}
-	var coreCapacity = float32(availableCores / requireCore)
-	var memoryCapacity = float32(availableMemory / uint64(requireMemory))
-	var diskCapacity = float32(availableDisk / requireDisk)
-	capacity = evaluateCoreFactor*coreCapacity + evaluateMemoryFactor*memoryCapacity + evaluateDiskFactor*diskCapacity
-	//log.Printf("<resource_manager> debug: configure capacity %.2f, core %d / %d => %.2f, mem %d / %d => %.2f, disk %d / %d => %.2f",
-	//	capacity, availableCores, requireCore, coreCapacity, availableMemory >> 20, requireMemory >> 20, memoryCapacity,
-	//		availableDisk >> 30, requireDisk >> 30, diskCapacity)
-	return capacity, nil
+	return candidate, nil
 }
 
 func (manager *ResourceManager) saveConfig() (err error) {
 	var config ResourceData
-	var totalPools, totalCells = 0, 0
+	if config, err = manager.buildResourceData(); err != nil {
+		return
+	}
+	manager.revision++
+	if err = manager.metadata.Save(config); err != nil {
+		err = fmt.Errorf("commit config data fail: %s", err.Error())
+		return
+	}
+	var totalCells = 0
+	for _, pool := range config.Pools {
+		totalCells += len(pool.Cells)
+	}
+	log.Printf("<resource_manager> %d pools, %d storages, %d address pool(s), %d cells, %d template(s), %d policy group(s), %d server group(s) saved to '%s'",
+		len(config.Pools), len(config.StoragePools), len(config.AddressPools),
+		totalCells, len(config.SystemTemplates), len(config.SecurityPolicyGroup), len(config.ServerGroups), manager.dataFile)
+	return nil
+}
+
+// compactConfig : write full snapshot and reset journal
+func (manager *ResourceManager) compactConfig() (err error) {
+	var config ResourceData
+	if config, err = manager.buildResourceData(); err != nil {
+		return
+	}
+	manager.revision++
+	if err = manager.metadata.Compact(config); err != nil {
+		err = fmt.Errorf("compact config data fail: %s", err.Error())
+		return
+	}
+	log.Printf("<resource_manager> config data compacted to '%s'", manager.dataFile)
+	return nil
+}
+
+func (manager *ResourceManager) buildResourceData() (config ResourceData, err error) {
+	config.SchemaVersion = ResourceDataVersion()
 	config.Zone = manager.zone.Name
 	for poolName, poolStatus := range manager.pools {
-		var pool = poolDefine{Name: poolName, Enabled: poolStatus.Enabled, Network: poolStatus.Network, Storage: poolStatus.Storage, Failover: poolStatus.Failover}
+		var overcommit = poolStatus.PoolOvercommit
+		var pool = poolDefine{Name: poolName, Enabled: poolStatus.Enabled, Network: poolStatus.Network, Storage: poolStatus.Storage,
+			Failover: poolStatus.Failover, Strategy: poolStatus.Strategy, CPUOvercommit: &overcommit.CPURatio, MemoryOvercommit: &overcommit.MemoryRatio,
+			DiskOvercommit: &overcommit.DiskRatio, CPUHealthThreshold: &overcommit.CPUHealthThreshold,
+			RebalanceThreshold: poolStatus.Rebalance.Threshold, RebalanceInterval: poolStatus.Rebalance.Interval,
+			FailoverTolerance: poolStatus.FailoverCapacity.Tolerance, FailoverEnforce: poolStatus.FailoverCapacity.Enforce}
 		pool.Cells = map[string]cellDefine{}
-		totalPools++
 		for cellName, _ := range poolStatus.Cells {
 			if cellStatus, exists := manager.cells[cellName]; exists {
-				var cell = cellDefine{Enabled: cellStatus.Enabled, PurgeAppending: cellStatus.PurgeAppending}
+				var cell = cellDefine{Enabled: cellStatus.Enabled, PurgeAppending: cellStatus.PurgeAppending, Labels: cellStatus.Labels}
 				pool.Cells[cellName] = cell
 			} else {
-				return fmt.Errorf("invalid cell '%s'", cellName)
+				return config, fmt.Errorf("invalid cell '%s'", cellName)
 			}
-			totalCells++
 		}
 		config.Pools = append(config.Pools, pool)
 	}
@@ -4559,7 +6308,7 @@ func (manager *ResourceManager) saveConfig() (err error) {
 		for _, startAddress := range pool.rangeStartAddressed {
 			currentRange, exists := pool.ranges[startAddress]
 			if !exists {
-				return fmt.Errorf("invalid start address '%s' in pool '%s'", startAddress, poolName)
+				return config, fmt.Errorf("invalid start address '%s' in pool '%s'", startAddress, poolName)
 			}
 			var status AddressRangeStatus
 			status.Start = currentRange.startAddress.String()
@@ -4591,18 +6340,61 @@ func (manager *ResourceManager) saveConfig() (err error) {
 		}
 		config.SecurityPolicyGroup = append(config.SecurityPolicyGroup, policy)
 	}
-	var data []byte
-	if data, err = json.MarshalIndent(config, "", " "); err != nil {
-		err = fmt.Errorf("marshal config data fail: %s", err.Error())
-		return
-	}
-	if err = ioutil.WriteFile(manager.dataFile, data, DefaultConfigPerm); err != nil {
-		return err
-	}
-	log.Printf("<resource_manager> %d pools, %d storages, %d address pool(s), %d cells, %d template(s), %d policy group(s) saved to '%s'",
-		totalPools, len(config.StoragePools), len(config.AddressPools),
-		totalCells, len(config.SystemTemplates), len(config.SecurityPolicyGroup), manager.dataFile)
-	return nil
+	var serverGroup ServerGroupStatus
+	for _, groupID := range manager.sortedServerGroupID {
+		if serverGroup, exists = manager.serverGroups[groupID]; !exists {
+			err = fmt.Errorf("invalid server group '%s'", groupID)
+			return
+		}
+		config.ServerGroups = append(config.ServerGroups, serverGroup)
+	}
+	for scope, limit := range manager.quotas {
+		config.Quotas = append(config.Quotas, quotaDefine{Scope: scope.Scope, Name: scope.Name, Limit: limit})
+	}
+	if 0 != len(manager.instanceSelectors) {
+		config.InstanceSelectors = manager.instanceSelectors
+	}
+	if 0 != len(manager.snapshotCounts) {
+		config.SnapshotCounts = manager.snapshotCounts
+	}
+	manager.saveTasks(&config)
+	//stable order, so unchanged sections are not journaled again
+	sort.Slice(config.Pools, func(i, j int) bool {
+		return config.Pools[i].Name < config.Pools[j].Name
+	})
+	sort.Slice(config.StoragePools, func(i, j int) bool {
+		return config.StoragePools[i].Name < config.StoragePools[j].Name
+	})
+	sort.Slice(config.AddressPools, func(i, j int) bool {
+		return config.AddressPools[i].Name < config.AddressPools[j].Name
+	})
+	for _, pool := range config.AddressPools {
+		for _, addressRange := range pool.Ranges {
+			var allocated = addressRange.Allocated
+			sort.Slice(allocated, func(i, j int) bool {
+				return allocated[i].Address < allocated[j].Address
+			})
+		}
+	}
+	sort.Slice(config.Quotas, func(i, j int) bool {
+		if config.Quotas[i].Scope != config.Quotas[j].Scope {
+			return config.Quotas[i].Scope < config.Quotas[j].Scope
+		}
+		return config.Quotas[i].Name < config.Quotas[j].Name
+	})
+	sort.Slice(config.Migrations, func(i, j int) bool {
+		return config.Migrations[i].ID < config.Migrations[j].ID
+	})
+	sort.Slice(config.Maintenances, func(i, j int) bool {
+		return config.Maintenances[i].Cell < config.Maintenances[j].Cell
+	})
+	for _, tasks := range [][]batchTaskDefine{config.BatchCreateTasks, config.BatchDeleteTasks, config.BatchStopTasks} {
+		var list = tasks
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].ID < list[j].ID
+		})
+	}
+	return config, nil
 }
 
 func (manager *ResourceManager) generateDefaultTemplates() (templates []SystemTemplate, err error) {
@@ -4676,6 +6468,10 @@ func (manager *ResourceManager) generateDefaultConfig() (err error) {
 	var defaultPool = ManagedComputePool{}
 	defaultPool.Name = DefaultPoolName
 	defaultPool.Enabled = true
+	defaultPool.Strategy = DefaultPlacementStrategy
+	defaultPool.PoolOvercommit = defaultPoolOvercommit()
+	defaultPool.Rebalance = defaultRebalanceConfig()
+	defaultPool.FailoverCapacity = defaultFailoverCapacity()
 	defaultPool.Cells = map[string]bool{}
 	defaultPool.InstanceNames = map[string]string{}
 	manager.pools = map[string]ManagedComputePool{DefaultPoolName: defaultPool}
@@ -4690,6 +6486,10 @@ func (manager *ResourceManager) generateDefaultConfig() (err error) {
 	}
 	manager.policyGroups = map[string]managedSecurityPolicyGroup{}
 	manager.policyGroupNames = map[string]bool{}
+	manager.serverGroups = map[string]ServerGroupStatus{}
+	manager.serverGroupNames = map[string]bool{}
+	manager.instanceGroups = map[string]string{}
+	manager.quotas = map[quotaScope]ResourceQuota{}
 	log.Println("<resource_manager> default configure generated")
 	return nil
 }
@@ -4698,13 +6498,23 @@ func (manager *ResourceManager) loadConfig() (err error) {
 	var configChanged = false
 	defer func() {
 		if configChanged {
-			if err = manager.saveConfig(); err != nil {
+			if err = manager.compactConfig(); err != nil {
 				log.Printf("<resource_manager> save config fail after load: %s", err.Error())
 				return
 			}
 		}
 	}()
-	if _, err = os.Stat(manager.dataFile); os.IsNotExist(err) {
+	if _, err = store.Migrate(manager.metadata, resourceDataMigrations, manager.dataFile); err != nil {
+		err = fmt.Errorf("migrate config in '%s' fail: %s", manager.dataFile, err.Error())
+		return
+	}
+	var config ResourceData
+	var result store.LoadResult
+	if result, err = manager.metadata.Load(&config); err != nil {
+		err = fmt.Errorf("read config from '%s' fail: %s", manager.dataFile, err.Error())
+		return
+	}
+	if !result.Exists {
 		if err = manager.generateDefaultConfig(); err != nil {
 			err = fmt.Errorf("generate default config fail: %s", err.Error())
 			return
@@ -4712,16 +6522,53 @@ func (manager *ResourceManager) loadConfig() (err error) {
 		configChanged = true
 		return
 	}
-	var data []byte
-
-	if data, err = ioutil.ReadFile(manager.dataFile); err != nil {
-		err = fmt.Errorf("read config from '%s' fail: %s", manager.dataFile, err.Error())
+	if result.Dirty {
+		//journal replayed or imported
+		configChanged = true
+	}
+	var generated bool
+	if generated, err = manager.loadZoneConfig(config); err != nil {
 		return
 	}
-	var config ResourceData
-	if err = json.Unmarshal(data, &config); err != nil {
-		return err
+	if generated {
+		configChanged = true
+	}
+	var totalInstances = 0
+	for _, pool := range config.Pools {
+		for _, cell := range pool.Cells {
+			totalInstances += len(cell.Instances)
+		}
+	}
+	for _, group := range config.ServerGroups {
+		manager.serverGroups[group.ID] = group
+		manager.serverGroupNames[group.Name] = true
+		manager.sortedServerGroupID = append(manager.sortedServerGroupID, group.ID)
+		for _, instanceID := range group.Members {
+			manager.instanceGroups[instanceID] = group.ID
+		}
+	}
+	for _, quota := range config.Quotas {
+		manager.quotas[quotaScope{Scope: quota.Scope, Name: quota.Name}] = quota.Limit
+	}
+	for instanceID, selector := range config.InstanceSelectors {
+		manager.instanceSelectors[instanceID] = selector
+	}
+	for instanceID, count := range config.SnapshotCounts {
+		manager.snapshotCounts[instanceID] = count
 	}
+	if manager.loadTasks(config) {
+		configChanged = true
+	}
+	manager.zone.Name = config.Zone
+	log.Printf("<resource_manager>  %d compute/ %d storage/ %d address pools, %d templates, %d security policy, %d server group(s), %d cell(s), %d instance(s) loaded",
+		len(manager.pools), len(manager.storagePools), len(manager.addressPools),
+		len(manager.allTemplateID), len(manager.sortedPolicyGroupID), len(manager.sortedServerGroupID), len(manager.cells), totalInstances)
+
+	return nil
+}
+
+// loadZoneConfig : load pools, cells, templates and security policy groups, generated is true when default templates created
+func (manager *ResourceManager) loadZoneConfig(config ResourceData) (generated bool, err error) {
 	for _, poolDefine := range config.AddressPools {
 		var pool ManagedAddressPool
 		pool.name = poolDefine.Name
@@ -4734,14 +6581,16 @@ func (manager *ResourceManager) loadConfig() (err error) {
 		for _, rangeDefine := range poolDefine.Ranges {
 			var status ManagedIPV4AddressRange
 			if status.startAddress = net.ParseIP(rangeDefine.Start); nil == status.startAddress {
-				return fmt.Errorf("invalid start address '%s' of pool '%s'", rangeDefine.Start, poolDefine.Name)
+				err = fmt.Errorf("invalid start address '%s' of pool '%s'", rangeDefine.Start, poolDefine.Name)
+				return
 			}
 			if status.endAddress = net.ParseIP(rangeDefine.End); nil == status.endAddress {
-				return fmt.Errorf("invalid end address '%s' of pool '%s'", rangeDefine.End, poolDefine.Name)
+				err = fmt.Errorf("invalid end address '%s' of pool '%s'", rangeDefine.End, poolDefine.Name)
+				return
 			}
 			status.netmask, err = IPv4ToMask(rangeDefine.Netmask)
 			if err != nil {
-				return err
+				return
 			}
 			status.capacity = rangeDefine.Capacity
 			status.allocated = map[string]string{}
@@ -4753,7 +6602,6 @@ func (manager *ResourceManager) loadConfig() (err error) {
 		}
 		manager.addressPools[pool.name] = pool
 	}
-	var totalCells, totalInstances = 0, 0
 	for _, pool := range config.Pools {
 		var poolStatus = ManagedComputePool{}
 		poolStatus.Enabled = pool.Enabled
@@ -4761,11 +6609,10 @@ func (manager *ResourceManager) loadConfig() (err error) {
 		poolStatus.Cells = map[string]bool{}
 		poolStatus.InstanceNames = map[string]string{}
 		for cellName, cell := range pool.Cells {
-			totalInstances += len(cell.Instances)
-			totalCells++
 			var cellStatus = ManagedComputeCell{}
 			cellStatus.Enabled = cell.Enabled
 			cellStatus.PurgeAppending = cell.PurgeAppending
+			cellStatus.Labels = cell.Labels
 			cellStatus.Name = cellName
 			cellStatus.Pool = pool.Name
 			cellStatus.Instances = map[string]bool{}
@@ -4774,6 +6621,25 @@ func (manager *ResourceManager) loadConfig() (err error) {
 			poolStatus.Cells[cellName] = true
 		}
 		poolStatus.Failover = pool.Failover
+		if "" == pool.Strategy {
+			poolStatus.Strategy = DefaultPlacementStrategy
+		} else {
+			poolStatus.Strategy = pool.Strategy
+		}
+		poolStatus.PoolOvercommit = PoolOvercommitUpdate{
+			CPURatio:           pool.CPUOvercommit,
+			MemoryRatio:        pool.MemoryOvercommit,
+			DiskRatio:          pool.DiskOvercommit,
+			CPUHealthThreshold: pool.CPUHealthThreshold,
+		}.Apply(defaultPoolOvercommit())
+		poolStatus.Rebalance = PoolRebalanceConfig{Threshold: pool.RebalanceThreshold, Interval: pool.RebalanceInterval}
+		if 0 == poolStatus.Rebalance.Threshold {
+			poolStatus.Rebalance.Threshold = DefaultRebalanceThreshold
+		}
+		poolStatus.FailoverCapacity = PoolFailoverCapacity{Tolerance: pool.FailoverTolerance, Enforce: pool.FailoverEnforce}
+		if 0 == poolStatus.FailoverCapacity.Tolerance {
+			poolStatus.FailoverCapacity.Tolerance = DefaultFailoverTolerance
+		}
 		poolStatus.CellCount = uint64(len(pool.Cells))
 		poolStatus.Storage = pool.Storage
 		poolStatus.Network = pool.Network
@@ -4792,7 +6658,7 @@ func (manager *ResourceManager) loadConfig() (err error) {
 			err = fmt.Errorf("generate templates fail: %s", err.Error())
 			return
 		}
-		configChanged = true
+		generated = true
 	}
 	for _, template := range templates {
 		manager.templates[template.ID] = template
@@ -4803,12 +6669,7 @@ func (manager *ResourceManager) loadConfig() (err error) {
 		manager.policyGroupNames[policy.Name] = true
 		manager.sortedPolicyGroupID = append(manager.sortedPolicyGroupID, policy.ID)
 	}
-	manager.zone.Name = config.Zone
-	log.Printf("<resource_manager>  %d compute/ %d storage/ %d address pools, %d templates, %d security policy, %d cell(s), %d instance(s) loaded",
-		len(manager.pools), len(manager.storagePools), len(manager.addressPools),
-		len(manager.allTemplateID), len(manager.sortedPolicyGroupID), len(manager.cells), totalInstances)
-
-	return nil
+	return generated, nil
 }
 
 func (manager *ResourceManager) syncInstanceStatistic(cellName string) (err error) {
@@ -0,0 +1,66 @@
+package modules
+
+import (
+	"testing"
+)
+
+func TestResourceQuota_ExternalAddresses(t *testing.T) {
+	var pools = map[string]ManagedComputePool{
+		"public":   {ComputePoolInfo: ComputePoolInfo{Name: "public", Network: "public"}},
+		"both":     {ComputePoolInfo: ComputePoolInfo{Name: "both", Network: "both"}},
+		"private":  {ComputePoolInfo: ComputePoolInfo{Name: "private", Network: "private"}},
+		"no-route": {ComputePoolInfo: ComputePoolInfo{Name: "no-route"}},
+	}
+	var addressPools = map[string]ManagedAddressPool{
+		"public":  {name: "public", mode: AddressAllocationExternal},
+		"both":    {name: "both", mode: AddressAllocationBoth},
+		"private": {name: "private", mode: AddressAllocationInternal},
+	}
+	var testCases = []struct {
+		Name      string
+		Instances []InstanceStatus
+		Pool      string
+		Count     uint
+		Used      uint
+		Allowed   bool
+	}{
+		{"first address", nil, "public", 1, 0, true},
+		{"within limit", []InstanceStatus{{Pool: "public"}}, "both", 1, 1, true},
+		{"pending instance counted", []InstanceStatus{{Pool: "public"}, {Pool: "both"}}, "public", 1, 2, false},
+		{"assigned by cell counted", []InstanceStatus{{Pool: "private", ExternalNetwork: InstanceNetworkInfo{AssignedAddress: "1.1.1.1"}},
+			{Pool: "public"}}, "public", 1, 2, false},
+		{"internal only pool", []InstanceStatus{{Pool: "public"}, {Pool: "both"}}, "private", 1, 2, true},
+		{"pool without network", []InstanceStatus{{Pool: "public"}, {Pool: "both"}}, "no-route", 1, 2, true},
+		{"batch exceeds limit", nil, "public", 3, 0, false},
+		{"instance of other user", []InstanceStatus{{Pool: "public", User: "bob"}, {Pool: "both", User: "bob"}}, "public", 2, 0, true},
+	}
+	for _, testCase := range testCases {
+		var manager = &ResourceManager{
+			pools:        pools,
+			addressPools: addressPools,
+			instances:    map[string]InstanceStatus{},
+			quotas: map[quotaScope]ResourceQuota{
+				{QuotaScopeUser, "alice"}: {ExternalAddresses: 2},
+			},
+		}
+		for index, instance := range testCase.Instances {
+			instance.ID = string(rune('a' + index))
+			if "" == instance.User {
+				instance.User = "alice"
+			}
+			manager.instances[instance.ID] = instance
+		}
+		var used = manager.quotaUsage(quotaScope{QuotaScopeUser, "alice"})
+		if used.ExternalAddresses != testCase.Used {
+			t.Fatalf("%s: %d external address used, %d expected", testCase.Name, used.ExternalAddresses, testCase.Used)
+		}
+		var required ResourceQuota
+		if manager.externalAddressRequired(testCase.Pool) {
+			required.ExternalAddresses = testCase.Count
+		}
+		var err = manager.verifyQuota("alice", "dev", required)
+		if (nil == err) != testCase.Allowed {
+			t.Fatalf("%s: unexpected verify result %v", testCase.Name, err)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package modules
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	batchTaskStallTimeout   = time.Second * 30
+	batchTaskRecoverTimeout = time.Minute * 5
+	taskInterruptedReason   = "interrupted by restart of core"
+)
+
+type migrationDefine struct {
+	ID         string            `json:"id"`
+	SourcePool string            `json:"source_pool"`
+	SourceCell string            `json:"source_cell"`
+	TargetPool string            `json:"target_pool"`
+	TargetCell string            `json:"target_cell"`
+	Instances  []string          `json:"instances"`
+	Selector   PlacementSelector `json:"selector,omitempty"`
+	Finished   bool              `json:"finished,omitempty"`
+	Progress   uint              `json:"progress,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	Recovered  bool              `json:"recovered,omitempty"`
+	CreateTime time.Time         `json:"create_time"`
+	FinishTime time.Time         `json:"finish_time,omitempty"`
+}
+
+type batchGuestDefine struct {
+	Name     string          `json:"name"`
+	ID       string          `json:"id,omitempty"`
+	Progress uint            `json:"progress,omitempty"`
+	Status   BatchTaskStatus `json:"status"`
+	Error    string          `json:"error,omitempty"`
+}
+
+type batchTaskDefine struct {
+	ID           string             `json:"id"`
+	StartTime    time.Time          `json:"start_time"`
+	LatestUpdate time.Time          `json:"latest_update"`
+	Finished     bool               `json:"finished,omitempty"`
+	Recovered    bool               `json:"recovered,omitempty"`
+	Guests       []batchGuestDefine `json:"guests"`
+}
+
+// saveTasks : fill migrations, batch tasks and cell maintenances into data for persistence
+func (manager *ResourceManager) saveTasks(config *ResourceData) {
+	for _, migration := range manager.migrations {
+		var define = migrationDefine{
+			ID:         migration.ID,
+			SourcePool: migration.SourcePool,
+			SourceCell: migration.SourceCell,
+			TargetPool: migration.TargetPool,
+			TargetCell: migration.TargetCell,
+			Instances:  migration.Instances,
+			Selector:   migration.Selector,
+			Finished:   migration.Finished,
+			Progress:   migration.Progress,
+			Recovered:  migration.Recovered,
+			CreateTime: migration.CreateTime,
+			FinishTime: migration.FinishTime,
+		}
+		if nil != migration.Error {
+			define.Error = migration.Error.Error()
+		}
+		config.Migrations = append(config.Migrations, define)
+	}
+	for taskID, task := range manager.batchCreateTasks {
+		var define = batchTaskDefine{ID: taskID, StartTime: task.StartTime, LatestUpdate: task.LatestUpdate,
+			Finished: task.Finished, Recovered: task.Recovered}
+		for _, guest := range task.Guests {
+			define.Guests = append(define.Guests, batchGuestDefine{guest.Name, guest.ID, guest.Progress, guest.Status, guest.Error})
+		}
+		config.BatchCreateTasks = append(config.BatchCreateTasks, define)
+	}
+	for taskID, task := range manager.batchDeleteTasks {
+		var define = batchTaskDefine{ID: taskID, StartTime: task.StartTime, LatestUpdate: task.LatestUpdate,
+			Finished: task.Finished, Recovered: task.Recovered}
+		for _, guest := range task.Guests {
+			define.Guests = append(define.Guests, batchGuestDefine{Name: guest.Name, ID: guest.ID, Status: guest.Status, Error: guest.Error})
+		}
+		config.BatchDeleteTasks = append(config.BatchDeleteTasks, define)
+	}
+	for taskID, task := range manager.batchStopTasks {
+		var define = batchTaskDefine{ID: taskID, StartTime: task.StartTime, LatestUpdate: task.LatestUpdate,
+			Finished: task.Finished, Recovered: task.Recovered}
+		for _, guest := range task.Guests {
+			define.Guests = append(define.Guests, batchGuestDefine{Name: guest.Name, ID: guest.ID, Status: guest.Status, Error: guest.Error})
+		}
+		config.BatchStopTasks = append(config.BatchStopTasks, define)
+	}
+	for _, status := range manager.maintenances {
+		config.Maintenances = append(config.Maintenances, status)
+	}
+}
+
+// loadTasks : recover migrations, batch tasks and cell maintenances, unfinished ones are marked recovered and
+// reconciled after cells report their instances
+func (manager *ResourceManager) loadTasks(config ResourceData) (changed bool) {
+	var now = time.Now()
+	var interrupted = 0
+	for _, define := range config.Migrations {
+		var migration = MigrationStatus{
+			MigrationParameter: MigrationParameter{
+				ID:         define.ID,
+				SourcePool: define.SourcePool,
+				SourceCell: define.SourceCell,
+				TargetPool: define.TargetPool,
+				TargetCell: define.TargetCell,
+				Instances:  define.Instances,
+				Selector:   define.Selector,
+			},
+			Finished:   define.Finished,
+			Progress:   define.Progress,
+			Recovered:  define.Recovered,
+			CreateTime: define.CreateTime,
+			FinishTime: define.FinishTime,
+		}
+		if "" != define.Error {
+			migration.Error = errors.New(define.Error)
+		} else if !define.Finished {
+			//in progress when core stopped
+			migration.Error = errors.New(taskInterruptedReason)
+			migration.Recovered = true
+			migration.FinishTime = now
+			interrupted++
+			changed = true
+		}
+		manager.migrations[migration.ID] = migration
+	}
+	for _, define := range config.BatchCreateTasks {
+		var task = BatchCreateGuestTask{StartTime: define.StartTime, LatestUpdate: define.LatestUpdate,
+			Finished: define.Finished, Recovered: define.Recovered, GuestName: map[string]int{}}
+		for index, guest := range define.Guests {
+			var status = CreateGuestStatus{guest.Name, guest.ID, guest.Progress, guest.Status, guest.Error}
+			if !task.Finished && BatchTaskStatusProcess == status.Status && "" == status.ID {
+				//never allocated, nobody will create it anymore
+				status.Status = BatchTaskStatusFail
+				status.Error = taskInterruptedReason
+			}
+			task.Guests = append(task.Guests, status)
+			task.GuestName[guest.Name] = index
+		}
+		if !task.Finished {
+			task.Recovered = true
+			task.LatestUpdate = now
+			interrupted++
+			changed = true
+		}
+		manager.batchCreateTasks[define.ID] = task
+	}
+	for _, define := range config.BatchDeleteTasks {
+		var task = BatchDeleteGuestTask{StartTime: define.StartTime, LatestUpdate: define.LatestUpdate,
+			Finished: define.Finished, Recovered: define.Recovered, GuestID: map[string]int{}}
+		for index, guest := range define.Guests {
+			task.Guests = append(task.Guests, DeleteGuestStatus{guest.Name, guest.ID, guest.Status, guest.Error})
+			task.GuestID[guest.ID] = index
+		}
+		if !task.Finished {
+			task.Recovered = true
+			task.LatestUpdate = now
+			interrupted++
+			changed = true
+		}
+		manager.batchDeleteTasks[define.ID] = task
+	}
+	for _, define := range config.BatchStopTasks {
+		var task = BatchStopGuestTask{StartTime: define.StartTime, LatestUpdate: define.LatestUpdate,
+			Finished: define.Finished, Recovered: define.Recovered, GuestID: map[string]int{}}
+		for index, guest := range define.Guests {
+			task.Guests = append(task.Guests, StopGuestStatus{guest.Name, guest.ID, guest.Status, guest.Error})
+			task.GuestID[guest.ID] = index
+		}
+		if !task.Finished {
+			task.Recovered = true
+			task.LatestUpdate = now
+			interrupted++
+			changed = true
+		}
+		manager.batchStopTasks[define.ID] = task
+	}
+	for _, status := range config.Maintenances {
+		if cell, exists := manager.cells[status.Cell]; !exists || cell.Pool != status.Pool {
+			log.Printf("<resource_manager> warning: maintenance of cell '%s' discarded, cell not in pool '%s' anymore",
+				status.Cell, status.Pool)
+			changed = true
+			continue
+		}
+		//drain continues after cell reported, interrupted migration released when refreshed
+		manager.maintenances[status.Cell] = status
+	}
+	if 0 != len(config.Maintenances) {
+		log.Printf("<resource_manager> %d cell maintenance(s) recovered", len(manager.maintenances))
+	}
+	if 0 != len(config.Migrations)+len(config.BatchCreateTasks)+len(config.BatchDeleteTasks)+len(config.BatchStopTasks) {
+		log.Printf("<resource_manager> %d migration(s), %d/%d/%d batch create/delete/stop task(s) recovered, %d interrupted",
+			len(config.Migrations), len(config.BatchCreateTasks), len(config.BatchDeleteTasks), len(config.BatchStopTasks), interrupted)
+	}
+	return
+}
+
+// instancesSynchronized : all cells in pools have reported instances since core started
+func (manager *ResourceManager) instancesSynchronized() bool {
+	for _, pool := range manager.pools {
+		for cellName, _ := range pool.Cells {
+			if cell, exists := manager.cells[cellName]; !exists || !cell.Synchronized {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// reconcileMigrations : check migrations involving cell after it reports all instances
+func (manager *ResourceManager) reconcileMigrations(cellName string) (changed bool) {
+	var flagChanged = false
+	for migrationID, migration := range manager.migrations {
+		if migration.Finished {
+			continue
+		}
+		if migration.SourceCell != cellName && migration.TargetCell != cellName {
+			continue
+		}
+		if migration.Recovered {
+			var completed = true
+			for _, instanceID := range migration.Instances {
+				if ins, exists := manager.instances[instanceID]; !exists || ins.Cell != migration.TargetCell {
+					completed = false
+					break
+				}
+			}
+			if completed {
+				migration.Finished = true
+				migration.Error = nil
+				migration.Recovered = false
+				migration.Progress = 100
+				migration.FinishTime = time.Now()
+				manager.migrations[migrationID] = migration
+				log.Printf("<resource_manager> interrupted migration '%s' reconciled as finished, all instance(s) found in cell '%s'",
+					migrationID, migration.TargetCell)
+				changed = true
+			}
+			continue
+		}
+		if nil != migration.Error {
+			continue
+		}
+		//still in progress, restore migrating flag of instance reported again
+		for _, instanceID := range migration.Instances {
+			if ins, exists := manager.instances[instanceID]; exists && ins.Cell == migration.SourceCell && !ins.Migrating {
+				ins.Migrating = true
+				manager.instances[instanceID] = ins
+				flagChanged = true
+			}
+		}
+	}
+	if flagChanged {
+		if err := manager.syncInstanceStatistic(cellName); err != nil {
+			log.Printf("<resource_manager> warning: sync instance statistic on cell '%s' fail: %s", cellName, err.Error())
+		}
+	}
+	return
+}
+
+// releaseExpiredMigrations : release finished or failed migrations out of retention
+func (manager *ResourceManager) releaseExpiredMigrations() (changed bool) {
+	var expireTime = time.Now().Add(-GetConfigurator().GetTaskRetention())
+	for migrationID, migration := range manager.migrations {
+		if !migration.Finished && nil == migration.Error {
+			continue
+		}
+		if migration.FinishTime.Before(expireTime) {
+			delete(manager.migrations, migrationID)
+			log.Printf("<resource_manager> release expired migration '%s'", migrationID)
+			changed = true
+		}
+	}
+	return
+}
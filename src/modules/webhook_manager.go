@@ -0,0 +1,801 @@
+package modules
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/project-nano/core/store"
+	"github.com/project-nano/framework"
+	uuid "github.com/satori/go.uuid"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+)
+
+const (
+	WebhookDataFilename        = "webhook.data"
+	HeaderNameWebhook          = "Nano-Webhook"
+	HeaderNameEvent            = "Nano-Event"
+	HeaderNameWebhookSignature = "Nano-Signature"
+	webhookSignatureMethod     = "Nano-Webhook-HMAC-SHA256"
+	webhookMaxAttempts         = 6
+	webhookRetryBase           = time.Second * 5
+	webhookRetryMax            = time.Minute * 5
+	webhookRequestTimeout      = time.Second * 10
+	webhookConcurrentLimit     = 8
+	webhookPendingLimit        = 1 << 12
+	webhookDeadLetterLimit     = 1 << 10
+	webhookResolveTimeout      = time.Second * 3
+)
+
+// WebhookConfig : empty events or pool matches all, secret required for signature
+type WebhookConfig struct {
+	URL     string   `json:"url"`
+	Events  []string `json:"events,omitempty"`
+	Pool    string   `json:"pool,omitempty"`
+	Secret  string   `json:"secret,omitempty"`
+	Enabled bool     `json:"enabled"`
+}
+
+// Webhook : secret never returned by query
+type Webhook struct {
+	ID string `json:"id"`
+	WebhookConfig
+	CreatedTime  string `json:"created_time"`
+	ModifiedTime string `json:"modified_time"`
+}
+
+// WebhookDeadLetter : event failed to deliver after all attempts
+type WebhookDeadLetter struct {
+	ID          string       `json:"id"`
+	Webhook     string       `json:"webhook"`
+	Event       ClusterEvent `json:"event"`
+	Attempts    int          `json:"attempts"`
+	LastError   string       `json:"last_error"`
+	LastAttempt string       `json:"last_attempt"`
+}
+
+// WebhookPayload : body posted to webhook URL
+type WebhookPayload struct {
+	Webhook string       `json:"webhook"`
+	Event   ClusterEvent `json:"event"`
+}
+
+type WebhookResult struct {
+	Error       error
+	ID          string
+	Webhook     Webhook
+	WebhookList []Webhook
+	DeadLetters []WebhookDeadLetter
+	Data        []byte
+	Revision    uint64
+}
+
+type WebhookModule interface {
+	QueryWebhooks(respChan chan WebhookResult)
+	GetWebhook(id string, respChan chan WebhookResult)
+	CreateWebhook(config WebhookConfig, respChan chan WebhookResult)
+	ModifyWebhook(id string, config WebhookConfig, respChan chan error)
+	DeleteWebhook(id string, respChan chan error)
+	QueryDeadLetters(webhook string, respChan chan WebhookResult)
+	RedeliverDeadLetter(id string, respChan chan error)
+	DeleteDeadLetter(id string, respChan chan error)
+	ExportWebhookData(revision uint64, respChan chan WebhookResult)
+}
+
+type webhookCommandType int
+
+const (
+	webhookCmdQuery = iota
+	webhookCmdGet
+	webhookCmdCreate
+	webhookCmdModify
+	webhookCmdDelete
+	webhookCmdQueryDeadLetters
+	webhookCmdRedeliver
+	webhookCmdDeleteDeadLetter
+	webhookCmdExport
+)
+
+type webhookCommand struct {
+	Type       webhookCommandType
+	ID         string
+	Config     WebhookConfig
+	Revision   uint64
+	ResultChan chan WebhookResult
+	ErrorChan  chan error
+}
+
+type webhookDelivery struct {
+	Webhook   string
+	Event     ClusterEvent
+	Attempts  int
+	NextTime  time.Time
+	LastError string
+	Sending   bool
+}
+
+type webhookDeliveryResult struct {
+	Delivery uint64
+	Error    error
+}
+
+// webhookPendingDelivery : delivery waiting for first attempt or retry, restored when core restarted
+type webhookPendingDelivery struct {
+	Webhook   string       `json:"webhook"`
+	Event     ClusterEvent `json:"event"`
+	Attempts  int          `json:"attempts,omitempty"`
+	LastError string       `json:"last_error,omitempty"`
+}
+
+type webhookSavedData struct {
+	Webhooks    []Webhook                `json:"webhooks,omitempty"`
+	DeadLetters []WebhookDeadLetter      `json:"dead_letters,omitempty"`
+	Pending     []webhookPendingDelivery `json:"pending,omitempty"`
+}
+
+// WebhookManager : post cluster events to subscribed URLs, retry with backoff,
+// move to dead letters when all attempts failed
+type WebhookManager struct {
+	webhooks     map[string]Webhook
+	deadLetters  []WebhookDeadLetter
+	deliveries   map[uint64]webhookDelivery
+	nextDelivery uint64
+	pendingDirty bool
+	revision     uint64 //increased by every save from 1, replicated when changed
+	sending      int
+	events       EventModule
+	subscription *EventSubscription
+	cursor       string
+	results      chan webhookDeliveryResult
+	commands     chan webhookCommand
+	client       http.Client
+	dataFile     string
+	metadata     store.Store
+	runner       *framework.SimpleRunner
+}
+
+func CreateWebhookManager(dataPath string, events EventModule) (manager *WebhookManager, err error) {
+	const (
+		DefaultQueueLength = 1 << 10
+	)
+	manager = &WebhookManager{events: events, revision: 1}
+	manager.webhooks = map[string]Webhook{}
+	manager.deliveries = map[uint64]webhookDelivery{}
+	manager.results = make(chan webhookDeliveryResult, webhookConcurrentLimit)
+	manager.commands = make(chan webhookCommand, DefaultQueueLength)
+	//address checked when connecting, so neither redirect nor changed DNS record reaches forbidden target
+	var dialer = net.Dialer{Timeout: webhookRequestTimeout, Control: controlWebhookConnection}
+	manager.client = http.Client{
+		Timeout:   webhookRequestTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+	}
+	manager.dataFile = filepath.Join(dataPath, WebhookDataFilename)
+	if manager.metadata, err = store.Open(GetConfigurator().GetMetadataStore(), manager.dataFile); err != nil {
+		return
+	}
+	if err = manager.loadData(); err != nil {
+		return
+	}
+	manager.runner = framework.CreateSimpleRunner(manager.routine)
+	return manager, nil
+}
+
+func (manager *WebhookManager) Start() error {
+	return manager.runner.Start()
+}
+
+func (manager *WebhookManager) Stop() error {
+	return manager.runner.Stop()
+}
+
+func (manager *WebhookManager) QueryWebhooks(respChan chan WebhookResult) {
+	manager.commands <- webhookCommand{Type: webhookCmdQuery, ResultChan: respChan}
+}
+
+func (manager *WebhookManager) GetWebhook(id string, respChan chan WebhookResult) {
+	manager.commands <- webhookCommand{Type: webhookCmdGet, ID: id, ResultChan: respChan}
+}
+
+func (manager *WebhookManager) CreateWebhook(config WebhookConfig, respChan chan WebhookResult) {
+	manager.commands <- webhookCommand{Type: webhookCmdCreate, Config: config, ResultChan: respChan}
+}
+
+// ModifyWebhook : keep current secret when new secret omitted
+func (manager *WebhookManager) ModifyWebhook(id string, config WebhookConfig, respChan chan error) {
+	manager.commands <- webhookCommand{Type: webhookCmdModify, ID: id, Config: config, ErrorChan: respChan}
+}
+
+func (manager *WebhookManager) DeleteWebhook(id string, respChan chan error) {
+	manager.commands <- webhookCommand{Type: webhookCmdDelete, ID: id, ErrorChan: respChan}
+}
+
+// QueryDeadLetters : all dead letters when webhook is empty
+func (manager *WebhookManager) QueryDeadLetters(webhook string, respChan chan WebhookResult) {
+	manager.commands <- webhookCommand{Type: webhookCmdQueryDeadLetters, ID: webhook, ResultChan: respChan}
+}
+
+func (manager *WebhookManager) RedeliverDeadLetter(id string, respChan chan error) {
+	manager.commands <- webhookCommand{Type: webhookCmdRedeliver, ID: id, ErrorChan: respChan}
+}
+
+func (manager *WebhookManager) DeleteDeadLetter(id string, respChan chan error) {
+	manager.commands <- webhookCommand{Type: webhookCmdDeleteDeadLetter, ID: id, ErrorChan: respChan}
+}
+
+// ExportWebhookData : saved document for replication to standby core, data omitted when revision unchanged
+func (manager *WebhookManager) ExportWebhookData(revision uint64, respChan chan WebhookResult) {
+	manager.commands <- webhookCommand{Type: webhookCmdExport, Revision: revision, ResultChan: respChan}
+}
+
+func (manager *WebhookManager) routine(c framework.RoutineController) {
+	const (
+		checkInterval = time.Second
+	)
+	log.Printf("<webhook> started, %d webhook(s) available", len(manager.webhooks))
+	var checkTicker = time.NewTicker(checkInterval)
+	manager.subscribe()
+	for !c.IsStopping() {
+		var incoming chan ClusterEvent
+		if nil != manager.subscription {
+			incoming = manager.subscription.Events
+		}
+		select {
+		case <-c.GetNotifyChannel():
+			c.SetStopping()
+		case event, ok := <-incoming:
+			if !ok {
+				//resubscribe on next check
+				log.Println("<webhook> event subscription closed by hub")
+				manager.subscription = nil
+				break
+			}
+			manager.cursor = event.ID
+			manager.dispatchEvent(event)
+		case result := <-manager.results:
+			manager.onDeliveryFinished(result)
+		case <-checkTicker.C:
+			if nil == manager.subscription {
+				manager.subscribe()
+			}
+			manager.startDeliveries()
+			if manager.pendingDirty {
+				if err := manager.saveData(); err != nil {
+					log.Printf("<webhook> save pending deliveries fail: %s", err.Error())
+				}
+			}
+		case cmd := <-manager.commands:
+			manager.handleCommand(cmd)
+		}
+	}
+	checkTicker.Stop()
+	if nil != manager.subscription {
+		manager.events.UnsubscribeEvents(manager.subscription.ID)
+	}
+	if manager.pendingDirty {
+		if err := manager.saveData(); err != nil {
+			log.Printf("<webhook> save pending deliveries fail: %s", err.Error())
+		}
+	}
+	if 0 != len(manager.deliveries) {
+		log.Printf("<webhook> %d undelivered event(s) saved", len(manager.deliveries))
+	}
+	if err := manager.metadata.Close(); err != nil {
+		log.Printf("<webhook> close metadata store fail: %s", err.Error())
+	}
+	c.NotifyExit()
+	log.Println("<webhook> stopped")
+}
+
+func (manager *WebhookManager) subscribe() {
+	var respChan = make(chan EventResult, 1)
+	manager.events.SubscribeEvents(EventFilter{}, manager.cursor, respChan)
+	var result = <-respChan
+	if result.Error != nil {
+		log.Printf("<webhook> subscribe events fail: %s", result.Error.Error())
+		return
+	}
+	if result.Lost {
+		log.Printf("<webhook> warning: events after '%s' lost", manager.cursor)
+	}
+	manager.subscription = &result.Subscription
+	for _, event := range result.Backlog {
+		manager.cursor = event.ID
+		manager.dispatchEvent(event)
+	}
+}
+
+func (manager *WebhookManager) handleCommand(cmd webhookCommand) {
+	var err error
+	switch cmd.Type {
+	case webhookCmdQuery:
+		err = manager.handleQueryWebhooks(cmd.ResultChan)
+	case webhookCmdGet:
+		err = manager.handleGetWebhook(cmd.ID, cmd.ResultChan)
+	case webhookCmdCreate:
+		err = manager.handleCreateWebhook(cmd.Config, cmd.ResultChan)
+	case webhookCmdModify:
+		err = manager.handleModifyWebhook(cmd.ID, cmd.Config, cmd.ErrorChan)
+	case webhookCmdDelete:
+		err = manager.handleDeleteWebhook(cmd.ID, cmd.ErrorChan)
+	case webhookCmdQueryDeadLetters:
+		err = manager.handleQueryDeadLetters(cmd.ID, cmd.ResultChan)
+	case webhookCmdRedeliver:
+		err = manager.handleRedeliverDeadLetter(cmd.ID, cmd.ErrorChan)
+	case webhookCmdDeleteDeadLetter:
+		err = manager.handleDeleteDeadLetter(cmd.ID, cmd.ErrorChan)
+	case webhookCmdExport:
+		err = manager.handleExportWebhookData(cmd.Revision, cmd.ResultChan)
+	default:
+		log.Printf("<webhook> unsupported command type %d", cmd.Type)
+		return
+	}
+	if err != nil {
+		log.Printf("<webhook> handle command %d fail: %s", cmd.Type, err.Error())
+	}
+}
+
+func (manager *WebhookManager) handleQueryWebhooks(respChan chan WebhookResult) (err error) {
+	var idList []string
+	for id := range manager.webhooks {
+		idList = append(idList, id)
+	}
+	sort.Strings(idList)
+	var webhooks = make([]Webhook, 0)
+	for _, id := range idList {
+		var webhook = manager.webhooks[id]
+		webhook.Secret = ""
+		webhooks = append(webhooks, webhook)
+	}
+	respChan <- WebhookResult{WebhookList: webhooks}
+	return nil
+}
+
+func (manager *WebhookManager) handleGetWebhook(id string, respChan chan WebhookResult) (err error) {
+	webhook, exists := manager.webhooks[id]
+	if !exists {
+		err = fmt.Errorf("invalid webhook '%s'", id)
+		respChan <- WebhookResult{Error: err}
+		return
+	}
+	webhook.Secret = ""
+	respChan <- WebhookResult{Webhook: webhook}
+	return nil
+}
+
+func (manager *WebhookManager) handleCreateWebhook(config WebhookConfig, respChan chan WebhookResult) (err error) {
+	if err = validateWebhookConfig(config); err != nil {
+		respChan <- WebhookResult{Error: err}
+		return
+	}
+	if "" == config.Secret {
+		err = errors.New("secret required")
+		respChan <- WebhookResult{Error: err}
+		return
+	}
+	var newID = uuid.NewV4().String()
+	var now = time.Now().Format(TimeFormatLayout)
+	manager.webhooks[newID] = Webhook{ID: newID, WebhookConfig: config, CreatedTime: now, ModifiedTime: now}
+	log.Printf("<webhook> new webhook '%s' created for '%s'", newID, config.URL)
+	respChan <- WebhookResult{ID: newID}
+	return manager.saveData()
+}
+
+func (manager *WebhookManager) handleModifyWebhook(id string, config WebhookConfig, respChan chan error) (err error) {
+	webhook, exists := manager.webhooks[id]
+	if !exists {
+		err = fmt.Errorf("invalid webhook '%s'", id)
+		respChan <- err
+		return
+	}
+	if err = validateWebhookConfig(config); err != nil {
+		respChan <- err
+		return
+	}
+	if "" == config.Secret {
+		config.Secret = webhook.Secret
+	}
+	webhook.WebhookConfig = config
+	webhook.ModifiedTime = time.Now().Format(TimeFormatLayout)
+	manager.webhooks[id] = webhook
+	log.Printf("<webhook> webhook '%s' modified", id)
+	respChan <- nil
+	return manager.saveData()
+}
+
+func (manager *WebhookManager) handleDeleteWebhook(id string, respChan chan error) (err error) {
+	if _, exists := manager.webhooks[id]; !exists {
+		err = fmt.Errorf("invalid webhook '%s'", id)
+		respChan <- err
+		return
+	}
+	delete(manager.webhooks, id)
+	for deliveryID, delivery := range manager.deliveries {
+		if id == delivery.Webhook && !delivery.Sending {
+			delete(manager.deliveries, deliveryID)
+			manager.pendingDirty = true
+		}
+	}
+	var remain []WebhookDeadLetter
+	for _, letter := range manager.deadLetters {
+		if id != letter.Webhook {
+			remain = append(remain, letter)
+		}
+	}
+	manager.deadLetters = remain
+	log.Printf("<webhook> webhook '%s' deleted", id)
+	respChan <- nil
+	return manager.saveData()
+}
+
+func (manager *WebhookManager) handleQueryDeadLetters(webhook string, respChan chan WebhookResult) (err error) {
+	var letters = make([]WebhookDeadLetter, 0)
+	for _, letter := range manager.deadLetters {
+		if "" == webhook || webhook == letter.Webhook {
+			letters = append(letters, letter)
+		}
+	}
+	respChan <- WebhookResult{DeadLetters: letters}
+	return nil
+}
+
+func (manager *WebhookManager) handleRedeliverDeadLetter(id string, respChan chan error) (err error) {
+	for index, letter := range manager.deadLetters {
+		if id != letter.ID {
+			continue
+		}
+		if _, exists := manager.webhooks[letter.Webhook]; !exists {
+			err = fmt.Errorf("webhook '%s' of dead letter not available", letter.Webhook)
+			respChan <- err
+			return
+		}
+		manager.deadLetters = append(manager.deadLetters[:index], manager.deadLetters[index+1:]...)
+		manager.queueDelivery(letter.Webhook, letter.Event)
+		log.Printf("<webhook> dead letter '%s' queued for redelivery", id)
+		respChan <- nil
+		return manager.saveData()
+	}
+	err = fmt.Errorf("invalid dead letter '%s'", id)
+	respChan <- err
+	return
+}
+
+func (manager *WebhookManager) handleDeleteDeadLetter(id string, respChan chan error) (err error) {
+	for index, letter := range manager.deadLetters {
+		if id != letter.ID {
+			continue
+		}
+		manager.deadLetters = append(manager.deadLetters[:index], manager.deadLetters[index+1:]...)
+		respChan <- nil
+		return manager.saveData()
+	}
+	err = fmt.Errorf("invalid dead letter '%s'", id)
+	respChan <- err
+	return
+}
+
+func validateWebhookConfig(config WebhookConfig) (err error) {
+	var target *url.URL
+	if target, err = url.Parse(config.URL); err != nil {
+		err = fmt.Errorf("invalid url '%s'", config.URL)
+		return
+	}
+	if ("http" != target.Scheme && "https" != target.Scheme) || "" == target.Host {
+		err = fmt.Errorf("invalid url '%s'", config.URL)
+		return
+	}
+	var host = target.Hostname()
+	var addresses []net.IP
+	if ip := net.ParseIP(host); nil != ip {
+		addresses = append(addresses, ip)
+	} else {
+		//unresolved host left to check when connecting
+		var ctx, cancel = context.WithTimeout(context.Background(), webhookResolveTimeout)
+		resolved, _ := net.DefaultResolver.LookupIPAddr(ctx, host)
+		cancel()
+		for _, address := range resolved {
+			addresses = append(addresses, address.IP)
+		}
+	}
+	for _, ip := range addresses {
+		if !webhookAddressAllowed(ip) {
+			err = fmt.Errorf("address %s of webhook host '%s' not allowed", ip, host)
+			return
+		}
+	}
+	for _, eventType := range config.Events {
+		if !clusterEventTypes[eventType] {
+			err = fmt.Errorf("invalid event type '%s'", eventType)
+			return
+		}
+	}
+	return nil
+}
+
+// webhookAddressAllowed : loopback, link-local, private and other non-public addresses
+// only allowed when covered by allowed networks in config
+func webhookAddressAllowed(ip net.IP) bool {
+	for _, network := range GetConfigurator().GetWebhookAllowedNetworks() {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() || ip.IsPrivate() || ip.IsUnspecified() {
+		return false
+	}
+	return true
+}
+
+// controlWebhookConnection : reject connection to address resolved when dialing
+func controlWebhookConnection(network, address string, conn syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	var ip = net.ParseIP(host)
+	if nil == ip {
+		return fmt.Errorf("invalid webhook address '%s'", address)
+	}
+	if !webhookAddressAllowed(ip) {
+		return fmt.Errorf("webhook address %s not allowed", ip)
+	}
+	return nil
+}
+
+func (webhook Webhook) accept(event ClusterEvent) bool {
+	if !webhook.Enabled {
+		return false
+	}
+	if "" != webhook.Pool && webhook.Pool != event.Pool {
+		return false
+	}
+	if 0 == len(webhook.Events) {
+		return true
+	}
+	for _, eventType := range webhook.Events {
+		if eventType == event.Type {
+			return true
+		}
+	}
+	return false
+}
+
+func (manager *WebhookManager) dispatchEvent(event ClusterEvent) {
+	for id, webhook := range manager.webhooks {
+		if webhook.accept(event) {
+			manager.queueDelivery(id, event)
+		}
+	}
+	manager.startDeliveries()
+}
+
+func (manager *WebhookManager) queueDelivery(webhook string, event ClusterEvent) {
+	if len(manager.deliveries) >= webhookPendingLimit {
+		log.Printf("<webhook> warning: delivery queue full, event '%s' to webhook '%s' moved to dead letters", event.ID, webhook)
+		manager.addDeadLetter(webhookDelivery{Webhook: webhook, Event: event, LastError: "delivery queue full"})
+		if err := manager.saveData(); err != nil {
+			log.Printf("<webhook> save dead letter fail: %s", err.Error())
+		}
+		return
+	}
+	manager.nextDelivery++
+	manager.deliveries[manager.nextDelivery] = webhookDelivery{Webhook: webhook, Event: event, NextTime: time.Now()}
+	manager.pendingDirty = true
+}
+
+func (manager *WebhookManager) startDeliveries() {
+	if manager.sending >= webhookConcurrentLimit {
+		return
+	}
+	var now = time.Now()
+	var dueList []uint64
+	for deliveryID, delivery := range manager.deliveries {
+		if !delivery.Sending && !delivery.NextTime.After(now) {
+			dueList = append(dueList, deliveryID)
+		}
+	}
+	//earlier event first
+	sort.Slice(dueList, func(i, j int) bool {
+		return dueList[i] < dueList[j]
+	})
+	for _, deliveryID := range dueList {
+		if manager.sending >= webhookConcurrentLimit {
+			return
+		}
+		var delivery = manager.deliveries[deliveryID]
+		webhook, exists := manager.webhooks[delivery.Webhook]
+		if !exists {
+			delete(manager.deliveries, deliveryID)
+			manager.pendingDirty = true
+			continue
+		}
+		delivery.Sending = true
+		delivery.Attempts++
+		manager.deliveries[deliveryID] = delivery
+		manager.pendingDirty = true
+		manager.sending++
+		go manager.deliver(deliveryID, webhook, delivery.Event)
+	}
+}
+
+func (manager *WebhookManager) deliver(deliveryID uint64, webhook Webhook, event ClusterEvent) {
+	var err = postWebhook(&manager.client, webhook, event)
+	manager.results <- webhookDeliveryResult{Delivery: deliveryID, Error: err}
+}
+
+func (manager *WebhookManager) onDeliveryFinished(result webhookDeliveryResult) {
+	manager.sending--
+	delivery, exists := manager.deliveries[result.Delivery]
+	if !exists {
+		return
+	}
+	manager.pendingDirty = true
+	if nil == result.Error {
+		delete(manager.deliveries, result.Delivery)
+		return
+	}
+	delivery.Sending = false
+	delivery.LastError = result.Error.Error()
+	if _, exists = manager.webhooks[delivery.Webhook]; !exists {
+		delete(manager.deliveries, result.Delivery)
+		return
+	}
+	if delivery.Attempts >= webhookMaxAttempts {
+		delete(manager.deliveries, result.Delivery)
+		log.Printf("<webhook> deliver event '%s' to webhook '%s' fail after %d attempts: %s",
+			delivery.Event.ID, delivery.Webhook, delivery.Attempts, delivery.LastError)
+		manager.addDeadLetter(delivery)
+		if err := manager.saveData(); err != nil {
+			log.Printf("<webhook> save dead letter fail: %s", err.Error())
+		}
+		return
+	}
+	var backoff = webhookRetryBase << uint(delivery.Attempts-1)
+	if backoff > webhookRetryMax {
+		backoff = webhookRetryMax
+	}
+	delivery.NextTime = time.Now().Add(backoff)
+	manager.deliveries[result.Delivery] = delivery
+	log.Printf("<webhook> deliver event '%s' to webhook '%s' fail (%d/%d), retry after %s: %s",
+		delivery.Event.ID, delivery.Webhook, delivery.Attempts, webhookMaxAttempts, backoff, delivery.LastError)
+}
+
+func (manager *WebhookManager) addDeadLetter(delivery webhookDelivery) {
+	var letter = WebhookDeadLetter{
+		ID:          uuid.NewV4().String(),
+		Webhook:     delivery.Webhook,
+		Event:       delivery.Event,
+		Attempts:    delivery.Attempts,
+		LastError:   delivery.LastError,
+		LastAttempt: time.Now().Format(TimeFormatLayout),
+	}
+	manager.deadLetters = append(manager.deadLetters, letter)
+	if len(manager.deadLetters) > webhookDeadLetterLimit {
+		manager.deadLetters = manager.deadLetters[len(manager.deadLetters)-webhookDeadLetterLimit:]
+	}
+}
+
+// SignWebhookPayload : hex of HMAC-SHA256 on "<date>\n<body>" with webhook secret
+func SignWebhookPayload(secret, date string, body []byte) string {
+	var mac = hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(date))
+	mac.Write([]byte("\n"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func postWebhook(client *http.Client, webhook Webhook, event ClusterEvent) (err error) {
+	var body []byte
+	if body, err = json.Marshal(WebhookPayload{Webhook: webhook.ID, Event: event}); err != nil {
+		return
+	}
+	var request *http.Request
+	if request, err = http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(body)); err != nil {
+		return
+	}
+	var date = time.Now().UTC().Format(time.RFC3339)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderNameDate, date)
+	request.Header.Set(HeaderNameWebhook, webhook.ID)
+	request.Header.Set(HeaderNameEvent, event.ID)
+	request.Header.Set(HeaderNameWebhookSignature, fmt.Sprintf("%s %s", webhookSignatureMethod,
+		SignWebhookPayload(webhook.Secret, date, body)))
+	var response *http.Response
+	if response, err = client.Do(request); err != nil {
+		return
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		err = fmt.Errorf("unexpected status %d", response.StatusCode)
+		return
+	}
+	return nil
+}
+
+func (manager *WebhookManager) handleExportWebhookData(revision uint64, respChan chan WebhookResult) (err error) {
+	if revision == manager.revision {
+		respChan <- WebhookResult{Revision: revision}
+		return nil
+	}
+	var data []byte
+	if data, err = json.Marshal(manager.buildSavedData()); err != nil {
+		respChan <- WebhookResult{Error: err}
+		return
+	}
+	respChan <- WebhookResult{Data: data, Revision: manager.revision}
+	return nil
+}
+
+func (manager *WebhookManager) buildSavedData() (saved webhookSavedData) {
+	for _, webhook := range manager.webhooks {
+		saved.Webhooks = append(saved.Webhooks, webhook)
+	}
+	sort.Slice(saved.Webhooks, func(i, j int) bool {
+		return saved.Webhooks[i].ID < saved.Webhooks[j].ID
+	})
+	saved.DeadLetters = manager.deadLetters
+	var idList []uint64
+	for deliveryID := range manager.deliveries {
+		idList = append(idList, deliveryID)
+	}
+	sort.Slice(idList, func(i, j int) bool {
+		return idList[i] < idList[j]
+	})
+	for _, deliveryID := range idList {
+		var delivery = manager.deliveries[deliveryID]
+		saved.Pending = append(saved.Pending, webhookPendingDelivery{
+			Webhook:   delivery.Webhook,
+			Event:     delivery.Event,
+			Attempts:  delivery.Attempts,
+			LastError: delivery.LastError,
+		})
+	}
+	return
+}
+
+func (manager *WebhookManager) saveData() error {
+	manager.pendingDirty = false
+	manager.revision++
+	return manager.metadata.Save(manager.buildSavedData())
+}
+
+func (manager *WebhookManager) loadData() (err error) {
+	var saved webhookSavedData
+	var result store.LoadResult
+	if result, err = manager.metadata.Load(&saved); err != nil {
+		return
+	}
+	if !result.Exists {
+		return nil
+	}
+	for _, webhook := range saved.Webhooks {
+		manager.webhooks[webhook.ID] = webhook
+	}
+	manager.deadLetters = saved.DeadLetters
+	//retry immediately, previous attempts still counted
+	var now = time.Now()
+	for _, pending := range saved.Pending {
+		manager.nextDelivery++
+		manager.deliveries[manager.nextDelivery] = webhookDelivery{Webhook: pending.Webhook, Event: pending.Event,
+			Attempts: pending.Attempts, LastError: pending.LastError, NextTime: now}
+	}
+	log.Printf("<webhook> %d webhook(s), %d dead letter(s), %d pending delivery(s) loaded from '%s'",
+		len(saved.Webhooks), len(saved.DeadLetters), len(saved.Pending), manager.dataFile)
+	if result.Dirty {
+		return manager.metadata.Compact(saved)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package modules
+
+import (
+	"fmt"
+	"github.com/project-nano/core/store"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// webhookManagerForTest : deliver to loopback server, saved in temporary path
+func webhookManagerForTest(t *testing.T, url string) *WebhookManager {
+	metadata, err := store.Open(store.BackendJSON, filepath.Join(t.TempDir(), WebhookDataFilename))
+	if err != nil {
+		t.Fatalf("open metadata fail: %s", err.Error())
+	}
+	t.Cleanup(func() {
+		metadata.Close()
+	})
+	var webhook = Webhook{ID: "webhook", WebhookConfig: WebhookConfig{URL: url, Secret: "secret", Enabled: true}}
+	return &WebhookManager{
+		webhooks:   map[string]Webhook{webhook.ID: webhook},
+		deliveries: map[uint64]webhookDelivery{},
+		results:    make(chan webhookDeliveryResult, webhookConcurrentLimit),
+		metadata:   metadata,
+		revision:   1,
+	}
+}
+
+func TestWebhook_Signature(t *testing.T) {
+	var received = make(chan *http.Request, 1)
+	var payload = make(chan []byte, 1)
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received <- r
+		payload <- body
+	}))
+	defer server.Close()
+	var webhook = Webhook{ID: "webhook", WebhookConfig: WebhookConfig{URL: server.URL, Secret: "secret", Enabled: true}}
+	var event = ClusterEvent{ID: "event", Type: "instance.created", Pool: "default"}
+	if err := postWebhook(server.Client(), webhook, event); err != nil {
+		t.Fatalf("post webhook fail: %s", err.Error())
+	}
+	var r, body = <-received, <-payload
+	if r.Header.Get(HeaderNameWebhook) != webhook.ID || r.Header.Get(HeaderNameEvent) != event.ID {
+		t.Fatalf("unexpected webhook headers %v", r.Header)
+	}
+	var date = r.Header.Get(HeaderNameDate)
+	if _, err := time.Parse(time.RFC3339, date); err != nil {
+		t.Fatalf("invalid date '%s'", date)
+	}
+	var signature = r.Header.Get(HeaderNameWebhookSignature)
+	if !strings.HasPrefix(signature, webhookSignatureMethod+" ") {
+		t.Fatalf("unexpected signature method '%s'", signature)
+	}
+	signature = strings.TrimPrefix(signature, webhookSignatureMethod+" ")
+	var testCases = []struct {
+		Name   string
+		Secret string
+		Date   string
+		Body   []byte
+		Valid  bool
+	}{
+		{"signed payload", webhook.Secret, date, body, true},
+		{"other secret", "other", date, body, false},
+		{"replayed with other date", webhook.Secret, "2000-01-01T00:00:00Z", body, false},
+		{"body modified", webhook.Secret, date, append(body, ' '), false},
+	}
+	for _, testCase := range testCases {
+		var expected = SignWebhookPayload(testCase.Secret, testCase.Date, testCase.Body)
+		if (expected == signature) != testCase.Valid {
+			t.Fatalf("%s: unexpected verify result, signature '%s', computed '%s'", testCase.Name, signature, expected)
+		}
+	}
+}
+
+func TestWebhook_RetrySchedule(t *testing.T) {
+	var attempts int32
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	var manager = webhookManagerForTest(t, server.URL)
+	manager.queueDelivery("webhook", ClusterEvent{ID: "event", Type: "instance.created"})
+	var expected = []time.Duration{
+		time.Second * 5,
+		time.Second * 10,
+		time.Second * 20,
+		time.Second * 40,
+		time.Second * 80,
+	}
+	for attempt := 1; attempt <= webhookMaxAttempts; attempt++ {
+		manager.startDeliveries()
+		if 1 != manager.sending {
+			t.Fatalf("attempt %d: %d delivery(s) sending", attempt, manager.sending)
+		}
+		var finished = time.Now()
+		manager.onDeliveryFinished(<-manager.results)
+		if attempt == webhookMaxAttempts {
+			break
+		}
+		var delivery, exists = manager.deliveries[1]
+		if !exists || delivery.Attempts != attempt || delivery.Sending {
+			t.Fatalf("attempt %d: unexpected delivery %+v", attempt, delivery)
+		}
+		if !strings.Contains(delivery.LastError, "503") {
+			t.Fatalf("attempt %d: unexpected error '%s'", attempt, delivery.LastError)
+		}
+		var backoff = delivery.NextTime.Sub(finished)
+		if backoff < expected[attempt-1] || backoff > expected[attempt-1]+time.Second {
+			t.Fatalf("attempt %d: retry after %s, %s expected", attempt, backoff, expected[attempt-1])
+		}
+		//not due before backoff
+		manager.startDeliveries()
+		if 0 != manager.sending {
+			t.Fatalf("attempt %d: retried before backoff", attempt)
+		}
+		delivery.NextTime = time.Now()
+		manager.deliveries[1] = delivery
+	}
+	if sent := atomic.LoadInt32(&attempts); webhookMaxAttempts != sent {
+		t.Fatalf("%d request(s) sent, %d expected", sent, webhookMaxAttempts)
+	}
+	if 0 != len(manager.deliveries) || 1 != len(manager.deadLetters) {
+		t.Fatalf("%d delivery(s), %d dead letter(s) left", len(manager.deliveries), len(manager.deadLetters))
+	}
+	var letter = manager.deadLetters[0]
+	if "webhook" != letter.Webhook || "event" != letter.Event.ID || webhookMaxAttempts != letter.Attempts {
+		t.Fatalf("unexpected dead letter %+v", letter)
+	}
+}
+
+func TestWebhook_DeadLetterLimit(t *testing.T) {
+	const (
+		overflow = 5
+	)
+	var manager = webhookManagerForTest(t, "http://localhost")
+	for index := 0; index < webhookDeadLetterLimit+overflow; index++ {
+		manager.addDeadLetter(webhookDelivery{Webhook: "webhook", Event: ClusterEvent{ID: fmt.Sprintf("event-%d", index)}})
+	}
+	if webhookDeadLetterLimit != len(manager.deadLetters) {
+		t.Fatalf("%d dead letters kept, limit %d", len(manager.deadLetters), webhookDeadLetterLimit)
+	}
+	//oldest dropped
+	var first, last = manager.deadLetters[0].Event.ID, manager.deadLetters[webhookDeadLetterLimit-1].Event.ID
+	if fmt.Sprintf("event-%d", overflow) != first || fmt.Sprintf("event-%d", webhookDeadLetterLimit+overflow-1) != last {
+		t.Fatalf("unexpected dead letters from '%s' to '%s'", first, last)
+	}
+	var respChan = make(chan error, 1)
+	if err := manager.handleRedeliverDeadLetter(manager.deadLetters[0].ID, respChan); err != nil || nil != <-respChan {
+		t.Fatalf("redeliver dead letter fail: %v", err)
+	}
+	if webhookDeadLetterLimit-1 != len(manager.deadLetters) || 1 != len(manager.deliveries) {
+		t.Fatalf("%d dead letter(s), %d delivery(s) after redeliver", len(manager.deadLetters), len(manager.deliveries))
+	}
+}
+
+func TestWebhook_AddressAllowed(t *testing.T) {
+	defer GetConfigurator().SetWebhookAllowedNetworks(nil)
+	var testCases = []struct {
+		Name     string
+		Address  string
+		Networks []string
+		Allowed  bool
+	}{
+		{"public", "8.8.8.8", nil, true},
+		{"public ipv6", "2001:4860:4860::8888", nil, true},
+		{"loopback", "127.0.0.1", nil, false},
+		{"loopback ipv6", "::1", nil, false},
+		{"private", "10.1.2.3", nil, false},
+		{"private ipv6", "fd00::1", nil, false},
+		{"link local", "169.254.169.254", nil, false},
+		{"multicast", "224.0.0.1", nil, false},
+		{"unspecified", "0.0.0.0", nil, false},
+		{"private in allowed network", "10.1.2.3", []string{"10.1.0.0/16"}, true},
+		{"private outside allowed network", "10.2.2.3", []string{"10.1.0.0/16"}, false},
+		{"loopback in allowed network", "127.0.0.1", []string{"127.0.0.0/8"}, true},
+	}
+	for _, testCase := range testCases {
+		if err := GetConfigurator().SetWebhookAllowedNetworks(testCase.Networks); err != nil {
+			t.Fatalf("%s: set allowed networks fail: %s", testCase.Name, err.Error())
+		}
+		if allowed := webhookAddressAllowed(net.ParseIP(testCase.Address)); allowed != testCase.Allowed {
+			t.Fatalf("%s: allowed %t, %t expected", testCase.Name, allowed, testCase.Allowed)
+		}
+		var config = WebhookConfig{URL: fmt.Sprintf("http://%s/hook", net.JoinHostPort(testCase.Address, "80"))}
+		if err := validateWebhookConfig(config); (nil == err) != testCase.Allowed {
+			t.Fatalf("%s: unexpected validate result %v", testCase.Name, err)
+		}
+	}
+	//checked again when connecting
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	GetConfigurator().SetWebhookAllowedNetworks(nil)
+	var dialer = net.Dialer{Timeout: webhookRequestTimeout, Control: controlWebhookConnection}
+	var client = &http.Client{Transport: &http.Transport{DialContext: dialer.DialContext}}
+	var webhook = Webhook{ID: "webhook", WebhookConfig: WebhookConfig{URL: server.URL, Enabled: true}}
+	if err := postWebhook(client, webhook, ClusterEvent{ID: "event"}); err == nil {
+		t.Fatalf("webhook posted to loopback address")
+	}
+	GetConfigurator().SetWebhookAllowedNetworks([]string{"127.0.0.0/8"})
+	if err := postWebhook(client, webhook, ClusterEvent{ID: "event"}); err != nil {
+		t.Fatalf("post to allowed network fail: %s", err.Error())
+	}
+}
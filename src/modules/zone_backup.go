@@ -0,0 +1,391 @@
+package modules
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+const (
+	ZoneBackupVersion           = 2
+	zoneBackupCredentialVersion = 1 //signed with key of API credential, only verified before key rotated
+	zoneBackupKeyContext        = "nano zone backup"
+	zoneBackupKeyIDLength       = 8
+)
+
+const (
+	ZoneChangeAdd    = "add"
+	ZoneChangeRemove = "remove"
+	ZoneChangeModify = "modify"
+)
+
+// ZoneBackupData : configuration owned by core, instances and tasks excluded
+type ZoneBackupData struct {
+	Zone                string                       `json:"zone"`
+	Pools               []poolDefine                 `json:"compute_pools"`
+	StoragePools        []storageDefine              `json:"storage_pools"`
+	AddressPools        []addressPoolDefine          `json:"address_pools"`
+	SystemTemplates     []SystemTemplate             `json:"system_templates"`
+	SecurityPolicyGroup []managedSecurityPolicyGroup `json:"security_policy_groups"`
+}
+
+// ZoneBackup : versioned archive, signed with zone key identified by KeyID, Signer records credential who created it
+type ZoneBackup struct {
+	Version    int            `json:"version"`
+	CreateTime string         `json:"create_time"`
+	Signer     string         `json:"signer"`
+	KeyID      string         `json:"key_id,omitempty"`
+	Signature  string         `json:"signature"`
+	Data       ZoneBackupData `json:"data"`
+}
+
+type ZoneRestoreChange struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+}
+
+type ZoneRestoreReport struct {
+	DryRun        bool                `json:"dry_run"`
+	Changes       []ZoneRestoreChange `json:"changes"`
+	resynchronize map[string]string   //connected cell => address, notified to join restored pool
+}
+
+// loadZoneBackupKey : derived from seal key, so standby and restored host holding the same credential.key verify backups
+func loadZoneBackupKey(sealFile string) (key []byte, err error) {
+	var sealKey []byte
+	if sealKey, err = readCredentialSealKey(sealFile); err != nil {
+		err = fmt.Errorf("load zone backup key fail: %s", err.Error())
+		return
+	}
+	var mac = hmac.New(sha256.New, sealKey)
+	mac.Write([]byte(zoneBackupKeyContext))
+	return mac.Sum(nil), nil
+}
+
+func zoneBackupKeyID(key []byte) string {
+	var digest = sha256.Sum256(key)
+	return hex.EncodeToString(digest[:zoneBackupKeyIDLength])
+}
+
+func (backup *ZoneBackup) computeSignature(key []byte) (signature string, err error) {
+	var data []byte
+	if data, err = json.Marshal(backup.Data); err != nil {
+		return
+	}
+	var mac = hmac.New(sha256.New, key)
+	mac.Write([]byte(fmt.Sprintf("%d\n%s\n%s\n", backup.Version, backup.CreateTime, backup.Signer)))
+	if zoneBackupCredentialVersion != backup.Version {
+		mac.Write([]byte(fmt.Sprintf("%s\n", backup.KeyID)))
+	}
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (backup *ZoneBackup) Sign(signer string, key []byte) (err error) {
+	backup.Version = ZoneBackupVersion
+	backup.CreateTime = time.Now().Format(TimeFormatLayout)
+	backup.Signer = signer
+	backup.KeyID = zoneBackupKeyID(key)
+	backup.Signature, err = backup.computeSignature(key)
+	return
+}
+
+// Verify : key of zone for current version, secret of signer for backups in credential version
+func (backup *ZoneBackup) Verify(key []byte) (err error) {
+	switch backup.Version {
+	case ZoneBackupVersion:
+		if current := zoneBackupKeyID(key); current != backup.KeyID {
+			return fmt.Errorf("backup signed by zone key '%s' instead of current key '%s', restore with the %s used when created",
+				backup.KeyID, current, CredentialSealFilename)
+		}
+	case zoneBackupCredentialVersion:
+	default:
+		return fmt.Errorf("unsupported backup version %d", backup.Version)
+	}
+	var expected string
+	if expected, err = backup.computeSignature(key); err != nil {
+		return
+	}
+	if !hmac.Equal([]byte(expected), []byte(backup.Signature)) {
+		return errors.New("backup signature corrupted")
+	}
+	return nil
+}
+
+func (manager *ResourceManager) buildZoneBackupData() (data ZoneBackupData, err error) {
+	var config ResourceData
+	if config, err = manager.buildResourceData(); err != nil {
+		return
+	}
+	data = ZoneBackupData{
+		Zone:                config.Zone,
+		Pools:               config.Pools,
+		StoragePools:        config.StoragePools,
+		AddressPools:        config.AddressPools,
+		SystemTemplates:     config.SystemTemplates,
+		SecurityPolicyGroup: config.SecurityPolicyGroup,
+	}
+	return data, nil
+}
+
+func (manager *ResourceManager) handleExportZoneBackup(respChan chan ResourceResult) (err error) {
+	var data ZoneBackupData
+	if data, err = manager.buildZoneBackupData(); err != nil {
+		respChan <- ResourceResult{Error: err}
+		return
+	}
+	respChan <- ResourceResult{ZoneBackup: ZoneBackup{Data: data}}
+	return nil
+}
+
+// handleRestoreZoneBackup : replace configuration with backup, only allowed when no instance in zone
+func (manager *ResourceManager) handleRestoreZoneBackup(data ZoneBackupData, dryRun bool, respChan chan ResourceResult) (err error) {
+	if err = verifyZoneBackupData(data); err != nil {
+		respChan <- ResourceResult{Error: err}
+		return
+	}
+	var current ZoneBackupData
+	if current, err = manager.buildZoneBackupData(); err != nil {
+		respChan <- ResourceResult{Error: err}
+		return
+	}
+	var report = ZoneRestoreReport{DryRun: dryRun, Changes: diffZoneBackupData(current, data)}
+	if dryRun {
+		respChan <- ResourceResult{ZoneRestore: report}
+		return nil
+	}
+	if 0 != len(manager.instances) {
+		err = fmt.Errorf("%d instance(s) in zone, restore only available on core without instance", len(manager.instances))
+		respChan <- ResourceResult{Error: err}
+		return
+	}
+	for id, migration := range manager.migrations {
+		if !migration.Finished && nil == migration.Error {
+			err = fmt.Errorf("migration '%s' in progress", id)
+			respChan <- ResourceResult{Error: err}
+			return
+		}
+	}
+	//load into another manager first, so current configuration kept when backup invalid
+	var restored = ResourceManager{}
+	restored.initialZoneConfig()
+	var config = ResourceData{
+		Zone:                data.Zone,
+		Pools:               data.Pools,
+		StoragePools:        data.StoragePools,
+		AddressPools:        data.AddressPools,
+		SystemTemplates:     data.SystemTemplates,
+		SecurityPolicyGroup: data.SecurityPolicyGroup,
+	}
+	if _, err = restored.loadZoneConfig(config); err != nil {
+		err = fmt.Errorf("load backup fail: %s", err.Error())
+		respChan <- ResourceResult{Error: err}
+		return
+	}
+	report.resynchronize = map[string]string{}
+	for cellName, previous := range manager.cells {
+		cell, exists := restored.cells[cellName]
+		if !exists {
+			if previous.Alive {
+				//still connected
+				previous.Pool = ""
+				restored.cells[cellName] = previous
+				restored.unallocatedCells[cellName] = true
+			}
+			continue
+		}
+		cell.Address = previous.Address
+		cell.Alive = previous.Alive
+		cell.LatestUpdate = previous.LatestUpdate
+		cell.ResourceUsage = previous.ResourceUsage
+		restored.cells[cellName] = cell
+		if cell.Alive {
+			report.resynchronize[cellName] = cell.Address
+		}
+	}
+	manager.pools = restored.pools
+	manager.cells = restored.cells
+	manager.unallocatedCells = restored.unallocatedCells
+	manager.storagePools = restored.storagePools
+	manager.addressPools = restored.addressPools
+	manager.templates = restored.templates
+	manager.allTemplateID = restored.allTemplateID
+	manager.policyGroups = restored.policyGroups
+	manager.policyGroupNames = restored.policyGroupNames
+	manager.sortedPolicyGroupID = restored.sortedPolicyGroupID
+	manager.maintenances = restored.maintenances
+	manager.rebalances = restored.rebalances
+	manager.failoverCapacity = restored.failoverCapacity
+	manager.zone.Name = data.Zone
+	log.Printf("<resource_manager> zone '%s' restored with %d change(s), %d compute/ %d storage/ %d address pool(s), %d cell(s)",
+		data.Zone, len(report.Changes), len(manager.pools), len(manager.storagePools), len(manager.addressPools), len(manager.cells))
+	respChan <- ResourceResult{ZoneRestore: report}
+	return manager.compactConfig()
+}
+
+// initialZoneConfig : allocate containers loaded by loadZoneConfig and states depend on them
+func (manager *ResourceManager) initialZoneConfig() {
+	manager.pools = map[string]ManagedComputePool{}
+	manager.cells = map[string]ManagedComputeCell{}
+	manager.unallocatedCells = map[string]bool{}
+	manager.storagePools = map[string]StoragePoolInfo{}
+	manager.addressPools = map[string]ManagedAddressPool{}
+	manager.templates = map[string]SystemTemplate{}
+	manager.allTemplateID = nil
+	manager.policyGroups = map[string]managedSecurityPolicyGroup{}
+	manager.policyGroupNames = map[string]bool{}
+	manager.sortedPolicyGroupID = nil
+	manager.maintenances = map[string]CellMaintenanceStatus{}
+	manager.rebalances = map[string]poolRebalanceTask{}
+	manager.failoverCapacity = map[string]FailoverCapacityStatus{}
+}
+
+func verifyZoneBackupData(data ZoneBackupData) error {
+	if "" == data.Zone {
+		return errors.New("zone name required")
+	}
+	var storagePools, addressPools = map[string]bool{}, map[string]bool{}
+	for _, pool := range data.StoragePools {
+		if storagePools[pool.Name] {
+			return fmt.Errorf("duplicate storage pool '%s'", pool.Name)
+		}
+		storagePools[pool.Name] = true
+	}
+	for _, pool := range data.AddressPools {
+		if addressPools[pool.Name] {
+			return fmt.Errorf("duplicate address pool '%s'", pool.Name)
+		}
+		addressPools[pool.Name] = true
+	}
+	var poolNames = map[string]bool{}
+	var cellPool = map[string]string{}
+	for _, pool := range data.Pools {
+		if poolNames[pool.Name] {
+			return fmt.Errorf("duplicate compute pool '%s'", pool.Name)
+		}
+		poolNames[pool.Name] = true
+		if "" != pool.Storage && !storagePools[pool.Storage] {
+			return fmt.Errorf("compute pool '%s' refers to invalid storage pool '%s'", pool.Name, pool.Storage)
+		}
+		if "" != pool.Network && !addressPools[pool.Network] {
+			return fmt.Errorf("compute pool '%s' refers to invalid address pool '%s'", pool.Name, pool.Network)
+		}
+		for cellName := range pool.Cells {
+			if previous, exists := cellPool[cellName]; exists {
+				return fmt.Errorf("cell '%s' belongs to both pool '%s' and '%s'", cellName, previous, pool.Name)
+			}
+			cellPool[cellName] = pool.Name
+		}
+	}
+	var policyNames = map[string]bool{}
+	for _, group := range data.SecurityPolicyGroup {
+		if policyNames[group.Name] {
+			return fmt.Errorf("duplicate security policy group name '%s'", group.Name)
+		}
+		policyNames[group.Name] = true
+	}
+	return nil
+}
+
+// diffZoneBackupData : changes required to turn current configuration into target
+func diffZoneBackupData(current, target ZoneBackupData) (changes []ZoneRestoreChange) {
+	changes = []ZoneRestoreChange{}
+	if current.Zone != target.Zone {
+		changes = append(changes, ZoneRestoreChange{Type: "zone", Name: target.Zone, Action: ZoneChangeModify})
+	}
+	var indexPools = func(data ZoneBackupData) (pools, cells map[string]interface{}) {
+		pools, cells = map[string]interface{}{}, map[string]interface{}{}
+		for _, pool := range data.Pools {
+			var cellNames = pool.Cells
+			pool.Cells = nil
+			pools[pool.Name] = pool
+			for cellName, cell := range cellNames {
+				cells[cellName] = struct {
+					Pool string
+					cellDefine
+				}{pool.Name, cell}
+			}
+		}
+		return
+	}
+	var currentPools, currentCells = indexPools(current)
+	var targetPools, targetCells = indexPools(target)
+	changes = append(changes, diffZoneSection("compute_pool", currentPools, targetPools)...)
+	changes = append(changes, diffZoneSection("cell", currentCells, targetCells)...)
+
+	var currentStorage, targetStorage = map[string]interface{}{}, map[string]interface{}{}
+	for _, pool := range current.StoragePools {
+		currentStorage[pool.Name] = pool
+	}
+	for _, pool := range target.StoragePools {
+		targetStorage[pool.Name] = pool
+	}
+	changes = append(changes, diffZoneSection("storage_pool", currentStorage, targetStorage)...)
+
+	var currentAddress, targetAddress = map[string]interface{}{}, map[string]interface{}{}
+	for _, pool := range current.AddressPools {
+		currentAddress[pool.Name] = pool
+	}
+	for _, pool := range target.AddressPools {
+		targetAddress[pool.Name] = pool
+	}
+	changes = append(changes, diffZoneSection("address_pool", currentAddress, targetAddress)...)
+
+	var currentTemplates, targetTemplates = map[string]interface{}{}, map[string]interface{}{}
+	for _, template := range current.SystemTemplates {
+		currentTemplates[template.ID] = template
+	}
+	for _, template := range target.SystemTemplates {
+		targetTemplates[template.ID] = template
+	}
+	changes = append(changes, diffZoneSection("system_template", currentTemplates, targetTemplates)...)
+
+	var currentPolicies, targetPolicies = map[string]interface{}{}, map[string]interface{}{}
+	for _, group := range current.SecurityPolicyGroup {
+		currentPolicies[group.ID] = group
+	}
+	for _, group := range target.SecurityPolicyGroup {
+		targetPolicies[group.ID] = group
+	}
+	changes = append(changes, diffZoneSection("security_policy_group", currentPolicies, targetPolicies)...)
+	return
+}
+
+func diffZoneSection(sectionType string, current, target map[string]interface{}) (changes []ZoneRestoreChange) {
+	var names []string
+	for name := range current {
+		names = append(names, name)
+	}
+	for name := range target {
+		if _, exists := current[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		origin, inCurrent := current[name]
+		value, inTarget := target[name]
+		var change = ZoneRestoreChange{Type: sectionType, Name: name}
+		if !inCurrent {
+			change.Action = ZoneChangeAdd
+		} else if !inTarget {
+			change.Action = ZoneChangeRemove
+		} else {
+			originData, _ := json.Marshal(origin)
+			targetData, _ := json.Marshal(value)
+			if string(originData) == string(targetData) {
+				continue
+			}
+			change.Action = ZoneChangeModify
+		}
+		changes = append(changes, change)
+	}
+	return
+}
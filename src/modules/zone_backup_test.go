@@ -0,0 +1,169 @@
+package modules
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// zoneBackupResource : export configured zone, restored data recorded
+type zoneBackupResource struct {
+	ResourceModule
+	zone     ZoneBackupData
+	restored []ZoneBackupData
+}
+
+func (resource *zoneBackupResource) ExportZoneBackup(respChan chan ResourceResult) {
+	respChan <- ResourceResult{ZoneBackup: ZoneBackup{Data: resource.zone}}
+}
+
+func (resource *zoneBackupResource) RestoreZoneBackup(data ZoneBackupData, dryRun bool, respChan chan ResourceResult) {
+	resource.restored = append(resource.restored, data)
+	respChan <- ResourceResult{ZoneRestore: ZoneRestoreReport{DryRun: dryRun, Changes: []ZoneRestoreChange{}}}
+}
+
+// zoneBackupKeyForTest : key derived from seal key generated in config path when absent
+func zoneBackupKeyForTest(t *testing.T, configPath string) []byte {
+	var sealFile = filepath.Join(configPath, CredentialSealFilename)
+	if _, err := loadCredentialSealer(sealFile); err != nil {
+		t.Fatalf("load seal key fail: %s", err.Error())
+	}
+	key, err := loadZoneBackupKey(sealFile)
+	if err != nil {
+		t.Fatalf("load zone backup key fail: %s", err.Error())
+	}
+	return key
+}
+
+func zoneBackupDataForTest() ZoneBackupData {
+	return ZoneBackupData{
+		Zone:         "default",
+		Pools:        []poolDefine{{Name: "pool1", Storage: "nfs", Network: "internal"}},
+		StoragePools: []storageDefine{{Name: "nfs"}},
+		AddressPools: []addressPoolDefine{{AddressPoolConfig: AddressPoolConfig{Name: "internal"}}},
+	}
+}
+
+func TestZoneBackup_SignAndVerify(t *testing.T) {
+	var key = zoneBackupKeyForTest(t, t.TempDir())
+	var otherKey = zoneBackupKeyForTest(t, t.TempDir())
+	const legacySecret = "credential-secret"
+	var testCases = []struct {
+		Name   string
+		Legacy bool
+		Modify func(backup *ZoneBackup)
+		Key    []byte
+		Valid  bool
+	}{
+		{"signed by zone key", false, func(*ZoneBackup) {}, key, true},
+		{"zone renamed", false, func(backup *ZoneBackup) { backup.Data.Zone = "modified" }, key, false},
+		{"pool removed", false, func(backup *ZoneBackup) { backup.Data.Pools = nil }, key, false},
+		{"signer replaced", false, func(backup *ZoneBackup) { backup.Signer = "other" }, key, false},
+		{"create time replaced", false, func(backup *ZoneBackup) { backup.CreateTime = "2000-01-01 00:00:00" }, key, false},
+		{"key id replaced", false, func(backup *ZoneBackup) { backup.KeyID = zoneBackupKeyID(otherKey) }, key, false},
+		{"signed by other zone", false, func(*ZoneBackup) {}, otherKey, false},
+		{"unsupported version", false, func(backup *ZoneBackup) { backup.Version = ZoneBackupVersion + 1 }, key, false},
+		{"signed by credential", true, func(*ZoneBackup) {}, []byte(legacySecret), true},
+		{"credential rotated", true, func(*ZoneBackup) {}, []byte("rotated-secret"), false},
+		{"credential backup tampered", true, func(backup *ZoneBackup) { backup.Data.Zone = "modified" }, []byte(legacySecret), false},
+	}
+	for _, testCase := range testCases {
+		var backup = ZoneBackup{Data: zoneBackupDataForTest()}
+		var err error
+		if testCase.Legacy {
+			backup.Version = zoneBackupCredentialVersion
+			backup.CreateTime = "2026-01-01 00:00:00"
+			backup.Signer = "operator"
+			backup.Signature, err = backup.computeSignature([]byte(legacySecret))
+		} else {
+			err = backup.Sign("operator", key)
+		}
+		if err != nil {
+			t.Fatalf("%s: sign fail: %s", testCase.Name, err.Error())
+		}
+		//signature survives transfer
+		data, err := json.Marshal(backup)
+		if err != nil {
+			t.Fatalf("%s: marshal fail: %s", testCase.Name, err.Error())
+		}
+		var received ZoneBackup
+		if err = json.Unmarshal(data, &received); err != nil {
+			t.Fatalf("%s: unmarshal fail: %s", testCase.Name, err.Error())
+		}
+		testCase.Modify(&received)
+		err = received.Verify(testCase.Key)
+		if testCase.Valid && err != nil {
+			t.Fatalf("%s: verify fail: %s", testCase.Name, err.Error())
+		} else if !testCase.Valid && err == nil {
+			t.Fatalf("%s: invalid backup verified", testCase.Name)
+		}
+	}
+}
+
+func TestZoneBackup_Restore(t *testing.T) {
+	var activeConfig = t.TempDir()
+	var source = &zoneBackupResource{zone: zoneBackupDataForTest()}
+	var active = &APIModule{resource: source, backupKey: zoneBackupKeyForTest(t, activeConfig)}
+	var w = httptest.NewRecorder()
+	active.backupZone(w, verifiedAPIRequest(http.MethodGet, "/zone/backup", "operator", nil), nil)
+	var response struct {
+		ErrorCode int        `json:"error_code"`
+		Message   string     `json:"message"`
+		Data      ZoneBackup `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || 0 != response.ErrorCode {
+		t.Fatalf("backup zone fail: %v, %s", err, response.Message)
+	}
+	backup, err := json.Marshal(response.Data)
+	if err != nil {
+		t.Fatalf("marshal backup fail: %s", err.Error())
+	}
+	sealKey, err := ioutil.ReadFile(filepath.Join(activeConfig, CredentialSealFilename))
+	if err != nil {
+		t.Fatalf("read seal key fail: %s", err.Error())
+	}
+	var testCases = []struct {
+		Name     string
+		Prepare  func(configPath string)
+		Restorer string
+		Restored bool
+	}{
+		{"same host", func(string) {}, "operator", true},
+		{"rebuilt host with seal key", func(configPath string) {
+			ioutil.WriteFile(filepath.Join(configPath, CredentialSealFilename), sealKey, credentialSealFilePerm)
+		}, "admin", true},
+		{"host of other zone", func(string) {}, "operator", false},
+	}
+	for _, testCase := range testCases {
+		var key = active.backupKey
+		if "same host" != testCase.Name {
+			var configPath = t.TempDir()
+			testCase.Prepare(configPath)
+			key = zoneBackupKeyForTest(t, configPath)
+		}
+		var target = &zoneBackupResource{}
+		var module = &APIModule{resource: target, backupKey: key}
+		w = httptest.NewRecorder()
+		module.restoreZone(w, verifiedAPIRequest(http.MethodPost, "/zone/restore", testCase.Restorer, backup), nil)
+		var result Response
+		if err = json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("%s: parse response fail: %s", testCase.Name, err.Error())
+		}
+		if !testCase.Restored {
+			if 0 == result.ErrorCode || 0 != len(target.restored) {
+				t.Fatalf("%s: backup of other zone restored", testCase.Name)
+			}
+			continue
+		}
+		if 0 != result.ErrorCode {
+			t.Fatalf("%s: restore fail: %s", testCase.Name, result.Message)
+		}
+		if 1 != len(target.restored) || target.restored[0].Zone != source.zone.Zone ||
+			len(target.restored[0].Pools) != len(source.zone.Pools) {
+			t.Fatalf("%s: unexpected restored data %+v", testCase.Name, target.restored)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+module github.com/project-nano/core/store
+
+go 1.19
+
+require go.etcd.io/bbolt v1.3.7
+
+require golang.org/x/sys v0.12.0 // indirect
@@ -0,0 +1,331 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const (
+	journalCompactEntries = 200
+	journalFileSuffix     = ".journal"
+	snapshotTempSuffix    = ".tmp"
+	timeFormatLayout      = "2006-01-02 15:04:05"
+	//reserved section of snapshot, sequence of the latest journal entry compacted into it
+	journalSequenceSection = "journal_sequence"
+)
+
+// journalEntry : sections changed since previous entry, null section means removed
+type journalEntry struct {
+	Sequence uint64                     `json:"seq"`
+	Time     string                     `json:"time"`
+	Checksum uint32                     `json:"checksum"`
+	Sections map[string]json.RawMessage `json:"sections"`
+}
+
+// jsonStore : snapshot file written by atomic rename, plus an append-only journal
+// of changed sections, compacted into snapshot periodically
+type jsonStore struct {
+	dataFile    string
+	journalFile string
+	sections    map[string]json.RawMessage
+	sequence    uint64
+	entries     int
+	file        *os.File
+}
+
+// JournalReplay : sections recovered from snapshot and journal, Skipped are entries already compacted into snapshot
+type JournalReplay struct {
+	Sections  map[string]json.RawMessage
+	Sequence  uint64
+	Replayed  int
+	Skipped   int
+	Discarded int
+}
+
+func newJSONStore(dataFile string) *jsonStore {
+	return &jsonStore{
+		dataFile:    dataFile,
+		journalFile: dataFile + journalFileSuffix,
+		sections:    map[string]json.RawMessage{},
+	}
+}
+
+func checksumSections(sections map[string]json.RawMessage) (checksum uint32, err error) {
+	var data []byte
+	if data, err = json.Marshal(sections); err != nil {
+		return
+	}
+	return crc32.ChecksumIEEE(data), nil
+}
+
+// ReplayJournal : read snapshot then apply journal entries after its sequence, an incomplete or broken tail is discarded.
+// Entries not newer than snapshot are left by a crash before journal truncated, they are skipped.
+// Nothing modified, so it is safe to call on data of a running service
+func ReplayJournal(dataFile string) (result JournalReplay, err error) {
+	result.Sections = map[string]json.RawMessage{}
+	var data []byte
+	if data, err = ioutil.ReadFile(dataFile); err != nil {
+		err = fmt.Errorf("read snapshot '%s' fail: %s", dataFile, err.Error())
+		return
+	}
+	if err = json.Unmarshal(data, &result.Sections); err != nil {
+		err = fmt.Errorf("parse snapshot '%s' fail: %s", dataFile, err.Error())
+		return
+	}
+	if section, exists := result.Sections[journalSequenceSection]; exists {
+		if err = json.Unmarshal(section, &result.Sequence); err != nil {
+			err = fmt.Errorf("invalid journal sequence '%s' in snapshot '%s'", string(section), dataFile)
+			return
+		}
+		delete(result.Sections, journalSequenceSection)
+	}
+	var snapshotSequence = result.Sequence
+	var journalFile = dataFile + journalFileSuffix
+	if data, err = ioutil.ReadFile(journalFile); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		} else {
+			err = fmt.Errorf("read journal '%s' fail: %s", journalFile, err.Error())
+		}
+		return
+	}
+	var scanner = bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 1<<16), len(data)+1)
+	for scanner.Scan() {
+		var line = scanner.Bytes()
+		if 0 == len(bytes.TrimSpace(line)) {
+			continue
+		}
+		var entry journalEntry
+		if err = json.Unmarshal(line, &entry); err != nil {
+			log.Printf("<store> discard broken entry after sequence %d: %s", result.Sequence, err.Error())
+			result.Discarded++
+			err = nil
+			break
+		}
+		var checksum uint32
+		if checksum, err = checksumSections(entry.Sections); err != nil {
+			return
+		}
+		if checksum != entry.Checksum {
+			log.Printf("<store> discard entry %d due to checksum mismatch", entry.Sequence)
+			result.Discarded++
+			break
+		}
+		if entry.Sequence <= snapshotSequence {
+			result.Skipped++
+			continue
+		}
+		if entry.Sequence <= result.Sequence {
+			log.Printf("<store> discard entry %d out of sequence %d", entry.Sequence, result.Sequence)
+			result.Discarded++
+			break
+		}
+		for name, section := range entry.Sections {
+			if 0 == len(section) || "null" == string(section) {
+				delete(result.Sections, name)
+			} else {
+				result.Sections[name] = section
+			}
+		}
+		result.Sequence = entry.Sequence
+		result.Replayed++
+	}
+	return result, nil
+}
+
+func (store *jsonStore) Load(document interface{}) (result LoadResult, err error) {
+	if !fileExists(store.dataFile) {
+		return
+	}
+	var replay JournalReplay
+	if replay, err = ReplayJournal(store.dataFile); err != nil {
+		return
+	}
+	if err = mergeSections(replay.Sections, document); err != nil {
+		return
+	}
+	store.sections = replay.Sections
+	store.sequence = replay.Sequence
+	store.entries = replay.Replayed + replay.Skipped + replay.Discarded
+	result.Exists = true
+	result.Replayed = replay.Replayed
+	result.Skipped = replay.Skipped
+	result.Discarded = replay.Discarded
+	if 0 != store.entries {
+		result.Dirty = true
+		log.Printf("<store> %d entries replayed, %d skipped, %d discarded from '%s'",
+			replay.Replayed, replay.Skipped, replay.Discarded, store.journalFile)
+	}
+	return
+}
+
+// LoadSection : read from recovered sections, or from snapshot and journal when not loaded yet
+func (store *jsonStore) LoadSection(name string, value interface{}) (exists bool, err error) {
+	if 0 == len(store.sections) {
+		return loadJSONSection(store.dataFile, name, value)
+	}
+	var section json.RawMessage
+	if section, exists = store.sections[name]; !exists {
+		return
+	}
+	err = json.Unmarshal(section, value)
+	return
+}
+
+func (store *jsonStore) Exists() (bool, error) {
+	return fileExists(store.dataFile), nil
+}
+
+func loadJSONSection(dataFile, name string, value interface{}) (exists bool, err error) {
+	if !fileExists(dataFile) {
+		return
+	}
+	var replay JournalReplay
+	if replay, err = ReplayJournal(dataFile); err != nil {
+		return
+	}
+	var section json.RawMessage
+	if section, exists = replay.Sections[name]; !exists {
+		return
+	}
+	err = json.Unmarshal(section, value)
+	return
+}
+
+// Save : append changed sections to journal, compact when too many entries appended
+func (store *jsonStore) Save(document interface{}) (err error) {
+	if nil == store.file || store.entries >= journalCompactEntries {
+		return store.Compact(document)
+	}
+	var sections map[string]json.RawMessage
+	if sections, err = splitSections(document); err != nil {
+		return
+	}
+	var changed = changedSections(store.sections, sections)
+	if 0 == len(changed) {
+		return nil
+	}
+	for name, section := range changed {
+		if nil == section {
+			changed[name] = json.RawMessage("null")
+		}
+	}
+	var entry = journalEntry{
+		Sequence: store.sequence + 1,
+		Time:     time.Now().Format(timeFormatLayout),
+		Sections: changed,
+	}
+	if entry.Checksum, err = checksumSections(changed); err != nil {
+		return
+	}
+	var line []byte
+	if line, err = json.Marshal(entry); err != nil {
+		return
+	}
+	line = append(line, '\n')
+	if _, err = store.file.Write(line); err != nil {
+		err = fmt.Errorf("append journal fail: %s", err.Error())
+		return
+	}
+	if err = store.file.Sync(); err != nil {
+		err = fmt.Errorf("sync journal fail: %s", err.Error())
+		return
+	}
+	store.sections = sections
+	store.sequence = entry.Sequence
+	store.entries++
+	return nil
+}
+
+// Compact : write full snapshot atomically then truncate journal. Snapshot carries sequence of the latest entry,
+// so entries left when crashed before truncated are never replayed over it
+func (store *jsonStore) Compact(document interface{}) (err error) {
+	var sections map[string]json.RawMessage
+	if sections, err = splitSections(document); err != nil {
+		return
+	}
+	var snapshot = map[string]json.RawMessage{}
+	for name, section := range sections {
+		snapshot[name] = section
+	}
+	snapshot[journalSequenceSection] = json.RawMessage(strconv.FormatUint(store.sequence, 10))
+	var data []byte
+	if data, err = json.MarshalIndent(snapshot, "", " "); err != nil {
+		return
+	}
+	if err = writeFileAtomic(store.dataFile, data, DefaultPerm); err != nil {
+		return
+	}
+	if nil == store.file {
+		if store.file, err = os.OpenFile(store.journalFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, DefaultPerm); err != nil {
+			err = fmt.Errorf("open journal '%s' fail: %s", store.journalFile, err.Error())
+			return
+		}
+	}
+	if err = store.file.Truncate(0); err != nil {
+		err = fmt.Errorf("truncate journal fail: %s", err.Error())
+		return
+	}
+	if err = store.file.Sync(); err != nil {
+		return
+	}
+	store.sections = sections
+	store.entries = 0
+	return nil
+}
+
+func (store *jsonStore) Pending() int {
+	return store.entries
+}
+
+func (store *jsonStore) Close() (err error) {
+	if nil != store.file {
+		err = store.file.Close()
+		store.file = nil
+	}
+	return
+}
+
+// writeFileAtomic : write to temporary file then rename, so target is never half written
+func writeFileAtomic(filename string, data []byte, perm os.FileMode) (err error) {
+	var tempFile = filename + snapshotTempSuffix
+	var file *os.File
+	if file, err = os.OpenFile(tempFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm); err != nil {
+		return
+	}
+	if _, err = file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tempFile)
+		return
+	}
+	if err = file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tempFile)
+		return
+	}
+	if err = file.Close(); err != nil {
+		os.Remove(tempFile)
+		return
+	}
+	if err = os.Rename(tempFile, filename); err != nil {
+		os.Remove(tempFile)
+		return
+	}
+	//persist rename
+	var dir *os.File
+	if dir, err = os.Open(filepath.Dir(filename)); err != nil {
+		return
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
@@ -0,0 +1,169 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testDocument struct {
+	Name    string   `json:"name"`
+	Counter int      `json:"counter"`
+	Items   []string `json:"items,omitempty"`
+}
+
+func loadTestDocument(t *testing.T, dataFile string) (document testDocument, result LoadResult) {
+	var metadata = newJSONStore(dataFile)
+	defer metadata.Close()
+	var err error
+	if result, err = metadata.Load(&document); err != nil {
+		t.Fatalf("load '%s' fail: %s", dataFile, err.Error())
+	}
+	return
+}
+
+func TestJSONStore_SaveAndReplay(t *testing.T) {
+	var testCases = []struct {
+		Name      string
+		Documents []testDocument
+		Replayed  int
+	}{
+		{"first save compacted", []testDocument{{Name: "a", Counter: 1}}, 0},
+		{"changes journaled", []testDocument{{Name: "a", Counter: 1}, {Name: "a", Counter: 2}, {Name: "b", Counter: 2}}, 2},
+		{"unchanged not journaled", []testDocument{{Name: "a"}, {Name: "a"}, {Name: "a", Items: []string{"x"}}}, 1},
+		{"removed section", []testDocument{{Name: "a", Items: []string{"x"}}, {Name: "a"}}, 1},
+	}
+	for _, testCase := range testCases {
+		var dataFile = filepath.Join(t.TempDir(), "test.data")
+		var metadata = newJSONStore(dataFile)
+		for _, document := range testCase.Documents {
+			if err := metadata.Save(document); err != nil {
+				t.Fatalf("%s: save fail: %s", testCase.Name, err.Error())
+			}
+		}
+		metadata.Close()
+		document, result := loadTestDocument(t, dataFile)
+		var expected = testCase.Documents[len(testCase.Documents)-1]
+		if document.Name != expected.Name || document.Counter != expected.Counter || len(document.Items) != len(expected.Items) {
+			t.Fatalf("%s: unexpected document %+v, %+v expected", testCase.Name, document, expected)
+		}
+		if result.Replayed != testCase.Replayed {
+			t.Fatalf("%s: %d entries replayed, %d expected", testCase.Name, result.Replayed, testCase.Replayed)
+		}
+		if result.Dirty != (0 != testCase.Replayed) {
+			t.Fatalf("%s: unexpected dirty %t", testCase.Name, result.Dirty)
+		}
+	}
+}
+
+func TestJSONStore_DiscardBrokenTail(t *testing.T) {
+	var dataFile = filepath.Join(t.TempDir(), "test.data")
+	var metadata = newJSONStore(dataFile)
+	for counter := 1; counter <= 3; counter++ {
+		if err := metadata.Save(testDocument{Name: "a", Counter: counter}); err != nil {
+			t.Fatalf("save fail: %s", err.Error())
+		}
+	}
+	metadata.Close()
+	var file, err = os.OpenFile(dataFile+journalFileSuffix, os.O_WRONLY|os.O_APPEND, DefaultPerm)
+	if err != nil {
+		t.Fatalf("open journal fail: %s", err.Error())
+	}
+	//half written entry
+	file.WriteString(`{"seq":4,"time":"","checksum":1,"sec`)
+	file.Close()
+	document, result := loadTestDocument(t, dataFile)
+	if 3 != document.Counter {
+		t.Fatalf("counter %d after broken tail discarded, 3 expected", document.Counter)
+	}
+	if 2 != result.Replayed || 1 != result.Discarded {
+		t.Fatalf("%d replayed, %d discarded, 2 and 1 expected", result.Replayed, result.Discarded)
+	}
+}
+
+func TestJSONStore_Compact(t *testing.T) {
+	var dataFile = filepath.Join(t.TempDir(), "test.data")
+	var metadata = newJSONStore(dataFile)
+	defer metadata.Close()
+	for counter := 1; counter <= journalCompactEntries+2; counter++ {
+		if err := metadata.Save(testDocument{Name: "a", Counter: counter}); err != nil {
+			t.Fatalf("save %d fail: %s", counter, err.Error())
+		}
+	}
+	if metadata.Pending() >= journalCompactEntries {
+		t.Fatalf("%d entries pending, journal not compacted", metadata.Pending())
+	}
+	document, _ := loadTestDocument(t, dataFile)
+	if journalCompactEntries+2 != document.Counter {
+		t.Fatalf("counter %d after compacted, %d expected", document.Counter, journalCompactEntries+2)
+	}
+	var data, err = os.ReadFile(dataFile)
+	if err != nil {
+		t.Fatalf("read snapshot fail: %s", err.Error())
+	}
+	var sections map[string]json.RawMessage
+	if err = json.Unmarshal(data, &sections); err != nil {
+		t.Fatalf("parse snapshot fail: %s", err.Error())
+	}
+	if _, exists := sections[journalSequenceSection]; !exists {
+		t.Fatal("journal sequence not saved in snapshot")
+	}
+	var loaded = map[string]json.RawMessage{}
+	if _, err = newJSONStore(dataFile).Load(&loaded); err != nil {
+		t.Fatalf("load sections fail: %s", err.Error())
+	}
+	if _, exists := loaded[journalSequenceSection]; exists {
+		t.Fatal("journal sequence exposed as section of document")
+	}
+}
+
+// TestJSONStore_CrashBeforeTruncate : snapshot renamed into place but journal not truncated yet
+func TestJSONStore_CrashBeforeTruncate(t *testing.T) {
+	var dataFile = filepath.Join(t.TempDir(), "test.data")
+	var journalFile = dataFile + journalFileSuffix
+	var metadata = newJSONStore(dataFile)
+	for counter := 1; counter <= 3; counter++ {
+		if err := metadata.Save(testDocument{Name: "a", Counter: counter}); err != nil {
+			t.Fatalf("save %d fail: %s", counter, err.Error())
+		}
+	}
+	var journal, err = os.ReadFile(journalFile)
+	if err != nil {
+		t.Fatalf("read journal fail: %s", err.Error())
+	}
+	//latest change only written by snapshot
+	if err = metadata.Compact(testDocument{Name: "b", Counter: 4}); err != nil {
+		t.Fatalf("compact fail: %s", err.Error())
+	}
+	metadata.Close()
+	//crash: truncate never happened
+	if err = os.WriteFile(journalFile, journal, DefaultPerm); err != nil {
+		t.Fatalf("restore journal fail: %s", err.Error())
+	}
+	document, result := loadTestDocument(t, dataFile)
+	if "b" != document.Name || 4 != document.Counter {
+		t.Fatalf("document rolled back to %+v", document)
+	}
+	if 0 != result.Replayed || 2 != result.Skipped || !result.Dirty {
+		t.Fatalf("%d replayed, %d skipped, dirty %t, expect 0 replayed, 2 skipped and dirty",
+			result.Replayed, result.Skipped, result.Dirty)
+	}
+	//new entries after restart continue from snapshot
+	metadata = newJSONStore(dataFile)
+	if _, err = metadata.Load(&document); err != nil {
+		t.Fatalf("reload fail: %s", err.Error())
+	}
+	if err = metadata.Compact(document); err != nil {
+		t.Fatalf("compact after reload fail: %s", err.Error())
+	}
+	document.Counter = 5
+	if err = metadata.Save(document); err != nil {
+		t.Fatalf("save after reload fail: %s", err.Error())
+	}
+	metadata.Close()
+	document, result = loadTestDocument(t, dataFile)
+	if 5 != document.Counter || 1 != result.Replayed {
+		t.Fatalf("counter %d with %d replayed after restart, expect 5 and 1", document.Counter, result.Replayed)
+	}
+}
@@ -0,0 +1,518 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	kvFileExtension = ".db"
+	kvOpenTimeout   = time.Second * 3
+)
+
+var (
+	sectionBucket = []byte("sections")
+	layoutBucket  = []byte("layouts")
+	recordBucket  = []byte("records")
+)
+
+// sectionLayout : section stored as records, one key for each element of array or field of object.
+// Keys of array kept in order
+type sectionLayout struct {
+	Array bool     `json:"array,omitempty"`
+	Keys  []string `json:"keys,omitempty"`
+}
+
+// storedSection : section as saved in kv store, value saved as a whole when no layout
+type storedSection struct {
+	Value   json.RawMessage
+	Layout  *sectionLayout
+	Records map[string]json.RawMessage
+}
+
+// kvChanges : keys to write in one transaction, nil value means removed.
+// Records of a section mapped to nil means its bucket dropped
+type kvChanges struct {
+	Sections map[string]json.RawMessage
+	Layouts  map[string]json.RawMessage
+	Records  map[string]map[string]json.RawMessage
+}
+
+// kvStore : embedded transactional key-value store. Elements of array sections with unique id or name,
+// and fields of object sections, are saved as individual records, so a change only rewrites the records it touched
+type kvStore struct {
+	dbFile   string
+	jsonFile string
+	db       *bolt.DB
+	readOnly bool
+	sections map[string]storedSection
+}
+
+func openKVStore(filename string) (store *kvStore, err error) {
+	store = &kvStore{
+		jsonFile: filename,
+		dbFile:   strings.TrimSuffix(filename, filepath.Ext(filename)) + kvFileExtension,
+		sections: map[string]storedSection{},
+	}
+	if store.db, err = bolt.Open(store.dbFile, DefaultPerm, &bolt.Options{Timeout: kvOpenTimeout}); err != nil {
+		err = fmt.Errorf("open kv store '%s' fail: %s", store.dbFile, err.Error())
+		return nil, err
+	}
+	err = store.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{sectionBucket, layoutBucket, recordBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		store.db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// openKVStoreReadOnly : db file not created yet left closed, then loaded as empty and imported from json file
+func openKVStoreReadOnly(filename string) (store *kvStore, err error) {
+	store = &kvStore{
+		jsonFile: filename,
+		dbFile:   strings.TrimSuffix(filename, filepath.Ext(filename)) + kvFileExtension,
+		readOnly: true,
+		sections: map[string]storedSection{},
+	}
+	if !fileExists(store.dbFile) {
+		return store, nil
+	}
+	if store.db, err = bolt.Open(store.dbFile, DefaultPerm, &bolt.Options{ReadOnly: true, Timeout: kvOpenTimeout}); err != nil {
+		if errors.Is(err, bolt.ErrTimeout) {
+			err = fmt.Errorf("kv store '%s' locked, stop the service using it first", store.dbFile)
+		} else {
+			err = fmt.Errorf("open kv store '%s' fail: %s", store.dbFile, err.Error())
+		}
+		return nil, err
+	}
+	return store, nil
+}
+
+func (store *kvStore) Load(document interface{}) (result LoadResult, err error) {
+	var stored = map[string]storedSection{}
+	if nil != store.db {
+		err = store.db.View(func(tx *bolt.Tx) (err error) {
+			stored, err = readSections(tx)
+			return
+		})
+		if err != nil {
+			return
+		}
+	}
+	var sections = map[string]json.RawMessage{}
+	if 0 == len(stored) {
+		if !fileExists(store.jsonFile) {
+			return
+		}
+		//import from json backend, written into kv when compacted
+		var replay JournalReplay
+		if replay, err = ReplayJournal(store.jsonFile); err != nil {
+			return
+		}
+		sections = replay.Sections
+		result.Replayed = replay.Replayed
+		result.Skipped = replay.Skipped
+		result.Discarded = replay.Discarded
+		result.Dirty = true
+		log.Printf("<store> %d section(s) imported from '%s' into '%s'", len(sections), store.jsonFile, store.dbFile)
+	} else {
+		for name, section := range stored {
+			if sections[name], err = section.join(); err != nil {
+				return
+			}
+		}
+	}
+	if err = mergeSections(sections, document); err != nil {
+		return
+	}
+	if !result.Dirty {
+		store.sections = stored
+	}
+	result.Exists = true
+	return
+}
+
+// LoadSection : read value or records of one section, from json file when not imported yet
+func (store *kvStore) LoadSection(name string, value interface{}) (exists bool, err error) {
+	var imported = false
+	var data json.RawMessage
+	if nil != store.db {
+		err = store.db.View(func(tx *bolt.Tx) (err error) {
+			if imported, err = hasSections(tx); err != nil || !imported {
+				return
+			}
+			var section storedSection
+			if section, exists, err = readSection(tx, name); err != nil || !exists {
+				return
+			}
+			data, err = section.join()
+			return
+		})
+		if err != nil {
+			return
+		}
+	}
+	if !imported {
+		return loadJSONSection(store.jsonFile, name, value)
+	}
+	if !exists {
+		return
+	}
+	err = json.Unmarshal(data, value)
+	return
+}
+
+func (store *kvStore) Exists() (exists bool, err error) {
+	if nil != store.db {
+		err = store.db.View(func(tx *bolt.Tx) (err error) {
+			exists, err = hasSections(tx)
+			return
+		})
+		if err != nil || exists {
+			return
+		}
+	}
+	return fileExists(store.jsonFile), nil
+}
+
+// Save : write changed records and sections in one transaction
+func (store *kvStore) Save(document interface{}) (err error) {
+	var sections map[string]storedSection
+	if sections, err = splitStoredSections(document); err != nil {
+		return
+	}
+	var changes = changedRecords(store.sections, sections)
+	if changes.empty() {
+		return nil
+	}
+	if err = store.write(changes, false); err != nil {
+		return
+	}
+	store.sections = sections
+	return nil
+}
+
+// Compact : rewrite all sections, remove those not in document
+func (store *kvStore) Compact(document interface{}) (err error) {
+	var sections map[string]storedSection
+	if sections, err = splitStoredSections(document); err != nil {
+		return
+	}
+	if err = store.write(changedRecords(map[string]storedSection{}, sections), true); err != nil {
+		return
+	}
+	store.sections = sections
+	return nil
+}
+
+// write : apply changes, all buckets cleared first when reset
+func (store *kvStore) write(changes kvChanges, reset bool) error {
+	if store.readOnly {
+		return fmt.Errorf("kv store '%s' opened read only", store.dbFile)
+	}
+	return store.db.Update(func(tx *bolt.Tx) (err error) {
+		if reset {
+			for _, name := range [][]byte{sectionBucket, layoutBucket, recordBucket} {
+				if err = tx.DeleteBucket(name); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+					return
+				}
+				if _, err = tx.CreateBucket(name); err != nil {
+					return
+				}
+			}
+		}
+		if err = putValues(tx.Bucket(sectionBucket), changes.Sections); err != nil {
+			return fmt.Errorf("write section fail: %s", err.Error())
+		}
+		if err = putValues(tx.Bucket(layoutBucket), changes.Layouts); err != nil {
+			return fmt.Errorf("write layout fail: %s", err.Error())
+		}
+		var records = tx.Bucket(recordBucket)
+		for name, values := range changes.Records {
+			if nil == values {
+				if err = records.DeleteBucket([]byte(name)); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+					return fmt.Errorf("remove records of section '%s' fail: %s", name, err.Error())
+				}
+				continue
+			}
+			var bucket *bolt.Bucket
+			if bucket, err = records.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return fmt.Errorf("create records of section '%s' fail: %s", name, err.Error())
+			}
+			if err = putValues(bucket, values); err != nil {
+				return fmt.Errorf("write records of section '%s' fail: %s", name, err.Error())
+			}
+		}
+		return nil
+	})
+}
+
+// Pending : every transaction already durable, nothing to compact
+func (store *kvStore) Pending() int {
+	return 0
+}
+
+func (store *kvStore) Close() error {
+	if nil == store.db {
+		return nil
+	}
+	return store.db.Close()
+}
+
+func putValues(bucket *bolt.Bucket, values map[string]json.RawMessage) (err error) {
+	for key, value := range values {
+		if nil == value {
+			err = bucket.Delete([]byte(key))
+		} else {
+			err = bucket.Put([]byte(key), value)
+		}
+		if err != nil {
+			return fmt.Errorf("key '%s': %s", key, err.Error())
+		}
+	}
+	return nil
+}
+
+// hasSections : false when nothing stored or imported yet
+func hasSections(tx *bolt.Tx) (bool, error) {
+	for _, name := range [][]byte{sectionBucket, layoutBucket} {
+		if bucket := tx.Bucket(name); nil != bucket {
+			if key, _ := bucket.Cursor().First(); nil != key {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func readSections(tx *bolt.Tx) (sections map[string]storedSection, err error) {
+	sections = map[string]storedSection{}
+	if bucket := tx.Bucket(sectionBucket); nil != bucket {
+		err = bucket.ForEach(func(key, value []byte) error {
+			//value only valid in transaction
+			sections[string(key)] = storedSection{Value: append(json.RawMessage{}, value...)}
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	var names []string
+	if bucket := tx.Bucket(layoutBucket); nil != bucket {
+		err = bucket.ForEach(func(key, _ []byte) error {
+			names = append(names, string(key))
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	for _, name := range names {
+		if sections[name], _, err = readSection(tx, name); err != nil {
+			return
+		}
+	}
+	return sections, nil
+}
+
+func readSection(tx *bolt.Tx, name string) (section storedSection, exists bool, err error) {
+	if bucket := tx.Bucket(sectionBucket); nil != bucket {
+		if value := bucket.Get([]byte(name)); nil != value {
+			section.Value = append(json.RawMessage{}, value...)
+			return section, true, nil
+		}
+	}
+	var data []byte
+	if bucket := tx.Bucket(layoutBucket); nil != bucket {
+		data = bucket.Get([]byte(name))
+	}
+	if nil == data {
+		return
+	}
+	var layout sectionLayout
+	if err = json.Unmarshal(data, &layout); err != nil {
+		err = fmt.Errorf("invalid layout of section '%s': %s", name, err.Error())
+		return
+	}
+	section.Layout = &layout
+	section.Records = map[string]json.RawMessage{}
+	if bucket := tx.Bucket(recordBucket).Bucket([]byte(name)); nil != bucket {
+		err = bucket.ForEach(func(key, value []byte) error {
+			section.Records[string(key)] = append(json.RawMessage{}, value...)
+			return nil
+		})
+		if err != nil {
+			return
+		}
+	}
+	return section, true, nil
+}
+
+// join : restore section value from records
+func (section storedSection) join() (data json.RawMessage, err error) {
+	if nil == section.Layout {
+		return section.Value, nil
+	}
+	if !section.Layout.Array {
+		return json.Marshal(section.Records)
+	}
+	var buffer bytes.Buffer
+	buffer.WriteByte('[')
+	for index, key := range section.Layout.Keys {
+		record, exists := section.Records[key]
+		if !exists {
+			err = fmt.Errorf("record '%s' missing", key)
+			return
+		}
+		if 0 != index {
+			buffer.WriteByte(',')
+		}
+		buffer.Write(record)
+	}
+	buffer.WriteByte(']')
+	return buffer.Bytes(), nil
+}
+
+func splitStoredSections(document interface{}) (stored map[string]storedSection, err error) {
+	var sections map[string]json.RawMessage
+	if sections, err = splitSections(document); err != nil {
+		return
+	}
+	stored = map[string]storedSection{}
+	for name, value := range sections {
+		stored[name] = splitRecords(value)
+	}
+	return stored, nil
+}
+
+// splitRecords : object split into fields, array split into elements keyed by their unique id or name.
+// Other values, or array elements without unique key, saved as a whole
+func splitRecords(value json.RawMessage) (section storedSection) {
+	section.Value = value
+	var trimmed = bytes.TrimSpace(value)
+	if 0 == len(trimmed) {
+		return
+	}
+	switch trimmed[0] {
+	case '{':
+		var records map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &records); err != nil {
+			return
+		}
+		section.Layout = &sectionLayout{}
+		section.Records = records
+	case '[':
+		var elements []json.RawMessage
+		if err := json.Unmarshal(trimmed, &elements); err != nil {
+			return
+		}
+		var layout = sectionLayout{Array: true}
+		var records = map[string]json.RawMessage{}
+		for _, element := range elements {
+			var identity struct {
+				ID   interface{} `json:"id"`
+				Name interface{} `json:"name"`
+			}
+			if err := json.Unmarshal(element, &identity); err != nil {
+				return
+			}
+			var key, _ = identity.ID.(string)
+			if "" == key {
+				key, _ = identity.Name.(string)
+			}
+			if _, exists := records[key]; "" == key || exists {
+				return
+			}
+			records[key] = element
+			layout.Keys = append(layout.Keys, key)
+		}
+		section.Layout = &layout
+		section.Records = records
+	}
+	return
+}
+
+// changedRecords : records, layouts and sections different from previous
+func changedRecords(previous, current map[string]storedSection) (changes kvChanges) {
+	changes = kvChanges{
+		Sections: map[string]json.RawMessage{},
+		Layouts:  map[string]json.RawMessage{},
+		Records:  map[string]map[string]json.RawMessage{},
+	}
+	for name, section := range current {
+		origin, exists := previous[name]
+		if nil == section.Layout {
+			if !exists || nil != origin.Layout || string(origin.Value) != string(section.Value) {
+				changes.Sections[name] = section.Value
+			}
+			if exists && nil != origin.Layout {
+				changes.Layouts[name] = nil
+				changes.Records[name] = nil
+			}
+			continue
+		}
+		if exists && nil == origin.Layout {
+			changes.Sections[name] = nil
+		}
+		if !exists || !origin.Layout.equals(*section.Layout) {
+			//error impossible for marshaling of keys
+			changes.Layouts[name], _ = json.Marshal(section.Layout)
+		}
+		var values = map[string]json.RawMessage{}
+		for key, record := range section.Records {
+			if value, exists := origin.Records[key]; !exists || string(value) != string(record) {
+				values[key] = record
+			}
+		}
+		for key, _ := range origin.Records {
+			if _, exists := section.Records[key]; !exists {
+				values[key] = nil
+			}
+		}
+		if 0 != len(values) {
+			changes.Records[name] = values
+		}
+	}
+	for name, origin := range previous {
+		if _, exists := current[name]; exists {
+			continue
+		}
+		if nil == origin.Layout {
+			changes.Sections[name] = nil
+		} else {
+			changes.Layouts[name] = nil
+			changes.Records[name] = nil
+		}
+	}
+	return
+}
+
+func (layout *sectionLayout) equals(other sectionLayout) bool {
+	if nil == layout || layout.Array != other.Array || len(layout.Keys) != len(other.Keys) {
+		return false
+	}
+	for index, key := range layout.Keys {
+		if key != other.Keys[index] {
+			return false
+		}
+	}
+	return true
+}
+
+func (changes kvChanges) empty() bool {
+	return 0 == len(changes.Sections) && 0 == len(changes.Layouts) && 0 == len(changes.Records)
+}
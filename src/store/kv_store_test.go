@@ -0,0 +1,253 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestKVStore_OpenReadOnly(t *testing.T) {
+	var testCases = []struct {
+		Name     string
+		JSONData *testDocument
+		KVData   *testDocument
+		Exists   bool
+		Expected string
+	}{
+		{"nothing stored", nil, nil, false, ""},
+		{"imported from json", &testDocument{Name: "json"}, nil, true, "json"},
+		{"kv preferred", &testDocument{Name: "json"}, &testDocument{Name: "kv"}, true, "kv"},
+	}
+	for _, testCase := range testCases {
+		var dataFile = filepath.Join(t.TempDir(), "test.data")
+		var dbFile = filepath.Join(filepath.Dir(dataFile), "test"+kvFileExtension)
+		if nil != testCase.JSONData {
+			var metadata = newJSONStore(dataFile)
+			if err := metadata.Save(*testCase.JSONData); err != nil {
+				t.Fatalf("%s: save json fail: %s", testCase.Name, err.Error())
+			}
+			metadata.Close()
+		}
+		if nil != testCase.KVData {
+			metadata, err := openKVStore(dataFile)
+			if err != nil {
+				t.Fatalf("%s: open kv fail: %s", testCase.Name, err.Error())
+			}
+			if err = metadata.Compact(*testCase.KVData); err != nil {
+				t.Fatalf("%s: save kv fail: %s", testCase.Name, err.Error())
+			}
+			metadata.Close()
+		}
+		metadata, err := OpenReadOnly(BackendKV, dataFile)
+		if err != nil {
+			t.Fatalf("%s: open read only fail: %s", testCase.Name, err.Error())
+		}
+		var document testDocument
+		result, err := metadata.Load(&document)
+		if err != nil {
+			t.Fatalf("%s: load fail: %s", testCase.Name, err.Error())
+		}
+		if result.Exists != testCase.Exists || document.Name != testCase.Expected {
+			t.Fatalf("%s: unexpected document %+v, exists %t", testCase.Name, document, result.Exists)
+		}
+		if err = metadata.Compact(document); err == nil {
+			t.Fatalf("%s: read only store modified", testCase.Name)
+		}
+		metadata.Close()
+		if nil == testCase.KVData && fileExists(dbFile) {
+			t.Fatalf("%s: '%s' created by read only store", testCase.Name, dbFile)
+		}
+	}
+}
+
+func TestKVStore_OpenReadOnlyLocked(t *testing.T) {
+	var dataFile = filepath.Join(t.TempDir(), "test.data")
+	running, err := openKVStore(dataFile)
+	if err != nil {
+		t.Fatalf("open kv fail: %s", err.Error())
+	}
+	defer running.Close()
+	if _, err = OpenReadOnly(BackendKV, dataFile); err == nil {
+		t.Fatalf("store locked by running service opened")
+	}
+}
+
+type recordDocument struct {
+	Version int               `json:"schema_version"`
+	Pools   []recordPool      `json:"pools,omitempty"`
+	Counts  map[string]uint   `json:"counts,omitempty"`
+	Tags    []string          `json:"tags,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+}
+
+type recordPool struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+	Size int    `json:"size"`
+}
+
+func TestKVStore_SaveRecords(t *testing.T) {
+	var base = recordDocument{
+		Version: 1,
+		Pools:   []recordPool{{ID: "p1", Name: "a", Size: 1}, {ID: "p2", Name: "b", Size: 2}},
+		Counts:  map[string]uint{"x": 1, "y": 2},
+		Tags:    []string{"t1"},
+	}
+	var testCases = []struct {
+		Name     string
+		Modify   func(document *recordDocument)
+		Sections []string
+		Layouts  []string
+		Records  map[string][]string
+	}{
+		{"unchanged", func(document *recordDocument) {}, nil, nil, nil},
+		{"modify one record", func(document *recordDocument) {
+			document.Pools = []recordPool{{ID: "p1", Name: "a", Size: 1}, {ID: "p2", Name: "b", Size: 3}}
+		}, nil, nil, map[string][]string{"pools": {"p2"}}},
+		{"append record", func(document *recordDocument) {
+			document.Pools = append(document.Pools[:2:2], recordPool{ID: "p3", Name: "c"})
+		}, nil, []string{"pools"}, map[string][]string{"pools": {"p3"}}},
+		{"remove record", func(document *recordDocument) {
+			document.Pools = document.Pools[:1]
+		}, nil, []string{"pools"}, map[string][]string{"pools": {"p2"}}},
+		{"reorder records", func(document *recordDocument) {
+			document.Pools = []recordPool{document.Pools[1], document.Pools[0]}
+		}, nil, []string{"pools"}, nil},
+		{"modify field of object", func(document *recordDocument) {
+			document.Counts = map[string]uint{"x": 1, "y": 3}
+		}, nil, nil, map[string][]string{"counts": {"y"}}},
+		{"scalar section", func(document *recordDocument) {
+			document.Version = 2
+		}, []string{"schema_version"}, nil, nil},
+		{"array without key saved as a whole", func(document *recordDocument) {
+			document.Tags = []string{"t1", "t2"}
+		}, []string{"tags"}, nil, nil},
+		{"duplicate keys saved as a whole", func(document *recordDocument) {
+			document.Pools = []recordPool{{Name: "a"}, {Name: "a"}}
+		}, []string{"pools"}, []string{"pools"}, map[string][]string{"pools": nil}},
+		{"section added", func(document *recordDocument) {
+			document.Labels = map[string]string{"zone": "z1"}
+		}, nil, []string{"labels"}, map[string][]string{"labels": {"zone"}}},
+		{"section removed", func(document *recordDocument) {
+			document.Counts = nil
+		}, nil, []string{"counts"}, map[string][]string{"counts": nil}},
+	}
+	for _, testCase := range testCases {
+		var dataFile = filepath.Join(t.TempDir(), "test.data")
+		metadata, err := openKVStore(dataFile)
+		if err != nil {
+			t.Fatalf("%s: open kv fail: %s", testCase.Name, err.Error())
+		}
+		var document = base
+		document.Pools = append([]recordPool{}, base.Pools...)
+		if err = metadata.Compact(document); err != nil {
+			t.Fatalf("%s: compact fail: %s", testCase.Name, err.Error())
+		}
+		testCase.Modify(&document)
+		current, err := splitStoredSections(document)
+		if err != nil {
+			t.Fatalf("%s: split fail: %s", testCase.Name, err.Error())
+		}
+		var changes = changedRecords(metadata.sections, current)
+		if names := sortedKeys(changes.Sections); fmt.Sprint(names) != fmt.Sprint(sortedStrings(testCase.Sections)) {
+			t.Fatalf("%s: sections %v changed, %v expected", testCase.Name, names, testCase.Sections)
+		}
+		if names := sortedKeys(changes.Layouts); fmt.Sprint(names) != fmt.Sprint(sortedStrings(testCase.Layouts)) {
+			t.Fatalf("%s: layouts %v changed, %v expected", testCase.Name, names, testCase.Layouts)
+		}
+		if len(changes.Records) != len(testCase.Records) {
+			t.Fatalf("%s: records of %d section(s) changed, %v expected", testCase.Name, len(changes.Records), testCase.Records)
+		}
+		for name, keys := range testCase.Records {
+			if records := sortedKeys(changes.Records[name]); fmt.Sprint(records) != fmt.Sprint(sortedStrings(keys)) {
+				t.Fatalf("%s: records %v of section '%s' changed, %v expected", testCase.Name, records, name, keys)
+			}
+		}
+		if err = metadata.Save(document); err != nil {
+			t.Fatalf("%s: save fail: %s", testCase.Name, err.Error())
+		}
+		metadata.Close()
+		if metadata, err = openKVStore(dataFile); err != nil {
+			t.Fatalf("%s: reopen kv fail: %s", testCase.Name, err.Error())
+		}
+		var loaded recordDocument
+		if _, err = metadata.Load(&loaded); err != nil {
+			t.Fatalf("%s: load fail: %s", testCase.Name, err.Error())
+		}
+		metadata.Close()
+		if fmt.Sprintf("%+v", loaded) != fmt.Sprintf("%+v", document) {
+			t.Fatalf("%s: loaded %+v, %+v expected", testCase.Name, loaded, document)
+		}
+	}
+}
+
+func TestKVStore_LoadSection(t *testing.T) {
+	var document = recordDocument{
+		Version: 3,
+		Pools:   []recordPool{{ID: "p1", Name: "a", Size: 1}},
+	}
+	var testCases = []struct {
+		Name     string
+		Imported bool
+	}{
+		{"stored in kv", true},
+		{"not imported from json", false},
+	}
+	for _, testCase := range testCases {
+		var dataFile = filepath.Join(t.TempDir(), "test.data")
+		if testCase.Imported {
+			metadata, err := openKVStore(dataFile)
+			if err != nil {
+				t.Fatalf("%s: open kv fail: %s", testCase.Name, err.Error())
+			}
+			if err = metadata.Compact(document); err != nil {
+				t.Fatalf("%s: save kv fail: %s", testCase.Name, err.Error())
+			}
+			metadata.Close()
+		} else {
+			var metadata = newJSONStore(dataFile)
+			if err := metadata.Save(document); err != nil {
+				t.Fatalf("%s: save json fail: %s", testCase.Name, err.Error())
+			}
+			metadata.Close()
+		}
+		metadata, err := OpenReadOnly(BackendKV, dataFile)
+		if err != nil {
+			t.Fatalf("%s: open read only fail: %s", testCase.Name, err.Error())
+		}
+		if exists, err := metadata.Exists(); err != nil || !exists {
+			t.Fatalf("%s: document not exists: %v", testCase.Name, err)
+		}
+		var version int
+		var pools []recordPool
+		var labels map[string]string
+		exists, err := metadata.LoadSection("schema_version", &version)
+		if err != nil || !exists || version != document.Version {
+			t.Fatalf("%s: version %d loaded, exists %t, error %v", testCase.Name, version, exists, err)
+		}
+		exists, err = metadata.LoadSection("pools", &pools)
+		if err != nil || !exists || fmt.Sprint(pools) != fmt.Sprint(document.Pools) {
+			t.Fatalf("%s: pools %v loaded, exists %t, error %v", testCase.Name, pools, exists, err)
+		}
+		if exists, err = metadata.LoadSection("labels", &labels); err != nil || exists {
+			t.Fatalf("%s: absent section loaded, exists %t, error %v", testCase.Name, exists, err)
+		}
+		metadata.Close()
+	}
+}
+
+func sortedKeys(values map[string]json.RawMessage) []string {
+	var keys []string
+	for key, _ := range values {
+		keys = append(keys, key)
+	}
+	return sortedStrings(keys)
+}
+
+func sortedStrings(values []string) []string {
+	var sorted = append([]string{}, values...)
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+const (
+	SchemaVersionSection = "schema_version"
+	backupFileFormat     = "%s.v%d.backup"
+)
+
+// Migration : upgrade sections of a document from the previous version to Version
+type Migration struct {
+	Version     int
+	Description string
+	Upgrade     func(sections map[string]json.RawMessage) error
+}
+
+// MigrationPlan : migrations required by stored document, in order
+type MigrationPlan struct {
+	Exists  bool
+	Current int
+	Target  int
+	Pending []Migration
+}
+
+// LatestVersion : schema version after all migrations applied
+func LatestVersion(migrations []Migration) int {
+	if 0 == len(migrations) {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// PlanMigration : compare version of stored document with migrations, only the version section read
+func PlanMigration(metadata Store, migrations []Migration) (plan MigrationPlan, err error) {
+	for index, migration := range migrations {
+		if migration.Version != index+1 {
+			err = fmt.Errorf("migration %d has invalid version %d", index, migration.Version)
+			return
+		}
+	}
+	plan.Target = LatestVersion(migrations)
+	if plan.Exists, err = metadata.Exists(); err != nil {
+		return
+	}
+	if !plan.Exists {
+		plan.Current = plan.Target
+		return
+	}
+	if _, err = metadata.LoadSection(SchemaVersionSection, &plan.Current); err != nil {
+		err = fmt.Errorf("invalid schema version: %s", err.Error())
+		return
+	}
+	if plan.Current > plan.Target {
+		err = fmt.Errorf("schema version %d newer than supported version %d", plan.Current, plan.Target)
+		return
+	}
+	plan.Pending = migrations[plan.Current:]
+	return plan, nil
+}
+
+// Migrate : run pending migrations on stored document, the document before migration saved as
+// '<filename>.v<version>.backup' first
+func Migrate(metadata Store, migrations []Migration, filename string) (plan MigrationPlan, err error) {
+	if plan, err = PlanMigration(metadata, migrations); err != nil {
+		return
+	}
+	if 0 == len(plan.Pending) {
+		return
+	}
+	var sections = map[string]json.RawMessage{}
+	if _, err = metadata.Load(&sections); err != nil {
+		return
+	}
+	var data []byte
+	if data, err = json.MarshalIndent(sections, "", " "); err != nil {
+		return
+	}
+	var backupFile = fmt.Sprintf(backupFileFormat, filename, plan.Current)
+	if err = writeFileAtomic(backupFile, data, DefaultPerm); err != nil {
+		err = fmt.Errorf("backup '%s' fail: %s", backupFile, err.Error())
+		return
+	}
+	for _, migration := range plan.Pending {
+		if err = migration.Upgrade(sections); err != nil {
+			err = fmt.Errorf("migrate to version %d fail: %s", migration.Version, err.Error())
+			return
+		}
+		sections[SchemaVersionSection] = json.RawMessage(strconv.Itoa(migration.Version))
+		log.Printf("<store> '%s' migrated to version %d: %s", filename, migration.Version, migration.Description)
+	}
+	if err = metadata.Compact(sections); err != nil {
+		err = fmt.Errorf("save migrated data fail: %s", err.Error())
+		return
+	}
+	log.Printf("<store> '%s' upgraded from version %d to %d, previous data saved as '%s'",
+		filename, plan.Current, plan.Target, backupFile)
+	return plan, nil
+}
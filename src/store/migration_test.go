@@ -0,0 +1,95 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+type versionedDocument struct {
+	Version int      `json:"schema_version,omitempty"`
+	Name    string   `json:"name"`
+	Items   []string `json:"items,omitempty"`
+}
+
+var testMigrations = []Migration{
+	{Version: 1, Description: "add default item", Upgrade: func(sections map[string]json.RawMessage) error {
+		sections["items"] = json.RawMessage(`["default"]`)
+		return nil
+	}},
+	{Version: 2, Description: "upper case name", Upgrade: func(sections map[string]json.RawMessage) error {
+		var name string
+		if err := json.Unmarshal(sections["name"], &name); err != nil {
+			return err
+		}
+		sections["name"] = json.RawMessage(fmt.Sprintf(`"%s-v2"`, name))
+		return nil
+	}},
+}
+
+func TestMigration_Migrate(t *testing.T) {
+	var testCases = []struct {
+		Name       string
+		Backend    string
+		Stored     *versionedDocument
+		Migrations []Migration
+		Valid      bool
+		Pending    int
+		Expected   versionedDocument
+	}{
+		{"nothing stored", BackendJSON, nil, testMigrations, true, 0, versionedDocument{}},
+		{"unversioned json", BackendJSON, &versionedDocument{Name: "a"}, testMigrations, true, 2,
+			versionedDocument{Version: 2, Name: "a-v2", Items: []string{"default"}}},
+		{"unversioned kv", BackendKV, &versionedDocument{Name: "a"}, testMigrations, true, 2,
+			versionedDocument{Version: 2, Name: "a-v2", Items: []string{"default"}}},
+		{"partially migrated", BackendJSON, &versionedDocument{Version: 1, Name: "b", Items: []string{"x"}}, testMigrations, true, 1,
+			versionedDocument{Version: 2, Name: "b-v2", Items: []string{"x"}}},
+		{"up to date", BackendKV, &versionedDocument{Version: 2, Name: "c"}, testMigrations, true, 0,
+			versionedDocument{Version: 2, Name: "c"}},
+		{"newer than supported", BackendJSON, &versionedDocument{Version: 3, Name: "d"}, testMigrations, false, 0,
+			versionedDocument{Version: 3, Name: "d"}},
+		{"invalid version order", BackendJSON, &versionedDocument{Name: "e"}, testMigrations[1:], false, 0,
+			versionedDocument{Name: "e"}},
+		{"failed migration keeps data", BackendJSON, &versionedDocument{Name: "f"}, append(testMigrations[:1:1],
+			Migration{Version: 2, Upgrade: func(map[string]json.RawMessage) error { return errors.New("broken") }}), false, 0,
+			versionedDocument{Name: "f"}},
+	}
+	for _, testCase := range testCases {
+		var dataFile = filepath.Join(t.TempDir(), "test.data")
+		metadata, err := Open(testCase.Backend, dataFile)
+		if err != nil {
+			t.Fatalf("%s: open store fail: %s", testCase.Name, err.Error())
+		}
+		if nil != testCase.Stored {
+			if err = metadata.Compact(*testCase.Stored); err != nil {
+				t.Fatalf("%s: save document fail: %s", testCase.Name, err.Error())
+			}
+		}
+		plan, err := Migrate(metadata, testCase.Migrations, dataFile)
+		metadata.Close()
+		if (nil == err) != testCase.Valid {
+			t.Fatalf("%s: unexpected migrate result %v", testCase.Name, err)
+		}
+		if testCase.Valid && len(plan.Pending) != testCase.Pending {
+			t.Fatalf("%s: %d migration(s) pending, %d expected", testCase.Name, len(plan.Pending), testCase.Pending)
+		}
+		if 0 != testCase.Pending && !fileExists(fmt.Sprintf(backupFileFormat, dataFile, plan.Current)) {
+			t.Fatalf("%s: backup of version %d not saved", testCase.Name, plan.Current)
+		}
+		if metadata, err = Open(testCase.Backend, dataFile); err != nil {
+			t.Fatalf("%s: reopen store fail: %s", testCase.Name, err.Error())
+		}
+		var document versionedDocument
+		_, err = metadata.Load(&document)
+		metadata.Close()
+		if err != nil {
+			t.Fatalf("%s: load document fail: %s", testCase.Name, err.Error())
+		}
+		if document.Version != testCase.Expected.Version || document.Name != testCase.Expected.Name ||
+			fmt.Sprint(document.Items) != fmt.Sprint(testCase.Expected.Items) {
+			t.Fatalf("%s: document %+v, %+v expected", testCase.Name, document, testCase.Expected)
+		}
+	}
+}
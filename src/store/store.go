@@ -0,0 +1,106 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+const (
+	BackendJSON    = "json"
+	BackendKV      = "kv"
+	DefaultBackend = BackendJSON
+	DefaultPerm    = 0640
+)
+
+// LoadResult : what happened when loading, Dirty means store should be compacted
+// since journal replayed or data imported from other backend
+type LoadResult struct {
+	Exists    bool
+	Dirty     bool
+	Replayed  int
+	Skipped   int
+	Discarded int
+}
+
+// Store : persistent metadata of a module. A document is a JSON object whose
+// top-level fields are stored as sections, backends only write changed sections or records
+type Store interface {
+	// Load : recover document, Exists is false when nothing stored yet
+	Load(document interface{}) (result LoadResult, err error)
+	// LoadSection : point read of one top-level section
+	LoadSection(name string, value interface{}) (exists bool, err error)
+	// Exists : false when nothing stored yet
+	Exists() (bool, error)
+	// Save : persist changed sections of document
+	Save(document interface{}) error
+	// Compact : rewrite whole document
+	Compact(document interface{}) error
+	// Pending : changes saved since last compaction
+	Pending() int
+	Close() error
+}
+
+// Open : open store of backend, filename is the JSON data file, other backends derive their file from it
+func Open(backend, filename string) (Store, error) {
+	switch backend {
+	case "", BackendJSON:
+		return newJSONStore(filename), nil
+	case BackendKV:
+		return openKVStore(filename)
+	default:
+		return nil, fmt.Errorf("invalid metadata store backend '%s'", backend)
+	}
+}
+
+// OpenReadOnly : open store of backend for inspection, nothing created or modified. A kv store not created yet
+// is skipped, and one locked by running service fails after timeout instead of blocking
+func OpenReadOnly(backend, filename string) (Store, error) {
+	switch backend {
+	case "", BackendJSON:
+		return newJSONStore(filename), nil
+	case BackendKV:
+		return openKVStoreReadOnly(filename)
+	default:
+		return nil, fmt.Errorf("invalid metadata store backend '%s'", backend)
+	}
+}
+
+func splitSections(document interface{}) (sections map[string]json.RawMessage, err error) {
+	var data []byte
+	if data, err = json.Marshal(document); err != nil {
+		return
+	}
+	sections = map[string]json.RawMessage{}
+	err = json.Unmarshal(data, &sections)
+	return
+}
+
+func mergeSections(sections map[string]json.RawMessage, document interface{}) (err error) {
+	var data []byte
+	if data, err = json.Marshal(sections); err != nil {
+		return
+	}
+	return json.Unmarshal(data, document)
+}
+
+// changedSections : sections different from previous, removed ones mapped to nil
+func changedSections(previous, current map[string]json.RawMessage) (changed map[string]json.RawMessage) {
+	changed = map[string]json.RawMessage{}
+	for name, section := range current {
+		if origin, exists := previous[name]; !exists || string(origin) != string(section) {
+			changed[name] = section
+		}
+	}
+	for name, _ := range previous {
+		if _, exists := current[name]; !exists {
+			changed[name] = nil
+		}
+	}
+	return
+}
+
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return !os.IsNotExist(err)
+}
@@ -0,0 +1,22 @@
+package task
+
+import (
+	"github.com/project-nano/core/modules"
+)
+
+// newInstanceEvent : event of guest, pool and cell filled when guest still allocated
+func newInstanceEvent(resource modules.ResourceModule, eventType, instanceID string) (event modules.ClusterEvent){
+	event = modules.ClusterEvent{Type: eventType, Guest: instanceID}
+	var respChan = make(chan modules.ResourceResult, 1)
+	resource.GetInstanceStatus(instanceID, respChan)
+	var result = <- respChan
+	if result.Error == nil{
+		event.Pool = result.Instance.Pool
+		event.Cell = result.Instance.Cell
+	}
+	return event
+}
+
+func newStatusEvent(eventType string, status modules.InstanceStatus) modules.ClusterEvent{
+	return modules.ClusterEvent{Type: eventType, Pool: status.Pool, Cell: status.Cell, Guest: status.ID}
+}
@@ -58,6 +58,15 @@ func (executor *CreateGuestExecutor) Execute(id framework.SessionID, request fra
 	if config.AutoStart, err = request.GetBoolean(framework.ParamKeyOption); err != nil {
 		return err
 	}
+	//optional server group
+	config.ServerGroup, _ = request.GetString(framework.ParamKeyAssign)
+	//optional label selector
+	if tags, _ := request.GetStringArray(framework.ParamKeyTag); 0 != len(tags) {
+		if config.Selector, err = modules.ParsePlacementSelector(tags); err != nil {
+			err = fmt.Errorf("invalid selector: %s", err.Error())
+			return executor.ResponseFail(resp, err.Error(), request.GetSender())
+		}
+	}
 	var templateID string
 	if templateID, err = request.GetString(framework.ParamKeyTemplate); err != nil {
 		err = fmt.Errorf("get template id fail: %s", err.Error())
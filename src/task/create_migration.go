@@ -58,6 +58,11 @@ func (executor *CreateMigrationExecutor) Execute(id framework.SessionID, request
 
 	var migrationID string
 	var params = modules.MigrationParameter{SourcePool: poolName, SourceCell: sourceCell, TargetPool: poolName, TargetCell: targetCell, Instances: instances}
+	if tags, _ := request.GetStringArray(framework.ParamKeyTag); 0 != len(tags) {
+		if params.Selector, err = modules.ParsePlacementSelector(tags); err != nil {
+			return
+		}
+	}
 	{
 		resp, _ := framework.CreateJsonMessage(framework.CreateMigrationResponse)
 		resp.SetSuccess(false)
@@ -51,6 +51,46 @@ func (executor *CreateSnapshotExecutor) Execute(id framework.SessionID, request
 		}
 		ins = result.Instance
 	}
+	var snapshotCount uint
+	{
+		//refresh snapshot count before verify quota
+		query, _ := framework.CreateJsonMessage(framework.QuerySnapshotRequest)
+		query.SetFromSession(id)
+		query.SetString(framework.ParamKeyInstance, instanceID)
+		if err = executor.Sender.SendMessage(query, ins.Cell); err != nil {
+			log.Printf("[%08X] query snapshot from cell '%s' fail: %s", id, ins.Cell, err.Error())
+			resp.SetError(err.Error())
+			return executor.Sender.SendMessage(resp, request.GetSender())
+		}
+		timer := time.NewTimer(modules.GetConfigurator().GetOperateTimeout())
+		select {
+		case cellResp := <-incoming:
+			if !cellResp.IsSuccess() {
+				log.Printf("[%08X] cell query snapshot fail: %s", id, cellResp.GetError())
+				resp.SetError(cellResp.GetError())
+				return executor.Sender.SendMessage(resp, request.GetSender())
+			}
+			names, _ := cellResp.GetStringArray(framework.ParamKeyName)
+			snapshotCount = uint(len(names))
+		case <-timer.C:
+			log.Printf("[%08X] wait query snapshot response timeout", id)
+			resp.SetError("cell timeout")
+			return executor.Sender.SendMessage(resp, request.GetSender())
+		}
+		var errChan = make(chan error, 1)
+		executor.ResourceModule.UpdateSnapshotCount(instanceID, snapshotCount, errChan)
+		if err = <-errChan; err != nil {
+			log.Printf("[%08X] update snapshot count fail: %s", id, err.Error())
+			resp.SetError(err.Error())
+			return executor.Sender.SendMessage(resp, request.GetSender())
+		}
+		executor.ResourceModule.CheckQuota(ins.User, ins.Group, modules.ResourceQuota{Snapshots: 1}, errChan)
+		if err = <-errChan; err != nil {
+			log.Printf("[%08X] %s", id, err.Error())
+			resp.SetError(err.Error())
+			return executor.Sender.SendMessage(resp, request.GetSender())
+		}
+	}
 	{
 		//forward request
 		forward, _ := framework.CreateJsonMessage(framework.CreateSnapshotRequest)
@@ -68,6 +108,11 @@ func (executor *CreateSnapshotExecutor) Execute(id framework.SessionID, request
 		case cellResp := <-incoming:
 			if cellResp.IsSuccess() {
 				log.Printf("[%08X] cell create snapshot success", id)
+				var errChan = make(chan error, 1)
+				executor.ResourceModule.UpdateSnapshotCount(instanceID, snapshotCount+1, errChan)
+				if err = <-errChan; err != nil {
+					log.Printf("[%08X] warning: update snapshot count fail: %s", id, err.Error())
+				}
 			} else {
 				log.Printf("[%08X] cell create snapshot fail: %s", id, cellResp.GetError())
 			}
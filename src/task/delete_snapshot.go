@@ -64,6 +64,7 @@ func (executor *DeleteSnapshotExecutor) Execute(id framework.SessionID, request
 		case cellResp := <-incoming:
 			if cellResp.IsSuccess() {
 				log.Printf("[%08X] cell delete snapshot success", id)
+				executor.refreshSnapshotCount(id, instanceID, ins.Cell, incoming)
 			} else {
 				log.Printf("[%08X] cell delete snapshot fail: %s", id, cellResp.GetError())
 			}
@@ -79,3 +80,31 @@ func (executor *DeleteSnapshotExecutor) Execute(id framework.SessionID, request
 		}
 	}
 }
+
+// refreshSnapshotCount : deleted snapshot counted in quota until count refreshed from cell
+func (executor *DeleteSnapshotExecutor) refreshSnapshotCount(id framework.SessionID, instanceID, cellName string,
+	incoming chan framework.Message) {
+	query, _ := framework.CreateJsonMessage(framework.QuerySnapshotRequest)
+	query.SetFromSession(id)
+	query.SetString(framework.ParamKeyInstance, instanceID)
+	if err := executor.Sender.SendMessage(query, cellName); err != nil {
+		log.Printf("[%08X] warning: query snapshot from cell '%s' fail: %s", id, cellName, err.Error())
+		return
+	}
+	timer := time.NewTimer(modules.GetConfigurator().GetOperateTimeout())
+	select {
+	case cellResp := <-incoming:
+		if !cellResp.IsSuccess() {
+			log.Printf("[%08X] warning: cell query snapshot fail: %s", id, cellResp.GetError())
+			return
+		}
+		names, _ := cellResp.GetStringArray(framework.ParamKeyName)
+		var errChan = make(chan error, 1)
+		executor.ResourceModule.UpdateSnapshotCount(instanceID, uint(len(names)), errChan)
+		if err := <-errChan; err != nil {
+			log.Printf("[%08X] warning: update snapshot count fail: %s", id, err.Error())
+		}
+	case <-timer.C:
+		log.Printf("[%08X] warning: wait query snapshot response timeout", id)
+	}
+}
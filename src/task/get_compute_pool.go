@@ -40,6 +40,8 @@ func (executor *GetComputePoolExecutor)Execute(id framework.SessionID, request f
 	resp.SetString(framework.ParamKeyNetwork, poolInfo.Network)
 	resp.SetString(framework.ParamKeyStorage, poolInfo.Storage)
 	resp.SetBoolean(framework.ParamKeyOption, poolInfo.Failover)
+	resp.SetString(framework.ParamKeyPolicy, poolInfo.Strategy)
+	resp.SetUIntArray(framework.ParamKeyLimit, poolInfo.ToOptions())
 	resp.SetSuccess(true)
 	return executor.Sender.SendMessage(resp, request.GetSender())
 }
\ No newline at end of file
@@ -4,6 +4,7 @@ go 1.19
 
 replace (
 	github.com/project-nano/core/modules => ../modules
+	github.com/project-nano/core/store => ../store
 	github.com/project-nano/framework => ../../../framework
 )
 
@@ -8,6 +8,7 @@ import (
 
 type HandleAddressChangedExecutor struct {
 	ResourceModule modules.ResourceModule
+	EventModule    modules.EventModule
 }
 
 func (executor *HandleAddressChangedExecutor)Execute(id framework.SessionID, event framework.Message,
@@ -28,6 +29,10 @@ func (executor *HandleAddressChangedExecutor)Execute(id framework.SessionID, eve
 	err = <- respChan
 	if err != nil{
 		log.Printf("[%08X] update address fail: %s", id, err.Error())
+		return nil
 	}
+	var changed = newInstanceEvent(executor.ResourceModule, modules.EventAddressChanged, instanceID)
+	changed.Attributes = map[string]string{"address": address}
+	executor.EventModule.PublishEvent(changed)
 	return nil
 }
\ No newline at end of file
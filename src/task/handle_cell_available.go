@@ -11,6 +11,7 @@ import (
 type HandleCellAvailableExecutor struct {
 	Sender         framework.MessageSender
 	ResourceModule modules.ResourceModule
+	EventModule    modules.EventModule
 }
 
 func (executor *HandleCellAvailableExecutor) Execute(id framework.SessionID, request framework.Message,
@@ -44,6 +45,8 @@ func (executor *HandleCellAvailableExecutor) Execute(id framework.SessionID, req
 			log.Printf("[%08X] get cell status fail: %s", id, result.Error.Error())
 			return nil
 		}
+		executor.EventModule.PublishEvent(modules.ClusterEvent{Type: modules.EventCellAvailable, Pool: result.Pool,
+			Cell: cellName, Attributes: map[string]string{"address": cellAddress}})
 		if result.Pool == "" {
 			log.Printf("[%08X] cell not allocated", id)
 			return nil
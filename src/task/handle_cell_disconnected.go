@@ -4,11 +4,13 @@ import (
 	"github.com/project-nano/framework"
 	"github.com/project-nano/core/modules"
 	"log"
+	"strconv"
 )
 
 type HandleCellDisconnectedExecutor struct {
 	Sender         framework.MessageSender
 	ResourceModule modules.ResourceModule
+	EventModule    modules.EventModule
 }
 
 func (executor *HandleCellDisconnectedExecutor)Execute(id framework.SessionID, request framework.Message,
@@ -21,6 +23,16 @@ func (executor *HandleCellDisconnectedExecutor)Execute(id framework.SessionID, r
 	if err != nil{
 		return
 	}
+	var lost = modules.ClusterEvent{Type: modules.EventCellDisconnected, Cell: cellName,
+		Attributes: map[string]string{"graceful": strconv.FormatBool(gracefullyClose)}}
+	{
+		var respChan = make(chan modules.ResourceResult, 1)
+		executor.ResourceModule.GetCellStatus(cellName, respChan)
+		if result := <- respChan; result.Error == nil{
+			lost.Pool = result.Pool
+		}
+		executor.EventModule.PublishEvent(lost)
+	}
 	if gracefullyClose{
 		var respChan = make(chan error, 1)
 		executor.ResourceModule.SetCellDead(cellName, respChan)
@@ -64,5 +76,7 @@ func (executor *HandleCellDisconnectedExecutor)Execute(id framework.SessionID, r
 	}
 	log.Printf("[%08X] %d instance(s) on cell '%s' dispatched to %d new cells by automated failover.",
 		id, instanceCount, cellName, len(plan))
+	executor.EventModule.PublishEvent(modules.ClusterEvent{Type: modules.EventCellFailover, Pool: lost.Pool, Cell: cellName,
+		Attributes: map[string]string{"instances": strconv.Itoa(instanceCount), "targets": strconv.Itoa(len(plan))}})
 	return nil
 }
@@ -9,6 +9,7 @@ import (
 type HandleGuestCreatedExecutor struct {
 	Sender         framework.MessageSender
 	ResourceModule modules.ResourceModule
+	EventModule    modules.EventModule
 }
 
 func (executor *HandleGuestCreatedExecutor)Execute(id framework.SessionID, event framework.Message,
@@ -36,6 +37,8 @@ func (executor *HandleGuestCreatedExecutor)Execute(id framework.SessionID, event
 	err = <- respChan
 	if err != nil{
 		log.Printf("[%08X] confirm instance fail: %s", id, err.Error())
+		return nil
 	}
+	executor.EventModule.PublishEvent(newInstanceEvent(executor.ResourceModule, modules.EventGuestCreated, instanceID))
 	return nil
 }
@@ -9,6 +9,7 @@ import (
 type HandleGuestDeletedExecutor struct {
 	Sender         framework.MessageSender
 	ResourceModule modules.ResourceModule
+	EventModule    modules.EventModule
 }
 
 func (executor *HandleGuestDeletedExecutor)Execute(id framework.SessionID, event framework.Message,
@@ -19,11 +20,14 @@ func (executor *HandleGuestDeletedExecutor)Execute(id framework.SessionID, event
 	}
 	log.Printf("[%08X] recv guest '%s' deleted from %s.[%08X]", id, instanceID,
 		event.GetSender(), event.GetFromSession())
+	var deleted = newInstanceEvent(executor.ResourceModule, modules.EventGuestDeleted, instanceID)
 	var respChan = make(chan error)
 	executor.ResourceModule.DeallocateInstance(instanceID, nil, respChan)
 	err = <- respChan
 	if err != nil{
 		log.Printf("[%08X] deallocate guest fail: %s", id, err.Error())
+		return nil
 	}
+	executor.EventModule.PublishEvent(deleted)
 	return nil
 }
@@ -9,6 +9,7 @@ import (
 type HandleGuestStartedExecutor struct {
 	Sender         framework.MessageSender
 	ResourceModule modules.ResourceModule
+	EventModule    modules.EventModule
 }
 
 func (executor *HandleGuestStartedExecutor)Execute(id framework.SessionID, event framework.Message,
@@ -38,7 +39,9 @@ func (executor *HandleGuestStartedExecutor)Execute(id framework.SessionID, event
 		err = <- respChan
 		if err != nil{
 			log.Printf("[%08X] warning: update started status fail: %s", id, err)
+			return nil
 		}
+		executor.EventModule.PublishEvent(newStatusEvent(modules.EventGuestStarted, status))
 		return nil
 	}
 }
@@ -9,6 +9,7 @@ import (
 type HandleGuestStoppedExecutor struct {
 	Sender         framework.MessageSender
 	ResourceModule modules.ResourceModule
+	EventModule    modules.EventModule
 }
 
 func (executor *HandleGuestStoppedExecutor)Execute(id framework.SessionID, event framework.Message,
@@ -38,7 +39,9 @@ func (executor *HandleGuestStoppedExecutor)Execute(id framework.SessionID, event
 		err = <- respChan
 		if err != nil{
 			log.Printf("[%08X] warning: update stopped status fail: %s", id, err)
+			return nil
 		}
+		executor.EventModule.PublishEvent(newStatusEvent(modules.EventGuestStopped, status))
 		return nil
 	}
 }
@@ -9,6 +9,7 @@ import (
 
 type HandleGuestSystemResetExecutor struct {
 	ResourceModule modules.ResourceModule
+	EventModule    modules.EventModule
 }
 
 func (executor *HandleGuestSystemResetExecutor)Execute(id framework.SessionID, request framework.Message,
@@ -25,8 +26,13 @@ func (executor *HandleGuestSystemResetExecutor)Execute(id framework.SessionID, r
 	err = <- respChan
 	if err != nil{
 		log.Printf("[%08X] recv guest reset finish, but update fail: %s", id, err.Error())
-	}else{
-		log.Printf("[%08X] reset system of guest '%s' finished", id, guestID)
+		return nil
 	}
+	log.Printf("[%08X] reset system of guest '%s' finished", id, guestID)
+	var reset = newInstanceEvent(executor.ResourceModule, modules.EventGuestSystemReset, guestID)
+	if !request.IsSuccess(){
+		reset.Attributes = map[string]string{"error": request.GetError()}
+	}
+	executor.EventModule.PublishEvent(reset)
 	return nil
 }
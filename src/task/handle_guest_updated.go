@@ -5,11 +5,13 @@ import (
 	"github.com/project-nano/core/modules"
 	"log"
 	"errors"
+	"strconv"
 )
 
 type HandleGuestUpdatedExecutor struct {
 	Sender         framework.MessageSender
 	ResourceModule modules.ResourceModule
+	EventModule    modules.EventModule
 }
 
 func (executor *HandleGuestUpdatedExecutor)Execute(id framework.SessionID, event framework.Message,
@@ -21,9 +23,12 @@ func (executor *HandleGuestUpdatedExecutor)Execute(id framework.SessionID, event
 	if !event.IsSuccess(){
 		log.Printf("[%08X] guest '%s' create fail: %s", id, instanceID, event.GetError())
 		err = errors.New(event.GetError())
+		var failed = newInstanceEvent(executor.ResourceModule, modules.EventGuestUpdated, instanceID)
+		failed.Attributes = map[string]string{"error": event.GetError()}
 		var respChan = make(chan error)
 		executor.ResourceModule.DeallocateInstance(instanceID, err, respChan)
 		<- respChan
+		executor.EventModule.PublishEvent(failed)
 		return nil
 	}
 
@@ -58,7 +63,11 @@ func (executor *HandleGuestUpdatedExecutor)Execute(id framework.SessionID, event
 		err = <- respChan
 		if err != nil{
 			log.Printf("[%08X] warning: update progress fail: %s", id, err)
+			return nil
 		}
+		var updated = newStatusEvent(modules.EventGuestUpdated, status)
+		updated.Attributes = map[string]string{"progress": strconv.FormatUint(uint64(progress), 10)}
+		executor.EventModule.PublishEvent(updated)
 		return nil
 	}
 }
\ No newline at end of file
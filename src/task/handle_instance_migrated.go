@@ -4,11 +4,13 @@ import (
 	"github.com/project-nano/framework"
 	"github.com/project-nano/core/modules"
 	"log"
+	"strconv"
 )
 
 type HandleInstanceMigratedExecutor struct {
 	Sender         framework.MessageSender
 	ResourceModule modules.ResourceModule
+	EventModule    modules.EventModule
 }
 
 func (executor *HandleInstanceMigratedExecutor)Execute(id framework.SessionID, request framework.Message,
@@ -39,9 +41,12 @@ func (executor *HandleInstanceMigratedExecutor)Execute(id framework.SessionID, r
 		err = <- respChan
 		if err != nil{
 			log.Printf("[%08X] finish migration fail: %s", id, err.Error())
-		}else{
-			log.Printf("[%08X] migration '%s' finished from %s.[%08X]", id, migrationID, request.GetSender(), request.GetFromSession())
+			return nil
 		}
+		log.Printf("[%08X] migration '%s' finished from %s.[%08X]", id, migrationID, request.GetSender(), request.GetFromSession())
+		executor.publishMigrated(instances, map[string]string{"migration": migrationID})
+		executor.EventModule.PublishEvent(modules.ClusterEvent{Type: modules.EventMigrationFinished, Cell: request.GetSender(),
+			Attributes: map[string]string{"migration": migrationID, "instances": strconv.Itoa(len(instances))}})
 		return nil
 	}else{
 		//failover
@@ -54,10 +59,19 @@ func (executor *HandleInstanceMigratedExecutor)Execute(id framework.SessionID, r
 		err = <- respChan
 		if err != nil{
 			log.Printf("[%08X] migrate instance fail: %s", id, err.Error())
-		}else{
-			log.Printf("[%08X] %d instance(s) migrated from '%s' to '%s'", id, len(instances), sourceCell, request.GetSender())
+			return nil
 		}
+		log.Printf("[%08X] %d instance(s) migrated from '%s' to '%s'", id, len(instances), sourceCell, request.GetSender())
+		executor.publishMigrated(instances, map[string]string{"source": sourceCell, "failover": "true"})
 		return nil
 	}
 
+}
+
+func (executor *HandleInstanceMigratedExecutor) publishMigrated(instances []string, attributes map[string]string){
+	for _, instanceID := range instances{
+		var migrated = newInstanceEvent(executor.ResourceModule, modules.EventInstanceMigrated, instanceID)
+		migrated.Attributes = attributes
+		executor.EventModule.PublishEvent(migrated)
+	}
 }
\ No newline at end of file
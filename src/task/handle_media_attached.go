@@ -9,6 +9,7 @@ import (
 type HandleMediaAttachedExecutor struct {
 	Sender         framework.MessageSender
 	ResourceModule modules.ResourceModule
+	EventModule    modules.EventModule
 }
 
 func (executor *HandleMediaAttachedExecutor)Execute(id framework.SessionID, event framework.Message,
@@ -50,7 +51,11 @@ func (executor *HandleMediaAttachedExecutor)Execute(id framework.SessionID, even
 		err = <- respChan
 		if err != nil{
 			log.Printf("[%08X] warning: update media status fail: %s", id, err)
+			return nil
 		}
+		var event = newStatusEvent(modules.EventMediaAttached, status)
+		event.Attributes = map[string]string{"media": mediaSource}
+		executor.EventModule.PublishEvent(event)
 		return nil
 	}
 }
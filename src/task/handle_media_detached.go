@@ -9,6 +9,7 @@ import (
 type HandleMediaDetachedExecutor struct {
 	Sender         framework.MessageSender
 	ResourceModule modules.ResourceModule
+	EventModule    modules.EventModule
 }
 
 func (executor *HandleMediaDetachedExecutor)Execute(id framework.SessionID, event framework.Message,
@@ -45,7 +46,9 @@ func (executor *HandleMediaDetachedExecutor)Execute(id framework.SessionID, even
 		err = <- respChan
 		if err != nil{
 			log.Printf("[%08X] warning: update media status fail: %s", id, err)
+			return nil
 		}
+		executor.EventModule.PublishEvent(newStatusEvent(modules.EventMediaDetached, status))
 		return nil
 	}
 }
@@ -28,6 +28,13 @@ func (executor *ModifyComputePoolExecutor)Execute(id framework.SessionID, reques
 	addressPool, _ := request.GetString(framework.ParamKeyNetwork)
 	var failover = false
 	failover, _ = request.GetBoolean(framework.ParamKeyOption)
+	strategy, _ := request.GetString(framework.ParamKeyPolicy)
+	var overcommit modules.PoolOvercommitUpdate
+	if options, _ := request.GetUIntArray(framework.ParamKeyLimit); 0 != len(options){
+		if err = overcommit.FromOptions(options); err != nil{
+			return err
+		}
+	}
 
 
 	resp, _ := framework.CreateJsonMessage(framework.ModifyComputePoolResponse)
@@ -43,7 +50,7 @@ func (executor *ModifyComputePoolExecutor)Execute(id framework.SessionID, reques
 	}
 
 	var respChan= make(chan error)
-	executor.ResourceModule.ModifyPool(pool, storagePool, addressPool, failover, respChan)
+	executor.ResourceModule.ModifyPool(pool, storagePool, addressPool, failover, strategy, overcommit, respChan)
 	err = <-respChan
 	if err != nil{
 		resp.SetError(err.Error())
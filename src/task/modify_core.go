@@ -46,6 +46,15 @@ func (executor *ModifyGuestCoreExecutor) Execute(id framework.SessionID, request
 			resp.SetError(err.Error())
 			return executor.Sender.SendMessage(resp, request.GetSender())
 		}
+		if cores > ins.Cores {
+			var errChan = make(chan error, 1)
+			executor.ResourceModule.CheckQuota(ins.User, ins.Group, modules.ResourceQuota{Cores: cores - ins.Cores}, errChan)
+			if err = <-errChan; err != nil {
+				log.Printf("[%08X] %s", id, err.Error())
+				resp.SetError(err.Error())
+				return executor.Sender.SendMessage(resp, request.GetSender())
+			}
+		}
 	}
 	{
 		//request modify core
@@ -47,6 +47,15 @@ func (executor *ModifyGuestMemoryExecutor) Execute(id framework.SessionID, reque
 			resp.SetError(err.Error())
 			return executor.Sender.SendMessage(resp, request.GetSender())
 		}
+		if memory > ins.Memory {
+			var errChan = make(chan error, 1)
+			executor.ResourceModule.CheckQuota(ins.User, ins.Group, modules.ResourceQuota{Memory: uint64(memory - ins.Memory)}, errChan)
+			if err = <-errChan; err != nil {
+				log.Printf("[%08X] %s", id, err.Error())
+				resp.SetError(err.Error())
+				return executor.Sender.SendMessage(resp, request.GetSender())
+			}
+		}
 	}
 	{
 		//request modify memory
@@ -22,7 +22,7 @@ func (executor *QueryAddressRangeExecutor)Execute(id framework.SessionID, reques
 		return
 	}
 	var respChan = make(chan modules.ResourceResult, 1)
-	executor.ResourceModule.QueryAddressRange(poolName, rangeType, respChan)
+	executor.ResourceModule.QueryAddressRange(poolName, rangeType, modules.UnmarshalListOptionFromMessage(request), respChan)
 	resp, _ := framework.CreateJsonMessage(framework.QueryAddressRangeResponse)
 	resp.SetFromSession(id)
 	resp.SetToSession(request.GetFromSession())
@@ -46,6 +46,7 @@ func (executor *QueryAddressRangeExecutor)Execute(id framework.SessionID, reques
 	resp.SetStringArray(framework.ParamKeyStart, startArray)
 	resp.SetStringArray(framework.ParamKeyEnd, endArray)
 	resp.SetStringArray(framework.ParamKeyMask, maskArray)
+	modules.MarshalListPageToMessage(result, resp)
 	log.Printf("[%08X] reply %d address range(s) to %s.[%08X]",
 		id, len(result.AddressRangeList), request.GetSender(), request.GetFromSession())
 	return executor.Sender.SendMessage(resp, request.GetSender())
@@ -22,15 +22,16 @@ func (executor *QueryInstanceStatusExecutor)Execute(id framework.SessionID, requ
 	if err == nil{
 		inCell = true
 	}
+	var option = modules.UnmarshalListOptionFromMessage(request)
 	var respChan = make(chan modules.ResourceResult)
 	if inCell{
 		//log.Printf("[%08X] request query instance status in cell '%s' from %s.[%08X]", id, cellName,
 		//	request.GetSender(), request.GetFromSession())
-		executor.ResourceModule.QueryInstanceStatusInCell(poolName, cellName, respChan)
+		executor.ResourceModule.QueryInstanceStatusInCell(poolName, cellName, option, respChan)
 	}else{
 		//log.Printf("[%08X] request query instance status in pool '%s' from %s.[%08X]", id, poolName,
 		//	request.GetSender(), request.GetFromSession())
-		executor.ResourceModule.QueryInstanceStatusInPool(poolName, respChan)
+		executor.ResourceModule.QueryInstanceStatusInPool(poolName, option, respChan)
 	}
 	result := <- respChan
 
@@ -47,6 +48,7 @@ func (executor *QueryInstanceStatusExecutor)Execute(id framework.SessionID, requ
 
 	var instances = result.InstanceList
 	modules.MarshalInstanceStatusListToMessage(instances, resp)
+	modules.MarshalListPageToMessage(result, resp)
 	resp.SetSuccess(true)
 	//log.Printf("[%08X] %d instance(s) available", id, len(instances))
 	return executor.Sender.SendMessage(resp, request.GetSender())
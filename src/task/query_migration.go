@@ -18,13 +18,13 @@ func (executor *QueryMigrationExecutor)Execute(id framework.SessionID, request f
 	resp.SetToSession(request.GetFromSession())
 	resp.SetFromSession(id)
 	var respChan = make(chan modules.ResourceResult, 1)
-	executor.ResourceModule.QueryMigration(respChan)
+	executor.ResourceModule.QueryMigration(modules.UnmarshalListOptionFromMessage(request), respChan)
 	var result = <- respChan
 	if result.Error != nil{
 		err = result.Error
-		log.Printf("[%08X] query migration fail: %s", err.Error())
+		log.Printf("[%08X] query migration fail: %s", id, err.Error())
 		resp.SetError(err.Error())
-		return nil
+		return executor.Sender.SendMessage(resp, request.GetSender())
 	}
 	var idList, errMessage []string
 	var finish, progress []uint64
@@ -48,6 +48,7 @@ func (executor *QueryMigrationExecutor)Execute(id framework.SessionID, request f
 	resp.SetUIntArray(framework.ParamKeyStatus, finish)
 	resp.SetUIntArray(framework.ParamKeyProgress, progress)
 	resp.SetStringArray(framework.ParamKeyError, errMessage)
+	modules.MarshalListPageToMessage(result, resp)
 	log.Printf("[%08X] %d migrations available", id, len(idList))
 	return executor.Sender.SendMessage(resp, request.GetSender())
 }
\ No newline at end of file
@@ -38,7 +38,7 @@ func (executor *QuerySecurityPolicyGroupsExecutor) Execute(id framework.SessionI
 	resp.SetTransactionID(request.GetTransactionID())
 	resp.SetSuccess(false)
 	var respChan = make(chan modules.ResourceResult, 1)
-	executor.ResourceModule.QuerySecurityPolicyGroups(condition, respChan)
+	executor.ResourceModule.QuerySecurityPolicyGroups(condition, modules.UnmarshalListOptionFromMessage(request), respChan)
 	var result = <- respChan
 	if result.Error != nil{
 		err = result.Error
@@ -82,6 +82,7 @@ func (executor *QuerySecurityPolicyGroupsExecutor) Execute(id framework.SessionI
 		resp.SetUIntArray(framework.ParamKeyAction, accept)
 		resp.SetUIntArray(framework.ParamKeyEnable, enabled)
 		resp.SetUIntArray(framework.ParamKeyLimit, global)
+		modules.MarshalListPageToMessage(result, resp)
 		resp.SetSuccess(true)
 	}
 	return executor.Sender.SendMessage(resp, request.GetSender())
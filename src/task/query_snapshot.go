@@ -54,6 +54,12 @@ func (executor *QuerySnapshotExecutor) Execute(id framework.SessionID, request f
 				//	log.Printf("[%08X] cell get snapshot success", id)
 				//}else{
 				log.Printf("[%08X] cell query snapshot fail: %s", id, cellResp.GetError())
+			} else if names, err := cellResp.GetStringArray(framework.ParamKeyName); nil == err {
+				var errChan = make(chan error, 1)
+				executor.ResourceModule.UpdateSnapshotCount(instanceID, uint(len(names)), errChan)
+				if err = <-errChan; err != nil {
+					log.Printf("[%08X] warning: update snapshot count fail: %s", id, err.Error())
+				}
 			}
 			cellResp.SetFromSession(id)
 			cellResp.SetToSession(request.GetFromSession())
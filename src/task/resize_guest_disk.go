@@ -58,6 +58,15 @@ func (executor *ResizeGuestDiskExecutor) Execute(id framework.SessionID, request
 			resp.SetError(err.Error())
 			return executor.Sender.SendMessage(resp, request.GetSender())
 		}
+		{
+			var errChan = make(chan error, 1)
+			executor.ResourceModule.CheckQuota(ins.User, ins.Group, modules.ResourceQuota{Disk: uint64(diskSize) - ins.Disks[diskIndex]}, errChan)
+			if err = <-errChan; err != nil {
+				log.Printf("[%08X] %s", id, err.Error())
+				resp.SetError(err.Error())
+				return executor.Sender.SendMessage(resp, request.GetSender())
+			}
+		}
 	}
 	{
 		//request delete
@@ -54,6 +54,24 @@ func (executor *StartBatchCreateGuestExecutor)Execute(id framework.SessionID, re
 		}
 	}
 
+	var serverGroup string
+	if serverGroup, _ = request.GetString(framework.ParamKeyAssign); "" != serverGroup{
+		var respChan = make(chan modules.ResourceResult, 1)
+		executor.ResourceModule.GetServerGroup(serverGroup, respChan)
+		var result = <- respChan
+		if result.Error != nil{
+			err = fmt.Errorf("get server group fail: %s", result.Error)
+			return
+		}
+	}
+
+	if tags, _ := request.GetStringArray(framework.ParamKeyTag); 0 != len(tags){
+		if _, err = modules.ParsePlacementSelector(tags); err != nil{
+			err = fmt.Errorf("invalid selector: %s", err.Error())
+			return
+		}
+	}
+
 	log.Printf("[%08X] recv batch create %d guests from %s.[%08X]", id, guestCount, request.GetSender(), request.GetFromSession())
 
 	resp, _ := framework.CreateJsonMessage(framework.StartBatchCreateGuestResponse)
@@ -66,7 +84,17 @@ func (executor *StartBatchCreateGuestExecutor)Execute(id framework.SessionID, re
 	var guestList []string
 	{
 		var respChan = make(chan modules.ResourceResult, 1)
-		var bathRequest = modules.BatchCreateRequest{modules.BatchCreateNameRule(nameRule), namePrefix, poolName, int(guestCount)}
+		var bathRequest = modules.BatchCreateRequest{
+			Rule: modules.BatchCreateNameRule(nameRule),
+			Prefix: namePrefix,
+			Pool: poolName,
+			Count: int(guestCount),
+		}
+		bathRequest.User, _ = request.GetString(framework.ParamKeyUser)
+		bathRequest.Group, _ = request.GetString(framework.ParamKeyGroup)
+		bathRequest.Resource.Cores, _ = request.GetUInt(framework.ParamKeyCore)
+		bathRequest.Resource.Memory, _ = request.GetUInt(framework.ParamKeyMemory)
+		bathRequest.Resource.Disks, _ = request.GetUIntArray(framework.ParamKeyDisk)
 		executor.ResourceModule.StartBatchCreateGuest(bathRequest, respChan)
 		var result = <- respChan
 		if result.Error != nil{
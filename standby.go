@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/project-nano/core/modules"
+	"github.com/project-nano/framework"
+	"github.com/project-nano/sonar"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	standbyCheckInterval    = time.Second * 2
+	standbyQueryTimeout     = time.Second * 3
+	standbyFailureThreshold = 3
+	standbyFenceInterval    = time.Second * 5
+	takeoverConfirmFilename = "takeover.confirm"
+	takeoverConfirmValidity = time.Minute * 10
+)
+
+// StandbyMonitor : replicate persistent data from active core, take over when the active
+// core disappears from group and stops serving replication. Take over requires confirmation
+// of operator unless auto take over enabled, then previous active core fenced with new epoch.
+type StandbyMonitor struct {
+	service       *MainService
+	activeAddress string
+	replica       *modules.ResourceReplica
+	pinger        *sonar.Pinger
+	failures      int
+	awaiting      bool
+	epoch         uint64
+	fenceFailures int
+	sealError     error
+	runner        *framework.SimpleRunner
+}
+
+// probeActiveCore : address of core service published in group
+func probeActiveCore(config DomainConfig) (address string, err error) {
+	var pinger *sonar.Pinger
+	if pinger, err = sonar.CreatePinger(config.GroupAddress, config.GroupPort, config.Domain); err != nil {
+		return
+	}
+	return queryCoreService(pinger)
+}
+
+func queryCoreService(pinger *sonar.Pinger) (address string, err error) {
+	var echo sonar.Echo
+	if echo, err = pinger.Query(standbyQueryTimeout); err != nil {
+		return
+	}
+	for _, service := range echo.Services {
+		if framework.ServiceTypeStringCore == service.Type {
+			return service.Address, nil
+		}
+	}
+	err = errors.New("no core service published")
+	return
+}
+
+func CreateStandbyMonitor(service *MainService, activeAddress string) (monitor *StandbyMonitor, err error) {
+	monitor = &StandbyMonitor{service: service, activeAddress: activeAddress}
+	if monitor.replica, err = modules.OpenResourceReplica(service.configPath, service.dataPath, service.config.ReplicationSecret); err != nil {
+		return
+	}
+	monitor.runner = framework.CreateSimpleRunner(monitor.routine)
+	return monitor, nil
+}
+
+func (monitor *StandbyMonitor) Start() error {
+	return monitor.runner.Start()
+}
+
+func (monitor *StandbyMonitor) Stop() error {
+	return monitor.runner.Stop()
+}
+
+func (monitor *StandbyMonitor) GetDataPath() string {
+	return monitor.replica.GetDataPath()
+}
+
+func (monitor *StandbyMonitor) GetStatus() string {
+	var lastSync = "never"
+	if synchronized := monitor.replica.LastSynchronized(); !synchronized.IsZero() {
+		lastSync = synchronized.Format(modules.TimeFormatLayout)
+	}
+	var status = fmt.Sprintf("standby for active core '%s' of epoch %d, last synchronized %s", monitor.activeAddress,
+		monitor.replica.GetEpoch(), lastSync)
+	if nil != monitor.sealError {
+		status += fmt.Sprintf(", not ready: %s", monitor.sealError.Error())
+	}
+	if monitor.awaiting {
+		status += fmt.Sprintf(", active core lost, run '%s %s' to confirm take over", ExecuteName, TakeoverCommand)
+	}
+	return status
+}
+
+func (monitor *StandbyMonitor) routine(c framework.RoutineController) {
+	log.Printf("<standby> started, active core '%s', replication port %d", monitor.activeAddress, monitor.service.config.ReplicationPort)
+	var checkTicker = time.NewTicker(standbyCheckInterval)
+	monitor.synchronize()
+	for !c.IsStopping() {
+		select {
+		case <-c.GetNotifyChannel():
+			c.SetStopping()
+		case <-checkTicker.C:
+			if 0 != monitor.epoch {
+				//already took over
+				if !monitor.fencePreviousCore() {
+					break
+				}
+				checkTicker.Stop()
+				c.NotifyExit()
+				return
+			}
+			if !monitor.checkActiveCore() {
+				break
+			}
+			if !monitor.takeOver() {
+				checkTicker.Stop()
+				c.NotifyExit()
+				return
+			}
+			checkTicker.Reset(standbyFenceInterval)
+			if monitor.fencePreviousCore() {
+				checkTicker.Stop()
+				c.NotifyExit()
+				return
+			}
+		}
+	}
+	checkTicker.Stop()
+	if err := monitor.replica.Close(); err != nil {
+		log.Printf("<standby> close replica fail: %s", err.Error())
+	}
+	log.Println("<standby> stopped")
+	c.NotifyExit()
+}
+
+// synchronize : a replica with mismatched seal key never becomes ready, report it loudly once until fixed
+func (monitor *StandbyMonitor) synchronize() error {
+	updated, err := monitor.replica.Synchronize(monitor.activeAddress, monitor.service.config.ReplicationPort)
+	if errors.Is(err, modules.ErrSealKeyMismatch) {
+		if nil == monitor.sealError || monitor.sealError.Error() != err.Error() {
+			log.Printf("<standby> error: %s, standby not ready and never takes over until fixed", err.Error())
+		}
+		monitor.sealError = err
+		return err
+	} else if nil != monitor.sealError && nil == err {
+		log.Println("<standby> seal key verified with active core")
+		monitor.sealError = nil
+	}
+	if err != nil {
+		return err
+	}
+	if updated {
+		log.Printf("<standby> resource data replicated from '%s'", monitor.activeAddress)
+	}
+	return nil
+}
+
+// checkActiveCore : return true when active core should be taken over
+func (monitor *StandbyMonitor) checkActiveCore() (lost bool) {
+	if nil == monitor.pinger {
+		var err error
+		if monitor.pinger, err = sonar.CreatePinger(monitor.service.config.GroupAddress, monitor.service.config.GroupPort,
+			monitor.service.config.Domain); err != nil {
+			log.Printf("<standby> create pinger fail: %s", err.Error())
+			return false
+		}
+	}
+	address, queryError := queryCoreService(monitor.pinger)
+	if nil != queryError {
+		//receiver closed when query timeout
+		monitor.pinger = nil
+	} else if address != monitor.activeAddress {
+		log.Printf("<standby> active core changed from '%s' to '%s'", monitor.activeAddress, address)
+		monitor.activeAddress = address
+	}
+	var syncError = monitor.synchronize()
+	if nil == queryError || nil == syncError {
+		if 0 != monitor.failures {
+			log.Printf("<standby> active core '%s' recovered", monitor.activeAddress)
+			monitor.failures = 0
+			monitor.awaiting = false
+		}
+		if nil != syncError {
+			log.Printf("<standby> warning: replicate from '%s' fail: %s", monitor.activeAddress, syncError.Error())
+		}
+		return false
+	}
+	if monitor.failures < standbyFailureThreshold {
+		monitor.failures++
+		log.Printf("<standby> active core '%s' lost (%d/%d), query: %s, replicate: %s", monitor.activeAddress,
+			monitor.failures, standbyFailureThreshold, queryError.Error(), syncError.Error())
+		if monitor.failures < standbyFailureThreshold {
+			return false
+		}
+	}
+	if nil != monitor.sealError {
+		log.Printf("<standby> error: can not take over, %s", monitor.sealError.Error())
+		return false
+	}
+	if monitor.replica.LastSynchronized().IsZero() {
+		log.Println("<standby> warning: no resource data replicated yet, keep waiting for active core")
+		return false
+	}
+	if monitor.service.config.AutoTakeover {
+		return true
+	}
+	return monitor.takeoverConfirmed()
+}
+
+// takeoverConfirmed : lost core may still serve cells behind a partition, only take over when
+// operator confirmed that it stopped
+func (monitor *StandbyMonitor) takeoverConfirmed() bool {
+	var confirmFile = filepath.Join(monitor.service.dataPath, takeoverConfirmFilename)
+	info, err := os.Stat(confirmFile)
+	if err != nil {
+		if !monitor.awaiting {
+			log.Printf("<standby> active core '%s' lost, stop it then run '%s %s' to confirm take over",
+				monitor.activeAddress, ExecuteName, TakeoverCommand)
+			monitor.awaiting = true
+		}
+		return false
+	}
+	if err = os.Remove(confirmFile); err != nil {
+		log.Printf("<standby> remove take over confirmation fail: %s", err.Error())
+		return false
+	}
+	if time.Since(info.ModTime()) > takeoverConfirmValidity {
+		log.Printf("<standby> warning: take over confirmation created at %s expired, confirm again",
+			info.ModTime().Format(modules.TimeFormatLayout))
+		return false
+	}
+	log.Println("<standby> take over confirmed by operator")
+	return true
+}
+
+// takeOver : start as active with epoch newer than replicated one
+func (monitor *StandbyMonitor) takeOver() bool {
+	var epoch = monitor.replica.GetEpoch() + 1
+	log.Printf("<standby> take over active core '%s' with epoch %d, data last synchronized at %s",
+		monitor.activeAddress, epoch, monitor.replica.LastSynchronized().Format(modules.TimeFormatLayout))
+	if err := monitor.replica.Close(); err != nil {
+		log.Printf("<standby> close replica fail: %s", err.Error())
+	}
+	if err := modules.SaveHAState(monitor.service.dataPath, modules.HAState{Epoch: epoch}); err != nil {
+		log.Printf("<standby> save epoch fail: %s", err.Error())
+		return false
+	}
+	output, err := monitor.service.startActive()
+	if err != nil {
+		log.Printf("<standby> take over fail: %s", err.Error())
+		return false
+	}
+	monitor.epoch = epoch
+	monitor.awaiting = false
+	log.Printf("<standby> became active: %s", output)
+	return true
+}
+
+// fencePreviousCore : return true when previous active core acknowledged new epoch,
+// step down when it holds a newer one
+func (monitor *StandbyMonitor) fencePreviousCore() (finished bool) {
+	var fence = modules.ReplicationFence{Epoch: monitor.epoch, Address: monitor.service.config.ListenAddress}
+	result, err := modules.SendReplicationFence(monitor.activeAddress, monitor.service.config.ReplicationPort,
+		monitor.service.config.ReplicationSecret, fence)
+	if err != nil {
+		monitor.fenceFailures++
+		if 1 == monitor.fenceFailures {
+			log.Printf("<standby> fence previous core '%s' fail, keep trying: %s", monitor.activeAddress, err.Error())
+		}
+		return false
+	}
+	if result.SteppedDown {
+		log.Printf("<standby> previous core '%s' of epoch %d stepped down", monitor.activeAddress, result.Epoch)
+		return true
+	}
+	if result.Epoch > monitor.epoch {
+		log.Printf("<standby> core '%s' holds newer epoch %d than local epoch %d, step down",
+			monitor.activeAddress, result.Epoch, monitor.epoch)
+		go monitor.service.stepDown(monitor.activeAddress)
+		return true
+	}
+	log.Printf("<standby> warning: core '%s' also active with epoch %d, stop one of them", monitor.activeAddress, result.Epoch)
+	return false
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"github.com/project-nano/core/imageserver"
+	"github.com/project-nano/core/modules"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStandbyMonitor_TakeoverConfirmed(t *testing.T) {
+	var testCases = []struct {
+		Name      string
+		Confirmed bool
+		Age       time.Duration
+		Expected  bool
+	}{
+		{"not confirmed", false, 0, false},
+		{"confirmed", true, 0, true},
+		{"confirmation expired", true, takeoverConfirmValidity + time.Minute, false},
+	}
+	for _, testCase := range testCases {
+		var service = &MainService{dataPath: t.TempDir()}
+		var monitor = &StandbyMonitor{service: service, activeAddress: "active"}
+		var confirmFile = filepath.Join(service.dataPath, takeoverConfirmFilename)
+		if testCase.Confirmed {
+			if err := ioutil.WriteFile(confirmFile, []byte("confirmed"), DefaultFilePerm); err != nil {
+				t.Fatalf("%s: write confirmation fail: %s", testCase.Name, err.Error())
+			}
+			var modified = time.Now().Add(-testCase.Age)
+			if err := os.Chtimes(confirmFile, modified, modified); err != nil {
+				t.Fatalf("%s: change confirmation time fail: %s", testCase.Name, err.Error())
+			}
+		}
+		if confirmed := monitor.takeoverConfirmed(); confirmed != testCase.Expected {
+			t.Fatalf("%s: confirmed %t, %t expected", testCase.Name, confirmed, testCase.Expected)
+		}
+		if monitor.awaiting == testCase.Confirmed {
+			t.Fatalf("%s: unexpected awaiting %t", testCase.Name, monitor.awaiting)
+		}
+		if _, err := os.Stat(confirmFile); !os.IsNotExist(err) {
+			t.Fatalf("%s: confirmation not consumed", testCase.Name)
+		}
+		if testCase.Expected && monitor.takeoverConfirmed() {
+			t.Fatalf("%s: confirmation used twice", testCase.Name)
+		}
+	}
+}
+
+func TestStandbyMonitor_ImageDataReplicated(t *testing.T) {
+	if modules.ImageDataFilename != imageserver.ImageDataFilename {
+		t.Fatalf("replicated image data '%s' differs from '%s' saved by image service",
+			modules.ImageDataFilename, imageserver.ImageDataFilename)
+	}
+}